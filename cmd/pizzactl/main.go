@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+const usage = `usage: pizzactl [-config file] [-json] [-dry-run] <command>
+
+commands:
+  bootstrap         create missing Fauna collections and indexes, or the
+                    tables of database.driver, and check the calendar and
+                    email credentials
+  check-templates   render every template with sample data and report errors
+  migrate-ids       give events made before stable IDs their old timestamp ID
+  retention         purge or anonymize records past the retention config
+
+With -dry-run, bootstrap, migrate-ids, and retention only report what they
+would change.
+`
+
+func main() {
+	configFile := flag.String("config", "configs/pizza.yaml", "config file")
+	jsonOutput := flag.Bool("json", false, "print results as JSON")
+	dryRun := flag.Bool("dry-run", false, "report what would change without changing it")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "bootstrap":
+		config, err := pizza.LoadConfig(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not load config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(bootstrap(config, *dryRun, *jsonOutput))
+	case "check-templates":
+		os.Exit(checkTemplates(*jsonOutput))
+	case "migrate-ids":
+		openStore(*configFile)
+		os.Exit(migrateIDs(*dryRun, *jsonOutput))
+	case "retention":
+		config := openStore(*configFile)
+		os.Exit(retention(config.Retention, *dryRun, *jsonOutput))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// openStore loads the config and connects to its database, or exits.
+func openStore(configFile string) pizza.Config {
+	config, err := pizza.LoadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err = pizza.OpenStore(config.Database); err != nil {
+		fmt.Fprintf(os.Stderr, "could not open the database: %v\n", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+func bootstrap(config pizza.Config, dryRun, jsonOutput bool) int {
+	var steps []pizza.BootstrapStep
+	var err error
+	if len(config.Database.Driver) > 0 {
+		steps, err = pizza.BootstrapSQL(config.Database, dryRun)
+	} else if err = pizza.OpenStore(config.Database); err != nil {
+		steps = []pizza.BootstrapStep{{Kind: "database", Name: "fauna", Error: err.Error()}}
+	} else {
+		steps, err = pizza.BootstrapFauna(dryRun)
+	}
+	if err == nil {
+		steps = append(steps, pizza.BootstrapCalendar(config.Calendar))
+		if step := pizza.BootstrapEmail(config.Email); step != nil {
+			steps = append(steps, *step)
+		}
+	}
+
+	failed := false
+	for _, step := range steps {
+		failed = failed || len(step.Error) > 0
+	}
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			OK    bool                  `json:"ok"`
+			Steps []pizza.BootstrapStep `json:"steps"`
+		}{!failed, steps})
+	} else {
+		for _, step := range steps {
+			switch {
+			case len(step.Error) > 0:
+				fmt.Printf("FAIL    %s %s: %s\n", step.Kind, step.Name, step.Error)
+			case step.Created && dryRun:
+				fmt.Printf("would create %s %s\n", step.Kind, step.Name)
+			case step.Created:
+				fmt.Printf("created %s %s\n", step.Kind, step.Name)
+			default:
+				fmt.Printf("ok      %s %s\n", step.Kind, step.Name)
+			}
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func migrateIDs(dryRun, jsonOutput bool) int {
+	migrated, err := pizza.MigrateFridayUIDs(dryRun)
+	if jsonOutput {
+		out := struct {
+			OK       bool   `json:"ok"`
+			Migrated int    `json:"migrated"`
+			Error    string `json:"error,omitempty"`
+		}{OK: err == nil, Migrated: migrated}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		json.NewEncoder(os.Stdout).Encode(out)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "could not migrate event ids: %v\n", err)
+	} else if dryRun {
+		fmt.Printf("would have migrated %d events\n", migrated)
+	} else {
+		fmt.Printf("migrated %d events\n", migrated)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func retention(config pizza.RetentionConfig, dryRun, jsonOutput bool) int {
+	pizza.ApplyRetention(config)
+	results := pizza.RunRetention(time.Now(), dryRun)
+	failed := false
+	for _, result := range results {
+		failed = failed || len(result.Error) > 0
+	}
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			OK      bool                    `json:"ok"`
+			Results []pizza.RetentionResult `json:"results"`
+		}{!failed, results})
+	} else {
+		verb := "purged"
+		if config.Anonymize {
+			verb = "anonymized"
+		}
+		if dryRun {
+			verb = "would have " + verb
+		}
+		for _, result := range results {
+			if len(result.Error) > 0 {
+				fmt.Printf("FAIL    %s: %s\n", result.Name, result.Error)
+			} else {
+				fmt.Printf("%s %d %s records from before %s\n", verb, result.Count, result.Name, result.Before.Format("2006-01-02"))
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("no retention policies are set")
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func checkTemplates(jsonOutput bool) int {
+	problems := pizza.CheckTemplateRenders(pizza.StaticDir)
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			OK       bool     `json:"ok"`
+			Problems []string `json:"problems"`
+		}{len(problems) == 0, problems})
+	} else {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) == 0 {
+			fmt.Println("all templates render")
+		}
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -5,6 +5,8 @@ go 1.19
 require (
 	github.com/fauna/faunadb-go/v4 v4.2.0
 	github.com/gorilla/mux v1.8.0
+	github.com/lib/pq v1.10.7
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/stretchr/testify v1.8.1
 	go.uber.org/zap v1.23.0
 	golang.org/x/oauth2 v0.4.0
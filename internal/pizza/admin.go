@@ -0,0 +1,955 @@
+package pizza
+
+import (
+	"html/template"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// AdminPassword is the HTTP basic auth password for the admin pages. The
+// admin pages are not found when it is empty.
+var AdminPassword = ""
+
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireAdminPassword(requireAdminCode(requireAdminCSRF(next)))
+}
+
+// AdminView is embedded in the data of admin pages with forms.
+type AdminView struct {
+	// CSRFToken goes in a csrf field of every form, checked by
+	// requireAdminCSRF
+	CSRFToken string
+}
+
+func newAdminView(w http.ResponseWriter, r *http.Request) AdminView {
+	return AdminView{CSRFToken: browserCSRFToken(w, r)}
+}
+
+// requireAdminCSRF refuses forms posted from other sites, which the browser
+// would send the admin password along with.
+func requireAdminCSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		var err error
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			err = r.ParseMultipartForm(MaxBodySize)
+		} else {
+			err = r.ParseForm()
+		}
+		if bodyTooLarge(err) {
+			writeTooLarge(w, r)
+			return
+		} else if err != nil || !checkBrowserCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdminPassword lets through requests with the admin password, or the
+// owner's own once the series was transferred, before the second factor is
+// checked.
+func requireAdminPassword(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(AdminPassword) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		_, password, ok := r.BasicAuth()
+		if ok {
+			var err error
+			if ok, err = checkAdminPassword(password); err != nil {
+				Handle500(w, r)
+				return
+			}
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="rsvp.pizza admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+type AdminSettingData struct {
+	Name     string
+	Help     string
+	Value    string
+	Override string
+	Default  string
+}
+
+type AdminSettingsPageData struct {
+	AdminView
+	Settings []AdminSettingData
+	Saved    bool
+	Error    string
+}
+
+// HandleAdminSettings shows the runtime settings and saves overrides. Leaving
+// a setting blank goes back to the config file value.
+func HandleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/settings.html")
+	if err != nil {
+		Log.Error("template admin settings failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminSettingsPageData{AdminView: newAdminView(w, r)}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		overrides := map[string]string{}
+		for _, setting := range Settings {
+			if value := strings.TrimSpace(r.PostForm.Get(setting.Name)); len(value) > 0 {
+				overrides[setting.Name] = value
+			}
+		}
+		if isDryRun(r) {
+			var plan Plan
+			if plan, err = PlanSettings(overrides); err == nil {
+				writePlan(w, r, plan)
+				return
+			}
+		} else {
+			err = SaveSettingOverrides(overrides)
+		}
+		if err == ErrSettingInvalid {
+			data.Error = "One of the settings isn't valid."
+		} else if err != nil {
+			Handle500(w, r)
+			return
+		} else {
+			data.Saved = true
+		}
+	}
+
+	overrides, err := settingsCache.Get("runtime")
+	if err != nil {
+		Log.Error("failed to load settings", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	for _, setting := range Settings {
+		data.Settings = append(data.Settings, AdminSettingData{
+			Name:     setting.Name,
+			Help:     setting.Help,
+			Value:    setting.Value(),
+			Override: overrides[setting.Name],
+			Default:  fileSettings[setting.Name],
+		})
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminImagesPageData struct {
+	AdminView
+	FridayID string
+	Date     string
+	Cover    Image
+	Images   []Image
+	Saved    bool
+	Error    string
+}
+
+// HandleAdminImages uploads photos for a Friday, or its cover image, and
+// shows the ones it already has.
+func HandleAdminImages(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/images.html")
+	if err != nil {
+		Log.Error("template admin images failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := AdminImagesPageData{AdminView: newAdminView(w, r), FridayID: id, Date: FormatTime(friday.Start)}
+
+	if r.Method == http.MethodPost && blobStore == nil {
+		data.Error = "Uploads are off until uploadDir is set in the config."
+	} else if r.Method == http.MethodPost {
+		if err = r.ParseMultipartForm(MaxBodySize); bodyTooLarge(err) {
+			writeTooLarge(w, r)
+			return
+		} else if err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		cover := r.PostForm.Get("kind") == "cover"
+		for _, header := range r.MultipartForm.File["image"] {
+			img, err := storeUpload(header, "fridays/"+id)
+			if err == ErrImageInvalid || err == ErrUploadType {
+				data.Error = header.Filename + " isn't a supported image."
+				break
+			} else if err == ErrUploadInfected {
+				data.Error = header.Filename + " failed the virus scan."
+				break
+			} else if err != nil {
+				Log.Error("failed to store image", zap.Error(err), zap.String("id", id))
+				Handle500(w, r)
+				return
+			}
+			if err = AddFridayImage(id, img, cover); err != nil {
+				Handle500(w, r)
+				return
+			}
+			if cover {
+				friday.Cover = img
+			} else {
+				friday.Images = append(friday.Images, img)
+			}
+			data.Saved = true
+		}
+	}
+
+	data.Cover = friday.Cover
+	data.Images = friday.Images
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+func storeUpload(header *multipart.FileHeader, prefix string) (Image, error) {
+	file, err := header.Open()
+	if err != nil {
+		return Image{}, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return Image{}, err
+	}
+	if err = CheckUpload(header.Filename, data); err != nil {
+		return Image{}, err
+	}
+	return StoreImage(prefix, data)
+}
+
+type AdminQuarantinedData struct {
+	ID      string
+	Form    string
+	Reason  string
+	Created string
+	Values  string
+}
+
+type AdminQuarantinePageData struct {
+	AdminView
+	Held []AdminQuarantinedData
+}
+
+// HandleAdminQuarantine lists the public form submissions held as possible
+// spam so they can be released or discarded.
+func HandleAdminQuarantine(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/quarantine.html")
+	if err != nil {
+		Log.Error("template admin quarantine failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		q, err := GetQuarantined(r.PostForm.Get("id"))
+		if err != nil {
+			Handle500(w, r)
+			return
+		} else if q == nil {
+			Handle4xx(w, r)
+			return
+		}
+		if r.PostForm.Get("action") == "release" {
+			err = ReleaseQuarantined(*q)
+		} else {
+			err = DeleteQuarantined(q.ID)
+		}
+		if err != nil {
+			Log.Error("failed to review quarantined submission", zap.Error(err), zap.String("id", q.ID))
+			Handle500(w, r)
+			return
+		}
+	}
+
+	held, err := ListQuarantined()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminQuarantinePageData{AdminView: newAdminView(w, r)}
+	for _, q := range held {
+		data.Held = append(data.Held, AdminQuarantinedData{
+			ID:      q.ID,
+			Form:    q.Form,
+			Reason:  q.Reason,
+			Created: FormatTime(q.CreatedAt),
+			Values:  url.Values(q.Values).Encode(),
+		})
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminContentData struct {
+	Name     string
+	Title    string
+	Markdown string
+	Preview  template.HTML
+}
+
+type AdminContentPageData struct {
+	AdminView
+	Sections []AdminContentData
+	Saved    bool
+}
+
+// HandleAdminContent edits the markdown copy shown on the index page.
+func HandleAdminContent(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/content.html")
+	if err != nil {
+		Log.Error("template admin content failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminContentPageData{AdminView: newAdminView(w, r)}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		content := map[string]string{}
+		for _, section := range ContentSections {
+			if md := strings.TrimSpace(r.PostForm.Get(section.Name)); len(md) > 0 {
+				content[section.Name] = md
+			}
+		}
+		if err = SaveIndexContent(content); err != nil {
+			Handle500(w, r)
+			return
+		}
+		data.Saved = true
+	}
+
+	content, err := contentCache.Get("index")
+	if err != nil {
+		Log.Error("failed to load content", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	for _, section := range ContentSections {
+		data.Sections = append(data.Sections, AdminContentData{
+			Name:     section.Name,
+			Title:    section.Title,
+			Markdown: content[section.Name],
+			Preview:  template.HTML(RenderMarkdown(content[section.Name])),
+		})
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminGuestData struct {
+	// ID is the RSVP's, to approve or decline it while it is pending
+	ID       string
+	Name     string
+	Email    string
+	PlusOnes int
+	Kids     int
+	Status   string
+	// Note is the host's private note about the friend
+	Note string
+	// Groups are the groups the host tagged the friend with for seating
+	Groups string
+	// Days are the days of a multi-day event the friend is coming, or empty
+	// for every day
+	Days      []string
+	CheckedIn bool
+	Arrival   string
+	Late      bool
+	// Birthday is set when the party is near the friend's birthday
+	Birthday bool
+}
+
+type AdminGuestsPageData struct {
+	AdminView
+	FridayID string
+	// Cohosts are the emails of the people helping host this event
+	Cohosts   []string
+	Date      string
+	Headcount int
+	// Count splits the headcount into adults and kids for the pizza order
+	Count GuestCount
+	// Days are set for multi-day events
+	Days   []DayHeadcount
+	Guests []AdminGuestData
+	// Message says how approving or declining an RSVP went
+	Message string
+}
+
+// HandleAdminGuests lists who RSVPed to a Friday with the host's notes about
+// each friend, which can be edited from the list, and approves or declines
+// the RSVPs waiting for a spot.
+func HandleAdminGuests(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/guests.html")
+	if err != nil {
+		Log.Error("template admin guests failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+
+	message := ""
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		switch action := r.PostForm.Get("action"); action {
+		case "approve", "decline":
+			rsvp, err := GetFridayRSVP(r.PostForm.Get("rsvp"))
+			if err != nil {
+				Handle500(w, r)
+				return
+			} else if rsvp == nil || rsvp.FridayID != id {
+				Handle4xx(w, r)
+				return
+			}
+			name, _ := GetCachedFriendName(rsvp.Email)
+			if len(name) == 0 {
+				name = rsvp.Email
+			}
+			if _, err = ReviewRSVP(rsvp.ID, action == "approve"); err == ErrRSVPReviewed {
+				message = "The RSVP of " + name + " was already approved or declined."
+			} else if err != nil {
+				Log.Error("failed to review rsvp", zap.Error(err), zap.String("id", rsvp.ID))
+				Handle500(w, r)
+				return
+			} else if action == "approve" {
+				message = "Approved " + name + ", who was emailed they're in."
+			} else {
+				message = "Declined " + name + ", who was emailed."
+			}
+		default:
+			email := r.PostForm.Get("email")
+			if err = SetFriendNote(email, strings.TrimSpace(r.PostForm.Get("note"))); err != nil {
+				Handle500(w, r)
+				return
+			}
+			if err = SetFriendGroups(email, parseList(r.PostForm.Get("groups"))); err != nil {
+				Handle500(w, r)
+				return
+			}
+		}
+	}
+
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminGuestsPageData{AdminView: newAdminView(w, r), FridayID: id, Date: FormatTime(friday.Start), Headcount: Headcount(rsvps), Count: CountGuests(rsvps), Cohosts: friday.Cohosts,
+		Message: message}
+	days := friday.Days()
+	if len(days) > 1 {
+		data.Days = DayHeadcounts(*friday, rsvps)
+	}
+	birthdays, err := ListFriendBirthdays()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	for _, rsvp := range rsvps {
+		guest := AdminGuestData{ID: rsvp.ID, Email: rsvp.Email, PlusOnes: rsvp.PlusOnes, Kids: rsvp.Kids, Status: rsvp.Status, CheckedIn: rsvp.CheckedIn,
+			Arrival: FormatArrival(rsvp.Arrival), Late: rsvp.Late}
+		for _, day := range days {
+			if len(rsvp.Days) > 0 && rsvp.Attends(day.Date) {
+				guest.Days = append(guest.Days, day.Label)
+			}
+		}
+		if len(guest.Status) == 0 {
+			guest.Status = RSVPStatusConfirmed
+		}
+		if birthday, ok := birthdays[rsvp.Email]; ok {
+			guest.Birthday = BirthdayNear(birthday, friday.Start)
+		}
+		guest.Name, _ = GetCachedFriendName(rsvp.Email)
+		if guest.Note, err = GetFriendNote(rsvp.Email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		groups, err := GetFriendGroups(rsvp.Email)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		guest.Groups = strings.Join(groups, ", ")
+		data.Guests = append(data.Guests, guest)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminDuplicatesPageData struct {
+	AdminView
+	Groups [][]Friend
+	Merged int
+}
+
+// mergeTarget names the friends a merge removes, for confirming it.
+func mergeTarget(r *http.Request) (string, error) {
+	primary := r.PostForm.Get("primary")
+	var duplicates []string
+	for _, duplicate := range r.PostForm["email"] {
+		if duplicate != primary {
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+	if len(duplicates) == 0 {
+		return "", nil
+	}
+	return strings.Join(duplicates, ", ") + " into " + primary, nil
+}
+
+// HandleAdminDuplicates lists friends that are likely the same person and
+// merges a group into the friend the host picks.
+func HandleAdminDuplicates(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/duplicates.html")
+	if err != nil {
+		Log.Error("template admin duplicates failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminDuplicatesPageData{AdminView: newAdminView(w, r)}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		primary := r.PostForm.Get("primary")
+		if isDryRun(r) {
+			plan := Plan{Action: "merge friends"}
+			for _, duplicate := range r.PostForm["email"] {
+				merge, err := PlanMergeFriends(primary, duplicate)
+				if err != nil {
+					Handle500(w, r)
+					return
+				}
+				plan.Store = append(plan.Store, merge.Store...)
+				plan.Calendar = append(plan.Calendar, merge.Calendar...)
+			}
+			writePlan(w, r, plan)
+			return
+		}
+		for _, duplicate := range r.PostForm["email"] {
+			if duplicate == primary {
+				continue
+			}
+			if err = MergeFriends(primary, duplicate); err != nil {
+				Log.Error("failed to merge friends", zap.Error(err), zap.String("primary", primary), zap.String("duplicate", duplicate))
+				Handle500(w, r)
+				return
+			}
+			data.Merged++
+		}
+	}
+
+	friends, err := SearchFriends("")
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data.Groups = FindDuplicateFriends(friends)
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+// deleteFridayTarget names an event to be deleted, for confirming it. Events
+// nobody RSVPed to are deleted without asking.
+func deleteFridayTarget(r *http.Request) (string, error) {
+	id := mux.Vars(r)["id"]
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil || len(rsvps) == 0 {
+		return "", err
+	}
+	friday, err := GetFriday(id)
+	if err != nil || friday == nil {
+		return "", err
+	}
+	return FormatTime(friday.Start) + " with " + strconv.Itoa(len(rsvps)) + " RSVPs", nil
+}
+
+// HandleAdminDeleteFriday deletes an event with its RSVPs and cancels it on
+// the calendar.
+func HandleAdminDeleteFriday(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if isDryRun(r) {
+		if plan, err := PlanDeleteFriday(id); err == ErrRSVPNotFound {
+			Handle4xx(w, r)
+		} else if err != nil {
+			Handle500(w, r)
+		} else {
+			writePlan(w, r, plan)
+		}
+		return
+	}
+	if err := DeleteFriday(id); err == ErrRSVPNotFound {
+		Handle4xx(w, r)
+		return
+	} else if err != nil {
+		Handle500(w, r)
+		return
+	}
+	if err := DeleteCalendarEvent(id); err != nil {
+		Log.Warn("failed to delete calendar event", zap.Error(err), zap.String("eventID", id))
+	}
+	if err := UnmirrorFriday(id); err != nil {
+		Log.Warn("failed to delete mirrored event", zap.Error(err), zap.String("eventID", id))
+	}
+	Log.Info("deleted friday", zap.String("id", id))
+	fridayCache.Clear()
+	apiFridaysCache.Clear()
+	http.Redirect(w, r, "/admin/fridays", http.StatusSeeOther)
+}
+
+type AdminFriendsPageData struct {
+	AdminView
+	Friends []Friend
+	Query   string
+	Added   string
+	Removed string
+	Error   string
+}
+
+// HandleAdminFriends lists the friends allowed to RSVP, adds new ones, and
+// removes friends, keeping their past RSVPs for the recaps and stats.
+func HandleAdminFriends(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/friends.html")
+	if err != nil {
+		Log.Error("template admin friends failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminFriendsPageData{AdminView: newAdminView(w, r), Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		email := strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+		switch r.PostForm.Get("action") {
+		case "add":
+			name := strings.TrimSpace(r.PostForm.Get("name"))
+			if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+				data.Error = "That doesn't look like an email address."
+			} else if len(name) == 0 {
+				data.Error = "Friends need a name."
+			} else if allowed, err := IsFriendAllowed(email); err != nil {
+				Handle500(w, r)
+				return
+			} else if allowed {
+				data.Error = email + " is already a friend."
+			} else if isDryRun(r) {
+				writePlan(w, r, PlanAddFriend(name, email))
+				return
+			} else if err = AddFriend(name, email); err != nil {
+				Handle500(w, r)
+				return
+			} else {
+				Log.Info("added friend", zap.String("email", email))
+				data.Added = name
+			}
+		case "remove":
+			if isDryRun(r) {
+				plan, err := PlanRemoveFriend(email)
+				if err != nil {
+					Handle500(w, r)
+					return
+				}
+				writePlan(w, r, plan)
+				return
+			}
+			if err = DeleteFriend(email); err != nil {
+				Handle500(w, r)
+				return
+			}
+			Log.Info("removed friend", zap.String("email", email))
+			data.Removed = email
+		default:
+			Handle4xx(w, r)
+			return
+		}
+	}
+
+	if data.Friends, err = SearchFriends(data.Query); err != nil {
+		Handle500(w, r)
+		return
+	}
+	sort.Slice(data.Friends, func(i, j int) bool {
+		return strings.ToLower(data.Friends[i].Name) < strings.ToLower(data.Friends[j].Name)
+	})
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminSearchComment struct {
+	APISearchComment
+	Date string
+}
+
+type AdminSearchPageData struct {
+	Query    string
+	Searched bool
+	Friends  []Friend
+	Fridays  []AdminFridayData
+	Comments []AdminSearchComment
+}
+
+// HandleAdminSearch finds friends by name, email, or note, events by date, and
+// the comments friends left answering the party's questions.
+func HandleAdminSearch(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/search.html")
+	if err != nil {
+		Log.Error("template admin search failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminSearchPageData{Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+	if len(data.Query) >= SearchSchema.Query[0].MinLen {
+		results, err := Search(data.Query)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		data.Searched = true
+		data.Friends = results.Friends
+		estZone, _ := time.LoadLocation("America/New_York")
+		for _, friday := range results.Fridays {
+			data.Fridays = append(data.Fridays, AdminFridayData{ID: friday.ID, Date: friday.Start.In(estZone).Format(time.RFC1123)})
+		}
+		dates := map[string]string{}
+		for _, comment := range results.Comments {
+			date, ok := dates[comment.FridayID]
+			if !ok {
+				friday, err := GetFriday(comment.FridayID)
+				if err != nil {
+					Handle500(w, r)
+					return
+				} else if friday != nil {
+					date = FormatTime(friday.Start)
+				}
+				dates[comment.FridayID] = date
+			}
+			data.Comments = append(data.Comments, AdminSearchComment{comment, date})
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+func purgeFriendTarget(r *http.Request) (string, error) {
+	return strings.ToLower(strings.TrimSpace(r.PostForm.Get("email"))), nil
+}
+
+// HandleAdminPurgeFriend removes a friend and their history: their RSVPs,
+// which also take them off the calendar, and everything else stored about
+// them.
+func HandleAdminPurgeFriend(w http.ResponseWriter, r *http.Request) {
+	email, _ := purgeFriendTarget(r)
+	if allowed, err := IsFriendAllowed(email); err != nil {
+		Handle500(w, r)
+		return
+	} else if !allowed {
+		Handle4xx(w, r)
+		return
+	}
+	if isDryRun(r) {
+		plan, err := PlanPurgeFriend(email)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		writePlan(w, r, plan)
+		return
+	}
+	rsvps, err := ListFriendRSVPs(email)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	for _, rsvp := range rsvps {
+		if err = DeleteFridayRSVP(rsvp.ID); err != nil {
+			Handle500(w, r)
+			return
+		}
+		if err = RemoveCalendarAttendee(rsvp.FridayID, email); err != nil {
+			Log.Warn("failed to remove calendar attendee", zap.Error(err), zap.String("eventID", rsvp.FridayID))
+		}
+		rsvpsChanged(ChangeRSVPDeleted, rsvp.FridayID)
+	}
+	if err = PurgeFriend(email); err != nil {
+		Handle500(w, r)
+		return
+	}
+	Log.Info("purged friend", zap.String("email", email), zap.Int("rsvps", len(rsvps)))
+	http.Redirect(w, r, "/admin/audit", http.StatusSeeOther)
+}
+
+type AdminFridaysPageData struct {
+	AdminView
+	Fridays []AdminFridayData
+	Created string
+	Error   string
+	// Next suggests the start of the next party, for the datetime-local input
+	Next string
+	// DrinkCategories are offered when drinks coordination is turned on
+	DrinkCategories string
+}
+
+type AdminFridayData struct {
+	ID   string
+	Date string
+}
+
+// HandleAdminFridays lists upcoming Fridays and adds new ones. Times are
+// entered in New York time.
+func HandleAdminFridays(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/fridays.html")
+	if err != nil {
+		Log.Error("template admin fridays failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := AdminFridaysPageData{AdminView: newAdminView(w, r)}
+	estZone, _ := time.LoadLocation("America/New_York")
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		in := EventInput{Announcement: r.PostForm.Get("announcement")}
+		if r.PostForm.Get("drinks") == "on" {
+			if in.Drinks = ParseDrinkCategories(r.PostForm.Get("drinkCategories")); len(in.Drinks) == 0 {
+				in.Drinks = DrinkCategories
+			}
+		}
+		in.Start, err = time.ParseInLocation("2006-01-02T15:04", r.PostForm.Get("start"), estZone)
+		if end := r.PostForm.Get("end"); err == nil && len(end) > 0 {
+			in.End, err = time.ParseInLocation("2006-01-02T15:04", end, estZone)
+		}
+		if capacity := r.PostForm.Get("capacity"); err == nil && len(capacity) > 0 {
+			in.Capacity, err = strconv.Atoi(capacity)
+		}
+		var friday *Friday
+		if err != nil {
+			err = ErrEventInvalid
+		} else if isDryRun(r) {
+			var plan Plan
+			if plan, err = PlanCreateEvent(in); err == nil {
+				writePlan(w, r, plan)
+				return
+			}
+		} else {
+			friday, err = CreateEvent(in)
+		}
+		switch err {
+		case nil:
+			data.Created = friday.Start.In(estZone).Format(time.RFC1123)
+		case ErrEventInvalid:
+			data.Error = "That event isn't valid. It must start in the next year and last at most " + strconv.Itoa(MaxEventDays) + " days."
+		case ErrEventExists:
+			data.Error = err.Error()
+		default:
+			Handle500(w, r)
+			return
+		}
+	}
+
+	fridays, err := GetCachedFridays(365)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data.Next = NextFridays(time.Now(), estZone, PartyHour, PartyMinute, 1)[0].Format("2006-01-02T15:04")
+	data.DrinkCategories = strings.Join(DrinkCategories, ", ")
+	for _, friday := range fridays {
+		data.Fridays = append(data.Fridays, AdminFridayData{
+			ID:   friday.ID(),
+			Date: friday.Start.In(estZone).Format(time.RFC1123),
+		})
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package pizza_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestAdminGuestsNotes(t *testing.T) {
+	// GIVEN the host's note about a guest
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/admin/guests.html")
+	require.NoError(t, err)
+	data := pizza.AdminGuestsPageData{FridayID: "1680903000", Date: "Fri Apr 7, 5:30 PM", Headcount: 1, Guests: []pizza.AdminGuestData{
+		{ID: "1", Name: "Ted Lasso", Email: "believe@tedlasso.com", Status: pizza.RSVPStatusConfirmed, Note: `allergic to "shellfish"`},
+	}}
+
+	// WHEN
+	var page bytes.Buffer
+	err = pizza.ExecuteTemplate(&page, plate, data)
+
+	// THEN the note can be edited in place
+	require.NoError(t, err)
+	assert.Contains(t, page.String(), `name="note" value="allergic to &#34;shellfish&#34;"`)
+}
+
+func TestAdminFriendsSearch(t *testing.T) {
+	// GIVEN the sandbox's made up friends
+	pizza.StaticDir = "../../static"
+	defer pizza.SetStore(pizza.NewSandboxStore(time.Now()))()
+
+	// WHEN the host looks at every friend, then searches for one
+	all := httptest.NewRecorder()
+	pizza.HandleAdminFriends(all, httptest.NewRequest(http.MethodGet, "/admin/friends", nil))
+	found := httptest.NewRecorder()
+	pizza.HandleAdminFriends(found, httptest.NewRequest(http.MethodGet, "/admin/friends?q=BASIL", nil))
+
+	// THEN every friend is listed, and the search keeps only the match
+	assert.Equal(t, http.StatusOK, all.Code)
+	assert.Contains(t, all.Body.String(), "Ada Example &lt;ada@example.com&gt;")
+	assert.Contains(t, all.Body.String(), "Basil Example &lt;basil@example.com&gt;")
+	assert.Equal(t, http.StatusOK, found.Code)
+	assert.Contains(t, found.Body.String(), `name="q" value="BASIL"`)
+	assert.Contains(t, found.Body.String(), "Basil Example &lt;basil@example.com&gt;")
+	assert.NotContains(t, found.Body.String(), "ada@example.com")
+}
+
+func TestAdminFriendsAddInvalid(t *testing.T) {
+	// GIVEN the sandbox's made up friends
+	pizza.StaticDir = "../../static"
+	defer pizza.SetStore(pizza.NewSandboxStore(time.Now()))()
+
+	for _, tc := range []struct {
+		name, email, expected string
+	}{
+		{"Ted Lasso", "not an email", "That doesn&#39;t look like an email address."},
+		{"Ted Lasso", "Ted Lasso <believe@tedlasso.com>", "That doesn&#39;t look like an email address."},
+		{"  ", "believe@tedlasso.com", "Friends need a name."},
+		{"Ada", "ada@example.com", "ada@example.com is already a friend."},
+	} {
+		// WHEN the host adds a friend they've mistyped
+		form := url.Values{"action": {"add"}, "name": {tc.name}, "email": {tc.email}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/friends", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		pizza.HandleAdminFriends(w, req)
+
+		// THEN they're told what's wrong and nobody is added
+		assert.Equal(t, http.StatusOK, w.Code, tc.email)
+		assert.Contains(t, w.Body.String(), "<p>"+tc.expected+"</p>", tc.email)
+		assert.NotContains(t, w.Body.String(), "<p>Added", tc.email)
+	}
+}
@@ -0,0 +1,213 @@
+package pizza
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidAlert = errors.New("invalid headcount alert")
+
+// HeadcountAlert tells the host when a party's headcount passes Count, or,
+// for alerts that aren't Above, when it is still under Count on the By
+// weekday of the party's week.
+type HeadcountAlert struct {
+	Above bool   `fauna:"above"`
+	Count int    `fauna:"count"`
+	By    string `fauna:"by"`
+}
+
+// ID names the alert, and is what remembers it was sent for a party.
+func (a HeadcountAlert) ID() string {
+	if a.Above {
+		return "above-" + strconv.Itoa(a.Count)
+	}
+	return "below-" + strconv.Itoa(a.Count) + "-" + strings.ToLower(a.By)
+}
+
+func (a HeadcountAlert) String() string {
+	if a.Above {
+		return fmt.Sprintf("more than %d people", a.Count)
+	}
+	return fmt.Sprintf("fewer than %d people by %s", a.Count, a.By)
+}
+
+// ParseHeadcountAlert reads an alert from the admin form, where kind is
+// "above" or "below" and by is a weekday for below alerts.
+func ParseHeadcountAlert(kind, count, by string) (HeadcountAlert, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n < 1 {
+		return HeadcountAlert{}, ErrInvalidAlert
+	}
+	switch kind {
+	case "above":
+		return HeadcountAlert{Above: true, Count: n}, nil
+	case "below":
+		day, err := ParseWeekday(by)
+		if err != nil {
+			return HeadcountAlert{}, ErrInvalidAlert
+		}
+		return HeadcountAlert{Count: n, By: day.String()}, nil
+	}
+	return HeadcountAlert{}, ErrInvalidAlert
+}
+
+// alertTime is when a below alert is checked: the start of the last By
+// weekday on or before the party, in New York.
+func (a HeadcountAlert) alertTime(start time.Time) time.Time {
+	estZone, _ := time.LoadLocation("America/New_York")
+	local := start.In(estZone)
+	day, _ := ParseWeekday(a.By)
+	back := (int(local.Weekday()) - int(day) + 7) % 7
+	year, month, date := local.Date()
+	return time.Date(year, month, date-back, 0, 0, 0, 0, estZone)
+}
+
+// DueHeadcountAlerts are the alerts that should fire for a party with the
+// headcount at now, leaving out those already sent.
+func DueHeadcountAlerts(alerts []HeadcountAlert, friday Friday, headcount int, now time.Time, sent map[string]bool) []HeadcountAlert {
+	due := []HeadcountAlert{}
+	if !now.Before(friday.Start) {
+		return due
+	}
+	for _, alert := range alerts {
+		if sent[alert.ID()+":"+friday.ID()] {
+			continue
+		}
+		if alert.Above && headcount > alert.Count {
+			due = append(due, alert)
+		} else if !alert.Above && headcount < alert.Count && !now.Before(alert.alertTime(friday.Start)) {
+			due = append(due, alert)
+		}
+	}
+	return due
+}
+
+type AlertEmailData struct {
+	Date      string
+	Headcount int
+	Alert     string
+	GuestsURL string
+}
+
+// CheckHeadcountAlerts emails the host the party's alerts that are due. It
+// runs whenever RSVPs change and on the notifications schedule, so alerts
+// for a day fire even if nobody RSVPs.
+func CheckHeadcountAlerts(friday Friday) {
+	hostEmail := CurrentSettings().HostEmail
+	if len(hostEmail) == 0 {
+		return
+	}
+	alerts, sent, err := GetHeadcountAlerts()
+	if err != nil || len(alerts) == 0 {
+		return
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return
+	}
+	headcount := Headcount(rsvps)
+	for _, alert := range DueHeadcountAlerts(alerts, friday, headcount, time.Now(), sent) {
+		// mark it first so a failed email isn't sent again on every RSVP
+		if err = MarkHeadcountAlertSent(alert.ID() + ":" + friday.ID()); err != nil {
+			return
+		}
+		msg, err := RenderEmail("alert", AlertEmailData{
+			Date:      FormatTime(friday.Start),
+			Headcount: headcount,
+			Alert:     alert.String(),
+			GuestsURL: BaseURL + "/admin/fridays/" + friday.ID() + "/guests",
+		})
+		if err != nil {
+			Log.Error("alert template failure", zap.Error(err))
+			return
+		}
+		msg.To = hostEmail
+		if err = SendEmail(msg); err != nil {
+			Log.Warn("failed to send headcount alert", zap.Error(err), zap.String("alert", alert.ID()))
+		}
+	}
+}
+
+type AdminAlertsPageData struct {
+	AdminView
+	Alerts  []AdminAlertData
+	Enabled bool
+	Message string
+	Error   string
+}
+
+type AdminAlertData struct {
+	ID          string
+	Description string
+}
+
+// HandleAdminAlerts lists the headcount alerts, adds them, and removes them.
+func HandleAdminAlerts(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/alerts.html")
+	if err != nil {
+		Log.Error("template admin alerts failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	alerts, _, err := GetHeadcountAlerts()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminAlertsPageData{AdminView: newAdminView(w, r), Enabled: len(CurrentSettings().HostEmail) > 0}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		saved := []HeadcountAlert{}
+		switch r.PostForm.Get("action") {
+		case "add":
+			alert, err := ParseHeadcountAlert(r.PostForm.Get("kind"), r.PostForm.Get("count"), r.PostForm.Get("by"))
+			if err != nil {
+				data.Error = "Alerts need a number of people, and a day for fewer than."
+				break
+			}
+			for _, existing := range alerts {
+				if existing.ID() != alert.ID() {
+					saved = append(saved, existing)
+				}
+			}
+			saved = append(saved, alert)
+			data.Message = "You'll be told when a party has " + alert.String() + "."
+		case "remove":
+			for _, existing := range alerts {
+				if existing.ID() != r.PostForm.Get("id") {
+					saved = append(saved, existing)
+				}
+			}
+			data.Message = "Removed the alert."
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		if len(data.Error) == 0 {
+			if err = SaveHeadcountAlerts(saved); err != nil {
+				Handle500(w, r)
+				return
+			}
+			alerts = saved
+		}
+	}
+
+	for _, alert := range alerts {
+		data.Alerts = append(data.Alerts, AdminAlertData{ID: alert.ID(), Description: alert.String()})
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
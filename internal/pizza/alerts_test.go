@@ -0,0 +1,51 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeadcountAlert(t *testing.T) {
+	// WHEN
+	above, err := pizza.ParseHeadcountAlert("above", "15", "")
+	require.Nil(t, err)
+	below, err := pizza.ParseHeadcountAlert("below", "4", "wednesday")
+	require.Nil(t, err)
+
+	// THEN
+	assert.Equal(t, "more than 15 people", above.String())
+	assert.Equal(t, "fewer than 4 people by Wednesday", below.String())
+	assert.Equal(t, "below-4-wednesday", below.ID())
+	_, err = pizza.ParseHeadcountAlert("below", "4", "")
+	assert.Equal(t, pizza.ErrInvalidAlert, err)
+	_, err = pizza.ParseHeadcountAlert("above", "0", "")
+	assert.Equal(t, pizza.ErrInvalidAlert, err)
+}
+
+func TestDueHeadcountAlerts(t *testing.T) {
+	// GIVEN a party on Friday, Apr 7 at 5:30pm in New York
+	friday := pizza.Friday{Start: time.Unix(1680903000, 0)}
+	above := pizza.HeadcountAlert{Above: true, Count: 15}
+	below := pizza.HeadcountAlert{Count: 4, By: "Wednesday"}
+	alerts := []pizza.HeadcountAlert{above, below}
+	tuesday := time.Date(2023, 4, 4, 12, 0, 0, 0, time.UTC)
+	wednesday := time.Date(2023, 4, 5, 12, 0, 0, 0, time.UTC)
+	none := map[string]bool{}
+
+	// THEN
+	assert.Empty(t, pizza.DueHeadcountAlerts(alerts, friday, 3, tuesday, none))
+	assert.Equal(t, []pizza.HeadcountAlert{below}, pizza.DueHeadcountAlerts(alerts, friday, 3, wednesday, none))
+	assert.Empty(t, pizza.DueHeadcountAlerts(alerts, friday, 15, wednesday, none))
+	assert.Equal(t, []pizza.HeadcountAlert{above}, pizza.DueHeadcountAlerts(alerts, friday, 16, tuesday, none))
+
+	// THEN alerts are only sent once per party
+	sent := map[string]bool{"above-15:1680903000": true}
+	assert.Empty(t, pizza.DueHeadcountAlerts(alerts, friday, 16, tuesday, sent))
+
+	// THEN nothing is sent once the party started
+	assert.Empty(t, pizza.DueHeadcountAlerts(alerts, friday, 1, friday.Start, none))
+}
@@ -0,0 +1,174 @@
+package pizza
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AliasLinkTTL is how long the link to confirm an email alias works.
+var AliasLinkTTL = 24 * time.Hour
+
+var primaryEmailCache *Cache[string]
+
+func init() {
+	c := NewCache(10*time.Minute, GetPrimaryEmail)
+	primaryEmailCache = &c
+}
+
+// GetCachedPrimaryEmail is the email of the friend's profile, so RSVPs from
+// any of their addresses land on the same friend.
+func GetCachedPrimaryEmail(email string) (string, error) {
+	primary, err := primaryEmailCache.Get(email)
+	if err != nil || len(primary) == 0 {
+		// strangers aren't cached, they may be added at any time
+		primaryEmailCache.Delete(email)
+	}
+	return primary, err
+}
+
+// AliasURL is the link sent to a new address to confirm it belongs to the
+// friend.
+func AliasURL(email, alias string, expires time.Time) string {
+	exp := LinkExpiry(expires)
+	q := url.Values{}
+	q.Set("email", email)
+	q.Set("alias", alias)
+	q.Set("expires", exp)
+	q.Set("sig", SignLink("alias", email, alias, exp))
+	return fmt.Sprintf("/aliases/verify?%s", q.Encode())
+}
+
+type AliasEmailData struct {
+	Name      string
+	Alias     string
+	VerifyURL string
+}
+
+type AliasesPageData struct {
+	View
+	Email   string
+	Aliases []string
+	Sent    string
+	Error   string
+}
+
+// HandleAliases lets the friend remembered by the browser add and remove
+// other email addresses they use. New addresses are confirmed by email.
+func HandleAliases(w http.ResponseWriter, r *http.Request) {
+	data := AliasesPageData{Email: friendFromCookie(r)}
+	if len(data.Email) == 0 {
+		render(w, r, "html/aliases.html", &data)
+		return
+	}
+
+	var err error
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil || !checkCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		alias := strings.ToLower(strings.TrimSpace(r.PostForm.Get("alias")))
+		if r.PostForm.Get("action") == "remove" {
+			err = RemoveFriendAlias(data.Email, alias)
+			primaryEmailCache.Delete(alias)
+		} else if primary, err2 := GetPrimaryEmail(alias); err2 != nil {
+			err = err2
+		} else if len(primary) > 0 || !strings.Contains(alias, "@") {
+			data.Error = "That email can't be added."
+		} else {
+			err = sendAliasLink(data.Email, alias)
+			data.Sent = alias
+		}
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+	}
+
+	if data.Aliases, err = GetFriendAliases(data.Email); err != nil {
+		Handle500(w, r)
+		return
+	}
+	render(w, r, "html/aliases.html", &data)
+}
+
+func sendAliasLink(email, alias string) error {
+	name, _ := GetCachedFriendName(email)
+	msg, err := RenderEmail("alias", AliasEmailData{
+		Name:      name,
+		Alias:     alias,
+		VerifyURL: BaseURL + AliasURL(email, alias, time.Now().Add(AliasLinkTTL)),
+	})
+	if err != nil {
+		Log.Error("alias template failure", zap.Error(err))
+		return err
+	}
+	msg.To = alias
+	if err = SendEmail(msg); err != nil {
+		Log.Warn("failed to send alias link", zap.Error(err), zap.String("email", alias))
+	}
+	return err
+}
+
+type VerifyAliasPageData struct {
+	Email    string
+	Alias    string
+	Expires  string
+	Sig      string
+	Expired  bool
+	Verified bool
+	// CSRFToken goes in the form's csrf field, checked by checkBrowserCSRF
+	CSRFToken string
+}
+
+// HandleVerifyAlias adds the alias from a confirmation link. The alias is only
+// added on POST so link previews can't confirm it.
+func HandleVerifyAlias(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/verify_alias.html")
+	if err != nil {
+		Log.Error("template verify alias failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := VerifyAliasPageData{
+		Email:   r.Form.Get("email"),
+		Alias:   r.Form.Get("alias"),
+		Expires: r.Form.Get("expires"),
+		Sig:     r.Form.Get("sig"),
+	}
+	if !VerifyLink(data.Sig, "alias", data.Email, data.Alias, data.Expires) {
+		Handle4xx(w, r)
+		return
+	}
+	data.Expired = LinkExpired(data.Expires, time.Now())
+
+	if r.Method == http.MethodPost && !data.Expired {
+		if !checkBrowserCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		if err = AddFriendAlias(data.Email, data.Alias); err != nil {
+			Handle500(w, r)
+			return
+		}
+		negativeFriendCache.Delete(data.Alias)
+		primaryEmailCache.Delete(data.Alias)
+		data.Verified = true
+	}
+
+	data.CSRFToken = browserCSRFToken(w, r)
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,203 @@
+package pizza
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Venue is where the parties usually are, for {{venue}} in announcements.
+var Venue string
+
+// roomBot posts announcement templates to the Matrix room, if there is one.
+var roomBot *MatrixBot
+
+// AnnouncementVariable is a {{name}} in an announcement that is filled in
+// whenever it is shown, so the headcount is always current.
+type AnnouncementVariable struct {
+	Name        string
+	Description string
+}
+
+var AnnouncementVariables = []AnnouncementVariable{
+	{"event_date", "when the party starts"},
+	{"deadline", "when RSVPs close"},
+	{"headcount", "how many are coming"},
+	{"spots_left", "how many spots are left, blank without a capacity"},
+	{"venue", "the venue from the config"},
+	{"rsvp_url", "the link to RSVP"},
+}
+
+var announcementVariable = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// FillAnnouncement replaces the variables in the text with their values.
+// Unknown variables are left as written so typos stand out.
+func FillAnnouncement(text string, vars map[string]string) string {
+	return announcementVariable.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := vars[announcementVariable.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// AnnouncementVars are the values of the variables for the event.
+func AnnouncementVars(friday Friday, headcount int) map[string]string {
+	vars := map[string]string{
+		"event_date": FormatTime(friday.Start),
+		"deadline":   FormatTime(friday.Deadline()),
+		"headcount":  strconv.Itoa(headcount),
+		"spots_left": "",
+		"venue":      Venue,
+		"rsvp_url":   BaseURL + "/",
+	}
+	if limit := friday.Limit(); limit > headcount {
+		vars["spots_left"] = strconv.Itoa(limit - headcount)
+	} else if limit > 0 {
+		vars["spots_left"] = "0"
+	}
+	return vars
+}
+
+// FridayAnnouncement is the event's announcement with its variables filled
+// in. The headcount is only looked up when the announcement uses it.
+func FridayAnnouncement(friday Friday) string {
+	if !strings.Contains(friday.Announcement, "{{") {
+		return friday.Announcement
+	}
+	if strings.Contains(friday.Announcement, "headcount") || strings.Contains(friday.Announcement, "spots_left") {
+		return FillAnnouncement(friday.Announcement, announcementVarsFor(friday))
+	}
+	return FillAnnouncement(friday.Announcement, AnnouncementVars(friday, 0))
+}
+
+type AdminTemplateData struct {
+	Name string
+	Body string
+	// Preview is the template filled in for the next event
+	Preview string
+}
+
+type AdminTemplateFriday struct {
+	ID   string
+	Date string
+}
+
+type AdminTemplatesPageData struct {
+	AdminView
+	Templates []AdminTemplateData
+	Variables []AnnouncementVariable
+	Fridays   []AdminTemplateFriday
+	// Matrix is set when templates can be posted to the Matrix room
+	Matrix  bool
+	Message string
+	Error   string
+}
+
+// HandleAdminTemplates manages the announcement templates, and uses one as an
+// event's announcement or posts it to the Matrix room.
+func HandleAdminTemplates(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/templates.html")
+	if err != nil {
+		Log.Error("template admin templates failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	templates, err := GetAnnouncementTemplates()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminTemplatesPageData{AdminView: newAdminView(w, r), Variables: AnnouncementVariables, Matrix: roomBot != nil}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		name := strings.TrimSpace(r.PostForm.Get("name"))
+		body := ""
+		for _, tmpl := range templates {
+			if tmpl.Name == name {
+				body = tmpl.Body
+			}
+		}
+		var friday *Friday
+		for i := range fridays {
+			if fridays[i].ID() == r.PostForm.Get("friday") {
+				friday = &fridays[i]
+			}
+		}
+		switch action := r.PostForm.Get("action"); {
+		case action == "save" && len(name) > 0:
+			body = strings.TrimSpace(r.PostForm.Get("body"))
+			saved := []AnnouncementTemplate{}
+			for _, tmpl := range templates {
+				if tmpl.Name != name {
+					saved = append(saved, tmpl)
+				}
+			}
+			if len(body) > 0 {
+				saved = append(saved, AnnouncementTemplate{Name: name, Body: body})
+			}
+			if err = SaveAnnouncementTemplates(saved); err != nil {
+				Handle500(w, r)
+				return
+			}
+			templates = saved
+			data.Message = "Saved " + name + "."
+		case action == "announce" && len(body) > 0 && friday != nil:
+			if err = SetFridayAnnouncement(friday.ID(), body); err != nil {
+				Handle500(w, r)
+				return
+			}
+			fridayCache.Clear()
+			apiFridaysCache.Clear()
+			data.Message = "Set the announcement for " + FormatTime(friday.Start) + "."
+		case action == "post" && len(body) > 0 && friday != nil && roomBot != nil:
+			text := MarkdownText(FillAnnouncement(body, announcementVarsFor(*friday)))
+			if err = roomBot.Notify(text); err != nil {
+				Log.Warn("matrix announcement failed", zap.Error(err))
+				data.Error = "Could not post to the room, try again."
+			} else {
+				data.Message = "Posted to the room."
+			}
+		default:
+			data.Error = "Pick a template and a Friday."
+		}
+	}
+
+	for _, friday := range fridays {
+		data.Fridays = append(data.Fridays, AdminTemplateFriday{ID: friday.ID(), Date: FormatTime(friday.Start)})
+	}
+	for _, tmpl := range templates {
+		tmplData := AdminTemplateData{Name: tmpl.Name, Body: tmpl.Body}
+		if len(fridays) > 0 {
+			tmplData.Preview = FillAnnouncement(tmpl.Body, announcementVarsFor(fridays[0]))
+		}
+		data.Templates = append(data.Templates, tmplData)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+// announcementVarsFor looks up the event's headcount for its variables.
+func announcementVarsFor(friday Friday) map[string]string {
+	status, err := GetFridayStatus(friday)
+	if err != nil {
+		Log.Warn("failed to get headcount for announcement", zap.Error(err), zap.String("eventID", friday.ID()))
+	}
+	return AnnouncementVars(friday, status.Headcount)
+}
@@ -0,0 +1,44 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestFillAnnouncement(t *testing.T) {
+	// GIVEN
+	vars := map[string]string{"headcount": "7", "venue": "the Crown & Anchor"}
+
+	// WHEN
+	text := pizza.FillAnnouncement("{{headcount}} going to {{ venue }}, bring {{drinks}}", vars)
+
+	// THEN unknown variables are left alone
+	assert.Equal(t, "7 going to the Crown & Anchor, bring {{drinks}}", text)
+}
+
+func TestAnnouncementVars(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC), Capacity: 10}
+
+	// WHEN
+	vars := pizza.AnnouncementVars(friday, 7)
+	full := pizza.AnnouncementVars(friday, 12)
+
+	// THEN
+	assert.Equal(t, pizza.FormatTime(friday.Start), vars["event_date"])
+	assert.Equal(t, "7", vars["headcount"])
+	assert.Equal(t, "3", vars["spots_left"])
+	assert.Equal(t, "0", full["spots_left"])
+}
+
+func TestFridayAnnouncementWithoutVariables(t *testing.T) {
+	// GIVEN an announcement that needs no lookups
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC), Announcement: "Bring a **friend**"}
+
+	// THEN
+	assert.Equal(t, "Bring a **friend**", pizza.FridayAnnouncement(friday))
+}
@@ -0,0 +1,364 @@
+package pizza
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Log.Error("json encode failure", zap.Error(err))
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, APIError{msg})
+}
+
+// writeConditionalJSON serves the value with an ETag and Last-Modified so
+// polling clients get a 304 when nothing has changed.
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, modified time.Time, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		Log.Error("json encode failure", zap.Error(err))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	if len(w.Header().Get("Cache-Control")) == 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+type APIFriday struct {
+	ID       string     `json:"id"`
+	Start    time.Time  `json:"start"`
+	End      time.Time  `json:"end"`
+	Opens    *time.Time `json:"opens,omitempty"`
+	Deadline time.Time  `json:"deadline"`
+	Closed   bool       `json:"closed"`
+	Guests   int        `json:"guests"`
+	// Kids are how many of the guests are children
+	Kids int `json:"kids"`
+}
+
+// APIFridaysMaxAge is how long clients may cache the list of Fridays, and how
+// long the server keeps it before listing them again.
+var APIFridaysMaxAge = 30 * time.Second
+
+// apiFridays is the list of Fridays served by the API and when it last changed.
+type apiFridays struct {
+	fridays  []APIFriday
+	modified time.Time
+}
+
+var apiFridaysCache *Cache[apiFridays]
+
+func init() {
+	c := NewCache(APIFridaysMaxAge, func(string) (apiFridays, error) { return listAPIFridays() })
+	apiFridaysCache = &c
+}
+
+// HandleAPIListFridays serves the upcoming Fridays from a cache that, with
+// cacheStale set, answers right away with a slightly old list while fetching a
+// new one, and tells clients they may do the same.
+func HandleAPIListFridays(w http.ResponseWriter, r *http.Request) {
+	res, err := apiFridaysCache.Get("")
+	if err != nil {
+		Log.Error("failed to list fridays", zap.Error(err))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d",
+		int(APIFridaysMaxAge.Seconds()), int(CacheStale.Seconds())))
+	writeConditionalJSON(w, r, res.modified, res.fridays)
+}
+
+func listAPIFridays() (apiFridays, error) {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		return apiFridays{}, err
+	}
+
+	var modified time.Time
+	res := make([]APIFriday, len(fridays))
+	for i, friday := range fridays {
+		rsvps, err := ListFridayRSVPs(friday.ID())
+		if err != nil {
+			return apiFridays{}, err
+		}
+		res[i] = APIFriday{
+			ID:       friday.ID(),
+			Start:    friday.Start,
+			End:      friday.EndTime(),
+			Deadline: friday.Deadline(),
+			Closed:   friday.IsClosed(),
+		}
+		if opens := friday.Opens(); !opens.IsZero() {
+			res[i].Opens = &opens
+		}
+		if friday.Modified().After(modified) {
+			modified = friday.Modified()
+		}
+		res[i].Guests = Headcount(rsvps)
+		res[i].Kids = CountGuests(rsvps).Kids
+		for _, rsvp := range rsvps {
+			if rsvp.UpdatedAt.After(modified) {
+				modified = rsvp.UpdatedAt
+			}
+		}
+	}
+	return apiFridays{res, modified}, nil
+}
+
+// APIKeys are bearer tokens allowed every API scope.
+var APIKeys []string
+
+// HomeAssistantSensor is shaped for Home Assistant's RESTful sensor, with the
+// headcount as the state and the rest as attributes.
+type HomeAssistantSensor struct {
+	State      int                     `json:"state"`
+	Attributes HomeAssistantAttributes `json:"attributes"`
+}
+
+type HomeAssistantAttributes struct {
+	FridayID      string     `json:"friday_id,omitempty"`
+	NextEvent     *time.Time `json:"next_event"`
+	Deadline      *time.Time `json:"deadline"`
+	RSVPsOpen     bool       `json:"rsvps_open"`
+	FriendlyName  string     `json:"friendly_name"`
+	UnitOfMeasure string     `json:"unit_of_measurement"`
+	Icon          string     `json:"icon"`
+}
+
+func HandleAPIHomeAssistant(w http.ResponseWriter, r *http.Request) {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	sensor := HomeAssistantSensor{Attributes: HomeAssistantAttributes{
+		FriendlyName:  "Pizza headcount",
+		UnitOfMeasure: "people",
+		Icon:          "mdi:pizza",
+	}}
+	if len(fridays) > 0 {
+		status, err := GetFridayStatus(fridays[0])
+		if err != nil {
+			Log.Error("failed to get friday status", zap.Error(err))
+			writeAPIError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		sensor.State = status.Headcount
+		sensor.Attributes.FridayID = status.ID
+		sensor.Attributes.NextEvent = &status.Start
+		sensor.Attributes.Deadline = &status.Deadline
+		sensor.Attributes.RSVPsOpen = status.Open
+	}
+	writeJSON(w, http.StatusOK, sensor)
+}
+
+type APIChanges struct {
+	Changes []Change `json:"changes"`
+	Cursor  string   `json:"cursor"`
+}
+
+var ChangesSchema = Schema{Query: []Param{
+	{Name: "since"},
+	{Name: "limit", Int: true, Min: 1, Max: atMost(1000)},
+}}
+
+// HandleAPIListChanges returns the RSVP changes after the since cursor so
+// integrations can mirror state incrementally.
+func HandleAPIListChanges(w http.ResponseWriter, r *http.Request) {
+	changes, cursor, err := ListChanges(RequestParam(r, "since"), RequestInt(r, "limit", 100))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if changes == nil {
+		changes = []Change{}
+	}
+	writeJSON(w, http.StatusOK, APIChanges{changes, cursor})
+}
+
+type APISearchFriday struct {
+	ID    string    `json:"id"`
+	Start time.Time `json:"start"`
+}
+
+// APISearchComment is a friend's answer to one of the party's questions.
+type APISearchComment struct {
+	RSVPID   string `json:"rsvpId"`
+	FridayID string `json:"fridayId"`
+	Email    string `json:"email"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+type APISearchResults struct {
+	Friends  []Friend           `json:"friends"`
+	Fridays  []APISearchFriday  `json:"fridays"`
+	Comments []APISearchComment `json:"comments"`
+}
+
+// FridayMatches reports whether the query names the event, by its ID, a date
+// like 2023-04-07, its month, or the time shown on the site.
+func FridayMatches(friday Friday, query string) bool {
+	estZone, _ := time.LoadLocation("America/New_York")
+	local := friday.Start.In(estZone)
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, s := range []string{
+		friday.ID(),
+		local.Format("2006-01-02"),
+		local.Format("January 2 2006"),
+		FormatTime(friday.Start),
+	} {
+		if strings.Contains(strings.ToLower(s), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommentMatches returns the friend's answers on the RSVP that contain the
+// query, ignoring case, ordered by question.
+func CommentMatches(rsvp RSVP, query string) []APISearchComment {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var comments []APISearchComment
+	for question, answer := range rsvp.Answers {
+		if strings.Contains(strings.ToLower(answer), query) {
+			comments = append(comments, APISearchComment{rsvp.ID, rsvp.FridayID, rsvp.Email, question, answer})
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].Question < comments[j].Question })
+	return comments
+}
+
+// Search finds friends by name, email, or the host's note about them, events
+// by date, and the comments friends left answering the party's questions.
+func Search(query string) (APISearchResults, error) {
+	results := APISearchResults{Friends: []Friend{}, Fridays: []APISearchFriday{}, Comments: []APISearchComment{}}
+	friends, err := SearchFriends(query)
+	if err != nil {
+		return results, err
+	}
+	results.Friends = append(results.Friends, friends...)
+	fridays, err := GetAllFridays()
+	if err != nil {
+		return results, err
+	}
+	for _, friday := range fridays {
+		if FridayMatches(friday, query) {
+			results.Fridays = append(results.Fridays, APISearchFriday{friday.ID(), friday.Start})
+		}
+	}
+	rsvps, err := SearchComments(query)
+	if err != nil {
+		return results, err
+	}
+	for _, rsvp := range rsvps {
+		results.Comments = append(results.Comments, CommentMatches(rsvp, query)...)
+	}
+	return results, nil
+}
+
+var SearchSchema = Schema{Query: []Param{{Name: "q", Required: true, MinLen: 2}}}
+
+// HandleAPISearch finds friends by name, email, or note, events by date, and
+// comments by their text.
+func HandleAPISearch(w http.ResponseWriter, r *http.Request) {
+	results, err := Search(RequestParam(r, "q"))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// HandleAPIListRSVPs lists every RSVP to a Friday, including those waiting
+// for approval.
+func HandleAPIListRSVPs(w http.ResponseWriter, r *http.Request) {
+	rsvps, err := ListFridayRSVPs(mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if rsvps == nil {
+		rsvps = []RSVP{}
+	}
+	writeJSON(w, http.StatusOK, rsvps)
+}
+
+// HandleAPIReviewRSVP approves or declines an RSVP over the Friday's limit.
+func HandleAPIReviewRSVP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	rsvp, err := ReviewRSVP(id, mux.Vars(r)["action"] == "approve")
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, rsvp)
+	case ErrRSVPNotFound:
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case ErrRSVPReviewed, ErrRSVPConflict:
+		writeAPIError(w, http.StatusConflict, err.Error())
+	default:
+		Log.Error("failed to review rsvp", zap.Error(err), zap.String("id", id))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// PatchRSVPSchema is the RSVPEdit fields that are checked the same for every
+// RSVP. The rest depend on the Friday, so are checked by EditRSVP.
+var PatchRSVPSchema = Schema{Body: []Param{
+	{Name: "email", Required: true, Email: true},
+	{Name: "sig", Required: true},
+	{Name: "plusOnes", Int: true},
+	{Name: "kids", Int: true},
+}}
+
+func HandleAPIPatchRSVP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var edit RSVPEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); bodyTooLarge(err) {
+		writeTooLarge(w, r)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "malformed request body")
+		return
+	}
+
+	rsvp, err := EditRSVP(id, edit)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, rsvp)
+	case ErrRSVPNotFound, ErrRSVPNotOwner:
+		writeAPIError(w, http.StatusNotFound, ErrRSVPNotFound.Error())
+	case ErrRSVPClosed, ErrRSVPConflict, ErrRSVPFull:
+		writeAPIError(w, http.StatusConflict, err.Error())
+	case ErrRSVPInvalid:
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+	default:
+		Log.Error("failed to edit rsvp", zap.Error(err), zap.String("id", id))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,130 @@
+package pizza_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFridayMatches(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)}
+	event := pizza.Friday{UID: "01h0000000abcdefghijklmnop", Start: friday.Start}
+
+	// THEN
+	assert.True(t, pizza.FridayMatches(friday, "2023-04-07"))
+	assert.True(t, pizza.FridayMatches(friday, "april 7"))
+	assert.True(t, pizza.FridayMatches(friday, "1680903000"))
+	assert.True(t, pizza.FridayMatches(friday, "07 Apr 23"))
+	assert.False(t, pizza.FridayMatches(friday, "2023-04-08"))
+	assert.False(t, pizza.FridayMatches(friday, "may"))
+	assert.True(t, pizza.FridayMatches(event, "01h0000000abcdefghijklmnop"))
+	assert.False(t, pizza.FridayMatches(event, "1680903000"))
+}
+
+func TestCommentMatches(t *testing.T) {
+	// GIVEN
+	rsvp := pizza.RSVP{ID: "abc123", FridayID: "1680903000", Email: "believe@tedlasso.com", Answers: map[string]string{
+		"Anything we should know?": "Bringing BISCUITS for the boss",
+		"Favorite pizza?":          "Anything with biscuits on it",
+		"Coming early?":            "No",
+	}}
+
+	// WHEN
+	comments := pizza.CommentMatches(rsvp, "biscuits")
+
+	// THEN
+	assert.Equal(t, []pizza.APISearchComment{
+		{RSVPID: "abc123", FridayID: "1680903000", Email: "believe@tedlasso.com", Question: "Anything we should know?", Answer: "Bringing BISCUITS for the boss"},
+		{RSVPID: "abc123", FridayID: "1680903000", Email: "believe@tedlasso.com", Question: "Favorite pizza?", Answer: "Anything with biscuits on it"},
+	}, comments)
+	assert.Empty(t, pizza.CommentMatches(rsvp, "dessert"))
+}
+
+func TestAPIListFridaysConditionalGet(t *testing.T) {
+	// GIVEN the made up events of the sandbox, listed once
+	defer pizza.SetStore(pizza.NewSandboxStore(time.Now()))()
+	first := httptest.NewRecorder()
+	pizza.HandleAPIListFridays(first, httptest.NewRequest(http.MethodGet, "/api/v1/fridays", nil))
+	require.Equal(t, http.StatusOK, first.Code)
+	var fridays []pizza.APIFriday
+	require.Nil(t, json.Unmarshal(first.Body.Bytes(), &fridays))
+	require.NotEmpty(t, fridays)
+	etag := first.Header().Get("ETag")
+	lastModified := first.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+
+	// WHEN the client polls again with what it has
+	byETag := httptest.NewRequest(http.MethodGet, "/api/v1/fridays", nil)
+	byETag.Header.Set("If-None-Match", etag)
+	notModified := httptest.NewRecorder()
+	pizza.HandleAPIListFridays(notModified, byETag)
+	byDate := httptest.NewRequest(http.MethodGet, "/api/v1/fridays", nil)
+	byDate.Header.Set("If-Modified-Since", lastModified)
+	notModifiedSince := httptest.NewRecorder()
+	pizza.HandleAPIListFridays(notModifiedSince, byDate)
+	stale := httptest.NewRequest(http.MethodGet, "/api/v1/fridays", nil)
+	stale.Header.Set("If-None-Match", `"stale"`)
+	changed := httptest.NewRecorder()
+	pizza.HandleAPIListFridays(changed, stale)
+
+	// THEN nothing is sent unless the list changed
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.Bytes())
+	assert.Equal(t, http.StatusNotModified, notModifiedSince.Code)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.Equal(t, first.Body.String(), changed.Body.String())
+	assert.Equal(t, etag, changed.Header().Get("ETag"))
+}
+
+func TestAPIListChanges(t *testing.T) {
+	// GIVEN the made up RSVPs of the sandbox
+	sandbox := pizza.NewSandboxStore(time.Now())
+	defer pizza.SetStore(sandbox)()
+	all, _, _ := sandbox.ListChanges("", 1000)
+	handler := pizza.Validate(pizza.ChangesSchema, pizza.HandleAPIListChanges)
+
+	// WHEN a client follows the feed a few changes at a time
+	var changes []pizza.Change
+	cursor := ""
+	for page := 0; page <= len(all); page++ {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/changes?"+url.Values{"since": {cursor}, "limit": {"4"}}.Encode(), nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var res pizza.APIChanges
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.LessOrEqual(t, len(res.Changes), 4)
+		if len(res.Changes) == 0 {
+			// THEN the cursor stays put once the client is caught up
+			assert.Equal(t, cursor, res.Cursor)
+			break
+		}
+		changes = append(changes, res.Changes...)
+		cursor = res.Cursor
+	}
+
+	// THEN every change is seen once, in order
+	require.Len(t, changes, len(all))
+	for i, change := range changes {
+		assert.Equal(t, all[i].Cursor, change.Cursor)
+		assert.Equal(t, all[i].Type, change.Type)
+		if i > 0 {
+			assert.False(t, change.At.Before(changes[i-1].At))
+		}
+	}
+
+	// WHEN the client asks for too many at once
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/changes?limit=5000", nil))
+
+	// THEN
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,152 @@
+package pizza
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidArrival = errors.New("invalid arrival time")
+
+// ArrivalOpen reports whether guests can say when they'll get to the party:
+// from the start of its day in New York until it ends.
+func (f Friday) ArrivalOpen(now time.Time) bool {
+	estZone, _ := time.LoadLocation("America/New_York")
+	year, month, day := f.Start.In(estZone).Date()
+	return !now.Before(time.Date(year, month, day, 0, 0, 0, 0, estZone)) && now.Before(f.EndTime())
+}
+
+// ParseArrival reads a time of day like 19:15 in New York as when a guest
+// will get to the party. Times more than 12 hours before it starts are the
+// next day, so late night parties can be arrived at after midnight.
+func ParseArrival(friday Friday, clock string) (time.Time, error) {
+	estZone, _ := time.LoadLocation("America/New_York")
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, ErrInvalidArrival
+	}
+	year, month, day := friday.Start.In(estZone).Date()
+	arrival := time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, estZone)
+	if arrival.Before(friday.Start.Add(-12 * time.Hour)) {
+		arrival = arrival.AddDate(0, 0, 1)
+	}
+	if !arrival.Before(friday.EndTime()) {
+		return time.Time{}, ErrInvalidArrival
+	}
+	return arrival, nil
+}
+
+// FormatArrival is the time of day a guest expects to arrive, or empty when
+// they haven't said.
+func FormatArrival(arrival time.Time) string {
+	if arrival.IsZero() {
+		return ""
+	}
+	estZone, _ := time.LoadLocation("America/New_York")
+	return arrival.In(estZone).Format(time.Kitchen)
+}
+
+type ArrivalPageData struct {
+	ID   string
+	Sig  string
+	Date string
+	// Confirm is set when the friend has to enter their email, as arrival
+	// links are forwarded like edit links
+	Confirm bool
+	Hint    string
+	// Arrival is the time they said, for the time input
+	Arrival string
+	Late    bool
+	Saved   bool
+	Error   string
+	Closed  bool
+}
+
+// HandleArrival is a quick page for guests on the day of a party to tell the
+// host when they'll get there, or that they're running late. The host and
+// co-hosts see it next to the guest on their pages.
+func HandleArrival(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/arrival.html")
+	if err != nil {
+		Log.Error("template arrival failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := ArrivalPageData{ID: r.Form.Get("rsvp"), Sig: r.Form.Get("sig")}
+
+	rsvp, err := GetFridayRSVP(data.ID)
+	if err != nil {
+		Log.Error("failed to get rsvp", zap.Error(err), zap.String("id", data.ID))
+		Handle500(w, r)
+		return
+	} else if rsvp == nil || !VerifyLink(data.Sig, "rsvp", rsvp.ID, rsvp.Email) {
+		Handle4xx(w, r)
+		return
+	}
+	friday, err := GetFriday(rsvp.FridayID)
+	if err != nil {
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	data.Date = FormatTime(friday.Start)
+	data.Closed = !rsvp.Confirmed() || !friday.ArrivalOpen(time.Now())
+	email := rsvp.Email
+	if friendFromCookie(r) != rsvp.Email {
+		data.Confirm = true
+		data.Hint = MaskEmail(rsvp.Email)
+		email = strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+	}
+
+	if r.Method == http.MethodPost && !data.Closed {
+		if email != rsvp.Email {
+			Log.Debug("arrival link used by someone else", zap.String("id", rsvp.ID))
+			Handle4xx(w, r)
+			return
+		}
+		arrival, late := rsvp.Arrival, rsvp.Late
+		switch r.PostForm.Get("action") {
+		case "arrival":
+			if arrival, err = ParseArrival(*friday, r.PostForm.Get("arrival")); err != nil {
+				data.Error = "Pick a time before the party ends."
+			}
+			late = false
+		case "late":
+			late = true
+		case "ontime":
+			late = false
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		if len(data.Error) == 0 {
+			if err = SetRSVPArrival(rsvp.ID, arrival, late); err != nil {
+				Handle500(w, r)
+				return
+			}
+			rsvp.Arrival, rsvp.Late = arrival, late
+			data.Confirm = false
+			data.Saved = true
+		}
+	}
+
+	if !rsvp.Arrival.IsZero() {
+		estZone, _ := time.LoadLocation("America/New_York")
+		data.Arrival = rsvp.Arrival.In(estZone).Format("15:04")
+	}
+	data.Late = rsvp.Late
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
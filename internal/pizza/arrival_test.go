@@ -0,0 +1,47 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArrival(t *testing.T) {
+	// GIVEN a party on Friday, Apr 7 from 5:30pm to 9:30pm in New York
+	friday := pizza.Friday{Start: time.Unix(1680903000, 0)}
+
+	// WHEN
+	arrival, err := pizza.ParseArrival(friday, "19:15")
+
+	// THEN
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 4, 7, 23, 15, 0, 0, time.UTC), arrival.UTC())
+	assert.Equal(t, "7:15PM", pizza.FormatArrival(arrival))
+	assert.Equal(t, "", pizza.FormatArrival(time.Time{}))
+
+	// THEN guests can't arrive after it ends
+	_, err = pizza.ParseArrival(friday, "22:00")
+	assert.Equal(t, pizza.ErrInvalidArrival, err)
+	_, err = pizza.ParseArrival(friday, "7pm")
+	assert.Equal(t, pizza.ErrInvalidArrival, err)
+
+	// THEN a late night party can be arrived at after midnight
+	late := pizza.Friday{Start: time.Date(2023, 4, 8, 3, 0, 0, 0, time.UTC)}
+	arrival, err = pizza.ParseArrival(late, "00:30")
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 4, 8, 4, 30, 0, 0, time.UTC), arrival.UTC())
+}
+
+func TestArrivalOpen(t *testing.T) {
+	// GIVEN a party on Friday, Apr 7 from 5:30pm to 9:30pm in New York
+	friday := pizza.Friday{Start: time.Unix(1680903000, 0)}
+
+	// THEN guests can say when they'll arrive on the day until it ends
+	assert.False(t, friday.ArrivalOpen(time.Date(2023, 4, 7, 3, 0, 0, 0, time.UTC)))
+	assert.True(t, friday.ArrivalOpen(time.Date(2023, 4, 7, 5, 0, 0, 0, time.UTC)))
+	assert.True(t, friday.ArrivalOpen(time.Date(2023, 4, 8, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, friday.ArrivalOpen(time.Date(2023, 4, 8, 1, 30, 0, 0, time.UTC)))
+}
@@ -0,0 +1,166 @@
+package pizza
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetManifest is the file in StaticDir that lists the bundled CSS and JS.
+// The server hashes the assets itself when it is missing.
+const AssetManifest = "assets.json"
+
+// assetDirs are the directories in StaticDir with bundled assets.
+var assetDirs = []string{"css", "js"}
+
+// Asset is a bundled file with its subresource integrity hash.
+type Asset struct {
+	Version   string `json:"version"`
+	Integrity string `json:"integrity"`
+}
+
+var assets = map[string]Asset{}
+
+// BuildAssets hashes the assets in the static directory, or the built in
+// one when dir is empty.
+func BuildAssets(dir string) (map[string]Asset, error) {
+	fsys := staticFS(dir)
+	manifest := map[string]Asset{}
+	for _, assetDir := range assetDirs {
+		err := fs.WalkDir(fsys, assetDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := path.Ext(p)
+			if d.IsDir() || ext == ".br" || ext == ".gz" || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			sum := sha512.Sum384(data)
+			manifest[p] = Asset{
+				Version:   hex.EncodeToString(sum[:4]),
+				Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// WriteAssetManifest builds the asset manifest for the static directory. The
+// built in static directory has no manifest, so its assets are hashed when
+// the server starts.
+func WriteAssetManifest(dir string) error {
+	if len(dir) == 0 {
+		return errors.New("set PIZZA_STATIC_DIR to the static directory to write its manifest")
+	}
+	manifest, err := BuildAssets(dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, AssetManifest), append(data, '\n'), 0o644)
+}
+
+// LoadAssets reads the asset manifest, or hashes the assets when there isn't
+// one.
+func LoadAssets(dir string) error {
+	data, err := fs.ReadFile(staticFS(dir), AssetManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		assets, err = BuildAssets(dir)
+		return err
+	} else if err != nil {
+		return err
+	}
+	manifest := map[string]Asset{}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	assets = manifest
+	return nil
+}
+
+// AssetURL is the URL of the asset, versioned so browsers fetch it again when
+// it changes.
+func AssetURL(name string) string {
+	if asset, ok := assets[name]; ok {
+		return "/static/" + name + "?v=" + asset.Version
+	}
+	return "/static/" + name
+}
+
+// AssetIntegrity is the asset's subresource integrity hash.
+func AssetIntegrity(name string) string {
+	return assets[name].Integrity
+}
+
+func assetAttrs(name, attr string) string {
+	s := fmt.Sprintf(`%s="%s"`, attr, AssetURL(name))
+	if integrity := AssetIntegrity(name); len(integrity) > 0 {
+		s += fmt.Sprintf(` integrity="%s"`, integrity)
+	}
+	return s
+}
+
+var templateFuncs = template.FuncMap{
+	"asset":     AssetURL,
+	"integrity": AssetIntegrity,
+	"banner": func() template.HTML {
+		return template.HTML(RenderBanner(time.Now()))
+	},
+	"flashes": func(flashes []string) template.HTML {
+		return template.HTML(renderFlashes(flashes))
+	},
+	"stylesheet": func(name string) template.HTML {
+		return template.HTML(fmt.Sprintf(`<link rel="stylesheet" %s>`, assetAttrs(name, "href")))
+	},
+	"script": func(name string) template.HTML {
+		return template.HTML(fmt.Sprintf(`<script %s></script>`, assetAttrs(name, "src")))
+	},
+}
+
+// maxPooledBuffer is the largest render buffer kept for reuse, so one huge
+// page doesn't hold on to its memory.
+const maxPooledBuffer = 64 << 10
+
+var renderBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// executeTemplate renders the page into a pooled buffer and then writes it, so
+// pages don't each grow a new buffer and a template that fails partway doesn't
+// send half a page.
+func executeTemplate(w io.Writer, plate *template.Template, data any) error {
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			renderBuffers.Put(buf)
+		}
+	}()
+	if err := plate.Execute(buf, data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
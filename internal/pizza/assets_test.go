@@ -0,0 +1,35 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssets(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "css"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css/index.css"), []byte("body {}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css/index.css.gz"), []byte("gzip"), 0o644))
+
+	// WHEN
+	manifest, err := pizza.BuildAssets(dir)
+	require.NoError(t, err)
+	require.NoError(t, pizza.WriteAssetManifest(dir))
+	// the manifest is read instead of hashing the files again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css/index.css"), []byte("changed"), 0o644))
+	require.NoError(t, pizza.LoadAssets(dir))
+
+	// THEN
+	assert.Len(t, manifest, 1)
+	assert.Equal(t, "sha384-JvbluEOKMBmUtNHx346xlZFWqKqtOmexOupPSHRCR0NbwTey4wjq9itKKoSWuGsH", manifest["css/index.css"].Integrity)
+	assert.Equal(t, "/static/css/index.css?v="+manifest["css/index.css"].Version, pizza.AssetURL("css/index.css"))
+	assert.Equal(t, manifest["css/index.css"].Integrity, pizza.AssetIntegrity("css/index.css"))
+	assert.Equal(t, "/static/js/missing.js", pizza.AssetURL("js/missing.js"))
+	assert.Equal(t, "", pizza.AssetIntegrity("js/missing.js"))
+}
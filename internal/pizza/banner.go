@@ -0,0 +1,25 @@
+package pizza
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	BannerInfo    = "info"
+	BannerWarning = "warning"
+)
+
+// RenderBanner is the banner's HTML, or nothing when there is no banner at
+// now. The message is basic markdown.
+func RenderBanner(now time.Time) string {
+	s := CurrentSettings()
+	if len(s.BannerMessage) == 0 || (!s.BannerExpires.IsZero() && !now.Before(s.BannerExpires)) {
+		return ""
+	}
+	role := "status"
+	if s.BannerLevel == BannerWarning {
+		role = "alert"
+	}
+	return fmt.Sprintf(`<div class="banner banner-%s" role="%s">%s</div>`, s.BannerLevel, role, RenderBasicMarkdown(s.BannerMessage))
+}
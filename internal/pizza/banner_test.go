@@ -0,0 +1,37 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderBanner(t *testing.T) {
+	// GIVEN
+	now := time.Date(2023, 4, 7, 12, 0, 0, 0, time.UTC)
+	defer pizza.ApplySettings(map[string]string{})
+
+	// WHEN
+	pizza.ApplySettings(map[string]string{"bannerMessage": "New address **this week**", "bannerLevel": "warning", "bannerExpires": "2023-04-08T00:00"})
+
+	// THEN the banner shows until it expires in New York time
+	assert.Equal(t, pizza.BannerWarning, pizza.CurrentSettings().BannerLevel)
+	assert.Contains(t, pizza.RenderBanner(now), `<div class="banner banner-warning" role="alert">`)
+	assert.Contains(t, pizza.RenderBanner(now), "<strong>this week</strong>")
+	assert.Equal(t, "", pizza.RenderBanner(time.Date(2023, 4, 8, 4, 0, 0, 0, time.UTC)))
+
+	// WHEN
+	pizza.ApplySettings(map[string]string{"bannerMessage": "<script>", "bannerLevel": "loud"})
+
+	// THEN an invalid level is ignored and the message is escaped
+	assert.Equal(t, pizza.BannerWarning, pizza.CurrentSettings().BannerLevel)
+	assert.NotContains(t, pizza.RenderBanner(now), "<script>")
+
+	// WHEN
+	pizza.ApplySettings(map[string]string{})
+
+	// THEN
+	assert.Equal(t, "", pizza.RenderBanner(now))
+}
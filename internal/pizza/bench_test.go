@@ -0,0 +1,84 @@
+package pizza_test
+
+import (
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The allocation budgets keep the hot paths from creeping up on a 256MB
+// instance. Raise them deliberately, with the benchmark numbers to show why.
+const (
+	renderIndexAllocBudget      = 100
+	cacheGetAllocBudget         = 0
+	submitValidationAllocBudget = 30
+)
+
+func TestAllocationBudgets(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/index.html")
+	require.NoError(t, err)
+	data := pizza.TemplateFixtures["html/index.html"][1]
+	cache := pizza.NewCache(time.Hour, func(key string) ([]pizza.Friday, error) {
+		return []pizza.Friday{{Start: time.Now()}}, nil
+	})
+	cache.Get("90")
+	form := url.Values{"ft": {pizza.FormToken()}}
+	sig := pizza.SignLink("invite", "believe@tedlasso.com", "1700000000")
+
+	// WHEN
+	render := testing.AllocsPerRun(100, func() { pizza.ExecuteTemplate(io.Discard, plate, data) })
+	get := testing.AllocsPerRun(100, func() { cache.Get("90") })
+	validate := testing.AllocsPerRun(100, func() {
+		pizza.CheckSpam(form)
+		pizza.VerifyLink(sig, "invite", "believe@tedlasso.com", "1700000000")
+	})
+
+	// THEN
+	assert.LessOrEqual(t, render, float64(renderIndexAllocBudget))
+	assert.LessOrEqual(t, get, float64(cacheGetAllocBudget))
+	assert.LessOrEqual(t, validate, float64(submitValidationAllocBudget))
+}
+
+func BenchmarkRenderIndex(b *testing.B) {
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/index.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := pizza.TemplateFixtures["html/index.html"][1]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = pizza.ExecuteTemplate(io.Discard, plate, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCacheGet(b *testing.B) {
+	cache := pizza.NewCache(time.Hour, func(key string) ([]pizza.Friday, error) {
+		return []pizza.Friday{{Start: time.Now()}}, nil
+	})
+	cache.Get("90")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get("90")
+	}
+}
+
+func BenchmarkSubmitValidation(b *testing.B) {
+	form := url.Values{"ft": {pizza.FormToken()}, "email": {"believe@tedlasso.com"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pizza.CheckSpam(form)
+	}
+}
@@ -0,0 +1,60 @@
+package pizza
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// BirthdayWindow is how close to a guest's birthday a party has to be for the
+// host to be told to get a candle.
+var BirthdayWindow = 3 * 24 * time.Hour
+
+var ErrInvalidBirthday = errors.New("birthdays look like 04-08")
+
+// ParseBirthday reads a birthday as MM-DD, or a date with the year which is
+// then dropped, returning it as MM-DD.
+func ParseBirthday(s string) (string, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	// parse in a leap year so 02-29 is allowed
+	if t, err := time.Parse("2006-01-02", "2024-"+s); err == nil {
+		return t.Format("01-02"), nil
+	} else if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Format("01-02"), nil
+	}
+	return "", ErrInvalidBirthday
+}
+
+// BirthdayNear is true when the birthday, as MM-DD, falls within the
+// BirthdayWindow of the time in New York. Leap day birthdays are on March 1st
+// in other years.
+func BirthdayNear(birthday string, t time.Time) bool {
+	day, err := time.Parse("2006-01-02", "2024-"+birthday)
+	if err != nil {
+		return false
+	}
+	estZone, _ := time.LoadLocation("America/New_York")
+	t = t.In(estZone)
+	for year := t.Year() - 1; year <= t.Year()+1; year++ {
+		on := time.Date(year, day.Month(), day.Day(), 0, 0, 0, 0, estZone)
+		if t.After(on.Add(-BirthdayWindow)) && t.Before(on.AddDate(0, 0, 1).Add(BirthdayWindow)) {
+			return true
+		}
+	}
+	return false
+}
+
+// BirthdayGuests returns the emails of the guests whose birthday is near the
+// party, in order, given the birthdays by email from ListFriendBirthdays.
+func BirthdayGuests(friday Friday, rsvps []RSVP, birthdays map[string]string) []string {
+	guests := []string{}
+	for _, rsvp := range rsvps {
+		if birthday, ok := birthdays[rsvp.Email]; ok && BirthdayNear(birthday, friday.Start) {
+			guests = append(guests, rsvp.Email)
+		}
+	}
+	sort.Strings(guests)
+	return guests
+}
@@ -0,0 +1,52 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestParseBirthday(t *testing.T) {
+	for in, want := range map[string]string{"": "", "04-08": "04-08", "1985-04-08": "04-08", "02-29": "02-29"} {
+		got, err := pizza.ParseBirthday(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"4/8", "13-01", "02-30", "April 8"} {
+		_, err := pizza.ParseBirthday(in)
+		assert.Equal(t, pizza.ErrInvalidBirthday, err, in)
+	}
+}
+
+func TestBirthdayNear(t *testing.T) {
+	// GIVEN a party on Friday evening in New York, Saturday in UTC
+	party := time.Date(2023, 4, 8, 0, 30, 0, 0, time.UTC)
+
+	// THEN birthdays within a few days either side are near
+	assert.True(t, pizza.BirthdayNear("04-07", party))
+	assert.True(t, pizza.BirthdayNear("04-10", party))
+	assert.True(t, pizza.BirthdayNear("04-04", party))
+	assert.False(t, pizza.BirthdayNear("04-12", party))
+	assert.False(t, pizza.BirthdayNear("03-30", party))
+	assert.False(t, pizza.BirthdayNear("", party))
+
+	// AND across the new year
+	assert.True(t, pizza.BirthdayNear("01-01", time.Date(2022, 12, 30, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, pizza.BirthdayNear("12-30", time.Date(2023, 1, 1, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestBirthdayGuests(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 22, 0, 0, 0, time.UTC)}
+	rsvps := []pizza.RSVP{{Email: "roy@kent.com"}, {Email: "believe@tedlasso.com"}, {Email: "keeley@jones.com"}}
+	birthdays := map[string]string{"believe@tedlasso.com": "04-08", "roy@kent.com": "04-06", "keeley@jones.com": "09-01", "nate@richmond.com": "04-07"}
+
+	// WHEN
+	guests := pizza.BirthdayGuests(friday, rsvps, birthdays)
+
+	// THEN only guests coming are flagged
+	assert.Equal(t, []string{"believe@tedlasso.com", "roy@kent.com"}, guests)
+}
@@ -0,0 +1,62 @@
+package pizza
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps uploaded files such as event photos.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	// URL is where the blob is served from.
+	URL(key string) string
+}
+
+var blobStore BlobStore
+
+var ErrBlobKey = errors.New("invalid blob key")
+
+// FileBlobStore stores blobs in a directory served under /uploads/.
+type FileBlobStore struct {
+	dir string
+}
+
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{dir: dir}
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrBlobKey
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *FileBlobStore) Put(key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *FileBlobStore) URL(key string) string {
+	return "/uploads/" + strings.TrimPrefix(key, "/")
+}
+
+// Handler serves the stored blobs. Keys are unique per upload, so they can be
+// cached forever.
+func (s *FileBlobStore) Handler() http.Handler {
+	return http.StripPrefix("/uploads/", FileServer(s.dir, "public, max-age=31536000, immutable"))
+}
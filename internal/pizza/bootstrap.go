@@ -0,0 +1,173 @@
+package pizza
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	f "github.com/fauna/faunadb-go/v4/faunadb"
+)
+
+// FaunaCollections are the collections the server stores documents in.
+var FaunaCollections = []string{
+	"fridays", "friends", "rsvps", "notifications", "settings", "quarantine",
+	"content", "reactions", "passkeys", "devices", "changes", "email_events",
+	"admin", "audit",
+}
+
+// FaunaIndex describes an index the server queries. Fields are paths like
+// "data.email", or "ts" and "ref".
+type FaunaIndex struct {
+	Name   string
+	Source string
+	Terms  []string
+	Values []string
+	Unique bool
+}
+
+// FaunaIndexes are the indexes the server queries.
+var FaunaIndexes = []FaunaIndex{
+	{Name: "all_emails", Source: "friends", Terms: []string{"data.email"}, Unique: true},
+	{Name: "all_fridays", Source: "fridays", Values: []string{"data.date"}},
+	{Name: "all_fridays_range", Source: "fridays", Values: []string{"data.date", "ref"}},
+	{Name: "fridays_by_uid", Source: "fridays", Terms: []string{"data.uid"}, Unique: true},
+	{Name: "rsvp_codes", Source: "friends", Terms: []string{"data.email", "data.rsvp_code"}},
+	{Name: "rsvps_by_status", Source: "rsvps", Terms: []string{"data.status"}},
+	{Name: "rsvps_by_invite_pending", Source: "rsvps", Terms: []string{"data.invite_pending"}},
+	{Name: "friends_by_rsvp_code_expires", Source: "friends", Values: []string{"data.rsvp_code_expires", "ref"}},
+	{Name: "friends_by_alias", Source: "friends", Terms: []string{"data.aliases"}},
+	{Name: "friends_by_phone", Source: "friends", Terms: []string{"data.phone"}},
+	{Name: "friends_by_email_status", Source: "friends", Terms: []string{"data.email_status"}},
+	{Name: "friends_by_digest", Source: "friends", Terms: []string{"data.digest"}},
+	{Name: "friends_by_referrer", Source: "friends", Terms: []string{"data.referred_by"}},
+	{Name: "rsvps_by_friday", Source: "rsvps", Terms: []string{"data.friday"}},
+	{Name: "rsvps_by_friend", Source: "rsvps", Terms: []string{"data.email"}},
+	{Name: "rsvps_by_friend_friday", Source: "rsvps", Terms: []string{"data.email", "data.friday"}},
+	{Name: "notifications_by_friday", Source: "notifications", Terms: []string{"data.friday", "data.kind"}},
+	{Name: "notifications_by_friend_friday", Source: "notifications", Terms: []string{"data.email", "data.friday", "data.kind"}},
+	{Name: "reactions_by_friday", Source: "reactions", Terms: []string{"data.friday"}},
+	{Name: "reactions_by_friend_friday", Source: "reactions", Terms: []string{"data.email", "data.friday"}},
+	{Name: "passkeys_by_id", Source: "passkeys", Terms: []string{"data.id"}, Unique: true},
+	{Name: "passkeys_by_friend", Source: "passkeys", Terms: []string{"data.email"}},
+	{Name: "devices_by_token", Source: "devices", Terms: []string{"data.token"}},
+	{Name: "devices_by_prev", Source: "devices", Terms: []string{"data.prev"}},
+	{Name: "devices_by_friend", Source: "devices", Terms: []string{"data.email"}},
+	{Name: "changes_by_ts", Source: "changes", Values: []string{"ts", "ref"}},
+	{Name: "email_events_by_ts", Source: "email_events", Values: []string{"ts", "ref"}},
+	{Name: "email_events_by_friend", Source: "email_events", Terms: []string{"data.email"}},
+	{Name: "notifications_by_friend", Source: "notifications", Terms: []string{"data.email"}},
+	{Name: "reactions_by_friend", Source: "reactions", Terms: []string{"data.email"}},
+	{Name: "audit_by_ts", Source: "audit", Values: []string{"ts", "ref"}},
+}
+
+func faunaFields(paths []string) f.Arr {
+	fields := f.Arr{}
+	for _, path := range paths {
+		field := f.Arr{}
+		for _, part := range strings.Split(path, ".") {
+			field = append(field, part)
+		}
+		fields = append(fields, f.Obj{"field": field})
+	}
+	return fields
+}
+
+// BootstrapStep is one thing bootstrap checked or created.
+type BootstrapStep struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Created is set for what was missing, which a dry run leaves missing
+	Created bool   `json:"created"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BootstrapFauna creates the collections and indexes that don't exist yet, or
+// with dryRun only finds them. Existing ones are left alone, so it is safe to
+// run again.
+func BootstrapFauna(dryRun bool) ([]BootstrapStep, error) {
+	var steps []BootstrapStep
+	for _, name := range FaunaCollections {
+		/*
+			If(Exists(Collection("fridays")), false, Do(CreateCollection({ name: "fridays" }), true))
+		*/
+		created, err := createIfMissing(f.Collection(name), f.CreateCollection(f.Obj{"name": name}), dryRun)
+		steps = append(steps, BootstrapStep{Kind: "collection", Name: name, Created: created})
+		if err != nil {
+			steps[len(steps)-1].Error = err.Error()
+			return steps, err
+		}
+	}
+	for _, index := range FaunaIndexes {
+		params := f.Obj{"name": index.Name, "source": f.Collection(index.Source), "unique": index.Unique}
+		if len(index.Terms) > 0 {
+			params["terms"] = faunaFields(index.Terms)
+		}
+		if len(index.Values) > 0 {
+			params["values"] = faunaFields(index.Values)
+		}
+		created, err := createIfMissing(f.Index(index.Name), f.CreateIndex(params), dryRun)
+		steps = append(steps, BootstrapStep{Kind: "index", Name: index.Name, Created: created})
+		if err != nil {
+			steps[len(steps)-1].Error = err.Error()
+			return steps, err
+		}
+	}
+	return steps, nil
+}
+
+func createIfMissing(ref, create f.Expr, dryRun bool) (bool, error) {
+	missing := f.Do(create, true)
+	if dryRun {
+		missing = f.BooleanV(true)
+	}
+	qRes, err := faunaClient.Query(f.If(f.Exists(ref), false, missing))
+	if err != nil {
+		return false, err
+	}
+	var created bool
+	err = qRes.Get(&created)
+	return created, err
+}
+
+// BootstrapCalendar checks the calendar credentials can read the calendar.
+func BootstrapCalendar(config CalendarConfig) BootstrapStep {
+	step := BootstrapStep{Kind: "calendar", Name: config.ID}
+	if config.Provider == "caldav" {
+		step.Name = config.CalDAV.URL
+	}
+	if err := InitCalendar(config, context.Background()); err != nil {
+		step.Error = err.Error()
+	} else if _, err = ListEvents(1); err != nil {
+		step.Error = err.Error()
+	}
+	return step
+}
+
+// BootstrapEmail checks the SMTP server accepts the credentials. It is skipped
+// when email is not configured.
+func BootstrapEmail(config EmailConfig) *BootstrapStep {
+	if len(config.SMTPHost) == 0 {
+		return nil
+	}
+	step := &BootstrapStep{Kind: "smtp", Name: config.SMTPHost}
+	client, err := smtp.Dial(fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort))
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: config.SMTPHost}); err != nil {
+			step.Error = err.Error()
+			return step
+		}
+	}
+	if len(config.Username) > 0 {
+		if err = client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)); err != nil {
+			step.Error = err.Error()
+		}
+	}
+	return step
+}
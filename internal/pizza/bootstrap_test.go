@@ -0,0 +1,48 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapCoversQueries(t *testing.T) {
+	// GIVEN
+	collections := map[string]bool{}
+	for _, name := range pizza.FaunaCollections {
+		collections[name] = true
+	}
+	indexes := map[string]bool{}
+	for _, index := range pizza.FaunaIndexes {
+		assert.False(t, indexes[index.Name], "index %s is listed twice", index.Name)
+		assert.True(t, collections[index.Source], "index %s is on unknown collection %s", index.Name, index.Source)
+		indexes[index.Name] = true
+	}
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	used := regexp.MustCompile(`f\.(Index|Collection)\("([a-z_]+)"\)`)
+
+	// WHEN
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		// THEN
+		for _, m := range used.FindAllStringSubmatch(string(src), -1) {
+			if m[1] == "Index" {
+				assert.True(t, indexes[m[2]], "%s queries index %s that bootstrap doesn't create", file, m[2])
+			} else {
+				assert.True(t, collections[m[2]], "%s uses collection %s that bootstrap doesn't create", file, m[2])
+			}
+		}
+	}
+}
@@ -2,7 +2,10 @@ package pizza
 
 import (
 	"errors"
+	"sync"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 type CacheValue[V any] struct {
@@ -14,40 +17,98 @@ type Cache[T any] struct {
 	ttl     time.Duration
 	store   map[string]CacheValue[T]
 	refresh func(key string) (T, error)
+	// stale is how long past the ttl a value is still served while it is
+	// refreshed in the background
+	stale      time.Duration
+	refreshing map[string]bool
+	// generation changes whenever values are removed, so a background refresh
+	// that started before doesn't put an old value back
+	generation int
+	mu         *sync.Mutex
 }
 
 func NewCache[T any](ttl time.Duration, refreshFunc func(key string) (T, error)) Cache[T] {
 	return Cache[T]{
-		ttl:     ttl,
-		store:   make(map[string]CacheValue[T]),
-		refresh: refreshFunc,
+		ttl:        ttl,
+		store:      make(map[string]CacheValue[T]),
+		refresh:    refreshFunc,
+		refreshing: make(map[string]bool),
+		mu:         &sync.Mutex{},
 	}
 }
 
+// StaleWhileRevalidate serves values up to stale past their ttl right away,
+// refreshing them in the background, so a slow refresh only holds up the
+// first lookup after a value has been stale for that long.
+func (c *Cache[T]) StaleWhileRevalidate(stale time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale = stale
+}
+
 func (c *Cache[T]) Get(key string) (T, error) {
+	c.mu.Lock()
 	v, ok := c.store[key]
-	if !ok || v.createdAt.Add(c.ttl).Before(time.Now()) {
-		if c.refresh != nil {
-			newVal, err := c.refresh(key)
-			if err != nil {
-				return *(new(T)), err
-			}
-			v = CacheValue[T]{newVal, time.Now()}
-			c.store[key] = v
-			return newVal, nil
-		} else {
-			return *(new(T)), errors.New("not found")
+	age := time.Since(v.createdAt)
+	if ok && age <= c.ttl {
+		c.mu.Unlock()
+		return v.val, nil
+	} else if c.refresh == nil {
+		c.mu.Unlock()
+		return *(new(T)), errors.New("not found")
+	} else if ok && age <= c.ttl+c.stale {
+		if !c.refreshing[key] {
+			c.refreshing[key] = true
+			go c.revalidate(key, c.generation)
 		}
-	} else {
+		c.mu.Unlock()
 		return v.val, nil
 	}
+	c.mu.Unlock()
+
+	newVal, err := c.refresh(key)
+	if err != nil {
+		return *(new(T)), err
+	}
+	c.Store(key, newVal)
+	return newVal, nil
+}
+
+func (c *Cache[T]) revalidate(key string, generation int) {
+	newVal, err := c.refresh(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+	if err != nil {
+		Log.Warn("cache refresh failed", zap.Error(err), zap.String("key", key))
+	} else if generation == c.generation {
+		c.store[key] = CacheValue[T]{newVal, time.Now()}
+	}
 }
 
 func (c *Cache[T]) Has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	_, ok := c.store[key]
 	return ok
 }
 
 func (c *Cache[T]) Store(key string, val T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.store[key] = CacheValue[T]{val, time.Now()}
 }
+
+func (c *Cache[T]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.store, key)
+	c.generation++
+}
+
+func (c *Cache[T]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = make(map[string]CacheValue[T])
+	c.generation++
+}
@@ -1,6 +1,7 @@
 package pizza_test
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -51,3 +52,81 @@ func TestCacheStore(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, data, val)
 }
+
+func TestCacheDelete(t *testing.T) {
+	// GIVEN
+	calls := 0
+	refresh := func(key string) (int, error) {
+		calls++
+		return calls, nil
+	}
+	cache := pizza.NewCache(time.Hour, refresh)
+	cache.Get("foo")
+
+	// WHEN
+	cache.Delete("foo")
+	val, err := cache.Get("foo")
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, 2, val)
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	// GIVEN
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	refresh := func(key string) (int, error) {
+		n := int(calls.Add(1))
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil
+	}
+	cache := pizza.NewCache(50*time.Millisecond, refresh)
+	cache.StaleWhileRevalidate(time.Minute)
+	val, err := cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, val)
+
+	// WHEN the value is stale
+	time.Sleep(100 * time.Millisecond)
+	val, err = cache.Get("foo")
+
+	// THEN the stale value is served while it is refreshed
+	assert.Nil(t, err)
+	assert.Equal(t, 1, val)
+	<-refreshed
+	assert.Eventually(t, func() bool {
+		val, _ := cache.Get("foo")
+		return val == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCacheClearDuringRevalidate(t *testing.T) {
+	// GIVEN a refresh that is still running when the cache is cleared
+	var calls atomic.Int32
+	release := make(chan struct{})
+	refresh := func(key string) (int, error) {
+		n := int(calls.Add(1))
+		if n == 2 {
+			<-release
+		}
+		return n, nil
+	}
+	cache := pizza.NewCache(50*time.Millisecond, refresh)
+	cache.StaleWhileRevalidate(time.Minute)
+	cache.Get("foo")
+	time.Sleep(100 * time.Millisecond)
+	cache.Get("foo")
+
+	// WHEN
+	cache.Clear()
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+
+	// THEN the old refresh doesn't put its value back
+	val, err := cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, val)
+}
@@ -0,0 +1,493 @@
+package pizza
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/calendar/v3"
+)
+
+// CalDAVCalendar keeps the events on a CalDAV (RFC 4791) calendar, like
+// Fastmail, iCloud, or Nextcloud. The server sends the invites to the guests
+// of an event the organizer puts there.
+type CalDAVCalendar struct {
+	// URL is the calendar collection, ending in a slash
+	URL       string
+	username  string
+	password  string
+	organizer string
+	client    *http.Client
+}
+
+var _ Calendar = &CalDAVCalendar{}
+
+// CalDAVError is a request the CalDAV server refused.
+type CalDAVError struct {
+	Code   int
+	Status string
+}
+
+func (e *CalDAVError) Error() string {
+	return "caldav: " + e.Status
+}
+
+func NewCalDAVCalendar(config CalDAVConfig) *CalDAVCalendar {
+	organizer := config.Organizer
+	if len(organizer) == 0 {
+		organizer = config.Username
+	}
+	return &CalDAVCalendar{
+		URL:       strings.TrimSuffix(config.URL, "/") + "/",
+		username:  config.Username,
+		password:  config.Password,
+		organizer: organizer,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *CalDAVCalendar) String() string {
+	return c.URL
+}
+
+// other is the calendar at id, which may be relative to this one's URL.
+func (c *CalDAVCalendar) other(id string) Calendar {
+	other := *c
+	if base, err := url.Parse(c.URL); err == nil {
+		if ref, err := url.Parse(id); err == nil {
+			other.URL = strings.TrimSuffix(base.ResolveReference(ref).String(), "/") + "/"
+		}
+	}
+	return &other
+}
+
+func (c *CalDAVCalendar) eventURL(eventID string) string {
+	return c.URL + url.PathEscape(eventID) + ".ics"
+}
+
+// do makes the request through the calendar workers and returns the body and
+// ETag of a successful response.
+func (c *CalDAVCalendar) do(method, target string, header http.Header, body string) (data []byte, etag string, err error) {
+	err = callCalendar(func() error {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if len(c.username) > 0 {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &CalDAVError{Code: resp.StatusCode, Status: resp.Status}
+		}
+		etag = resp.Header.Get("ETag")
+		data, err = io.ReadAll(resp.Body)
+		return err
+	})
+	return data, etag, err
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		CalendarData string `xml:"propstat>prop>calendar-data"`
+	} `xml:"response"`
+}
+
+func (c *CalDAVCalendar) ListEvents(from, to time.Time, max int64) ([]*calendar.Event, error) {
+	timeRange := fmt.Sprintf(`start="%s"`, from.UTC().Format(icalTimeFormat))
+	data := "<c:calendar-data/>"
+	if !to.IsZero() {
+		timeRange += fmt.Sprintf(` end="%s"`, to.UTC().Format(icalTimeFormat))
+		// recurring events are expanded to each time they happen, like
+		// Google's single events
+		data = "<c:calendar-data><c:expand " + timeRange + "/></c:calendar-data>"
+	}
+	query := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">` +
+		`<d:prop>` + data + `</d:prop>` +
+		`<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">` +
+		`<c:time-range ` + timeRange + `/>` +
+		`</c:comp-filter></c:comp-filter></c:filter>` +
+		`</c:calendar-query>`
+	body, _, err := c.do("REPORT", c.URL, http.Header{
+		"Content-Type": {"application/xml; charset=utf-8"},
+		"Depth":        {"1"},
+	}, query)
+	if err != nil {
+		return nil, err
+	}
+	var multistatus davMultistatus
+	if err = xml.Unmarshal(body, &multistatus); err != nil {
+		return nil, err
+	}
+	var events []*calendar.Event
+	for _, resp := range multistatus.Responses {
+		events = append(events, ParseICalEvents(resp.CalendarData)...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventStart(events[i]).Before(eventStart(events[j]))
+	})
+	if max > 0 && int64(len(events)) > max {
+		events = events[:max]
+	}
+	return events, nil
+}
+
+func (c *CalDAVCalendar) GetEvent(eventID string) (*calendar.Event, error) {
+	body, _, err := c.do(http.MethodGet, c.eventURL(eventID), nil, "")
+	if isNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	events := ParseICalEvents(string(body))
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return events[0], nil
+}
+
+func (c *CalDAVCalendar) CreateEvent(event *calendar.Event) (*calendar.Event, error) {
+	_, _, err := c.do(http.MethodPut, c.eventURL(event.Id), http.Header{
+		"Content-Type":  {"text/calendar; charset=utf-8"},
+		"If-None-Match": {"*"},
+	}, CalDAVEventICal(event, c.organizer))
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// UpdateEvent adds the event if it isn't on the calendar.
+func (c *CalDAVCalendar) UpdateEvent(event *calendar.Event) (*calendar.Event, error) {
+	_, _, err := c.do(http.MethodPut, c.eventURL(event.Id), http.Header{
+		"Content-Type": {"text/calendar; charset=utf-8"},
+	}, CalDAVEventICal(event, c.organizer))
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// rewriteEvent writes the event back with the change made to it, only if it
+// wasn't changed since it was read, reading it again when it was. change
+// reports whether there is anything to write.
+func (c *CalDAVCalendar) rewriteEvent(eventID string, change func(event *calendar.Event) bool) (*calendar.Event, error) {
+	for attempt := 0; ; attempt++ {
+		body, etag, err := c.do(http.MethodGet, c.eventURL(eventID), nil, "")
+		if isNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		events := ParseICalEvents(string(body))
+		if len(events) == 0 {
+			return nil, nil
+		}
+		event := events[0]
+		if !change(event) {
+			return event, nil
+		}
+		header := http.Header{"Content-Type": {"text/calendar; charset=utf-8"}}
+		if len(etag) > 0 {
+			header["If-Match"] = []string{etag}
+		}
+		_, _, err = c.do(http.MethodPut, c.eventURL(eventID), header, CalDAVEventICal(event, c.organizer))
+		if isConflict(err) && attempt < calendarConflictRetries {
+			Log.Debug("event changed while rewriting it, retrying", zap.String("eventID", eventID))
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+}
+
+func (c *CalDAVCalendar) InviteToEvent(eventID, name, email string) (*calendar.Event, error) {
+	return c.rewriteEvent(eventID, func(event *calendar.Event) bool {
+		if _, ok := withoutAttendee(event.Attendees, email); ok {
+			return false
+		}
+		event.Attendees = append(event.Attendees, &calendar.EventAttendee{
+			DisplayName:    name,
+			Email:          email,
+			ResponseStatus: "needsAction",
+		})
+		return true
+	})
+}
+
+func (c *CalDAVCalendar) RemoveFromEvent(eventID, email string) (*calendar.Event, error) {
+	return c.rewriteEvent(eventID, func(event *calendar.Event) bool {
+		attendees, ok := withoutAttendee(event.Attendees, email)
+		event.Attendees = attendees
+		return ok
+	})
+}
+
+func (c *CalDAVCalendar) SetEventDescription(eventID, description string) (*calendar.Event, error) {
+	return c.rewriteEvent(eventID, func(event *calendar.Event) bool {
+		if event.Description == description {
+			return false
+		}
+		event.Description = description
+		return true
+	})
+}
+
+func (c *CalDAVCalendar) DeleteEvent(eventID string) error {
+	_, _, err := c.do(http.MethodDelete, c.eventURL(eventID), nil, "")
+	return err
+}
+
+func eventStart(event *calendar.Event) time.Time {
+	if event.Start == nil {
+		return time.Time{}
+	}
+	if len(event.Start.DateTime) > 0 {
+		start, _ := time.Parse(time.RFC3339, event.Start.DateTime)
+		return start
+	}
+	start, _ := time.Parse("2006-01-02", event.Start.Date)
+	return start
+}
+
+// partStats are Google's attendee response statuses by their iCalendar
+// PARTSTAT.
+var partStats = map[string]string{
+	"NEEDS-ACTION": "needsAction",
+	"ACCEPTED":     "accepted",
+	"DECLINED":     "declined",
+	"TENTATIVE":    "tentative",
+}
+
+// CalDAVEventICal is the event as an iCalendar object for a CalDAV server,
+// with the organizer inviting its attendees.
+func CalDAVEventICal(event *calendar.Event, organizer string) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//rsvp.pizza//EN",
+		"BEGIN:VEVENT",
+		"UID:" + event.Id,
+		"DTSTAMP:" + time.Now().UTC().Format(icalTimeFormat),
+		"DTSTART" + icalDateTime(event.Start),
+		"DTEND" + icalDateTime(event.End),
+		"SUMMARY:" + icalEscape(event.Summary),
+		"DESCRIPTION:" + icalEscape(event.Description),
+	}
+	if len(event.Status) > 0 {
+		lines = append(lines, "STATUS:"+strings.ToUpper(event.Status))
+	}
+	if len(event.Visibility) > 0 && event.Visibility != "default" {
+		lines = append(lines, "CLASS:"+strings.ToUpper(event.Visibility))
+	}
+	if len(event.Transparency) > 0 {
+		lines = append(lines, "TRANSP:"+strings.ToUpper(event.Transparency))
+	}
+	if len(event.Attendees) > 0 && len(organizer) > 0 {
+		lines = append(lines, "ORGANIZER:mailto:"+organizer)
+	}
+	for _, attendee := range event.Attendees {
+		partStat := "NEEDS-ACTION"
+		for stat, status := range partStats {
+			if status == attendee.ResponseStatus {
+				partStat = stat
+			}
+		}
+		line := "ATTENDEE"
+		if len(attendee.DisplayName) > 0 {
+			line += `;CN="` + strings.ReplaceAll(attendee.DisplayName, `"`, "'") + `"`
+		}
+		lines = append(lines, line+";ROLE=REQ-PARTICIPANT;PARTSTAT="+partStat+";RSVP=TRUE:mailto:"+attendee.Email)
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+	var b strings.Builder
+	writeFoldedLines(&b, lines)
+	return b.String()
+}
+
+// icalDateTime is a DTSTART or DTEND value with its parameters, in UTC unless
+// it is a whole day.
+func icalDateTime(dt *calendar.EventDateTime) string {
+	if dt == nil {
+		return ":"
+	}
+	if len(dt.DateTime) == 0 {
+		return ";VALUE=DATE:" + strings.ReplaceAll(dt.Date, "-", "")
+	}
+	t, err := time.Parse(time.RFC3339, dt.DateTime)
+	if err != nil {
+		return ":"
+	}
+	return ":" + t.UTC().Format(icalTimeFormat)
+}
+
+// ParseICalEvents reads the events of an iCalendar object into Google's
+// shape. Properties the service doesn't use are skipped.
+func ParseICalEvents(ics string) []*calendar.Event {
+	ics = strings.ReplaceAll(ics, "\r\n", "\n")
+	ics = strings.NewReplacer("\n ", "", "\n\t", "").Replace(ics)
+	var events []*calendar.Event
+	var event *calendar.Event
+	// depth counts the components open inside the event, like alarms
+	depth := 0
+	for _, line := range strings.Split(ics, "\n") {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT" && event == nil:
+			event = &calendar.Event{}
+			continue
+		case event == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && depth > 0:
+			depth--
+			continue
+		case name == "END":
+			events = append(events, event)
+			event = nil
+			continue
+		case depth > 0:
+			continue
+		}
+		switch name {
+		case "UID":
+			event.Id = value
+		case "SUMMARY":
+			event.Summary = icalUnescape(value)
+		case "DESCRIPTION":
+			event.Description = icalUnescape(value)
+		case "DTSTART":
+			event.Start = parseICalDateTime(params, value)
+		case "DTEND":
+			event.End = parseICalDateTime(params, value)
+		case "STATUS":
+			event.Status = strings.ToLower(value)
+		case "CLASS":
+			event.Visibility = strings.ToLower(value)
+		case "TRANSP":
+			event.Transparency = strings.ToLower(value)
+		case "ORGANIZER":
+			event.Organizer = &calendar.EventOrganizer{DisplayName: params["CN"], Email: mailtoAddress(value)}
+		case "ATTENDEE":
+			status, ok := partStats[strings.ToUpper(params["PARTSTAT"])]
+			if !ok {
+				status = "needsAction"
+			}
+			event.Attendees = append(event.Attendees, &calendar.EventAttendee{
+				DisplayName:    params["CN"],
+				Email:          mailtoAddress(value),
+				ResponseStatus: status,
+			})
+		}
+	}
+	for _, event := range events {
+		if event.Organizer == nil {
+			continue
+		}
+		for _, attendee := range event.Attendees {
+			attendee.Organizer = strings.EqualFold(attendee.Email, event.Organizer.Email)
+		}
+	}
+	return events
+}
+
+// splitICalLine splits a content line into its upper case name, parameters,
+// and value. Colons and semicolons in quoted parameters don't split it.
+func splitICalLine(line string) (string, map[string]string, string) {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';':
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case r == ':':
+			parts = append(parts, line[start:i])
+			params := map[string]string{}
+			for _, param := range parts[1:] {
+				if key, val, ok := strings.Cut(param, "="); ok {
+					params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+				}
+			}
+			return strings.ToUpper(parts[0]), params, line[i+1:]
+		}
+	}
+	return "", nil, ""
+}
+
+func parseICalDateTime(params map[string]string, value string) *calendar.EventDateTime {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		day, err := time.Parse("20060102", value)
+		if err != nil {
+			return nil
+		}
+		return &calendar.EventDateTime{Date: day.Format("2006-01-02")}
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalTimeFormat, value)
+		if err != nil {
+			return nil
+		}
+		return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}
+	}
+	// times without a zone are the party's time, in New York
+	zone := params["TZID"]
+	loc, err := time.LoadLocation(zone)
+	if err != nil || len(zone) == 0 {
+		zone = "America/New_York"
+		loc, _ = time.LoadLocation(zone)
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return nil
+	}
+	return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: zone}
+}
+
+func mailtoAddress(value string) string {
+	if len(value) >= 7 && strings.EqualFold(value[:7], "mailto:") {
+		return value[7:]
+	}
+	return value
+}
+
+func icalUnescape(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped && (r == 'n' || r == 'N'):
+			b.WriteRune('\n')
+		case escaped:
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	return b.String()
+}
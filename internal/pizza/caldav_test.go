@@ -0,0 +1,170 @@
+package pizza_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestCalDAVEventICal(t *testing.T) {
+	// GIVEN
+	event := &calendar.Event{
+		Id:          "01g7d2m9r0",
+		Summary:     "Pizza Friday",
+		Description: pizza.EventDescription + "\n\nGuests:\n- Ted Lasso, +2",
+		Start:       &calendar.EventDateTime{DateTime: "2023-04-07T17:30:00-04:00", TimeZone: "America/New_York"},
+		End:         &calendar.EventDateTime{DateTime: "2023-04-07T21:30:00-04:00", TimeZone: "America/New_York"},
+		Status:      "confirmed",
+		Visibility:  "private",
+		Attendees: []*calendar.EventAttendee{
+			{DisplayName: "Ted Lasso", Email: "believe@tedlasso.com", ResponseStatus: "accepted"},
+			{Email: "roy@kent.com"},
+		},
+	}
+
+	// WHEN
+	ics := pizza.CalDAVEventICal(event, "host@rsvp.pizza")
+	parsed := pizza.ParseICalEvents(ics)
+
+	// THEN
+	assert.Contains(t, ics, "DTSTART:20230407T213000Z\r\n")
+	assert.Contains(t, ics, "ORGANIZER:mailto:host@rsvp.pizza\r\n")
+	assert.Contains(t, ics, `ATTENDEE;CN="Ted Lasso";ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED;RSVP=TRUE`)
+	require.Len(t, parsed, 1)
+	assert.Equal(t, event.Id, parsed[0].Id)
+	assert.Equal(t, event.Summary, parsed[0].Summary)
+	assert.Equal(t, event.Description, parsed[0].Description)
+	assert.Equal(t, "2023-04-07T21:30:00Z", parsed[0].Start.DateTime)
+	assert.Equal(t, "confirmed", parsed[0].Status)
+	assert.Equal(t, "private", parsed[0].Visibility)
+	require.Len(t, parsed[0].Attendees, 2)
+	assert.Equal(t, "Ted Lasso", parsed[0].Attendees[0].DisplayName)
+	assert.Equal(t, "believe@tedlasso.com", parsed[0].Attendees[0].Email)
+	assert.Equal(t, "accepted", parsed[0].Attendees[0].ResponseStatus)
+	assert.Equal(t, "needsAction", parsed[0].Attendees[1].ResponseStatus)
+}
+
+func TestParseICalEvents(t *testing.T) {
+	// GIVEN an event made by hand in another calendar app
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:abc-123@example.com\r\n" +
+		"DTSTART;TZID=America/New_York:20230407T173000\r\nDTEND;TZID=America/New_York:20230407T213000\r\n" +
+		"SUMMARY:Pizza\\, again\r\nORGANIZER;CN=Host:mailto:host@rsvp.pizza\r\n" +
+		"ATTENDEE;CN=\"Lasso; Ted\";PARTSTAT=ACCEPTED:MAILTO:believe@tedlasso.com\r\n" +
+		"ATTENDEE;PARTSTAT=ACCEPTED:mailto:host@rsvp.pizza\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Pizza soon\r\nEND:VALARM\r\n" +
+		"DESCRIPTION:Bring a \r\n friend\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:picnic\r\nDTSTART;VALUE=DATE:20230408\r\nDTEND;VALUE=DATE:20230409\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	// WHEN
+	events := pizza.ParseICalEvents(ics)
+
+	// THEN
+	require.Len(t, events, 2)
+	assert.Equal(t, "Pizza, again", events[0].Summary)
+	assert.Equal(t, "Bring a friend", events[0].Description)
+	assert.Equal(t, "2023-04-07T17:30:00-04:00", events[0].Start.DateTime)
+	assert.Equal(t, "America/New_York", events[0].Start.TimeZone)
+	require.Len(t, events[0].Attendees, 2)
+	assert.Equal(t, "Lasso; Ted", events[0].Attendees[0].DisplayName)
+	assert.Equal(t, "believe@tedlasso.com", events[0].Attendees[0].Email)
+	assert.True(t, events[0].Attendees[1].Organizer)
+	assert.Equal(t, "2023-04-08", events[1].Start.Date)
+
+	// THEN events from another app import like those from Google
+	_, rsvps, ok := pizza.ImportedFriday(events[0])
+	assert.True(t, ok)
+	require.Len(t, rsvps, 1)
+	assert.Equal(t, "believe@tedlasso.com", rsvps[0].Email)
+}
+
+func TestCalDAVCalendar(t *testing.T) {
+	// GIVEN a CalDAV server keeping the events it is sent
+	var mu sync.Mutex
+	objects := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if user, pass, _ := r.BasicAuth(); user != "host" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if _, ok := objects[r.URL.Path]; ok && r.Header.Get("If-None-Match") == "*" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			if body, ok := objects[r.URL.Path]; ok {
+				io.WriteString(w, body)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case "REPORT":
+			assert.Equal(t, "1", r.Header.Get("Depth"))
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+			for path, body := range objects {
+				io.WriteString(w, `<d:response><d:href>`+path+`</d:href><d:propstat><d:prop><c:calendar-data>`)
+				io.WriteString(w, strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace(body))
+				io.WriteString(w, `</c:calendar-data></d:prop></d:propstat></d:response>`)
+			}
+			io.WriteString(w, `</d:multistatus>`)
+		}
+	}))
+	defer ts.Close()
+	stored := func(path string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return objects[path]
+	}
+	pizza.SetCalendar(pizza.NewCalDAVCalendar(pizza.CalDAVConfig{URL: ts.URL + "/calendars/host/pizza", Username: "host", Password: "secret"}))
+	defer pizza.SetCalendar(nil)
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+
+	// WHEN
+	_, err := pizza.InviteToCalendarEvent("01g7d2m9r0", start, start.Add(4*time.Hour), "Ted Lasso", "believe@tedlasso.com")
+
+	// THEN the event is made with the friend invited by the host
+	require.Nil(t, err)
+	saved := pizza.ParseICalEvents(stored("/calendars/host/pizza/01g7d2m9r0.ics"))
+	require.Len(t, saved, 1)
+	assert.Equal(t, "host", saved[0].Organizer.Email)
+	require.Len(t, saved[0].Attendees, 1)
+	assert.Equal(t, "believe@tedlasso.com", saved[0].Attendees[0].Email)
+
+	// WHEN
+	events, err := pizza.ListEvents(5)
+
+	// THEN
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "01g7d2m9r0", events[0].Id)
+	assert.Equal(t, "believe@tedlasso.com", events[0].Attendees[0].Email)
+
+	// WHEN
+	require.Nil(t, pizza.RemoveCalendarAttendee("01g7d2m9r0", "believe@tedlasso.com"))
+	require.Nil(t, pizza.DeleteCalendarEvent("01g7d2m9r0"))
+	event, err := pizza.GetCalendarEvent("01g7d2m9r0")
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Nil(t, event)
+	assert.Empty(t, stored("/calendars/host/pizza/01g7d2m9r0.ics"))
+}
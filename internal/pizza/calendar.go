@@ -3,7 +3,11 @@ package pizza
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -13,13 +17,95 @@ import (
 	"google.golang.org/api/option"
 )
 
-type Calendar struct {
-	srv        *calendar.Service
-	id         string
-	eventCache map[string]*calendar.Event
+// Calendar is where the parties are put and their guests invited. Events are
+// in Google Calendar's shape whichever calendar keeps them. GoogleCalendar is
+// used unless calendar.provider is set, in which case a CalDAVCalendar is.
+type Calendar interface {
+	// ListEvents returns the events between from and to, or every event
+	// after from when to is zero, by start time. It returns at most max
+	// events unless max is 0.
+	ListEvents(from, to time.Time, max int64) ([]*calendar.Event, error)
+	// GetEvent returns nil if there is no event with the ID.
+	GetEvent(eventID string) (*calendar.Event, error)
+	CreateEvent(event *calendar.Event) (*calendar.Event, error)
+	// UpdateEvent replaces the event with the same ID.
+	UpdateEvent(event *calendar.Event) (*calendar.Event, error)
+	// InviteToEvent adds the guest to the event, which sends them an invite,
+	// leaving the rest of it as it is on the calendar. It returns the event
+	// as it is after, or nil if there is no such event.
+	InviteToEvent(eventID, name, email string) (*calendar.Event, error)
+	// RemoveFromEvent takes the guest off the event, leaving the rest of it
+	// as it is on the calendar, even when it changed since it was cached. It
+	// returns the event as it is after, or nil if there is no such event.
+	RemoveFromEvent(eventID, email string) (*calendar.Event, error)
+	// SetEventDescription replaces the event's description, leaving the rest
+	// of it, like its guests, as it is on the calendar. It returns the event
+	// as it is after, or nil if there is no such event.
+	SetEventDescription(eventID, description string) (*calendar.Event, error)
+	// DeleteEvent cancels the event, emailing the guests on it.
+	DeleteEvent(eventID string) error
+	// String names the calendar in logs and startup checks.
+	String() string
+	// other is another calendar of the same account, like the mirror.
+	other(id string) Calendar
 }
 
-var cal *Calendar
+var cal Calendar
+
+var (
+	eventCacheMu sync.Mutex
+	eventCache   = map[string]*calendar.Event{}
+	// attendeesMu keeps attendee changes, which read the event and write it
+	// back, from overwriting each other
+	attendeesMu sync.Mutex
+)
+
+func cachedEvent(eventID string) (*calendar.Event, bool) {
+	eventCacheMu.Lock()
+	defer eventCacheMu.Unlock()
+	event, ok := eventCache[eventID]
+	return event, ok
+}
+
+func cacheEvent(eventID string, event *calendar.Event) {
+	eventCacheMu.Lock()
+	defer eventCacheMu.Unlock()
+	eventCache[eventID] = event
+}
+
+const EventDescription = "Welcome to Pizza Friday!"
+
+// calendarConflictRetries is how many more times an event is changed when it
+// changed between reading and writing it, e.g. as a guest replied.
+const calendarConflictRetries = 3
+
+// InitCalendar connects to the configured calendar provider.
+func InitCalendar(config CalendarConfig, ctx context.Context) error {
+	switch config.Provider {
+	case "", "google":
+		return InitCalendarClient(config.CredentialFile, config.TokenFile, config.ID, ctx)
+	case "caldav":
+		SetCalendar(NewCalDAVCalendar(config.CalDAV))
+		return nil
+	}
+	return fmt.Errorf("unknown calendar provider %q", config.Provider)
+}
+
+// SetCalendar replaces the calendar, e.g. with one pointed at a test server.
+func SetCalendar(c Calendar) {
+	eventCacheMu.Lock()
+	eventCache = map[string]*calendar.Event{}
+	eventCacheMu.Unlock()
+	cal = c
+}
+
+// GoogleCalendar keeps the events on a Google Calendar.
+type GoogleCalendar struct {
+	srv *calendar.Service
+	id  string
+}
+
+var _ Calendar = &GoogleCalendar{}
 
 func InitCalendarClient(credentialFile, tokenFile, id string, ctx context.Context) error {
 	b, err := os.ReadFile(credentialFile)
@@ -44,13 +130,166 @@ func InitCalendarClient(credentialFile, tokenFile, id string, ctx context.Contex
 	if srv, err := calendar.NewService(ctx, option.WithHTTPClient(client)); err != nil {
 		return err
 	} else {
-		cal = &Calendar{srv, id, make(map[string]*calendar.Event)}
+		SetCalendar(NewGoogleCalendar(srv, id))
 		return nil
 	}
 }
 
+func NewGoogleCalendar(srv *calendar.Service, id string) *GoogleCalendar {
+	return &GoogleCalendar{srv: srv, id: id}
+}
+
+func (c *GoogleCalendar) String() string {
+	return c.id
+}
+
+func (c *GoogleCalendar) other(id string) Calendar {
+	return &GoogleCalendar{srv: c.srv, id: id}
+}
+
+func (c *GoogleCalendar) ListEvents(from, to time.Time, max int64) ([]*calendar.Event, error) {
+	var events []*calendar.Event
+	pageToken := ""
+	for {
+		list := c.srv.Events.List(c.id).
+			ShowDeleted(false).
+			SingleEvents(true).
+			TimeMin(from.Format(time.RFC3339)).
+			OrderBy("startTime").
+			PageToken(pageToken)
+		if !to.IsZero() {
+			list = list.TimeMax(to.Format(time.RFC3339))
+		}
+		if max > 0 {
+			list = list.MaxResults(max)
+		}
+		var page *calendar.Events
+		err := callCalendar(func() (err error) {
+			// TODO add timeout
+			page, err = list.Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page.Items...)
+		if pageToken = page.NextPageToken; max > 0 || len(pageToken) == 0 {
+			return events, nil
+		}
+	}
+}
+
+func (c *GoogleCalendar) GetEvent(eventID string) (*calendar.Event, error) {
+	var event *calendar.Event
+	err := callCalendar(func() (err error) {
+		// TODO add timeout
+		event, err = c.srv.Events.Get(c.id, eventID).Do()
+		return err
+	})
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return event, err
+}
+
+func (c *GoogleCalendar) CreateEvent(event *calendar.Event) (created *calendar.Event, err error) {
+	err = callCalendar(func() error {
+		// TODO add timeout
+		created, err = c.srv.Events.Insert(c.id, event).Context(context.Background()).Do()
+		return err
+	})
+	return created, err
+}
+
+func (c *GoogleCalendar) UpdateEvent(event *calendar.Event) (updated *calendar.Event, err error) {
+	err = callCalendar(func() error {
+		// TODO add timeout
+		updated, err = c.srv.Events.Update(c.id, event.Id, event).Context(context.Background()).Do()
+		return err
+	})
+	return updated, err
+}
+
+// patchEvent patches the event with the fields change returns, only if the
+// event wasn't changed since it was read, reading it again when it was. change
+// returns nil when there is nothing to patch.
+func (c *GoogleCalendar) patchEvent(eventID string, change func(event *calendar.Event) *calendar.Event) (*calendar.Event, error) {
+	for attempt := 0; ; attempt++ {
+		var event *calendar.Event
+		err := callCalendar(func() (err error) {
+			// TODO add timeout
+			event, err = c.srv.Events.Get(c.id, eventID).Do()
+			return err
+		})
+		if isNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		fields := change(event)
+		if fields == nil {
+			return event, nil
+		}
+		patch := c.srv.Events.Patch(c.id, eventID, fields)
+		patch.Header().Set("If-Match", event.Etag)
+		var patched *calendar.Event
+		err = callCalendar(func() (err error) {
+			// TODO add timeout
+			patched, err = patch.Do()
+			return err
+		})
+		if isConflict(err) && attempt < calendarConflictRetries {
+			Log.Debug("event changed while patching it, retrying", zap.String("eventID", eventID))
+			continue
+		}
+		return patched, err
+	}
+}
+
+// InviteToEvent patches only the event's attendees.
+func (c *GoogleCalendar) InviteToEvent(eventID, name, email string) (*calendar.Event, error) {
+	return c.patchEvent(eventID, func(event *calendar.Event) *calendar.Event {
+		if _, ok := withoutAttendee(event.Attendees, email); ok {
+			return nil
+		}
+		return &calendar.Event{Attendees: append(event.Attendees, &calendar.EventAttendee{
+			DisplayName: name,
+			Email:       email,
+		})}
+	})
+}
+
+// RemoveFromEvent patches only the event's attendees.
+func (c *GoogleCalendar) RemoveFromEvent(eventID, email string) (*calendar.Event, error) {
+	return c.patchEvent(eventID, func(event *calendar.Event) *calendar.Event {
+		attendees, ok := withoutAttendee(event.Attendees, email)
+		if !ok {
+			return nil
+		}
+		// attendees are sent even when none are left, to clear them
+		return &calendar.Event{Attendees: attendees, ForceSendFields: []string{"Attendees"}}
+	})
+}
+
+// SetEventDescription patches only the event's description.
+func (c *GoogleCalendar) SetEventDescription(eventID, description string) (*calendar.Event, error) {
+	return c.patchEvent(eventID, func(event *calendar.Event) *calendar.Event {
+		if event.Description == description {
+			return nil
+		}
+		return &calendar.Event{Description: description, ForceSendFields: []string{"Description"}}
+	})
+}
+
+func (c *GoogleCalendar) DeleteEvent(eventID string) error {
+	return callCalendar(func() error {
+		// TODO add timeout
+		return c.srv.Events.Delete(c.id, eventID).SendUpdates("all").Do()
+	})
+}
+
 func CreateCalendarEvent(eventID string, start, end time.Time) (*calendar.Event, error) {
-	description := "Welcome to Pizza Friday!"
+	description := EventDescription
 	timezone := "America/New_York"
 	guestsCanInviteOthers := false
 	event := calendar.Event{
@@ -73,59 +312,183 @@ func CreateCalendarEvent(eventID string, start, end time.Time) (*calendar.Event,
 		Summary:    "Pizza Friday",
 		Visibility: "private",
 	}
-	// TODO add timeout
-	return cal.srv.Events.Insert(cal.id, &event).Context(context.Background()).Do()
+	return cal.CreateEvent(&event)
 }
 
 func GetCalendarEvent(eventID string) (*calendar.Event, error) {
-	// TODO add timeout
-	if event, ok := cal.eventCache[eventID]; ok {
+	if event, ok := cachedEvent(eventID); ok {
 		return event, nil
 	}
-	if event, err := cal.srv.Events.Get(cal.id, eventID).Do(); err == nil {
-		cal.eventCache[eventID] = event
-		return event, nil
-	} else if err != nil && err.Error() == "googleapi: Error 404: Not Found, notFound" {
-		cal.eventCache[eventID] = nil
-		return nil, nil
-	} else {
+	event, err := cal.GetEvent(eventID)
+	if err != nil {
 		return nil, err
 	}
+	cacheEvent(eventID, event)
+	return event, nil
+}
+
+func updateCalendarEvent(event *calendar.Event) (*calendar.Event, error) {
+	updated, err := cal.UpdateEvent(event)
+	if err == nil {
+		cacheEvent(event.Id, updated)
+	}
+	return updated, err
 }
 
 func InviteToCalendarEvent(eventID string, start, end time.Time, name, email string) (*calendar.Event, error) {
-	// TODO add locks
-	event, err := GetCalendarEvent(eventID)
-	if err != nil {
+	attendeesMu.Lock()
+	defer attendeesMu.Unlock()
+	updated, err := cal.InviteToEvent(eventID, name, email)
+	if err == nil && updated == nil {
 		Log.Info("event does not exist, creating new", zap.String("eventID", eventID))
-		event, err = CreateCalendarEvent(eventID, start, end)
+		event, err := CreateCalendarEvent(eventID, start, end)
 		if err != nil {
 			Log.Error("failed to create event", zap.String("eventID", eventID), zap.Error(err))
 			return nil, err
 		}
 		Log.Info("event created", zap.String("eventID", event.Id))
+		updated, err = cal.InviteToEvent(eventID, name, email)
 	}
-	event.Attendees = append(event.Attendees, &calendar.EventAttendee{
-		DisplayName: name,
-		Email:       email,
-	})
-	// TODO add timeout
-	event, err = cal.srv.Events.Update(cal.id, eventID, event).Do()
+	if err == nil {
+		cacheEvent(eventID, updated)
+	}
+	return updated, err
+}
+
+// ReplaceCalendarAttendee changes the email of an attendee of the event, for
+// when two friends turn out to be the same person.
+func ReplaceCalendarAttendee(eventID, oldEmail, newEmail, name string) error {
+	attendeesMu.Lock()
+	defer attendeesMu.Unlock()
+	event, err := GetCalendarEvent(eventID)
+	if err != nil || event == nil {
+		return err
+	}
+	attendees := []*calendar.EventAttendee{}
+	found, present := false, false
+	for _, attendee := range event.Attendees {
+		if strings.EqualFold(attendee.Email, oldEmail) {
+			found = true
+			continue
+		}
+		present = present || strings.EqualFold(attendee.Email, newEmail)
+		attendees = append(attendees, attendee)
+	}
+	if !found {
+		return nil
+	}
+	if !present {
+		attendees = append(attendees, &calendar.EventAttendee{DisplayName: name, Email: newEmail})
+	}
+	event.Attendees = attendees
+	_, err = updateCalendarEvent(event)
+	return err
+}
+
+// withoutAttendee is the attendees but the one with the email, and whether
+// they were there.
+func withoutAttendee(attendees []*calendar.EventAttendee, email string) ([]*calendar.EventAttendee, bool) {
+	without := []*calendar.EventAttendee{}
+	for _, attendee := range attendees {
+		if !strings.EqualFold(attendee.Email, email) {
+			without = append(without, attendee)
+		}
+	}
+	return without, len(without) < len(attendees)
+}
+
+// RemoveCalendarAttendee takes the friend off the event's guest list, without
+// undoing changes made to the event elsewhere, like other guests replying.
+func RemoveCalendarAttendee(eventID, email string) error {
+	attendeesMu.Lock()
+	defer attendeesMu.Unlock()
+	event, err := cal.RemoveFromEvent(eventID, email)
 	if err != nil {
-		cal.eventCache[eventID] = event
+		return err
 	}
-	return event, err
+	cacheEvent(eventID, event)
+	return nil
+}
+
+// DeleteCalendarEvent cancels the event, emailing the guests on it.
+func DeleteCalendarEvent(eventID string) error {
+	err := cal.DeleteEvent(eventID)
+	if err == nil {
+		cacheEvent(eventID, nil)
+	}
+	return err
 }
 
-func ListEvents(numEvents int64) (*calendar.Events, error) {
-	t := time.Now().Format(time.RFC3339)
-	// TODO add timeout
-	events, err := cal.srv.Events.List(cal.id).
-		ShowDeleted(false).
-		SingleEvents(true).
-		TimeMin(t).
-		MaxResults(numEvents).
-		OrderBy("startTime").
-		Do()
-	return events, err
+// ListEvents lists the next events on the calendar.
+func ListEvents(numEvents int64) ([]*calendar.Event, error) {
+	return cal.ListEvents(time.Now(), time.Time{}, numEvents)
+}
+
+// ListPastEvents lists every event on the calendar that started between since
+// and now, oldest first.
+func ListPastEvents(since time.Time) ([]*calendar.Event, error) {
+	return cal.ListEvents(since, time.Now(), 0)
+}
+
+// BuildEventDescription lists each guest's plus ones, kids, toppings, days,
+// and answers under the standard event description. Names are keyed by email.
+func BuildEventDescription(rsvps []RSVP, names map[string]string) string {
+	var b strings.Builder
+	b.WriteString(EventDescription)
+	if len(rsvps) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n\nGuests:")
+	for _, rsvp := range rsvps {
+		name := names[rsvp.Email]
+		if name == "" {
+			name = rsvp.Email
+		}
+		fmt.Fprintf(&b, "\n- %s", name)
+		if rsvp.PlusOnes > 0 {
+			fmt.Fprintf(&b, " +%d", rsvp.PlusOnes)
+		}
+		if rsvp.Kids == 1 {
+			b.WriteString(" with 1 kid")
+		} else if rsvp.Kids > 1 {
+			fmt.Fprintf(&b, " with %d kids", rsvp.Kids)
+		}
+		if len(rsvp.Toppings) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(rsvp.Toppings, ", "))
+		}
+		if len(rsvp.Days) > 0 {
+			days := make([]string, len(rsvp.Days))
+			for i, date := range rsvp.Days {
+				day, err := time.Parse("2006-01-02", date)
+				if err != nil {
+					days[i] = date
+				} else {
+					days[i] = day.Format("Mon Jan 2")
+				}
+			}
+			fmt.Fprintf(&b, "\n    Only %s", strings.Join(days, ", "))
+		}
+		questions := make([]string, 0, len(rsvp.Answers))
+		for q := range rsvp.Answers {
+			questions = append(questions, q)
+		}
+		sort.Strings(questions)
+		for _, q := range questions {
+			fmt.Fprintf(&b, "\n    %s %s", q, rsvp.Answers[q])
+		}
+	}
+	return b.String()
+}
+
+// UpdateCalendarEventDescription replaces the event's description, without
+// undoing changes made to the event elsewhere, like guests replying.
+func UpdateCalendarEventDescription(eventID, description string) (*calendar.Event, error) {
+	attendeesMu.Lock()
+	defer attendeesMu.Unlock()
+	event, err := cal.SetEventDescription(eventID, description)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	cacheEvent(eventID, event)
+	return event, nil
 }
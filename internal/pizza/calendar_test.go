@@ -2,13 +2,19 @@ package pizza_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
 )
 
 func TestCalendarInvite(t *testing.T) {
@@ -24,3 +30,168 @@ func TestCalendarInvite(t *testing.T) {
 	require.Nil(t, err)
 	pizza.Log.Debug("invite sent", zap.String("eventID", eventID), zap.Time("start", start), zap.Time("end", end))
 }
+
+func TestBuildEventDescription(t *testing.T) {
+	// GIVEN
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 2, Kids: 1, Toppings: []string{"pepperoni", "onion"}},
+		{Email: "roy@kent.com", Days: []string{"2023-04-08"}, Answers: map[string]string{"Bringing drinks?": "yes"}},
+	}
+	names := map[string]string{"believe@tedlasso.com": "Ted Lasso"}
+
+	// WHEN
+	description := pizza.BuildEventDescription(rsvps, names)
+
+	// THEN
+	require.Equal(t, pizza.EventDescription+"\n\nGuests:"+
+		"\n- Ted Lasso +2 with 1 kid (pepperoni, onion)"+
+		"\n- roy@kent.com"+
+		"\n    Only Sat Apr 8"+
+		"\n    Bringing drinks? yes", description)
+	require.Equal(t, pizza.EventDescription, pizza.BuildEventDescription(nil, names))
+}
+
+func TestRemoveCalendarAttendee(t *testing.T) {
+	// GIVEN an event whose guests change on Google's side while Ted cancels
+	event := calendar.Event{Id: "01g7d2m9r0", Etag: `"1"`, Summary: "Pizza Friday", Attendees: []*calendar.EventAttendee{
+		{Email: "believe@tedlasso.com"},
+		{Email: "roy@kent.com", ResponseStatus: "accepted"},
+	}}
+	var patches []map[string]json.RawMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/calendars/pizza/events/01g7d2m9r0", r.URL.Path)
+		if r.Method == http.MethodPatch {
+			patch := map[string]json.RawMessage{}
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&patch))
+			patches = append(patches, patch)
+			if len(patches) == 1 {
+				// Keeley accepts before the first patch lands
+				event.Attendees = append(event.Attendees, &calendar.EventAttendee{Email: "keeley@jones.com", ResponseStatus: "accepted"})
+				event.Etag = `"2"`
+			}
+			if r.Header.Get("If-Match") != event.Etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`{"error": {"code": 412, "message": "Precondition Failed"}}`))
+				return
+			}
+			require.Nil(t, json.Unmarshal(patch["attendees"], &event.Attendees))
+			event.Etag = `"3"`
+		}
+		json.NewEncoder(w).Encode(event)
+	}))
+	defer ts.Close()
+	srv, err := calendar.NewService(context.Background(), option.WithEndpoint(ts.URL+"/"), option.WithHTTPClient(ts.Client()))
+	require.Nil(t, err)
+	pizza.SetCalendar(pizza.NewGoogleCalendar(srv, "pizza"))
+	defer pizza.SetCalendar(nil)
+
+	// WHEN
+	err = pizza.RemoveCalendarAttendee("01g7d2m9r0", "believe@tedlasso.com")
+
+	// THEN only the attendees are patched, again after the conflict, and
+	// Keeley's answer is kept
+	require.Nil(t, err)
+	require.Len(t, patches, 2)
+	for _, patch := range patches {
+		assert.Len(t, patch, 1)
+		assert.Contains(t, patch, "attendees")
+	}
+	require.Len(t, event.Attendees, 2)
+	assert.Equal(t, "roy@kent.com", event.Attendees[0].Email)
+	assert.Equal(t, "keeley@jones.com", event.Attendees[1].Email)
+	cached, err := pizza.GetCalendarEvent("01g7d2m9r0")
+	require.Nil(t, err)
+	assert.Equal(t, `"3"`, cached.Etag)
+}
+
+// conflictingCalendar serves the event, and has Keeley accept it just before
+// the first patch lands, so that patch fails on its If-Match.
+func conflictingCalendar(t *testing.T, event *calendar.Event, patches *[]map[string]json.RawMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/calendars/pizza/events/"+event.Id, r.URL.Path)
+		if r.Method == http.MethodPatch {
+			patch := map[string]json.RawMessage{}
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&patch))
+			*patches = append(*patches, patch)
+			if len(*patches) == 1 {
+				event.Attendees = append(event.Attendees, &calendar.EventAttendee{Email: "keeley@jones.com", ResponseStatus: "accepted"})
+				event.Etag = `"2"`
+			}
+			if r.Header.Get("If-Match") != event.Etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`{"error": {"code": 412, "message": "Precondition Failed"}}`))
+				return
+			}
+			if attendees, ok := patch["attendees"]; ok {
+				require.Nil(t, json.Unmarshal(attendees, &event.Attendees))
+			}
+			if description, ok := patch["description"]; ok {
+				require.Nil(t, json.Unmarshal(description, &event.Description))
+			}
+			event.Etag = `"3"`
+		}
+		json.NewEncoder(w).Encode(event)
+	}))
+}
+
+func TestInviteToCalendarEventConflict(t *testing.T) {
+	// GIVEN an event whose guests change on Google's side while Ted RSVPs
+	event := calendar.Event{Id: "01g7d2m9r0", Etag: `"1"`, Summary: "Pizza Friday", Attendees: []*calendar.EventAttendee{
+		{Email: "roy@kent.com", ResponseStatus: "accepted"},
+	}}
+	var patches []map[string]json.RawMessage
+	ts := conflictingCalendar(t, &event, &patches)
+	defer ts.Close()
+	srv, err := calendar.NewService(context.Background(), option.WithEndpoint(ts.URL+"/"), option.WithHTTPClient(ts.Client()))
+	require.Nil(t, err)
+	pizza.SetCalendar(pizza.NewGoogleCalendar(srv, "pizza"))
+	defer pizza.SetCalendar(nil)
+
+	// WHEN
+	start := time.Now().Add(48 * time.Hour)
+	_, err = pizza.InviteToCalendarEvent("01g7d2m9r0", start, start.Add(4*time.Hour), "Ted Lasso", "believe@tedlasso.com")
+
+	// THEN only the attendees are patched, again after the conflict, and
+	// Keeley's answer is kept
+	require.Nil(t, err)
+	require.Len(t, patches, 2)
+	for _, patch := range patches {
+		assert.Len(t, patch, 1)
+		assert.Contains(t, patch, "attendees")
+	}
+	require.Len(t, event.Attendees, 3)
+	assert.Equal(t, "roy@kent.com", event.Attendees[0].Email)
+	assert.Equal(t, "keeley@jones.com", event.Attendees[1].Email)
+	assert.Equal(t, "accepted", event.Attendees[1].ResponseStatus)
+	assert.Equal(t, "believe@tedlasso.com", event.Attendees[2].Email)
+}
+
+func TestUpdateCalendarEventDescriptionConflict(t *testing.T) {
+	// GIVEN an event whose guests change on Google's side while its
+	// description is rewritten
+	event := calendar.Event{Id: "01g7d2m9r0", Etag: `"1"`, Summary: "Pizza Friday", Description: pizza.EventDescription, Attendees: []*calendar.EventAttendee{
+		{Email: "roy@kent.com", ResponseStatus: "accepted"},
+	}}
+	var patches []map[string]json.RawMessage
+	ts := conflictingCalendar(t, &event, &patches)
+	defer ts.Close()
+	srv, err := calendar.NewService(context.Background(), option.WithEndpoint(ts.URL+"/"), option.WithHTTPClient(ts.Client()))
+	require.Nil(t, err)
+	pizza.SetCalendar(pizza.NewGoogleCalendar(srv, "pizza"))
+	defer pizza.SetCalendar(nil)
+
+	// WHEN
+	updated, err := pizza.UpdateCalendarEventDescription("01g7d2m9r0", pizza.EventDescription+"\n\nGuests:\n- Roy Kent")
+
+	// THEN only the description is patched, again after the conflict, and
+	// Keeley's answer is kept
+	require.Nil(t, err)
+	require.Len(t, patches, 2)
+	for _, patch := range patches {
+		assert.Len(t, patch, 1)
+		assert.Contains(t, patch, "description")
+	}
+	assert.Equal(t, pizza.EventDescription+"\n\nGuests:\n- Roy Kent", updated.Description)
+	require.Len(t, event.Attendees, 2)
+	assert.Equal(t, "keeley@jones.com", event.Attendees[1].Email)
+}
@@ -0,0 +1,136 @@
+package pizza
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CalendarRateLimit is how many Google Calendar API calls are made a second
+// across every worker, under the project's quota.
+var CalendarRateLimit = 5.0
+
+// CalendarWorkers is how many Google Calendar API calls run at once.
+var CalendarWorkers = 2
+
+// CalendarQueueLimit and CalendarMaxErrorRate are when the calendar is backed
+// up: more calls waiting than the limit, or more than the rate of the last
+// calendarErrorWindow calls failing. RSVPs are then saved with their invite
+// sent later instead of waiting on the calendar.
+var (
+	CalendarQueueLimit   = 50
+	CalendarMaxErrorRate = 0.5
+)
+
+const calendarErrorWindow = 20
+
+var (
+	calendarResultsMu sync.Mutex
+	// calendarResults is a ring of whether each of the last calls failed
+	calendarResults []bool
+	calendarNext    int
+)
+
+// calendarQueueSize is how many calls may wait for a worker before callers
+// block.
+const calendarQueueSize = 1000
+
+type calendarCall struct {
+	do   func() error
+	done chan error
+}
+
+var (
+	calendarCalls chan calendarCall
+	// calendarQueued, calendarMade, and calendarFailed are reported on /metrics
+	calendarQueued atomic.Int64
+	calendarMade   atomic.Int64
+	calendarFailed atomic.Int64
+)
+
+// StartCalendarWorkers sends every Google Calendar API call through workers
+// that together make at most perSecond calls a second, so a burst of invites
+// waits its turn instead of getting the project throttled. Calls made before
+// it is started go straight to the API.
+func StartCalendarWorkers(workers int, perSecond float64) {
+	calls := make(chan calendarCall, calendarQueueSize)
+	tokens := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / perSecond))
+		defer ticker.Stop()
+		for range ticker.C {
+			tokens <- struct{}{}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for call := range calls {
+				<-tokens
+				calendarQueued.Add(-1)
+				call.done <- runCalendarCall(call.do)
+			}
+		}()
+	}
+	calendarCalls = calls
+}
+
+// callCalendar makes a Google Calendar API call through the workers and waits
+// for it to finish.
+func callCalendar(do func() error) error {
+	if calendarCalls == nil {
+		return runCalendarCall(do)
+	}
+	call := calendarCall{do: do, done: make(chan error, 1)}
+	calendarQueued.Add(1)
+	calendarCalls <- call
+	return <-call.done
+}
+
+func runCalendarCall(do func() error) error {
+	calendarMade.Add(1)
+	err := do()
+	if err != nil {
+		calendarFailed.Add(1)
+	}
+	calendarResultsMu.Lock()
+	if len(calendarResults) < calendarErrorWindow {
+		calendarResults = append(calendarResults, err != nil)
+	} else {
+		calendarResults[calendarNext] = err != nil
+		calendarNext = (calendarNext + 1) % calendarErrorWindow
+	}
+	calendarResultsMu.Unlock()
+	return err
+}
+
+// CalendarErrorRate is the share of the last calendarErrorWindow calls that
+// failed.
+func CalendarErrorRate() float64 {
+	calendarResultsMu.Lock()
+	defer calendarResultsMu.Unlock()
+	if len(calendarResults) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, f := range calendarResults {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(calendarResults))
+}
+
+// CalendarBackedUp reports whether calendar calls should wait, because too
+// many are queued or too many recently failed.
+func CalendarBackedUp() bool {
+	return calendarQueued.Load() > int64(CalendarQueueLimit) || CalendarErrorRate() > CalendarMaxErrorRate
+}
+
+func writeCalendarMetrics(w io.Writer) {
+	fmt.Fprintf(w, "# HELP pizza_calendar_queue_depth Calendar API calls waiting for a worker.\n# TYPE pizza_calendar_queue_depth gauge\npizza_calendar_queue_depth %d\n", calendarQueued.Load())
+	fmt.Fprintf(w, "# HELP pizza_calendar_calls_total Calendar API calls made.\n# TYPE pizza_calendar_calls_total counter\npizza_calendar_calls_total %d\n", calendarMade.Load())
+	fmt.Fprintf(w, "# HELP pizza_calendar_error_rate Share of recent calendar API calls that failed.\n# TYPE pizza_calendar_error_rate gauge\npizza_calendar_error_rate %g\n", CalendarErrorRate())
+	fmt.Fprintf(w, "# HELP pizza_calendar_failures_total Calendar API calls that failed.\n# TYPE pizza_calendar_failures_total counter\npizza_calendar_failures_total %d\n", calendarFailed.Load())
+}
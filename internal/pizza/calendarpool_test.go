@@ -0,0 +1,71 @@
+package pizza_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarWorkers(t *testing.T) {
+	// GIVEN
+	pizza.StartCalendarWorkers(3, 50)
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+
+	// WHEN a burst of calls is queued
+	start := time.Now()
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pizza.CallCalendar(func() error {
+				if i == 0 {
+					return errors.New("quota exceeded")
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	// THEN they are spread out to 50 a second however many workers there are
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+	assert.EqualError(t, errs[0], "quota exceeded")
+	for _, err := range errs[1:] {
+		assert.Nil(t, err)
+	}
+	w := httptest.NewRecorder()
+	pizza.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "pizza_calendar_queue_depth 0\n")
+	assert.Contains(t, w.Body.String(), "pizza_calendar_calls_total 10\n")
+	assert.Contains(t, w.Body.String(), "pizza_calendar_failures_total 1\n")
+}
+
+func TestCalendarBackedUp(t *testing.T) {
+	// GIVEN
+	pizza.CalendarMaxErrorRate = 0.5
+
+	// WHEN the calendar starts failing
+	for i := 0; i < 20; i++ {
+		pizza.CallCalendar(func() error { return errors.New("quota exceeded") })
+	}
+
+	// THEN
+	assert.Equal(t, 1.0, pizza.CalendarErrorRate())
+	assert.True(t, pizza.CalendarBackedUp())
+
+	// WHEN it recovers
+	for i := 0; i < 15; i++ {
+		pizza.CallCalendar(func() error { return nil })
+	}
+
+	// THEN
+	assert.Equal(t, 0.25, pizza.CalendarErrorRate())
+	assert.False(t, pizza.CalendarBackedUp())
+}
@@ -0,0 +1,83 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type CancelPageData struct {
+	ID   string
+	Sig  string
+	Date string
+	// Confirm is set when the friend has to enter their email to cancel, as
+	// cancel links are forwarded like edit links
+	Confirm   bool
+	Hint      string
+	Cancelled bool
+	Closed    bool
+}
+
+// HandleCancel lets a friend back out of a Friday from the link on their edit
+// page. Opening the link only asks them to confirm, so link previews don't
+// cancel anything.
+func HandleCancel(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/cancel.html")
+	if err != nil {
+		Log.Error("template cancel failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := CancelPageData{ID: r.Form.Get("rsvp"), Sig: r.Form.Get("sig")}
+
+	rsvp, err := GetFridayRSVP(data.ID)
+	if err != nil {
+		Log.Error("failed to get rsvp", zap.Error(err), zap.String("id", data.ID))
+		Handle500(w, r)
+		return
+	} else if rsvp == nil {
+		// cancelled already, e.g. by pressing the button twice
+		data.Cancelled = true
+	} else if !VerifyLink(data.Sig, "rsvp", rsvp.ID, rsvp.Email) {
+		Handle4xx(w, r)
+		return
+	} else {
+		if friday, err := GetFriday(rsvp.FridayID); err == nil && friday != nil {
+			data.Date = FormatTime(friday.Start)
+		}
+		email := rsvp.Email
+		if friendFromCookie(r) != rsvp.Email {
+			data.Confirm = true
+			data.Hint = MaskEmail(rsvp.Email)
+			email = strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+		}
+		if r.Method == http.MethodPost {
+			_, err = CancelRSVP(rsvp.ID, email, data.Sig)
+			if err == ErrRSVPNotOwner {
+				Log.Debug("cancel link used by someone else", zap.String("id", rsvp.ID))
+				Handle4xx(w, r)
+				return
+			} else if err == ErrRSVPClosed {
+				data.Closed = true
+			} else if err != nil {
+				Log.Error("failed to cancel rsvp", zap.Error(err), zap.String("id", rsvp.ID))
+				Handle500(w, r)
+				return
+			} else {
+				Log.Info("rsvp cancelled", zap.String("eventID", rsvp.FridayID), zap.String("email", rsvp.Email))
+				data.Cancelled = true
+			}
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,189 @@
+package pizza
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Captcha checks that a form was sent by a person.
+type Captcha interface {
+	// Widget is what a form needs to show the challenge
+	Widget() CaptchaWidget
+	// Verify checks the response the widget added to the form
+	Verify(form url.Values, remoteIP string) (bool, error)
+}
+
+type CaptchaWidget struct {
+	Script  string
+	Class   string
+	SiteKey string
+}
+
+// SiteVerifyCaptcha is a provider that verifies responses by posting them to
+// a siteverify endpoint, like hCaptcha and Cloudflare Turnstile.
+type SiteVerifyCaptcha struct {
+	VerifyURL string
+	Field     string
+	Secret    string
+	widget    CaptchaWidget
+	client    *http.Client
+}
+
+func NewHCaptcha(siteKey, secret string) *SiteVerifyCaptcha {
+	return &SiteVerifyCaptcha{
+		VerifyURL: "https://api.hcaptcha.com/siteverify",
+		Field:     "h-captcha-response",
+		Secret:    secret,
+		widget:    CaptchaWidget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", SiteKey: siteKey},
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func NewTurnstile(siteKey, secret string) *SiteVerifyCaptcha {
+	return &SiteVerifyCaptcha{
+		VerifyURL: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		Field:     "cf-turnstile-response",
+		Secret:    secret,
+		widget:    CaptchaWidget{Script: "https://challenges.cloudflare.com/turnstile/v0/api.js", Class: "cf-turnstile", SiteKey: siteKey},
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *SiteVerifyCaptcha) Widget() CaptchaWidget {
+	return c.widget
+}
+
+func (c *SiteVerifyCaptcha) Verify(form url.Values, remoteIP string) (bool, error) {
+	response := form.Get(c.Field)
+	if len(response) == 0 {
+		return false, nil
+	}
+	res, err := c.client.PostForm(c.VerifyURL, url.Values{
+		"secret":   {c.Secret},
+		"response": {response},
+		"remoteip": {remoteIP},
+	})
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	var body struct {
+		Success bool `json:"success"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return false, err
+	}
+	return body.Success, nil
+}
+
+const (
+	CaptchaAlways = "always"
+	CaptchaAuto   = "auto"
+)
+
+var (
+	captcha Captcha
+	// CaptchaMode is CaptchaAlways to always show the captcha on public forms,
+	// or CaptchaAuto to only show it while the forms are under attack
+	CaptchaMode = CaptchaAuto
+	// CaptchaAttackRate is the number of public form submissions in a minute
+	// that turns on the captcha in auto mode
+	CaptchaAttackRate = 30
+	// CaptchaAttackCooldown is how long the captcha stays on after an attack
+	CaptchaAttackCooldown = 15 * time.Minute
+)
+
+// attack counts public form submissions to detect floods of them.
+var attack struct {
+	sync.Mutex
+	minute time.Time
+	count  int
+	until  time.Time
+}
+
+func recordSubmission() {
+	attack.Lock()
+	defer attack.Unlock()
+	now := time.Now()
+	if minute := now.Truncate(time.Minute); minute != attack.minute {
+		attack.minute, attack.count = minute, 0
+	}
+	attack.count++
+	if attack.count > CaptchaAttackRate {
+		if now.After(attack.until) {
+			Log.Warn("public forms under attack, turning on captcha", zap.Int("count", attack.count))
+		}
+		attack.until = now.Add(CaptchaAttackCooldown)
+	}
+}
+
+// CaptchaWidgetFor is the widget for a public form, or nil when no captcha is
+// needed.
+func CaptchaWidgetFor() *CaptchaWidget {
+	if !captchaRequired() {
+		return nil
+	}
+	widget := captcha.Widget()
+	return &widget
+}
+
+func captchaRequired() bool {
+	if captcha == nil {
+		return false
+	}
+	if CaptchaMode == CaptchaAlways {
+		return true
+	}
+	attack.Lock()
+	defer attack.Unlock()
+	return time.Now().Before(attack.until)
+}
+
+// CheckCaptcha counts the submission of a public form and verifies its
+// captcha when one is needed.
+func CheckCaptcha(r *http.Request, form url.Values) bool {
+	required := captchaRequired()
+	recordSubmission()
+	if !required {
+		return true
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ok, err := captcha.Verify(form, ip)
+	if err != nil {
+		Log.Error("captcha verify error", zap.Error(err))
+	}
+	return ok
+}
+
+// initCaptcha sets up the configured captcha provider.
+func initCaptcha(config CaptchaConfig) {
+	switch config.Provider {
+	case "hcaptcha":
+		captcha = NewHCaptcha(config.SiteKey, config.Secret)
+	case "turnstile":
+		captcha = NewTurnstile(config.SiteKey, config.Secret)
+	default:
+		captcha = nil
+		return
+	}
+	if len(config.Mode) > 0 {
+		CaptchaMode = config.Mode
+	}
+	if config.AttackRate > 0 {
+		CaptchaAttackRate = config.AttackRate
+	}
+}
+
+// SetCaptcha replaces the captcha provider.
+func SetCaptcha(c Captcha) {
+	captcha = c
+}
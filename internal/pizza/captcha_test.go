@@ -0,0 +1,77 @@
+package pizza_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteVerifyCaptcha(t *testing.T) {
+	// GIVEN
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "shh", r.PostForm.Get("secret"))
+		assert.Equal(t, "10.0.0.1", r.PostForm.Get("remoteip"))
+		json.NewEncoder(w).Encode(map[string]bool{"success": r.PostForm.Get("response") == "human"})
+	}))
+	defer ts.Close()
+	captcha := pizza.NewTurnstile("site", "shh")
+	captcha.VerifyURL = ts.URL
+
+	// WHEN
+	human, humanErr := captcha.Verify(url.Values{"cf-turnstile-response": {"human"}}, "10.0.0.1")
+	bot, botErr := captcha.Verify(url.Values{"cf-turnstile-response": {"bot"}}, "10.0.0.1")
+	missing, missingErr := captcha.Verify(url.Values{}, "10.0.0.1")
+
+	// THEN
+	assert.NoError(t, humanErr)
+	assert.True(t, human)
+	assert.NoError(t, botErr)
+	assert.False(t, bot)
+	assert.NoError(t, missingErr)
+	assert.False(t, missing)
+	assert.Equal(t, "cf-turnstile", captcha.Widget().Class)
+}
+
+type fakeCaptcha struct{}
+
+func (fakeCaptcha) Widget() pizza.CaptchaWidget {
+	return pizza.CaptchaWidget{Class: "fake"}
+}
+
+func (fakeCaptcha) Verify(form url.Values, remoteIP string) (bool, error) {
+	return form.Get("captcha") == "ok", nil
+}
+
+func TestCheckCaptchaAuto(t *testing.T) {
+	// GIVEN
+	pizza.SetCaptcha(fakeCaptcha{})
+	pizza.CaptchaAttackRate = 2
+	defer func() {
+		pizza.SetCaptcha(nil)
+		pizza.CaptchaAttackRate = 30
+	}()
+	req := httptest.NewRequest(http.MethodPost, "/notify", nil)
+
+	// WHEN
+	widgetBefore := pizza.CaptchaWidgetFor()
+	for i := 0; i < 3; i++ {
+		assert.True(t, pizza.CheckCaptcha(req, url.Values{}))
+	}
+	widgetAfter := pizza.CaptchaWidgetFor()
+	failed := pizza.CheckCaptcha(req, url.Values{})
+	passed := pizza.CheckCaptcha(req, url.Values{"captcha": {"ok"}})
+
+	// THEN
+	assert.Nil(t, widgetBefore)
+	require.NotNil(t, widgetAfter)
+	assert.Equal(t, "fake", widgetAfter.Class)
+	assert.False(t, failed)
+	assert.True(t, passed)
+}
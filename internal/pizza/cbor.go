@@ -0,0 +1,105 @@
+package pizza
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var ErrCBOR = errors.New("malformed cbor")
+
+// decodeCBOR reads one CBOR item, just enough of the format for WebAuthn
+// attestations and COSE keys. Integers are int64, maps are map[interface{}],
+// and it returns the bytes after the item.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORDepth(data, 0)
+}
+
+func decodeCBORDepth(data []byte, depth int) (interface{}, []byte, error) {
+	if len(data) == 0 || depth > 16 {
+		return nil, nil, ErrCBOR
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, ErrCBOR
+		}
+		var buf [8]byte
+		copy(buf[8-size:], data[:size])
+		arg = binary.BigEndian.Uint64(buf[:])
+		data = data[size:]
+	default:
+		// indefinite lengths aren't used by authenticators
+		return nil, nil, ErrCBOR
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBOR
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if uint64(len(data)) < arg {
+			return nil, nil, ErrCBOR
+		}
+		if major == 2 {
+			return data[:arg], data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBOR
+		}
+		items := make([]interface{}, arg)
+		var err error
+		for i := range items {
+			if items[i], data, err = decodeCBORDepth(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBOR
+		}
+		items := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, rest, err := decodeCBORDepth(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, ok := key.([]byte); ok {
+				return nil, nil, ErrCBOR
+			}
+			if items[key], data, err = decodeCBORDepth(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, data, nil
+	case 6:
+		// tags are ignored
+		return decodeCBORDepth(data, depth+1)
+	default:
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+		return nil, nil, ErrCBOR
+	}
+}
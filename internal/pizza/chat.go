@@ -0,0 +1,121 @@
+package pizza
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ChatWebhook posts to a Slack or Discord channel through an incoming
+// webhook, to give the host a heads-up when friends RSVP or cancel.
+type ChatWebhook struct {
+	URL string
+	// Discord webhooks take the message as content, Slack's as text
+	Discord bool
+	client  *http.Client
+}
+
+var chat *ChatWebhook
+
+func NewChatWebhook(config ChatConfig) *ChatWebhook {
+	discord := config.Kind == "discord"
+	if len(config.Kind) == 0 {
+		discord = strings.Contains(config.WebhookURL, "discord.com/") || strings.Contains(config.WebhookURL, "discordapp.com/")
+	}
+	return &ChatWebhook{
+		URL:     config.WebhookURL,
+		Discord: discord,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// InitChat sets up posting to the chat. It stays off when no webhook is
+// configured.
+func InitChat(config ChatConfig) {
+	if len(config.WebhookURL) == 0 {
+		chat = nil
+		return
+	}
+	chat = NewChatWebhook(config)
+}
+
+// SetChat replaces the chat webhook, e.g. with one pointed at a test server.
+func SetChat(c *ChatWebhook) {
+	chat = c
+}
+
+// Post sends the message to the channel. Names in it can't mention anyone.
+func (c *ChatWebhook) Post(text string) error {
+	var payload any
+	if c.Discord {
+		payload = map[string]any{"content": text, "allowed_mentions": map[string][]string{"parse": {}}}
+	} else {
+		payload = map[string]string{"text": strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Post(c.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("chat webhook status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// ChatRSVPMessage is what the chat is told about the friend's RSVP being
+// created or deleted.
+func ChatRSVPMessage(changeType, name string, rsvp RSVP, friday Friday, headcount int) string {
+	estZone, _ := time.LoadLocation("America/New_York")
+	date := friday.Start.In(estZone).Format("Mon Jan 2")
+	switch {
+	case changeType == ChangeRSVPDeleted:
+		return fmt.Sprintf("%s cancelled for %s. %d going.", name, date, headcount)
+	case rsvp.Status == RSVPStatusPending:
+		return fmt.Sprintf("%s asked to come to %s, waiting for a spot. %d going.", name, date, headcount)
+	}
+	party := ""
+	if rsvp.PlusOnes > 0 {
+		party += fmt.Sprintf(" +%d", rsvp.PlusOnes)
+	}
+	if rsvp.Kids == 1 {
+		party += " with 1 kid"
+	} else if rsvp.Kids > 1 {
+		party += fmt.Sprintf(" with %d kids", rsvp.Kids)
+	}
+	return fmt.Sprintf("%s%s is coming to %s. %d going.", name, party, date, headcount)
+}
+
+// notifyChat tells the chat about the RSVP, logging failures since the RSVP
+// is already saved. Only upcoming Fridays are posted about, which leaves out
+// the probe's.
+func notifyChat(changeType string, rsvp RSVP) {
+	if chat == nil {
+		return
+	}
+	friday, ok, err := GetCachedFriday(UpcomingDays, rsvp.FridayID)
+	if err != nil || !ok {
+		return
+	}
+	rsvps, err := ListFridayRSVPs(rsvp.FridayID)
+	if err != nil {
+		Log.Warn("failed to list rsvps for chat", zap.Error(err), zap.String("eventID", rsvp.FridayID))
+		return
+	}
+	name, err := GetCachedFriendName(rsvp.Email)
+	if err != nil || len(name) == 0 {
+		name = rsvp.Email
+	}
+	if err = chat.Post(ChatRSVPMessage(changeType, name, rsvp, friday, Headcount(rsvps))); err != nil {
+		Log.Warn("failed to post to chat", zap.Error(err), zap.String("eventID", rsvp.FridayID))
+	}
+}
@@ -0,0 +1,57 @@
+package pizza_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatRSVPMessage(t *testing.T) {
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)}
+
+	assert.Equal(t, "Ted Lasso +2 with 1 kid is coming to Fri Apr 7. 6 going.",
+		pizza.ChatRSVPMessage(pizza.ChangeRSVPCreated, "Ted Lasso", pizza.RSVP{PlusOnes: 2, Kids: 1, Status: pizza.RSVPStatusConfirmed}, friday, 6))
+	assert.Equal(t, "Roy Kent asked to come to Fri Apr 7, waiting for a spot. 6 going.",
+		pizza.ChatRSVPMessage(pizza.ChangeRSVPCreated, "Roy Kent", pizza.RSVP{Status: pizza.RSVPStatusPending}, friday, 6))
+	assert.Equal(t, "Ted Lasso cancelled for Fri Apr 7. 2 going.",
+		pizza.ChatRSVPMessage(pizza.ChangeRSVPDeleted, "Ted Lasso", pizza.RSVP{PlusOnes: 2, Kids: 1}, friday, 2))
+}
+
+func TestChatWebhookPost(t *testing.T) {
+	// GIVEN
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	slack := pizza.NewChatWebhook(pizza.ChatConfig{WebhookURL: server.URL})
+	discord := pizza.NewChatWebhook(pizza.ChatConfig{WebhookURL: server.URL, Kind: "discord"})
+
+	// WHEN
+	err := slack.Post("<!channel> Ted & Roy")
+
+	// THEN the name can't ping the channel
+	assert.NoError(t, err)
+	assert.Equal(t, "&lt;!channel&gt; Ted &amp; Roy", payload["text"])
+
+	// WHEN
+	err = discord.Post("@everyone Ted & Roy")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "@everyone Ted & Roy", payload["content"])
+	assert.Equal(t, map[string]any{"parse": []any{}}, payload["allowed_mentions"])
+}
+
+func TestNewChatWebhook(t *testing.T) {
+	assert.True(t, pizza.NewChatWebhook(pizza.ChatConfig{WebhookURL: "https://discord.com/api/webhooks/1/abc"}).Discord)
+	assert.False(t, pizza.NewChatWebhook(pizza.ChatConfig{WebhookURL: "https://hooks.slack.com/services/T0/B0/abc"}).Discord)
+	assert.False(t, pizza.NewChatWebhook(pizza.ChatConfig{WebhookURL: "https://discord.com/api/webhooks/1/abc", Kind: "slack"}).Discord)
+}
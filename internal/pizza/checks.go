@@ -0,0 +1,132 @@
+package pizza
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	f "github.com/fauna/faunadb-go/v4/faunadb"
+)
+
+// StartupReport lists everything found wrong when the server starts, so they
+// can all be fixed at once.
+type StartupReport struct {
+	Problems []string
+}
+
+func (r *StartupReport) Error() string {
+	return "startup checks failed:\n  - " + strings.Join(r.Problems, "\n  - ")
+}
+
+// RunStartupChecks makes sure the static files, templates, database, and
+// calendar are all usable before the server takes requests.
+func RunStartupChecks() error {
+	report := &StartupReport{}
+	report.Problems = append(report.Problems, CheckStaticDir(StaticDir)...)
+	if len(report.Problems) == 0 {
+		report.Problems = append(report.Problems, CheckTemplates(StaticDir)...)
+	}
+	report.Problems = append(report.Problems, CheckDatabase()...)
+	if cal != nil {
+		report.Problems = append(report.Problems, CheckCalendar()...)
+	}
+	if len(report.Problems) > 0 {
+		return report
+	}
+	return nil
+}
+
+// CheckStaticDir makes sure the static directory has the templates and assets.
+// The built in one, when dir is empty, always does.
+func CheckStaticDir(dir string) []string {
+	var problems []string
+	for _, sub := range []string{"html", "email", "css", "js"} {
+		if info, err := fs.Stat(staticFS(dir), sub); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("static directory %s has no %s/, set PIZZA_STATIC_DIR to the static directory of the release", dir, sub))
+		}
+	}
+	return problems
+}
+
+// CheckTemplates parses every page and email template.
+func CheckTemplates(dir string) []string {
+	var problems []string
+	fsys := staticFS(dir)
+	fs.WalkDir(fsys, "html", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return err
+		}
+		if _, err := template.New(path.Base(p)).Funcs(templateFuncs).ParseFS(fsys, p); err != nil {
+			problems = append(problems, fmt.Sprintf("template %s doesn't parse: %v", p, err))
+		}
+		return nil
+	})
+	emails, _ := fs.Glob(fsys, "email/*.txt")
+	for _, p := range emails {
+		plate, err := texttemplate.ParseFS(fsys, p)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("email %s doesn't parse: %v", path.Base(p), err))
+		} else if plate.Lookup("subject") == nil {
+			problems = append(problems, fmt.Sprintf("email %s has no {{define \"subject\"}}", path.Base(p)))
+		}
+	}
+	return problems
+}
+
+// CheckDatabase makes sure the store can be reached, and for Fauna, that
+// every collection and index the server queries exists. SQLStore creates its
+// tables when it is opened.
+func CheckDatabase() []string {
+	if _, ok := store.(FaunaStore); ok {
+		return CheckFaunaSchema()
+	}
+	if err := store.Ping(); err != nil {
+		return []string{fmt.Sprintf("can't reach the database, check database.dsn: %v", err)}
+	}
+	return nil
+}
+
+// CheckFaunaSchema makes sure every collection and index the server queries
+// exists.
+func CheckFaunaSchema() []string {
+	var names []string
+	checks := f.Arr{}
+	for _, name := range FaunaCollections {
+		names = append(names, "collection "+name)
+		checks = append(checks, f.Exists(f.Collection(name)))
+	}
+	for _, index := range FaunaIndexes {
+		names = append(names, "index "+index.Name)
+		checks = append(checks, f.Exists(f.Index(index.Name)))
+	}
+	qRes, err := faunaClient.Query(checks)
+	if err != nil {
+		return []string{fmt.Sprintf("can't reach Fauna, check FAUNADB_SECRET: %v", err)}
+	}
+	var exists []bool
+	if err = qRes.Get(&exists); err != nil || len(exists) != len(names) {
+		return []string{fmt.Sprintf("unexpected answer from Fauna: %v", err)}
+	}
+	var problems []string
+	for i, ok := range exists {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing, run pizzactl bootstrap", names[i]))
+		}
+	}
+	return problems
+}
+
+// CheckCalendar makes sure the calendar can be read with the saved token.
+func CheckCalendar() []string {
+	if _, err := ListEvents(1); err != nil {
+		fix := "check calendar.caldav's URL and password"
+		if _, ok := cal.(*GoogleCalendar); ok {
+			fix = "renew the token with cmd/renew_calendar_credentials.go"
+		}
+		return []string{fmt.Sprintf("calendar %s isn't reachable, %s: %v", cal, fix, err)}
+	}
+	return nil
+}
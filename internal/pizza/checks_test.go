@@ -0,0 +1,40 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTemplates(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+
+	// THEN
+	assert.Empty(t, pizza.CheckStaticDir(pizza.StaticDir))
+	assert.Empty(t, pizza.CheckTemplates(pizza.StaticDir))
+}
+
+func TestCheckTemplatesBroken(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	for _, sub := range []string{"html", "email"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "html", "index.html"), []byte("{{if .FridayTimes}}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "email", "digest.txt"), []byte("no subject"), 0o644))
+
+	// WHEN
+	missing := pizza.CheckStaticDir(dir)
+	broken := pizza.CheckTemplates(dir)
+
+	// THEN
+	assert.Len(t, missing, 2)
+	assert.Len(t, broken, 2)
+	assert.Contains(t, broken[0], "index.html")
+	assert.Contains(t, broken[1], "digest.txt")
+}
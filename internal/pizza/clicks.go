@@ -0,0 +1,186 @@
+package pizza
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	EmailSent    = "sent"
+	EmailClicked = "clicked"
+)
+
+// AnalyticsDays is how far back the analytics page looks.
+var AnalyticsDays = 90
+
+// TrackedURL wraps a link to the site in an email to the friend, so following
+// it records a click before redirecting. kind is the name of the email.
+func TrackedURL(email, kind, link string) string {
+	q := url.Values{}
+	q.Set("to", link)
+	q.Set("email", email)
+	q.Set("kind", kind)
+	q.Set("sig", SignLink("click", email, kind, link))
+	return BaseURL + "/click?" + q.Encode()
+}
+
+// trackEmail wraps the email's links with TrackedURL and records that it was
+// sent, unless the friend opted out of tracking. Links off the site are left
+// alone.
+func trackEmail(email, kind string, links ...*string) {
+	if noTracking, err := GetFriendNoTracking(email); err != nil || noTracking {
+		return
+	}
+	for _, link := range links {
+		if strings.HasPrefix(*link, BaseURL+"/") {
+			*link = TrackedURL(email, kind, *link)
+		}
+	}
+	if err := RecordEmailEvent(email, kind, EmailSent); err != nil {
+		Log.Warn("failed to record email", zap.Error(err), zap.String("kind", kind))
+	}
+}
+
+// HandleClick records a click on a tracked link and redirects to it. Link
+// preview bots and mail scanners are redirected without counting.
+func HandleClick(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to, email, kind := q.Get("to"), q.Get("email"), q.Get("kind")
+	if !VerifyLink(q.Get("sig"), "click", email, kind, to) || !strings.HasPrefix(to, BaseURL+"/") {
+		Handle4xx(w, r)
+		return
+	}
+	if !IsPreviewBot(r.UserAgent()) {
+		if err := RecordEmailEvent(email, kind, EmailClicked); err != nil {
+			Log.Warn("failed to record click", zap.Error(err), zap.String("kind", kind))
+		}
+	}
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
+
+// EmailKindStats sums up one kind of email.
+type EmailKindStats struct {
+	Kind   string
+	Sent   int
+	Clicks int
+	// Clickers are the friends who clicked a link in the email at least once
+	Clickers int
+}
+
+// FriendEmailStats sums up the tracked emails sent to one friend.
+type FriendEmailStats struct {
+	Email     string
+	Sent      int
+	Clicks    int
+	LastClick time.Time
+}
+
+// SummarizeEmailEvents adds up the email events by kind of email, and by
+// friend with the most recent clickers first.
+func SummarizeEmailEvents(events []EmailEvent) ([]EmailKindStats, []FriendEmailStats) {
+	kinds := map[string]*EmailKindStats{}
+	friends := map[string]*FriendEmailStats{}
+	clickers := map[string]bool{}
+	for _, event := range events {
+		kind, ok := kinds[event.Kind]
+		if !ok {
+			kind = &EmailKindStats{Kind: event.Kind}
+			kinds[event.Kind] = kind
+		}
+		friend, ok := friends[event.Email]
+		if !ok {
+			friend = &FriendEmailStats{Email: event.Email}
+			friends[event.Email] = friend
+		}
+		switch event.Event {
+		case EmailSent:
+			kind.Sent++
+			friend.Sent++
+		case EmailClicked:
+			kind.Clicks++
+			friend.Clicks++
+			if event.At.After(friend.LastClick) {
+				friend.LastClick = event.At
+			}
+			if key := event.Kind + "\x00" + event.Email; !clickers[key] {
+				clickers[key] = true
+				kind.Clickers++
+			}
+		}
+	}
+
+	byKind := make([]EmailKindStats, 0, len(kinds))
+	for _, kind := range kinds {
+		byKind = append(byKind, *kind)
+	}
+	sort.Slice(byKind, func(i, j int) bool { return byKind[i].Kind < byKind[j].Kind })
+	byFriend := make([]FriendEmailStats, 0, len(friends))
+	for _, friend := range friends {
+		byFriend = append(byFriend, *friend)
+	}
+	sort.Slice(byFriend, func(i, j int) bool {
+		if !byFriend[i].LastClick.Equal(byFriend[j].LastClick) {
+			return byFriend[i].LastClick.After(byFriend[j].LastClick)
+		}
+		return byFriend[i].Email < byFriend[j].Email
+	})
+	return byKind, byFriend
+}
+
+type AdminAnalyticsFriendData struct {
+	Name      string
+	Email     string
+	Sent      int
+	Clicks    int
+	LastClick string
+}
+
+type AdminAnalyticsPageData struct {
+	Days    int
+	Kinds   []EmailKindStats
+	Friends []AdminAnalyticsFriendData
+	// Experiment compares the cohorts getting the digest at different hours
+	Experiment []DigestCohortStats
+}
+
+// HandleAdminAnalytics shows how often friends click the links in the emails
+// they are sent, and how each cohort of the digest timing experiment RSVPs.
+func HandleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/analytics.html")
+	if err != nil {
+		Log.Error("template admin analytics failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	events, err := ListEmailEvents(time.Now().AddDate(0, 0, -AnalyticsDays))
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminAnalyticsPageData{Days: AnalyticsDays}
+	if data.Experiment, err = digestExperiment(time.Now().AddDate(0, 0, -AnalyticsDays)); err != nil {
+		Handle500(w, r)
+		return
+	}
+	var friends []FriendEmailStats
+	data.Kinds, friends = SummarizeEmailEvents(events)
+	for _, friend := range friends {
+		row := AdminAnalyticsFriendData{Email: friend.Email, Sent: friend.Sent, Clicks: friend.Clicks}
+		row.Name, _ = GetCachedFriendName(friend.Email)
+		if !friend.LastClick.IsZero() {
+			row.LastClick = FormatTime(friend.LastClick)
+		}
+		data.Friends = append(data.Friends, row)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
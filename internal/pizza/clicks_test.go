@@ -0,0 +1,68 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackedURL(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	defer func(base string) { pizza.BaseURL = base }(pizza.BaseURL)
+	pizza.BaseURL = "https://rsvp.pizza"
+	link := pizza.TrackedURL("believe@tedlasso.com", "digest", "https://rsvp.pizza/?invite=x")
+	tampered := pizza.TrackedURL("believe@tedlasso.com", "digest", "https://rsvp.pizza/?invite=x")
+	tampered = tampered[:len(tampered)-1] + "A"
+	offsite := "/click?" + url.Values{
+		"to": {"https://evil.example"}, "email": {"believe@tedlasso.com"}, "kind": {"digest"},
+		"sig": {pizza.SignLink("click", "believe@tedlasso.com", "digest", "https://evil.example")},
+	}.Encode()
+
+	// WHEN a preview bot follows the link, so nothing is recorded
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, link, nil)
+	r.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0")
+	pizza.HandleClick(w, r)
+	wTampered := httptest.NewRecorder()
+	pizza.HandleClick(wTampered, httptest.NewRequest(http.MethodGet, tampered, nil))
+	wOffsite := httptest.NewRecorder()
+	pizza.HandleClick(wOffsite, httptest.NewRequest(http.MethodGet, offsite, nil))
+
+	// THEN only signed links to the site redirect
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://rsvp.pizza/?invite=x", w.Header().Get("Location"))
+	assert.NotEqual(t, http.StatusSeeOther, wTampered.Code)
+	assert.NotEqual(t, http.StatusSeeOther, wOffsite.Code)
+}
+
+func TestSummarizeEmailEvents(t *testing.T) {
+	// GIVEN
+	at := time.Date(2023, 4, 7, 12, 0, 0, 0, time.UTC)
+	events := []pizza.EmailEvent{
+		{Email: "believe@tedlasso.com", Kind: "digest", Event: pizza.EmailSent},
+		{Email: "roy@kent.com", Kind: "digest", Event: pizza.EmailSent},
+		{Email: "believe@tedlasso.com", Kind: "digest", Event: pizza.EmailClicked, At: at},
+		{Email: "believe@tedlasso.com", Kind: "digest", Event: pizza.EmailClicked, At: at.Add(time.Hour)},
+		{Email: "roy@kent.com", Kind: "spot", Event: pizza.EmailSent},
+	}
+
+	// WHEN
+	kinds, friends := pizza.SummarizeEmailEvents(events)
+
+	// THEN
+	assert.Equal(t, []pizza.EmailKindStats{
+		{Kind: "digest", Sent: 2, Clicks: 2, Clickers: 1},
+		{Kind: "spot", Sent: 1},
+	}, kinds)
+	assert.Equal(t, []pizza.FriendEmailStats{
+		{Email: "believe@tedlasso.com", Sent: 1, Clicks: 2, LastClick: at.Add(time.Hour)},
+		{Email: "roy@kent.com", Sent: 2},
+	}, friends)
+}
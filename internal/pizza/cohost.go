@@ -0,0 +1,220 @@
+package pizza
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// CohostGrace is how long after the event ends a co-host's link keeps
+// working, to check in stragglers and announce leftovers.
+var CohostGrace = 12 * time.Hour
+
+// CohostURL is the co-host's link to help host the event, which works until
+// CohostGrace after it ends.
+func CohostURL(friday Friday, email string) string {
+	exp := LinkExpiry(friday.EndTime().Add(CohostGrace))
+	q := url.Values{}
+	q.Set("email", email)
+	q.Set("expires", exp)
+	q.Set("sig", SignLink("cohost", friday.ID(), email, exp))
+	return BaseURL + "/cohost/" + friday.ID() + "?" + q.Encode()
+}
+
+type CohostEmailData struct {
+	Date      string
+	CohostURL string
+	Expires   string
+}
+
+func sendCohostInvite(friday Friday, email string) {
+	msg, err := RenderEmail("cohost", CohostEmailData{
+		Date:      FormatTime(friday.Start),
+		CohostURL: CohostURL(friday, email),
+		Expires:   FormatTime(friday.EndTime().Add(CohostGrace)),
+	})
+	if err != nil {
+		Log.Error("cohost template failure", zap.Error(err))
+		return
+	}
+	msg.To = email
+	if err = SendEmail(msg); err != nil {
+		Log.Warn("failed to send cohost invite", zap.Error(err), zap.String("email", email))
+	}
+}
+
+// HandleAdminCohosts adds a co-host to the event, emailing them their link,
+// or removes one, which stops their link working.
+func HandleAdminCohosts(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+	if !strings.Contains(email, "@") {
+		Handle4xx(w, r)
+		return
+	}
+	cohosts := []string{}
+	for _, cohost := range friday.Cohosts {
+		if cohost != email {
+			cohosts = append(cohosts, cohost)
+		}
+	}
+	add := r.PostForm.Get("action") == "add"
+	if add {
+		cohosts = append(cohosts, email)
+	}
+	if err = SetFridayCohosts(id, cohosts); err != nil {
+		Handle500(w, r)
+		return
+	}
+	fridayCache.Clear()
+	if add {
+		go sendCohostInvite(*friday, email)
+	}
+	Log.Info("cohosts changed", zap.String("id", id), zap.String("email", email), zap.Bool("added", add))
+	http.Redirect(w, r, "/admin/fridays/"+id+"/guests", http.StatusSeeOther)
+}
+
+type CohostGuestData struct {
+	ID        string
+	Name      string
+	Email     string
+	PlusOnes  int
+	Kids      int
+	CheckedIn bool
+	// Arrival is when they said they'd get there, and Late is set when they
+	// said they're running late
+	Arrival string
+	Late    bool
+	// Note is the host's note about the friend, like an allergy
+	Note string
+}
+
+type CohostPageData struct {
+	FridayID  string
+	Email     string
+	Expires   string
+	Sig       string
+	Date      string
+	Headcount int
+	// Arrived counts the guests checked in, with their plus ones and kids
+	Arrived      int
+	Guests       []CohostGuestData
+	Announcement string
+}
+
+// HandleCohost lets a co-host help with one event until their link expires:
+// see who's coming with the host's notes about them, check guests in, and
+// change the announcement. The rest of the admin pages stay private.
+func HandleCohost(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/cohost.html")
+	if err != nil {
+		Log.Error("template cohost failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	data := CohostPageData{
+		FridayID: id,
+		Email:    strings.ToLower(r.Form.Get("email")),
+		Expires:  r.Form.Get("expires"),
+		Sig:      r.Form.Get("sig"),
+	}
+	if !VerifyLink(data.Sig, "cohost", id, data.Email, data.Expires) || LinkExpired(data.Expires, time.Now()) {
+		Handle4xx(w, r)
+		return
+	}
+	friday, err := GetFriday(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	} else if friday == nil || !containsString(friday.Cohosts, data.Email) {
+		// the host removed them
+		Handle4xx(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		switch r.PostForm.Get("action") {
+		case "checkin":
+			rsvp, err := GetFridayRSVP(r.PostForm.Get("rsvp"))
+			if err != nil {
+				Handle500(w, r)
+				return
+			} else if rsvp == nil || rsvp.FridayID != id {
+				Handle4xx(w, r)
+				return
+			}
+			if err = SetRSVPCheckedIn(rsvp.ID, r.PostForm.Get("checked") == "on"); err != nil {
+				Handle500(w, r)
+				return
+			}
+		case "announce":
+			if err = SetFridayAnnouncement(id, strings.TrimSpace(r.PostForm.Get("announcement"))); err != nil {
+				Handle500(w, r)
+				return
+			}
+			fridayCache.Clear()
+			apiFridaysCache.Clear()
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		Log.Info("cohost action", zap.String("id", id), zap.String("email", data.Email), zap.String("action", r.PostForm.Get("action")))
+		q := url.Values{"email": {data.Email}, "expires": {data.Expires}, "sig": {data.Sig}}
+		http.Redirect(w, r, "/cohost/"+id+"?"+q.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	confirmed := ConfirmedRSVPs(rsvps)
+	data.Date = FormatTime(friday.Start)
+	data.Headcount = Headcount(confirmed)
+	data.Announcement = friday.Announcement
+	for _, rsvp := range confirmed {
+		guest := CohostGuestData{ID: rsvp.ID, Email: rsvp.Email, PlusOnes: rsvp.PlusOnes, Kids: rsvp.Kids, CheckedIn: rsvp.CheckedIn,
+			Arrival: FormatArrival(rsvp.Arrival), Late: rsvp.Late}
+		guest.Name, _ = GetCachedFriendName(rsvp.Email)
+		if guest.Note, err = GetFriendNote(rsvp.Email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		if rsvp.CheckedIn {
+			data.Arrived += rsvp.Guests()
+		}
+		data.Guests = append(data.Guests, guest)
+	}
+	sort.Slice(data.Guests, func(i, j int) bool {
+		return strings.ToLower(data.Guests[i].Name+data.Guests[i].Email) < strings.ToLower(data.Guests[j].Name+data.Guests[j].Email)
+	})
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package pizza_test
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestCohostURL(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{
+		UID:   "01g7d2m9r0",
+		Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC),
+	}
+
+	// WHEN
+	link, err := url.Parse(pizza.CohostURL(friday, "keeley@jones.com"))
+
+	// THEN the link only works for this event and co-host, until after the party
+	assert.Nil(t, err)
+	assert.Equal(t, "/cohost/01g7d2m9r0", link.Path)
+	exp := link.Query().Get("expires")
+	sig := link.Query().Get("sig")
+	assert.True(t, pizza.VerifyLink(sig, "cohost", "01g7d2m9r0", "keeley@jones.com", exp))
+	assert.False(t, pizza.VerifyLink(sig, "cohost", "01g7d2m9r1", "keeley@jones.com", exp))
+	assert.False(t, pizza.VerifyLink(sig, "cohost", "01g7d2m9r0", "roy@kent.com", exp))
+	assert.False(t, pizza.LinkExpired(exp, friday.EndTime().Add(pizza.CohostGrace-time.Minute)))
+	assert.True(t, pizza.LinkExpired(exp, friday.EndTime().Add(pizza.CohostGrace+pizza.LinkClockSkew+time.Minute)))
+}
+
+func TestCohostPageNotes(t *testing.T) {
+	// GIVEN the host's note about a guest who hasn't arrived
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/cohost.html")
+	require.NoError(t, err)
+	data := pizza.CohostPageData{FridayID: "1680903000", Date: "Fri Apr 7, 5:30 PM", Headcount: 2, Guests: []pizza.CohostGuestData{
+		{ID: "1", Name: "Ted Lasso", Email: "believe@tedlasso.com", Note: "allergic to <b>shellfish</b>"},
+		{ID: "2", Name: "Roy Kent", Email: "roy@kent.com"},
+	}}
+
+	// WHEN
+	var page bytes.Buffer
+	err = pizza.ExecuteTemplate(&page, plate, data)
+
+	// THEN the co-host sees it next to the guest when checking them in
+	require.NoError(t, err)
+	assert.Contains(t, page.String(), "Ted Lasso &lt;believe@tedlasso.com&gt; <em>allergic to &lt;b&gt;shellfish&lt;/b&gt;</em>")
+	assert.Equal(t, 1, strings.Count(page.String(), "<em>"))
+}
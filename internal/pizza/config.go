@@ -18,10 +18,16 @@ func init() {
 		panic(fmt.Sprintf("could not create logger: %v", err))
 	}
 
+	newCaches(1 * time.Hour)
 	if faunaSecret := os.Getenv("FAUNADB_SECRET"); len(faunaSecret) > 0 {
-		newFaunaClient(faunaSecret, 1*time.Hour)
+		newFaunaClient(faunaSecret)
+	}
+
+	if linkSecret := os.Getenv("PIZZA_LINK_SECRET"); len(linkSecret) > 0 {
+		newLinkSecret(linkSecret)
 	} else {
-		panic("no FAUNADB_SECRET found")
+		Log.Warn("no PIZZA_LINK_SECRET found, personal links will expire on restart")
+		newLinkSecret("")
 	}
 
 	if val := os.Getenv("PIZZA_STATIC_DIR"); len(val) > 0 {
@@ -30,25 +36,265 @@ func init() {
 }
 
 type Config struct {
-	Port            int            `yaml:"port"`
-	ReadTimeout     time.Duration  `yaml:"readTimeout"`
-	WriteTimeout    time.Duration  `yaml:"writeTimeout"`
-	ShutdownTimeout time.Duration  `yaml:"shutdownTimeout"`
-	Calendar        CalendarConfig `yaml:"calendar"`
+	Port            int           `yaml:"port"`
+	BaseURL         string        `yaml:"baseURL"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	MaxBodySize     int64         `yaml:"maxBodySize"`
+	StaticMaxAge    time.Duration `yaml:"staticMaxAge"`
+	// CacheStale is how long past its TTL cached data is served while it is
+	// refreshed in the background
+	CacheStale     time.Duration `yaml:"cacheStale"`
+	RSVPDeadline   time.Duration `yaml:"rsvpDeadline"`
+	RSVPOpenWindow time.Duration `yaml:"rsvpOpens"`
+	MaxPlusOnes    int           `yaml:"maxPlusOnes"`
+	MaxKids        int           `yaml:"maxKids"`
+	SlicesPerAdult int           `yaml:"slicesPerAdult"`
+	SlicesPerKid   int           `yaml:"slicesPerKid"`
+	Capacity       int           `yaml:"capacity"`
+	// Waitlist lets RSVPs to full parties in as spots open, without approval
+	Waitlist  bool `yaml:"waitlist"`
+	TableSize int  `yaml:"tableSize"`
+	// Venue is where the parties usually are, for announcements
+	Venue string `yaml:"venue"`
+	// HostEmail is where headcount alerts are emailed
+	HostEmail string `yaml:"hostEmail"`
+	// SpotNotifications is "order" to offer freed up spots to one waiting
+	// friend at a time, or "all" to offer them to everyone at once
+	SpotNotifications string        `yaml:"spotNotifications"`
+	ClaimWindow       time.Duration `yaml:"claimWindow"`
+	// SpamMinFillTime is the least time a person takes to fill in a form
+	SpamMinFillTime time.Duration     `yaml:"spamMinFillTime"`
+	Toppings        []string          `yaml:"toppings"`
+	DrinkCategories []string          `yaml:"drinkCategories"`
+	DietaryOptions  []string          `yaml:"dietaryOptions"`
+	APIKeys         []string          `yaml:"apiKeys" redact:"true"`
+	APIClients      []APIClientConfig `yaml:"apiClients"`
+	// APIJWTSecret verifies HS256 JWTs, which are off when it is empty
+	APIJWTSecret string `yaml:"apiJWTSecret" redact:"true"`
+	// APIRateLimits are requests per minute per API client by scope
+	APIRateLimits map[string]int `yaml:"apiRateLimits"`
+	// EventsHookSecret signs requests that create events through /hooks/events
+	EventsHookSecret string `yaml:"eventsHookSecret" redact:"true"`
+	// AdminPassword protects the admin pages, which are off when it is empty
+	AdminPassword string `yaml:"adminPassword" redact:"true"`
+	// Maintenance shows a maintenance page on everything but the admin pages
+	Maintenance bool `yaml:"maintenance"`
+	// Sandbox serves only the read-only API, over made up data
+	Sandbox bool `yaml:"sandbox"`
+	// Referrals lets friends share a link that adds newcomers to the friends
+	Referrals bool `yaml:"referrals"`
+	// ReferralPlusOnes are extra plus ones for friends who referred a newcomer
+	ReferralPlusOnes int `yaml:"referralPlusOnes"`
+	// UploadDir is where uploaded images are stored
+	UploadDir    string `yaml:"uploadDir"`
+	ImageWorkers int    `yaml:"imageWorkers"`
+	// ClamdSocket is a ClamAV daemon socket to scan uploads with
+	ClamdSocket string          `yaml:"clamdSocket"`
+	Calendar    CalendarConfig  `yaml:"calendar"`
+	Email       EmailConfig     `yaml:"email"`
+	Matrix      MatrixConfig    `yaml:"matrix"`
+	MQTT        MQTTConfig      `yaml:"mqtt"`
+	Captcha     CaptchaConfig   `yaml:"captcha"`
+	SMS         SMSConfig       `yaml:"sms"`
+	Chat        ChatConfig      `yaml:"chat"`
+	Probe       ProbeConfig     `yaml:"probe"`
+	Database    DatabaseConfig  `yaml:"database"`
+	Retention   RetentionConfig `yaml:"retention"`
+	Sheets      SheetsConfig    `yaml:"sheets"`
+	// SubmitLimits are how fast RSVPs may be sent by each IP address and
+	// email
+	SubmitLimits SubmitLimitsConfig `yaml:"submitLimits"`
+	// OAuth lets friends log in with their Google or GitHub account
+	OAuth OAuthConfig `yaml:"oauth"`
+	// Session is how the cookies keeping browsers logged in are set
+	Session SessionConfig `yaml:"session"`
+	// Features are who each flagged feature is on for, by name
+	Features map[string]FeatureTarget `yaml:"features"`
+
+	// fileKeys are the keys set in the config file
+	fileKeys map[string]bool
+}
+
+type APIClientConfig struct {
+	Name   string   `yaml:"name"`
+	Key    string   `yaml:"key" redact:"true"`
+	Scopes []string `yaml:"scopes"`
 }
 
 type CalendarConfig struct {
 	CredentialFile string `yaml:"credentialFile"`
 	TokenFile      string `yaml:"tokenFile"`
 	ID             string `yaml:"id"`
+	// RateLimit is how many API calls are made a second, across Workers
+	RateLimit float64 `yaml:"rateLimit"`
+	Workers   int     `yaml:"workers"`
+	// QueueLimit and MaxErrorRate are when RSVPs stop waiting for their
+	// calendar invite, which is sent later instead
+	QueueLimit   int     `yaml:"queueLimit"`
+	MaxErrorRate float64 `yaml:"maxErrorRate"`
+	// MirrorID is a public calendar events are copied to without their
+	// guests, mirroring is off when it is empty
+	MirrorID string `yaml:"mirrorID"`
+	// Provider is google, the default, or caldav to keep the events on any
+	// CalDAV server instead
+	Provider string       `yaml:"provider"`
+	CalDAV   CalDAVConfig `yaml:"caldav"`
+}
+
+type SessionConfig struct {
+	// Secure sends cookies over https only, by default when baseURL is https
+	Secure *bool `yaml:"secure"`
+	// SameSite is lax, the default, strict, or none. The admin session is
+	// always strict.
+	SameSite string `yaml:"sameSite"`
+	// Domain shares the cookies with subdomains, only the host gets them
+	// when empty
+	Domain string `yaml:"domain"`
+	// Lifetime is how long a login lasts, after which a remembered device
+	// logs back in
+	Lifetime time.Duration `yaml:"lifetime"`
+	// IdleTimeout logs out a browser, and forgets its device, when it isn't
+	// used for this long. It is off when 0.
+	IdleTimeout time.Duration `yaml:"idleTimeout"`
+}
+
+type CalDAVConfig struct {
+	// URL is the calendar collection, like
+	// https://caldav.fastmail.com/dav/calendars/user/me@fastmail.com/Default/
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" redact:"true"`
+	// Organizer is the email the invites come from, Username when empty
+	Organizer string `yaml:"organizer"`
+}
+
+type EmailConfig struct {
+	WebhookToken   string `yaml:"webhookToken" redact:"true"`
+	SMTPHost       string `yaml:"smtpHost"`
+	SMTPPort       int    `yaml:"smtpPort"`
+	Username       string `yaml:"username"`
+	Password       string `yaml:"password" redact:"true"`
+	From           string `yaml:"from"`
+	InboundAddress string `yaml:"inboundAddress"`
+	// DigestDay is the weekday the digest is sent, digests are off when empty
+	DigestDay  string `yaml:"digestDay"`
+	DigestHour int    `yaml:"digestHour"`
+	// DigestHours, when set, replaces DigestHour with several hours to try
+	// sending the digest at, each to a random cohort of friends
+	DigestHours []int `yaml:"digestHours"`
+}
+
+type MatrixConfig struct {
+	Homeserver    string            `yaml:"homeserver"`
+	AccessToken   string            `yaml:"accessToken" redact:"true"`
+	UserID        string            `yaml:"userID"`
+	RoomID        string            `yaml:"roomID"`
+	AnnounceEvery time.Duration     `yaml:"announceEvery"`
+	Friends       map[string]string `yaml:"friends"`
+}
+
+type MQTTConfig struct {
+	Broker   string `yaml:"broker"`
+	ClientID string `yaml:"clientID"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" redact:"true"`
+	Topic    string `yaml:"topic"`
+	// DiscoveryPrefix enables Home Assistant MQTT discovery, usually "homeassistant"
+	DiscoveryPrefix string `yaml:"discoveryPrefix"`
 }
 
+type CaptchaConfig struct {
+	// Provider is "hcaptcha" or "turnstile", the captcha is off when empty
+	Provider string `yaml:"provider"`
+	SiteKey  string `yaml:"siteKey"`
+	Secret   string `yaml:"secret" redact:"true"`
+	// Mode is "always", or "auto" to only ask while the forms are under attack
+	Mode       string `yaml:"mode"`
+	AttackRate int    `yaml:"attackRate"`
+}
+
+type OAuthConfig struct {
+	Google OAuthClientConfig `yaml:"google"`
+	GitHub OAuthClientConfig `yaml:"github"`
+}
+
+type OAuthClientConfig struct {
+	// ClientID turns on logging in with the provider when set
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret" redact:"true"`
+}
+
+type SMSConfig struct {
+	// AccountSID is the Twilio account, texting login codes is off when empty
+	AccountSID string `yaml:"accountSID"`
+	AuthToken  string `yaml:"authToken" redact:"true"`
+	From       string `yaml:"from"`
+}
+
+type ChatConfig struct {
+	// WebhookURL is a Slack or Discord incoming webhook told about RSVPs and
+	// cancels, it is off when empty
+	WebhookURL string `yaml:"webhookURL" redact:"true"`
+	// Kind is slack or discord, guessed from the URL when empty
+	Kind string `yaml:"kind"`
+}
+
+type ProbeConfig struct {
+	// FridayID is a sandbox Friday in the past that the probe RSVPs to, the
+	// probe is off when it is empty
+	FridayID string        `yaml:"friday"`
+	Email    string        `yaml:"email"`
+	Every    time.Duration `yaml:"every"`
+}
+
+type DatabaseConfig struct {
+	// Driver is sqlite3 or postgres to keep everything in that database
+	// instead of Fauna when set
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn" redact:"true"`
+}
+
+type SheetsConfig struct {
+	// SpreadsheetID is where each party's attendees are written, syncing is
+	// off when it is empty
+	SpreadsheetID string `yaml:"spreadsheetID"`
+	// Columns are filled in by the host and read back, like "Paid?"
+	Columns []string      `yaml:"columns"`
+	Every   time.Duration `yaml:"every"`
+}
+
+type SubmitLimitsConfig struct {
+	IP    SubmitLimit `yaml:"ip"`
+	Email SubmitLimit `yaml:"email"`
+}
+
+// RetentionConfig is how many months records are kept, forever when 0.
+type RetentionConfig struct {
+	AuditMonths     int  `yaml:"auditMonths"`
+	ClickMonths     int  `yaml:"clickMonths"`
+	CancelledMonths int  `yaml:"cancelledMonths"`
+	Anonymize       bool `yaml:"anonymize"`
+	DryRun          bool `yaml:"dryRun"`
+}
+
+// DefaultShutdownTimeout is how long requests in flight get to finish on
+// shutdown when the config doesn't say.
+const DefaultShutdownTimeout = 10 * time.Second
+
 func LoadConfig(filename string) (Config, error) {
 	config := Config{}
 	rawBytes, err := os.ReadFile(filename)
 	if err != nil {
 		return config, err
 	}
-	err = yaml.Unmarshal(rawBytes, &config)
+	if err = yaml.Unmarshal(rawBytes, &config); err != nil {
+		return config, err
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	config.fileKeys, err = configFileKeys(rawBytes)
 	return config, err
 }
@@ -0,0 +1,174 @@
+package pizza
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ConfirmTTL is how long the admin has to type back the code confirming a
+// destructive action.
+var ConfirmTTL = 10 * time.Minute
+
+// AuditDays is how far back the audit log page looks.
+var AuditDays = 90
+
+// ConfirmCode is the six digit code that confirms the action on the target
+// until the expiry, as made by LinkExpiry.
+func ConfirmCode(action, target, exp string) string {
+	sig, _ := base64.RawURLEncoding.DecodeString(SignLink("confirm", action, target, exp))
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sig)%1000000)
+}
+
+// requestIP is the address the request came from, as kept in the audit log.
+func requestIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+type AdminConfirmField struct {
+	Name  string
+	Value string
+}
+
+type AdminConfirmPageData struct {
+	AdminView
+	Action  string
+	Target  string
+	URL     string
+	Fields  []AdminConfirmField
+	Expires string
+	Code    string
+	Error   string
+}
+
+// formFields are the fields of the posted form, but the confirmation's and the
+// CSRF token, to send it again.
+func formFields(r *http.Request) []AdminConfirmField {
+	var fields []AdminConfirmField
+	for key, values := range r.PostForm {
+		if strings.HasPrefix(key, "confirm_") || key == "csrf" {
+			continue
+		}
+		for _, value := range values {
+			fields = append(fields, AdminConfirmField{key, value})
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// requireConfirmation makes the admin confirm a destructive form by typing
+// back a code that expires after ConfirmTTL, and adds the action to the audit
+// log before it goes through. target names what the form destroys, and forms
+// it names nothing for go straight through, as do dry runs: every handler
+// behind it must answer ?dry_run=true with a plan and change nothing.
+func requireConfirmation(action string, target func(r *http.Request) (string, error), next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		if isDryRun(r) {
+			next(w, r)
+			return
+		}
+		name, err := target(r)
+		if err != nil {
+			Handle500(w, r)
+			return
+		} else if len(name) == 0 {
+			next(w, r)
+			return
+		}
+
+		data := AdminConfirmPageData{AdminView: newAdminView(w, r), Action: action, Target: name, URL: r.URL.RequestURI()}
+		if exp, code := r.PostForm.Get("confirm_expires"), strings.TrimSpace(r.PostForm.Get("confirm_code")); len(exp) > 0 {
+			want := ConfirmCode(action, name, exp)
+			if !LinkExpired(exp, time.Now()) && subtle.ConstantTimeCompare([]byte(code), []byte(want)) == 1 {
+				// nothing is destroyed without a record of it
+				if err = RecordAudit(action, name, requestIP(r)); err != nil {
+					Handle500(w, r)
+					return
+				}
+				Log.Info("admin confirmed", zap.String("action", action), zap.String("target", name))
+				next(w, r)
+				return
+			}
+			data.Error = "That code didn't match, or it expired. Here's a new one."
+		}
+
+		plate, err := parseTemplate("html/admin/confirm.html")
+		if err != nil {
+			Log.Error("template admin confirm failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+		data.Fields = formFields(r)
+		data.Expires = LinkExpiry(time.Now().Add(ConfirmTTL))
+		data.Code = ConfirmCode(action, name, data.Expires)
+		if err = executeTemplate(w, plate, data); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+	}
+}
+
+type AdminAuditEntryData struct {
+	Action string
+	Target string
+	IP     string
+	At     string
+}
+
+type AdminAuditPageData struct {
+	Days    int
+	Entries []AdminAuditEntryData
+}
+
+// HandleAdminAudit lists the destructive actions the admin confirmed, newest
+// first.
+func HandleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/audit.html")
+	if err != nil {
+		Log.Error("template admin audit failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	entries, err := ListAuditEntries(time.Now().AddDate(0, 0, -AuditDays))
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminAuditPageData{Days: AuditDays}
+	for i := len(entries) - 1; i >= 0; i-- {
+		data.Entries = append(data.Entries, AdminAuditEntryData{
+			Action: entries[i].Action,
+			Target: entries[i].Target,
+			IP:     entries[i].IP,
+			At:     FormatTime(entries[i].At),
+		})
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,118 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmCode(t *testing.T) {
+	// GIVEN
+	exp := pizza.LinkExpiry(time.Now().Add(pizza.ConfirmTTL))
+
+	// WHEN
+	code := pizza.ConfirmCode("delete event", "Fri Apr 7", exp)
+
+	// THEN the code is six digits bound to the action, target, and expiry
+	assert.Regexp(t, `^[0-9]{6}$`, code)
+	assert.Equal(t, code, pizza.ConfirmCode("delete event", "Fri Apr 7", exp))
+	assert.NotEqual(t, code, pizza.ConfirmCode("delete event", "Fri Apr 14", exp))
+	assert.NotEqual(t, code, pizza.ConfirmCode("purge friend", "Fri Apr 7", exp))
+}
+
+func TestRequireConfirmation(t *testing.T) {
+	// GIVEN a handler that destroys the friend named in the form
+	pizza.StaticDir = "../../static"
+	called := 0
+	handler := pizza.RequireConfirmation("purge friend", func(r *http.Request) (string, error) {
+		return r.PostForm.Get("email"), nil
+	}, func(w http.ResponseWriter, r *http.Request) { called++ })
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/admin/friends/purge", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler(w, r)
+		return w
+	}
+	exp := pizza.LinkExpiry(time.Now().Add(-time.Hour))
+
+	// WHEN
+	nothing := post(url.Values{})
+	asked := post(url.Values{"email": {"roy@kent.com"}})
+	expired := post(url.Values{"email": {"roy@kent.com"}, "confirm_expires": {exp}, "confirm_code": {pizza.ConfirmCode("purge friend", "roy@kent.com", exp)}})
+	wrong := post(url.Values{"email": {"roy@kent.com"}, "confirm_expires": {pizza.LinkExpiry(time.Now().Add(time.Minute))}, "confirm_code": {"abc"}})
+
+	// THEN forms that name nothing go through, and the others ask for a code
+	// that hasn't expired
+	assert.Equal(t, 1, called)
+	assert.Equal(t, http.StatusOK, nothing.Code)
+	require.Equal(t, http.StatusOK, asked.Code)
+	assert.Contains(t, asked.Body.String(), `name="email" value="roy@kent.com"`)
+	assert.Contains(t, asked.Body.String(), `name="confirm_expires"`)
+	assert.NotContains(t, asked.Body.String(), "didn't match")
+	assert.Contains(t, expired.Body.String(), "didn't match")
+	assert.Contains(t, wrong.Body.String(), "didn't match")
+
+	// WHEN
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/admin/friends/purge?dry_run=true", strings.NewReader("email=roy%40kent.com"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler(w, r)
+
+	// THEN a dry run changes nothing, so it needs no code
+	assert.Equal(t, 2, called)
+}
+
+func TestConfirmedRoutesDryRun(t *testing.T) {
+	// GIVEN the sandbox's made up friends and events, and the current owner
+	pizza.StaticDir = "../../static"
+	sandbox := pizza.NewSandboxStore(time.Now())
+	defer pizza.SetStore(sandbox)()
+	fridays, err := sandbox.GetAllFridays()
+	require.NoError(t, err)
+	friday := fridays[0]
+	rsvps, err := pizza.ListFriendRSVPs("basil@example.com")
+	require.NoError(t, err)
+	own, err := pizza.GetOwnership()
+	require.NoError(t, err)
+
+	forms := map[string]url.Values{
+		"/admin/transfer":            {"action": {"start"}, "to": {"roy@kent.com"}},
+		"/admin/friends/purge":       {"email": {"basil@example.com"}},
+		"/admin/friends/duplicates":  {"primary": {"ada@example.com"}, "email": {"ada@example.com", "basil@example.com"}},
+		"/admin/fridays/{id}/delete": {},
+	}
+	require.Len(t, forms, len(pizza.ConfirmedRoutes))
+	for route, handler := range pizza.ConfirmedRoutes {
+		// WHEN the admin asks for a dry run of the form
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, route+"?dry_run=true", strings.NewReader(forms[route].Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = mux.SetURLVars(r, map[string]string{"id": friday.ID()})
+		handler(w, r)
+
+		// THEN they're shown the plan without being asked for a code
+		require.Equal(t, http.StatusOK, w.Code, route)
+		assert.Contains(t, w.Body.String(), "Dry run: ", route)
+		assert.NotContains(t, w.Body.String(), "confirm_code", route)
+	}
+
+	// THEN nothing changed
+	after, err := pizza.ListFriendRSVPs("basil@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, rsvps, after)
+	stillThere, err := pizza.GetFriday(friday.ID())
+	require.NoError(t, err)
+	assert.NotNil(t, stillThere)
+	ownAfter, err := pizza.GetOwnership()
+	require.NoError(t, err)
+	assert.Equal(t, own.TransferTo, ownAfter.TransferTo)
+}
@@ -0,0 +1,234 @@
+package pizza
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type ConfirmEmailData struct {
+	Name       string
+	Dates      []string
+	ConfirmURL string
+	Expires    string
+}
+
+type ConfirmPageData struct {
+	// Query is the confirmation link's query, posted back to confirm
+	Query   string
+	Dates   []string
+	Expired bool
+}
+
+func newRSVPCode() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate rsvp code: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// confirmRSVPParts are the parts of a confirmation link that are signed: who
+// is RSVPing, the code, and everything they picked.
+func confirmRSVPParts(q url.Values) []string {
+	parts := []string{q.Get("email"), q.Get("code"), strings.Join(q["date"], ","), q.Get("plusOnes"), q.Get("kids"), strings.Join(q["topping"], ",")}
+	for _, id := range q["date"] {
+		parts = append(parts, strings.Join(q["day:"+id], ","))
+	}
+	diets := []string{}
+	for key := range q {
+		if strings.HasPrefix(key, "diet:") {
+			diets = append(diets, key+"="+q.Get(key))
+		}
+	}
+	sort.Strings(diets)
+	return append(parts, diets...)
+}
+
+// ConfirmRSVPURL is the link emailed to a friend to confirm their RSVP. What
+// they picked rides along in the link, signed, and the code works once.
+func ConfirmRSVPURL(email, code string, fridays []Friday, days [][]string, plusOnes, kids int, prefs RSVPPreferences) string {
+	q := url.Values{}
+	q.Set("email", email)
+	q.Set("code", code)
+	q.Set("plusOnes", strconv.Itoa(plusOnes))
+	q.Set("kids", strconv.Itoa(kids))
+	for _, topping := range prefs.Toppings {
+		q.Add("topping", topping)
+	}
+	for diet, n := range prefs.Diets {
+		q.Set("diet:"+diet, strconv.Itoa(n))
+	}
+	for i, friday := range fridays {
+		q.Add("date", friday.ID())
+		for _, day := range days[i] {
+			q.Add("day:"+friday.ID(), day)
+		}
+	}
+	q.Set("sig", SignLink("confirm-rsvp", confirmRSVPParts(q)...))
+	return BaseURL + "/confirm?" + q.Encode()
+}
+
+// VerifyConfirmRSVP reports whether the confirmation link's query is one
+// made by ConfirmRSVPURL.
+func VerifyConfirmRSVP(q url.Values) bool {
+	return VerifyLink(q.Get("sig"), "confirm-rsvp", confirmRSVPParts(q)...)
+}
+
+// sendRSVPConfirmation holds the friend's dates with a new code and emails
+// them the link to confirm, to the address they RSVPed with.
+func sendRSVPConfirmation(to, email string, fridays []Friday, days [][]string, plusOnes, kids int, prefs RSVPPreferences) error {
+	code := newRSVPCode()
+	expires := time.Now().Add(RSVPCodeTTL)
+	starts := make([]time.Time, len(fridays))
+	data := ConfirmEmailData{
+		ConfirmURL: ConfirmRSVPURL(email, code, fridays, days, plusOnes, kids, prefs),
+		Expires:    FormatTime(expires),
+	}
+	for i, friday := range fridays {
+		starts[i] = friday.Start
+		data.Dates = append(data.Dates, FormatTime(friday.Start))
+	}
+	if err := CreateRSVP(email, code, starts, expires); err != nil {
+		return err
+	}
+	data.Name, _ = GetCachedFriendName(email)
+	msg, err := RenderEmail("confirm", data)
+	if err != nil {
+		Log.Error("confirm template failure", zap.Error(err))
+		return err
+	}
+	msg.To = to
+	return SendEmail(msg)
+}
+
+// HandleConfirm RSVPs a friend who followed the link in their confirmation
+// email. Opening the link only asks them to confirm, since mail scanners open
+// links too, and the RSVP goes through when they press the button.
+func HandleConfirm(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if !VerifyConfirmRSVP(q) {
+		Handle4xx(w, r)
+		return
+	}
+	email := q.Get("email")
+	plusOnes, err := strconv.Atoi(q.Get("plusOnes"))
+	if err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	kids, err := strconv.Atoi(q.Get("kids"))
+	if err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	prefs, err := ParsePreferences(q, 1+plusOnes+kids)
+	if err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	fridays := []Friday{}
+	days := [][]string{}
+	for _, id := range q["date"] {
+		friday, ok, err := GetCachedFriday(UpcomingDays, id)
+		if err != nil {
+			Log.Error("failed to get fridays", zap.Error(err))
+			Handle500(w, r)
+			return
+		} else if !ok || !friday.IsOpen() {
+			// RSVPs closed while the email was on its way
+			continue
+		}
+		fridays = append(fridays, friday)
+		days = append(days, q["day:"+id])
+	}
+
+	if r.Method != http.MethodPost {
+		plate, err := parseTemplate("html/confirm.html")
+		if err != nil {
+			Log.Error("template confirm failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+		data := ConfirmPageData{Query: q.Encode()}
+		for _, friday := range fridays {
+			data.Dates = append(data.Dates, FormatTime(friday.Start))
+		}
+		if err = executeTemplate(w, plate, data); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+		}
+		return
+	}
+
+	if err = ConfirmRSVP(email, q.Get("code"), time.Now()); err == ErrRSVPCodeExpired {
+		plate, err := parseTemplate("html/confirm.html")
+		if err != nil {
+			Log.Error("template confirm failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+		if err = executeTemplate(w, plate, ConfirmPageData{Expired: true}); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+		}
+		return
+	} else if err != nil {
+		Handle500(w, r)
+		return
+	}
+	// following the link proved the inbox is theirs
+	rememberFriend(w, r, email)
+
+	plate, err := parseTemplate("html/submit.html")
+	if err != nil {
+		Log.Error("template submit failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	unlock, ok := LockEmail(email, SubmitLockWait)
+	if !ok {
+		Log.Warn("rsvp still in flight", zap.String("email", email))
+		Handle4xx(w, r)
+		return
+	}
+	defer unlock()
+	data := SubmitPageData{DigestURL: DigestURL(email), ICalURL: ICalFeedURL(email), Waitlist: Waitlist}
+	if data.RSVPs, err = submitRSVPs(email, fridays, days, plusOnes, kids, prefs); err != nil {
+		Handle500(w, r)
+		return
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+// submitRSVPs RSVPs the friend to each of the Fridays, on the days picked for
+// it. The friend's email must be locked with LockEmail.
+func submitRSVPs(email string, fridays []Friday, days [][]string, plusOnes, kids int, prefs RSVPPreferences) ([]SubmitRSVPData, error) {
+	rsvps := []SubmitRSVPData{}
+	for i, friday := range fridays {
+		rsvp, err := RSVPToFriday(email, friday, RSVPOptions{PlusOnes: plusOnes, Kids: kids, Days: days[i], Preferences: prefs})
+		if err != nil {
+			Log.Error("rsvp failed", zap.Error(err), zap.String("eventID", friday.ID()), zap.String("email", email))
+			return rsvps, err
+		}
+		rsvps = append(rsvps, SubmitRSVPData{
+			Date:    FormatTime(friday.Start),
+			EditURL: EditRSVPURL(rsvp),
+			Pending: rsvp.Status == RSVPStatusPending,
+			Queued:  rsvp.InvitePending,
+		})
+	}
+	return rsvps, nil
+}
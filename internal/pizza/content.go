@@ -0,0 +1,54 @@
+package pizza
+
+import (
+	"html/template"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ContentSection is a part of the index page copy the host writes in markdown
+// from the admin pages.
+type ContentSection struct {
+	Name  string
+	Title string
+}
+
+var ContentSections = []ContentSection{
+	{"welcome", "Welcome"},
+	{"rules", "House rules"},
+	{"faq", "FAQ"},
+}
+
+var contentCache *Cache[map[string]string]
+
+func init() {
+	c := NewCache(time.Minute, func(string) (map[string]string, error) { return GetContent() })
+	contentCache = &c
+}
+
+// RenderedContent is the index page copy rendered to HTML by section name.
+// Sections the host hasn't written are left out.
+func RenderedContent() map[string]template.HTML {
+	content, err := contentCache.Get("index")
+	if err != nil {
+		Log.Warn("failed to load content", zap.Error(err))
+		return map[string]template.HTML{}
+	}
+	rendered := map[string]template.HTML{}
+	for _, section := range ContentSections {
+		if md := content[section.Name]; len(md) > 0 {
+			rendered[section.Name] = template.HTML(RenderMarkdown(md))
+		}
+	}
+	return rendered
+}
+
+// SaveIndexContent stores the index page copy and shows it right away.
+func SaveIndexContent(content map[string]string) error {
+	if err := SaveContent(content); err != nil {
+		return err
+	}
+	contentCache.Store("index", content)
+	return nil
+}
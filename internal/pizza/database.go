@@ -1,7 +1,10 @@
 package pizza
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	f "github.com/fauna/faunadb-go/v4/faunadb"
@@ -9,29 +12,139 @@ import (
 )
 
 var faunaClient *f.FaunaClient
-var fridayCache *Cache[[]time.Time]
+var fridayCache *Cache[[]Friday]
 var positiveFriendCache *Cache[string]
 var negativeFriendCache *Cache[bool]
 
-func newFaunaClient(secret string, cacheTTL time.Duration) {
+// CacheStale is how long past their TTL the Fridays are still served while
+// they are fetched again in the background.
+var CacheStale time.Duration
+
+func newFaunaClient(secret string) {
 	faunaClient = f.NewFaunaClient(secret)
+}
+
+// newCaches sets up the caches in front of the store, whichever it is.
+func newCaches(cacheTTL time.Duration) {
 	fcache := NewCache(cacheTTL, GetUpcomingFridaysStr)
 	fridayCache = &fcache
 	posFriendCache := NewCache(24*time.Hour, GetFriendName)
 	positiveFriendCache = &posFriendCache
 	negFriendCache := NewCache[bool](5*time.Minute, nil)
 	negativeFriendCache = &negFriendCache
+	newSuppressedEmailCache()
+}
+
+// FaunaStore keeps everything in FaunaDB, see bootstrap.go for its
+// collections and indexes.
+type FaunaStore struct{}
+
+// Ping checks the friends collection can be read with FAUNADB_SECRET.
+func (FaunaStore) Ping() error {
+	_, err := faunaClient.Query(f.Exists(f.Collection("friends")))
+	return err
+}
+
+// Friday is a single pizza event. Each event carries its own start time, so an
+// early dinner and a late night can be scheduled alongside each other.
+type Friday struct {
+	// UID is assigned when the event is created and stays the same when it is
+	// rescheduled. Events from before UIDs have none until migrated.
+	UID       string    `fauna:"uid"`
+	Start     time.Time `fauna:"date"`
+	End       time.Time `fauna:"end"`
+	Questions []string  `fauna:"questions"`
+	// Photos are image URLs shown on the recap page once the event is over
+	Photos      []string `fauna:"photos"`
+	RecapPublic bool     `fauna:"recap_public"`
+	// Images are photos uploaded from the admin pages and Cover is shown on
+	// the index
+	Images []Image `fauna:"images"`
+	Cover  Image   `fauna:"cover"`
+	// Announcement is a note from the host in basic markdown, shown on the
+	// index and in the digest
+	Announcement string `fauna:"announcement"`
+	// Drinks are the kinds of drinks guests coordinate bringing, and there's
+	// no drinks section when it is empty
+	Drinks []string `fauna:"drinks"`
+	// Capacity overrides RuntimeSettings.DefaultCapacity for this event
+	Capacity int   `fauna:"capacity"`
+	TS       int64 `fauna:"ts"`
+	// Tables are the emails of the RSVPs seated at each table
+	Tables [][]string `fauna:"tables"`
+	// Cohosts are the emails of people who help host this event only
+	Cohosts []string `fauna:"cohosts"`
+	// Reminders replace the reminder schedule for this event when set
+	Reminders []Reminder `fauna:"reminders"`
+}
+
+// ID is the identifier used for the event in forms and on the calendar. Events
+// without a UID fall back to their start time, as all events used to.
+func (f Friday) ID() string {
+	if len(f.UID) > 0 {
+		return f.UID
+	}
+	return strconv.FormatInt(f.Start.Unix(), 10)
+}
+
+// EndTime returns the end of the event, falling back to EventDuration when no
+// explicit end was stored.
+func (f Friday) EndTime() time.Time {
+	if f.End.IsZero() {
+		return f.Start.Add(EventDuration)
+	}
+	return f.End
+}
+
+// Modified is when the event's document was last changed.
+func (f Friday) Modified() time.Time {
+	return time.UnixMicro(f.TS)
 }
 
-func IsFriendAllowed(friendEmail string) (bool, error) {
-	if negativeFriendCache.Has(friendEmail) {
-		return false, nil
+// Deadline is the last moment an RSVP will be accepted for the event.
+func (f Friday) Deadline() time.Time {
+	return f.Start.Add(-CurrentSettings().RSVPDeadline)
+}
+
+// Opens is when RSVPs for the event start being accepted.
+func (f Friday) Opens() time.Time {
+	window := CurrentSettings().RSVPOpenWindow
+	if window <= 0 {
+		return time.Time{}
 	}
-	if positiveFriendCache.Has(friendEmail) {
-		return true, nil
+	return f.Start.Add(-window)
+}
+
+// IsOpen reports whether RSVPs are being accepted right now.
+func (f Friday) IsOpen() bool {
+	return !time.Now().Before(f.Opens()) && !f.IsClosed()
+}
+
+// IsClosed reports whether the RSVP deadline has passed.
+func (f Friday) IsClosed() bool {
+	return time.Now().After(f.Deadline())
+}
+
+// Limit is the most guests the host wants before RSVPs need approval, or 0
+// when there is no limit.
+func (f Friday) Limit() int {
+	if f.Capacity > 0 {
+		return f.Capacity
 	}
+	return CurrentSettings().DefaultCapacity
+}
+
+// IsOver reports whether the event has ended.
+func (f Friday) IsOver() bool {
+	return time.Now().After(f.EndTime())
+}
+
+func (FaunaStore) IsFriendAllowed(friendEmail string) (bool, error) {
 	qRes, err := faunaClient.Query(
-		f.Exists(f.MatchTerm(f.Index("all_emails"), friendEmail)),
+		f.Or(
+			f.Exists(f.MatchTerm(f.Index("all_emails"), friendEmail)),
+			f.Exists(f.MatchTerm(f.Index("friends_by_alias"), friendEmail)),
+		),
 	)
 	if err != nil {
 		Log.Error("fauna error", zap.Error(err))
@@ -42,9 +155,6 @@ func IsFriendAllowed(friendEmail string) (bool, error) {
 		Log.Error("fauna parse error", zap.Error(err))
 		return false, err
 	}
-	if !exists {
-		negativeFriendCache.Store(friendEmail, false)
-	}
 	return exists, nil
 }
 
@@ -52,7 +162,7 @@ func GetCachedFriendName(friendEmail string) (string, error) {
 	return positiveFriendCache.Get(friendEmail)
 }
 
-func GetFriendName(friendEmail string) (string, error) {
+func (FaunaStore) GetFriendName(friendEmail string) (string, error) {
 	/*
 		Get(Select(
 			"ref",
@@ -72,26 +182,79 @@ func GetFriendName(friendEmail string) (string, error) {
 	return name, nil
 }
 
-func GetAllFridays() ([]time.Time, error) {
-	qRes, err := faunaClient.Query(f.Paginate(f.Match(f.Index("all_fridays"))))
-	if err != nil {
-		Log.Error("fauna error", zap.Error(err))
-		return nil, err
+// faunaPageSize is how many documents are read at a time when reading a whole
+// set.
+const faunaPageSize = 1000
+
+// paginateAll reads the whole set a page at a time, following the after cursor
+// of each page to the next. query wraps each page, e.g. in a Map, and each
+// decodes the result.
+func paginateAll(set f.Expr, query func(page f.Expr) f.Expr, each func(f.Value) error) error {
+	var after f.Value
+	for {
+		page := f.Paginate(set, f.Size(faunaPageSize))
+		if after != nil {
+			page = f.Paginate(set, f.Size(faunaPageSize), f.After(after))
+		}
+		qRes, err := faunaClient.Query(query(page))
+		if err != nil {
+			Log.Error("fauna error", zap.Error(err))
+			return err
+		}
+		if err = each(qRes); err != nil {
+			Log.Error("fauna decode error", zap.Error(err))
+			return err
+		}
+		if after, err = qRes.At(f.ObjKey("after")).GetValue(); err != nil {
+			// the last page has no after cursor
+			return nil
+		}
 	}
-	var arr []time.Time
-	if err = qRes.At(f.ObjKey("data")).Get(&arr); err != nil {
-		Log.Error("fauna decode error", zap.Error(err))
+}
+
+// GetAllFridays returns every event, past and upcoming, in order.
+func (FaunaStore) GetAllFridays() ([]Friday, error) {
+	var fridays []Friday
+	err := paginateAll(f.Match(f.Index("all_fridays_range")), func(page f.Expr) f.Expr {
+		return f.Map(page, f.Lambda("x", f.Let().Bind(
+			"doc", f.Get(f.Select(1, f.Var("x"))),
+		).In(
+			f.Merge(f.Select("data", f.Var("doc")), f.Obj{"ts": f.Select("ts", f.Var("doc"))}),
+		)))
+	}, func(qRes f.Value) error {
+		var page []Friday
+		if err := qRes.At(f.ObjKey("data")).Get(&page); err != nil {
+			return err
+		}
+		fridays = append(fridays, page...)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	Log.Debug("got all fridays", zap.Times("fridays", arr))
-	return arr, nil
+	Log.Debug("got all fridays", zap.Int("count", len(fridays)))
+	return fridays, nil
 }
 
-func GetCachedFridays(daysAhead int) ([]time.Time, error) {
+func GetCachedFridays(daysAhead int) ([]Friday, error) {
 	return fridayCache.Get(strconv.Itoa(daysAhead))
 }
 
-func GetUpcomingFridaysStr(daysAhead string) ([]time.Time, error) {
+// GetCachedFriday finds an upcoming event by its ID.
+func GetCachedFriday(daysAhead int, id string) (Friday, bool, error) {
+	fridays, err := GetCachedFridays(daysAhead)
+	if err != nil {
+		return Friday{}, false, err
+	}
+	for _, friday := range fridays {
+		if friday.ID() == id {
+			return friday, true, nil
+		}
+	}
+	return Friday{}, false, nil
+}
+
+func GetUpcomingFridaysStr(daysAhead string) ([]Friday, error) {
 	days, err := strconv.ParseInt(daysAhead, 10, 32)
 	if err != nil {
 		return nil, err
@@ -99,7 +262,7 @@ func GetUpcomingFridaysStr(daysAhead string) ([]time.Time, error) {
 	return GetUpcomingFridays(int(days))
 }
 
-func GetUpcomingFridays(daysAhead int) ([]time.Time, error) {
+func (FaunaStore) GetUpcomingFridays(daysAhead int) ([]Friday, error) {
 	/*
 		Map(
 			Paginate(
@@ -109,30 +272,292 @@ func GetUpcomingFridays(daysAhead int) ([]time.Time, error) {
 					TimeAdd(TimeAdd(Now(), 1, "day"), 30, "days")
 				)
 			),
-			Lambda('x', Select(0, Var('x')))
+			Lambda('x', Let(
+				{ doc: Get(Select(1, Var('x'))) },
+				Merge(Select("data", Var("doc")), { ts: Select("ts", Var("doc")) })
+			))
 		)
 	*/
 	qRes, err := faunaClient.Query(f.Map(f.Paginate(f.Range(
 		f.Match(f.Index("all_fridays_range")),
 		f.Now(),
 		f.TimeAdd(f.TimeAdd(f.Now(), 1, "days"), daysAhead, "days"),
-	)), f.Lambda("x", f.Select(0, f.Var("x")))))
+	)), f.Lambda("x", f.Let().Bind(
+		"doc", f.Get(f.Select(1, f.Var("x"))),
+	).In(
+		f.Merge(f.Select("data", f.Var("doc")), f.Obj{"ts": f.Select("ts", f.Var("doc"))}),
+	))))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var fridays []Friday
+	if err = qRes.At(f.ObjKey("data")).Get(&fridays); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+
+	Log.Debug("got upcoming fridays", zap.Any("fridays", fridays))
+
+	return fridays, nil
+}
+
+// fridayRef finds the ref of the event with the given ID inside a query, or
+// null if there is none. IDs are looked up by UID first, and IDs that are
+// timestamps also match an unmigrated event starting then.
+func fridayRef(id string) f.Expr {
+	/*
+		If(
+			Exists(Match(Index("fridays_by_uid"), "1680903000")),
+			Select("ref", Get(Match(Index("fridays_by_uid"), "1680903000"))),
+			Select(["data", 0, 1], Paginate(Range(Match(Index("all_fridays_range")), Epoch(1680903000, "second"), Epoch(1680903000, "second"))), null)
+		)
+	*/
+	byUID := f.MatchTerm(f.Index("fridays_by_uid"), id)
+	var legacy f.Expr = f.Null()
+	if start, err := strconv.ParseInt(id, 10, 64); err == nil {
+		legacy = f.Select([]interface{}{"data", 0, 1}, f.Paginate(f.Range(
+			f.Match(f.Index("all_fridays_range")),
+			f.Epoch(start, "second"),
+			f.Epoch(start, "second"),
+		)), f.Default(f.Null()))
+	}
+	return f.If(f.Exists(byUID), f.Select("ref", f.Get(byUID)), legacy)
+}
+
+// GetFriday finds any event, past or upcoming, by its ID. It returns nil if
+// there is no such event.
+func (FaunaStore) GetFriday(id string) (*Friday, error) {
+	/*
+		Let(
+			{ ref: <fridayRef> },
+			If(IsNull(Var("ref")), null, Let(
+				{ doc: Get(Var("ref")) },
+				Merge(Select("data", Var("doc")), { ts: Select("ts", Var("doc")) })
+			))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Let().Bind(
+		"ref", fridayRef(id),
+	).In(f.If(
+		f.IsNull(f.Var("ref")),
+		f.Null(),
+		f.Let().Bind(
+			"doc", f.Get(f.Var("ref")),
+		).In(
+			f.Merge(f.Select("data", f.Var("doc")), f.Obj{"ts": f.Select("ts", f.Var("doc"))}),
+		),
+	)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return nil, nil
+	}
+	var friday Friday
+	if err = qRes.Get(&friday); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return &friday, nil
+}
+
+// GetFridayAt finds the event starting at exactly start, whatever its ID. It
+// returns nil if there is no such event.
+func (FaunaStore) GetFridayAt(start time.Time) (*Friday, error) {
+	/*
+		Map(
+			Paginate(Range(Match(Index("all_fridays_range")), Time("2023-04-07T21:30:00Z"), Time("2023-04-07T21:30:00Z"))),
+			Lambda('x', Let(
+				{ doc: Get(Select(1, Var('x'))) },
+				Merge(Select("data", Var("doc")), { ts: Select("ts", Var("doc")) })
+			))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(f.Paginate(f.Range(
+		f.Match(f.Index("all_fridays_range")),
+		start,
+		start,
+	)), f.Lambda("x", f.Let().Bind(
+		"doc", f.Get(f.Select(1, f.Var("x"))),
+	).In(
+		f.Merge(f.Select("data", f.Var("doc")), f.Obj{"ts": f.Select("ts", f.Var("doc"))}),
+	))))
 	if err != nil {
 		Log.Error("fauna error", zap.Error(err))
 		return nil, err
 	}
-	var times []time.Time
-	if err = qRes.At(f.ObjKey("data")).Get(&times); err != nil {
+	var fridays []Friday
+	if err = qRes.At(f.ObjKey("data")).Get(&fridays); err != nil {
 		Log.Error("fauna decode error", zap.Error(err))
 		return nil, err
 	}
+	if len(fridays) == 0 {
+		return nil, nil
+	}
+	return &fridays[0], nil
+}
+
+// MigrateFridayUIDs gives every event without a UID its old timestamp ID as a
+// UID, so links, RSVPs and calendar events made before UIDs keep working after
+// the event is rescheduled. It returns how many events were migrated, or with
+// dryRun how many would be without changing them.
+func (FaunaStore) MigrateFridayUIDs(dryRun bool) (int, error) {
+	/*
+		Select("data", Map(
+			Filter(
+				Paginate(Documents(Collection("fridays")), { size: 1000 }),
+				Lambda('ref', Not(ContainsPath(["data", "uid"], Get(Var("ref")))))
+			),
+			Lambda('ref', Let(
+				{ date: Select(["data", "date"], Get(Var("ref"))) },
+				Update(Var("ref"), { data: { uid: ToString(ToSeconds(Var("date"))) } })
+			))
+		))
+	*/
+	migrate := f.Lambda("ref", f.Let().Bind(
+		"date", f.Select(f.Arr{"data", "date"}, f.Get(f.Var("ref"))),
+	).In(
+		f.Update(f.Var("ref"), f.Obj{"data": f.Obj{"uid": f.ToString(f.ToSeconds(f.Var("date")))}}),
+	))
+	if dryRun {
+		migrate = f.Lambda("ref", f.Var("ref"))
+	}
+	qRes, err := faunaClient.Query(f.Select("data", f.Map(
+		f.Filter(
+			f.Paginate(f.Documents(f.Collection("fridays")), f.Size(1000)),
+			f.Lambda("ref", f.Not(f.ContainsPath(f.Arr{"data", "uid"}, f.Get(f.Var("ref"))))),
+		),
+		migrate,
+	)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return 0, err
+	}
+	var migrated f.ArrayV
+	if err = qRes.Get(&migrated); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return 0, err
+	}
+	return len(migrated), nil
+}
+
+// AddFridayImage adds an uploaded photo to the event, or makes it the cover.
+func (FaunaStore) AddFridayImage(id string, img Image, cover bool) error {
+	/*
+		Let(
+			{ ref: <fridayRef> },
+			If(IsNull(Var("ref")), null, Update(Var("ref"), { data: { images: Append([...], Select(["data", "images"], Get(Var("ref")), [])) } }))
+		)
+	*/
+	var data f.Obj
+	if cover {
+		data = f.Obj{"cover": img}
+	} else {
+		data = f.Obj{"images": f.Append(f.Arr{img}, f.Select([]string{"data", "images"}, f.Get(f.Var("ref")), f.Default(f.Arr{})))}
+	}
+	qRes, err := faunaClient.Query(f.Let().Bind(
+		"ref", fridayRef(id),
+	).In(
+		f.If(f.IsNull(f.Var("ref")), f.Null(), f.Update(f.Var("ref"), f.Obj{"data": data})),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return ErrRSVPNotFound
+	}
+	return nil
+}
+
+// SetFridayReminders replaces the reminders for the event, which then
+// follows the schedule again when they are empty.
+func (FaunaStore) SetFridayReminders(id string, reminders []Reminder) error {
+	return updateFridayData(id, f.Obj{"reminders": reminders})
+}
 
-	Log.Debug("got all fridays", zap.Times("fridays", times))
+// SetFridayTables saves the seating plan for the event.
+func (FaunaStore) SetFridayTables(id string, tables [][]string) error {
+	return updateFridayData(id, f.Obj{"tables": tables})
+}
+
+// SetFridayAnnouncement replaces the host's note for the event.
+func (FaunaStore) SetFridayAnnouncement(id, announcement string) error {
+	return updateFridayData(id, f.Obj{"announcement": announcement})
+}
+
+// SetFridayCohosts replaces the emails of the event's co-hosts.
+func (FaunaStore) SetFridayCohosts(id string, cohosts []string) error {
+	return updateFridayData(id, f.Obj{"cohosts": cohosts})
+}
+
+// updateFridayData sets fields of the event with the ID, returning
+// ErrRSVPNotFound if there is no such event.
+func updateFridayData(id string, data f.Obj) error {
+	/*
+		Let(
+			{ ref: <fridayRef> },
+			If(IsNull(Var("ref")), null, Update(Var("ref"), { data: { ... } }))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Let().Bind(
+		"ref", fridayRef(id),
+	).In(
+		f.If(f.IsNull(f.Var("ref")), f.Null(), f.Update(f.Var("ref"), f.Obj{"data": data})),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return ErrRSVPNotFound
+	}
+	return nil
+}
 
-	return times, nil
+// DeleteFriday removes the event along with its RSVPs and reactions. Each RSVP
+// is recorded in the change feed.
+func (FaunaStore) DeleteFriday(id string) error {
+	/*
+		Let(
+			{ ref: <fridayRef> },
+			If(IsNull(Var("ref")), null, Do(
+				Foreach(Paginate(Match(Index("rsvps_by_friday"), "1680903000"), { size: 1000 }), Lambda('ref', <delete rsvp>)),
+				Foreach(Paginate(Match(Index("reactions_by_friday"), "1680903000"), { size: 1000 }), Lambda('ref', Delete(Var('ref')))),
+				Delete(Var("ref"))
+			))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Let().Bind(
+		"ref", fridayRef(id),
+	).In(
+		f.If(f.IsNull(f.Var("ref")), f.Null(), f.Do(
+			f.Foreach(
+				f.Paginate(f.MatchTerm(f.Index("rsvps_by_friday"), id), f.Size(1000)),
+				f.Lambda("rsvp", f.Let().Bind("doc", f.Delete(f.Var("rsvp"))).In(recordRSVPChange(ChangeRSVPDeleted, f.Var("doc")))),
+			),
+			f.Foreach(
+				f.Paginate(f.MatchTerm(f.Index("reactions_by_friday"), id), f.Size(1000)),
+				f.Lambda("reaction", f.Delete(f.Var("reaction"))),
+			),
+			f.Delete(f.Var("ref")),
+		)),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return ErrRSVPNotFound
+	}
+	return nil
 }
 
-func CreateRSVP(friendEmail, code string, pendingDates []time.Time) error {
+// CreateRSVP holds the friend's pending dates until they confirm them with the
+// code, which works until expires.
+func (FaunaStore) CreateRSVP(friendEmail, code string, pendingDates []time.Time, expires time.Time) error {
 	qRes, err := faunaClient.Query(
 		f.Update(
 			f.Select(
@@ -140,8 +565,9 @@ func CreateRSVP(friendEmail, code string, pendingDates []time.Time) error {
 				f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)),
 			),
 			f.Obj{"data": f.Obj{
-				"pending_rsvps": pendingDates,
-				"rsvp_code":     code,
+				"pending_rsvps":     pendingDates,
+				"rsvp_code":         code,
+				"rsvp_code_expires": expires,
 			}},
 		),
 	)
@@ -153,26 +579,2273 @@ func CreateRSVP(friendEmail, code string, pendingDates []time.Time) error {
 	return nil
 }
 
-func ConfirmRSVP(friendEmail, code string) error {
+// ConfirmRSVP confirms the friend's pending dates and clears the code so it
+// can't be used again. Codes that expired before notAfter, have no expiry, or
+// were already used return ErrRSVPCodeExpired.
+func (FaunaStore) ConfirmRSVP(friendEmail, code string, notAfter time.Time) error {
+	/*
+		Let(
+			{ doc: Get(Match(Index("rsvp_codes"), ["test@email.com", "code"])) },
+			If(
+				GTE(Select(["data", "rsvp_code_expires"], Var("doc"), Epoch(0, "second")), Epoch(1680903000, "second")),
+				Update(Select("ref", Var("doc")), { data: {
+					confirmed_rsvps: Select(["data", "pending_rsvps"], Var("doc")),
+					pending_rsvps: null, rsvp_code: null, rsvp_code_expires: null
+				} }),
+				null
+			)
+		)
+	*/
 	qRes, err := faunaClient.Query(
 		f.Let().Bind(
-			"pending", f.Select([]string{"data", "pending_rsvps"},
-				f.Get(f.MatchTerm(f.Index("rsvp_codes"), []string{friendEmail, code}))),
-		).Bind(
-			"ref", f.Select("ref",
-				f.Get(f.MatchTerm(f.Index("rsvp_codes"), []string{friendEmail, code}))),
+			"doc", f.Get(f.MatchTerm(f.Index("rsvp_codes"), []string{friendEmail, code})),
 		).In(
-			f.Update(f.Var("ref"), f.Obj{
-				"data": f.Obj{
-					"confirmed_rsvps": f.Var("pending"),
-				},
-			}),
+			f.If(
+				f.GTE(
+					f.Select([]string{"data", "rsvp_code_expires"}, f.Var("doc"), f.Default(f.Epoch(0, "second"))),
+					f.Epoch(notAfter.Unix(), "second"),
+				),
+				f.Update(f.Select("ref", f.Var("doc")), f.Obj{
+					"data": f.Obj{
+						"confirmed_rsvps":   f.Select([]string{"data", "pending_rsvps"}, f.Var("doc")),
+						"pending_rsvps":     f.Null(),
+						"rsvp_code":         f.Null(),
+						"rsvp_code_expires": f.Null(),
+					},
+				}),
+				f.Null(),
+			),
 		),
 	)
-	if err != nil {
+	if _, ok := err.(f.NotFound); ok {
+		return ErrRSVPCodeExpired
+	} else if err != nil {
 		Log.Error("fauna error", zap.Error(err))
 		return err
 	}
+	if _, ok := qRes.(f.NullV); ok {
+		return ErrRSVPCodeExpired
+	}
 	Log.Debug("rsvp confirmed", zap.Any("result", qRes))
 	return nil
 }
+
+// ClearExpiredRSVPCodes removes the codes and pending dates of RSVPs that were
+// not confirmed before their code expired, returning how many were cleared.
+func (FaunaStore) ClearExpiredRSVPCodes(before time.Time) (int, error) {
+	/*
+		Map(
+			Paginate(Range(Match(Index("friends_by_rsvp_code_expires")), [], [Epoch(1680903000, "second")]), { size: 1000 }),
+			Lambda(["expires", "ref"], Update(Var("ref"), { data: { pending_rsvps: null, rsvp_code: null, rsvp_code_expires: null } }))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Range(
+			f.Match(f.Index("friends_by_rsvp_code_expires")),
+			f.Arr{},
+			f.Arr{f.Epoch(before.Unix(), "second")},
+		), f.Size(1000)),
+		f.Lambda(f.Arr{"expires", "ref"}, f.Select("id", f.Select("ref", f.Update(f.Var("ref"), f.Obj{
+			"data": f.Obj{
+				"pending_rsvps":     f.Null(),
+				"rsvp_code":         f.Null(),
+				"rsvp_code_expires": f.Null(),
+			},
+		})))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return 0, err
+	}
+	var ids []string
+	if err = qRes.At(f.ObjKey("data")).Get(&ids); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// RSVP is a friend's response to a single Friday, kept in the rsvps collection
+// alongside the calendar invite so it can be edited up to the deadline.
+type RSVP struct {
+	ID       string         `fauna:"-" json:"id"`
+	Email    string         `fauna:"email" json:"email"`
+	FridayID string         `fauna:"friday" json:"fridayId"`
+	PlusOnes int            `fauna:"plus_ones" json:"plusOnes"`
+	Kids     int            `fauna:"kids" json:"kids"`
+	Drinks   map[string]int `fauna:"drinks" json:"drinks,omitempty"`
+	// Diets are how many of the friend's party have each dietary restriction
+	Diets     map[string]int    `fauna:"diets" json:"diets,omitempty"`
+	Status    string            `fauna:"status" json:"status"`
+	Toppings  []string          `fauna:"toppings" json:"toppings"`
+	Answers   map[string]string `fauna:"answers" json:"answers"`
+	UpdatedAt time.Time         `fauna:"updated_at" json:"updatedAt"`
+	// InvitePending is set when the calendar invite is still to be sent
+	InvitePending bool `fauna:"invite_pending" json:"invitePending"`
+	// Days are the dates of a multi-day event the friend is coming, or every
+	// day when empty
+	Days []string `fauna:"days" json:"days,omitempty"`
+	// CheckedIn is set once the friend arrives at the party
+	CheckedIn bool `fauna:"checked_in" json:"checkedIn,omitempty"`
+	// Arrival is when the friend expects to get to the party, and Late is set
+	// when they said they're running late
+	Arrival time.Time `fauna:"arrival" json:"arrival,omitempty"`
+	Late    bool      `fauna:"late" json:"late,omitempty"`
+	// Sheet is what the host entered for the friend in their columns of the
+	// party's tab in the attendees spreadsheet
+	Sheet map[string]string `fauna:"sheet" json:"sheet,omitempty"`
+	// Version is the document's timestamp when it was read, so an update can
+	// tell whether someone else changed it first
+	Version int64 `fauna:"-" json:"-"`
+}
+
+// Confirmed reports whether the friend is on the guest list. RSVPs stored
+// before approvals existed have no status and are confirmed.
+func (r RSVP) Confirmed() bool {
+	return r.Status == "" || r.Status == RSVPStatusConfirmed
+}
+
+// Guests is how many people the RSVP brings, the friend included. Kids are
+// children on top of the plus ones, and take a spot like anyone else.
+func (r RSVP) Guests() int {
+	return 1 + r.PlusOnes + r.Kids
+}
+
+// Attends reports whether the friend is coming on the day with the date.
+func (r RSVP) Attends(date string) bool {
+	return len(r.Days) == 0 || containsString(r.Days, date)
+}
+
+type rsvpDocument struct {
+	Ref  f.RefV `fauna:"ref"`
+	TS   int64  `fauna:"ts"`
+	Data RSVP   `fauna:"data"`
+}
+
+func (d rsvpDocument) rsvp() RSVP {
+	rsvp := d.Data
+	rsvp.ID = d.Ref.ID
+	rsvp.Version = d.TS
+	return rsvp
+}
+
+// CreateFridayRSVP stores the RSVP unless the friend already has one for the
+// Friday, in which case the existing RSVP is returned untouched. It reports
+// whether the RSVP was created. When the Friday has a limit, the headcount is
+// checked in the same transaction and an RSVP that would take it over the
+// limit is stored as pending, so two friends can't both take the last spot.
+func (FaunaStore) CreateFridayRSVP(rsvp RSVP, limit int) (RSVP, bool, error) {
+	/*
+		Let(
+			{ match: Match(Index("rsvps_by_friend_friday"), ["test@email.com", "1680903000"]) },
+			If(
+				Exists(Var("match")),
+				{ doc: Get(Var("match")), created: false },
+				Let(
+					{
+						headcount: Sum(Select("data", Map(
+							Paginate(Match(Index("rsvps_by_friday"), "1680903000"), { size: 1000 }),
+							Lambda('ref', Let({ rsvp: Select("data", Get(Var("ref"))) }, If(
+								ContainsValue(Select("status", Var("rsvp"), ""), ["", "confirmed"]),
+								Add(1, Select("plus_ones", Var("rsvp"), 0), Select("kids", Var("rsvp"), 0)),
+								0
+							)))
+						))),
+						status: If(And(GT(12, 0), GT(Add(Var("headcount"), 1), 12)), "pending", "confirmed"),
+						doc: Create(Collection("rsvps"), {
+							data: Merge({...}, { status: Var("status") }),
+							ttl: If(Equals(Var("status"), "pending"), <pendingRSVPTTL>, null)
+						})
+					},
+					Do(Create(Collection("changes"), ...), { doc: Var("doc"), created: true })
+				)
+			)
+		)
+	*/
+	rsvp.UpdatedAt = time.Now()
+	qRes, err := faunaClient.Query(
+		f.Let().Bind(
+			"match", f.MatchTerm(f.Index("rsvps_by_friend_friday"), []string{rsvp.Email, rsvp.FridayID}),
+		).In(
+			f.If(
+				f.Exists(f.Var("match")),
+				f.Obj{"doc": f.Get(f.Var("match")), "created": false},
+				f.Let().Bind(
+					"headcount", fridayHeadcount(rsvp.FridayID),
+				).Bind(
+					"status", f.If(
+						f.And(f.GT(limit, 0), f.GT(f.Add(f.Var("headcount"), rsvp.Guests()), limit)),
+						RSVPStatusPending,
+						rsvp.Status,
+					),
+				).Bind(
+					"doc", f.Create(f.Collection("rsvps"), f.Obj{
+						"data": f.Merge(rsvp, f.Obj{"status": f.Var("status")}),
+						"ttl":  f.If(f.Equals(f.Var("status"), RSVPStatusPending), pendingRSVPTTL(rsvp.FridayID), f.Null()),
+					}),
+				).In(
+					f.Do(recordRSVPChange(ChangeRSVPCreated, f.Var("doc")), f.Obj{"doc": f.Var("doc"), "created": true}),
+				),
+			),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return rsvp, false, err
+	}
+	var doc rsvpDocument
+	var created bool
+	if err = qRes.At(f.ObjKey("doc")).Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return rsvp, false, err
+	}
+	if err = qRes.At(f.ObjKey("created")).Get(&created); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return rsvp, false, err
+	}
+	return doc.rsvp(), created, nil
+}
+
+// ImportFridayRSVP adds an RSVP from before the friend's RSVPs were kept,
+// unless they already have one for the Friday. It is left out of the changes
+// feed, since nothing changed. It reports whether the RSVP was added.
+func (FaunaStore) ImportFridayRSVP(rsvp RSVP) (bool, error) {
+	/*
+		If(
+			Exists(Match(Index("rsvps_by_friend_friday"), ["test@email.com", "1680903000"])),
+			false,
+			Do(Create(Collection("rsvps"), { data: {...} }), true)
+		)
+	*/
+	qRes, err := faunaClient.Query(f.If(
+		f.Exists(f.MatchTerm(f.Index("rsvps_by_friend_friday"), []string{rsvp.Email, rsvp.FridayID})),
+		false,
+		f.Do(f.Create(f.Collection("rsvps"), f.Obj{"data": rsvp}), true),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return false, err
+	}
+	var added bool
+	if err = qRes.Get(&added); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return false, err
+	}
+	return added, nil
+}
+
+// fridayHeadcount counts the Friday's confirmed guests, like Headcount, inside
+// a query.
+func fridayHeadcount(fridayID string) f.Expr {
+	return f.Sum(f.Select("data", f.Map(
+		f.Paginate(f.MatchTerm(f.Index("rsvps_by_friday"), fridayID), f.Size(1000)),
+		f.Lambda("ref", rsvpHeadcount(f.Select("data", f.Get(f.Var("ref"))))),
+	)))
+}
+
+// rsvpHeadcount is how many guests the RSVP's data counts towards the
+// headcount inside a query, its Guests if it is confirmed and none otherwise.
+func rsvpHeadcount(data f.Expr) f.Expr {
+	return f.Let().Bind(
+		"rsvp", data,
+	).In(f.If(
+		f.ContainsValue(f.Select("status", f.Var("rsvp"), f.Default("")), f.Arr{"", RSVPStatusConfirmed}),
+		f.Add(1, f.Select("plus_ones", f.Var("rsvp"), f.Default(0)), f.Select("kids", f.Var("rsvp"), f.Default(0))),
+		0,
+	))
+}
+
+// pendingRSVPTTL is when a pending RSVP for the Friday expires, like
+// PendingRSVPExpires, inside a query. The start is read from the event so the
+// TTL follows it when it's rescheduled.
+func pendingRSVPTTL(fridayID string) f.Expr {
+	return f.Let().Bind(
+		"friday", fridayRef(fridayID),
+	).In(f.If(
+		f.IsNull(f.Var("friday")),
+		f.Null(),
+		f.Select(f.Arr{"data", "date"}, f.Get(f.Var("friday"))),
+	))
+}
+
+// rsvpDocumentFields sets a TTL on RSVPs waiting for approval so Fauna removes
+// them once their Friday starts, and clears it when they are reviewed.
+func rsvpDocumentFields(rsvp RSVP) f.Obj {
+	fields := f.Obj{"data": rsvp, "ttl": f.Null()}
+	if rsvp.Status == RSVPStatusPending {
+		fields["ttl"] = pendingRSVPTTL(rsvp.FridayID)
+	}
+	return fields
+}
+
+// GetFridayRSVP returns the RSVP with the given ID, or nil if there is none.
+func (FaunaStore) GetFridayRSVP(id string) (*RSVP, error) {
+	qRes, err := faunaClient.Query(f.Get(f.RefCollection(f.Collection("rsvps"), id)))
+	if _, ok := err.(f.NotFound); ok {
+		return nil, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var doc rsvpDocument
+	if err = qRes.Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	rsvp := doc.rsvp()
+	return &rsvp, nil
+}
+
+// UpdateFridayRSVP saves the RSVP. An RSVP that was read from the database is
+// only saved if nobody changed it since, otherwise ErrRSVPConflict is returned.
+func (FaunaStore) UpdateFridayRSVP(rsvp RSVP) error {
+	return updateFridayRSVP(rsvp, 0)
+}
+
+// EditFridayRSVP is UpdateFridayRSVP, but returns ErrRSVPFull if the RSVP is
+// confirmed and grew by more guests than there are spots left, counted in the
+// same transaction.
+func (FaunaStore) EditFridayRSVP(rsvp RSVP, limit int) error {
+	return updateFridayRSVP(rsvp, limit)
+}
+
+func updateFridayRSVP(rsvp RSVP, limit int) error {
+	/*
+		Let(
+			{ ref: Ref(Collection("rsvps"), "1") },
+			If(
+				Equals(Select("ts", Get(Var("ref"))), 1680903000000000),
+				If(
+					Let(
+						{ before: <rsvpHeadcount of Select("data", Get(Var("ref")))> },
+						Or(LTE(3, Var("before")), LTE(Add(Subtract(<fridayHeadcount>, Var("before")), 3), 12))
+					),
+					Let({ doc: Update(Var("ref"), { data: {...} }) }, Do(Create(Collection("changes"), ...), Var("doc"))),
+					"full"
+				),
+				null
+			)
+		)
+	*/
+	ref := f.RefCollection(f.Collection("rsvps"), rsvp.ID)
+	var unchanged f.Expr = f.BooleanV(true)
+	if rsvp.Version != 0 {
+		unchanged = f.Equals(f.Select("ts", f.Get(ref)), rsvp.Version)
+	}
+	// shrinking always fits, even if the limit was lowered below the headcount
+	var fits f.Expr = f.BooleanV(true)
+	if limit > 0 && rsvp.Confirmed() {
+		fits = f.Let().Bind(
+			"before", rsvpHeadcount(f.Select("data", f.Get(ref))),
+		).In(f.Or(
+			f.LTE(rsvp.Guests(), f.Var("before")),
+			f.LTE(f.Add(f.Subtract(fridayHeadcount(rsvp.FridayID), f.Var("before")), rsvp.Guests()), limit),
+		))
+	}
+	rsvp.UpdatedAt = time.Now()
+	qRes, err := faunaClient.Query(
+		f.If(
+			unchanged,
+			f.If(
+				fits,
+				f.Let().Bind(
+					"doc", f.Update(ref, rsvpDocumentFields(rsvp)),
+				).In(
+					f.Do(recordRSVPChange(ChangeRSVPUpdated, f.Var("doc")), f.Var("doc")),
+				),
+				"full",
+			),
+			f.Null(),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return ErrRSVPConflict
+	} else if full, ok := qRes.(f.StringV); ok && full == "full" {
+		return ErrRSVPFull
+	}
+	Log.Debug("rsvp updated", zap.Any("result", qRes))
+	return nil
+}
+
+// SetRSVPCheckedIn marks whether the friend has arrived at the party.
+func (FaunaStore) SetRSVPCheckedIn(id string, checkedIn bool) error {
+	_, err := faunaClient.Query(f.Update(f.RefCollection(f.Collection("rsvps"), id), f.Obj{"data": f.Obj{"checked_in": checkedIn}}))
+	if _, ok := err.(f.NotFound); ok {
+		return ErrRSVPNotFound
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// SetRSVPArrival saves when the friend expects to get to the party and
+// whether they're running late.
+func (FaunaStore) SetRSVPArrival(id string, arrival time.Time, late bool) error {
+	_, err := faunaClient.Query(f.Update(f.RefCollection(f.Collection("rsvps"), id), f.Obj{"data": f.Obj{"arrival": arrival, "late": late}}))
+	if _, ok := err.(f.NotFound); ok {
+		return ErrRSVPNotFound
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// DeleteFridayRSVP removes an RSVP.
+func (FaunaStore) DeleteFridayRSVP(id string) error {
+	_, err := faunaClient.Query(
+		f.Let().Bind(
+			"doc", f.Delete(f.RefCollection(f.Collection("rsvps"), id)),
+		).In(
+			recordRSVPChange(ChangeRSVPDeleted, f.Var("doc")),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ListPendingRSVPs returns every RSVP waiting for the host's approval.
+func (FaunaStore) ListPendingRSVPs() ([]RSVP, error) {
+	/*
+		Map(
+			Paginate(Match(Index("rsvps_by_status"), "pending"), { size: 1000 }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("rsvps_by_status"), RSVPStatusPending), f.Size(1000)),
+		f.Lambda("ref", f.Get(f.Var("ref"))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var docs []rsvpDocument
+	if err = qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	rsvps := make([]RSVP, len(docs))
+	for i, doc := range docs {
+		rsvps[i] = doc.rsvp()
+	}
+	return rsvps, nil
+}
+
+// ListInvitePendingRSVPs returns the RSVPs whose calendar invite is still to
+// be sent.
+func (FaunaStore) ListInvitePendingRSVPs() ([]RSVP, error) {
+	/*
+		Map(
+			Paginate(Match(Index("rsvps_by_invite_pending"), true), { size: 1000 }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("rsvps_by_invite_pending"), true), f.Size(1000)),
+		f.Lambda("ref", f.Get(f.Var("ref"))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var docs []rsvpDocument
+	if err = qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	rsvps := make([]RSVP, len(docs))
+	for i, doc := range docs {
+		rsvps[i] = doc.rsvp()
+	}
+	return rsvps, nil
+}
+
+// ListFriendRSVPs returns every RSVP the friend has made.
+func (FaunaStore) ListFriendRSVPs(friendEmail string) ([]RSVP, error) {
+	/*
+		Map(
+			Paginate(Match(Index("rsvps_by_friend"), "test@email.com"), { size: 1000 }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("rsvps_by_friend"), friendEmail), f.Size(1000)),
+		f.Lambda("ref", f.Get(f.Var("ref"))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var docs []rsvpDocument
+	if err = qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	rsvps := make([]RSVP, len(docs))
+	for i, doc := range docs {
+		rsvps[i] = doc.rsvp()
+	}
+	return rsvps, nil
+}
+
+// PurgeFriend removes the friend and everything stored about them but their
+// RSVPs, which are removed one at a time so the calendar follows.
+func (FaunaStore) PurgeFriend(friendEmail string) error {
+	/*
+		Do(
+			Foreach(Paginate(Match(Index("notifications_by_friend"), "test@email.com"), { size: 1000 }), Lambda('ref', Delete(Var('ref')))),
+			...
+			Delete(Select("ref", Get(Match(Index("all_emails"), "test@email.com"))))
+		)
+	*/
+	deletes := []interface{}{}
+	for _, index := range []string{"notifications_by_friend", "reactions_by_friend", "passkeys_by_friend", "devices_by_friend", "email_events_by_friend"} {
+		deletes = append(deletes, f.Foreach(
+			f.Paginate(f.MatchTerm(f.Index(index), friendEmail), f.Size(1000)),
+			f.Lambda("ref", f.Delete(f.Var("ref"))),
+		))
+	}
+	deletes = append(deletes, f.Delete(f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)))))
+	_, err := faunaClient.Query(f.Do(deletes...))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// AddFriend adds a friend so they can RSVP.
+func (FaunaStore) AddFriend(name, email string) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("friends"), f.Obj{"data": f.Obj{
+		"name":      name,
+		"email":     email,
+		"joined_at": f.Now(),
+	}}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// DeleteFriend removes the friend so they can no longer RSVP.
+func (FaunaStore) DeleteFriend(friendEmail string) error {
+	_, err := faunaClient.Query(f.Delete(f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)))))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) ListFridayRSVPs(fridayID string) ([]RSVP, error) {
+	/*
+		Map(
+			Paginate(Match(Index("rsvps_by_friday"), "1680903000"), { size: 1000, after: ... }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	rsvps := []RSVP{}
+	err := paginateAll(f.MatchTerm(f.Index("rsvps_by_friday"), fridayID), func(page f.Expr) f.Expr {
+		return f.Map(page, f.Lambda("ref", f.Get(f.Var("ref"))))
+	}, func(qRes f.Value) error {
+		var docs []rsvpDocument
+		if err := qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			rsvps = append(rsvps, doc.rsvp())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsvps, nil
+}
+
+// Notification is a friend's request to hear when something changes for a
+// Friday, such as RSVPs opening.
+type Notification struct {
+	ID        string    `fauna:"-"`
+	Email     string    `fauna:"email"`
+	FridayID  string    `fauna:"friday"`
+	Kind      string    `fauna:"kind"`
+	CreatedAt time.Time `fauna:"created_at"`
+	// NotifiedAt is when the friend was offered a spot they haven't claimed yet
+	NotifiedAt time.Time `fauna:"notified_at"`
+}
+
+type notificationDocument struct {
+	Ref  f.RefV       `fauna:"ref"`
+	Data Notification `fauna:"data"`
+}
+
+// CreateNotification subscribes the friend unless they already are.
+func (FaunaStore) CreateNotification(n Notification) error {
+	n.CreatedAt = time.Now()
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(f.MatchTerm(f.Index("notifications_by_friend_friday"), []string{n.Email, n.FridayID, n.Kind})),
+			f.Null(),
+			f.Create(f.Collection("notifications"), f.Obj{"data": n}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ListNotifications returns the subscriptions of a kind for the Friday, oldest
+// first.
+func (FaunaStore) ListNotifications(fridayID, kind string) ([]Notification, error) {
+	/*
+		Map(
+			Paginate(Match(Index("notifications_by_friday"), ["1680903000", "open"]), { size: 1000, after: ... }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	var notifications []Notification
+	err := paginateAll(f.MatchTerm(f.Index("notifications_by_friday"), []string{fridayID, kind}), func(page f.Expr) f.Expr {
+		return f.Map(page, f.Lambda("ref", f.Get(f.Var("ref"))))
+	}, func(qRes f.Value) error {
+		var docs []notificationDocument
+		if err := qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			n := doc.Data
+			n.ID = doc.Ref.ID
+			notifications = append(notifications, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.Before(notifications[j].CreatedAt)
+	})
+	return notifications, nil
+}
+
+func (FaunaStore) UpdateNotification(n Notification) error {
+	_, err := faunaClient.Query(f.Update(f.RefCollection(f.Collection("notifications"), n.ID), f.Obj{"data": n}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) DeleteNotification(id string) error {
+	_, err := faunaClient.Query(f.Delete(f.RefCollection(f.Collection("notifications"), id)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// settingsRef is the single document holding the runtime settings overrides.
+var settingsRef = f.RefCollection(f.Collection("settings"), "1")
+
+// GetSettings returns the settings overridden from the admin page by name.
+func (FaunaStore) GetSettings() (map[string]string, error) {
+	qRes, err := faunaClient.Query(f.Get(settingsRef))
+	if _, ok := err.(f.NotFound); ok {
+		return map[string]string{}, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	settings := map[string]string{}
+	if err = qRes.At(f.ObjKey("data")).Get(&settings); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SaveSettings replaces the settings overrides.
+func (FaunaStore) SaveSettings(settings map[string]string) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(settingsRef),
+			f.Replace(settingsRef, f.Obj{"data": settings}),
+			f.Create(settingsRef, f.Obj{"data": settings}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ownershipRef is the single document holding who owns the series and the
+// transfer waiting for the next owner, if any.
+var ownershipRef = f.RefCollection(f.Collection("admin"), "2")
+
+// Ownership is who runs the series. Until it is first transferred the owner
+// is the config file's host email and admin password.
+type Ownership struct {
+	Owner string `fauna:"owner"`
+	// PasswordHash replaces the config file's admin password once set
+	PasswordHash string    `fauna:"password_hash"`
+	Since        time.Time `fauna:"since"`
+	// TransferTo was offered the series by the owner until TransferExpires
+	TransferTo      string    `fauna:"transfer_to"`
+	TransferExpires time.Time `fauna:"transfer_expires"`
+}
+
+func (FaunaStore) GetOwnership() (Ownership, error) {
+	var own Ownership
+	qRes, err := faunaClient.Query(f.Get(ownershipRef))
+	if _, ok := err.(f.NotFound); ok {
+		return own, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return own, err
+	}
+	if err = qRes.At(f.ObjKey("data")).Get(&own); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return own, err
+	}
+	return own, nil
+}
+
+// SaveOwnership replaces who owns the series.
+func (FaunaStore) SaveOwnership(own Ownership) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(ownershipRef),
+			f.Replace(ownershipRef, f.Obj{"data": own}),
+			f.Create(ownershipRef, f.Obj{"data": own}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// adminAuthRef is the single document holding the admin's second factor.
+var adminAuthRef = f.RefCollection(f.Collection("admin"), "1")
+
+// AdminAuth is the admin's TOTP secret and the hashes of their unused
+// recovery codes. The secret is empty until the admin enrolls.
+type AdminAuth struct {
+	TOTPSecret    string    `fauna:"totp_secret"`
+	RecoveryCodes []string  `fauna:"recovery_codes"`
+	EnrolledAt    time.Time `fauna:"enrolled_at"`
+}
+
+func (FaunaStore) GetAdminAuth() (AdminAuth, error) {
+	var auth AdminAuth
+	qRes, err := faunaClient.Query(f.Get(adminAuthRef))
+	if _, ok := err.(f.NotFound); ok {
+		return auth, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return auth, err
+	}
+	if err = qRes.At(f.ObjKey("data")).Get(&auth); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return auth, err
+	}
+	return auth, nil
+}
+
+// SaveAdminAuth replaces the admin's second factor.
+func (FaunaStore) SaveAdminAuth(auth AdminAuth) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(adminAuthRef),
+			f.Replace(adminAuthRef, f.Obj{"data": auth}),
+			f.Create(adminAuthRef, f.Obj{"data": auth}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// contentRef is the single document holding the index page copy.
+var contentRef = f.RefCollection(f.Collection("content"), "1")
+
+// GetContent returns the markdown of the index page sections by name.
+func (FaunaStore) GetContent() (map[string]string, error) {
+	qRes, err := faunaClient.Query(f.Get(contentRef))
+	if _, ok := err.(f.NotFound); ok {
+		return map[string]string{}, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	content := map[string]string{}
+	if err = qRes.At(f.ObjKey("data")).Get(&content); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return content, nil
+}
+
+// SaveContent replaces the index page copy.
+func (FaunaStore) SaveContent(content map[string]string) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(contentRef),
+			f.Replace(contentRef, f.Obj{"data": content}),
+			f.Create(contentRef, f.Obj{"data": content}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// AnnouncementTemplate is announcement text the host reuses, with variables
+// filled in by FillAnnouncement.
+type AnnouncementTemplate struct {
+	Name string `fauna:"name"`
+	Body string `fauna:"body"`
+}
+
+// templatesRef is the single document holding the announcement templates.
+var templatesRef = f.RefCollection(f.Collection("content"), "2")
+
+func (FaunaStore) GetAnnouncementTemplates() ([]AnnouncementTemplate, error) {
+	qRes, err := faunaClient.Query(f.Select([]string{"data", "templates"}, f.Get(templatesRef)))
+	if _, ok := err.(f.NotFound); ok {
+		return []AnnouncementTemplate{}, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	templates := []AnnouncementTemplate{}
+	if err = qRes.Get(&templates); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return templates, nil
+}
+
+// SaveAnnouncementTemplates replaces the announcement templates.
+func (FaunaStore) SaveAnnouncementTemplates(templates []AnnouncementTemplate) error {
+	data := f.Obj{"data": f.Obj{"templates": templates}}
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(templatesRef),
+			f.Replace(templatesRef, data),
+			f.Create(templatesRef, data),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// alertsRef is the single document holding the headcount alerts and which
+// were sent for which party.
+var alertsRef = f.RefCollection(f.Collection("content"), "3")
+
+// GetHeadcountAlerts returns the alerts and the set of "<alert>:<friday>"
+// already sent.
+func (FaunaStore) GetHeadcountAlerts() ([]HeadcountAlert, map[string]bool, error) {
+	qRes, err := faunaClient.Query(f.Select("data", f.Get(alertsRef)))
+	if _, ok := err.(f.NotFound); ok {
+		return []HeadcountAlert{}, map[string]bool{}, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, nil, err
+	}
+	var doc struct {
+		Alerts []HeadcountAlert `fauna:"alerts"`
+		Sent   []string         `fauna:"sent"`
+	}
+	if err = qRes.Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, nil, err
+	}
+	sent := map[string]bool{}
+	for _, key := range doc.Sent {
+		sent[key] = true
+	}
+	return doc.Alerts, sent, nil
+}
+
+// SaveHeadcountAlerts replaces the alerts, keeping which were sent.
+func (FaunaStore) SaveHeadcountAlerts(alerts []HeadcountAlert) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(alertsRef),
+			f.Update(alertsRef, f.Obj{"data": f.Obj{"alerts": alerts}}),
+			f.Create(alertsRef, f.Obj{"data": f.Obj{"alerts": alerts, "sent": f.Arr{}}}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// MarkHeadcountAlertSent remembers an "<alert>:<friday>" was sent.
+func (FaunaStore) MarkHeadcountAlertSent(key string) error {
+	/*
+		Update(Ref(Collection("content"), "3"), { data: {
+			sent: Append(["above-15:1680903000"], Select(["data", "sent"], Get(Ref(Collection("content"), "3")), []))
+		} })
+	*/
+	_, err := faunaClient.Query(f.Update(alertsRef, f.Obj{"data": f.Obj{
+		"sent": f.Append(f.Arr{key}, f.Select([]string{"data", "sent"}, f.Get(alertsRef), f.Default(f.Arr{}))),
+	}}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// remindersRef is the single document holding the reminder schedule and
+// which reminders were sent for which party.
+var remindersRef = f.RefCollection(f.Collection("content"), "4")
+
+// GetReminderSchedule returns the reminders sent before every party, which
+// are DefaultReminders until the host changes them, and the set of
+// "<reminder>:<friday>" already sent.
+func (FaunaStore) GetReminderSchedule() ([]Reminder, map[string]bool, error) {
+	qRes, err := faunaClient.Query(f.Select("data", f.Get(remindersRef)))
+	if _, ok := err.(f.NotFound); ok {
+		return DefaultReminders, map[string]bool{}, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, nil, err
+	}
+	var doc struct {
+		Reminders []Reminder `fauna:"reminders"`
+		Sent      []string   `fauna:"sent"`
+	}
+	if err = qRes.Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, nil, err
+	}
+	sent := map[string]bool{}
+	for _, key := range doc.Sent {
+		sent[key] = true
+	}
+	return doc.Reminders, sent, nil
+}
+
+// SaveReminderSchedule replaces the reminder schedule, keeping which were
+// sent.
+func (FaunaStore) SaveReminderSchedule(reminders []Reminder) error {
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(remindersRef),
+			f.Update(remindersRef, f.Obj{"data": f.Obj{"reminders": reminders}}),
+			f.Create(remindersRef, f.Obj{"data": f.Obj{"reminders": reminders, "sent": f.Arr{}}}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// MarkRemindersSent remembers each "<reminder>:<friday>" was sent.
+func (FaunaStore) MarkRemindersSent(keys []string) error {
+	/*
+		If(
+			Exists(Ref(Collection("content"), "4")),
+			Update(Ref(Collection("content"), "4"), { data: {
+				sent: Append(["before-2h0m0s:1680903000"], Select(["data", "sent"], Get(Ref(Collection("content"), "4")), []))
+			} }),
+			Create(Ref(Collection("content"), "4"), { data: { reminders: <DefaultReminders>, sent: ["before-2h0m0s:1680903000"] } })
+		)
+	*/
+	_, err := faunaClient.Query(
+		f.If(
+			f.Exists(remindersRef),
+			f.Update(remindersRef, f.Obj{"data": f.Obj{
+				"sent": f.Append(keys, f.Select([]string{"data", "sent"}, f.Get(remindersRef), f.Default(f.Arr{}))),
+			}}),
+			f.Create(remindersRef, f.Obj{"data": f.Obj{"reminders": DefaultReminders, "sent": keys}}),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// FlagFriendEmail marks a friend's email as undeliverable so the host can
+// correct it.
+func (FaunaStore) FlagFriendEmail(issue EmailIssue) error {
+	qRes, err := faunaClient.Query(
+		f.If(
+			f.Exists(f.MatchTerm(f.Index("all_emails"), issue.Email)),
+			f.Update(
+				f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), issue.Email))),
+				f.Obj{"data": f.Obj{
+					"email_status":     issue.Status,
+					"email_reason":     issue.Reason,
+					"email_flagged_at": f.Now(),
+				}},
+			),
+			f.Null(),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return err
+	}
+	Log.Debug("friend email flagged", zap.Any("result", qRes))
+	return nil
+}
+
+// GetEmailStatus returns why mail to the friend is suppressed, or an empty
+// string if it can be delivered.
+func (FaunaStore) GetEmailStatus(friendEmail string) (string, error) {
+	qRes, err := faunaClient.Query(
+		f.If(
+			f.Exists(f.MatchTerm(f.Index("all_emails"), friendEmail)),
+			f.Select([]string{"data", "email_status"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default("")),
+			"",
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return "", err
+	}
+	var status string
+	if err = qRes.Get(&status); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return "", err
+	}
+	return status, nil
+}
+
+// FlaggedFriend is a friend whose email has bounced or complained.
+type FlaggedFriend struct {
+	Name      string    `fauna:"name"`
+	Email     string    `fauna:"email"`
+	Status    string    `fauna:"email_status"`
+	Reason    string    `fauna:"email_reason"`
+	FlaggedAt time.Time `fauna:"email_flagged_at"`
+}
+
+func (FaunaStore) ListFlaggedFriends() ([]FlaggedFriend, error) {
+	/*
+		Map(
+			Paginate(Match(Index("friends_by_email_status"), "bounced"), { size: 1000, after: ... }),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	var friends []FlaggedFriend
+	for _, status := range []string{EmailStatusBounced, EmailStatusComplained} {
+		err := paginateAll(f.MatchTerm(f.Index("friends_by_email_status"), status), func(page f.Expr) f.Expr {
+			return f.Map(page, f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))))
+		}, func(qRes f.Value) error {
+			var page []FlaggedFriend
+			if err := qRes.At(f.ObjKey("data")).Get(&page); err != nil {
+				return err
+			}
+			friends = append(friends, page...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return friends, nil
+}
+
+// Friend is a friend's contact information.
+type Friend struct {
+	Name  string `fauna:"name" json:"name"`
+	Email string `fauna:"email" json:"email"`
+}
+
+// SearchFriends finds friends whose name, email, or the host's note about them
+// contains the query, ignoring case.
+func (FaunaStore) SearchFriends(query string) ([]Friend, error) {
+	/*
+		Map(
+			Filter(
+				Paginate(Documents(Collection("friends")), { size: 1000, after: ... }),
+				Lambda('ref', Let({ doc: Select("data", Get(Var('ref'))) }, Or(
+					ContainsStr(LowerCase(Select("name", Var('doc'), "")), "ted"),
+					ContainsStr(LowerCase(Select("email", Var('doc'), "")), "ted"),
+					ContainsStr(LowerCase(Select("note", Var('doc'), "")), "ted")
+				)))
+			),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	query = strings.ToLower(query)
+	var friends []Friend
+	err := paginateAll(f.Documents(f.Collection("friends")), func(page f.Expr) f.Expr {
+		return f.Map(
+			f.Filter(page, f.Lambda("ref", f.Let().Bind("doc", f.Select("data", f.Get(f.Var("ref")))).In(f.Or(
+				f.ContainsStr(f.LowerCase(f.Select("name", f.Var("doc"), f.Default(""))), query),
+				f.ContainsStr(f.LowerCase(f.Select("email", f.Var("doc"), f.Default(""))), query),
+				f.ContainsStr(f.LowerCase(f.Select("note", f.Var("doc"), f.Default(""))), query),
+			)))),
+			f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))),
+		)
+	}, func(qRes f.Value) error {
+		var page []Friend
+		if err := qRes.At(f.ObjKey("data")).Get(&page); err != nil {
+			return err
+		}
+		friends = append(friends, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return friends, nil
+}
+
+// SearchComments finds RSVPs with an answer to one of the party's questions
+// that contains the query, ignoring case.
+func (FaunaStore) SearchComments(query string) ([]RSVP, error) {
+	/*
+		Map(
+			Filter(
+				Paginate(Documents(Collection("rsvps")), { size: 1000, after: ... }),
+				Lambda('ref', Not(IsEmpty(Filter(
+					ToArray(Select(["data", "answers"], Get(Var('ref')), {})),
+					Lambda('answer', ContainsStr(LowerCase(Select(1, Var('answer'))), "ted"))
+				))))
+			),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	query = strings.ToLower(query)
+	var rsvps []RSVP
+	err := paginateAll(f.Documents(f.Collection("rsvps")), func(page f.Expr) f.Expr {
+		return f.Map(
+			f.Filter(page, f.Lambda("ref", f.Not(f.IsEmpty(f.Filter(
+				f.ToArray(f.Select(f.Arr{"data", "answers"}, f.Get(f.Var("ref")), f.Default(f.Obj{}))),
+				f.Lambda("answer", f.ContainsStr(f.LowerCase(f.Select(1, f.Var("answer"))), query)),
+			))))),
+			f.Lambda("ref", f.Get(f.Var("ref"))),
+		)
+	}, func(qRes f.Value) error {
+		var docs []rsvpDocument
+		if err := qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			rsvps = append(rsvps, doc.rsvp())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsvps, nil
+}
+
+// SetFriendDigest subscribes or unsubscribes the friend from the weekly digest.
+func (FaunaStore) SetFriendDigest(friendEmail string, subscribed bool) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"digest": subscribed}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) GetFriendDigest(friendEmail string) (bool, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "digest"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default(false)),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return false, err
+	}
+	var subscribed bool
+	if err = qRes.Get(&subscribed); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return false, err
+	}
+	return subscribed, nil
+}
+
+// FriendBirthday is a friend's birthday as MM-DD, and whether they want it
+// celebrated.
+type FriendBirthday struct {
+	Email    string `fauna:"email"`
+	Birthday string `fauna:"birthday"`
+	// NoCelebrate is set when the friend would rather the host didn't make a
+	// fuss
+	NoCelebrate bool `fauna:"no_celebrate"`
+}
+
+// SetFriendBirthday saves the friend's birthday, or clears it when empty.
+func (FaunaStore) SetFriendBirthday(friendEmail, birthday string, noCelebrate bool) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"birthday": birthday, "no_celebrate": noCelebrate}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) GetFriendBirthday(friendEmail string) (FriendBirthday, error) {
+	qRes, err := faunaClient.Query(
+		f.Select("data", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return FriendBirthday{}, err
+	}
+	var birthday FriendBirthday
+	if err = qRes.Get(&birthday); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return FriendBirthday{}, err
+	}
+	return birthday, nil
+}
+
+// ListFriendBirthdays returns the birthdays of the friends who want them
+// celebrated, by email.
+func (FaunaStore) ListFriendBirthdays() (map[string]string, error) {
+	/*
+		Map(
+			Filter(
+				Paginate(Documents(Collection("friends")), { size: 1000 }),
+				Lambda('ref', Let({ doc: Select("data", Get(Var('ref'))) }, And(
+					Not(Equals(Select("birthday", Var('doc'), ""), "")),
+					Not(Select("no_celebrate", Var('doc'), false))
+				)))
+			),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Filter(
+			f.Paginate(f.Documents(f.Collection("friends")), f.Size(1000)),
+			f.Lambda("ref", f.Let().Bind("doc", f.Select("data", f.Get(f.Var("ref")))).In(f.And(
+				f.Not(f.Equals(f.Select("birthday", f.Var("doc"), f.Default("")), "")),
+				f.Not(f.Select("no_celebrate", f.Var("doc"), f.Default(false))),
+			))),
+		),
+		f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var friends []FriendBirthday
+	if err = qRes.At(f.ObjKey("data")).Get(&friends); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	birthdays := make(map[string]string, len(friends))
+	for _, friend := range friends {
+		birthdays[friend.Email] = friend.Birthday
+	}
+	return birthdays, nil
+}
+
+// SetFriendNoTracking turns click tracking in the friend's emails off or back
+// on.
+func (FaunaStore) SetFriendNoTracking(friendEmail string, noTracking bool) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"no_tracking": noTracking}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) GetFriendNoTracking(friendEmail string) (bool, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "no_tracking"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default(false)),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return false, err
+	}
+	var noTracking bool
+	if err = qRes.Get(&noTracking); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return false, err
+	}
+	return noTracking, nil
+}
+
+// EmailEvent is an email with tracked links being sent to a friend, or the
+// friend clicking one of its links.
+type EmailEvent struct {
+	Email string    `fauna:"email"`
+	Kind  string    `fauna:"kind"`
+	Event string    `fauna:"event"`
+	At    time.Time `fauna:"-"`
+	TS    int64     `fauna:"ts"`
+}
+
+func (FaunaStore) RecordEmailEvent(email, kind, event string) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("email_events"), f.Obj{"data": f.Obj{
+		"email": email,
+		"kind":  kind,
+		"event": event,
+	}}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ListEmailEvents returns the email events since the time, oldest first.
+func (FaunaStore) ListEmailEvents(since time.Time) ([]EmailEvent, error) {
+	/*
+		Map(
+			Paginate(Range(Match(Index("email_events_by_ts")), 1680903000000000, []), { size: 10000 }),
+			Lambda(['ts', 'ref'], Merge(Select("data", Get(Var('ref'))), { ts: Var('ts') }))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Range(f.Match(f.Index("email_events_by_ts")), since.UnixMicro(), f.Arr{}), f.Size(10000)),
+		f.Lambda(f.Arr{"ts", "ref"}, f.Merge(
+			f.Select("data", f.Get(f.Var("ref"))),
+			f.Obj{"ts": f.Var("ts")},
+		)),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var events []EmailEvent
+	if err = qRes.At(f.ObjKey("data")).Get(&events); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	for i := range events {
+		events[i].At = time.UnixMicro(events[i].TS)
+	}
+	return events, nil
+}
+
+// AuditEntry is a destructive admin action the admin confirmed.
+type AuditEntry struct {
+	Action string    `fauna:"action"`
+	Target string    `fauna:"target"`
+	IP     string    `fauna:"ip"`
+	At     time.Time `fauna:"-"`
+	TS     int64     `fauna:"ts"`
+}
+
+// RecordAudit adds the action to the audit log.
+func (FaunaStore) RecordAudit(action, target, ip string) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("audit"), f.Obj{"data": f.Obj{
+		"action": action,
+		"target": target,
+		"ip":     ip,
+	}}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ListAuditEntries returns the audit log since the time, oldest first.
+func (FaunaStore) ListAuditEntries(since time.Time) ([]AuditEntry, error) {
+	/*
+		Map(
+			Paginate(Range(Match(Index("audit_by_ts")), 1680903000000000, []), { size: 1000 }),
+			Lambda(['ts', 'ref'], Merge(Select("data", Get(Var('ref'))), { ts: Var('ts') }))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Range(f.Match(f.Index("audit_by_ts")), since.UnixMicro(), f.Arr{}), f.Size(1000)),
+		f.Lambda(f.Arr{"ts", "ref"}, f.Merge(
+			f.Select("data", f.Get(f.Var("ref"))),
+			f.Obj{"ts": f.Var("ts")},
+		)),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var entries []AuditEntry
+	if err = qRes.At(f.ObjKey("data")).Get(&entries); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].At = time.UnixMicro(entries[i].TS)
+	}
+	return entries, nil
+}
+
+// ExpireLog deletes the documents of the log from before the time through
+// its by_ts index, see Store.
+func (FaunaStore) ExpireLog(log string, before time.Time, docType string, fields []string, dryRun bool) (int, error) {
+	/*
+		Select("data", Map(
+			Filter(
+				Paginate(Range(Match(Index("changes_by_ts")), [], [1680903000000000]), { size: 1000 }),
+				Lambda(['ts', 'ref'], Let({ doc: Get(Var('ref')) }, And(
+					true,
+					Equals(Select(["data", "type"], Var('doc'), null), "rsvp.deleted"),
+					Or(ContainsPath(["data", "rsvp"], Var('doc')))
+				)))
+			),
+			Lambda(['ts', 'ref'], Select("ts", Update(Var('ref'), { data: { rsvp: null } })))
+		))
+	*/
+	conds := []interface{}{true}
+	if len(docType) > 0 {
+		conds = append(conds, f.Equals(f.Select([]string{"data", "type"}, f.Var("doc"), f.Default(f.Null())), docType))
+	}
+	var action f.Expr = f.Select("ts", f.Delete(f.Var("ref")))
+	if len(fields) > 0 {
+		cleared := f.Obj{}
+		has := []interface{}{}
+		for _, field := range fields {
+			cleared[field] = f.Null()
+			has = append(has, f.ContainsPath([]string{"data", field}, f.Var("doc")))
+		}
+		conds = append(conds, f.Or(has...))
+		action = f.Select("ts", f.Update(f.Var("ref"), f.Obj{"data": cleared}))
+	}
+	if dryRun {
+		action = f.Var("ts")
+	}
+	qRes, err := faunaClient.Query(f.Select("data", f.Map(
+		f.Filter(
+			f.Paginate(f.Range(f.Match(f.Index(log+"_by_ts")), f.Arr{}, f.Arr{before.UnixMicro()}), f.Size(1000)),
+			f.Lambda(f.Arr{"ts", "ref"}, f.Let().Bind("doc", f.Get(f.Var("ref"))).In(f.And(conds...))),
+		),
+		f.Lambda(f.Arr{"ts", "ref"}, action),
+	)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return 0, err
+	}
+	var ts []int64
+	if err = qRes.Get(&ts); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return 0, err
+	}
+	return len(ts), nil
+}
+
+// SetFriendNote sets the host's private note about a friend.
+func (FaunaStore) SetFriendNote(friendEmail, note string) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"note": note}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// SetFriendGroups sets the groups the host tagged the friend with, such as
+// "work", which the seating plan keeps together.
+func (FaunaStore) SetFriendGroups(friendEmail string, groups []string) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"groups": groups}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) GetFriendGroups(friendEmail string) ([]string, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "groups"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default(f.Arr{})),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var groups []string
+	if err = qRes.Get(&groups); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SetFriendLabs sets the labs features the friend turned on for themselves.
+func (FaunaStore) SetFriendLabs(friendEmail string, labs []string) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"labs": labs}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) GetFriendLabs(friendEmail string) ([]string, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "labs"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default(f.Arr{})),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var labs []string
+	if err = qRes.Get(&labs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return labs, nil
+}
+
+func (FaunaStore) GetFriendNote(friendEmail string) (string, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "note"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default("")),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return "", err
+	}
+	var note string
+	if err = qRes.Get(&note); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return "", err
+	}
+	return note, nil
+}
+
+// GetPrimaryEmail returns the email of the friend the address belongs to,
+// which is the address itself unless it is one of their aliases. It is empty
+// for strangers.
+func (FaunaStore) GetPrimaryEmail(email string) (string, error) {
+	/*
+		If(
+			Exists(Match(Index("all_emails"), "test@email.com")),
+			"test@email.com",
+			Select(["data", "email"], Get(Match(Index("friends_by_alias"), "test@email.com")), "")
+		)
+	*/
+	alias := f.MatchTerm(f.Index("friends_by_alias"), email)
+	qRes, err := faunaClient.Query(
+		f.If(
+			f.Exists(f.MatchTerm(f.Index("all_emails"), email)),
+			email,
+			f.If(f.Exists(alias), f.Select([]string{"data", "email"}, f.Get(alias)), ""),
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return "", err
+	}
+	var primary string
+	if err = qRes.Get(&primary); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return "", err
+	}
+	return primary, nil
+}
+
+func (FaunaStore) GetFriendAliases(friendEmail string) ([]string, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "aliases"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default(f.Arr{})),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var aliases []string
+	if err = qRes.Get(&aliases); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// AddFriendAlias lets the friend use another email address.
+func (FaunaStore) AddFriendAlias(friendEmail, alias string) error {
+	ref := f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)))
+	_, err := faunaClient.Query(
+		f.Update(ref, f.Obj{"data": f.Obj{
+			"aliases": f.Union(f.Arr{alias}, f.Select([]string{"data", "aliases"}, f.Get(ref), f.Default(f.Arr{}))),
+		}}),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) RemoveFriendAlias(friendEmail, alias string) error {
+	ref := f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)))
+	_, err := faunaClient.Query(
+		f.Update(ref, f.Obj{"data": f.Obj{
+			"aliases": f.Difference(f.Select([]string{"data", "aliases"}, f.Get(ref), f.Default(f.Arr{})), f.Arr{alias}),
+		}}),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// DigestFriend is a friend subscribed to the weekly digest.
+type DigestFriend struct {
+	Name  string `fauna:"name"`
+	Email string `fauna:"email"`
+}
+
+func (FaunaStore) ListDigestFriends() ([]DigestFriend, error) {
+	/*
+		Map(
+			Paginate(Match(Index("friends_by_digest"), true), { size: 1000, after: ... }),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	var friends []DigestFriend
+	err := paginateAll(f.MatchTerm(f.Index("friends_by_digest"), true), func(page f.Expr) f.Expr {
+		return f.Map(page, f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))))
+	}, func(qRes f.Value) error {
+		var page []DigestFriend
+		if err := qRes.At(f.ObjKey("data")).Get(&page); err != nil {
+			return err
+		}
+		friends = append(friends, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return friends, nil
+}
+
+// ReferredFriend is a friend who joined through another friend's referral
+// link.
+type ReferredFriend struct {
+	Name       string    `fauna:"name"`
+	Email      string    `fauna:"email"`
+	ReferredBy string    `fauna:"referred_by"`
+	JoinedAt   time.Time `fauna:"joined_at"`
+}
+
+// AddReferredFriend adds a newcomer to the friends, remembering who referred
+// them.
+func (FaunaStore) AddReferredFriend(name, email, referrer string) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("friends"), f.Obj{"data": f.Obj{
+		"name":        name,
+		"email":       email,
+		"referred_by": referrer,
+		"joined_at":   f.Now(),
+	}}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// CountReferrals is how many newcomers joined through the friend's referral
+// link.
+func (FaunaStore) CountReferrals(friendEmail string) (int, error) {
+	qRes, err := faunaClient.Query(f.Count(f.MatchTerm(f.Index("friends_by_referrer"), friendEmail)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return 0, err
+	}
+	var count int
+	if err = qRes.Get(&count); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+func (FaunaStore) ListReferredFriends() ([]ReferredFriend, error) {
+	/*
+		Map(
+			Filter(
+				Paginate(Documents(Collection("friends")), { size: 1000 }),
+				Lambda('ref', ContainsPath(["data", "referred_by"], Get(Var('ref'))))
+			),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Filter(
+			f.Paginate(f.Documents(f.Collection("friends")), f.Size(1000)),
+			f.Lambda("ref", f.ContainsPath(f.Arr{"data", "referred_by"}, f.Get(f.Var("ref")))),
+		),
+		f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var friends []ReferredFriend
+	if err = qRes.At(f.ObjKey("data")).Get(&friends); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return friends, nil
+}
+
+const (
+	ChangeRSVPCreated = "rsvp.created"
+	ChangeRSVPUpdated = "rsvp.updated"
+	ChangeRSVPDeleted = "rsvp.deleted"
+)
+
+// Change is an entry in the change feed. Changes are written in the same
+// transaction as the mutation they describe.
+type Change struct {
+	Cursor   string    `fauna:"-" json:"cursor"`
+	At       time.Time `fauna:"-" json:"at"`
+	TS       int64     `fauna:"ts" json:"-"`
+	ID       string    `fauna:"id" json:"-"`
+	Type     string    `fauna:"type" json:"type"`
+	FridayID string    `fauna:"friday" json:"fridayId"`
+	RSVPID   string    `fauna:"rsvp_id" json:"-"`
+	RSVP     *RSVP     `fauna:"rsvp" json:"rsvp,omitempty"`
+}
+
+func recordRSVPChange(changeType string, doc f.Expr) f.Expr {
+	return f.Create(f.Collection("changes"), f.Obj{"data": f.Obj{
+		"type":    changeType,
+		"friday":  f.Select([]string{"data", "friday"}, doc),
+		"rsvp_id": f.Select([]string{"ref", "id"}, doc),
+		"rsvp":    f.Select("data", doc),
+	}})
+}
+
+// ListChanges returns up to limit changes after the cursor, oldest first,
+// along with the cursor to resume from.
+func (FaunaStore) ListChanges(cursor string, limit int) ([]Change, string, error) {
+	/*
+		Map(
+			Paginate(Range(Match(Index("changes_by_ts")), [1680903000000000, Ref(Collection("changes"), "1")], []), { size: 100 }),
+			Lambda(['ts', 'ref'], Merge(Select("data", Get(Var('ref'))), { ts: Var('ts'), id: Select("id", Var('ref')) }))
+		)
+	*/
+	var from interface{} = f.Arr{}
+	cursorTS, cursorID, hasCursor := parseChangeCursor(cursor)
+	if hasCursor {
+		from = f.Arr{cursorTS, f.RefCollection(f.Collection("changes"), cursorID)}
+	}
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Range(f.Match(f.Index("changes_by_ts")), from, f.Arr{}), f.Size(limit+1)),
+		f.Lambda(f.Arr{"ts", "ref"}, f.Merge(
+			f.Select("data", f.Get(f.Var("ref"))),
+			f.Obj{"ts": f.Var("ts"), "id": f.Select("id", f.Var("ref"))},
+		)),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, cursor, err
+	}
+	var changes []Change
+	if err = qRes.At(f.ObjKey("data")).Get(&changes); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, cursor, err
+	}
+	// the range includes the change at the cursor, which was already seen
+	if hasCursor && len(changes) > 0 && changes[0].ID == cursorID {
+		changes = changes[1:]
+	}
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	for i := range changes {
+		changes[i].Cursor = fmt.Sprintf("%d-%s", changes[i].TS, changes[i].ID)
+		changes[i].At = time.UnixMicro(changes[i].TS)
+		if changes[i].RSVP != nil {
+			changes[i].RSVP.ID = changes[i].RSVPID
+		}
+		cursor = changes[i].Cursor
+	}
+	return changes, cursor, nil
+}
+
+// ListRecentChanges returns the latest changes, newest first.
+func (FaunaStore) ListRecentChanges(limit int) ([]Change, error) {
+	/*
+		Map(
+			Paginate(Reverse(Match(Index("changes_by_ts"))), { size: 100 }),
+			Lambda(['ts', 'ref'], Merge(Select("data", Get(Var('ref'))), { ts: Var('ts'), id: Select("id", Var('ref')) }))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Reverse(f.Match(f.Index("changes_by_ts"))), f.Size(limit)),
+		f.Lambda(f.Arr{"ts", "ref"}, f.Merge(
+			f.Select("data", f.Get(f.Var("ref"))),
+			f.Obj{"ts": f.Var("ts"), "id": f.Select("id", f.Var("ref"))},
+		)),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var changes []Change
+	if err = qRes.At(f.ObjKey("data")).Get(&changes); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	for i := range changes {
+		changes[i].Cursor = fmt.Sprintf("%d-%s", changes[i].TS, changes[i].ID)
+		changes[i].At = time.UnixMicro(changes[i].TS)
+		if changes[i].RSVP != nil {
+			changes[i].RSVP.ID = changes[i].RSVPID
+		}
+	}
+	return changes, nil
+}
+
+func parseChangeCursor(cursor string) (int64, string, bool) {
+	tsStr, id, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return 0, "", false
+	}
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return ts, id, true
+}
+
+// Quarantined is a public form submission held for review because it looked
+// like spam.
+type Quarantined struct {
+	ID        string              `fauna:"-"`
+	Form      string              `fauna:"form"`
+	Values    map[string][]string `fauna:"values"`
+	Reason    string              `fauna:"reason"`
+	CreatedAt time.Time           `fauna:"created_at"`
+}
+
+type quarantinedDocument struct {
+	Ref  f.RefV      `fauna:"ref"`
+	Data Quarantined `fauna:"data"`
+}
+
+func (FaunaStore) CreateQuarantined(q Quarantined) error {
+	q.CreatedAt = time.Now()
+	_, err := faunaClient.Query(f.Create(f.Collection("quarantine"), f.Obj{"data": q}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// ListQuarantined returns the held submissions, newest first.
+func (FaunaStore) ListQuarantined() ([]Quarantined, error) {
+	/*
+		Map(
+			Paginate(Documents(Collection("quarantine")), { size: 1000 }),
+			Lambda('ref', Get(Var('ref')))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.Documents(f.Collection("quarantine")), f.Size(1000)),
+		f.Lambda("ref", f.Get(f.Var("ref"))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var docs []quarantinedDocument
+	if err = qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	held := make([]Quarantined, len(docs))
+	for i, doc := range docs {
+		held[i] = doc.Data
+		held[i].ID = doc.Ref.ID
+	}
+	sort.Slice(held, func(i, j int) bool {
+		return held[i].CreatedAt.After(held[j].CreatedAt)
+	})
+	return held, nil
+}
+
+func (FaunaStore) GetQuarantined(id string) (*Quarantined, error) {
+	qRes, err := faunaClient.Query(f.Get(f.RefCollection(f.Collection("quarantine"), id)))
+	if _, ok := err.(f.NotFound); ok {
+		return nil, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var doc quarantinedDocument
+	if err = qRes.Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	doc.Data.ID = doc.Ref.ID
+	return &doc.Data, nil
+}
+
+func (FaunaStore) DeleteQuarantined(id string) error {
+	_, err := faunaClient.Query(f.Delete(f.RefCollection(f.Collection("quarantine"), id)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// Reaction is a friend's emoji reaction to a Friday. Each friend has at most
+// one per Friday.
+type Reaction struct {
+	Email    string `fauna:"email"`
+	FridayID string `fauna:"friday"`
+	Emoji    string `fauna:"emoji"`
+}
+
+// SetReaction replaces the friend's reaction to the Friday, or removes it when
+// the emoji is empty.
+func (FaunaStore) SetReaction(r Reaction) error {
+	/*
+		Let(
+			{ match: Match(Index("reactions_by_friend_friday"), ["believe@tedlasso.com", "1680903000"]) },
+			If(Exists(Var("match")), Delete(Select("ref", Get(Var("match")))), null)
+		)
+	*/
+	match := f.MatchTerm(f.Index("reactions_by_friend_friday"), []string{r.Email, r.FridayID})
+	var create interface{} = f.Null()
+	if len(r.Emoji) > 0 {
+		create = f.Create(f.Collection("reactions"), f.Obj{"data": r})
+	}
+	_, err := faunaClient.Query(f.Do(
+		f.If(f.Exists(match), f.Delete(f.Select("ref", f.Get(match))), f.Null()),
+		create,
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) ListReactions(fridayID string) ([]Reaction, error) {
+	/*
+		Map(
+			Paginate(Match(Index("reactions_by_friday"), "1680903000"), { size: 1000 }),
+			Lambda('ref', Select("data", Get(Var('ref'))))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("reactions_by_friday"), fridayID), f.Size(1000)),
+		f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var reactions []Reaction
+	if err = qRes.At(f.ObjKey("data")).Get(&reactions); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// GetFriendByPhone is the email of the friend with the phone number, or empty
+// if no friend has it.
+func (FaunaStore) GetFriendByPhone(phone string) (string, error) {
+	/*
+		Select(["data", "email"], Get(Match(Index("friends_by_phone"), "+15555550123")), "")
+	*/
+	match := f.MatchTerm(f.Index("friends_by_phone"), phone)
+	qRes, err := faunaClient.Query(
+		f.If(f.Exists(match), f.Select([]string{"data", "email"}, f.Get(match)), ""),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return "", err
+	}
+	var email string
+	if err = qRes.Get(&email); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return "", err
+	}
+	return email, nil
+}
+
+// GetFriendPhone is the friend's phone number, or empty if they haven't
+// added one.
+func (FaunaStore) GetFriendPhone(friendEmail string) (string, error) {
+	qRes, err := faunaClient.Query(
+		f.Select([]string{"data", "phone"}, f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail)), f.Default("")),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return "", err
+	}
+	var phone string
+	if err = qRes.Get(&phone); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return "", err
+	}
+	return phone, nil
+}
+
+func (FaunaStore) SetFriendPhone(friendEmail, phone string) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("all_emails"), friendEmail))),
+			f.Obj{"data": f.Obj{"phone": phone}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// Passkey is a WebAuthn credential a friend can log in with.
+type Passkey struct {
+	// ID is the base64url credential id
+	ID    string `fauna:"id"`
+	Email string `fauna:"email"`
+	// PublicKey is the base64url COSE key
+	PublicKey string    `fauna:"key"`
+	SignCount uint32    `fauna:"sign_count"`
+	Name      string    `fauna:"name"`
+	CreatedAt time.Time `fauna:"created_at"`
+}
+
+func (FaunaStore) CreatePasskey(key Passkey) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("passkeys"), f.Obj{"data": key}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// GetPasskey is the passkey with the credential id, or nil if there is none.
+func (FaunaStore) GetPasskey(id string) (*Passkey, error) {
+	/*
+		Select("data", Get(Match(Index("passkeys_by_id"), "b64-credential-id")))
+	*/
+	qRes, err := faunaClient.Query(f.Select("data", f.Get(f.MatchTerm(f.Index("passkeys_by_id"), id))))
+	if _, ok := err.(f.NotFound); ok {
+		return nil, nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var key Passkey
+	if err = qRes.Get(&key); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (FaunaStore) ListPasskeys(friendEmail string) ([]Passkey, error) {
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("passkeys_by_friend"), friendEmail), f.Size(100)),
+		f.Lambda("ref", f.Select("data", f.Get(f.Var("ref")))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var keys []Passkey
+	if err = qRes.At(f.ObjKey("data")).Get(&keys); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (FaunaStore) UpdatePasskeySignCount(id string, signCount uint32) error {
+	_, err := faunaClient.Query(
+		f.Update(
+			f.Select("ref", f.Get(f.MatchTerm(f.Index("passkeys_by_id"), id))),
+			f.Obj{"data": f.Obj{"sign_count": signCount}},
+		),
+	)
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// DeletePasskey removes the friend's passkey. Passkeys of other friends are
+// left alone.
+func (FaunaStore) DeletePasskey(friendEmail, id string) error {
+	/*
+		Let(
+			{ doc: Get(Match(Index("passkeys_by_id"), "b64-credential-id")) },
+			If(
+				Equals(Select(["data", "email"], Var("doc")), "believe@tedlasso.com"),
+				Delete(Select("ref", Var("doc"))),
+				null
+			)
+		)
+	*/
+	_, err := faunaClient.Query(f.Let().Bind(
+		"doc", f.Get(f.MatchTerm(f.Index("passkeys_by_id"), id)),
+	).In(f.If(
+		f.Equals(f.Select([]string{"data", "email"}, f.Var("doc")), friendEmail),
+		f.Delete(f.Select("ref", f.Var("doc"))),
+		f.Null(),
+	)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// Device is a browser that stays logged in as a friend with a device token.
+// Only hashes of the tokens are stored.
+type Device struct {
+	ID        string    `fauna:"-"`
+	Token     string    `fauna:"token"`
+	Prev      string    `fauna:"prev"`
+	Email     string    `fauna:"email"`
+	UserAgent string    `fauna:"user_agent"`
+	CreatedAt time.Time `fauna:"created_at"`
+	RotatedAt time.Time `fauna:"rotated_at"`
+}
+
+type deviceDocument struct {
+	Ref  f.RefV `fauna:"ref"`
+	Data Device `fauna:"data"`
+}
+
+func (FaunaStore) CreateDevice(d Device) error {
+	_, err := faunaClient.Query(f.Create(f.Collection("devices"), f.Obj{"data": d}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+// GetDeviceByToken is the device whose current or previous token has the hash,
+// or nil if there is none.
+func (FaunaStore) GetDeviceByToken(hash string) (*Device, error) {
+	/*
+		Let(
+			{ token: Match(Index("devices_by_token"), "hash"), prev: Match(Index("devices_by_prev"), "hash") },
+			If(Exists(Var("token")), Get(Var("token")), If(Exists(Var("prev")), Get(Var("prev")), null))
+		)
+	*/
+	qRes, err := faunaClient.Query(f.Let().Bind(
+		"token", f.MatchTerm(f.Index("devices_by_token"), hash),
+	).Bind(
+		"prev", f.MatchTerm(f.Index("devices_by_prev"), hash),
+	).In(f.If(
+		f.Exists(f.Var("token")),
+		f.Get(f.Var("token")),
+		f.If(f.Exists(f.Var("prev")), f.Get(f.Var("prev")), f.Null()),
+	)))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	if _, ok := qRes.(f.NullV); ok {
+		return nil, nil
+	}
+	var doc deviceDocument
+	if err = qRes.Get(&doc); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	doc.Data.ID = doc.Ref.ID
+	return &doc.Data, nil
+}
+
+// RotateDevice replaces the device's token, keeping the old one as its
+// previous token.
+func (FaunaStore) RotateDevice(id, prev, token string) error {
+	_, err := faunaClient.Query(f.Update(
+		f.RefCollection(f.Collection("devices"), id),
+		f.Obj{"data": f.Obj{"token": token, "prev": prev, "rotated_at": time.Now()}},
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) ListDevices(friendEmail string) ([]Device, error) {
+	qRes, err := faunaClient.Query(f.Map(
+		f.Paginate(f.MatchTerm(f.Index("devices_by_friend"), friendEmail), f.Size(100)),
+		f.Lambda("ref", f.Get(f.Var("ref"))),
+	))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+		return nil, err
+	}
+	var docs []deviceDocument
+	if err = qRes.At(f.ObjKey("data")).Get(&docs); err != nil {
+		Log.Error("fauna decode error", zap.Error(err))
+		return nil, err
+	}
+	devices := make([]Device, len(docs))
+	for i, doc := range docs {
+		devices[i] = doc.Data
+		devices[i].ID = doc.Ref.ID
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].RotatedAt.After(devices[j].RotatedAt)
+	})
+	return devices, nil
+}
+
+func (FaunaStore) DeleteDevice(id string) error {
+	_, err := faunaClient.Query(f.Delete(f.RefCollection(f.Collection("devices"), id)))
+	if _, ok := err.(f.NotFound); ok {
+		return nil
+	} else if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
+
+func (FaunaStore) CreateFriday(friday Friday) error {
+	data := f.Obj{"uid": friday.UID, "date": friday.Start}
+	if !friday.End.IsZero() {
+		data["end"] = friday.End
+	}
+	if len(friday.Announcement) > 0 {
+		data["announcement"] = friday.Announcement
+	}
+	if friday.Capacity > 0 {
+		data["capacity"] = friday.Capacity
+	}
+	if len(friday.Drinks) > 0 {
+		data["drinks"] = friday.Drinks
+	}
+	_, err := faunaClient.Query(f.Create(f.Collection("fridays"), f.Obj{"data": data}))
+	if err != nil {
+		Log.Error("fauna error", zap.Error(err))
+	}
+	return err
+}
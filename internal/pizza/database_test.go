@@ -3,11 +3,14 @@ package pizza_test
 import (
 	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mpoegel/rsvp.pizza/internal/pizza"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsFriendAllowed(t *testing.T) {
@@ -23,3 +26,132 @@ func TestGetFriendName(t *testing.T) {
 	assert.Nil(t, err)
 	fmt.Println(name)
 }
+
+func TestFridayTimes(t *testing.T) {
+	// GIVEN
+	start := time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)
+	friday := pizza.Friday{Start: start}
+
+	// THEN
+	assert.Equal(t, "1680903000", friday.ID())
+	assert.Equal(t, start.Add(pizza.EventDuration), friday.EndTime())
+
+	// WHEN
+	friday.End = start.Add(2 * time.Hour)
+	t.Cleanup(pizza.SetSettings(func(s *pizza.RuntimeSettings) { s.RSVPDeadline = 3 * time.Hour }))
+
+	// THEN
+	assert.Equal(t, start.Add(2*time.Hour), friday.EndTime())
+	assert.Equal(t, start.Add(-3*time.Hour), friday.Deadline())
+}
+
+func TestFridayOpens(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Start: time.Now().Add(72 * time.Hour)}
+	t.Cleanup(pizza.SetSettings(func(s *pizza.RuntimeSettings) { s.RSVPDeadline = 2 * time.Hour }))
+
+	// THEN
+	assert.True(t, friday.Opens().IsZero())
+	assert.True(t, friday.IsOpen())
+
+	// WHEN
+	pizza.UpdateSettings(func(s *pizza.RuntimeSettings) { s.RSVPOpenWindow = 48 * time.Hour })
+
+	// THEN
+	assert.Equal(t, friday.Start.Add(-48*time.Hour), friday.Opens())
+	assert.False(t, friday.IsOpen())
+
+	// WHEN
+	pizza.UpdateSettings(func(s *pizza.RuntimeSettings) { s.RSVPOpenWindow = 96 * time.Hour })
+
+	// THEN
+	assert.True(t, friday.IsOpen())
+}
+
+func TestFriendNote(t *testing.T) {
+	// GIVEN a friend
+	email := fmt.Sprintf("note-%d@example.com", time.Now().UnixNano())
+	assert.Nil(t, pizza.AddFriend("Note Test", email))
+	defer pizza.DeleteFriend(email)
+
+	// WHEN the host notes something about them
+	assert.Nil(t, pizza.SetFriendNote(email, "allergic to shellfish"))
+	note, err := pizza.GetFriendNote(email)
+
+	// THEN it is kept with them and they can be found by it
+	assert.Nil(t, err)
+	assert.Equal(t, "allergic to shellfish", note)
+	friends, err := pizza.SearchFriends("SHELLFISH")
+	assert.Nil(t, err)
+	assert.Contains(t, friends, pizza.Friend{Name: "Note Test", Email: email})
+}
+
+func TestCreateFridayRSVPLastSpots(t *testing.T) {
+	// GIVEN a party with room for 3 and 10 friends RSVPing at once
+	fridayID := fmt.Sprint(time.Now().UnixNano())
+	const limit, friends = 3, 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var rsvps []pizza.RSVP
+
+	// WHEN
+	for i := 0; i < friends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rsvp, created, err := pizza.CreateFridayRSVP(pizza.RSVP{
+				Email:    fmt.Sprintf("race-%d@example.com", i),
+				FridayID: fridayID,
+				Status:   pizza.RSVPStatusConfirmed,
+			}, limit)
+			assert.Nil(t, err)
+			assert.True(t, created)
+			mu.Lock()
+			defer mu.Unlock()
+			rsvps = append(rsvps, rsvp)
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		for _, rsvp := range rsvps {
+			pizza.DeleteFridayRSVP(rsvp.ID)
+		}
+	}()
+
+	// THEN only as many as fit are confirmed, the rest wait for a spot
+	require.Len(t, rsvps, friends)
+	assert.Equal(t, limit, len(pizza.ConfirmedRSVPs(rsvps)))
+	stored, err := pizza.ListFridayRSVPs(fridayID)
+	require.Nil(t, err)
+	assert.Len(t, stored, friends)
+	assert.Equal(t, limit, pizza.Headcount(stored))
+}
+
+func TestUpdateFridayRSVPConflict(t *testing.T) {
+	// GIVEN an RSVP read twice, e.g. by the friend and by the host
+	rsvp, _, err := pizza.CreateFridayRSVP(pizza.RSVP{
+		Email:    "conflict@example.com",
+		FridayID: fmt.Sprint(time.Now().UnixNano()),
+		Status:   pizza.RSVPStatusConfirmed,
+	}, 0)
+	require.Nil(t, err)
+	defer pizza.DeleteFridayRSVP(rsvp.ID)
+	friend, err := pizza.GetFridayRSVP(rsvp.ID)
+	require.Nil(t, err)
+	host, err := pizza.GetFridayRSVP(rsvp.ID)
+	require.Nil(t, err)
+
+	// WHEN both save their change
+	friend.PlusOnes = 1
+	first := pizza.UpdateFridayRSVP(*friend)
+	host.Status = pizza.RSVPStatusDeclined
+	second := pizza.UpdateFridayRSVP(*host)
+
+	// THEN the second doesn't overwrite the first
+	assert.Nil(t, first)
+	assert.Equal(t, pizza.ErrRSVPConflict, second)
+	saved, err := pizza.GetFridayRSVP(rsvp.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 1, saved.PlusOnes)
+	assert.Equal(t, pizza.RSVPStatusConfirmed, saved.Status)
+}
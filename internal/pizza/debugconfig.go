@@ -0,0 +1,197 @@
+package pizza
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ConfigSourceFile     = "file"
+	ConfigSourceDefault  = "default"
+	ConfigSourceSettings = "settings"
+	ConfigSourceEnv      = "env"
+)
+
+// ConfigEntry is one value of the running configuration and where it came
+// from.
+type ConfigEntry struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// configSnapshot is the configuration the server started with, taken once in
+// NewServer and never changed after.
+var configSnapshot []ConfigEntry
+
+// configDefaults are the values used for keys missing from the config file
+// that default to something other than zero.
+var configDefaults = map[string]func() any{
+	"shutdownTimeout":          func() any { return DefaultShutdownTimeout.String() },
+	"maxBodySize":              func() any { return MaxBodySize },
+	"staticMaxAge":             func() any { return StaticMaxAge.String() },
+	"slicesPerAdult":           func() any { return SlicesPerAdult },
+	"slicesPerKid":             func() any { return SlicesPerKid },
+	"tableSize":                func() any { return DefaultTableSize },
+	"claimWindow":              func() any { return ClaimWindow.String() },
+	"drinkCategories":          func() any { return DrinkCategories },
+	"spamMinFillTime":          func() any { return SpamMinFillTime.String() },
+	"calendar.rateLimit":       func() any { return CalendarRateLimit },
+	"calendar.workers":         func() any { return CalendarWorkers },
+	"calendar.queueLimit":      func() any { return CalendarQueueLimit },
+	"calendar.maxErrorRate":    func() any { return CalendarMaxErrorRate },
+	"captcha.attackRate":       func() any { return CaptchaAttackRate },
+	"submitLimits.ip.burst":    func() any { return SubmitIPLimit.Burst },
+	"submitLimits.ip.every":    func() any { return SubmitIPLimit.Every.String() },
+	"submitLimits.email.burst": func() any { return SubmitEmailLimit.Burst },
+	"submitLimits.email.every": func() any { return SubmitEmailLimit.Every.String() },
+	"session.sameSite":         func() any { return "lax" },
+	"session.lifetime":         func() any { return FriendCookieTTL.String() },
+}
+
+// configFileKeys reads which keys the config file sets, nested keys joined
+// with dots.
+func configFileKeys(raw []byte) (map[string]bool, error) {
+	var doc map[any]any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	var walk func(prefix string, m map[any]any)
+	walk = func(prefix string, m map[any]any) {
+		for k, v := range m {
+			key := prefix + fmt.Sprint(k)
+			keys[key] = true
+			if nested, ok := v.(map[any]any); ok {
+				walk(key+".", nested)
+			}
+		}
+	}
+	walk("", doc)
+	return keys, nil
+}
+
+// SnapshotConfig lists every value in the config, with secrets redacted, as
+// set in the file or else by default. Maps such as matrix.friends are one
+// value.
+func SnapshotConfig(config Config) []ConfigEntry {
+	var entries []ConfigEntry
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if len(name) == 0 || !field.IsExported() {
+				continue
+			}
+			key := prefix + name
+			if field.Type.Kind() == reflect.Struct {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			entry := ConfigEntry{Key: key, Value: configValue(v.Field(i), field.Tag.Get("redact") == "true"), Source: ConfigSourceFile}
+			if !config.fileKeys[key] {
+				entry.Source = ConfigSourceDefault
+				if def, ok := configDefaults[key]; ok {
+					entry.Value = def()
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	walk("", reflect.ValueOf(config))
+
+	for _, env := range []struct{ key, name string }{
+		{"faunaSecret", "FAUNADB_SECRET"},
+		{"linkSecret", "PIZZA_LINK_SECRET"},
+	} {
+		entry := ConfigEntry{Key: env.key, Value: "", Source: ConfigSourceDefault}
+		if len(os.Getenv(env.name)) > 0 {
+			entry.Value, entry.Source = redacted, ConfigSourceEnv
+		}
+		entries = append(entries, entry)
+	}
+	entry := ConfigEntry{Key: "staticDir", Value: StaticDir, Source: ConfigSourceDefault}
+	if len(os.Getenv("PIZZA_STATIC_DIR")) > 0 {
+		entry.Source = ConfigSourceEnv
+	}
+	return append(entries, entry)
+}
+
+const redacted = "[redacted]"
+
+// configValue is the value as it would be written in the config file, or
+// redacted when it is a secret that is set.
+func configValue(v reflect.Value, redact bool) any {
+	if redact && !v.IsZero() {
+		return redacted
+	}
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		list := make([]map[string]any, v.Len())
+		for i := range list {
+			list[i] = map[string]any{}
+			item := v.Index(i)
+			for j := 0; j < item.NumField(); j++ {
+				field := item.Type().Field(j)
+				name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+				list[i][name] = configValue(item.Field(j), field.Tag.Get("redact") == "true")
+			}
+		}
+		return list
+	}
+	return v.Interface()
+}
+
+// EffectiveConfig is the snapshot with the settings the host can override from
+// the admin page at their current values.
+func EffectiveConfig(snapshot []ConfigEntry, overrides map[string]string) []ConfigEntry {
+	entries := make([]ConfigEntry, len(snapshot), len(snapshot)+len(Settings))
+	copy(entries, snapshot)
+	for _, setting := range Settings {
+		_, overridden := overrides[setting.Name]
+		found := false
+		for i := range entries {
+			if entries[i].Key == setting.Name {
+				found = true
+				entries[i].Value = setting.Value()
+				if overridden {
+					entries[i].Source = ConfigSourceSettings
+				}
+			}
+		}
+		if !found {
+			entry := ConfigEntry{Key: setting.Name, Value: setting.Value(), Source: ConfigSourceDefault}
+			if overridden {
+				entry.Source = ConfigSourceSettings
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// HandleDebugConfig serves the configuration the server is running with and
+// where each value came from, to tell why a value isn't what was expected.
+func HandleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	overrides, err := settingsCache.Get("runtime")
+	if err != nil {
+		Log.Warn("failed to load settings", zap.Error(err))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Config []ConfigEntry `json:"config"`
+	}{EffectiveConfig(configSnapshot, overrides)})
+}
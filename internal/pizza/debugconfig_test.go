@@ -0,0 +1,50 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	// GIVEN a config file that sets a few values and a secret
+	file := filepath.Join(t.TempDir(), "pizza.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+cacheStale: 5m
+maxPlusOnes: 2
+adminPassword: hunter2
+apiClients:
+  - name: ha
+    key: key-secret
+    scopes: [read:events]
+calendar:
+  workers: 4
+`), 0o644))
+	config, err := pizza.LoadConfig(file)
+	require.NoError(t, err)
+
+	// WHEN the capacity is overridden from the admin page
+	entries := pizza.EffectiveConfig(pizza.SnapshotConfig(config), map[string]string{"capacity": "12"})
+	byKey := map[string]pizza.ConfigEntry{}
+	for _, entry := range entries {
+		byKey[entry.Key] = entry
+	}
+
+	// THEN each value says where it came from and secrets are never shown
+	assert.Equal(t, pizza.ConfigEntry{Key: "cacheStale", Value: "5m0s", Source: pizza.ConfigSourceFile}, byKey["cacheStale"])
+	assert.Equal(t, pizza.ConfigEntry{Key: "calendar.workers", Value: 4, Source: pizza.ConfigSourceFile}, byKey["calendar.workers"])
+	assert.Equal(t, pizza.ConfigSourceDefault, byKey["calendar.rateLimit"].Source)
+	assert.Equal(t, pizza.CalendarRateLimit, byKey["calendar.rateLimit"].Value)
+	assert.Equal(t, "[redacted]", byKey["adminPassword"].Value)
+	assert.Equal(t, "", byKey["email.password"].Value)
+	assert.Equal(t, []map[string]any{{"name": "ha", "key": "[redacted]", "scopes": []string{"read:events"}}}, byKey["apiClients"].Value)
+	assert.Equal(t, pizza.ConfigSourceSettings, byKey["capacity"].Source)
+	assert.Equal(t, pizza.ConfigSourceFile, byKey["maxPlusOnes"].Source)
+	assert.Equal(t, pizza.ConfigSourceDefault, byKey["bannerLevel"].Source)
+	assert.Equal(t, pizza.DefaultShutdownTimeout, config.ShutdownTimeout)
+	assert.Equal(t, pizza.ConfigEntry{Key: "shutdownTimeout", Value: "10s", Source: pizza.ConfigSourceDefault}, byKey["shutdownTimeout"])
+}
@@ -0,0 +1,192 @@
+package pizza
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// DeviceTokenTTL is how long a device stays logged in without being used.
+	DeviceTokenTTL = 180 * 24 * time.Hour
+	// DeviceRotationGrace is how long a rotated token still works, for requests
+	// the browser sent before it got the new one.
+	DeviceRotationGrace = time.Minute
+)
+
+const deviceCookieName = "pizza_device"
+
+func newDeviceToken() (string, string) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate device token: %v", err))
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashDeviceToken(token)
+}
+
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func setDeviceCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, newCookie(deviceCookieName, token, "/", time.Now().Add(DeviceTokenTTL)))
+}
+
+func clearDeviceCookie(w http.ResponseWriter) {
+	clearCookie(w, deviceCookieName, "/")
+}
+
+// forgetDevice logs out the browser's device, so its token can't log it back
+// in.
+func forgetDevice(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(deviceCookieName)
+	if err != nil {
+		return
+	}
+	clearDeviceCookie(w)
+	device, err := GetDeviceByToken(hashDeviceToken(cookie.Value))
+	if err != nil || device == nil {
+		return
+	}
+	if err = DeleteDevice(device.ID); err != nil {
+		Log.Warn("failed to forget device", zap.Error(err), zap.String("email", device.Email))
+	}
+}
+
+// rememberFriend logs the browser in as the friend, in a new session, and
+// keeps it logged in with a device token.
+func rememberFriend(w http.ResponseWriter, r *http.Request, email string) {
+	sameFriend := friendFromCookie(r) == email
+	replaceRequestCookie(r, friendCookieName, setFriendCookie(w, email))
+	if _, err := r.Cookie(deviceCookieName); err == nil && sameFriend {
+		return
+	}
+	token, hash := newDeviceToken()
+	userAgent := r.UserAgent()
+	if len(userAgent) > 200 {
+		userAgent = userAgent[:200]
+	}
+	err := CreateDevice(Device{
+		Token:     hash,
+		Email:     email,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+		RotatedAt: time.Now(),
+	})
+	if err != nil {
+		Log.Warn("failed to remember device", zap.Error(err), zap.String("email", email))
+		return
+	}
+	setDeviceCookie(w, token)
+}
+
+// RememberDevice logs the browser back in when its session has run out but it
+// has a device token. The token is replaced each time it is used, and a token
+// used again after it was replaced means it was copied, so the device is
+// logged out.
+func RememberDevice(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(deviceCookieName)
+		if err != nil || len(friendFromCookie(r)) > 0 || strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if email := useDeviceToken(w, cookie.Value); len(email) > 0 {
+			// the rest of this request sees the friend as logged in
+			replaceRequestCookie(r, friendCookieName, setFriendCookie(w, email))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func useDeviceToken(w http.ResponseWriter, token string) string {
+	hash := hashDeviceToken(token)
+	device, err := GetDeviceByToken(hash)
+	if err != nil {
+		return ""
+	}
+	if device == nil || time.Since(device.RotatedAt) > DeviceTokenTTL {
+		clearDeviceCookie(w)
+		return ""
+	}
+	if device.Token != hash {
+		if time.Since(device.RotatedAt) <= DeviceRotationGrace {
+			return device.Email
+		}
+		Log.Warn("replaced device token reused, logging device out", zap.String("email", device.Email))
+		DeleteDevice(device.ID)
+		clearDeviceCookie(w)
+		return ""
+	}
+	next, nextHash := newDeviceToken()
+	if err = RotateDevice(device.ID, hash, nextHash); err != nil {
+		return ""
+	}
+	setDeviceCookie(w, next)
+	return device.Email
+}
+
+type DevicesPageData struct {
+	View
+	Email   string
+	Devices []Device
+	Current string
+}
+
+// HandleDevices lists the devices logged in as the friend and lets them log
+// any of them out.
+func HandleDevices(w http.ResponseWriter, r *http.Request) {
+	data := DevicesPageData{Email: friendFromCookie(r)}
+	if len(data.Email) > 0 {
+		devices, err := ListDevices(data.Email)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		if cookie, err := r.Cookie(deviceCookieName); err == nil {
+			hash := hashDeviceToken(cookie.Value)
+			for _, d := range devices {
+				if d.Token == hash || d.Prev == hash {
+					data.Current = d.ID
+				}
+			}
+		}
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil || !checkCSRF(r) {
+				Handle4xx(w, r)
+				return
+			}
+			id := r.PostForm.Get("id")
+			for _, d := range devices {
+				if d.ID == id || id == "all" {
+					if err = DeleteDevice(d.ID); err != nil {
+						Handle500(w, r)
+						return
+					}
+				}
+			}
+			if id == data.Current || id == "all" {
+				clearDeviceCookie(w)
+				clearFriendCookie(w)
+			}
+			if id == "all" {
+				addFlash(w, r, "Logged out everywhere.")
+			} else {
+				addFlash(w, r, "Logged out.")
+			}
+			http.Redirect(w, r, "/devices", http.StatusSeeOther)
+			return
+		}
+		data.Devices = devices
+	}
+
+	render(w, r, "html/devices.html", &data)
+}
@@ -0,0 +1,27 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberDeviceWithoutToken(t *testing.T) {
+	// GIVEN
+	called := false
+	handler := pizza.RememberDevice(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	// WHEN
+	handler.ServeHTTP(rec, req)
+
+	// THEN
+	assert.True(t, called)
+	assert.Empty(t, rec.Result().Cookies())
+}
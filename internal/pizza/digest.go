@@ -0,0 +1,320 @@
+package pizza
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RenderEmail renders the email template static/email/<name>.txt. The
+// template defines a "subject" template alongside the body.
+func RenderEmail(name string, data any) (EmailMessage, error) {
+	plate, err := emailTemplate(name)
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	var subject, body strings.Builder
+	if err = plate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return EmailMessage{}, err
+	}
+	if err = plate.Execute(&body, data); err != nil {
+		return EmailMessage{}, err
+	}
+	return EmailMessage{Subject: strings.TrimSpace(subject.String()), Body: strings.TrimSpace(body.String()) + "\n"}, nil
+}
+
+type DigestFridayData struct {
+	Date      string
+	Deadline  string
+	Closed    bool
+	Headcount int
+	Kids      int
+	Pizzas    int
+	Guests    []string
+	// Birthdays are the guests whose birthday is near the party
+	Birthdays []string
+	// Announcement is the host's note as plain text
+	Announcement string
+	// Notes are the host's notes about the guests, only in the host's digest
+	Notes []DigestNote
+}
+
+type DigestNote struct {
+	Name string
+	Note string
+}
+
+type ToppingCount struct {
+	Topping string `json:"topping"`
+	Votes   int    `json:"votes"`
+}
+
+type DigestData struct {
+	Name           string
+	Fridays        []DigestFridayData
+	Toppings       []ToppingCount
+	RSVPURL        string
+	UnsubscribeURL string
+}
+
+// WithoutNotes is the digest without the host's notes about the guests, for
+// everyone but the host.
+func (d DigestData) WithoutNotes() DigestData {
+	fridays := make([]DigestFridayData, len(d.Fridays))
+	for i, friday := range d.Fridays {
+		friday.Notes = nil
+		fridays[i] = friday
+	}
+	d.Fridays = fridays
+	return d
+}
+
+// ToppingStandings tallies the topping votes across the RSVPs, most popular
+// first.
+func ToppingStandings(rsvps []RSVP) []ToppingCount {
+	votes := make(map[string]int)
+	for _, rsvp := range rsvps {
+		for _, topping := range rsvp.Toppings {
+			votes[topping]++
+		}
+	}
+	standings := make([]ToppingCount, 0, len(votes))
+	for topping, n := range votes {
+		standings = append(standings, ToppingCount{topping, n})
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Votes != standings[j].Votes {
+			return standings[i].Votes > standings[j].Votes
+		}
+		return standings[i].Topping < standings[j].Topping
+	})
+	return standings
+}
+
+// buildDigest gathers the parts of the digest shared by every friend.
+func buildDigest() (DigestData, error) {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		return DigestData{}, err
+	}
+	birthdays, err := ListFriendBirthdays()
+	if err != nil {
+		return DigestData{}, err
+	}
+	data := DigestData{}
+	all := []RSVP{}
+	for _, friday := range fridays {
+		rsvps, err := ListFridayRSVPs(friday.ID())
+		if err != nil {
+			return data, err
+		}
+		rsvps = ConfirmedRSVPs(rsvps)
+		fridayData := DigestFridayData{
+			Date:         FormatTime(friday.Start),
+			Deadline:     FormatTime(friday.Deadline()),
+			Closed:       friday.IsClosed(),
+			Announcement: MarkdownText(FridayAnnouncement(friday)),
+		}
+		for _, rsvp := range rsvps {
+			fridayData.Headcount += rsvp.Guests()
+			fridayData.Kids += rsvp.Kids
+			name, err := GetCachedFriendName(rsvp.Email)
+			if err != nil || len(name) == 0 {
+				name = MaskEmail(rsvp.Email)
+			}
+			fridayData.Guests = append(fridayData.Guests, name)
+			note, err := GetFriendNote(rsvp.Email)
+			if err != nil {
+				return data, err
+			} else if len(note) > 0 {
+				fridayData.Notes = append(fridayData.Notes, DigestNote{name, note})
+			}
+		}
+		for _, email := range BirthdayGuests(friday, rsvps, birthdays) {
+			name, err := GetCachedFriendName(email)
+			if err != nil || len(name) == 0 {
+				name = MaskEmail(email)
+			}
+			fridayData.Birthdays = append(fridayData.Birthdays, name)
+		}
+		fridayData.Pizzas = CountGuests(rsvps).Pizzas()
+		data.Fridays = append(data.Fridays, fridayData)
+		all = append(all, rsvps...)
+	}
+	data.Toppings = ToppingStandings(all)
+	return data, nil
+}
+
+// SendDigests emails the digest to the subscribed friends in the cohort of
+// the hours, see DigestCohort, which is everyone when there is one hour.
+func SendDigests(hours []int, cohort int) {
+	friends, err := ListDigestFriends()
+	if err != nil {
+		Log.Error("failed to list digest friends", zap.Error(err))
+		return
+	}
+	if len(friends) == 0 {
+		return
+	}
+	data, err := buildDigest()
+	if err != nil {
+		Log.Error("failed to build digest", zap.Error(err))
+		return
+	}
+	if len(data.Fridays) == 0 {
+		Log.Info("no upcoming fridays, skipping digest")
+		return
+	}
+	hostEmail := CurrentSettings().HostEmail
+	for _, friend := range friends {
+		if DigestCohort(friend.Email, hours) != cohort {
+			continue
+		}
+		data.Name = friend.Name
+		data.RSVPURL = InviteURL(BaseURL, friend.Email)
+		data.UnsubscribeURL = DigestURL(friend.Email)
+		trackEmail(friend.Email, "digest", &data.RSVPURL)
+		digest := data
+		if len(hostEmail) == 0 || !strings.EqualFold(friend.Email, hostEmail) {
+			digest = data.WithoutNotes()
+		}
+		msg, err := RenderEmail("digest", digest)
+		if err != nil {
+			Log.Error("digest template failure", zap.Error(err))
+			return
+		}
+		msg.To = friend.Email
+		if err = SendEmail(msg); err != nil {
+			Log.Warn("failed to send digest", zap.Error(err), zap.String("email", friend.Email))
+		}
+	}
+}
+
+// WatchDigest sends the digest every week on the day, in the party's local
+// time zone, at each of the hours to its cohort of friends.
+func WatchDigest(ctx context.Context, day time.Weekday, hours []int) {
+	estZone, _ := time.LoadLocation("America/New_York")
+	for {
+		now := time.Now().In(estZone)
+		cohort, next := 0, nextDigest(now, day, hours[0])
+		for i, hour := range hours[1:] {
+			if t := nextDigest(now, day, hour); t.Before(next) {
+				cohort, next = i+1, t
+			}
+		}
+		if !sleepCtx(ctx, time.Until(next)) {
+			return
+		}
+		SendDigests(hours, cohort)
+	}
+}
+
+func nextDigest(now time.Time, day time.Weekday, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	next = next.AddDate(0, 0, (int(day)-int(now.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// ParseWeekday reads a day name such as "Monday".
+func ParseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("unknown weekday %q", name)
+}
+
+// DigestURL is the personal link for managing a friend's digest subscription.
+func DigestURL(email string) string {
+	q := url.Values{}
+	q.Set("email", email)
+	q.Set("sig", SignLink("digest", email))
+	return BaseURL + "/digest?" + q.Encode()
+}
+
+type DigestPageData struct {
+	Email      string
+	Sig        string
+	Subscribed bool
+	// NoTracking is set when the friend opted out of click tracking
+	NoTracking bool
+	Birthday   FriendBirthday
+	Error      string
+	Saved      bool
+	// CSRFToken goes in the form's csrf field, checked by checkBrowserCSRF
+	CSRFToken string
+}
+
+func HandleDigest(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/digest.html")
+	if err != nil {
+		Log.Error("template digest failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := DigestPageData{
+		Email: strings.ToLower(r.Form.Get("email")),
+		Sig:   r.Form.Get("sig"),
+	}
+	if !VerifyLink(data.Sig, "digest", data.Email) {
+		Handle4xx(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !checkBrowserCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		data.Subscribed = r.PostForm.Get("subscribe") == "on"
+		data.NoTracking = r.PostForm.Get("tracking") != "on"
+		if err = SetFriendDigest(data.Email, data.Subscribed); err != nil {
+			Handle500(w, r)
+			return
+		}
+		if err = SetFriendNoTracking(data.Email, data.NoTracking); err != nil {
+			Handle500(w, r)
+			return
+		}
+		data.Birthday.NoCelebrate = r.PostForm.Get("celebrate") != "on"
+		if data.Birthday.Birthday, err = ParseBirthday(strings.TrimSpace(r.PostForm.Get("birthday"))); err != nil {
+			data.Error = err.Error()
+			data.Birthday.Birthday = r.PostForm.Get("birthday")
+		} else if err = SetFriendBirthday(data.Email, data.Birthday.Birthday, data.Birthday.NoCelebrate); err != nil {
+			Handle500(w, r)
+			return
+		} else {
+			data.Saved = true
+		}
+	} else if data.Subscribed, err = GetFriendDigest(data.Email); err != nil {
+		Handle500(w, r)
+		return
+	} else if data.NoTracking, err = GetFriendNoTracking(data.Email); err != nil {
+		Handle500(w, r)
+		return
+	} else if data.Birthday, err = GetFriendBirthday(data.Email); err != nil {
+		Handle500(w, r)
+		return
+	}
+
+	data.CSRFToken = browserCSRFToken(w, r)
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToppingStandings(t *testing.T) {
+	// GIVEN
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", Toppings: []string{"pepperoni", "mushroom"}},
+		{Email: "coach@tedlasso.com", Toppings: []string{"pepperoni"}},
+		{Email: "roy@tedlasso.com", Toppings: []string{"anchovy"}},
+		{Email: "keeley@tedlasso.com"},
+	}
+
+	// WHEN
+	standings := pizza.ToppingStandings(rsvps)
+
+	// THEN
+	assert.Equal(t, []pizza.ToppingCount{
+		{Topping: "pepperoni", Votes: 2},
+		{Topping: "anchovy", Votes: 1},
+		{Topping: "mushroom", Votes: 1},
+	}, standings)
+}
+
+func TestRenderDigest(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	data := pizza.DigestData{
+		Name: "Ted Lasso",
+		Fridays: []pizza.DigestFridayData{
+			{Date: "07 Apr 23 17:30 EDT", Deadline: "07 Apr 23 15:30 EDT", Headcount: 3, Kids: 1, Pizzas: 2, Guests: []string{"Ted Lasso", "Coach Beard"}},
+			{Date: "14 Apr 23 17:30 EDT", Deadline: "14 Apr 23 15:30 EDT", Announcement: "Bring a chair"},
+		},
+		Toppings:       []pizza.ToppingCount{{Topping: "pepperoni", Votes: 2}},
+		RSVPURL:        "https://rsvp.pizza/?invite=believe%40tedlasso.com",
+		UnsubscribeURL: "https://rsvp.pizza/digest?email=believe%40tedlasso.com",
+	}
+
+	// WHEN
+	msg, err := pizza.RenderEmail("digest", data)
+
+	// THEN
+	require.Nil(t, err)
+	assert.Equal(t, "This week at Pizza Friday", msg.Subject)
+	assert.Contains(t, msg.Body, "Hi Ted Lasso,")
+	assert.Contains(t, msg.Body, "07 Apr 23 17:30 EDT (RSVP by 07 Apr 23 15:30 EDT)\n  3 going (kids: 1), about 2 pizzas: Ted Lasso, Coach Beard\n")
+	assert.Contains(t, msg.Body, "14 Apr 23 17:30 EDT (RSVP by 14 Apr 23 15:30 EDT)\n  0 going\n\nBring a chair\n")
+	assert.Contains(t, msg.Body, "Topping poll:\n  pepperoni: 2\n")
+	assert.Contains(t, msg.Body, data.UnsubscribeURL)
+}
+
+func TestRenderDigestNotes(t *testing.T) {
+	// GIVEN the host's note about a guest coming
+	pizza.StaticDir = "../../static"
+	data := pizza.DigestData{
+		Name: "Rebecca Welton",
+		Fridays: []pizza.DigestFridayData{
+			{Date: "07 Apr 23 17:30 EDT", Deadline: "07 Apr 23 15:30 EDT", Headcount: 1, Guests: []string{"Ted Lasso"},
+				Notes: []pizza.DigestNote{{Name: "Ted Lasso", Note: "allergic to shellfish"}}},
+		},
+	}
+
+	// WHEN
+	host, err := pizza.RenderEmail("digest", data)
+	require.Nil(t, err)
+	friend, err := pizza.RenderEmail("digest", data.WithoutNotes())
+	require.Nil(t, err)
+
+	// THEN only the host's digest has the note
+	assert.Contains(t, host.Body, "1 going: Ted Lasso\n  Your note on Ted Lasso: allergic to shellfish\n")
+	assert.NotContains(t, friend.Body, "shellfish")
+	assert.Len(t, data.Fridays[0].Notes, 1)
+}
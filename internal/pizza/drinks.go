@@ -0,0 +1,66 @@
+package pizza
+
+import (
+	"strings"
+)
+
+// DrinkCategories are offered on the admin page when the host asks guests to
+// coordinate drinks for an event.
+var DrinkCategories = []string{"beer", "wine", "soda"}
+
+// MaxDrinks is the most of one kind of drink a guest may say they're bringing.
+var MaxDrinks = 24
+
+// DrinkTally is what the guests are bringing of one kind of drink.
+type DrinkTally struct {
+	Category string
+	Count    int
+	Bringers []string
+	// Mine is how many the friend looking at the tally is bringing
+	Mine int
+}
+
+// TallyDrinks adds up what the confirmed guests are bringing for each of the
+// event's drink categories. Names are keyed by email, and email is the friend
+// looking at the tally.
+func TallyDrinks(friday Friday, rsvps []RSVP, names map[string]string, email string) []DrinkTally {
+	tallies := make([]DrinkTally, len(friday.Drinks))
+	for i, category := range friday.Drinks {
+		tallies[i].Category = category
+		for _, rsvp := range ConfirmedRSVPs(rsvps) {
+			n := rsvp.Drinks[category]
+			if n <= 0 {
+				continue
+			}
+			tallies[i].Count += n
+			if rsvp.Email == email {
+				tallies[i].Mine = n
+			}
+			name := names[rsvp.Email]
+			if len(name) == 0 {
+				name = MaskEmail(rsvp.Email)
+			}
+			tallies[i].Bringers = append(tallies[i].Bringers, name)
+		}
+	}
+	return tallies
+}
+
+// ParseDrinkCategories reads the comma separated categories from the admin
+// page, leaving out blanks and repeats.
+func ParseDrinkCategories(s string) []string {
+	return parseList(s)
+}
+
+// parseList splits a comma separated list typed by the host into lower case
+// entries, leaving out blanks and repeats.
+func parseList(s string) []string {
+	var entries []string
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if len(entry) > 0 && !containsString(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
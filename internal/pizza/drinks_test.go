@@ -0,0 +1,33 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTallyDrinks(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Drinks: []string{"beer", "soda"}}
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", Drinks: map[string]int{"beer": 6, "soda": 2}},
+		{Email: "roy@kent.com", Drinks: map[string]int{"beer": 12, "wine": 1}},
+		{Email: "jamie@tartt.com", Drinks: map[string]int{"soda": 4}, Status: pizza.RSVPStatusPending},
+	}
+	names := map[string]string{"believe@tedlasso.com": "Ted Lasso"}
+
+	// WHEN
+	tallies := pizza.TallyDrinks(friday, rsvps, names, "believe@tedlasso.com")
+
+	// THEN only the event's categories and confirmed guests count
+	assert.Equal(t, []pizza.DrinkTally{
+		{Category: "beer", Count: 18, Bringers: []string{"Ted Lasso", "r**@kent.com"}, Mine: 6},
+		{Category: "soda", Count: 2, Bringers: []string{"Ted Lasso"}, Mine: 2},
+	}, tallies)
+}
+
+func TestParseDrinkCategories(t *testing.T) {
+	assert.Equal(t, []string{"beer", "cider"}, pizza.ParseDrinkCategories(" Beer, cider,, beer "))
+	assert.Nil(t, pizza.ParseDrinkCategories(" , "))
+}
@@ -0,0 +1,240 @@
+package pizza
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Plan is what an admin change would do, reported instead of doing it when
+// the request asks for a dry run with ?dry_run=true.
+type Plan struct {
+	Action string `json:"action"`
+	// Store, Calendar, and Notifications list the changes to each, one per
+	// line
+	Store         []string `json:"store"`
+	Calendar      []string `json:"calendar"`
+	Notifications []string `json:"notifications"`
+}
+
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return dryRun
+}
+
+// PlanCreateEvent is what CreateEvent would do with the event. It returns the
+// same errors.
+func PlanCreateEvent(in EventInput) (Plan, error) {
+	plan := Plan{Action: "add event"}
+	friday, err := newEvent(in)
+	if err != nil {
+		return plan, err
+	}
+	line := "add the event from " + FormatTime(friday.Start) + " to " + FormatTime(friday.EndTime())
+	if friday.Capacity > 0 {
+		line += " for " + strconv.Itoa(friday.Capacity) + " guests"
+	}
+	plan.Store = append(plan.Store, line)
+	if len(friday.Drinks) > 0 {
+		plan.Store = append(plan.Store, "coordinate drinks: "+strings.Join(friday.Drinks, ", "))
+	}
+	if len(friday.Announcement) > 0 {
+		plan.Store = append(plan.Store, "announce: "+friday.Announcement)
+	}
+	if MirrorEnabled() {
+		plan.Calendar = append(plan.Calendar, "add the public copy of the event to the mirror calendar")
+	}
+	return plan, nil
+}
+
+// PlanDeleteFriday is what deleting the event would do. It returns
+// ErrRSVPNotFound if there is no event with the ID.
+func PlanDeleteFriday(id string) (Plan, error) {
+	plan := Plan{Action: "delete event"}
+	friday, err := GetFriday(id)
+	if err != nil {
+		return plan, err
+	} else if friday == nil {
+		return plan, ErrRSVPNotFound
+	}
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		return plan, err
+	}
+	plan.Store = append(plan.Store, "delete the event on "+FormatTime(friday.Start))
+	var guests []string
+	for _, rsvp := range rsvps {
+		plan.Store = append(plan.Store, fmt.Sprintf("delete the %s RSVP of %s for %d", rsvp.Status, rsvp.Email, rsvp.Guests()))
+		if rsvp.Confirmed() && !rsvp.InvitePending {
+			guests = append(guests, rsvp.Email)
+		}
+	}
+	plan.Calendar = append(plan.Calendar, "delete calendar event "+id)
+	if MirrorEnabled() {
+		plan.Calendar = append(plan.Calendar, "delete the public copy of the event from the mirror calendar")
+	}
+	if len(guests) > 0 {
+		plan.Notifications = append(plan.Notifications, "the calendar emails the cancellation to "+strings.Join(guests, ", "))
+	}
+	return plan, nil
+}
+
+// PlanAddFriend is what adding the friend would do.
+func PlanAddFriend(name, email string) Plan {
+	return Plan{Action: "add friend", Store: []string{"add friend " + name + " <" + email + ">"}}
+}
+
+// PlanRemoveFriend is what removing the friend, keeping their RSVPs, would do.
+func PlanRemoveFriend(email string) (Plan, error) {
+	plan := Plan{Action: "remove friend"}
+	rsvps, err := ListFriendRSVPs(email)
+	if err != nil {
+		return plan, err
+	}
+	plan.Store = append(plan.Store, fmt.Sprintf("remove friend %s, keeping their %d RSVPs", email, len(rsvps)))
+	return plan, nil
+}
+
+// PlanPurgeFriend is what removing the friend with their RSVPs would do,
+// including the waitlisted friends let in to the spots they free.
+func PlanPurgeFriend(email string) (Plan, error) {
+	plan := Plan{Action: "purge friend"}
+	rsvps, err := ListFriendRSVPs(email)
+	if err != nil {
+		return plan, err
+	}
+	for _, rsvp := range rsvps {
+		plan.Store = append(plan.Store, fmt.Sprintf("delete the %s RSVP to %s for %d", rsvp.Status, rsvp.FridayID, rsvp.Guests()))
+		plan.Calendar = append(plan.Calendar, "remove "+email+" from calendar event "+rsvp.FridayID)
+		friday, ok, err := GetCachedFriday(UpcomingDays, rsvp.FridayID)
+		if err != nil {
+			return plan, err
+		} else if !ok {
+			continue
+		}
+		all, err := ListFridayRSVPs(rsvp.FridayID)
+		if err != nil {
+			return plan, err
+		}
+		others := []RSVP{}
+		for _, other := range all {
+			if other.ID != rsvp.ID {
+				others = append(others, other)
+			}
+		}
+		if Waitlist {
+			for _, promoted := range WaitlistPromotions(others, friday.Limit()) {
+				plan.Store = append(plan.Store, "let in the waitlisted RSVP of "+promoted.Email+" to "+rsvp.FridayID)
+				plan.Calendar = append(plan.Calendar, "invite "+promoted.Email+" to calendar event "+rsvp.FridayID)
+				plan.Notifications = append(plan.Notifications, "email "+promoted.Email+" they're in on "+FormatTime(friday.Start))
+			}
+		}
+		plan.Notifications = append(plan.Notifications, "offer any spots left on "+FormatTime(friday.Start)+" to friends waiting for one")
+	}
+	plan.Store = append(plan.Store, "delete friend "+email+" with their notify requests, reactions, passkeys, devices, and email events")
+	return plan, nil
+}
+
+// PlanMergeFriends is what merging the duplicate friend into the primary
+// friend would do.
+func PlanMergeFriends(primary, duplicate string) (Plan, error) {
+	plan := Plan{Action: "merge friends"}
+	if primary == duplicate {
+		return plan, nil
+	}
+	rsvps, err := ListFriendRSVPs(duplicate)
+	if err != nil {
+		return plan, err
+	}
+	for _, rsvp := range rsvps {
+		others, err := ListFridayRSVPs(rsvp.FridayID)
+		if err != nil {
+			return plan, err
+		}
+		if hasRSVP(others, primary) {
+			plan.Store = append(plan.Store, fmt.Sprintf("delete the %s RSVP of %s to %s, which %s already answered", rsvp.Status, duplicate, rsvp.FridayID, primary))
+		} else {
+			plan.Store = append(plan.Store, fmt.Sprintf("move the %s RSVP of %s to %s over to %s", rsvp.Status, duplicate, rsvp.FridayID, primary))
+		}
+		plan.Calendar = append(plan.Calendar, "replace "+duplicate+" with "+primary+" on calendar event "+rsvp.FridayID)
+	}
+	plan.Store = append(plan.Store, "delete friend "+duplicate)
+	return plan, nil
+}
+
+// PlanTransfer is what offering the series to the next owner would do.
+func PlanTransfer(to string) Plan {
+	return Plan{
+		Action:        "transfer ownership",
+		Store:         []string{"offer the series to " + to + " until " + FormatTime(time.Now().Add(TransferTTL))},
+		Notifications: []string{"email " + to + " a link to accept the series"},
+	}
+}
+
+// PlanCancelTransfer is what taking back the offer to the next owner would do.
+func PlanCancelTransfer(to string) Plan {
+	return Plan{Action: "cancel transfer", Store: []string{"take back the offer of the series to " + to}}
+}
+
+// PlanSettings is what SaveSettingOverrides would change. It returns the same
+// errors.
+func PlanSettings(overrides map[string]string) (Plan, error) {
+	plan := Plan{Action: "save settings"}
+	current, err := settingsCache.Get("runtime")
+	if err != nil {
+		return plan, err
+	}
+	for name := range overrides {
+		if _, ok := findSetting(name); !ok {
+			return plan, ErrSettingInvalid
+		}
+	}
+	for _, setting := range Settings {
+		value, ok := overrides[setting.Name]
+		if value == current[setting.Name] {
+			continue
+		}
+		to := value
+		if !ok {
+			to = fileSettings[setting.Name] + " from the config file"
+		} else if _, err := setting.parse(value); err != nil {
+			return plan, err
+		}
+		plan.Store = append(plan.Store, fmt.Sprintf("change %s from %s to %s", setting.Name, setting.Value(), to))
+	}
+	return plan, nil
+}
+
+type AdminDryRunPageData struct {
+	AdminView
+	Plan
+	// URL and Fields send the same form again to make the changes
+	URL    string
+	Fields []AdminConfirmField
+}
+
+// writePlan shows the admin what their form would change, with a button to go
+// ahead.
+func writePlan(w http.ResponseWriter, r *http.Request, plan Plan) {
+	plate, err := parseTemplate("html/admin/dryrun.html")
+	if err != nil {
+		Log.Error("template admin dry run failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	Log.Info("admin dry run", zap.String("action", plan.Action))
+	u := *r.URL
+	q := u.Query()
+	q.Del("dry_run")
+	u.RawQuery = q.Encode()
+	data := AdminDryRunPageData{AdminView: newAdminView(w, r), Plan: plan, URL: u.RequestURI(), Fields: formFields(r)}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
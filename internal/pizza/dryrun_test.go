@@ -0,0 +1,43 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanCreateEventInvalid(t *testing.T) {
+	// WHEN
+	_, err := pizza.PlanCreateEvent(pizza.EventInput{Start: time.Now().Add(-time.Hour).Truncate(time.Minute)})
+
+	// THEN a dry run turns down the same events
+	assert.Equal(t, pizza.ErrEventInvalid, err)
+}
+
+func TestWritePlan(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	plan := pizza.PlanAddFriend("Roy Kent", "roy@kent.com")
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/admin/friends?dry_run=true", strings.NewReader("action=add&name=Roy+Kent&email=roy%40kent.com"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	require.Nil(t, r.ParseForm())
+
+	// WHEN
+	pizza.WritePlan(w, r, plan)
+
+	// THEN the changes are listed, and going ahead sends the form again
+	// without the dry run
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "add friend Roy Kent &lt;roy@kent.com&gt;")
+	assert.Contains(t, body, `action="/admin/friends"`)
+	assert.Contains(t, body, `name="name" value="Roy Kent"`)
+	assert.Contains(t, body, `name="action" value="add"`)
+}
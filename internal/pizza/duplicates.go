@@ -0,0 +1,111 @@
+package pizza
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// CanonicalEmail is the mailbox an email address delivers to. Gmail ignores
+// dots in the name, and most providers ignore anything after a plus.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+func canonicalName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// FindDuplicateFriends groups the friends that are likely the same person
+// because they share a name or a mailbox. Friends without duplicates are left
+// out.
+func FindDuplicateFriends(friends []Friend) [][]Friend {
+	// union friends that share a key
+	parent := make([]int, len(friends))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	seen := map[string]int{}
+	for i, friend := range friends {
+		keys := []string{"email:" + CanonicalEmail(friend.Email)}
+		if name := canonicalName(friend.Name); len(name) > 0 {
+			keys = append(keys, "name:"+name)
+		}
+		for _, key := range keys {
+			if j, ok := seen[key]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				seen[key] = i
+			}
+		}
+	}
+
+	groups := map[int][]Friend{}
+	order := []int{}
+	for i, friend := range friends {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], friend)
+	}
+	duplicates := [][]Friend{}
+	for _, root := range order {
+		if len(groups[root]) > 1 {
+			duplicates = append(duplicates, groups[root])
+		}
+	}
+	return duplicates
+}
+
+// MergeFriends moves the duplicate friend's RSVPs to the primary friend,
+// updating the calendar invites, and then removes the duplicate. An RSVP for
+// a Friday the primary friend already answered is dropped.
+func MergeFriends(primary, duplicate string) error {
+	if primary == duplicate {
+		return nil
+	}
+	name, _ := GetFriendName(primary)
+	rsvps, err := ListFriendRSVPs(duplicate)
+	if err != nil {
+		return err
+	}
+	for _, rsvp := range rsvps {
+		others, err := ListFridayRSVPs(rsvp.FridayID)
+		if err != nil {
+			return err
+		}
+		if hasRSVP(others, primary) {
+			err = DeleteFridayRSVP(rsvp.ID)
+		} else {
+			rsvp.Email = primary
+			err = UpdateFridayRSVP(rsvp)
+		}
+		if err != nil {
+			return err
+		}
+		if err = ReplaceCalendarAttendee(rsvp.FridayID, duplicate, primary, name); err != nil {
+			Log.Warn("failed to update calendar attendee", zap.Error(err), zap.String("eventID", rsvp.FridayID))
+		}
+		rsvpsChanged(ChangeRSVPUpdated, rsvp.FridayID)
+	}
+	Log.Info("merged friends", zap.String("primary", primary), zap.String("duplicate", duplicate))
+	return DeleteFriend(duplicate)
+}
@@ -0,0 +1,32 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalEmail(t *testing.T) {
+	assert.Equal(t, "tedlasso@gmail.com", pizza.CanonicalEmail("Ted.Lasso+pizza@googlemail.com"))
+	assert.Equal(t, "ted.lasso@afcrichmond.com", pizza.CanonicalEmail("ted.lasso+pizza@afcrichmond.com"))
+	assert.Equal(t, "not-an-email", pizza.CanonicalEmail("not-an-email"))
+}
+
+func TestFindDuplicateFriends(t *testing.T) {
+	// GIVEN
+	friends := []pizza.Friend{
+		{Name: "Ted Lasso", Email: "ted.lasso@gmail.com"},
+		{Name: "Roy Kent", Email: "roy@afcrichmond.com"},
+		{Name: "Coach", Email: "tedlasso@gmail.com"},
+		{Name: "coach ", Email: "beard@afcrichmond.com"},
+		{Name: "", Email: "keeley@afcrichmond.com"},
+		{Name: "", Email: "rebecca@afcrichmond.com"},
+	}
+
+	// WHEN
+	groups := pizza.FindDuplicateFriends(friends)
+
+	// THEN
+	assert.Equal(t, [][]pizza.Friend{{friends[0], friends[2], friends[3]}}, groups)
+}
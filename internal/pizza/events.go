@@ -0,0 +1,282 @@
+package pizza
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// EventsHookSecret signs requests to the events webhook, which is off while it
+// is empty.
+var EventsHookSecret = ""
+
+// EventsHookMaxSkew is how far the timestamp of a webhook request may be from
+// now, so old requests can't be replayed.
+var EventsHookMaxSkew = 5 * time.Minute
+
+// PartyHour and PartyMinute are when parties usually start, in New York time.
+var (
+	PartyHour   = 18
+	PartyMinute = 0
+)
+
+// MaxEventDays is how many days an event may run, so a weekend away can be a
+// single event with an RSVP for each day.
+var MaxEventDays = 3
+
+// eventDayRollover is how long past midnight an event can go on and still
+// belong to the day before, so a late night isn't a second day.
+const eventDayRollover = 6 * time.Hour
+
+var (
+	ErrEventInvalid = errors.New("event is not valid")
+	ErrEventExists  = errors.New("an event already starts at that time")
+)
+
+// EventInput is a new event, from the admin page or a webhook.
+type EventInput struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Announcement string    `json:"announcement"`
+	Capacity     int       `json:"capacity"`
+	// Drinks turns on drinks coordination with these categories
+	Drinks []string `json:"drinks"`
+}
+
+// eventIDEncoding is base32hex in lower case, the only characters Google
+// Calendar allows in event IDs.
+var eventIDEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+
+// NewEventID makes a ULID for a new event: 48 bits of milliseconds since the
+// epoch followed by 80 random bits. It's written in base32hex instead of
+// Crockford's base32 so it can also be the calendar event's ID, and IDs still
+// sort by when they were made.
+func NewEventID(now time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(now.UnixMilli())<<16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		panic(fmt.Sprintf("could not generate event id: %v", err))
+	}
+	return eventIDEncoding.EncodeToString(id[:])
+}
+
+// ValidateEvent checks a new event. Events start on the minute, sometime in
+// the next year, and last at most MaxEventDays days.
+func ValidateEvent(in EventInput, now time.Time) error {
+	switch {
+	case in.Start.IsZero() || in.Start.Second() != 0 || in.Start.Nanosecond() != 0:
+		return ErrEventInvalid
+	case !in.Start.After(now) || in.Start.After(now.AddDate(1, 0, 0)):
+		return ErrEventInvalid
+	case !in.End.IsZero() && (!in.End.After(in.Start) || in.End.Sub(in.Start) > time.Duration(MaxEventDays)*24*time.Hour):
+		return ErrEventInvalid
+	case in.Capacity < 0 || utf8.RuneCountInString(in.Announcement) > 2000:
+		return ErrEventInvalid
+	case len(in.Drinks) > 10:
+		return ErrEventInvalid
+	}
+	for _, category := range in.Drinks {
+		if len(strings.TrimSpace(category)) == 0 || utf8.RuneCountInString(category) > 40 {
+			return ErrEventInvalid
+		}
+	}
+	return nil
+}
+
+// newEvent validates a new event and checks none starts at the same time.
+func newEvent(in EventInput) (Friday, error) {
+	if err := ValidateEvent(in, time.Now()); err != nil {
+		return Friday{}, err
+	}
+	friday := Friday{
+		UID:          NewEventID(time.Now()),
+		Start:        in.Start,
+		End:          in.End,
+		Announcement: strings.TrimSpace(in.Announcement),
+		Capacity:     in.Capacity,
+		Drinks:       in.Drinks,
+	}
+	existing, err := GetFridayAt(friday.Start)
+	if err != nil {
+		return Friday{}, err
+	} else if existing != nil {
+		return Friday{}, ErrEventExists
+	}
+	return friday, nil
+}
+
+// CreateEvent validates and adds a new event.
+func CreateEvent(in EventInput) (*Friday, error) {
+	friday, err := newEvent(in)
+	if err != nil {
+		return nil, err
+	}
+	if err = CreateFriday(friday); err != nil {
+		return nil, err
+	}
+	fridayCache.Clear()
+	apiFridaysCache.Clear()
+	if MirrorEnabled() {
+		go func() {
+			if err := MirrorFriday(friday); err != nil {
+				Log.Warn("failed to mirror event", zap.Error(err), zap.String("eventID", friday.ID()))
+			}
+		}()
+	}
+	return &friday, nil
+}
+
+// EventDay is one day of an event, in New York time.
+type EventDay struct {
+	// Date is like 2023-04-07, and is what RSVPs store
+	Date  string
+	Label string
+}
+
+// Days lists the days the event runs on. An event that ends early in the
+// morning doesn't run on that day, so most events have one day.
+func (f Friday) Days() []EventDay {
+	estZone, _ := time.LoadLocation("America/New_York")
+	start := f.Start.In(estZone)
+	end := f.EndTime().In(estZone).Add(-eventDayRollover)
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, estZone)
+	var days []EventDay
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, estZone); len(days) == 0 || !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, EventDay{Date: day.Format("2006-01-02"), Label: day.Format("Mon Jan 2")})
+	}
+	return days
+}
+
+// MultiDay reports whether the event runs on more than one day, so friends
+// pick which days they're coming.
+func (f Friday) MultiDay() bool {
+	return len(f.Days()) > 1
+}
+
+// EventDays checks the days a friend picked for the event. It returns nil,
+// meaning every day, when they picked none or all of them, and false if any
+// isn't a day of the event.
+func EventDays(friday Friday, picked []string) ([]string, bool) {
+	days := friday.Days()
+	seen := map[string]bool{}
+	for _, date := range picked {
+		found := false
+		for _, day := range days {
+			found = found || day.Date == date
+		}
+		if !found || seen[date] {
+			return nil, false
+		}
+		seen[date] = true
+	}
+	if len(picked) == len(days) {
+		return nil, true
+	}
+	return picked, true
+}
+
+// NextFridays returns the next n Fridays after from that start at hour:minute
+// local time in loc. Weeks are stepped on the calendar instead of adding 168
+// hours, so the Friday after the clocks change still starts at the same local
+// time. A time that is skipped when the clocks go forward is moved later by the
+// length of the jump, as time.Date does.
+func NextFridays(from time.Time, loc *time.Location, hour, minute, n int) []time.Time {
+	local := from.In(loc)
+	days := (int(time.Friday) - int(local.Weekday()) + 7) % 7
+	year, month, day := local.Date()
+	next := time.Date(year, month, day+days, hour, minute, 0, 0, loc)
+	if !next.After(from) {
+		days += 7
+	}
+	fridays := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		fridays = append(fridays, time.Date(year, month, day+days+7*i, hour, minute, 0, 0, loc))
+	}
+	return fridays
+}
+
+// VerifyEventsHook checks the X-Pizza-Signature header, "sha256=" and the hex
+// HMAC of the X-Pizza-Timestamp header, a dot, and the body.
+func VerifyEventsHook(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > EventsHookMaxSkew || skew < -EventsHookMaxSkew {
+		return false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+type EventsHookResponse struct {
+	ID    string    `json:"id"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// HandleEventsHook lets trusted automations, like a poll bot, create events.
+// With ?dry_run=true it answers with the plan for the event instead.
+func HandleEventsHook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if bodyTooLarge(err) {
+		writeTooLarge(w, r)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "malformed request body")
+		return
+	}
+	if !VerifyEventsHook(EventsHookSecret, r.Header.Get("X-Pizza-Timestamp"), r.Header.Get("X-Pizza-Signature"), body, time.Now()) {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var in EventInput
+	if err = json.Unmarshal(body, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "malformed request body")
+		return
+	}
+	var friday *Friday
+	var plan Plan
+	dryRun := isDryRun(r)
+	if dryRun {
+		plan, err = PlanCreateEvent(in)
+	} else {
+		friday, err = CreateEvent(in)
+	}
+	switch {
+	case err == nil && dryRun:
+		writeJSON(w, http.StatusOK, plan)
+	case err == nil:
+		Log.Info("event created by webhook", zap.String("id", friday.ID()))
+		writeJSON(w, http.StatusCreated, EventsHookResponse{ID: friday.ID(), Start: friday.Start, End: friday.EndTime()})
+	case err == ErrEventInvalid:
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+	case err == ErrEventExists:
+		writeAPIError(w, http.StatusConflict, err.Error())
+	default:
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+	}
+}
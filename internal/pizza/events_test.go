@@ -0,0 +1,185 @@
+package pizza_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEvent(t *testing.T) {
+	// GIVEN
+	now := time.Date(2023, 4, 3, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)
+
+	// THEN
+	assert.NoError(t, pizza.ValidateEvent(pizza.EventInput{Start: start}, now))
+	assert.NoError(t, pizza.ValidateEvent(pizza.EventInput{Start: start, End: start.Add(4 * time.Hour), Capacity: 12}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start.Add(time.Second)}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: now.Add(-time.Hour).Truncate(time.Minute)}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start.AddDate(2, 0, 0)}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start, End: start}, now))
+	assert.NoError(t, pizza.ValidateEvent(pizza.EventInput{Start: start, End: start.Add(40 * time.Hour)}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start, End: start.Add(73 * time.Hour)}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start, Capacity: -1}, now))
+	assert.NoError(t, pizza.ValidateEvent(pizza.EventInput{Start: start, Drinks: []string{"beer", "wine"}}, now))
+	assert.Equal(t, pizza.ErrEventInvalid, pizza.ValidateEvent(pizza.EventInput{Start: start, Drinks: []string{" "}}, now))
+}
+
+func TestNewEventID(t *testing.T) {
+	// GIVEN
+	now := time.Date(2023, 4, 3, 12, 0, 0, 0, time.UTC)
+
+	// WHEN
+	first := pizza.NewEventID(now)
+	again := pizza.NewEventID(now)
+	later := pizza.NewEventID(now.Add(time.Millisecond))
+
+	// THEN IDs are valid calendar event IDs that sort by creation time
+	assert.Regexp(t, "^[0-9a-v]{26}$", first)
+	assert.NotEqual(t, first, again)
+	assert.Equal(t, first[:9], again[:9])
+	assert.Less(t, first, later)
+	assert.Less(t, again, later)
+}
+
+func TestFridayIDFallsBackToStart(t *testing.T) {
+	// GIVEN
+	start := time.Unix(1680903000, 0)
+	legacy := pizza.Friday{Start: start}
+	migrated := pizza.Friday{UID: "1680903000", Start: start.Add(time.Hour)}
+	created := pizza.Friday{UID: pizza.NewEventID(start), Start: start}
+
+	// THEN rescheduling doesn't change an ID once it's stored
+	assert.Equal(t, "1680903000", legacy.ID())
+	assert.Equal(t, "1680903000", migrated.ID())
+	assert.Equal(t, created.UID, created.ID())
+}
+
+func TestEventDays(t *testing.T) {
+	// GIVEN
+	est, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	evening := pizza.Friday{Start: time.Date(2023, 4, 7, 18, 0, 0, 0, est), End: time.Date(2023, 4, 8, 1, 0, 0, 0, est)}
+	weekend := pizza.Friday{Start: time.Date(2023, 4, 7, 18, 0, 0, 0, est), End: time.Date(2023, 4, 9, 11, 0, 0, 0, est)}
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 1},
+		{Email: "roy@kent.com", Days: []string{"2023-04-08", "2023-04-09"}},
+		{Email: "jamie@tartt.com", Days: []string{"2023-04-09"}, Status: pizza.RSVPStatusPending},
+	}
+
+	// WHEN
+	days, ok := pizza.EventDays(weekend, []string{"2023-04-09", "2023-04-08"})
+	all, allOK := pizza.EventDays(weekend, []string{"2023-04-07", "2023-04-08", "2023-04-09"})
+	_, badOK := pizza.EventDays(weekend, []string{"2023-04-10"})
+	_, twiceOK := pizza.EventDays(weekend, []string{"2023-04-08", "2023-04-08"})
+
+	// THEN a late night is still one day
+	assert.False(t, evening.MultiDay())
+	assert.Equal(t, []pizza.EventDay{{Date: "2023-04-07", Label: "Fri Apr 7"}}, evening.Days())
+	assert.True(t, weekend.MultiDay())
+	assert.Len(t, weekend.Days(), 3)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"2023-04-09", "2023-04-08"}, days)
+	assert.True(t, allOK)
+	assert.Nil(t, all)
+	assert.False(t, badOK)
+	assert.False(t, twiceOK)
+	counts := pizza.DayHeadcounts(weekend, rsvps)
+	assert.Equal(t, []int{2, 3, 3}, []int{counts[0].Headcount, counts[1].Headcount, counts[2].Headcount})
+}
+
+func TestVerifyEventsHook(t *testing.T) {
+	// GIVEN
+	now := time.Unix(1680903000, 0)
+	body := []byte(`{"start":"2023-04-14T21:30:00Z"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1680903000."))
+	mac.Write(body)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	// THEN
+	assert.True(t, pizza.VerifyEventsHook("secret", "1680903000", sig, body, now))
+	assert.False(t, pizza.VerifyEventsHook("", "1680903000", sig, body, now))
+	assert.False(t, pizza.VerifyEventsHook("other", "1680903000", sig, body, now))
+	assert.False(t, pizza.VerifyEventsHook("secret", "1680903001", sig, body, now))
+	assert.False(t, pizza.VerifyEventsHook("secret", "1680903000", sig, []byte(`{}`), now))
+	assert.False(t, pizza.VerifyEventsHook("secret", "1680903000", sig, body, now.Add(time.Hour)))
+}
+
+func TestNextFridaysKeepsLocalTimeAcrossDST(t *testing.T) {
+	// GIVEN clocks go forward in New York on Sunday, 12 March 2023
+	estZone, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	from := time.Date(2023, 3, 6, 12, 0, 0, 0, estZone)
+
+	// WHEN
+	fridays := pizza.NextFridays(from, estZone, 18, 0, 2)
+
+	// THEN both start at 6pm, 167 hours apart
+	assert.Equal(t, time.Date(2023, 3, 10, 23, 0, 0, 0, time.UTC), fridays[0].UTC())
+	assert.Equal(t, time.Date(2023, 3, 17, 22, 0, 0, 0, time.UTC), fridays[1].UTC())
+	assert.Equal(t, 167*time.Hour, fridays[1].Sub(fridays[0]))
+}
+
+func TestNextFridaysProperties(t *testing.T) {
+	zones := []string{
+		"America/New_York",
+		"America/Los_Angeles",
+		"Europe/London",
+		"Australia/Sydney",
+		"Australia/Lord_Howe",
+		"America/Sao_Paulo",
+		"Asia/Kolkata",
+		"UTC",
+	}
+	for _, name := range zones {
+		loc, err := time.LoadLocation(name)
+		if !assert.Nil(t, err, name) {
+			continue
+		}
+		for _, at := range [][2]int{{18, 0}, {19, 30}, {0, 0}} {
+			// GIVEN times every 17 hours over two years, hitting every hour and weekday
+			for from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC); from.Year() < 2025; from = from.Add(17 * time.Hour) {
+				// WHEN
+				fridays := pizza.NextFridays(from, loc, at[0], at[1], 3)
+
+				// THEN
+				assert.Len(t, fridays, 3)
+				assert.True(t, fridays[0].After(from), "%s %v", name, from)
+				assert.True(t, fridays[0].Sub(from) <= 8*24*time.Hour, "%s %v", name, from)
+				for i, friday := range fridays {
+					local := friday.In(loc)
+					assert.Equal(t, time.Friday, local.Weekday(), "%s %v", name, from)
+					assert.Equal(t, at[0], local.Hour(), "%s %v", name, friday)
+					assert.Equal(t, at[1], local.Minute(), "%s %v", name, friday)
+					if i > 0 {
+						y1, m1, d1 := fridays[i-1].In(loc).Date()
+						assert.Equal(t, time.Date(y1, m1, d1+7, 0, 0, 0, 0, time.UTC), time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFridayICalEventAfterDST(t *testing.T) {
+	// GIVEN the first party after clocks go back in New York
+	estZone, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	start := pizza.NextFridays(time.Date(2023, 11, 4, 12, 0, 0, 0, estZone), estZone, 18, 0, 1)[0]
+	var b strings.Builder
+
+	// WHEN
+	err = pizza.WriteICalendar(&b, "Pizza", []pizza.ICalEvent{pizza.FridayICalEvent(pizza.Friday{Start: start})})
+
+	// THEN 6pm EST is 11pm UTC
+	assert.Nil(t, err)
+	assert.Contains(t, b.String(), "DTSTART:20231110T230000Z\r\n")
+}
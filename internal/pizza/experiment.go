@@ -0,0 +1,99 @@
+package pizza
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DigestExperimentHours are the hours the digest is tried at, one cohort of
+// friends to each. The experiment is off with fewer than two.
+var DigestExperimentHours []int
+
+// DigestCohort picks which of the hours the friend gets the digest at. Friends
+// are spread at random but always land in the same cohort, until the hours
+// change and a new experiment starts.
+func DigestCohort(email string, hours []int) int {
+	if len(hours) < 2 {
+		return 0
+	}
+	key := make([]string, 0, len(hours)+1)
+	key = append(key, email)
+	for _, hour := range hours {
+		key = append(key, strconv.Itoa(hour))
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(SignLink("cohort", key...))
+	return int(binary.BigEndian.Uint32(sig) % uint32(len(hours)))
+}
+
+// DigestCohortStats sums up how the friends who get the digest at one hour
+// RSVPed.
+type DigestCohortStats struct {
+	Hour    int
+	Friends int
+	// Attended are the friends with at least one confirmed RSVP
+	Attended int
+	RSVPs    int
+	// Rate is the percent of the friends who attended, and Delta how many
+	// points more that is than the first hour's
+	Rate  float64
+	Delta float64
+}
+
+// SummarizeDigestExperiment compares the confirmed RSVPs made since the time
+// by the friends in each cohort, with the first hour as the baseline.
+func SummarizeDigestExperiment(hours []int, friends []string, rsvps map[string][]RSVP, since time.Time) []DigestCohortStats {
+	if len(hours) < 2 {
+		return nil
+	}
+	stats := make([]DigestCohortStats, len(hours))
+	for i, hour := range hours {
+		stats[i].Hour = hour
+	}
+	for _, email := range friends {
+		cohort := &stats[DigestCohort(email, hours)]
+		cohort.Friends++
+		attended := false
+		for _, rsvp := range rsvps[email] {
+			if rsvp.Confirmed() && !rsvp.UpdatedAt.Before(since) {
+				cohort.RSVPs++
+				attended = true
+			}
+		}
+		if attended {
+			cohort.Attended++
+		}
+	}
+	for i := range stats {
+		if stats[i].Friends > 0 {
+			stats[i].Rate = 100 * float64(stats[i].Attended) / float64(stats[i].Friends)
+		}
+		stats[i].Delta = stats[i].Rate - stats[0].Rate
+	}
+	return stats
+}
+
+// digestExperiment gathers the RSVPs of every subscribed friend for the
+// analytics page. It reads each friend's RSVPs, which is fine for a group of
+// friends but not much more.
+func digestExperiment(since time.Time) ([]DigestCohortStats, error) {
+	if len(DigestExperimentHours) < 2 {
+		return nil, nil
+	}
+	friends, err := ListDigestFriends()
+	if err != nil {
+		return nil, err
+	}
+	emails := make([]string, 0, len(friends))
+	rsvps := map[string][]RSVP{}
+	for _, friend := range friends {
+		emails = append(emails, friend.Email)
+		if rsvps[friend.Email], err = ListFriendRSVPs(friend.Email); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(emails)
+	return SummarizeDigestExperiment(DigestExperimentHours, emails, rsvps, since), nil
+}
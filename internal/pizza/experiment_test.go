@@ -0,0 +1,59 @@
+package pizza_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestCohort(t *testing.T) {
+	// GIVEN
+	hours := []int{9, 17}
+	counts := make([]int, len(hours))
+
+	// WHEN
+	for i := 0; i < 200; i++ {
+		counts[pizza.DigestCohort(fmt.Sprintf("friend%d@example.com", i), hours)]++
+	}
+
+	// THEN friends are spread over both hours and always land in the same one
+	assert.Greater(t, counts[0], 50)
+	assert.Greater(t, counts[1], 50)
+	assert.Equal(t, pizza.DigestCohort("ted@lasso.com", hours), pizza.DigestCohort("ted@lasso.com", hours))
+	assert.Equal(t, 0, pizza.DigestCohort("ted@lasso.com", []int{9}))
+}
+
+func TestSummarizeDigestExperiment(t *testing.T) {
+	// GIVEN friends in each cohort, some of whom RSVPed since the experiment
+	hours := []int{9, 17}
+	since := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	var friends []string
+	rsvps := map[string][]pizza.RSVP{}
+	for i := 0; len(friends) < 20; i++ {
+		email := fmt.Sprintf("friend%d@example.com", i)
+		friends = append(friends, email)
+		if pizza.DigestCohort(email, hours) == 1 {
+			rsvps[email] = []pizza.RSVP{{UpdatedAt: since.Add(time.Hour)}, {UpdatedAt: since.Add(-time.Hour)}}
+		} else if len(rsvps) == 0 {
+			rsvps[email] = []pizza.RSVP{{UpdatedAt: since.Add(time.Hour), Status: pizza.RSVPStatusPending}}
+		}
+	}
+
+	// WHEN
+	stats := pizza.SummarizeDigestExperiment(hours, friends, rsvps, since)
+
+	// THEN everyone at 17:00 attended and nobody at 9:00, whose RSVP waits for
+	// approval
+	require.Len(t, stats, 2)
+	assert.Equal(t, 9, stats[0].Hour)
+	assert.Equal(t, 0, stats[0].Attended)
+	assert.Equal(t, stats[1].Friends, stats[1].Attended)
+	assert.Equal(t, stats[1].Friends, stats[1].RSVPs)
+	assert.Equal(t, 20, stats[0].Friends+stats[1].Friends)
+	assert.InDelta(t, 100, stats[1].Delta, 0.01)
+	assert.Nil(t, pizza.SummarizeDigestExperiment([]int{9}, friends, rsvps, since))
+}
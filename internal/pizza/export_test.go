@@ -0,0 +1,117 @@
+package pizza
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// ParseTemplate lets tests render pages the way the handlers do.
+var ParseTemplate = parseTemplate
+
+// ExecuteTemplate lets tests render pages the way the handlers do.
+var ExecuteTemplate = executeTemplate
+
+// CallCalendar lets tests queue calls without a calendar.
+var CallCalendar = callCalendar
+
+// RequireConfirmation lets tests wrap handlers in the confirmation step.
+var RequireConfirmation = requireConfirmation
+
+// ConfirmedRoutes are the admin forms behind the confirmation step, wrapped as
+// they are routed, so tests can post to each of them.
+var ConfirmedRoutes = map[string]http.HandlerFunc{
+	"/admin/transfer":            requireConfirmation("transfer ownership", transferTarget, HandleAdminTransfer),
+	"/admin/friends/purge":       requireConfirmation("purge friend", purgeFriendTarget, HandleAdminPurgeFriend),
+	"/admin/friends/duplicates":  requireConfirmation("merge friends", mergeTarget, HandleAdminDuplicates),
+	"/admin/fridays/{id}/delete": requireConfirmation("delete event", deleteFridayTarget, HandleAdminDeleteFriday),
+}
+
+// Rebind lets tests check the queries postgres gets.
+var Rebind = rebind
+
+// SetHealth lets tests probe the server without Fauna or the calendar.
+var SetHealth = setHealth
+
+// Validate lets tests check requests against a schema.
+var Validate = validate
+
+// AddFlash lets tests leave messages for the next page.
+var AddFlash = addFlash
+
+// CheckCSRF lets tests post forms as the logged in friend.
+var CheckCSRF = checkCSRF
+
+// CSRFToken lets tests post forms as the logged in friend.
+var CSRFToken = csrfToken
+
+// BrowserCSRFToken lets tests post forms that don't act as a friend.
+var BrowserCSRFToken = browserCSRFToken
+
+// CheckBrowserCSRF lets tests post forms that don't act as a friend.
+var CheckBrowserCSRF = checkBrowserCSRF
+
+// RequireAdminCSRF lets tests post admin forms without the admin password.
+var RequireAdminCSRF = requireAdminCSRF
+
+// NewFriendSession lets tests log in as a friend.
+var NewFriendSession = newFriendSession
+
+// FriendSessionCookie lets tests log in as a friend.
+var FriendSessionCookie = friendSession.cookie
+
+// WritePlan lets tests render the dry run page.
+var WritePlan = writePlan
+
+// CheckAdminCode lets tests enter admin codes without the login page.
+var CheckAdminCode = checkAdminCode
+
+// ResetAdminCodes forgets the wrong admin codes entered by earlier tests.
+func ResetAdminCodes() {
+	adminCodeMu.Lock()
+	defer adminCodeMu.Unlock()
+	adminCodeFailures = nil
+}
+
+// SNSStringToSign lets tests sign SNS messages.
+var SNSStringToSign = SNSEnvelope.stringToSign
+
+// SetSNSCert lets tests sign SNS messages with their own certificate.
+func SetSNSCert(certURL string, cert *x509.Certificate) {
+	snsCerts.Store(certURL, cert)
+}
+
+// Dial lets tests check where the publisher connects and which name its TLS
+// certificate must have.
+func (p *MQTTPublisher) Dial() (addr, serverName string) {
+	return p.addr, p.host
+}
+
+// SetStore lets tests serve made up data, like the sandbox's, without Fauna.
+// It returns a func that puts the store back.
+func SetStore(s Store) func() {
+	old := store
+	store = s
+	fridayCache.Clear()
+	apiFridaysCache.Clear()
+	return func() {
+		store = old
+		fridayCache.Clear()
+		apiFridaysCache.Clear()
+	}
+}
+
+// SetSettings lets tests change the runtime settings. It returns a func that
+// puts them back.
+func SetSettings(update func(s *RuntimeSettings)) func() {
+	old := CurrentSettings()
+	UpdateSettings(update)
+	return func() { UpdateSettings(func(s *RuntimeSettings) { *s = old }) }
+}
+
+// SetMQTTPublisher lets tests publish to their own broker. It returns a func
+// that puts the publisher back.
+func SetMQTTPublisher(p *MQTTPublisher) func() {
+	old := mqttPublisher
+	mqttPublisher = p
+	return func() { mqttPublisher = old }
+}
@@ -0,0 +1,170 @@
+package pizza
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The features behind a flag, checked with FeatureOn.
+const (
+	FeatureCountdown = "countdown"
+)
+
+// Feature is a feature that isn't on for everyone yet.
+type Feature struct {
+	Name        string
+	Description string
+}
+
+// Features are every flag the code checks, in the order the labs page lists
+// them.
+var Features = []Feature{
+	{Name: FeatureCountdown, Description: "Show how many days are left until each party on the RSVP page."},
+}
+
+// FeatureTarget is who a feature is on for.
+type FeatureTarget struct {
+	// Percent of friends it is on for, always the same friends
+	Percent int `yaml:"percent"`
+	// Friends it is on for whatever the percent
+	Friends []string `yaml:"friends"`
+	// Labs lets friends turn it on for themselves from the labs page
+	Labs bool `yaml:"labs"`
+}
+
+// FeatureTargets are who each feature is on for, and features without a
+// target are off for everyone.
+var FeatureTargets = map[string]FeatureTarget{}
+
+var friendLabsCache *Cache[[]string]
+
+func init() {
+	c := NewCache(time.Minute, GetFriendLabs)
+	friendLabsCache = &c
+}
+
+// featureBucket puts the friend in one of 100 buckets for the feature, so
+// raising its percent only adds friends. It doesn't use the link secret,
+// which may change on every restart.
+func featureBucket(name, email string) int {
+	sum := sha256.Sum256([]byte(name + "\x00" + email))
+	return int(binary.BigEndian.Uint32(sum[:]) % 100)
+}
+
+// FeatureTargeted reports whether the feature is on for the friend without
+// them opting in: they are listed, or in its percent.
+func FeatureTargeted(name, email string, target FeatureTarget) bool {
+	if len(email) == 0 {
+		return false
+	}
+	return containsString(target.Friends, email) || featureBucket(name, email) < target.Percent
+}
+
+// FeatureEnabled reports whether the feature is on for the friend, who opted
+// in to the labs features.
+func FeatureEnabled(name, email string, target FeatureTarget, labs []string) bool {
+	return FeatureTargeted(name, email, target) || (target.Labs && len(email) > 0 && containsString(labs, name))
+}
+
+// FeatureOn reports whether the feature is on for the friend, who may not be
+// known. Their opt-ins are cached, so it can be checked on every page.
+func FeatureOn(name, email string) bool {
+	target, ok := FeatureTargets[name]
+	if !ok || len(email) == 0 {
+		return false
+	}
+	var labs []string
+	if target.Labs {
+		var err error
+		if labs, err = friendLabsCache.Get(email); err != nil {
+			return false
+		}
+	}
+	return FeatureEnabled(name, email, target, labs)
+}
+
+// Countdown is how long until the party starts, in days in New York, like
+// "in 3 days".
+func Countdown(now, start time.Time) string {
+	estZone, _ := time.LoadLocation("America/New_York")
+	y1, m1, d1 := now.In(estZone).Date()
+	y2, m2, d2 := start.In(estZone).Date()
+	days := int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	switch {
+	case days <= 0:
+		return "today"
+	case days == 1:
+		return "tomorrow"
+	}
+	return "in " + strconv.Itoa(days) + " days"
+}
+
+type LabsFeatureData struct {
+	Name        string
+	Description string
+	// On is set when the friend opted in, and Targeted when it is already on
+	// for them
+	On       bool
+	Targeted bool
+}
+
+type LabsPageData struct {
+	View
+	Email    string
+	Features []LabsFeatureData
+}
+
+// HandleLabs lets the friend the browser is logged in as try the features
+// the host put in labs before everyone gets them.
+func HandleLabs(w http.ResponseWriter, r *http.Request) {
+	data := LabsPageData{Email: friendFromCookie(r)}
+	if len(data.Email) > 0 {
+		labs, err := GetFriendLabs(data.Email)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil || !checkCSRF(r) {
+				Handle4xx(w, r)
+				return
+			}
+			labs = []string{}
+			for _, name := range r.PostForm["feature"] {
+				if FeatureTargets[name].Labs && !containsString(labs, name) {
+					labs = append(labs, name)
+				}
+			}
+			sort.Strings(labs)
+			if err = SetFriendLabs(data.Email, labs); err != nil {
+				Handle500(w, r)
+				return
+			}
+			friendLabsCache.Store(data.Email, labs)
+			Log.Info("friend changed labs", zap.String("email", data.Email), zap.Strings("labs", labs))
+			addFlash(w, r, "Saved.")
+			http.Redirect(w, r, "/labs", http.StatusSeeOther)
+			return
+		}
+		for _, feature := range Features {
+			target := FeatureTargets[feature.Name]
+			if !target.Labs {
+				continue
+			}
+			data.Features = append(data.Features, LabsFeatureData{
+				Name:        feature.Name,
+				Description: feature.Description,
+				On:          containsString(labs, feature.Name),
+				Targeted:    FeatureTargeted(feature.Name, data.Email, target),
+			})
+		}
+	}
+
+	render(w, r, "html/labs.html", &data)
+}
@@ -0,0 +1,66 @@
+package pizza_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureEnabled(t *testing.T) {
+	// GIVEN
+	listed := pizza.FeatureTarget{Friends: []string{"believe@tedlasso.com"}}
+	labs := pizza.FeatureTarget{Labs: true}
+
+	// WHEN / THEN listed friends have it, and labs friends once they opt in
+	assert.True(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "believe@tedlasso.com", listed, nil))
+	assert.False(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "roy@kent.com", listed, nil))
+	assert.False(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "roy@kent.com", labs, nil))
+	assert.True(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "roy@kent.com", labs, []string{pizza.FeatureCountdown}))
+	assert.False(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "roy@kent.com", listed, []string{pizza.FeatureCountdown}))
+	assert.False(t, pizza.FeatureEnabled(pizza.FeatureCountdown, "", pizza.FeatureTarget{Percent: 100, Labs: true}, []string{pizza.FeatureCountdown}))
+}
+
+func TestFeatureTargetedPercent(t *testing.T) {
+	// GIVEN
+	var emails []string
+	for i := 0; i < 1000; i++ {
+		emails = append(emails, fmt.Sprintf("friend%d@richmond.com", i))
+	}
+
+	// WHEN
+	on := map[int]map[string]bool{}
+	for _, percent := range []int{0, 20, 50, 100} {
+		on[percent] = map[string]bool{}
+		for _, email := range emails {
+			if pizza.FeatureTargeted(pizza.FeatureCountdown, email, pizza.FeatureTarget{Percent: percent}) {
+				on[percent][email] = true
+			}
+		}
+	}
+
+	// THEN about the percent of friends have it, and raising it only adds more
+	assert.Empty(t, on[0])
+	assert.InDelta(t, 200, len(on[20]), 50)
+	assert.InDelta(t, 500, len(on[50]), 60)
+	assert.Len(t, on[100], len(emails))
+	for email := range on[20] {
+		assert.True(t, on[50][email], email)
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	// GIVEN a party at 6pm New York time
+	estZone, _ := time.LoadLocation("America/New_York")
+	start := time.Date(2023, 4, 7, 18, 0, 0, 0, estZone)
+
+	// WHEN / THEN days are counted in New York, not from the hour, also
+	// across the clocks changing
+	assert.Equal(t, "today", pizza.Countdown(time.Date(2023, 4, 7, 9, 0, 0, 0, estZone), start))
+	assert.Equal(t, "tomorrow", pizza.Countdown(time.Date(2023, 4, 6, 23, 0, 0, 0, estZone), start))
+	assert.Equal(t, "in 2 days", pizza.Countdown(time.Date(2023, 4, 5, 0, 30, 0, 0, estZone), start))
+	assert.Equal(t, "in 7 days", pizza.Countdown(time.Date(2023, 3, 31, 20, 0, 0, 0, estZone), start))
+	assert.Equal(t, "in 28 days", pizza.Countdown(time.Date(2023, 3, 10, 12, 0, 0, 0, estZone), start))
+}
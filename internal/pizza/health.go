@@ -0,0 +1,131 @@
+package pizza
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// HealthCheck is whether one of the services the server needs answered.
+type HealthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthReport is the last check of the database and the calendar, made in
+// the background so probes don't spend the calendar quota.
+type HealthReport struct {
+	Database  HealthCheck `json:"database"`
+	Calendar  HealthCheck `json:"calendar"`
+	CheckedAt time.Time   `json:"checkedAt"`
+	// CredentialsInvalid is set when the calendar token was rejected, which
+	// only a restart with a renewed token fixes
+	CredentialsInvalid bool `json:"credentialsInvalid,omitempty"`
+}
+
+var (
+	healthMu     sync.Mutex
+	healthReport HealthReport
+	// healthStale is how old the last check may be for the server to be
+	// ready, a few runs of WatchHealth
+	healthStale = 5 * time.Minute
+)
+
+func setHealth(report HealthReport) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	healthReport = report
+}
+
+func getHealth() HealthReport {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return healthReport
+}
+
+// Ready reports whether the server should take traffic at now: both
+// services answered the last check, and it was recent.
+func (h HealthReport) Ready(now time.Time) bool {
+	return h.Database.OK && h.Calendar.OK && now.Sub(h.CheckedAt) <= healthStale
+}
+
+func isCredentialError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	var apiErr *googleapi.Error
+	var davErr *CalDAVError
+	return errors.As(err, &retrieveErr) || (errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized) ||
+		(errors.As(err, &davErr) && davErr.Code == http.StatusUnauthorized)
+}
+
+// CheckHealth asks the database and the calendar whether they're reachable
+// with the server's credentials.
+func CheckHealth() HealthReport {
+	report := HealthReport{CheckedAt: time.Now(), Database: HealthCheck{OK: true}, Calendar: HealthCheck{OK: true}}
+	if err := store.Ping(); err != nil {
+		report.Database = HealthCheck{Error: err.Error()}
+	}
+	if cal != nil {
+		if _, err := ListEvents(1); err != nil {
+			report.Calendar = HealthCheck{Error: err.Error()}
+			report.CredentialsInvalid = isCredentialError(err)
+		}
+	}
+	return report
+}
+
+// WatchHealth checks the database and the calendar, which also keeps the
+// calendar credentials renewed, and logs when either stops answering.
+func WatchHealth(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		report := CheckHealth()
+		setHealth(report)
+		if !report.Database.OK {
+			Log.Warn("database is unreachable", zap.String("error", report.Database.Error))
+		}
+		if !report.Calendar.OK {
+			Log.Warn("failed to list calendar events", zap.String("error", report.Calendar.Error), zap.Bool("credentialsInvalid", report.CredentialsInvalid))
+		} else {
+			Log.Debug("calendar credentials are valid")
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
+
+// HandleHealthz is the liveness probe. It only fails when the calendar token
+// was rejected, so the server is restarted to load a renewed one; an outage
+// of the database or the calendar is left to HandleReadyz.
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	report := getHealth()
+	status := http.StatusOK
+	if report.CredentialsInvalid {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// HandleReadyz is the readiness probe, failing until the database and the calendar
+// have both answered a recent check.
+func HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := getHealth()
+	status := http.StatusOK
+	if !report.Ready(time.Now()) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// HandleSandboxHealth answers both probes in the sandbox, which needs neither
+// a database nor the calendar.
+func HandleSandboxHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthReport{Database: HealthCheck{OK: true}, Calendar: HealthCheck{OK: true}, CheckedAt: time.Now()})
+}
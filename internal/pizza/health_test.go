@@ -0,0 +1,48 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthReportReady(t *testing.T) {
+	// GIVEN
+	now := time.Now()
+	ok := pizza.HealthCheck{OK: true}
+	down := pizza.HealthCheck{Error: "connection refused"}
+
+	// THEN the server is ready once both answered a recent check
+	assert.False(t, pizza.HealthReport{}.Ready(now))
+	assert.True(t, pizza.HealthReport{Database: ok, Calendar: ok, CheckedAt: now}.Ready(now))
+	assert.False(t, pizza.HealthReport{Database: down, Calendar: ok, CheckedAt: now}.Ready(now))
+	assert.False(t, pizza.HealthReport{Database: ok, Calendar: ok, CheckedAt: now.Add(-time.Hour)}.Ready(now))
+}
+
+func TestHandleProbes(t *testing.T) {
+	// GIVEN the database is down
+	defer pizza.SetHealth(pizza.HealthReport{})
+	pizza.SetHealth(pizza.HealthReport{Database: pizza.HealthCheck{Error: "timeout"}, Calendar: pizza.HealthCheck{OK: true}, CheckedAt: time.Now()})
+
+	// WHEN
+	healthz := httptest.NewRecorder()
+	pizza.HandleHealthz(healthz, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	readyz := httptest.NewRecorder()
+	pizza.HandleReadyz(readyz, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// THEN traffic is gated but the server isn't restarted
+	assert.Equal(t, http.StatusOK, healthz.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, readyz.Code)
+
+	// WHEN the calendar token is rejected
+	pizza.SetHealth(pizza.HealthReport{Database: pizza.HealthCheck{OK: true}, CredentialsInvalid: true, CheckedAt: time.Now()})
+	healthz = httptest.NewRecorder()
+	pizza.HandleHealthz(healthz, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// THEN the server is restarted to load a renewed one
+	assert.Equal(t, http.StatusServiceUnavailable, healthz.Code)
+}
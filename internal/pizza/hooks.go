@@ -0,0 +1,246 @@
+package pizza
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// EmailWebhookToken must be passed as the token query parameter by the email
+// provider. The webhook is disabled while it is empty.
+var EmailWebhookToken string
+
+func checkEmailWebhookToken(w http.ResponseWriter, r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if len(EmailWebhookToken) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(EmailWebhookToken)) != 1 {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return false
+	}
+	return true
+}
+
+// readSNSMessage unwraps the message from an SNS notification, once its
+// signature checks out. Subscription confirmations are handled here, in which
+// case no message is returned.
+func readSNSMessage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	var envelope SNSEnvelope
+	if err := json.NewDecoder(r.Body).Decode(&envelope); bodyTooLarge(err) {
+		writeTooLarge(w, r)
+		return nil, false
+	} else if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "malformed request body")
+		return nil, false
+	}
+	if err := VerifySNS(envelope); err != nil {
+		Log.Warn("refusing unsigned sns message", zap.Error(err), zap.String("certURL", envelope.SigningCertURL))
+		writeAPIError(w, http.StatusForbidden, "invalid signature")
+		return nil, false
+	}
+	if envelope.Type == "SubscriptionConfirmation" {
+		confirmSNSSubscription(envelope.SubscribeURL)
+		writeJSON(w, http.StatusOK, struct{}{})
+		return nil, false
+	}
+	return []byte(envelope.Message), true
+}
+
+func HandleEmailWebhook(w http.ResponseWriter, r *http.Request) {
+	if !checkEmailWebhookToken(w, r) {
+		return
+	}
+
+	var issues []EmailIssue
+	var err error
+	switch provider := mux.Vars(r)["provider"]; provider {
+	case "ses":
+		message, ok := readSNSMessage(w, r)
+		if !ok {
+			return
+		}
+		issues, err = ParseSESNotification(message)
+	case "sendgrid":
+		var body []byte
+		if body, err = io.ReadAll(r.Body); bodyTooLarge(err) {
+			writeTooLarge(w, r)
+			return
+		} else if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "malformed request body")
+			return
+		}
+		issues, err = ParseSendGridEvents(body)
+	default:
+		writeAPIError(w, http.StatusNotFound, "unknown provider")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "malformed notification")
+		return
+	}
+
+	for _, issue := range issues {
+		Log.Info("flagging friend email", zap.String("email", issue.Email), zap.String("status", issue.Status))
+		if err = FlagFriendEmail(issue); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Flagged int `json:"flagged"`
+	}{len(issues)})
+}
+
+// HandleInboundEmail records RSVPs sent by replying to an invite, e.g. "yes" or
+// "+2", and replies to confirm.
+func HandleInboundEmail(w http.ResponseWriter, r *http.Request) {
+	if !checkEmailWebhookToken(w, r) {
+		return
+	}
+
+	var email InboundEmail
+	var err error
+	switch provider := mux.Vars(r)["provider"]; provider {
+	case "ses":
+		message, ok := readSNSMessage(w, r)
+		if !ok {
+			return
+		}
+		email, err = ParseSESInbound(message)
+	case "sendgrid":
+		email, err = ParseSendGridInbound(r)
+	default:
+		writeAPIError(w, http.StatusNotFound, "unknown provider")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if reply := processInboundRSVP(email); len(reply) > 0 {
+		subject := email.Subject
+		if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+			subject = "Re: " + subject
+		}
+		err = SendEmail(EmailMessage{To: email.From, Subject: subject, Body: reply, InReplyTo: email.MessageID})
+		if err != nil {
+			Log.Warn("failed to send rsvp reply", zap.Error(err), zap.String("email", email.From))
+		}
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// processInboundRSVP returns the reply to send, which is empty when the email
+// should be dropped without a reply.
+func processInboundRSVP(email InboundEmail) string {
+	if !email.Authenticated {
+		Log.Info("dropping unauthenticated inbound email", zap.String("from", email.From))
+		return ""
+	}
+	if ok, err := IsFriendAllowed(email.From); !ok {
+		if err != nil {
+			Log.Error("error checking email for inbound rsvp", zap.Error(err))
+		}
+		return ""
+	}
+
+	attending, plusOnes, ok := ParseRSVPReply(email.Text)
+	if !ok {
+		return "Sorry, we couldn't tell if you're coming. Reply YES, NO, or +2 to bring two friends."
+	}
+
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		return "Sorry, something went wrong. Please RSVP at " + BaseURL
+	}
+	// replies must be to a Friday's own address, so an RSVP never lands on a
+	// different party than the one the friend was invited to
+	fridayID := email.FridayID()
+	if len(fridayID) == 0 {
+		return "Sorry, we couldn't tell which pizza friday this is for. Reply to the email about it, or RSVP at " + BaseURL
+	}
+	var friday *Friday
+	for i := range fridays {
+		if fridays[i].ID() == fridayID {
+			friday = &fridays[i]
+			break
+		}
+	}
+	if friday == nil {
+		return "Sorry, we couldn't find that pizza friday. It may have passed or been cancelled; RSVP at " + BaseURL
+	}
+	friend, err := GetCachedPrimaryEmail(email.From)
+	if err != nil {
+		return "Sorry, something went wrong. Please RSVP at " + BaseURL
+	}
+	// like from the edit link, friends can cancel until the party starts
+	if !attending {
+		return cancelByReply(friend, *friday)
+	}
+	if friday.IsClosed() {
+		return "Sorry, RSVPs are closed for that pizza friday."
+	} else if !friday.IsOpen() {
+		return fmt.Sprintf("RSVPs for pizza on %s open on %s.", FormatTime(friday.Start), FormatTime(friday.Opens()))
+	}
+	if max := MaxPlusOnesFor(friend); plusOnes > max {
+		return fmt.Sprintf("Sorry, you can bring at most %d friends.", max)
+	}
+	rsvp, err := RSVPToFriday(friend, *friday, RSVPOptions{PlusOnes: plusOnes})
+	if err != nil {
+		Log.Error("inbound rsvp failed", zap.Error(err), zap.String("email", email.From))
+		return "Sorry, something went wrong. Please RSVP at " + BaseURL
+	}
+	guests := ""
+	if plusOnes == 1 {
+		guests = " with a friend"
+	} else if plusOnes > 1 {
+		guests = fmt.Sprintf(" with %d friends", plusOnes)
+	}
+	if rsvp.Status == RSVPStatusPending {
+		return fmt.Sprintf("Pizza on %s is full, so the host will let you know if there's room%s.\n\nChange your RSVP at %s%s",
+			FormatTime(friday.Start), guests, BaseURL, EditRSVPURL(rsvp))
+	}
+	return fmt.Sprintf("You're in for pizza on %s%s!\n\nChange your RSVP at %s%s",
+		FormatTime(friday.Start), guests, BaseURL, EditRSVPURL(rsvp))
+}
+
+// cancelByReply cancels the friend's RSVP to the Friday, if they have one,
+// and returns the reply.
+func cancelByReply(friend string, friday Friday) string {
+	rsvps, err := ListFriendRSVPs(friend)
+	if err != nil {
+		Log.Error("failed to list rsvps for inbound cancel", zap.Error(err), zap.String("email", friend))
+		return "Sorry, something went wrong. Please cancel from your edit link."
+	}
+	for _, rsvp := range rsvps {
+		if rsvp.FridayID != friday.ID() {
+			continue
+		}
+		if err = cancelRSVP(rsvp); err != nil {
+			Log.Error("inbound cancel failed", zap.Error(err), zap.String("email", friend))
+			return "Sorry, something went wrong. Please cancel from your edit link."
+		}
+		return fmt.Sprintf("Sorry you can't make it to pizza on %s. Your RSVP is cancelled.", FormatTime(friday.Start))
+	}
+	return fmt.Sprintf("Sorry you can't make it to pizza on %s.", FormatTime(friday.Start))
+}
+
+func confirmSNSSubscription(subscribeURL string) {
+	if !IsSNSURL(subscribeURL) {
+		Log.Warn("refusing to confirm sns subscription", zap.String("url", subscribeURL))
+		return
+	}
+	res, err := snsClient.Get(subscribeURL)
+	if err != nil {
+		Log.Error("failed to confirm sns subscription", zap.Error(err))
+		return
+	}
+	res.Body.Close()
+	Log.Info("confirmed sns subscription", zap.Int("status", res.StatusCode))
+}
@@ -0,0 +1,186 @@
+package pizza
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// ICalEvent is a single VEVENT in an iCalendar file.
+type ICalEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	// Status is CONFIRMED or TENTATIVE, left out when empty
+	Status string
+}
+
+// ICalFeedPastDays is how long parties stay in a friend's feed after they
+// end.
+var ICalFeedPastDays = 30
+
+// FridayICalEvent describes the Friday without any guest details so it can be
+// shared publicly.
+func FridayICalEvent(friday Friday) ICalEvent {
+	return ICalEvent{
+		UID:         fmt.Sprintf("%s@%s", friday.ID(), siteHost()),
+		Start:       friday.Start,
+		End:         friday.EndTime(),
+		Summary:     "Pizza Friday",
+		Description: EventDescription,
+		URL:         BaseURL + "/",
+	}
+}
+
+// GuestICalEvents are the parties the friend RSVPed to and hasn't been
+// declined from, ending since the time, with their link to change the RSVP.
+// RSVPs still waiting for a spot are tentative.
+func GuestICalEvents(rsvps []RSVP, fridays map[string]Friday, since time.Time) []ICalEvent {
+	events := []ICalEvent{}
+	for _, rsvp := range rsvps {
+		friday, ok := fridays[rsvp.FridayID]
+		if !ok || rsvp.Status == RSVPStatusDeclined || friday.EndTime().Before(since) {
+			continue
+		}
+		event := FridayICalEvent(friday)
+		event.URL = BaseURL + EditRSVPURL(rsvp)
+		event.Status = "CONFIRMED"
+		if announcement := MarkdownText(FridayAnnouncement(friday)); len(announcement) > 0 {
+			event.Description += "\n\n" + announcement
+		}
+		if !rsvp.Confirmed() {
+			event.Summary += " (waiting for a spot)"
+			event.Status = "TENTATIVE"
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// HandleICalFeed serves the friend's feed from the token in their
+// ICalFeedURL. Calendar apps fetch it every so often, so it only reads the
+// friend's RSVPs and their parties.
+func HandleICalFeed(w http.ResponseWriter, r *http.Request) {
+	email, ok := ParseICalToken(mux.Vars(r)["token"])
+	if !ok {
+		Handle4xx(w, r)
+		return
+	}
+	rsvps, err := ListFriendRSVPs(email)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -ICalFeedPastDays)
+	fridays := map[string]Friday{}
+	for _, rsvp := range rsvps {
+		if _, ok := fridays[rsvp.FridayID]; ok {
+			continue
+		}
+		friday, err := GetFriday(rsvp.FridayID)
+		if err != nil {
+			Handle500(w, r)
+			return
+		} else if friday != nil {
+			fridays[rsvp.FridayID] = *friday
+		}
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	if err = WriteICalendar(w, "Pizza Friday", GuestICalEvents(rsvps, fridays, since)); err != nil {
+		Log.Error("ics write failure", zap.Error(err))
+	}
+}
+
+func siteHost() string {
+	if u, err := url.Parse(BaseURL); err == nil && len(u.Host) > 0 {
+		return u.Host
+	}
+	return "rsvp.pizza"
+}
+
+// WriteICalendar writes the events as an iCalendar (RFC 5545) calendar.
+func WriteICalendar(w io.Writer, name string, events []ICalEvent) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//rsvp.pizza//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + icalEscape(name),
+	}
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+event.UID,
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+event.Start.UTC().Format(icalTimeFormat),
+			"DTEND:"+event.End.UTC().Format(icalTimeFormat),
+			"SUMMARY:"+icalEscape(event.Summary),
+			"DESCRIPTION:"+icalEscape(event.Description),
+		)
+		if len(event.URL) > 0 {
+			lines = append(lines, "URL:"+event.URL)
+		}
+		if len(event.Status) > 0 {
+			lines = append(lines, "STATUS:"+event.Status)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return writeFoldedLines(w, lines)
+}
+
+// WriteVCard writes a vCard (RFC 2426) for the Friday so contact apps and
+// assistants can pick up when and where the party is.
+func WriteVCard(w io.Writer, friday Friday) error {
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"FN:" + icalEscape("Pizza Friday "+FormatTime(friday.Start)),
+		"N:" + icalEscape("Pizza Friday") + ";;;;",
+		"NOTE:" + icalEscape(fmt.Sprintf("%s %s until %s", EventDescription, FormatTime(friday.Start), FormatTime(friday.EndTime()))),
+		"URL:" + BaseURL + "/",
+		"END:VCARD",
+	}
+	return writeFoldedLines(w, lines)
+}
+
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// writeFoldedLines ends each line with CRLF and folds lines longer than 75
+// octets without splitting UTF-8 characters.
+func writeFoldedLines(w io.Writer, lines []string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		n := 0
+		for _, r := range line {
+			size := len(string(r))
+			if n+size > 75 {
+				b.WriteString("\r\n ")
+				n = 1
+			}
+			b.WriteRune(r)
+			n += size
+		}
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
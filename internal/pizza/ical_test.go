@@ -0,0 +1,83 @@
+package pizza_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestWriteICalendar(t *testing.T) {
+	// GIVEN
+	start := time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)
+	event := pizza.ICalEvent{
+		UID:         "1680903000@rsvp.pizza",
+		Start:       start,
+		End:         start.Add(4 * time.Hour),
+		Summary:     "Pizza Friday",
+		Description: "Bring drinks, chairs; and " + strings.Repeat("pizza ", 20),
+	}
+	var b strings.Builder
+
+	// WHEN
+	err := pizza.WriteICalendar(&b, "Pizza", []pizza.ICalEvent{event})
+
+	// THEN
+	assert.Nil(t, err)
+	out := b.String()
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTART:20230407T213000Z\r\n")
+	assert.Contains(t, out, "DTEND:20230408T013000Z\r\n")
+	assert.Contains(t, out, `DESCRIPTION:Bring drinks\, chairs\; and pizza`)
+	for _, line := range strings.Split(out, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+}
+
+func TestWriteVCard(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{Start: time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)}
+	var b strings.Builder
+
+	// WHEN
+	err := pizza.WriteVCard(&b, friday)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(b.String(), "BEGIN:VCARD\r\nVERSION:3.0\r\n"))
+	assert.Contains(t, b.String(), "FN:Pizza Friday 07 Apr 23 17:30 EDT\r\n")
+}
+
+func TestGuestICalEvents(t *testing.T) {
+	// GIVEN
+	now := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	past := pizza.Friday{Start: now.AddDate(0, 0, -14)}
+	soon := pizza.Friday{Start: now.AddDate(0, 0, 6), Announcement: "Bring a **chair**"}
+	later := pizza.Friday{Start: now.AddDate(0, 0, 13)}
+	declined := pizza.Friday{Start: now.AddDate(0, 0, 20)}
+	fridays := map[string]pizza.Friday{"1": past, "2": soon, "3": later, "4": declined}
+	rsvps := []pizza.RSVP{
+		{ID: "a", Email: "roy@kent.com", FridayID: "3", Status: pizza.RSVPStatusPending},
+		{ID: "b", Email: "roy@kent.com", FridayID: "2"},
+		{ID: "c", Email: "roy@kent.com", FridayID: "1"},
+		{ID: "d", Email: "roy@kent.com", FridayID: "4", Status: pizza.RSVPStatusDeclined},
+		{ID: "e", Email: "roy@kent.com", FridayID: "5"},
+	}
+
+	// WHEN
+	events := pizza.GuestICalEvents(rsvps, fridays, now.AddDate(0, 0, -7))
+
+	// THEN
+	assert.Len(t, events, 2)
+	assert.Equal(t, soon.Start, events[0].Start)
+	assert.Equal(t, "CONFIRMED", events[0].Status)
+	assert.Contains(t, events[0].Description, "Bring a chair")
+	assert.Contains(t, events[0].URL, "/rsvp/b/edit?sig=")
+	assert.Equal(t, later.Start, events[1].Start)
+	assert.Equal(t, "TENTATIVE", events[1].Status)
+	assert.Equal(t, "Pizza Friday (waiting for a spot)", events[1].Summary)
+}
@@ -0,0 +1,213 @@
+package pizza
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"strings"
+
+	// register decoders for uploads
+	_ "image/gif"
+	_ "image/png"
+)
+
+// ImageWidths are the widths of the variants made for each uploaded image.
+// The smallest one doubles as the thumbnail.
+var ImageWidths = []int{320, 800, 1600}
+
+// maxImagePixels guards against decompression bombs.
+const maxImagePixels = 50_000_000
+
+var ErrImageInvalid = errors.New("not a supported image")
+
+// Image is an uploaded image stored as JPEG variants of several widths.
+type Image struct {
+	Key    string `fauna:"key" json:"key"`
+	Widths []int  `fauna:"widths" json:"widths"`
+}
+
+// ImageVariant is an encoded JPEG of one width.
+type ImageVariant struct {
+	Width int
+	Data  []byte
+}
+
+func variantKey(key string, width int) string {
+	return fmt.Sprintf("%s-%d.jpg", key, width)
+}
+
+// Src is the URL of the largest variant.
+func (i Image) Src() string {
+	if len(i.Widths) == 0 || blobStore == nil {
+		return ""
+	}
+	return blobStore.URL(variantKey(i.Key, i.Widths[len(i.Widths)-1]))
+}
+
+// Thumbnail is the URL of the smallest variant.
+func (i Image) Thumbnail() string {
+	if len(i.Widths) == 0 || blobStore == nil {
+		return ""
+	}
+	return blobStore.URL(variantKey(i.Key, i.Widths[0]))
+}
+
+// SrcSet lists every variant for a responsive img srcset attribute.
+func (i Image) SrcSet() string {
+	if blobStore == nil {
+		return ""
+	}
+	parts := make([]string, len(i.Widths))
+	for n, width := range i.Widths {
+		parts[n] = fmt.Sprintf("%s %dw", blobStore.URL(variantKey(i.Key, width)), width)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ProcessImage decodes an upload and re-encodes it as JPEG variants no wider
+// than the original. Re-encoding drops any EXIF metadata such as location.
+func ProcessImage(data []byte) ([]ImageVariant, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageInvalid
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageInvalid
+	}
+	// JPEG has no transparency, so flatten onto white
+	img := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Over)
+	width := img.Bounds().Dx()
+	variants := []ImageVariant{}
+	for _, w := range ImageWidths {
+		if w > width {
+			w = width
+		}
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, ResizeImage(img, w), &jpeg.Options{Quality: 82}); err != nil {
+			return nil, err
+		}
+		variants = append(variants, ImageVariant{w, buf.Bytes()})
+		if w == width {
+			break
+		}
+	}
+	return variants, nil
+}
+
+// ResizeImage scales the image to the width, keeping its aspect ratio, by
+// averaging the source pixels under each destination pixel.
+func ResizeImage(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+	if width == sw && height == sh {
+		return rgba
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(rgba.Pix[i])
+					g += int(rgba.Pix[i+1])
+					bl += int(rgba.Pix[i+2])
+					a += int(rgba.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+type imageJob struct {
+	data  []byte
+	reply chan imageResult
+}
+
+type imageResult struct {
+	variants []ImageVariant
+	err      error
+}
+
+// ImagePool processes uploads on a fixed number of workers so a burst of
+// photos can't use up the memory and CPU of a small server.
+type ImagePool struct {
+	jobs chan imageJob
+}
+
+var imagePool *ImagePool
+
+func NewImagePool(workers int) *ImagePool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &ImagePool{jobs: make(chan imageJob)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.jobs {
+				variants, err := ProcessImage(job.data)
+				job.reply <- imageResult{variants, err}
+			}
+		}()
+	}
+	return p
+}
+
+// Process waits for a worker to process the image.
+func (p *ImagePool) Process(data []byte) ([]ImageVariant, error) {
+	reply := make(chan imageResult, 1)
+	p.jobs <- imageJob{data, reply}
+	res := <-reply
+	return res.variants, res.err
+}
+
+// StoreImage processes an upload and stores its variants under a key derived
+// from its content.
+func StoreImage(prefix string, data []byte) (Image, error) {
+	variants, err := imagePool.Process(data)
+	if err != nil {
+		return Image{}, err
+	}
+	sum := sha256.Sum256(data)
+	img := Image{Key: prefix + "/" + hex.EncodeToString(sum[:8])}
+	for _, v := range variants {
+		if err = blobStore.Put(variantKey(img.Key, v.Width), v.Data); err != nil {
+			return Image{}, err
+		}
+		img.Widths = append(img.Widths, v.Width)
+	}
+	return img, nil
+}
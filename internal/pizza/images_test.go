@@ -0,0 +1,86 @@
+package pizza_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResizeImage(t *testing.T) {
+	// GIVEN
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.RGBA{200, 100, 50, 255})
+		}
+	}
+
+	// WHEN
+	dst := pizza.ResizeImage(src, 100)
+
+	// THEN
+	assert.Equal(t, 100, dst.Bounds().Dx())
+	assert.Equal(t, 50, dst.Bounds().Dy())
+	assert.Equal(t, color.RGBA{200, 100, 50, 255}, dst.RGBAAt(50, 25))
+}
+
+func TestProcessImage(t *testing.T) {
+	// GIVEN
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500)), nil))
+	// add an EXIF segment right after the start of image marker
+	exif := append([]byte{0xff, 0xe1, 0x00, 0x0e}, []byte("Exif\x00\x00GPSDATA")...)
+	upload := append(append(buf.Bytes()[:2:2], exif...), buf.Bytes()[2:]...)
+	var small bytes.Buffer
+	require.NoError(t, png.Encode(&small, image.NewNRGBA(image.Rect(0, 0, 200, 100))))
+
+	// WHEN
+	variants, err := pizza.ProcessImage(upload)
+	smallVariants, smallErr := pizza.ProcessImage(small.Bytes())
+	_, badErr := pizza.ProcessImage([]byte("not an image"))
+
+	// THEN
+	require.NoError(t, err)
+	require.Len(t, variants, 3)
+	assert.Equal(t, 320, variants[0].Width)
+	assert.Equal(t, 800, variants[1].Width)
+	assert.Equal(t, 1000, variants[2].Width)
+	for _, v := range variants {
+		assert.False(t, bytes.Contains(v.Data, []byte("Exif")))
+		config, err := jpeg.DecodeConfig(bytes.NewReader(v.Data))
+		require.NoError(t, err)
+		assert.Equal(t, v.Width, config.Width)
+		assert.Equal(t, v.Width/2, config.Height)
+	}
+	require.NoError(t, smallErr)
+	require.Len(t, smallVariants, 1)
+	assert.Equal(t, 200, smallVariants[0].Width)
+	assert.Equal(t, pizza.ErrImageInvalid, badErr)
+}
+
+func TestFileBlobStore(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	store := pizza.NewFileBlobStore(dir)
+
+	// WHEN
+	err := store.Put("fridays/1/abc-320.jpg", []byte("pizza"))
+	badErr := store.Put("../escape.jpg", []byte("pizza"))
+
+	// THEN
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "fridays/1/abc-320.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "pizza", string(data))
+	assert.Equal(t, "/uploads/fridays/1/abc-320.jpg", store.URL("fridays/1/abc-320.jpg"))
+	assert.Equal(t, pizza.ErrBlobKey, badErr)
+}
@@ -0,0 +1,229 @@
+package pizza
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/calendar/v3"
+)
+
+// ImportYears is how far back the import looks by default.
+var ImportYears = 3
+
+var eventUIDPattern = regexp.MustCompile(`^[0-9a-v]+$`)
+
+// IsPizzaEvent reports whether a calendar event is a pizza party, made by the
+// service or by hand before it.
+func IsPizzaEvent(event *calendar.Event) bool {
+	return strings.Contains(strings.ToLower(event.Summary), "pizza") || strings.HasPrefix(event.Description, EventDescription)
+}
+
+// ImportedFriday rebuilds the event and the RSVPs of its guests who accepted
+// from a calendar event. Guests' emails are as on the calendar. It reports
+// false for all-day events, which have no start time.
+func ImportedFriday(event *calendar.Event) (Friday, []RSVP, bool) {
+	if event.Start == nil || event.End == nil || len(event.Start.DateTime) == 0 {
+		return Friday{}, nil, false
+	}
+	start, err := time.Parse(time.RFC3339, event.Start.DateTime)
+	if err != nil {
+		return Friday{}, nil, false
+	}
+	end, err := time.Parse(time.RFC3339, event.End.DateTime)
+	if err != nil {
+		return Friday{}, nil, false
+	}
+	friday := Friday{Start: start, End: end}
+	// events made by the service have its ID, and others that can't be used
+	// in links fall back to the start time like events did before UIDs
+	if eventUIDPattern.MatchString(event.Id) {
+		friday.UID = event.Id
+	} else {
+		friday.UID = strconv.FormatInt(start.Unix(), 10)
+	}
+
+	var rsvps []RSVP
+	for _, attendee := range event.Attendees {
+		if attendee.Organizer || attendee.Self || attendee.Resource || attendee.ResponseStatus != "accepted" {
+			continue
+		}
+		rsvps = append(rsvps, RSVP{
+			Email:     strings.ToLower(attendee.Email),
+			FridayID:  friday.ID(),
+			PlusOnes:  int(attendee.AdditionalGuests),
+			Status:    RSVPStatusConfirmed,
+			UpdatedAt: start,
+		})
+	}
+	return friday, rsvps, true
+}
+
+// ImportResult counts what an import added.
+type ImportResult struct {
+	Events int
+	RSVPs  int
+	// Skipped are pizza events without a start time, and Strangers are guests
+	// who aren't friends
+	Skipped   int
+	Strangers int
+}
+
+// ImportCalendarHistory adds the pizza events on the calendar since the time,
+// and who came to them, that aren't in the database yet. Running it again
+// adds nothing new.
+func ImportCalendarHistory(since time.Time) (ImportResult, error) {
+	result := ImportResult{}
+	events, err := ListPastEvents(since)
+	if err != nil {
+		return result, err
+	}
+	for _, event := range events {
+		if !IsPizzaEvent(event) {
+			continue
+		}
+		friday, rsvps, ok := ImportedFriday(event)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		existing, err := GetFriday(friday.ID())
+		if err == nil && existing == nil {
+			existing, err = GetFridayAt(friday.Start)
+		}
+		if err != nil {
+			return result, err
+		}
+		if existing == nil {
+			if err = CreateFriday(friday); err != nil {
+				return result, err
+			}
+			result.Events++
+			existing = &friday
+		}
+		for _, rsvp := range rsvps {
+			primary, err := GetPrimaryEmail(rsvp.Email)
+			if err != nil {
+				return result, err
+			} else if len(primary) == 0 {
+				result.Strangers++
+				continue
+			}
+			rsvp.Email, rsvp.FridayID = primary, existing.ID()
+			added, err := ImportFridayRSVP(rsvp)
+			if err != nil {
+				return result, err
+			} else if added {
+				result.RSVPs++
+			}
+		}
+	}
+	return result, nil
+}
+
+// ImportJob is the state of the latest import.
+type ImportJob struct {
+	Running  bool
+	Since    time.Time
+	Started  time.Time
+	Finished time.Time
+	Result   ImportResult
+	Error    string
+}
+
+var importJob struct {
+	sync.Mutex
+	ImportJob
+}
+
+// StartImport runs ImportCalendarHistory in the background, unless an import
+// is already running. It reports whether it started one.
+func StartImport(since time.Time) bool {
+	importJob.Lock()
+	defer importJob.Unlock()
+	if importJob.Running {
+		return false
+	}
+	importJob.ImportJob = ImportJob{Running: true, Since: since, Started: time.Now()}
+	go func() {
+		result, err := ImportCalendarHistory(since)
+		importJob.Lock()
+		defer importJob.Unlock()
+		importJob.Running = false
+		importJob.Finished = time.Now()
+		importJob.Result = result
+		if err != nil {
+			Log.Error("calendar import failed", zap.Error(err))
+			importJob.Error = err.Error()
+		} else {
+			Log.Info("calendar import finished", zap.Any("result", result))
+		}
+	}()
+	return true
+}
+
+type AdminImportPageData struct {
+	AdminView
+	Since    string
+	Started  string
+	Finished string
+	Running  bool
+	Result   ImportResult
+	Error    string
+	// Busy is set when an import was asked for while one was running
+	Busy bool
+}
+
+// HandleAdminImport starts an import of past pizza events from the calendar
+// and shows how the latest one went.
+func HandleAdminImport(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/import.html")
+	if err != nil {
+		Log.Error("template admin import failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	estZone, _ := time.LoadLocation("America/New_York")
+	data := AdminImportPageData{AdminView: newAdminView(w, r), Since: time.Now().In(estZone).AddDate(-ImportYears, 0, 0).Format("2006-01-02")}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		since, err := time.ParseInLocation("2006-01-02", r.PostForm.Get("since"), estZone)
+		if err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		if StartImport(since) {
+			http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+			return
+		}
+		data.Busy = true
+	}
+
+	importJob.Lock()
+	job := importJob.ImportJob
+	importJob.Unlock()
+	if !job.Started.IsZero() {
+		data.Since = job.Since.In(estZone).Format("2006-01-02")
+		data.Started = FormatTime(job.Started)
+		data.Running = job.Running
+		data.Result = job.Result
+		data.Error = job.Error
+		if !job.Finished.IsZero() {
+			data.Finished = FormatTime(job.Finished)
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,54 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestIsPizzaEvent(t *testing.T) {
+	assert.True(t, pizza.IsPizzaEvent(&calendar.Event{Summary: "Pizza Friday"}))
+	assert.True(t, pizza.IsPizzaEvent(&calendar.Event{Summary: "Friday!", Description: pizza.EventDescription + "\n\nGuests:"}))
+	assert.False(t, pizza.IsPizzaEvent(&calendar.Event{Summary: "Dentist"}))
+}
+
+func TestImportedFriday(t *testing.T) {
+	// GIVEN a recurring event made by hand, with an ID that can't go in links
+	event := &calendar.Event{
+		Id:    "abc123_20230407T213000Z",
+		Start: &calendar.EventDateTime{DateTime: "2023-04-07T17:30:00-04:00"},
+		End:   &calendar.EventDateTime{DateTime: "2023-04-07T21:30:00-04:00"},
+		Attendees: []*calendar.EventAttendee{
+			{Email: "host@rsvp.pizza", Organizer: true, Self: true, ResponseStatus: "accepted"},
+			{Email: "Believe@TedLasso.com", ResponseStatus: "accepted", AdditionalGuests: 2},
+			{Email: "roy@kent.com", ResponseStatus: "declined"},
+			{Email: "jamie@tartt.com", ResponseStatus: "needsAction"},
+		},
+	}
+
+	// WHEN
+	friday, rsvps, ok := pizza.ImportedFriday(event)
+
+	// THEN the event falls back to its start time as its ID and only the
+	// friend who accepted came
+	require.True(t, ok)
+	assert.Equal(t, "1680903000", friday.ID())
+	assert.Equal(t, 4*time.Hour, friday.EndTime().Sub(friday.Start))
+	assert.Equal(t, []pizza.RSVP{{
+		Email: "believe@tedlasso.com", FridayID: "1680903000", PlusOnes: 2,
+		Status: pizza.RSVPStatusConfirmed, UpdatedAt: friday.Start,
+	}}, rsvps)
+
+	// WHEN the event was made by the service, or lasts all day
+	event.Id = "01g7d2m9r0"
+	made, _, _ := pizza.ImportedFriday(event)
+	_, _, allDay := pizza.ImportedFriday(&calendar.Event{Start: &calendar.EventDateTime{Date: "2023-04-07"}, End: &calendar.EventDateTime{Date: "2023-04-08"}})
+
+	// THEN
+	assert.Equal(t, "01g7d2m9r0", made.ID())
+	assert.False(t, allDay)
+}
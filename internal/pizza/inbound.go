@@ -0,0 +1,242 @@
+package pizza
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInboundMalformed = errors.New("malformed inbound email")
+
+// InboundEmail is an email received through the provider's inbound parse hook.
+// Authenticated is set when the provider reports that DMARC passed, or that
+// DKIM passed for the From domain. SPF alone only vouches for the envelope
+// sender, which needn't be the From address.
+type InboundEmail struct {
+	From          string
+	To            []string
+	Subject       string
+	MessageID     string
+	Text          string
+	Authenticated bool
+}
+
+// replyFridayPattern finds the event ID, a start time or a ULID in base32hex,
+// after the plus in the reply address.
+var replyFridayPattern = regexp.MustCompile(`\+([0-9a-v]+)@`)
+
+// FridayID returns the Friday encoded in the reply address, if any.
+func (e InboundEmail) FridayID() string {
+	for _, to := range e.To {
+		if m := replyFridayPattern.FindStringSubmatch(to); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// ParseSendGridInbound reads a SendGrid inbound parse form post.
+func ParseSendGridInbound(r *http.Request) (InboundEmail, error) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	from, err := mail.ParseAddress(r.FormValue("from"))
+	if err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	email := InboundEmail{
+		From:    strings.ToLower(from.Address),
+		To:      parseAddressList(r.FormValue("to")),
+		Subject: r.FormValue("subject"),
+		Text:    r.FormValue("text"),
+	}
+	email.Authenticated = sendGridDKIMPassed(r.FormValue("dkim"), email.From)
+	if headers, err := mail.ReadMessage(strings.NewReader(r.FormValue("headers") + "\r\n")); err == nil {
+		email.MessageID = headers.Header.Get("Message-Id")
+	}
+	return email, nil
+}
+
+// ParseSESInbound reads an Amazon SES receipt notification out of the SNS
+// message. The receipt rule must include the raw content.
+func ParseSESInbound(message []byte) (InboundEmail, error) {
+	var notification struct {
+		Receipt struct {
+			DKIMVerdict  struct{ Status string } `json:"dkimVerdict"`
+			DMARCVerdict struct{ Status string } `json:"dmarcVerdict"`
+		} `json:"receipt"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(message, &notification); err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(notification.Content))
+	if err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	text, err := plainTextBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return InboundEmail{}, ErrInboundMalformed
+	}
+	email := InboundEmail{
+		From:      strings.ToLower(from.Address),
+		To:        parseAddressList(msg.Header.Get("To")),
+		Subject:   msg.Header.Get("Subject"),
+		MessageID: msg.Header.Get("Message-Id"),
+		Text:      text,
+	}
+	// SES only says whether DKIM passed, not for which domain, so it counts
+	// when every signature is from the From domain
+	email.Authenticated = notification.Receipt.DMARCVerdict.Status == "PASS" ||
+		(notification.Receipt.DKIMVerdict.Status == "PASS" && dkimSignersAligned(msg.Header["Dkim-Signature"], email.From))
+	return email, nil
+}
+
+// emailDomain is the domain of the address.
+func emailDomain(address string) string {
+	return strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+}
+
+// alignedDomain reports whether a DKIM signing domain lines up with the From
+// address: the same domain or one of its parents, like DMARC's relaxed
+// alignment.
+func alignedDomain(signer, from string) bool {
+	signer = strings.Trim(strings.ToLower(signer), ". ")
+	domain := emailDomain(from)
+	return strings.Contains(signer, ".") && (domain == signer || strings.HasSuffix(domain, "."+signer))
+}
+
+// sendGridDKIMPassed reads SendGrid's DKIM results, like
+// "{@tedlasso.com : pass, @sendgrid.net : pass}", for one that passed for the
+// From domain.
+func sendGridDKIMPassed(results, from string) bool {
+	for _, result := range strings.Split(strings.Trim(results, "{}"), ",") {
+		signer, verdict, ok := strings.Cut(result, ":")
+		if ok && strings.TrimSpace(verdict) == "pass" && alignedDomain(strings.TrimPrefix(strings.TrimSpace(signer), "@"), from) {
+			return true
+		}
+	}
+	return false
+}
+
+// dkimSignersAligned reports whether the message has DKIM signatures and all
+// of them are from the From domain.
+func dkimSignersAligned(signatures []string, from string) bool {
+	if len(signatures) == 0 {
+		return false
+	}
+	for _, signature := range signatures {
+		aligned := false
+		for _, tag := range strings.Split(signature, ";") {
+			if name, value, ok := strings.Cut(tag, "="); ok && strings.TrimSpace(name) == "d" {
+				aligned = alignedDomain(value, from)
+			}
+		}
+		if !aligned {
+			return false
+		}
+	}
+	return true
+}
+
+func parseAddressList(list string) []string {
+	addresses, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil
+	}
+	emails := make([]string, len(addresses))
+	for i, a := range addresses {
+		emails[i] = strings.ToLower(a.Address)
+	}
+	return emails
+}
+
+// plainTextBody finds the text/plain part of a possibly multipart body,
+// undoing its Content-Transfer-Encoding.
+func plainTextBody(contentType string, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		b, err := io.ReadAll(transferDecoder(encoding, body))
+		return string(b), err
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return "", err
+		}
+		partType := part.Header.Get("Content-Type")
+		if strings.HasPrefix(partType, "text/plain") || strings.HasPrefix(partType, "multipart/") {
+			// the multipart reader already decodes quoted-printable parts and
+			// drops their header, but leaves base64 to us
+			return plainTextBody(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+		}
+	}
+}
+
+// transferDecoder decodes a body in the Content-Transfer-Encoding, and passes
+// 7bit, 8bit and binary bodies through.
+func transferDecoder(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// the decoder skips the line breaks
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// ParseRSVPReply reads the first line of a reply that isn't quoted, accepting
+// replies like "yes", "no", "+2", or "yes +1". The line must be nothing but
+// those, so "in a meeting" isn't read as a yes. The plus ones count is only
+// meaningful when attending.
+func ParseRSVPReply(text string) (attending bool, plusOnes int, ok bool) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, ">") {
+			continue
+		}
+		fields := strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+			return r == ' ' || r == ',' || r == '.' || r == '!'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		declined := false
+		for _, field := range fields {
+			switch field {
+			case "yes", "y", "yep", "yeah", "in":
+				attending = true
+				continue
+			case "no", "n", "nope", "out":
+				declined = true
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimPrefix(field, "+"))
+			if !strings.HasPrefix(field, "+") || err != nil || n < 0 {
+				return false, 0, false
+			}
+			attending, plusOnes = true, n
+		}
+		if attending && declined {
+			return false, 0, false
+		}
+		return attending, plusOnes, true
+	}
+	return false, 0, false
+}
@@ -0,0 +1,155 @@
+package pizza_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestParseRSVPReply(t *testing.T) {
+	tests := []struct {
+		text      string
+		attending bool
+		plusOnes  int
+		ok        bool
+	}{
+		{"Yes!", true, 0, true},
+		{"yes, +2\n\n> On Friday you wrote:\n> no", true, 2, true},
+		{"+1", true, 1, true},
+		{"\n> quoted yes\nNope!", false, 0, true},
+		{"maybe", false, 0, false},
+		{"in a meeting, will reply later", false, 0, false},
+		{"yes but no", false, 0, false},
+		{"No.", false, 0, true},
+		{"", false, 0, false},
+	}
+	for _, test := range tests {
+		attending, plusOnes, ok := pizza.ParseRSVPReply(test.text)
+		assert.Equal(t, test.attending, attending, test.text)
+		assert.Equal(t, test.plusOnes, plusOnes, test.text)
+		assert.Equal(t, test.ok, ok, test.text)
+	}
+}
+
+func TestParseSESInbound(t *testing.T) {
+	// GIVEN
+	content := "From: Ted Lasso <Believe@TedLasso.com>\r\n" +
+		"To: rsvp+1680903000@rsvp.pizza\r\n" +
+		"Subject: Pizza Friday\r\n" +
+		"Message-Id: <abc@tedlasso.com>\r\n" +
+		"Content-Type: multipart/alternative; boundary=XYZ\r\n\r\n" +
+		"--XYZ\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nyes +1\r\n" +
+		"--XYZ\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<p>yes +1</p>\r\n" +
+		"--XYZ--\r\n"
+	message, _ := json.Marshal(map[string]any{
+		"receipt": map[string]any{"dmarcVerdict": map[string]string{"status": "PASS"}},
+		"content": content,
+	})
+
+	// WHEN
+	email, err := pizza.ParseSESInbound(message)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, "believe@tedlasso.com", email.From)
+	assert.Equal(t, "1680903000", email.FridayID())
+	assert.Equal(t, "<abc@tedlasso.com>", email.MessageID)
+	assert.Equal(t, "yes +1", email.Text)
+	assert.True(t, email.Authenticated)
+}
+
+func TestParseSESInboundEncoded(t *testing.T) {
+	parse := func(content string) pizza.InboundEmail {
+		message, _ := json.Marshal(map[string]any{
+			"receipt": map[string]any{"dmarcVerdict": map[string]string{"status": "PASS"}},
+			"content": "From: believe@tedlasso.com\r\nTo: rsvp+1680903000@rsvp.pizza\r\n" + content,
+		})
+		email, err := pizza.ParseSESInbound(message)
+		assert.Nil(t, err)
+		return email
+	}
+
+	// GIVEN a multipart reply with a base64 text part split across lines
+	// WHEN
+	email := parse("Content-Type: multipart/alternative; boundary=XYZ\r\n\r\n" +
+		"--XYZ\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		"eWVzICsxDQoNCj4gT24gRnJpZGF5IFRlZCB3cm90ZToNCj4g\r\nUlNWUCDigJQgcGl6emE=\r\n" +
+		"--XYZ--\r\n")
+	// THEN
+	assert.Equal(t, "yes +1\r\n\r\n> On Friday Ted wrote:\r\n> RSVP — pizza", email.Text)
+	attending, plusOnes, ok := pizza.ParseRSVPReply(email.Text)
+	assert.True(t, ok)
+	assert.True(t, attending)
+	assert.Equal(t, 1, plusOnes)
+
+	// GIVEN a single part quoted-printable reply
+	// WHEN
+	email = parse("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: Quoted-Printable\r\n\r\n" +
+		"yes, +=\r\n2\r\n\r\n> RSVP =E2=80=94 pizza")
+	// THEN
+	assert.Equal(t, "yes, +2\r\n\r\n> RSVP — pizza", email.Text)
+	attending, plusOnes, ok = pizza.ParseRSVPReply(email.Text)
+	assert.True(t, ok)
+	assert.True(t, attending)
+	assert.Equal(t, 2, plusOnes)
+}
+
+func TestParseSESInboundDKIM(t *testing.T) {
+	parse := func(dkimDomain string, spf string) pizza.InboundEmail {
+		content := "DKIM-Signature: v=1; a=rsa-sha256; d=" + dkimDomain + "; s=s1; b=abc\r\n" +
+			"From: Ted Lasso <believe@mail.tedlasso.com>\r\n" +
+			"To: rsvp@rsvp.pizza\r\n\r\nyes\r\n"
+		message, _ := json.Marshal(map[string]any{
+			"receipt": map[string]any{
+				"spfVerdict":   map[string]string{"status": spf},
+				"dkimVerdict":  map[string]string{"status": "PASS"},
+				"dmarcVerdict": map[string]string{"status": "FAIL"},
+			},
+			"content": content,
+		})
+		email, err := pizza.ParseSESInbound(message)
+		assert.Nil(t, err)
+		return email
+	}
+
+	// THEN DKIM counts for the From domain and its parents only, whatever SPF says
+	assert.True(t, parse("tedlasso.com", "FAIL").Authenticated)
+	assert.True(t, parse("mail.tedlasso.com", "FAIL").Authenticated)
+	assert.False(t, parse("evil.com", "PASS").Authenticated)
+	assert.False(t, parse("com", "PASS").Authenticated)
+}
+
+func TestParseSendGridInboundDKIM(t *testing.T) {
+	parse := func(spf, dkim string) pizza.InboundEmail {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("from", "Ted Lasso <believe@tedlasso.com>")
+		form.WriteField("to", "rsvp@rsvp.pizza")
+		form.WriteField("text", "yes")
+		form.WriteField("SPF", spf)
+		form.WriteField("dkim", dkim)
+		form.Close()
+		r := httptest.NewRequest("POST", "/hooks/inbound/sendgrid", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		email, err := pizza.ParseSendGridInbound(r)
+		assert.Nil(t, err)
+		return email
+	}
+
+	// THEN only DKIM for the From domain counts
+	assert.True(t, parse("fail", "{@tedlasso.com : pass, @sendgrid.net : pass}").Authenticated)
+	assert.False(t, parse("pass", "{@evil.com : pass}").Authenticated)
+	assert.False(t, parse("pass", "{@tedlasso.com : fail}").Authenticated)
+}
+
+func TestInboundFridayID(t *testing.T) {
+	assert.Equal(t, "1680903000", pizza.InboundEmail{To: []string{"rsvp+1680903000@rsvp.pizza"}}.FridayID())
+	assert.Equal(t, "01h0000000abcdefghijklmnop", pizza.InboundEmail{To: []string{"hi@tedlasso.com", "rsvp+01h0000000abcdefghijklmnop@rsvp.pizza"}}.FridayID())
+	assert.Equal(t, "", pizza.InboundEmail{To: []string{"rsvp@rsvp.pizza"}}.FridayID())
+}
@@ -0,0 +1,94 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type InviteEmailData struct {
+	Name    string
+	RSVPURL string
+	// ReplyDate is the Friday friends can RSVP to by replying, if any
+	ReplyDate string
+}
+
+type InvitePageData struct {
+	// Expired is set when the friend was sent here by a link that expired
+	Expired   bool
+	Sent      bool
+	FormToken string
+	Captcha   *CaptchaWidget
+}
+
+// HandleRequestInvite emails a new invite link to a friend whose link expired.
+// The page looks the same whether or not the email belongs to a friend.
+func HandleRequestInvite(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/invite.html")
+	if err != nil {
+		Log.Error("template invite failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := InvitePageData{
+		Expired:   len(r.Form.Get("expired")) > 0,
+		FormToken: FormToken(),
+		Captcha:   CaptchaWidgetFor(),
+	}
+
+	if r.Method == http.MethodPost {
+		if !CheckCaptcha(r, r.PostForm) {
+			Handle4xx(w, r)
+			return
+		}
+		data.Sent = true
+		email := strings.ToLower(r.PostForm.Get("email"))
+		if reason := CheckSpam(r.PostForm); len(reason) > 0 {
+			Log.Info("invite request looks like spam", zap.String("reason", reason), zap.String("email", email))
+		} else if allowed, err := IsFriendAllowed(email); err != nil {
+			Log.Error("error checking email for invite request", zap.Error(err))
+			Handle500(w, r)
+			return
+		} else if allowed {
+			sendInvite(email)
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+func sendInvite(email string) {
+	name, _ := GetCachedFriendName(email)
+	data := InviteEmailData{Name: name, RSVPURL: InviteURL(BaseURL, email)}
+	// replies RSVP to the next Friday that is open
+	replyTo := ""
+	if fridays, err := GetCachedFridays(UpcomingDays); err == nil {
+		for _, friday := range fridays {
+			if friday.IsOpen() {
+				if replyTo = ReplyAddress(friday.ID()); len(replyTo) > 0 {
+					data.ReplyDate = FormatTime(friday.Start)
+				}
+				break
+			}
+		}
+	}
+	msg, err := RenderEmail("invite", data)
+	if err != nil {
+		Log.Error("invite template failure", zap.Error(err))
+		return
+	}
+	msg.To = email
+	msg.ReplyTo = replyTo
+	if err = SendEmail(msg); err != nil {
+		Log.Warn("failed to send invite", zap.Error(err), zap.String("email", email))
+	}
+}
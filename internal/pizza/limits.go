@@ -0,0 +1,215 @@
+package pizza
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MaxBodySize is the largest request body accepted by routes without a limit
+// of their own.
+var MaxBodySize int64 = 1 << 20
+
+// BodyLimits are the path prefixes allowed bodies of a different size than
+// MaxBodySize, such as inbound email with attachments and party photos. The
+// import form only sends a date, the events come from the calendar.
+var BodyLimits = map[string]int64{
+	"/hooks/inbound/": 10 << 20,
+	"/admin/fridays/": 32 << 20,
+	"/admin/import":   64 << 10,
+}
+
+func bodyLimit(path string) int64 {
+	limit, longest := MaxBodySize, 0
+	for prefix, n := range BodyLimits {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			limit, longest = n, len(prefix)
+		}
+	}
+	return limit
+}
+
+// LimitBody caps request bodies at the route's limit. Requests whose
+// Content-Length is over it get a 413 before they reach the handler. Other
+// bodies stream to the handler, and reading past the limit fails with an
+// *http.MaxBytesError, see bodyTooLarge, so uploads are never held in memory
+// here.
+func LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := bodyLimit(r.URL.Path)
+		if r.ContentLength > limit {
+			writeTooLarge(w, r)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyTooLarge reports whether reading the body failed because it went over
+// the limit set by LimitBody, for handlers to answer with writeTooLarge.
+func bodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// SubmitLockWait is how long an RSVP waits for another from the same email,
+// e.g. from a double click, to finish before giving up.
+var SubmitLockWait = 10 * time.Second
+
+var (
+	emailLocksMu sync.Mutex
+	// emailLocks holds a channel for each email with an RSVP in flight, which
+	// is closed when it finishes
+	emailLocks = make(map[string]chan struct{})
+)
+
+// LockEmail waits up to wait for other RSVPs from the email to finish, so
+// they don't invite the friend to the calendar in parallel. The returned
+// unlock must be called once the RSVP is done.
+func LockEmail(email string, wait time.Duration) (unlock func(), ok bool) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for {
+		emailLocksMu.Lock()
+		done, busy := emailLocks[email]
+		if !busy {
+			done = make(chan struct{})
+			emailLocks[email] = done
+			emailLocksMu.Unlock()
+			return func() {
+				emailLocksMu.Lock()
+				delete(emailLocks, email)
+				emailLocksMu.Unlock()
+				close(done)
+			}, true
+		}
+		emailLocksMu.Unlock()
+		select {
+		case <-done:
+		case <-timeout.C:
+			return nil, false
+		}
+	}
+}
+
+// SubmitLimit is a token bucket: Burst RSVPs may be sent at once, then one
+// more every Every. A negative Burst turns the limit off.
+type SubmitLimit struct {
+	Burst int           `yaml:"burst"`
+	Every time.Duration `yaml:"every"`
+}
+
+// SubmitIPLimit and SubmitEmailLimit are how fast each IP address and each
+// email may send RSVPs, so a misbehaving client can't spend the Fauna and
+// calendar quota. An IP address gets more, as friends share one at the party.
+var (
+	SubmitIPLimit    = SubmitLimit{Burst: 20, Every: 30 * time.Second}
+	SubmitEmailLimit = SubmitLimit{Burst: 5, Every: time.Minute}
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket for each key, by the limit it points to so
+// the config applies once it is loaded.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   *SubmitLimit
+	buckets map[string]*tokenBucket
+}
+
+// rateLimiterSweep is how many buckets are kept before full ones, which are
+// no different from new ones, are dropped.
+const rateLimiterSweep = 10000
+
+// allow takes a token for the key at now, returning how long until the next
+// one when there is none.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	limit := *l.limit
+	if limit.Burst < 0 || limit.Every <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = map[string]*tokenBucket{}
+	}
+	refill := func(b *tokenBucket) {
+		b.tokens += float64(now.Sub(b.last)) / float64(limit.Every)
+		if b.tokens > float64(limit.Burst) {
+			b.tokens = float64(limit.Burst)
+		}
+		b.last = now
+	}
+	if len(l.buckets) >= rateLimiterSweep {
+		for k, b := range l.buckets {
+			if refill(b); b.tokens >= float64(limit.Burst) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	refill(b)
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(limit.Every))
+	}
+	b.tokens--
+	return true, 0
+}
+
+var (
+	submitIPLimiter    = &rateLimiter{limit: &SubmitIPLimit}
+	submitEmailLimiter = &rateLimiter{limit: &SubmitEmailLimit}
+)
+
+// LimitSubmit is the router middleware that turns away RSVPs over
+// SubmitIPLimit or SubmitEmailLimit with a 429 before they reach
+// HandleSubmit. Other routes go straight through.
+func LimitSubmit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/submit" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := time.Now()
+		ok, wait := submitIPLimiter.allow(requestIP(r), now)
+		email := r.URL.Query().Get("email")
+		if len(email) == 0 {
+			email = friendFromCookie(r)
+		}
+		if email = strings.ToLower(strings.TrimSpace(email)); ok && len(email) > 0 {
+			ok, wait = submitEmailLimiter.allow(email, now)
+		}
+		if !ok {
+			Log.Debug("rsvp rate limited", zap.String("ip", requestIP(r)))
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "too many RSVPs, try again in a minute", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeTooLarge(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/hooks/") {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,121 @@
+package pizza_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitBody(t *testing.T) {
+	// GIVEN
+	pizza.MaxBodySize = 16
+	defer func() { pizza.MaxBodySize = 1 << 20 }()
+	var readErr error
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		readErr = err
+		w.Write(body)
+	}
+	schema := pizza.Schema{Body: []pizza.Param{{Name: "note"}}}
+	send := func(handler http.Handler, path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	validated := pizza.LimitBody(pizza.Validate(schema, echo))
+
+	// WHEN
+	small := send(validated, "/submit", `{"note":"12345"}`, false)
+	large := send(validated, "/submit", `{"note":"123456"}`, false)
+	chunked := send(validated, "/api/v1/rsvp/1", `{"note":"123456"}`, true)
+	inbound := send(validated, "/hooks/inbound/sendgrid", `{"note":"123456"}`, false)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, small.Code)
+	assert.Equal(t, `{"note":"12345"}`, small.Body.String())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, large.Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, chunked.Code)
+	assert.JSONEq(t, `{"error":"request body too large"}`, chunked.Body.String())
+	assert.Equal(t, http.StatusOK, inbound.Code)
+
+	// WHEN a handler reads a chunked body past the limit itself
+	send(pizza.LimitBody(http.HandlerFunc(echo)), "/submit", `{"note":"123456"}`, true)
+
+	// THEN it is streamed up to the limit and no further
+	var tooLarge *http.MaxBytesError
+	assert.ErrorAs(t, readErr, &tooLarge)
+}
+
+func TestLockEmail(t *testing.T) {
+	// GIVEN
+	unlock, ok := pizza.LockEmail("believe@tedlasso.com", time.Second)
+	assert.True(t, ok)
+
+	// WHEN another RSVP from the same email is in flight
+	_, locked := pizza.LockEmail("believe@tedlasso.com", 10*time.Millisecond)
+	other, otherOK := pizza.LockEmail("roy@kent.com", 10*time.Millisecond)
+
+	// THEN only that email waits
+	assert.False(t, locked)
+	assert.True(t, otherOK)
+	other()
+
+	// WHEN the first RSVP finishes while the second waits
+	acquired := make(chan bool)
+	go func() {
+		unlock, ok := pizza.LockEmail("believe@tedlasso.com", time.Second)
+		if ok {
+			unlock()
+		}
+		acquired <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	unlock()
+
+	// THEN
+	assert.True(t, <-acquired)
+}
+
+func TestLimitSubmit(t *testing.T) {
+	// GIVEN
+	defer func(ip, email pizza.SubmitLimit) { pizza.SubmitIPLimit, pizza.SubmitEmailLimit = ip, email }(pizza.SubmitIPLimit, pizza.SubmitEmailLimit)
+	pizza.SubmitIPLimit = pizza.SubmitLimit{Burst: 3, Every: time.Hour}
+	pizza.SubmitEmailLimit = pizza.SubmitLimit{Burst: 2, Every: time.Hour}
+	handler := pizza.LimitSubmit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// WHEN / THEN each email gets its burst, and the address all of them
+	assert.Equal(t, http.StatusOK, send("/submit?email=limit-ted@tedlasso.com", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, send("/submit?email=Limit-Ted@tedlasso.com", "10.0.0.2").Code)
+	limited := send("/submit?email=limit-ted@tedlasso.com", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "3600", limited.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, send("/submit?email=limit-roy@kent.com", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, send("/submit?email=limit-keeley@jones.com", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("/submit?email=limit-jamie@tartt.com", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, send("/rsvp/1/edit", "10.0.0.1").Code)
+
+	// WHEN the limit is turned off
+	pizza.SubmitIPLimit.Burst = -1
+	pizza.SubmitEmailLimit.Burst = -1
+
+	// THEN
+	assert.Equal(t, http.StatusOK, send("/submit?email=limit-ted@tedlasso.com", "10.0.0.1").Code)
+}
@@ -0,0 +1,175 @@
+package pizza
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var linkSecret []byte
+
+// InviteTTL is how long a personal invite link works.
+var InviteTTL = 30 * 24 * time.Hour
+
+// LinkClockSkew is how long after they expire links and RSVP codes are still
+// accepted, so a clock that is a little off, or smearing a leap second, doesn't
+// refuse one that was just sent.
+var LinkClockSkew = 2 * time.Minute
+
+func newLinkSecret(secret string) {
+	if len(secret) > 0 {
+		linkSecret = []byte(secret)
+		return
+	}
+	// links signed with a random secret stop working when the server restarts
+	linkSecret = make([]byte, 32)
+	if _, err := rand.Read(linkSecret); err != nil {
+		panic(fmt.Sprintf("could not generate link secret: %v", err))
+	}
+}
+
+// SignLink returns a signature over the kind of link and its values, binding a
+// personal link to the friend it was sent to.
+func SignLink(kind string, values ...string) string {
+	mac := hmac.New(sha256.New, linkSecret)
+	mac.Write([]byte(kind))
+	for _, v := range values {
+		mac.Write([]byte{0})
+		mac.Write([]byte(v))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:18])
+}
+
+func VerifyLink(sig, kind string, values ...string) bool {
+	return hmac.Equal([]byte(sig), []byte(SignLink(kind, values...)))
+}
+
+// LinkExpiry is when a link expires, as signed and sent in its query string.
+func LinkExpiry(expires time.Time) string {
+	return strconv.FormatInt(expires.Unix(), 10)
+}
+
+// LinkExpired reports whether a link's expiry, as made by LinkExpiry, has passed
+// by more than LinkClockSkew. Malformed expiries have always passed.
+func LinkExpired(exp string, now time.Time) bool {
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	return err != nil || now.After(time.Unix(expires, 0).Add(LinkClockSkew))
+}
+
+// sealLink encrypts the value for a link of the kind, so the link can name
+// the friend it was sent to without showing their email. The extra values are
+// authenticated but not encrypted.
+func sealLink(kind, value string, extra ...string) string {
+	gcm := linkCipher(kind)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("could not generate link nonce: %v", err))
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(strings.Join(extra, "\x00")))
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// openLink returns the value sealed by sealLink, if the token and the extra
+// values are the ones it was sealed with.
+func openLink(kind, token string, extra ...string) (string, bool) {
+	gcm := linkCipher(kind)
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", false
+	}
+	value, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(strings.Join(extra, "\x00")))
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+// linkCipher is keyed from the link secret, a different key for each kind of
+// link.
+func linkCipher(kind string) cipher.AEAD {
+	mac := hmac.New(sha256.New, linkSecret)
+	mac.Write([]byte("seal:" + kind))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(fmt.Sprintf("could not make link cipher: %v", err))
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("could not make link cipher: %v", err))
+	}
+	return gcm
+}
+
+// InviteURL is the personal RSVP link for a friend, which works for InviteTTL.
+// The friend's email is sealed in the link rather than shown. Having the link
+// proves nothing, so RSVPs from it still have to be confirmed from the
+// friend's inbox unless the browser is already theirs.
+func InviteURL(baseURL, email string) string {
+	exp := LinkExpiry(time.Now().Add(InviteTTL))
+	q := url.Values{}
+	q.Set("invite", sealLink("invite", email, exp))
+	q.Set("expires", exp)
+	return fmt.Sprintf("%s/?%s", strings.TrimRight(baseURL, "/"), q.Encode())
+}
+
+// InviteEmail is who the invite link with the query was sent to. It is false
+// when the link was not made by InviteURL.
+func InviteEmail(q url.Values) (string, bool) {
+	return openLink("invite", q.Get("invite"), q.Get("expires"))
+}
+
+// EditRSVPURL is the personal link for changing an RSVP. It does not carry the
+// email so a forwarded link can't be used without knowing who it was sent to.
+func EditRSVPURL(rsvp RSVP) string {
+	return fmt.Sprintf("/rsvp/%s/edit?sig=%s", rsvp.ID, url.QueryEscape(SignLink("rsvp", rsvp.ID, rsvp.Email)))
+}
+
+// CancelRSVPURL is the link to cancel the RSVP, signed like its edit link.
+func CancelRSVPURL(rsvp RSVP) string {
+	return fmt.Sprintf("/cancel?rsvp=%s&sig=%s", rsvp.ID, url.QueryEscape(SignLink("rsvp", rsvp.ID, rsvp.Email)))
+}
+
+// ICalFeedURL is the friend's own calendar feed of the parties they RSVPed
+// to, for calendar apps other than Google's to subscribe to. The friend's
+// email is sealed in the link, which doesn't expire.
+func ICalFeedURL(email string) string {
+	return BaseURL + "/ical/" + sealLink("ical", email)
+}
+
+// ParseICalToken is the email the token of an ICalFeedURL was made for. Feeds
+// subscribed to before the email was sealed have the email in the clear and
+// a signature, and keep working.
+func ParseICalToken(token string) (string, bool) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return openLink("ical", token)
+	}
+	email, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !VerifyLink(sig, "ical", string(email)) {
+		return "", false
+	}
+	return string(email), true
+}
+
+// ArrivalURL is the personal link for telling the host when the friend will
+// get to the party.
+func ArrivalURL(rsvp RSVP) string {
+	return fmt.Sprintf("/arrival?rsvp=%s&sig=%s", rsvp.ID, url.QueryEscape(SignLink("rsvp", rsvp.ID, rsvp.Email)))
+}
+
+// MaskEmail hides most of the local part of an email, e.g. b******@tedlasso.com.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return email
+	}
+	return email[:1] + strings.Repeat("*", at-1) + email[at:]
+}
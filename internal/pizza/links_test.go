@@ -0,0 +1,177 @@
+package pizza_test
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestSignLink(t *testing.T) {
+	// GIVEN
+	sig := pizza.SignLink("rsvp", "12345", "believe@tedlasso.com")
+
+	// THEN
+	assert.True(t, pizza.VerifyLink(sig, "rsvp", "12345", "believe@tedlasso.com"))
+	assert.False(t, pizza.VerifyLink(sig, "rsvp", "12345", "roy@kent.com"))
+	assert.False(t, pizza.VerifyLink(sig, "invite", "12345", "believe@tedlasso.com"))
+	assert.False(t, pizza.VerifyLink("", "rsvp", "12345", "believe@tedlasso.com"))
+}
+
+func TestInviteURL(t *testing.T) {
+	// WHEN
+	link, err := url.Parse(pizza.InviteURL("https://rsvp.pizza/", "believe@tedlasso.com"))
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, "rsvp.pizza", link.Host)
+	assert.NotContains(t, link.RawQuery, "tedlasso")
+	email, ok := pizza.InviteEmail(link.Query())
+	assert.True(t, ok)
+	assert.Equal(t, "believe@tedlasso.com", email)
+	exp := link.Query().Get("expires")
+	assert.False(t, pizza.LinkExpired(exp, time.Now().Add(pizza.InviteTTL-time.Minute)))
+	assert.True(t, pizza.LinkExpired(exp, time.Now().Add(pizza.InviteTTL+pizza.LinkClockSkew+time.Minute)))
+}
+
+func TestInviteEmailTampered(t *testing.T) {
+	// GIVEN
+	link, _ := url.Parse(pizza.InviteURL("https://rsvp.pizza/", "believe@tedlasso.com"))
+	q := link.Query()
+
+	// WHEN the expiry is pushed out
+	q.Set("expires", pizza.LinkExpiry(time.Now().Add(2*pizza.InviteTTL)))
+
+	// THEN
+	_, ok := pizza.InviteEmail(q)
+	assert.False(t, ok)
+	_, ok = pizza.InviteEmail(url.Values{"invite": {"believe@tedlasso.com"}})
+	assert.False(t, ok)
+}
+
+func TestLinkExpired(t *testing.T) {
+	// GIVEN
+	expires := time.Unix(1700000000, 0)
+	exp := pizza.LinkExpiry(expires)
+
+	// THEN links are accepted until the clock skew has passed
+	assert.Equal(t, "1700000000", exp)
+	assert.False(t, pizza.LinkExpired(exp, expires.Add(-time.Hour)))
+	assert.False(t, pizza.LinkExpired(exp, expires))
+	assert.False(t, pizza.LinkExpired(exp, expires.Add(pizza.LinkClockSkew)))
+	assert.True(t, pizza.LinkExpired(exp, expires.Add(pizza.LinkClockSkew+time.Second)))
+	assert.True(t, pizza.LinkExpired("", expires))
+	assert.True(t, pizza.LinkExpired("soon", expires))
+}
+
+func TestMaskEmail(t *testing.T) {
+	assert.Equal(t, "b******@tedlasso.com", pizza.MaskEmail("believe@tedlasso.com"))
+	assert.Equal(t, "r@kent.com", pizza.MaskEmail("r@kent.com"))
+	assert.Equal(t, "nobody", pizza.MaskEmail("nobody"))
+}
+
+func TestAliasURL(t *testing.T) {
+	// GIVEN
+	expires := time.Unix(1700000000, 0)
+
+	// WHEN
+	link, err := url.Parse(pizza.AliasURL("believe@tedlasso.com", "coach@richmond.com", expires))
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, "/aliases/verify", link.Path)
+	q := link.Query()
+	assert.Equal(t, "coach@richmond.com", q.Get("alias"))
+	assert.True(t, pizza.VerifyLink(q.Get("sig"), "alias", "believe@tedlasso.com", "coach@richmond.com", "1700000000"))
+	assert.False(t, pizza.VerifyLink(q.Get("sig"), "alias", "roy@kent.com", "coach@richmond.com", "1700000000"))
+}
+
+func TestCancelRSVPURL(t *testing.T) {
+	// GIVEN
+	rsvp := pizza.RSVP{ID: "12345", Email: "believe@tedlasso.com"}
+
+	// WHEN
+	link, err := url.Parse(pizza.CancelRSVPURL(rsvp))
+
+	// THEN it is signed like the edit link
+	assert.Nil(t, err)
+	assert.Equal(t, "/cancel", link.Path)
+	assert.Equal(t, "12345", link.Query().Get("rsvp"))
+	assert.True(t, pizza.VerifyLink(link.Query().Get("sig"), "rsvp", "12345", "believe@tedlasso.com"))
+	edit, err := url.Parse(pizza.EditRSVPURL(rsvp))
+	assert.Nil(t, err)
+	assert.Equal(t, edit.Query().Get("sig"), link.Query().Get("sig"))
+}
+
+func TestConfirmRSVPURL(t *testing.T) {
+	// GIVEN
+	fridays := []pizza.Friday{{Start: time.Unix(1680903000, 0)}, {Start: time.Unix(1681507800, 0)}}
+	days := [][]string{{"sat"}, nil}
+
+	// WHEN
+	link, err := url.Parse(pizza.ConfirmRSVPURL("believe@tedlasso.com", "code", fridays, days, 2, 1,
+		pizza.RSVPPreferences{Toppings: []string{"mushroom"}, Diets: map[string]int{"vegetarian": 2}}))
+
+	// THEN it carries what was picked, signed
+	assert.Nil(t, err)
+	q := link.Query()
+	assert.Equal(t, "/confirm", link.Path)
+	assert.Equal(t, []string{"1680903000", "1681507800"}, q["date"])
+	assert.Equal(t, []string{"sat"}, q["day:1680903000"])
+	assert.Equal(t, []string{"mushroom"}, q["topping"])
+	assert.Equal(t, "2", q.Get("diet:vegetarian"))
+	assert.True(t, pizza.VerifyConfirmRSVP(q))
+
+	// WHEN anything is changed
+	for _, key := range []string{"email", "code", "plusOnes", "kids", "day:1680903000", "topping", "diet:vegetarian", "diet:vegan"} {
+		changed := url.Values{}
+		for k, v := range q {
+			changed[k] = v
+		}
+		changed.Set(key, "9")
+
+		// THEN the link no longer verifies
+		assert.False(t, pizza.VerifyConfirmRSVP(changed), key)
+	}
+}
+
+func TestICalFeedURL(t *testing.T) {
+	// GIVEN
+	pizza.BaseURL = "https://rsvp.pizza"
+	link := pizza.ICalFeedURL("believe@tedlasso.com")
+	token := strings.TrimPrefix(link, "https://rsvp.pizza/ical/")
+
+	// WHEN
+	email, ok := pizza.ParseICalToken(token)
+
+	// THEN
+	assert.True(t, ok)
+	assert.Equal(t, "believe@tedlasso.com", email)
+	assert.NotContains(t, token, base64.RawURLEncoding.EncodeToString([]byte("believe@tedlasso.com")))
+	tampered := []byte(token)
+	tampered[len(tampered)/2] ^= 1
+	_, ok = pizza.ParseICalToken(string(tampered))
+	assert.False(t, ok)
+}
+
+func TestICalFeedURLLegacy(t *testing.T) {
+	// GIVEN a token made before the email was sealed
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("believe@tedlasso.com"))
+	token := encoded + "." + pizza.SignLink("ical", "believe@tedlasso.com")
+
+	// WHEN
+	email, ok := pizza.ParseICalToken(token)
+
+	// THEN
+	assert.True(t, ok)
+	assert.Equal(t, "believe@tedlasso.com", email)
+	_, ok = pizza.ParseICalToken(strings.Replace(token, ".", "x.", 1))
+	assert.False(t, ok)
+	_, ok = pizza.ParseICalToken(encoded)
+	assert.False(t, ok)
+}
@@ -0,0 +1,39 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const liteCookieName = "pizza_lite"
+
+// LiteCookieTTL is how long the browser remembers a friend's choice of the
+// lite or full index.
+var LiteCookieTTL = 365 * 24 * time.Hour
+
+// LiteMode reports whether to serve the lite index, a page with no images or
+// scripts beyond the captcha for friends on slow connections. A ?lite=1 or
+// ?lite=0 link sets the choice, a remembered choice comes next, and otherwise
+// browsers asking to save data get the lite page.
+func LiteMode(r *http.Request) bool {
+	switch r.URL.Query().Get("lite") {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	if cookie, err := r.Cookie(liteCookieName); err == nil {
+		return cookie.Value == "1"
+	}
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on")
+}
+
+// rememberLiteMode keeps the choice from a ?lite= link for the next visit.
+func rememberLiteMode(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("lite")
+	if value != "1" && value != "0" {
+		return
+	}
+	http.SetCookie(w, newCookie(liteCookieName, value, "/", time.Now().Add(LiteCookieTTL)))
+}
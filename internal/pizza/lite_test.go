@@ -0,0 +1,32 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteMode(t *testing.T) {
+	// GIVEN
+	request := func(url, saveData, cookie string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if len(saveData) > 0 {
+			r.Header.Set("Save-Data", saveData)
+		}
+		if len(cookie) > 0 {
+			r.AddCookie(&http.Cookie{Name: "pizza_lite", Value: cookie})
+		}
+		return r
+	}
+
+	// WHEN / THEN a link beats the remembered choice, which beats the header
+	assert.False(t, pizza.LiteMode(request("/", "", "")))
+	assert.True(t, pizza.LiteMode(request("/", "On", "")))
+	assert.False(t, pizza.LiteMode(request("/", "on", "0")))
+	assert.True(t, pizza.LiteMode(request("/", "", "1")))
+	assert.False(t, pizza.LiteMode(request("/?lite=0", "on", "1")))
+	assert.True(t, pizza.LiteMode(request("/?lite=1", "", "0")))
+}
@@ -0,0 +1,163 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type LoginPageData struct {
+	Enabled bool
+	Phone   string
+	Sent    bool
+	Error   string
+	// PasskeyChallenge is set when friends can log in with a passkey
+	PasskeyChallenge string
+	RPID             string
+	// Providers are the accounts friends can log in with
+	Providers []LoginProviderData
+}
+
+type LoginProviderData struct {
+	Title string
+	URL   string
+}
+
+func newLoginPageData() LoginPageData {
+	data := LoginPageData{Enabled: SMSEnabled()}
+	if PasskeysEnabled() {
+		data.PasskeyChallenge = NewWebAuthnChallenge("login")
+		data.RPID = WebAuthnRPID
+	}
+	for _, p := range loginProviders {
+		data.Providers = append(data.Providers, LoginProviderData{Title: p.Title, URL: "/login/" + p.Name})
+	}
+	return data
+}
+
+// HandleLogin signs in a friend with a code texted to their phone, for friends
+// who don't check their email for invite links, and links to the accounts
+// they can log in with instead.
+func HandleLogin(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/login.html")
+	if err != nil {
+		Log.Error("template login failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := newLoginPageData()
+
+	if r.Method == http.MethodPost && data.Enabled {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		phone, ok := NormalizePhone(r.PostForm.Get("phone"))
+		code := strings.TrimSpace(r.PostForm.Get("code"))
+		switch {
+		case !ok:
+			data.Error = "That doesn't look like a phone number."
+		case len(code) > 0:
+			data.Phone = phone
+			data.Sent = true
+			email, err := GetFriendByPhone(phone)
+			if err != nil {
+				Handle500(w, r)
+				return
+			}
+			// nobody is texted codes for numbers that aren't a friend's
+			if len(email) == 0 || !CheckOTP(email, "login:"+phone, code) {
+				data.Error = "That code didn't work, try again or ask for a new one."
+				break
+			}
+			rememberFriend(w, r, email)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		default:
+			email, err := GetFriendByPhone(phone)
+			if err != nil {
+				Handle500(w, r)
+				return
+			}
+			// the page looks the same either way so it can't be used to find out
+			// who is a friend
+			if len(email) > 0 {
+				sendLoginCode(email, "login:"+phone, phone)
+			}
+			data.Phone = phone
+			data.Sent = true
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+func sendLoginCode(email, key, phone string) {
+	code, ok := NewOTP(email, key)
+	if !ok {
+		return
+	}
+	if err := SendSMS(phone, code+" is your rsvp.pizza code"); err != nil {
+		Log.Warn("failed to send login code", zap.Error(err), zap.String("phone", phone))
+	}
+}
+
+type PhonePageData struct {
+	View
+	Enabled  bool
+	Email    string
+	Phone    string
+	Sent     bool
+	Verified bool
+	Error    string
+}
+
+// HandlePhone lets the friend remembered by the browser add a phone number to
+// log in with. The number is confirmed with a texted code.
+func HandlePhone(w http.ResponseWriter, r *http.Request) {
+	data := PhonePageData{Enabled: SMSEnabled(), Email: friendFromCookie(r)}
+
+	if r.Method == http.MethodPost && data.Enabled && len(data.Email) > 0 {
+		if err := r.ParseForm(); err != nil || !checkCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		phone, ok := NormalizePhone(r.PostForm.Get("phone"))
+		code := strings.TrimSpace(r.PostForm.Get("code"))
+		key := "phone:" + data.Email + ":" + phone
+		switch {
+		case !ok:
+			data.Error = "That doesn't look like a phone number."
+		case len(code) > 0:
+			data.Phone = phone
+			data.Sent = true
+			if !CheckOTP(data.Email, key, code) {
+				data.Error = "That code didn't work, try again or ask for a new one."
+				break
+			}
+			if owner, err := GetFriendByPhone(phone); err != nil {
+				Handle500(w, r)
+				return
+			} else if len(owner) > 0 && owner != data.Email {
+				data.Error = "That number belongs to another friend."
+				break
+			}
+			if err := SetFriendPhone(data.Email, phone); err != nil {
+				Handle500(w, r)
+				return
+			}
+			data.Verified = true
+		default:
+			sendLoginCode(data.Email, key, phone)
+			data.Phone = phone
+			data.Sent = true
+		}
+	}
+
+	render(w, r, "html/phone.html", &data)
+}
@@ -1,12 +1,188 @@
 package pizza
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type Mailer struct {
+	addr    string
+	auth    smtp.Auth
+	from    string
+	inbound string
+}
+
+var mailer *Mailer
+
+var (
+	ErrEmailSuppressed = errors.New("email address is flagged as undeliverable")
+	ErrEmailDisabled   = errors.New("email is not configured")
+)
+
 const (
-	GmailSMTPServer = ""
-	GmailSMTPPort   = 800
+	EmailStatusBounced    = "bounced"
+	EmailStatusComplained = "complained"
 )
 
-var GMAIL_API_KEY string
+var suppressedEmailCache *Cache[string]
+
+func newSuppressedEmailCache() {
+	c := NewCache(10*time.Minute, GetEmailStatus)
+	suppressedEmailCache = &c
+}
+
+// EmailIssue is a bounce or complaint reported by the email provider.
+type EmailIssue struct {
+	Email  string
+	Status string
+	Reason string
+}
+
+// EmailMessage is a plain text email.
+type EmailMessage struct {
+	To        string
+	Subject   string
+	Body      string
+	ReplyTo   string
+	InReplyTo string
+}
+
+// InitMailer sets up sending through the configured SMTP server. Email stays
+// disabled when no server is configured.
+func InitMailer(config EmailConfig) {
+	if len(config.SMTPHost) == 0 {
+		Log.Warn("no smtp server configured, email is disabled")
+		return
+	}
+	mailer = &Mailer{
+		addr:    fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort),
+		from:    config.From,
+		inbound: config.InboundAddress,
+	}
+	if len(config.Username) > 0 {
+		mailer.auth = smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)
+	}
+}
+
+func SendEmail(msg EmailMessage) error {
+	if IsEmailSuppressed(msg.To) {
+		return ErrEmailSuppressed
+	}
+	if mailer == nil {
+		return ErrEmailDisabled
+	}
+	return smtp.SendMail(mailer.addr, mailer.auth, mailer.from, []string{msg.To}, msg.Bytes(mailer.from))
+}
+
+// Bytes renders the message with its headers.
+func (m EmailMessage) Bytes(from string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if len(m.ReplyTo) > 0 {
+		fmt.Fprintf(&b, "Reply-To: %s\r\n", m.ReplyTo)
+	}
+	if len(m.InReplyTo) > 0 {
+		fmt.Fprintf(&b, "In-Reply-To: %s\r\nReferences: %s\r\n", m.InReplyTo, m.InReplyTo)
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// ReplyAddress is the address friends reply to in order to RSVP to a Friday,
+// e.g. rsvp+1680903000@rsvp.pizza.
+func ReplyAddress(fridayID string) string {
+	if mailer == nil || len(mailer.inbound) == 0 {
+		return ""
+	}
+	at := strings.LastIndex(mailer.inbound, "@")
+	if at < 0 {
+		return mailer.inbound
+	}
+	return mailer.inbound[:at] + "+" + fridayID + mailer.inbound[at:]
+}
 
 func SendConfirmationEmail(email, code string) error {
+	if IsEmailSuppressed(email) {
+		return ErrEmailSuppressed
+	}
 	return nil
 }
+
+// IsEmailSuppressed reports whether mail to the address has bounced or been
+// marked as spam, in which case nothing more should be sent to it.
+func IsEmailSuppressed(email string) bool {
+	status, err := suppressedEmailCache.Get(strings.ToLower(email))
+	return err == nil && len(status) > 0
+}
+
+// ParseSESNotification reads an Amazon SES notification out of the SNS message
+// body. Only permanent bounces are reported since transient ones may recover.
+func ParseSESNotification(message []byte) ([]EmailIssue, error) {
+	var notification struct {
+		NotificationType string `json:"notificationType"`
+		Bounce           struct {
+			BounceType        string `json:"bounceType"`
+			BouncedRecipients []struct {
+				EmailAddress   string `json:"emailAddress"`
+				DiagnosticCode string `json:"diagnosticCode"`
+			} `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplainedRecipients []struct {
+				EmailAddress string `json:"emailAddress"`
+			} `json:"complainedRecipients"`
+			ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		} `json:"complaint"`
+	}
+	if err := json.Unmarshal(message, &notification); err != nil {
+		return nil, err
+	}
+	issues := []EmailIssue{}
+	switch notification.NotificationType {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			break
+		}
+		for _, r := range notification.Bounce.BouncedRecipients {
+			issues = append(issues, EmailIssue{strings.ToLower(r.EmailAddress), EmailStatusBounced, r.DiagnosticCode})
+		}
+	case "Complaint":
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			issues = append(issues, EmailIssue{strings.ToLower(r.EmailAddress), EmailStatusComplained, notification.Complaint.ComplaintFeedbackType})
+		}
+	}
+	return issues, nil
+}
+
+// ParseSendGridEvents reads the bounce, dropped, and spam report events out of
+// a SendGrid event webhook body.
+func ParseSendGridEvents(body []byte) ([]EmailIssue, error) {
+	var events []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, err
+	}
+	issues := []EmailIssue{}
+	for _, e := range events {
+		switch e.Event {
+		case "bounce", "dropped":
+			issues = append(issues, EmailIssue{strings.ToLower(e.Email), EmailStatusBounced, e.Reason})
+		case "spamreport":
+			issues = append(issues, EmailIssue{strings.ToLower(e.Email), EmailStatusComplained, e.Event})
+		}
+	}
+	return issues, nil
+}
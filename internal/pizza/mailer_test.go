@@ -1 +1,59 @@
 package pizza_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestParseSESNotification(t *testing.T) {
+	// GIVEN
+	bounce := []byte(`{"notificationType":"Bounce","bounce":{"bounceType":"Permanent",
+		"bouncedRecipients":[{"emailAddress":"Believe@TedLasso.com","diagnosticCode":"550 no such user"}]}}`)
+	transient := []byte(`{"notificationType":"Bounce","bounce":{"bounceType":"Transient",
+		"bouncedRecipients":[{"emailAddress":"believe@tedlasso.com"}]}}`)
+	complaint := []byte(`{"notificationType":"Complaint","complaint":{"complaintFeedbackType":"abuse",
+		"complainedRecipients":[{"emailAddress":"roy@kent.com"}]}}`)
+
+	// WHEN
+	issues, err := pizza.ParseSESNotification(bounce)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, []pizza.EmailIssue{{Email: "believe@tedlasso.com", Status: pizza.EmailStatusBounced, Reason: "550 no such user"}}, issues)
+
+	// WHEN
+	issues, err = pizza.ParseSESNotification(transient)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Empty(t, issues)
+
+	// WHEN
+	issues, err = pizza.ParseSESNotification(complaint)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, []pizza.EmailIssue{{Email: "roy@kent.com", Status: pizza.EmailStatusComplained, Reason: "abuse"}}, issues)
+}
+
+func TestParseSendGridEvents(t *testing.T) {
+	// GIVEN
+	body := []byte(`[
+		{"email":"believe@tedlasso.com","event":"bounce","reason":"550 no such user"},
+		{"email":"keeley@jones.com","event":"delivered"},
+		{"email":"roy@kent.com","event":"spamreport"}
+	]`)
+
+	// WHEN
+	issues, err := pizza.ParseSendGridEvents(body)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, []pizza.EmailIssue{
+		{Email: "believe@tedlasso.com", Status: pizza.EmailStatusBounced, Reason: "550 no such user"},
+		{Email: "roy@kent.com", Status: pizza.EmailStatusComplained, Reason: "spamreport"},
+	}, issues)
+}
@@ -0,0 +1,44 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maintenanceExempt are the path prefixes that keep working in maintenance
+// mode.
+var maintenanceExempt = []string{"/admin/", "/static/", "/healthz", "/readyz"}
+
+// CheckMaintenance serves the maintenance page instead of the route while the
+// site is in maintenance mode, see RuntimeSettings.Maintenance.
+func CheckMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !CurrentSettings().Maintenance {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range maintenanceExempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("Retry-After", "300")
+		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/hooks/") {
+			writeAPIError(w, http.StatusServiceUnavailable, "down for maintenance")
+			return
+		}
+		plate, err := parseTemplate("html/maintenance.html")
+		if err != nil {
+			Log.Error("template maintenance failure", zap.Error(err))
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err = executeTemplate(w, plate, MaintenancePageData{}); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+		}
+	})
+}
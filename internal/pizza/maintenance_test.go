@@ -0,0 +1,37 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMaintenance(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	defer pizza.SetSettings(func(s *pizza.RuntimeSettings) { s.Maintenance = true })()
+	handler := pizza.CheckMaintenance(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// WHEN
+	index := get("/")
+	api := get("/api/v1/fridays")
+	admin := get("/admin/settings")
+
+	// THEN
+	assert.Equal(t, http.StatusServiceUnavailable, index.Code)
+	assert.Contains(t, index.Body.String(), "maintenance")
+	assert.Equal(t, http.StatusServiceUnavailable, api.Code)
+	assert.Equal(t, "application/json", api.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusOK, admin.Code)
+	assert.Equal(t, "ok", admin.Body.String())
+}
@@ -0,0 +1,153 @@
+package pizza
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,3})\s+(.*)$`)
+	mdBullet  = regexp.MustCompile(`^[-*]\s+(.*)$`)
+	mdNumber  = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	mdItalic  = regexp.MustCompile(`\*(.+?)\*`)
+)
+
+// markdownRules are the markdown features a renderer allows. Links, bold, and
+// lists are always allowed.
+type markdownRules struct {
+	headings bool
+	italics  bool
+}
+
+var (
+	fullMarkdown  = markdownRules{headings: true, italics: true}
+	basicMarkdown = markdownRules{}
+)
+
+// RenderMarkdown renders a small subset of markdown to HTML: paragraphs,
+// headings, lists, links, bold, and italics. Everything else, including any
+// HTML in the source, is escaped, so the output is safe to put in a page.
+func RenderMarkdown(src string) string {
+	return renderMarkdown(src, fullMarkdown)
+}
+
+// RenderBasicMarkdown renders only links, bold, and lists, for text written
+// by guests and short notes from the host.
+func RenderBasicMarkdown(src string) string {
+	return renderMarkdown(src, basicMarkdown)
+}
+
+func renderMarkdown(src string, rules markdownRules) string {
+	var b strings.Builder
+	var para []string
+	list := ""
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + strings.Join(para, "<br>\n") + "</p>\n")
+			para = nil
+		}
+		if len(list) > 0 {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	item := func(kind, text string) {
+		if len(para) > 0 || list != kind {
+			flush()
+			b.WriteString("<" + kind + ">\n")
+			list = kind
+		}
+		b.WriteString("<li>" + renderInline(text, rules) + "</li>\n")
+	}
+
+	for _, line := range markdownLines(src) {
+		if m := mdHeading.FindStringSubmatch(line); m != nil && rules.headings {
+			flush()
+			tag := []string{"h3", "h4", "h5"}[len(m[1])-1]
+			b.WriteString("<" + tag + ">" + renderInline(m[2], rules) + "</" + tag + ">\n")
+		} else if m := mdBullet.FindStringSubmatch(line); m != nil {
+			item("ul", m[1])
+		} else if m := mdNumber.FindStringSubmatch(line); m != nil {
+			item("ol", m[1])
+		} else if len(line) == 0 {
+			flush()
+		} else {
+			if len(list) > 0 {
+				flush()
+			}
+			para = append(para, renderInline(line, rules))
+		}
+	}
+	flush()
+	return b.String()
+}
+
+func markdownLines(src string) []string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return lines
+}
+
+// renderInline escapes the text and renders links and emphasis in it.
+func renderInline(text string, rules markdownRules) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdLink.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(renderEmphasis(text[last:m[0]], rules))
+		label, href := text[m[2]:m[3]], text[m[4]:m[5]]
+		if safeURL(href) {
+			b.WriteString(`<a href="` + html.EscapeString(href) + `">` + renderEmphasis(label, rules) + "</a>")
+		} else {
+			b.WriteString(renderEmphasis(label, rules))
+		}
+		last = m[1]
+	}
+	b.WriteString(renderEmphasis(text[last:], rules))
+	return b.String()
+}
+
+func renderEmphasis(text string, rules markdownRules) string {
+	text = html.EscapeString(text)
+	text = mdBold.ReplaceAllString(text, "<strong>$1</strong>")
+	if rules.italics {
+		text = mdItalic.ReplaceAllString(text, "<em>$1</em>")
+	}
+	return text
+}
+
+// MarkdownText renders basic markdown as plain text for email, with links
+// written out after their text.
+func MarkdownText(src string) string {
+	lines := markdownLines(src)
+	for i, line := range lines {
+		if m := mdBullet.FindStringSubmatch(line); m != nil {
+			line = "- " + m[1]
+		}
+		line = mdLink.ReplaceAllStringFunc(line, func(link string) string {
+			m := mdLink.FindStringSubmatch(link)
+			if safeURL(m[2]) {
+				return m[1] + " (" + m[2] + ")"
+			}
+			return m[1]
+		})
+		lines[i] = mdBold.ReplaceAllString(line, "$1")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// safeURL only allows links to web pages, email addresses, and this site, so
+// a link can't run script.
+func safeURL(href string) bool {
+	lower := strings.ToLower(href)
+	for _, prefix := range []string{"https://", "http://", "mailto:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//")
+}
@@ -0,0 +1,63 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	// GIVEN
+	src := "# Welcome\n" +
+		"Pizza is at **7pm**,\nbring *friends*.\n" +
+		"\n" +
+		"- [menu](https://rsvp.pizza/menu?a=1&b=2)\n" +
+		"- no_under_scores\n" +
+		"1. first\n" +
+		"2. second\n"
+
+	// WHEN
+	html := pizza.RenderMarkdown(src)
+
+	// THEN
+	assert.Equal(t, "<h3>Welcome</h3>\n"+
+		"<p>Pizza is at <strong>7pm</strong>,<br>\nbring <em>friends</em>.</p>\n"+
+		"<ul>\n<li><a href=\"https://rsvp.pizza/menu?a=1&amp;b=2\">menu</a></li>\n<li>no_under_scores</li>\n</ul>\n"+
+		"<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n", html)
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	// GIVEN
+	src := "<script>alert(1)</script> [click](javascript:alert(1)) [x](https://a.b/\"onmouseover=alert(1)) [y](//evil.example)"
+
+	// WHEN
+	html := pizza.RenderMarkdown(src)
+
+	// THEN
+	assert.Equal(t, "<p>&lt;script&gt;alert(1)&lt;/script&gt; click) "+
+		"<a href=\"https://a.b/&#34;onmouseover=alert(1\">x</a>) y</p>\n", html)
+}
+
+func TestRenderBasicMarkdown(t *testing.T) {
+	// GIVEN
+	src := "# not a heading\n**bring** *chairs*\n- [rsvp](/)"
+
+	// WHEN
+	html := pizza.RenderBasicMarkdown(src)
+
+	// THEN
+	assert.Equal(t, "<p># not a heading<br>\n<strong>bring</strong> *chairs*</p>\n"+
+		"<ul>\n<li><a href=\"/\">rsvp</a></li>\n</ul>\n", html)
+}
+
+func TestMarkdownText(t *testing.T) {
+	// GIVEN
+	src := "Pizza at **7pm**!\n* [menu](https://rsvp.pizza/menu)\n- [bad](javascript:alert(1))\n"
+
+	// WHEN
+	text := pizza.MarkdownText(src)
+
+	// THEN
+	assert.Equal(t, "Pizza at 7pm!\n- menu (https://rsvp.pizza/menu)\n- bad)", text)
+}
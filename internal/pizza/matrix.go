@@ -0,0 +1,265 @@
+package pizza
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MatrixBot posts upcoming Fridays to a Matrix room and takes RSVPs from
+// "!pizza" commands sent there.
+type MatrixBot struct {
+	homeserver    string
+	token         string
+	roomID        string
+	userID        string
+	announceEvery time.Duration
+	friends       map[string]string
+	client        *http.Client
+	txn           int64
+}
+
+const matrixCommandPrefix = "!pizza"
+
+func NewMatrixBot(config MatrixConfig) *MatrixBot {
+	return &MatrixBot{
+		homeserver:    strings.TrimRight(config.Homeserver, "/"),
+		token:         config.AccessToken,
+		roomID:        config.RoomID,
+		userID:        config.UserID,
+		announceEvery: config.AnnounceEvery,
+		friends:       config.Friends,
+		client:        &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Run syncs with the homeserver until ctx is done, answering commands as they
+// arrive.
+func (b *MatrixBot) Run(ctx context.Context) {
+	go b.announce(ctx)
+	since := ""
+	for ctx.Err() == nil {
+		next, err := b.sync(ctx, since)
+		if err != nil {
+			// a sync cut off by the shutdown didn't fail
+			if ctx.Err() == nil {
+				Log.Warn("matrix sync failed", zap.Error(err))
+				sleepCtx(ctx, 30*time.Second)
+			}
+			continue
+		}
+		since = next
+	}
+}
+
+func (b *MatrixBot) announce(ctx context.Context) {
+	if b.announceEvery <= 0 {
+		return
+	}
+	for {
+		if text := b.listFridays(); len(text) > 0 {
+			if err := b.Notify(text); err != nil {
+				Log.Warn("matrix announcement failed", zap.Error(err))
+			}
+		}
+		if !sleepCtx(ctx, b.announceEvery) {
+			return
+		}
+	}
+}
+
+// sync fetches new room events since the last batch. Events from the first
+// sync are history and are skipped.
+func (b *MatrixBot) sync(ctx context.Context, since string) (string, error) {
+	q := url.Values{}
+	q.Set("timeout", "30000")
+	q.Set("filter", fmt.Sprintf(`{"room":{"rooms":[%q],"timeline":{"limit":20}}}`, b.roomID))
+	if len(since) > 0 {
+		q.Set("since", since)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.homeserver+"/_matrix/client/v3/sync?"+q.Encode(), nil)
+	if err != nil {
+		return since, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	res, err := b.client.Do(req)
+	if err != nil {
+		return since, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return since, fmt.Errorf("matrix sync status %d", res.StatusCode)
+	}
+
+	var body struct {
+		NextBatch string `json:"next_batch"`
+		Rooms     struct {
+			Join map[string]struct {
+				Timeline struct {
+					Events []struct {
+						Type    string `json:"type"`
+						Sender  string `json:"sender"`
+						Content struct {
+							Body string `json:"body"`
+						} `json:"content"`
+					} `json:"events"`
+				} `json:"timeline"`
+			} `json:"join"`
+		} `json:"rooms"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return since, err
+	}
+	if len(since) == 0 {
+		return body.NextBatch, nil
+	}
+	for _, event := range body.Rooms.Join[b.roomID].Timeline.Events {
+		if event.Type != "m.room.message" || event.Sender == b.userID {
+			continue
+		}
+		if reply := b.HandleCommand(event.Sender, event.Content.Body); len(reply) > 0 {
+			if err = b.Notify(reply); err != nil {
+				Log.Warn("matrix reply failed", zap.Error(err))
+			}
+		}
+	}
+	return body.NextBatch, nil
+}
+
+// Notify posts a notice to the room. Notices are never answered by bots.
+func (b *MatrixBot) Notify(text string) error {
+	txn := atomic.AddInt64(&b.txn, 1)
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%d-%d",
+		b.homeserver, url.PathEscape(b.roomID), time.Now().UnixNano(), txn)
+	payload, err := json.Marshal(map[string]string{"msgtype": "m.notice", "body": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("matrix send status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// ParseMatrixCommand splits a "!pizza" command into its name and arguments.
+// Messages that aren't commands return ok as false.
+func ParseMatrixCommand(body string) (command string, args []string, ok bool) {
+	fields := strings.Fields(body)
+	if len(fields) == 0 || fields[0] != matrixCommandPrefix {
+		return "", nil, false
+	}
+	if len(fields) == 1 {
+		return "list", nil, true
+	}
+	return strings.ToLower(fields[1]), fields[2:], true
+}
+
+// HandleCommand answers a command from a room member.
+func (b *MatrixBot) HandleCommand(sender, body string) string {
+	command, args, ok := ParseMatrixCommand(body)
+	if !ok {
+		return ""
+	}
+	switch command {
+	case "list":
+		if text := b.listFridays(); len(text) > 0 {
+			return text
+		}
+		return "There are no upcoming pizza fridays."
+	case "rsvp":
+		return b.rsvp(sender, args)
+	default:
+		return "Try \"!pizza list\" to see upcoming pizza fridays or \"!pizza rsvp 1 +2\" to come to the first one with two friends."
+	}
+}
+
+func (b *MatrixBot) openFridays() ([]Friday, error) {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		return nil, err
+	}
+	open := []Friday{}
+	for _, friday := range fridays {
+		if friday.IsOpen() {
+			open = append(open, friday)
+		}
+	}
+	return open, nil
+}
+
+func (b *MatrixBot) listFridays() string {
+	fridays, err := b.openFridays()
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		return ""
+	}
+	lines := make([]string, len(fridays))
+	for i, friday := range fridays {
+		lines[i] = fmt.Sprintf("%d. %s (RSVP by %s)", i+1, FormatTime(friday.Start), FormatTime(friday.Deadline()))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Upcoming pizza fridays:\n" + strings.Join(lines, "\n")
+}
+
+func (b *MatrixBot) rsvp(sender string, args []string) string {
+	email, ok := b.friends[sender]
+	if !ok {
+		return fmt.Sprintf("Sorry %s, ask the host to link your Matrix account to your email.", sender)
+	}
+	if allowed, err := IsFriendAllowed(email); !allowed {
+		if err != nil {
+			Log.Error("error checking email for matrix rsvp", zap.Error(err))
+		}
+		return "Sorry, no pizza for you."
+	}
+	if len(args) == 0 {
+		return "Which pizza friday? Try \"!pizza rsvp 1\"."
+	}
+	n, err := strconv.Atoi(args[0])
+	fridays, ferr := b.openFridays()
+	if ferr != nil {
+		Log.Error("failed to get fridays", zap.Error(ferr))
+		return "Sorry, something went wrong."
+	}
+	if err != nil || n < 1 || n > len(fridays) {
+		return "That's not one of the upcoming pizza fridays. Try \"!pizza list\"."
+	}
+	plusOnes := 0
+	if len(args) > 1 {
+		max := MaxPlusOnesFor(email)
+		if plusOnes, err = strconv.Atoi(strings.TrimPrefix(args[1], "+")); err != nil || plusOnes < 0 || plusOnes > max {
+			return fmt.Sprintf("You can bring between 0 and %d friends.", max)
+		}
+	}
+	friday := fridays[n-1]
+	rsvp, err := RSVPToFriday(email, friday, RSVPOptions{PlusOnes: plusOnes})
+	if err != nil {
+		Log.Error("matrix rsvp failed", zap.Error(err), zap.String("email", email))
+		return "Sorry, something went wrong."
+	} else if rsvp.Status == RSVPStatusPending {
+		return fmt.Sprintf("Pizza on %s is full, %s. The host will let you know if there's room.", FormatTime(friday.Start), sender)
+	}
+	return fmt.Sprintf("%s is in for pizza on %s!", sender, FormatTime(friday.Start))
+}
@@ -0,0 +1,50 @@
+package pizza_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestParseMatrixCommand(t *testing.T) {
+	command, args, ok := pizza.ParseMatrixCommand("!pizza rsvp 1 +2")
+	assert.True(t, ok)
+	assert.Equal(t, "rsvp", command)
+	assert.Equal(t, []string{"1", "+2"}, args)
+
+	command, _, ok = pizza.ParseMatrixCommand("!pizza")
+	assert.True(t, ok)
+	assert.Equal(t, "list", command)
+
+	_, _, ok = pizza.ParseMatrixCommand("pizza tonight?")
+	assert.False(t, ok)
+}
+
+func TestMatrixNotify(t *testing.T) {
+	// GIVEN
+	var path, auth string
+	var content map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&content)
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer ts.Close()
+	bot := pizza.NewMatrixBot(pizza.MatrixConfig{Homeserver: ts.URL, AccessToken: "secret", RoomID: "!room:example.org"})
+
+	// WHEN
+	err := bot.Notify("Pizza tonight!")
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Regexp(t, `^/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/.+`, path)
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, map[string]string{"msgtype": "m.notice", "body": "Pizza tonight!"}, content)
+	assert.Equal(t, "", bot.HandleCommand("@ted:example.org", "hello"))
+}
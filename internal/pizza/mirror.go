@@ -0,0 +1,125 @@
+package pizza
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// MirrorCalendarID is a public calendar the events are copied to, without
+// their guests, for friends to subscribe to. Mirroring is off when empty.
+var MirrorCalendarID = ""
+
+func MirrorEnabled() bool {
+	return len(MirrorCalendarID) > 0 && cal != nil
+}
+
+// MirrorEventFor is the public copy of the event, with how many are going but
+// not who.
+func MirrorEventFor(friday Friday, headcount int) *calendar.Event {
+	timezone := "America/New_York"
+	description := EventDescription
+	if announcement := MarkdownText(FillAnnouncement(friday.Announcement, AnnouncementVars(friday, headcount))); len(announcement) > 0 {
+		description += "\n\n" + announcement
+	}
+	description += fmt.Sprintf("\n\n%d going. RSVP at %s/", headcount, BaseURL)
+	return &calendar.Event{
+		Id:           friday.ID(),
+		Summary:      "Pizza Friday",
+		Description:  description,
+		Start:        &calendar.EventDateTime{DateTime: friday.Start.Format(time.RFC3339), TimeZone: timezone},
+		End:          &calendar.EventDateTime{DateTime: friday.EndTime().Format(time.RFC3339), TimeZone: timezone},
+		Status:       "confirmed",
+		Visibility:   "public",
+		Transparency: "transparent",
+	}
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	var davErr *CalDAVError
+	return (errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone)) ||
+		(errors.As(err, &davErr) && (davErr.Code == http.StatusNotFound || davErr.Code == http.StatusGone))
+}
+
+// isConflict reports whether a write was refused because the event changed
+// since it was read.
+func isConflict(err error) bool {
+	var apiErr *googleapi.Error
+	var davErr *CalDAVError
+	return (errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed) ||
+		(errors.As(err, &davErr) && davErr.Code == http.StatusPreconditionFailed)
+}
+
+// MirrorFriday copies the event and its headcount to the mirror calendar,
+// adding it there if it's new.
+func MirrorFriday(friday Friday) error {
+	if !MirrorEnabled() {
+		return nil
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return err
+	}
+	mirror := cal.other(MirrorCalendarID)
+	event := MirrorEventFor(friday, Headcount(rsvps))
+	_, err = mirror.UpdateEvent(event)
+	if isNotFound(err) {
+		_, err = mirror.CreateEvent(event)
+	}
+	return err
+}
+
+// UnmirrorFriday removes a deleted event from the mirror calendar.
+func UnmirrorFriday(id string) error {
+	if !MirrorEnabled() {
+		return nil
+	}
+	err := cal.other(MirrorCalendarID).DeleteEvent(id)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// mirrorFridayID brings the mirror of the upcoming event up to date, logging
+// failures since the event itself is already saved.
+func mirrorFridayID(id string) {
+	if !MirrorEnabled() {
+		return
+	}
+	friday, ok, err := GetCachedFriday(UpcomingDays, id)
+	if err != nil || !ok {
+		return
+	}
+	if err = MirrorFriday(friday); err != nil {
+		Log.Warn("failed to mirror event", zap.Error(err), zap.String("eventID", id))
+	}
+}
+
+// WatchMirror copies every upcoming event to the mirror calendar, catching up
+// on events that changed while the calendar was down.
+func WatchMirror(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		if fridays, err := GetCachedFridays(UpcomingDays); err != nil {
+			Log.Warn("failed to get fridays to mirror", zap.Error(err))
+		} else {
+			for _, friday := range fridays {
+				if err = MirrorFriday(friday); err != nil {
+					Log.Warn("failed to mirror event", zap.Error(err), zap.String("eventID", friday.ID()))
+				}
+			}
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
@@ -0,0 +1,30 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorEventFor(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{
+		UID:          "01g7d2m9r0",
+		Start:        time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC),
+		Announcement: "Bring a **friend**",
+	}
+
+	// WHEN
+	event := pizza.MirrorEventFor(friday, 5)
+
+	// THEN the copy says how many are going but invites nobody
+	assert.Equal(t, "01g7d2m9r0", event.Id)
+	assert.Equal(t, "public", event.Visibility)
+	assert.Empty(t, event.Attendees)
+	assert.Equal(t, "2023-04-07T21:30:00Z", event.Start.DateTime)
+	assert.Equal(t, "2023-04-08T01:30:00Z", event.End.DateTime)
+	assert.Contains(t, event.Description, "Bring a friend")
+	assert.Contains(t, event.Description, "5 going.")
+}
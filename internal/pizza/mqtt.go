@@ -0,0 +1,288 @@
+package pizza
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MQTTPublisher publishes headcounts to an MQTT 3.1.1 broker for home
+// automation. Updates are rare, so each batch uses its own connection.
+type MQTTPublisher struct {
+	addr string
+	// host is addr without its port, the name the TLS certificate is for
+	host     string
+	useTLS   bool
+	clientID string
+	username string
+	password string
+	topic    string
+}
+
+// MQTTMessage is a QoS 0 message. Topic is relative to the configured topic.
+type MQTTMessage struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+var mqttPublisher *MQTTPublisher
+
+var ErrMQTTRefused = errors.New("mqtt connection refused")
+
+const mqttTimeout = 5 * time.Second
+
+func NewMQTTPublisher(config MQTTConfig) (*MQTTPublisher, error) {
+	u, err := url.Parse(config.Broker)
+	if err != nil {
+		return nil, err
+	}
+	p := &MQTTPublisher{
+		addr:     u.Host,
+		host:     u.Hostname(),
+		clientID: config.ClientID,
+		username: config.Username,
+		password: config.Password,
+		topic:    strings.TrimRight(config.Topic, "/"),
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if len(u.Port()) == 0 {
+			p.addr = net.JoinHostPort(p.host, "1883")
+		}
+	case "tls", "mqtts", "ssl":
+		p.useTLS = true
+		if len(u.Port()) == 0 {
+			p.addr = net.JoinHostPort(p.host, "8883")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mqtt scheme %q", u.Scheme)
+	}
+	if len(p.clientID) == 0 {
+		p.clientID = "rsvp.pizza"
+	}
+	return p, nil
+}
+
+// InitMQTT sets up publishing when a broker is configured.
+func InitMQTT(config MQTTConfig) error {
+	if len(config.Broker) == 0 {
+		return nil
+	}
+	p, err := NewMQTTPublisher(config)
+	if err != nil {
+		return err
+	}
+	mqttPublisher = p
+	return nil
+}
+
+func (p *MQTTPublisher) Publish(messages ...MQTTMessage) error {
+	return p.PublishTo(p.topic, messages...)
+}
+
+// PublishTo publishes the messages relative to a topic other than the
+// configured one.
+func (p *MQTTPublisher) PublishTo(topic string, messages ...MQTTMessage) error {
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	var err error
+	if p.useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, &tls.Config{ServerName: p.host})
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(mqttTimeout))
+
+	if _, err = conn.Write(p.connectPacket()); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err = io.ReadFull(conn, ack); err != nil {
+		return err
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		return ErrMQTTRefused
+	}
+	for _, msg := range messages {
+		if _, err = conn.Write(publishPacket(topic, msg)); err != nil {
+			return err
+		}
+	}
+	_, err = conn.Write([]byte{0xe0, 0x00})
+	return err
+}
+
+func (p *MQTTPublisher) connectPacket() []byte {
+	var body bytes.Buffer
+	writeMQTTString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+	flags := byte(0x02)
+	if len(p.username) > 0 {
+		flags |= 0x80
+	}
+	if len(p.password) > 0 {
+		flags |= 0x40
+	}
+	body.WriteByte(flags)
+	binary.Write(&body, binary.BigEndian, uint16(30)) // keep alive seconds
+	writeMQTTString(&body, p.clientID)
+	if len(p.username) > 0 {
+		writeMQTTString(&body, p.username)
+	}
+	if len(p.password) > 0 {
+		writeMQTTString(&body, p.password)
+	}
+	return mqttPacket(0x10, body.Bytes())
+}
+
+func publishPacket(topic string, msg MQTTMessage) []byte {
+	var body bytes.Buffer
+	writeMQTTString(&body, topic+"/"+msg.Topic)
+	body.Write(msg.Payload)
+	header := byte(0x30)
+	if msg.Retain {
+		header |= 0x01
+	}
+	return mqttPacket(header, body.Bytes())
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func writeMQTTString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
+
+// PublishHeadcounts publishes the retained headcount of every upcoming Friday
+// and of the next one.
+func PublishHeadcounts() {
+	if mqttPublisher == nil {
+		return
+	}
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		Log.Warn("failed to get fridays for mqtt", zap.Error(err))
+		return
+	}
+	messages := []MQTTMessage{}
+	for i, friday := range fridays {
+		state, err := mqttFridayState(friday)
+		if err != nil {
+			Log.Warn("failed to list rsvps for mqtt", zap.Error(err), zap.String("eventID", friday.ID()))
+			return
+		}
+		messages = append(messages, state...)
+		if i == 0 {
+			for _, msg := range state {
+				msg.Topic = strings.Replace(msg.Topic, "fridays/"+friday.ID(), "next", 1)
+				messages = append(messages, msg)
+			}
+		}
+	}
+	if err = mqttPublisher.Publish(messages...); err != nil {
+		Log.Warn("mqtt publish failed", zap.Error(err))
+	}
+}
+
+func mqttFridayState(friday Friday) ([]MQTTMessage, error) {
+	state, err := GetFridayStatus(friday)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "fridays/" + friday.ID()
+	return []MQTTMessage{
+		{Topic: prefix, Payload: payload, Retain: true},
+		{Topic: prefix + "/headcount", Payload: []byte(strconv.Itoa(state.Headcount)), Retain: true},
+	}, nil
+}
+
+// PublishDiscovery publishes Home Assistant MQTT discovery configs for the
+// next Friday's headcount, start time, and whether RSVPs are open.
+func PublishDiscovery(prefix string) {
+	if mqttPublisher == nil || len(prefix) == 0 {
+		return
+	}
+	device := map[string]any{"identifiers": []string{"rsvp_pizza"}, "name": "rsvp.pizza"}
+	state := mqttPublisher.topic + "/next"
+	configs := map[string]map[string]any{
+		"sensor/rsvp_pizza_headcount": {
+			"name": "Pizza headcount", "state_topic": state + "/headcount", "unit_of_measurement": "people",
+			"icon": "mdi:pizza",
+		},
+		"sensor/rsvp_pizza_next": {
+			"name": "Next pizza friday", "state_topic": state, "device_class": "timestamp",
+			"value_template": "{{ value_json.start }}",
+		},
+		"sensor/rsvp_pizza_deadline": {
+			"name": "Pizza RSVP deadline", "state_topic": state, "device_class": "timestamp",
+			"value_template": "{{ value_json.deadline }}",
+		},
+		"binary_sensor/rsvp_pizza_open": {
+			"name": "Pizza RSVPs open", "state_topic": state,
+			"value_template": "{{ 'ON' if value_json.open else 'OFF' }}",
+		},
+	}
+	messages := []MQTTMessage{}
+	for component, config := range configs {
+		config["unique_id"] = component[strings.Index(component, "/")+1:]
+		config["device"] = device
+		payload, err := json.Marshal(config)
+		if err != nil {
+			Log.Error("json encode failure", zap.Error(err))
+			return
+		}
+		messages = append(messages, MQTTMessage{Topic: component + "/config", Payload: payload, Retain: true})
+	}
+	if err := mqttPublisher.PublishTo(strings.TrimRight(prefix, "/"), messages...); err != nil {
+		Log.Warn("mqtt discovery publish failed", zap.Error(err))
+	}
+}
+
+// PublishRSVPChange announces a change to a Friday's RSVPs and refreshes the
+// retained headcounts.
+func PublishRSVPChange(changeType, fridayID string) {
+	if mqttPublisher == nil {
+		return
+	}
+	payload, _ := json.Marshal(map[string]string{"type": changeType, "fridayId": fridayID})
+	if err := mqttPublisher.Publish(MQTTMessage{Topic: "changes", Payload: payload}); err != nil {
+		Log.Warn("mqtt publish failed", zap.Error(err))
+		return
+	}
+	PublishHeadcounts()
+}
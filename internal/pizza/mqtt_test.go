@@ -0,0 +1,163 @@
+package pizza_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestMQTTPublish(t *testing.T) {
+	// GIVEN
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, 2)
+		io.ReadFull(conn, header)
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		rest, _ := io.ReadAll(conn)
+		received <- rest
+	}()
+	publisher, err := pizza.NewMQTTPublisher(pizza.MQTTConfig{Broker: "tcp://" + ln.Addr().String(), Topic: "pizza"})
+	require.Nil(t, err)
+
+	// WHEN
+	err = publisher.Publish(pizza.MQTTMessage{Topic: "next/headcount", Payload: []byte("12"), Retain: true})
+
+	// THEN
+	assert.Nil(t, err)
+	expected := append([]byte{0x31, 24, 0, 20}, []byte("pizza/next/headcount12")...)
+	expected = append(expected, 0xe0, 0x00)
+	assert.Equal(t, expected, <-received)
+}
+
+func TestMQTTPublishRefused(t *testing.T) {
+	// GIVEN
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, 2)
+		io.ReadFull(conn, header)
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{0x20, 0x02, 0x00, 0x05})
+	}()
+	publisher, err := pizza.NewMQTTPublisher(pizza.MQTTConfig{Broker: "tcp://" + ln.Addr().String(), Topic: "pizza"})
+	require.Nil(t, err)
+
+	// WHEN
+	err = publisher.Publish(pizza.MQTTMessage{Topic: "next/headcount", Payload: []byte("12")})
+
+	// THEN
+	assert.Equal(t, pizza.ErrMQTTRefused, err)
+}
+
+func TestMQTTPublisherServerName(t *testing.T) {
+	for broker, expected := range map[string][2]string{
+		"mqtts://broker.example.com":      {"broker.example.com:8883", "broker.example.com"},
+		"mqtts://broker.example.com:8884": {"broker.example.com:8884", "broker.example.com"},
+		"mqtts://[::1]":                   {"[::1]:8883", "::1"},
+		"mqtts://[2001:db8::1]:8884":      {"[2001:db8::1]:8884", "2001:db8::1"},
+		"mqtt://[::1]":                    {"[::1]:1883", "::1"},
+	} {
+		// GIVEN
+		publisher, err := pizza.NewMQTTPublisher(pizza.MQTTConfig{Broker: broker})
+		require.Nil(t, err)
+
+		// WHEN
+		addr, serverName := publisher.Dial()
+
+		// THEN the port is never mistaken for part of the host
+		assert.Equal(t, expected[0], addr, broker)
+		assert.Equal(t, expected[1], serverName, broker)
+	}
+}
+
+func TestMQTTPublishDiscovery(t *testing.T) {
+	// GIVEN a broker keeping what it is sent
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, 2)
+		io.ReadFull(conn, header)
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		rest, _ := io.ReadAll(conn)
+		received <- rest
+	}()
+	publisher, err := pizza.NewMQTTPublisher(pizza.MQTTConfig{Broker: "tcp://" + ln.Addr().String(), Topic: "pizza"})
+	require.Nil(t, err)
+	defer pizza.SetMQTTPublisher(publisher)()
+
+	// WHEN
+	pizza.PublishDiscovery("homeassistant/")
+
+	// THEN Home Assistant finds a sensor for each of the next Friday's states
+	sent := string(<-received)
+	for _, topic := range []string{
+		"homeassistant/sensor/rsvp_pizza_headcount/config",
+		"homeassistant/sensor/rsvp_pizza_next/config",
+		"homeassistant/sensor/rsvp_pizza_deadline/config",
+		"homeassistant/binary_sensor/rsvp_pizza_open/config",
+	} {
+		assert.Contains(t, sent, topic)
+	}
+	assert.Contains(t, sent, `"state_topic":"pizza/next/headcount"`)
+	assert.Contains(t, sent, `"unique_id":"rsvp_pizza_headcount"`)
+}
+
+func TestAPIHomeAssistant(t *testing.T) {
+	// GIVEN the made up events of the sandbox
+	sandbox := pizza.NewSandboxStore(time.Now())
+	defer pizza.SetStore(sandbox)()
+	fridays, err := sandbox.GetUpcomingFridays(pizza.UpcomingDays)
+	require.Nil(t, err)
+	require.NotEmpty(t, fridays)
+	rsvps, err := sandbox.ListFridayRSVPs(fridays[0].ID())
+	require.Nil(t, err)
+
+	// WHEN
+	w := httptest.NewRecorder()
+	pizza.HandleAPIHomeAssistant(w, httptest.NewRequest(http.MethodGet, "/api/v1/homeassistant", nil))
+
+	// THEN the sensor's state is the next Friday's headcount
+	require.Equal(t, http.StatusOK, w.Code)
+	var sensor pizza.HomeAssistantSensor
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &sensor))
+	assert.Equal(t, pizza.Headcount(rsvps), sensor.State)
+	assert.Equal(t, fridays[0].ID(), sensor.Attributes.FridayID)
+	require.NotNil(t, sensor.Attributes.NextEvent)
+	assert.True(t, fridays[0].Start.Equal(*sensor.Attributes.NextEvent))
+	require.NotNil(t, sensor.Attributes.Deadline)
+	assert.True(t, fridays[0].Deadline().Equal(*sensor.Attributes.Deadline))
+	assert.Equal(t, fridays[0].IsOpen(), sensor.Attributes.RSVPsOpen)
+	assert.Equal(t, "people", sensor.Attributes.UnitOfMeasure)
+}
@@ -0,0 +1,392 @@
+package pizza
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	NotifyRSVPsOpen = "open"
+	NotifySpotOpen  = "spot"
+)
+
+// SpotNotifyAll offers a freed up spot to everyone waiting for one at once
+// instead of to one friend at a time in the order they asked.
+var SpotNotifyAll = false
+
+// ClaimWindow is how long a friend has to claim a spot they were offered.
+var ClaimWindow = 2 * time.Hour
+
+type NotifyPageData struct {
+	FridayID   string
+	Kind       string
+	Date       string
+	Opens      string
+	Email      string
+	Subscribed bool
+	Held       bool
+	FormToken  string
+	Captcha    *CaptchaWidget
+}
+
+type OpenedEmailData struct {
+	Name     string
+	Date     string
+	Deadline string
+	RSVPURL  string
+	// Reply is set when friends can RSVP by replying to the email
+	Reply bool
+}
+
+type SpotEmailData struct {
+	Name     string
+	Date     string
+	Expires  string
+	ClaimURL string
+}
+
+// HandleNotify lets a friend ask to be emailed when RSVPs open for a Friday or
+// when a spot opens up at a full one.
+func HandleNotify(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/notify.html")
+	if err != nil {
+		Log.Error("template notify failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	friday, ok, err := GetCachedFriday(UpcomingDays, r.Form.Get("friday"))
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		Handle500(w, r)
+		return
+	} else if !ok {
+		Handle4xx(w, r)
+		return
+	}
+	data := NotifyPageData{
+		FridayID:  friday.ID(),
+		Kind:      r.Form.Get("kind"),
+		Date:      FormatTime(friday.Start),
+		Opens:     FormatTime(friday.Opens()),
+		Email:     friendFromCookie(r),
+		FormToken: FormToken(),
+		Captcha:   CaptchaWidgetFor(),
+	}
+	switch data.Kind {
+	case "", NotifyRSVPsOpen:
+		data.Kind = NotifyRSVPsOpen
+		ok = time.Now().Before(friday.Opens())
+	case NotifySpotOpen:
+		ok, err = isFull(friday)
+	default:
+		ok = false
+	}
+	if err != nil {
+		Log.Error("failed to get friday status", zap.Error(err))
+		Handle500(w, r)
+		return
+	} else if !ok {
+		Handle4xx(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !CheckCaptcha(r, r.PostForm) {
+			Handle4xx(w, r)
+			return
+		}
+		email := strings.ToLower(r.PostForm.Get("email"))
+		if allowed, err := IsFriendAllowed(email); !allowed {
+			if err != nil {
+				Log.Error("error checking email for notify request", zap.Error(err))
+				Handle500(w, r)
+			} else {
+				Handle4xx(w, r)
+			}
+			return
+		}
+		if email, err = GetCachedPrimaryEmail(email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		if reason := CheckSpam(r.PostForm); len(reason) > 0 {
+			err = holdSubmission("notify", r.PostForm, reason)
+			data.Held = true
+		} else {
+			err = CreateNotification(Notification{Email: email, FridayID: friday.ID(), Kind: data.Kind})
+			data.Subscribed = true
+		}
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		data.Email = email
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+func isFull(friday Friday) (bool, error) {
+	if friday.Limit() == 0 || !friday.IsOpen() {
+		return false, nil
+	}
+	status, err := GetFridayStatus(friday)
+	return status.Headcount >= friday.Limit(), err
+}
+
+// WatchNotifications periodically lets subscribed friends know about Fridays
+// they are waiting on.
+func WatchNotifications(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		SendOpenNotifications()
+		fridays, err := GetCachedFridays(UpcomingDays)
+		if err != nil {
+			Log.Warn("failed to get fridays for notifications", zap.Error(err))
+		}
+		for _, friday := range fridays {
+			CheckOpenSpots(friday)
+			CheckHeadcountAlerts(friday)
+			CheckReminders(friday)
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
+
+// SendOpenNotifications emails everyone waiting for RSVPs to open on a Friday
+// that is now open. Each subscription is removed once it has been sent.
+func SendOpenNotifications() {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		Log.Warn("failed to get fridays for notifications", zap.Error(err))
+		return
+	}
+	for _, friday := range fridays {
+		if !friday.IsOpen() {
+			continue
+		}
+		notifications, err := ListNotifications(friday.ID(), NotifyRSVPsOpen)
+		if err != nil {
+			continue
+		}
+		for _, n := range notifications {
+			name, _ := GetCachedFriendName(n.Email)
+			rsvpURL := InviteURL(BaseURL, n.Email)
+			trackEmail(n.Email, "opened", &rsvpURL)
+			msg, err := RenderEmail("opened", OpenedEmailData{
+				Name:     name,
+				Date:     FormatTime(friday.Start),
+				Deadline: FormatTime(friday.Deadline()),
+				RSVPURL:  rsvpURL,
+				Reply:    len(ReplyAddress(friday.ID())) > 0,
+			})
+			if err != nil {
+				Log.Error("opened template failure", zap.Error(err))
+				return
+			}
+			msg.To = n.Email
+			msg.ReplyTo = ReplyAddress(friday.ID())
+			if err = SendEmail(msg); err != nil && err != ErrEmailSuppressed {
+				Log.Warn("failed to send open notification", zap.Error(err), zap.String("email", n.Email))
+				continue
+			}
+			DeleteNotification(n.ID)
+		}
+	}
+}
+
+// CheckOpenSpots offers any room left at the Friday to the friends waiting for
+// a spot. Friends offered a spot one at a time have ClaimWindow to claim it
+// before it is offered to the next friend.
+func CheckOpenSpots(friday Friday) {
+	if friday.Limit() == 0 || !friday.IsOpen() {
+		return
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return
+	}
+	if Headcount(rsvps) >= friday.Limit() {
+		return
+	}
+	notifications, err := ListNotifications(friday.ID(), NotifySpotOpen)
+	if err != nil {
+		return
+	}
+	for _, n := range notifications {
+		if hasRSVP(rsvps, n.Email) {
+			DeleteNotification(n.ID)
+			continue
+		}
+		if !n.NotifiedAt.IsZero() {
+			if time.Since(n.NotifiedAt) < ClaimWindow {
+				return
+			}
+			DeleteNotification(n.ID)
+			continue
+		}
+		if err = sendSpotOpen(friday, n); err != nil {
+			Log.Warn("failed to send spot notification", zap.Error(err), zap.String("email", n.Email))
+			if err == ErrEmailSuppressed {
+				DeleteNotification(n.ID)
+			}
+			continue
+		}
+		if SpotNotifyAll {
+			DeleteNotification(n.ID)
+			continue
+		}
+		n.NotifiedAt = time.Now()
+		UpdateNotification(n)
+		return
+	}
+}
+
+func hasRSVP(rsvps []RSVP, email string) bool {
+	for _, rsvp := range rsvps {
+		if rsvp.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func sendSpotOpen(friday Friday, n Notification) error {
+	expires := time.Now().Add(ClaimWindow)
+	name, _ := GetCachedFriendName(n.Email)
+	claimURL := BaseURL + ClaimURL(friday.ID(), n.Email, expires)
+	trackEmail(n.Email, "spot", &claimURL)
+	msg, err := RenderEmail("spot", SpotEmailData{
+		Name:     name,
+		Date:     FormatTime(friday.Start),
+		Expires:  FormatTime(expires),
+		ClaimURL: claimURL,
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = n.Email
+	return SendEmail(msg)
+}
+
+// ClaimURL is the personal link for claiming a spot at a full Friday before
+// it expires.
+func ClaimURL(fridayID, email string, expires time.Time) string {
+	exp := LinkExpiry(expires)
+	q := url.Values{}
+	q.Set("email", email)
+	q.Set("expires", exp)
+	q.Set("sig", SignLink("claim", fridayID, email, exp))
+	return fmt.Sprintf("/claim/%s?%s", fridayID, q.Encode())
+}
+
+type ClaimPageData struct {
+	FridayID string
+	Email    string
+	Expires  string
+	Sig      string
+	Date     string
+	Room     int
+	// MaxPlusOnes is how many friends fit alongside the claimer
+	MaxPlusOnes int
+	Expired     bool
+	Claimed     bool
+	Taken       bool
+	EditURL     string
+}
+
+// HandleClaim takes an RSVP for a spot offered to the friend. The spot is
+// only taken on POST so link previews can't claim it.
+func HandleClaim(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/claim.html")
+	if err != nil {
+		Log.Error("template claim failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := ClaimPageData{
+		FridayID: mux.Vars(r)["id"],
+		Email:    strings.ToLower(r.Form.Get("email")),
+		Expires:  r.Form.Get("expires"),
+		Sig:      r.Form.Get("sig"),
+	}
+	if !VerifyLink(data.Sig, "claim", data.FridayID, data.Email, data.Expires) {
+		Handle4xx(w, r)
+		return
+	}
+	friday, ok, err := GetCachedFriday(UpcomingDays, data.FridayID)
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		Handle500(w, r)
+		return
+	} else if !ok {
+		Handle4xx(w, r)
+		return
+	}
+	data.Date = FormatTime(friday.Start)
+	data.Expired = LinkExpired(data.Expires, time.Now()) || !friday.IsOpen()
+
+	status, err := GetFridayStatus(friday)
+	if err != nil {
+		Log.Error("failed to get friday status", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	maxPlusOnes := MaxPlusOnesFor(data.Email)
+	data.Room = friday.Limit() - status.Headcount
+	if friday.Limit() == 0 {
+		data.Room = maxPlusOnes + 1
+	}
+	data.Taken = data.Room < 1
+	data.MaxPlusOnes = maxPlusOnes
+	if data.Room-1 < data.MaxPlusOnes {
+		data.MaxPlusOnes = data.Room - 1
+	}
+
+	if r.Method == http.MethodPost && !data.Expired && !data.Taken {
+		plusOnes, err := strconv.Atoi(r.PostForm.Get("plusOnes"))
+		if err != nil || plusOnes < 0 || plusOnes > data.MaxPlusOnes {
+			Handle4xx(w, r)
+			return
+		}
+		rsvp, err := RSVPToFriday(data.Email, friday, RSVPOptions{PlusOnes: plusOnes})
+		if err != nil {
+			Log.Error("claim failed", zap.Error(err), zap.String("eventID", friday.ID()), zap.String("email", data.Email))
+			Handle500(w, r)
+			return
+		}
+		rememberFriend(w, r, data.Email)
+		data.Claimed = rsvp.Confirmed()
+		data.Taken = !data.Claimed
+		data.EditURL = EditRSVPURL(rsvp)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
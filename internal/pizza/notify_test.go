@@ -0,0 +1,49 @@
+package pizza_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimURL(t *testing.T) {
+	// GIVEN
+	expires := time.Unix(1680900000, 0)
+
+	// WHEN
+	link := pizza.ClaimURL("1680903000", "believe@tedlasso.com", expires)
+
+	// THEN
+	require.True(t, strings.HasPrefix(link, "/claim/1680903000?"))
+	u, err := url.Parse(link)
+	require.Nil(t, err)
+	q := u.Query()
+	assert.Equal(t, "believe@tedlasso.com", q.Get("email"))
+	assert.Equal(t, "1680900000", q.Get("expires"))
+	assert.True(t, pizza.VerifyLink(q.Get("sig"), "claim", "1680903000", "believe@tedlasso.com", "1680900000"))
+	assert.False(t, pizza.VerifyLink(q.Get("sig"), "claim", "1680903000", "believe@tedlasso.com", "1680999999"))
+}
+
+func TestRenderSpotEmail(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	data := pizza.SpotEmailData{
+		Name:     "Ted Lasso",
+		Date:     "07 Apr 23 17:30 EDT",
+		Expires:  "07 Apr 23 13:30 EDT",
+		ClaimURL: "https://rsvp.pizza/claim/1680903000?sig=abc",
+	}
+
+	// WHEN
+	msg, err := pizza.RenderEmail("spot", data)
+
+	// THEN
+	require.Nil(t, err)
+	assert.Equal(t, "A spot opened up at Pizza Friday", msg.Subject)
+	assert.Contains(t, msg.Body, "Claim it by 07 Apr 23 13:30 EDT at https://rsvp.pizza/claim/1680903000?sig=abc")
+}
@@ -0,0 +1,229 @@
+package pizza
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+)
+
+const oauthCookieName = "pizza_oauth"
+
+// OAuthStateTTL is how long a friend has to log in with the provider.
+var OAuthStateTTL = 10 * time.Minute
+
+var ErrUnverifiedEmail = errors.New("account has no verified email")
+
+// LoginProvider logs friends in with an account they already have, taking
+// their email from it instead of a form.
+type LoginProvider struct {
+	// Name is the provider in the login URLs, like google
+	Name  string
+	Title string
+	// EmailURL answers with the account's emails, read by ParseEmail
+	EmailURL   string
+	ParseEmail func(io.Reader) (string, error)
+	config     oauth2.Config
+	client     *http.Client
+}
+
+// loginProviders are the providers turned on in the config, in the order
+// they are shown.
+var loginProviders []*LoginProvider
+
+func OAuthEnabled() bool {
+	return len(loginProviders) > 0
+}
+
+func findLoginProvider(name string) *LoginProvider {
+	for _, p := range loginProviders {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// initOAuth turns on the providers with a client ID. BaseURL must be set,
+// since the providers send friends back to it.
+func initOAuth(config OAuthConfig) {
+	loginProviders = nil
+	add := func(name, title string, client OAuthClientConfig, endpoint oauth2.Endpoint, scopes []string, emailURL string, parse func(io.Reader) (string, error)) {
+		if len(client.ClientID) == 0 {
+			return
+		}
+		loginProviders = append(loginProviders, &LoginProvider{
+			Name:       name,
+			Title:      title,
+			EmailURL:   emailURL,
+			ParseEmail: parse,
+			config: oauth2.Config{
+				ClientID:     client.ClientID,
+				ClientSecret: client.ClientSecret,
+				Endpoint:     endpoint,
+				RedirectURL:  BaseURL + "/login/" + name + "/callback",
+				Scopes:       scopes,
+			},
+			client: &http.Client{Timeout: 10 * time.Second},
+		})
+	}
+	add("google", "Google", config.Google, google.Endpoint, []string{"openid", "email"}, "https://openidconnect.googleapis.com/v1/userinfo", GoogleEmail)
+	add("github", "GitHub", config.GitHub, github.Endpoint, []string{"user:email"}, "https://api.github.com/user/emails", GitHubEmail)
+}
+
+// GoogleEmail reads the email from Google's userinfo, if Google verified it.
+func GoogleEmail(body io.Reader) (string, error) {
+	var info struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := json.NewDecoder(body).Decode(&info); err != nil {
+		return "", err
+	}
+	if !info.EmailVerified || len(info.Email) == 0 {
+		return "", ErrUnverifiedEmail
+	}
+	return strings.ToLower(info.Email), nil
+}
+
+// GitHubEmail reads the primary email from the GitHub account's emails, if
+// GitHub verified it.
+func GitHubEmail(body io.Reader) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := json.NewDecoder(body).Decode(&emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return strings.ToLower(e.Email), nil
+		}
+	}
+	return "", ErrUnverifiedEmail
+}
+
+// email trades the code the provider sent the friend back with for their
+// verified email.
+func (p *LoginProvider) email(ctx context.Context, code string) (string, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	res, err := p.config.Client(ctx, token).Get(p.EmailURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s answered %s", p.EmailURL, res.Status)
+	}
+	return p.ParseEmail(res.Body)
+}
+
+// loginNext is where to go after logging in, only ever a page on the site.
+func loginNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// HandleOAuthLogin sends the friend to the provider to log in, remembering
+// in a cookie the state to expect back and where to go after.
+func HandleOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider := findLoginProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		Handle4xx(w, r)
+		return
+	}
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate oauth state: %v", err))
+	}
+	state := base64.RawURLEncoding.EncodeToString(buf)
+	cookie := newCookie(oauthCookieName, url.Values{"state": {state}, "next": {loginNext(r.URL.Query().Get("next"))}}.Encode(), "/login/", time.Time{})
+	cookie.MaxAge = int(OAuthStateTTL.Seconds())
+	// sent back on the provider's redirect, which is a top level GET
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, provider.config.AuthCodeURL(state), http.StatusSeeOther)
+}
+
+// HandleOAuthCallback logs in the friend the provider sent back, as the
+// friend whose email, or one of their aliases, is the account's.
+func HandleOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider := findLoginProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		Handle4xx(w, r)
+		return
+	}
+	cookie, err := r.Cookie(oauthCookieName)
+	if err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	clearCookie(w, oauthCookieName, "/login/")
+	saved, err := url.ParseQuery(cookie.Value)
+	state := r.URL.Query().Get("state")
+	if err != nil || len(state) == 0 || subtle.ConstantTimeCompare([]byte(state), []byte(saved.Get("state"))) != 1 {
+		Log.Debug("oauth state mismatch", zap.String("provider", provider.Name))
+		Handle4xx(w, r)
+		return
+	}
+
+	data := newLoginPageData()
+	email := ""
+	if code := r.URL.Query().Get("code"); len(code) == 0 {
+		// the friend said no, or the provider failed
+		data.Error = provider.Title + " didn't log you in, try again or log in another way."
+	} else if email, err = provider.email(r.Context(), code); errors.Is(err, ErrUnverifiedEmail) {
+		data.Error = "Your " + provider.Title + " account doesn't have a verified email."
+	} else if err != nil {
+		Log.Warn("oauth login failed", zap.Error(err), zap.String("provider", provider.Name))
+		data.Error = provider.Title + " didn't log you in, try again or log in another way."
+	} else if allowed, err := IsFriendAllowed(email); err != nil {
+		Handle500(w, r)
+		return
+	} else if !allowed {
+		data.Error = email + " isn't on the friends list. Log in with the account you were invited with, or ask the host to add you."
+	} else {
+		if email, err = GetCachedPrimaryEmail(email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		Log.Info("friend logged in", zap.String("provider", provider.Name), zap.String("email", email))
+		rememberFriend(w, r, email)
+		http.Redirect(w, r, loginNext(saved.Get("next")), http.StatusSeeOther)
+		return
+	}
+
+	plate, err := parseTemplate("html/login.html")
+	if err != nil {
+		Log.Error("template login failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
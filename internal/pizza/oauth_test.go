@@ -0,0 +1,42 @@
+package pizza_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleEmail(t *testing.T) {
+	// WHEN
+	email, err := pizza.GoogleEmail(strings.NewReader(`{"sub":"1","email":"Believe@TedLasso.com","email_verified":true}`))
+	require.NoError(t, err)
+	_, unverified := pizza.GoogleEmail(strings.NewReader(`{"sub":"1","email":"believe@tedlasso.com","email_verified":false}`))
+	_, invalid := pizza.GoogleEmail(strings.NewReader(`<html>`))
+
+	// THEN
+	assert.Equal(t, "believe@tedlasso.com", email)
+	assert.ErrorIs(t, unverified, pizza.ErrUnverifiedEmail)
+	assert.Error(t, invalid)
+}
+
+func TestGitHubEmail(t *testing.T) {
+	// WHEN only the primary email counts, once GitHub verified it
+	email, err := pizza.GitHubEmail(strings.NewReader(`[
+		{"email":"roy@kent.com","primary":false,"verified":true},
+		{"email":"Roy@Richmond.com","primary":true,"verified":true}
+	]`))
+	require.NoError(t, err)
+	_, unverified := pizza.GitHubEmail(strings.NewReader(`[
+		{"email":"roy@kent.com","primary":false,"verified":true},
+		{"email":"roy@richmond.com","primary":true,"verified":false}
+	]`))
+	_, none := pizza.GitHubEmail(strings.NewReader(`[]`))
+
+	// THEN
+	assert.Equal(t, "roy@richmond.com", email)
+	assert.ErrorIs(t, unverified, pizza.ErrUnverifiedEmail)
+	assert.ErrorIs(t, none, pizza.ErrUnverifiedEmail)
+}
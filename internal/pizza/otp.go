@@ -0,0 +1,120 @@
+package pizza
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// OTPTTL is how long a texted login code works.
+	OTPTTL = 10 * time.Minute
+	// OTPResendAfter is how long before another code can be texted to the same
+	// number.
+	OTPResendAfter = time.Minute
+	// OTPMaxAttempts is how many wrong guesses at a friend's codes, across all
+	// of them, lock the friend out.
+	OTPMaxAttempts = 5
+	// OTPLockout is how long a friend is locked out for, counted from their
+	// first wrong guess.
+	OTPLockout = time.Hour
+)
+
+type otpCode struct {
+	email string
+	code  string
+	sent  time.Time
+}
+
+type otpFailure struct {
+	count int
+	since time.Time
+}
+
+// codes live in memory, a restart only means asking for a new one
+var (
+	otpMu       sync.Mutex
+	otpCodes    = map[string]*otpCode{}
+	otpFailures = map[string]*otpFailure{}
+)
+
+// expireOTPs forgets the codes and wrong guesses that no longer count.
+func expireOTPs(now time.Time) {
+	for k, c := range otpCodes {
+		if now.Sub(c.sent) > OTPTTL {
+			delete(otpCodes, k)
+		}
+	}
+	for email, f := range otpFailures {
+		if now.Sub(f.since) > OTPLockout {
+			delete(otpFailures, email)
+		}
+	}
+}
+
+func otpLockedOut(email string) bool {
+	f, ok := otpFailures[email]
+	return ok && f.count >= OTPMaxAttempts
+}
+
+// NewOTP makes a six digit code for the key, usually a phone number, for the
+// friend with the email. It returns false if a code was made for the key too
+// recently or the friend is locked out.
+func NewOTP(email, key string) (string, bool) {
+	otpMu.Lock()
+	defer otpMu.Unlock()
+	now := time.Now()
+	expireOTPs(now)
+	email = strings.ToLower(email)
+	if otpLockedOut(email) {
+		return "", false
+	}
+	if c, ok := otpCodes[key]; ok && now.Sub(c.sent) < OTPResendAfter {
+		return "", false
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(fmt.Sprintf("could not generate code: %v", err))
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	otpCodes[key] = &otpCode{email: email, code: code, sent: now}
+	return code, true
+}
+
+// CheckOTP reports whether the code is the one made for the key for the
+// friend with the email. A code works once. Wrong guesses count against the
+// friend, however many codes they ask for, and once they made OTPMaxAttempts
+// none of their codes work until OTPLockout has passed.
+func CheckOTP(email, key, code string) bool {
+	otpMu.Lock()
+	defer otpMu.Unlock()
+	now := time.Now()
+	expireOTPs(now)
+	email = strings.ToLower(email)
+	if otpLockedOut(email) {
+		return false
+	}
+	c, ok := otpCodes[key]
+	if ok && c.email == email && subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) == 1 {
+		delete(otpCodes, key)
+		delete(otpFailures, email)
+		return true
+	}
+	f, ok := otpFailures[email]
+	if !ok {
+		f = &otpFailure{since: now}
+		otpFailures[email] = f
+	}
+	if f.count++; f.count >= OTPMaxAttempts {
+		for k, c := range otpCodes {
+			if c.email == email {
+				delete(otpCodes, k)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTP(t *testing.T) {
+	// GIVEN
+	code, ok := pizza.NewOTP("believe@tedlasso.com", "login:+15555550123")
+
+	// WHEN
+	_, again := pizza.NewOTP("believe@tedlasso.com", "login:+15555550123")
+
+	// THEN
+	assert.True(t, ok)
+	assert.Len(t, code, 6)
+	assert.False(t, again)
+	assert.False(t, pizza.CheckOTP("believe@tedlasso.com", "login:+15555550199", code))
+	assert.False(t, pizza.CheckOTP("roy@kent.com", "login:+15555550123", code))
+	assert.True(t, pizza.CheckOTP("believe@tedlasso.com", "login:+15555550123", code))
+	assert.False(t, pizza.CheckOTP("believe@tedlasso.com", "login:+15555550123", code))
+}
+
+func TestOTPMaxAttempts(t *testing.T) {
+	// GIVEN
+	code, _ := pizza.NewOTP("keeley@jones.com", "login:+15555550124")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	// WHEN
+	for i := 0; i < pizza.OTPMaxAttempts; i++ {
+		pizza.CheckOTP("keeley@jones.com", "login:+15555550124", wrong)
+	}
+
+	// THEN
+	assert.False(t, pizza.CheckOTP("keeley@jones.com", "login:+15555550124", code))
+}
+
+func TestOTPLockoutAcrossCodes(t *testing.T) {
+	// GIVEN a friend guessing at the code texted to one number, then asking
+	// for a code for another
+	code, _ := pizza.NewOTP("jamie@tartt.com", "login:+15555550125")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < pizza.OTPMaxAttempts-1; i++ {
+		pizza.CheckOTP("jamie@tartt.com", "login:+15555550125", wrong)
+	}
+	other, ok := pizza.NewOTP("jamie@tartt.com", "phone:jamie@tartt.com:+15555550126")
+	assert.True(t, ok)
+	wrong = "000000"
+	if other == wrong {
+		wrong = "111111"
+	}
+
+	// WHEN they guess wrong once more at the new code
+	pizza.CheckOTP("jamie@tartt.com", "phone:jamie@tartt.com:+15555550126", wrong)
+
+	// THEN they are locked out of both, and no more codes are made for them,
+	// while other friends can still log in
+	assert.False(t, pizza.CheckOTP("jamie@tartt.com", "phone:jamie@tartt.com:+15555550126", other))
+	assert.False(t, pizza.CheckOTP("jamie@tartt.com", "login:+15555550125", code))
+	_, ok = pizza.NewOTP("Jamie@Tartt.com", "login:+15555550127")
+	assert.False(t, ok)
+	sam, ok := pizza.NewOTP("sam@obisanya.com", "login:+15555550128")
+	assert.True(t, ok)
+	assert.True(t, pizza.CheckOTP("sam@obisanya.com", "login:+15555550128", sam))
+}
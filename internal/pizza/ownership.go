@@ -0,0 +1,340 @@
+package pizza
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TransferTTL is how long the next owner has to accept the series.
+var TransferTTL = 72 * time.Hour
+
+// MinAdminPasswordLength is the shortest admin password a new owner may pick.
+const MinAdminPasswordLength = 12
+
+var ownershipCache *Cache[Ownership]
+
+func init() {
+	c := NewCache(time.Minute, func(string) (Ownership, error) { return GetOwnership() })
+	ownershipCache = &c
+}
+
+// HashAdminPassword salts and hashes a new owner's admin password. It doesn't
+// use the link secret, which may change on every restart.
+func HashAdminPassword(password string) string {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(fmt.Sprintf("could not generate password salt: %v", err))
+	}
+	return hex.EncodeToString(salt) + "." + hashAdminPassword(salt, password)
+}
+
+func hashAdminPassword(salt []byte, password string) string {
+	sum := sha256.Sum256(append(salt, password...))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAdminPasswordHash reports whether the password is the one hashed by
+// HashAdminPassword.
+func CheckAdminPasswordHash(hash, password string) bool {
+	saltHex, sum, ok := strings.Cut(hash, ".")
+	salt, err := hex.DecodeString(saltHex)
+	if !ok || err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashAdminPassword(salt, password)), []byte(sum)) == 1
+}
+
+// checkAdminPassword reports whether the password is the owner's, which is the
+// config file's until the series is first transferred.
+func checkAdminPassword(password string) (bool, error) {
+	own, err := ownershipCache.Get("")
+	if err != nil {
+		return false, err
+	}
+	if len(own.PasswordHash) > 0 {
+		return CheckAdminPasswordHash(own.PasswordHash, password), nil
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(AdminPassword)) == 1, nil
+}
+
+// TransferURL is the link emailed to the next owner to accept the series. It
+// works until the transfer expires, unless the owner takes it back.
+func TransferURL(to string, expires time.Time) string {
+	exp := LinkExpiry(expires)
+	q := url.Values{}
+	q.Set("to", to)
+	q.Set("expires", exp)
+	q.Set("sig", SignLink("transfer", to, exp))
+	return "/transfer?" + q.Encode()
+}
+
+// ApplyOwnership sends the host's email to the owner once the series was
+// transferred.
+func ApplyOwnership(own Ownership) {
+	if len(own.Owner) > 0 {
+		UpdateSettings(func(s *RuntimeSettings) { s.HostEmail = own.Owner })
+	}
+}
+
+// WatchOwnership picks up a transfer accepted on another instance.
+func WatchOwnership(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		if own, err := ownershipCache.Get(""); err != nil {
+			Log.Warn("failed to load ownership", zap.Error(err))
+		} else {
+			ApplyOwnership(own)
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
+
+type TransferEmailData struct {
+	Owner     string
+	AcceptURL string
+	Expires   string
+}
+
+type TransferredEmailData struct {
+	Owner    string
+	AuditURL string
+}
+
+type AdminTransferPageData struct {
+	AdminView
+	Owner string
+	Since string
+	// To and Expires are the transfer waiting to be accepted
+	To      string
+	Expires string
+	Message string
+	Error   string
+}
+
+// transferTarget names who the series is offered to, so the owner confirms
+// the offer. Taking it back needs no confirmation.
+func transferTarget(r *http.Request) (string, error) {
+	if r.PostForm.Get("action") != "start" {
+		return "", nil
+	}
+	return strings.ToLower(strings.TrimSpace(r.PostForm.Get("to"))), nil
+}
+
+// HandleAdminTransfer offers the series to another admin, who gets an email
+// to accept it, and takes the offer back. The offer goes through
+// requireConfirmation, which adds it to the audit log.
+func HandleAdminTransfer(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/transfer.html")
+	if err != nil {
+		Log.Error("template admin transfer failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	own, err := GetOwnership()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminTransferPageData{AdminView: newAdminView(w, r)}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		switch r.PostForm.Get("action") {
+		case "start":
+			to, _ := transferTarget(r)
+			if addr, err := mail.ParseAddress(to); err != nil || addr.Address != to {
+				data.Error = "That doesn't look like an email address."
+				break
+			} else if to == strings.ToLower(CurrentSettings().HostEmail) {
+				data.Error = "The series is already yours."
+				break
+			} else if isDryRun(r) {
+				writePlan(w, r, PlanTransfer(to))
+				return
+			}
+			own.TransferTo = to
+			own.TransferExpires = time.Now().Add(TransferTTL)
+			if err = SaveOwnership(own); err != nil {
+				Handle500(w, r)
+				return
+			}
+			ownershipCache.Delete("")
+			msg, err := RenderEmail("transfer", TransferEmailData{
+				Owner:     CurrentSettings().HostEmail,
+				AcceptURL: BaseURL + TransferURL(to, own.TransferExpires),
+				Expires:   FormatTime(own.TransferExpires),
+			})
+			if err != nil {
+				Log.Error("transfer template failure", zap.Error(err))
+				Handle500(w, r)
+				return
+			}
+			msg.To = to
+			if err = SendEmail(msg); err != nil {
+				Log.Warn("failed to send transfer", zap.Error(err), zap.String("to", to))
+				data.Error = "The transfer was saved but the email didn't send, try again."
+				break
+			}
+			Log.Info("ownership transfer offered", zap.String("to", to))
+			data.Message = "Sent " + to + " a link to accept the series."
+		case "cancel":
+			if len(own.TransferTo) == 0 {
+				break
+			} else if isDryRun(r) {
+				writePlan(w, r, PlanCancelTransfer(own.TransferTo))
+				return
+			}
+			if err = RecordAudit("cancel transfer", own.TransferTo, requestIP(r)); err != nil {
+				Handle500(w, r)
+				return
+			}
+			own.TransferTo, own.TransferExpires = "", time.Time{}
+			if err = SaveOwnership(own); err != nil {
+				Handle500(w, r)
+				return
+			}
+			ownershipCache.Delete("")
+			data.Message = "Took back the transfer."
+		default:
+			Handle4xx(w, r)
+			return
+		}
+	}
+
+	data.Owner = CurrentSettings().HostEmail
+	if !own.Since.IsZero() {
+		data.Since = FormatTime(own.Since)
+	}
+	if len(own.TransferTo) > 0 && time.Now().Before(own.TransferExpires) {
+		data.To = own.TransferTo
+		data.Expires = FormatTime(own.TransferExpires)
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type TransferPageData struct {
+	To      string
+	Expires string
+	Sig     string
+	// Hint is the masked email the link was sent to, which the next owner
+	// types back in case it was forwarded
+	Hint     string
+	Owner    string
+	Accepted bool
+	// Closed is set when the transfer expired or was taken back
+	Closed bool
+	Error  string
+	// CSRFToken goes in the form's csrf field, checked by checkBrowserCSRF
+	CSRFToken string
+}
+
+// HandleTransfer lets the next owner accept the series from the email the
+// owner sent them. They confirm their email and pick a new admin password;
+// the friends, settings, and parties stay where they are, the second factor is
+// turned off for them to set up their own, and they are asked to renew the
+// calendar credentials with their account.
+func HandleTransfer(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/transfer.html")
+	if err != nil {
+		Log.Error("template transfer failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := TransferPageData{To: r.Form.Get("to"), Expires: r.Form.Get("expires"), Sig: r.Form.Get("sig")}
+	if !VerifyLink(data.Sig, "transfer", data.To, data.Expires) {
+		Handle4xx(w, r)
+		return
+	}
+	own, err := GetOwnership()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data.Hint = MaskEmail(data.To)
+	data.Owner = CurrentSettings().HostEmail
+	data.Closed = LinkExpired(data.Expires, time.Now()) || own.TransferTo != data.To ||
+		LinkExpiry(own.TransferExpires) != data.Expires
+
+	if r.Method == http.MethodPost && !data.Closed {
+		if !checkBrowserCSRF(r) {
+			Handle4xx(w, r)
+			return
+		}
+		email := strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+		password := r.PostForm.Get("password")
+		if email != data.To {
+			data.Error = "That isn't the email the link was sent to."
+		} else if len(password) < MinAdminPasswordLength {
+			data.Error = fmt.Sprintf("Pick a password of at least %d characters.", MinAdminPasswordLength)
+		} else if password != r.PostForm.Get("password_again") {
+			data.Error = "The passwords didn't match."
+		}
+		if len(data.Error) == 0 {
+			// nothing changes hands without a record of it
+			if err = RecordAudit("accept transfer", data.To, requestIP(r)); err != nil {
+				Handle500(w, r)
+				return
+			}
+			previous := CurrentSettings().HostEmail
+			own = Ownership{Owner: data.To, PasswordHash: HashAdminPassword(password), Since: time.Now()}
+			if err = SaveOwnership(own); err != nil {
+				Handle500(w, r)
+				return
+			}
+			ownershipCache.Delete("")
+			// the previous owner's authenticator app no longer logs in
+			if err = SaveAdminAuth(AdminAuth{}); err != nil {
+				Handle500(w, r)
+				return
+			}
+			adminAuthCache.Delete("")
+			ApplyOwnership(own)
+			Log.Info("ownership transferred", zap.String("from", previous), zap.String("to", data.To))
+
+			if len(previous) > 0 {
+				msg, err := RenderEmail("transferred", TransferredEmailData{Owner: data.To, AuditURL: BaseURL + "/admin/audit"})
+				if err != nil {
+					Log.Error("transferred template failure", zap.Error(err))
+				} else {
+					msg.To = previous
+					if err = SendEmail(msg); err != nil {
+						Log.Warn("failed to send transferred", zap.Error(err), zap.String("email", previous))
+					}
+				}
+			}
+			data.Accepted = true
+		}
+	}
+
+	data.CSRFToken = browserCSRFToken(w, r)
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,72 @@
+package pizza_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPasswordHash(t *testing.T) {
+	// GIVEN
+	first := pizza.HashAdminPassword("richmond-till-i-die")
+	second := pizza.HashAdminPassword("richmond-till-i-die")
+
+	// THEN the same password is salted differently, and only it matches
+	assert.NotEqual(t, first, second)
+	assert.True(t, pizza.CheckAdminPasswordHash(first, "richmond-till-i-die"))
+	assert.True(t, pizza.CheckAdminPasswordHash(second, "richmond-till-i-die"))
+	assert.False(t, pizza.CheckAdminPasswordHash(first, "Richmond-till-i-die"))
+	assert.False(t, pizza.CheckAdminPasswordHash(first, ""))
+	assert.False(t, pizza.CheckAdminPasswordHash("", "richmond-till-i-die"))
+	assert.False(t, pizza.CheckAdminPasswordHash("not-hex."+strings.Split(first, ".")[1], "richmond-till-i-die"))
+}
+
+func TestTransferURL(t *testing.T) {
+	// GIVEN
+	expires := time.Now().Add(pizza.TransferTTL)
+
+	// WHEN
+	link, err := url.Parse(pizza.TransferURL("roy@kent.com", expires))
+	require.NoError(t, err)
+	q := link.Query()
+
+	// THEN the link is signed for the next owner until it expires
+	assert.Equal(t, "/transfer", link.Path)
+	assert.Equal(t, "roy@kent.com", q.Get("to"))
+	assert.Equal(t, pizza.LinkExpiry(expires), q.Get("expires"))
+	assert.True(t, pizza.VerifyLink(q.Get("sig"), "transfer", "roy@kent.com", q.Get("expires")))
+	assert.False(t, pizza.VerifyLink(q.Get("sig"), "transfer", "jamie@tartt.com", q.Get("expires")))
+	assert.False(t, pizza.LinkExpired(q.Get("expires"), time.Now()))
+}
+
+func TestApplyOwnershipWhileReading(t *testing.T) {
+	// GIVEN alerts and digests reading who the host is
+	defer pizza.SetSettings(func(s *pizza.RuntimeSettings) { s.HostEmail = "ted@lasso.com" })()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = pizza.CurrentSettings().HostEmail
+			}
+		}
+	}()
+
+	// WHEN a transfer is picked up, like from WatchOwnership
+	pizza.ApplyOwnership(pizza.Ownership{})
+	pizza.ApplyOwnership(pizza.Ownership{Owner: "rebecca@welton.com"})
+	close(stop)
+	<-done
+
+	// THEN alerts go to the new owner, and go test -race finds no data race
+	assert.Equal(t, "rebecca@welton.com", pizza.CurrentSettings().HostEmail)
+}
@@ -0,0 +1,170 @@
+package pizza
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type PasskeysPageData struct {
+	View
+	Enabled   bool
+	Email     string
+	Passkeys  []Passkey
+	RPID      string
+	UserID    string
+	Challenge string
+}
+
+// HandlePasskeys lists the passkeys of the friend remembered by the browser
+// and lets them add or remove one.
+func HandlePasskeys(w http.ResponseWriter, r *http.Request) {
+	data := PasskeysPageData{Enabled: PasskeysEnabled(), Email: friendFromCookie(r), RPID: WebAuthnRPID}
+	if data.Enabled && len(data.Email) > 0 {
+		var err error
+		if r.Method == http.MethodPost {
+			if err = r.ParseForm(); err != nil || !checkCSRF(r) {
+				Handle4xx(w, r)
+				return
+			}
+			if err = DeletePasskey(data.Email, r.PostForm.Get("id")); err != nil {
+				Handle500(w, r)
+				return
+			}
+			addFlash(w, r, "Removed the passkey.")
+			http.Redirect(w, r, "/passkeys", http.StatusSeeOther)
+			return
+		}
+		if data.Passkeys, err = ListPasskeys(data.Email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		// the user handle is stored on the authenticator, so it is not the email
+		userID := sha256.Sum256([]byte(data.Email))
+		data.UserID = base64.RawURLEncoding.EncodeToString(userID[:16])
+		data.Challenge = NewWebAuthnChallenge("register:" + data.Email)
+	}
+
+	render(w, r, "html/passkeys.html", &data)
+}
+
+// PasskeyRequest is a passkey ceremony from the browser, all binary values
+// base64url encoded.
+type PasskeyRequest struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	// CSRF is the page's CSRF token, sent when registering
+	CSRF string `json:"csrf"`
+}
+
+func decodePasskeyRequest(r *http.Request) (*PasskeyRequest, map[string][]byte, bool) {
+	var req PasskeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, nil, false
+	}
+	raw := map[string][]byte{}
+	for name, val := range map[string]string{
+		"clientDataJSON":    req.ClientDataJSON,
+		"attestationObject": req.AttestationObject,
+		"authenticatorData": req.AuthenticatorData,
+		"signature":         req.Signature,
+	} {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(val, "="))
+		if err != nil {
+			return nil, nil, false
+		}
+		raw[name] = b
+	}
+	return &req, raw, true
+}
+
+// HandlePasskeyRegister stores a passkey just created by the friend's browser.
+func HandlePasskeyRegister(w http.ResponseWriter, r *http.Request) {
+	email := friendFromCookie(r)
+	if !PasskeysEnabled() || len(email) == 0 {
+		writeAPIError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	req, raw, ok := decodePasskeyRequest(r)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "bad request")
+		return
+	} else if !checkCSRFToken(r, req.CSRF) {
+		writeAPIError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if err := VerifyClientData(raw["clientDataJSON"], "webauthn.create", "register:"+email); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key, err := VerifyRegistration(raw["attestationObject"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key.Email = email
+	key.Name = strings.TrimSpace(req.Name)
+	if name := []rune(key.Name); len(name) > 100 {
+		key.Name = string(name[:100])
+	}
+	if existing, err := GetPasskey(key.ID); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	} else if existing != nil {
+		writeAPIError(w, http.StatusConflict, "passkey already registered")
+		return
+	}
+	if err = CreatePasskey(*key); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusCreated, struct{}{})
+}
+
+// HandlePasskeyLogin signs in the friend whose passkey signed the login
+// challenge.
+func HandlePasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !PasskeysEnabled() {
+		writeAPIError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	req, raw, ok := decodePasskeyRequest(r)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+	if err := VerifyClientData(raw["clientDataJSON"], "webauthn.get", "login"); err != nil {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	key, err := GetPasskey(strings.TrimRight(req.ID, "="))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	} else if key == nil {
+		writeAPIError(w, http.StatusUnauthorized, ErrWebAuthn.Error())
+		return
+	}
+	signCount, err := VerifyAssertion(*key, raw["clientDataJSON"], raw["authenticatorData"], raw["signature"])
+	if err != nil {
+		Log.Warn("passkey login failed", zap.Error(err), zap.String("email", key.Email))
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if signCount != 0 {
+		if err = UpdatePasskeySignCount(key.ID, signCount); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+	rememberFriend(w, r, key.Email)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
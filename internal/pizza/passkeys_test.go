@@ -0,0 +1,119 @@
+package pizza_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func passkeyRequest(t *testing.T, path string, req pizza.PasskeyRequest) *http.Request {
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	return httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body)))
+}
+
+func clientDataJSON(kind, challenge string) string {
+	clientData := fmt.Sprintf(`{"type":%q,"challenge":%q,"origin":"https://rsvp.pizza"}`, kind, challenge)
+	return base64.RawURLEncoding.EncodeToString([]byte(clientData))
+}
+
+func TestHandlePasskeyRegisterRefused(t *testing.T) {
+	// GIVEN a friend logged in on a site with passkeys
+	rpID, origin := pizza.WebAuthnRPID, pizza.WebAuthnOrigin
+	defer func() { pizza.WebAuthnRPID, pizza.WebAuthnOrigin = rpID, origin }()
+	pizza.WebAuthnRPID, pizza.WebAuthnOrigin = "rsvp.pizza", "https://rsvp.pizza"
+	session := pizza.NewFriendSession("believe@tedlasso.com")
+	csrf := pizza.CSRFToken(session)
+	loginChallenge := pizza.NewWebAuthnChallenge("login")
+
+	for _, tc := range []struct {
+		name     string
+		loggedIn bool
+		body     string
+		req      pizza.PasskeyRequest
+		expected int
+	}{
+		{"not logged in", false, "", pizza.PasskeyRequest{CSRF: csrf}, http.StatusForbidden},
+		{"not json", true, "{", pizza.PasskeyRequest{}, http.StatusBadRequest},
+		{"not base64", true, "", pizza.PasskeyRequest{CSRF: csrf, ClientDataJSON: "!!"}, http.StatusBadRequest},
+		{"no csrf token", true, "", pizza.PasskeyRequest{}, http.StatusForbidden},
+		{"another friend's csrf token", true, "", pizza.PasskeyRequest{CSRF: pizza.CSRFToken(pizza.NewFriendSession("roy@kent.com"))}, http.StatusForbidden},
+		{"the login challenge", true, "", pizza.PasskeyRequest{CSRF: csrf, ClientDataJSON: clientDataJSON("webauthn.create", loginChallenge)}, http.StatusBadRequest},
+	} {
+		// WHEN the browser sends a passkey it shouldn't be trusted with
+		req := passkeyRequest(t, "/passkeys/register", tc.req)
+		if len(tc.body) > 0 {
+			req = httptest.NewRequest(http.MethodPost, "/passkeys/register", strings.NewReader(tc.body))
+		}
+		if tc.loggedIn {
+			req.AddCookie(pizza.FriendSessionCookie(session))
+		}
+		w := httptest.NewRecorder()
+		pizza.HandlePasskeyRegister(w, req)
+
+		// THEN it isn't stored
+		assert.Equal(t, tc.expected, w.Code, tc.name)
+	}
+}
+
+func TestHandlePasskeysDisabled(t *testing.T) {
+	// GIVEN a site without a base URL, so passkeys are off
+	rpID := pizza.WebAuthnRPID
+	defer func() { pizza.WebAuthnRPID = rpID }()
+	pizza.WebAuthnRPID = ""
+	session := pizza.NewFriendSession("believe@tedlasso.com")
+
+	// WHEN a friend registers a passkey, or logs in with one
+	register := passkeyRequest(t, "/passkeys/register", pizza.PasskeyRequest{CSRF: pizza.CSRFToken(session)})
+	register.AddCookie(pizza.FriendSessionCookie(session))
+	registered := httptest.NewRecorder()
+	pizza.HandlePasskeyRegister(registered, register)
+	loggedIn := httptest.NewRecorder()
+	pizza.HandlePasskeyLogin(loggedIn, passkeyRequest(t, "/passkeys/login", pizza.PasskeyRequest{ID: "credential-1"}))
+
+	// THEN both are refused
+	assert.Equal(t, http.StatusForbidden, registered.Code)
+	assert.Equal(t, http.StatusForbidden, loggedIn.Code)
+}
+
+func TestHandlePasskeyLoginRefused(t *testing.T) {
+	// GIVEN a site with passkeys
+	rpID, origin := pizza.WebAuthnRPID, pizza.WebAuthnOrigin
+	defer func() { pizza.WebAuthnRPID, pizza.WebAuthnOrigin = rpID, origin }()
+	pizza.WebAuthnRPID, pizza.WebAuthnOrigin = "rsvp.pizza", "https://rsvp.pizza"
+	challenge := pizza.NewWebAuthnChallenge("login")
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		req      pizza.PasskeyRequest
+		expected int
+	}{
+		{"not json", "[]", pizza.PasskeyRequest{}, http.StatusBadRequest},
+		{"not base64", "", pizza.PasskeyRequest{Signature: "!!"}, http.StatusBadRequest},
+		{"a made up challenge", "", pizza.PasskeyRequest{ClientDataJSON: clientDataJSON("webauthn.get", "made-up")}, http.StatusUnauthorized},
+		{"a registration", "", pizza.PasskeyRequest{ClientDataJSON: clientDataJSON("webauthn.create", challenge)}, http.StatusUnauthorized},
+	} {
+		// WHEN a browser logs in with something other than a signed login
+		// challenge
+		req := passkeyRequest(t, "/passkeys/login", tc.req)
+		if len(tc.body) > 0 {
+			req = httptest.NewRequest(http.MethodPost, "/passkeys/login", strings.NewReader(tc.body))
+		}
+		w := httptest.NewRecorder()
+		pizza.HandlePasskeyLogin(w, req)
+
+		// THEN nobody is logged in
+		assert.Equal(t, tc.expected, w.Code, tc.name)
+		assert.Empty(t, w.Result().Cookies(), tc.name)
+	}
+}
@@ -0,0 +1,100 @@
+package pizza
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// DietaryOptions are the dietary restrictions friends can say guests in
+// their party have.
+var DietaryOptions = []string{"vegetarian", "vegan", "gluten-free", "dairy-free"}
+
+// RSVPPreferences are what a friend's party would like to eat: their topping
+// votes and how many of them have each dietary restriction.
+type RSVPPreferences struct {
+	Toppings []string
+	Diets    map[string]int
+}
+
+// ParsePreferences reads the topping and diet:<restriction> fields of a form
+// for a party of guests. Restrictions nobody has are left out.
+func ParsePreferences(form url.Values, guests int) (RSVPPreferences, error) {
+	prefs := RSVPPreferences{Toppings: []string{}}
+	for _, topping := range form["topping"] {
+		if len(ToppingOptions) > 0 && !containsString(ToppingOptions, topping) {
+			return prefs, ErrRSVPInvalid
+		}
+		prefs.Toppings = append(prefs.Toppings, topping)
+	}
+	for _, diet := range DietaryOptions {
+		val := form.Get("diet:" + diet)
+		if len(val) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 || n > guests {
+			return prefs, ErrRSVPInvalid
+		}
+		if n > 0 {
+			if prefs.Diets == nil {
+				prefs.Diets = make(map[string]int)
+			}
+			prefs.Diets[diet] = n
+		}
+	}
+	return prefs, nil
+}
+
+type DietCount struct {
+	Diet   string `json:"diet"`
+	Guests int    `json:"guests"`
+}
+
+// PreferenceSummary tallies what the guests coming to a party would like, for
+// ordering the pizza.
+type PreferenceSummary struct {
+	Guests   int            `json:"guests"`
+	Toppings []ToppingCount `json:"toppings"`
+	Diets    []DietCount    `json:"diets"`
+}
+
+// SummarizePreferences tallies the topping votes and dietary restrictions of
+// the confirmed RSVPs, most common first.
+func SummarizePreferences(rsvps []RSVP) PreferenceSummary {
+	rsvps = ConfirmedRSVPs(rsvps)
+	summary := PreferenceSummary{Guests: Headcount(rsvps), Toppings: ToppingStandings(rsvps), Diets: []DietCount{}}
+	diets := make(map[string]int)
+	for _, rsvp := range rsvps {
+		for diet, n := range rsvp.Diets {
+			diets[diet] += n
+		}
+	}
+	for diet, n := range diets {
+		summary.Diets = append(summary.Diets, DietCount{diet, n})
+	}
+	sort.Slice(summary.Diets, func(i, j int) bool {
+		if summary.Diets[i].Guests != summary.Diets[j].Guests {
+			return summary.Diets[i].Guests > summary.Diets[j].Guests
+		}
+		return summary.Diets[i].Diet < summary.Diets[j].Diet
+	})
+	return summary
+}
+
+// HandleAPIPreferences serves the topping votes and dietary restrictions of
+// the guests coming to a Friday.
+func HandleAPIPreferences(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Log.Error("failed to list rsvps", zap.Error(err), zap.String("eventID", id))
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, SummarizePreferences(rsvps))
+}
@@ -0,0 +1,46 @@
+package pizza_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePreferences(t *testing.T) {
+	// GIVEN
+	form := url.Values{"topping": {"mushroom"}, "diet:vegetarian": {"2"}, "diet:vegan": {"0"}}
+
+	// WHEN
+	prefs, err := pizza.ParsePreferences(form, 3)
+
+	// THEN restrictions nobody has are left out
+	require.Nil(t, err)
+	assert.Equal(t, []string{"mushroom"}, prefs.Toppings)
+	assert.Equal(t, map[string]int{"vegetarian": 2}, prefs.Diets)
+
+	// THEN a party can't have more restricted guests than guests
+	_, err = pizza.ParsePreferences(url.Values{"diet:vegan": {"4"}}, 3)
+	assert.Equal(t, pizza.ErrRSVPInvalid, err)
+	_, err = pizza.ParsePreferences(url.Values{"diet:vegan": {"-1"}}, 3)
+	assert.Equal(t, pizza.ErrRSVPInvalid, err)
+}
+
+func TestSummarizePreferences(t *testing.T) {
+	// GIVEN
+	rsvps := []pizza.RSVP{
+		{PlusOnes: 1, Toppings: []string{"mushroom", "onion"}, Diets: map[string]int{"vegetarian": 2}},
+		{Toppings: []string{"mushroom"}, Diets: map[string]int{"vegetarian": 1, "gluten-free": 1}},
+		{Status: pizza.RSVPStatusPending, Toppings: []string{"onion"}, Diets: map[string]int{"vegan": 1}},
+	}
+
+	// WHEN
+	summary := pizza.SummarizePreferences(rsvps)
+
+	// THEN only guests who are coming count
+	assert.Equal(t, 3, summary.Guests)
+	assert.Equal(t, []pizza.ToppingCount{{Topping: "mushroom", Votes: 2}, {Topping: "onion", Votes: 1}}, summary.Toppings)
+	assert.Equal(t, []pizza.DietCount{{Diet: "vegetarian", Guests: 3}, {Diet: "gluten-free", Guests: 1}}, summary.Diets)
+}
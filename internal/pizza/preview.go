@@ -0,0 +1,67 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// PreviewBotAgents are parts of the user agents of the bots that unfurl links
+// pasted into chats and social media.
+var PreviewBotAgents = []string{
+	"bot",
+	"crawler",
+	"spider",
+	"facebookexternalhit",
+	"whatsapp",
+	"skypeuripreview",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"preview",
+}
+
+// IsPreviewBot reports whether the user agent is a link preview bot.
+func IsPreviewBot(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, agent := range PreviewBotAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+type PreviewPageData struct {
+	Title       string
+	Description string
+}
+
+// previewBots shows link preview bots a page without any attendee data for
+// signed links, so pasting a personal link into a chat doesn't share the
+// guest list with the chat's servers.
+func previewBots(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !r.URL.Query().Has("sig") || !IsPreviewBot(r.UserAgent()) {
+			next(w, r)
+			return
+		}
+		plate, err := parseTemplate("html/preview.html")
+		if err != nil {
+			Log.Error("template preview failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+		w.Header().Set("X-Robots-Tag", "noindex")
+		data := PreviewPageData{
+			Title:       "Pizza Friday",
+			Description: "A personal link to Pizza Friday. Open it to see the details.",
+		}
+		if err = executeTemplate(w, plate, data); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+			return
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPreviewBot(t *testing.T) {
+	// GIVEN
+	bots := []string{
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+		"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+		"facebookexternalhit/1.1",
+		"WhatsApp/2.23.20.0",
+		"TelegramBot (like TwitterBot)",
+	}
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0"
+
+	// WHEN / THEN
+	for _, bot := range bots {
+		assert.True(t, pizza.IsPreviewBot(bot), bot)
+	}
+	assert.False(t, pizza.IsPreviewBot(browser))
+}
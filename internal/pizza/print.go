@@ -0,0 +1,112 @@
+package pizza
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type PrintGuestData struct {
+	Name     string
+	Email    string
+	PlusOnes int
+	Kids     int
+	// Table is the guest's table in the seating plan, or 0 when unseated
+	Table int
+	// Days are the days of a multi-day event the friend is coming, or empty
+	// for every day
+	Days    []string
+	Note    string
+	Answers []EditAnswerData
+}
+
+type AdminPrintPageData struct {
+	Date      string
+	Headcount int
+	Count     GuestCount
+	Days      []DayHeadcount
+	// Guests are the confirmed guests in alphabetical order
+	Guests   []PrintGuestData
+	Drinks   []DrinkTally
+	Toppings []ToppingCount
+}
+
+// HandleAdminPrint renders a sheet for the host to print on the night, with a
+// checklist of the guests, the host's notes and their answers, what guests are
+// bringing, and the pizza order.
+func HandleAdminPrint(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/print.html")
+	if err != nil {
+		Log.Error("template admin print failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	confirmed := ConfirmedRSVPs(rsvps)
+	data := AdminPrintPageData{
+		Date:      FormatTime(friday.Start),
+		Headcount: Headcount(rsvps),
+		Count:     CountGuests(rsvps),
+		Toppings:  ToppingStandings(confirmed),
+	}
+	days := friday.Days()
+	if len(days) > 1 {
+		data.Days = DayHeadcounts(*friday, rsvps)
+	}
+
+	tables := map[string]int{}
+	seated, _ := SeatRSVPs(friday.Tables, rsvps)
+	for i, table := range seated {
+		for _, rsvp := range table {
+			tables[rsvp.Email] = i + 1
+		}
+	}
+	names := map[string]string{}
+	for _, rsvp := range confirmed {
+		guest := PrintGuestData{Email: rsvp.Email, PlusOnes: rsvp.PlusOnes, Kids: rsvp.Kids, Table: tables[rsvp.Email]}
+		guest.Name, _ = GetCachedFriendName(rsvp.Email)
+		names[rsvp.Email] = guest.Name
+		for _, day := range days {
+			if len(rsvp.Days) > 0 && rsvp.Attends(day.Date) {
+				guest.Days = append(guest.Days, day.Label)
+			}
+		}
+		if guest.Note, err = GetFriendNote(rsvp.Email); err != nil {
+			Handle500(w, r)
+			return
+		}
+		for _, question := range friday.Questions {
+			if answer := rsvp.Answers[question]; len(answer) > 0 {
+				guest.Answers = append(guest.Answers, EditAnswerData{question, answer})
+			}
+		}
+		data.Guests = append(data.Guests, guest)
+	}
+	sort.Slice(data.Guests, func(i, j int) bool {
+		return strings.ToLower(data.Guests[i].Name+data.Guests[i].Email) < strings.ToLower(data.Guests[j].Name+data.Guests[j].Email)
+	})
+	data.Drinks = TallyDrinks(*friday, rsvps, names, "")
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,50 @@
+package pizza_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestAdminPrintSheet(t *testing.T) {
+	// GIVEN a party with a seated guest the host has a note about, and one
+	// who hasn't a name yet
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/admin/print.html")
+	require.NoError(t, err)
+	data := pizza.AdminPrintPageData{
+		Date:      "Fri Apr 7, 5:30 PM",
+		Headcount: 4,
+		Count:     pizza.GuestCount{Adults: 3, Kids: 1},
+		Guests: []pizza.PrintGuestData{
+			{Name: "Ted Lasso", Email: "believe@tedlasso.com", PlusOnes: 1, Kids: 1, Table: 2, Note: "allergic to shellfish",
+				Answers: []pizza.EditAnswerData{{Question: "Bringing anything?", Answer: "biscuits"}}},
+			{Email: "roy@kent.com"},
+		},
+		Drinks:   []pizza.DrinkTally{{Category: "beer", Count: 6, Bringers: []string{"Ted Lasso"}}},
+		Toppings: []pizza.ToppingCount{{Topping: "pepperoni", Votes: 2}},
+	}
+
+	// WHEN
+	var page bytes.Buffer
+	err = pizza.ExecuteTemplate(&page, plate, data)
+
+	// THEN there is a box to tick for each guest, with what the host needs
+	// to know about them, and the order
+	require.NoError(t, err)
+	sheet := page.String()
+	assert.Equal(t, 2, strings.Count(sheet, "<td>&#9744;</td>"))
+	assert.Contains(t, sheet, "<td>Ted Lasso +1 (kids: 1)</td>")
+	assert.Contains(t, sheet, "<td>Table 2</td>")
+	assert.Contains(t, sheet, "<td>allergic to shellfish<br>Bringing anything? biscuits</td>")
+	assert.Contains(t, sheet, "<td>roy@kent.com</td>")
+	assert.Contains(t, sheet, fmt.Sprintf("4 coming (adults: 3, kids: 1). Order about %d pizzas.", data.Count.Pizzas()))
+	assert.Contains(t, sheet, "Toppings: pepperoni (2)")
+	assert.Contains(t, sheet, "beer: 6 from Ted Lasso")
+}
@@ -0,0 +1,113 @@
+package pizza
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProbeResult is the outcome of one synthetic RSVP.
+type ProbeResult struct {
+	Success  bool
+	Duration time.Duration
+	Time     time.Time
+	Error    string
+}
+
+var (
+	probeMu          sync.Mutex
+	probeLast        ProbeResult
+	probeLastSuccess time.Time
+	probeRuns        int
+	probeFailures    int
+)
+
+// RunProbe RSVPs the probe friend to the sandbox Friday, reads the RSVP back,
+// and deletes it, the same way a friend's RSVP is saved.
+func RunProbe(fridayID, email string) ProbeResult {
+	start := time.Now()
+	err := runProbe(fridayID, email)
+	result := ProbeResult{Success: err == nil, Duration: time.Since(start), Time: start}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	probeLast = result
+	probeRuns++
+	if result.Success {
+		probeLastSuccess = result.Time
+	} else {
+		probeFailures++
+	}
+	return result
+}
+
+func runProbe(fridayID, email string) error {
+	friday, err := GetFriday(fridayID)
+	if err != nil {
+		return err
+	} else if friday == nil {
+		return fmt.Errorf("sandbox friday %s not found", fridayID)
+	}
+	rsvp, err := RSVPToFriday(email, *friday, RSVPOptions{})
+	if len(rsvp.ID) > 0 {
+		defer func() {
+			if err := DeleteFridayRSVP(rsvp.ID); err != nil {
+				Log.Warn("probe failed to clean up rsvp", zap.Error(err), zap.String("id", rsvp.ID))
+			}
+		}()
+	}
+	if err != nil {
+		return err
+	}
+	saved, err := GetFridayRSVP(rsvp.ID)
+	if err != nil {
+		return err
+	} else if saved == nil || saved.Email != email || saved.FridayID != fridayID {
+		return errors.New("probe rsvp was not saved")
+	}
+	return nil
+}
+
+// WatchProbe runs the probe until ctx is done, logging failures.
+func WatchProbe(ctx context.Context, config ProbeConfig) {
+	for {
+		if result := RunProbe(config.FridayID, config.Email); !result.Success {
+			Log.Warn("synthetic rsvp probe failed", zap.String("error", result.Error), zap.Duration("duration", result.Duration))
+		}
+		if !sleepCtx(ctx, config.Every) {
+			return
+		}
+	}
+}
+
+// HandleMetrics reports the probe's results and the calendar queue in the
+// Prometheus text format.
+func HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	probeMu.Lock()
+	last, lastSuccess, runs, failures := probeLast, probeLastSuccess, probeRuns, probeFailures
+	probeMu.Unlock()
+
+	success := 0
+	if last.Success {
+		success = 1
+	}
+	var lastSuccessTS int64
+	if !lastSuccess.IsZero() {
+		lastSuccessTS = lastSuccess.Unix()
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP pizza_probe_success Whether the last synthetic RSVP worked.\n# TYPE pizza_probe_success gauge\npizza_probe_success %d\n", success)
+	fmt.Fprintf(w, "# HELP pizza_probe_duration_seconds How long the last synthetic RSVP took.\n# TYPE pizza_probe_duration_seconds gauge\npizza_probe_duration_seconds %g\n", last.Duration.Seconds())
+	fmt.Fprintf(w, "# HELP pizza_probe_last_success_timestamp_seconds When a synthetic RSVP last worked.\n# TYPE pizza_probe_last_success_timestamp_seconds gauge\npizza_probe_last_success_timestamp_seconds %d\n", lastSuccessTS)
+	fmt.Fprintf(w, "# HELP pizza_probe_runs_total Synthetic RSVPs tried.\n# TYPE pizza_probe_runs_total counter\npizza_probe_runs_total %d\n", runs)
+	fmt.Fprintf(w, "# HELP pizza_probe_failures_total Synthetic RSVPs that failed.\n# TYPE pizza_probe_failures_total counter\npizza_probe_failures_total %d\n", failures)
+	writeCalendarMetrics(w)
+}
@@ -0,0 +1,29 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	// GIVEN
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	// WHEN
+	pizza.HandleMetrics(w, r)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE pizza_probe_success gauge\npizza_probe_success 0\n")
+	assert.Contains(t, body, "# TYPE pizza_probe_runs_total counter\npizza_probe_runs_total 0\n")
+	assert.Contains(t, body, "pizza_probe_failures_total 0\n")
+	assert.Contains(t, body, "pizza_probe_duration_seconds 0\n")
+	assert.Contains(t, body, "pizza_probe_last_success_timestamp_seconds 0\n")
+}
@@ -0,0 +1,35 @@
+package pizza
+
+// Slices each guest eats and slices in a pizza, for working out how many
+// pizzas to order. Kids eat less pizza than adults.
+var (
+	SlicesPerAdult = 3
+	SlicesPerKid   = 2
+	SlicesPerPizza = 8
+)
+
+// GuestCount splits the confirmed guests into adults and kids.
+type GuestCount struct {
+	Adults int `json:"adults"`
+	Kids   int `json:"kids"`
+}
+
+// CountGuests counts the adults, friends and their plus ones, and the kids
+// coming with the confirmed RSVPs.
+func CountGuests(rsvps []RSVP) GuestCount {
+	count := GuestCount{}
+	for _, rsvp := range ConfirmedRSVPs(rsvps) {
+		count.Adults += 1 + rsvp.PlusOnes
+		count.Kids += rsvp.Kids
+	}
+	return count
+}
+
+// Pizzas is how many pizzas to order for the guests, rounded up.
+func (c GuestCount) Pizzas() int {
+	if SlicesPerPizza <= 0 {
+		return 0
+	}
+	slices := c.Adults*SlicesPerAdult + c.Kids*SlicesPerKid
+	return (slices + SlicesPerPizza - 1) / SlicesPerPizza
+}
@@ -0,0 +1,97 @@
+package pizza
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReactionEmoji are the reactions friends can leave on a Friday.
+var ReactionEmoji = []string{"🍕", "🎉", "👎"}
+
+type ReactionCount struct {
+	Emoji string
+	Count int
+	// Mine is set when this is the friend's own reaction
+	Mine bool
+}
+
+var reactionsCache *Cache[[]Reaction]
+
+func init() {
+	c := NewCache(time.Minute, ListReactions)
+	reactionsCache = &c
+}
+
+// CountReactions tallies the reactions for each emoji in ReactionEmoji.
+func CountReactions(reactions []Reaction, email string) []ReactionCount {
+	counts := make([]ReactionCount, len(ReactionEmoji))
+	for i, emoji := range ReactionEmoji {
+		counts[i].Emoji = emoji
+		for _, reaction := range reactions {
+			if reaction.Emoji == emoji {
+				counts[i].Count++
+				counts[i].Mine = counts[i].Mine || (len(email) > 0 && reaction.Email == email)
+			}
+		}
+	}
+	return counts
+}
+
+func isReactionEmoji(emoji string) bool {
+	for _, e := range ReactionEmoji {
+		if e == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleReact sets the reaction of the friend in the cookie to a Friday, or
+// removes it when they pick the same emoji again.
+func HandleReact(w http.ResponseWriter, r *http.Request) {
+	email := friendFromCookie(r)
+	if err := r.ParseForm(); err != nil || len(email) == 0 {
+		Handle4xx(w, r)
+		return
+	}
+	fridayID, emoji, _ := strings.Cut(r.PostForm.Get("react"), ":")
+	if !isReactionEmoji(emoji) {
+		Handle4xx(w, r)
+		return
+	}
+	if _, ok, err := GetCachedFriday(UpcomingDays, fridayID); err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		Handle500(w, r)
+		return
+	} else if !ok {
+		Handle4xx(w, r)
+		return
+	}
+	reactions, err := reactionsCache.Get(fridayID)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+
+	reaction := Reaction{Email: email, FridayID: fridayID, Emoji: emoji}
+	updated := []Reaction{}
+	for _, existing := range reactions {
+		if existing.Email != email {
+			updated = append(updated, existing)
+		} else if existing.Emoji == emoji {
+			reaction.Emoji = ""
+		}
+	}
+	if err = SetReaction(reaction); err != nil {
+		Handle500(w, r)
+		return
+	}
+	if len(reaction.Emoji) > 0 {
+		updated = append(updated, reaction)
+	}
+	reactionsCache.Store(fridayID, updated)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,29 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountReactions(t *testing.T) {
+	// GIVEN
+	reactions := []pizza.Reaction{
+		{Email: "believe@tedlasso.com", FridayID: "1", Emoji: "🍕"},
+		{Email: "coach@tedlasso.com", FridayID: "1", Emoji: "🍕"},
+		{Email: "roy@tedlasso.com", FridayID: "1", Emoji: "👎"},
+	}
+
+	// WHEN
+	counts := pizza.CountReactions(reactions, "roy@tedlasso.com")
+	anonymous := pizza.CountReactions(reactions, "")
+
+	// THEN
+	assert.Equal(t, []pizza.ReactionCount{
+		{Emoji: "🍕", Count: 2},
+		{Emoji: "🎉", Count: 0},
+		{Emoji: "👎", Count: 1, Mine: true},
+	}, counts)
+	assert.False(t, anonymous[2].Mine)
+}
@@ -0,0 +1,86 @@
+package pizza
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// RecapURL is the share link for a Friday's recap. Private recaps can only be
+// opened with the signature.
+func RecapURL(friday Friday) string {
+	return fmt.Sprintf("/recap/%s?sig=%s", friday.ID(), url.QueryEscape(SignLink("recap", friday.ID())))
+}
+
+type RecapPageData struct {
+	Date      string
+	Headcount int
+	Guests    []string
+	Toppings  []ToppingCount
+	Photos    []string
+	Images    []Image
+	ShareURL  string
+}
+
+// BuildRecap summarizes a Friday that has ended from its RSVPs.
+func BuildRecap(friday Friday, rsvps []RSVP, names map[string]string) RecapPageData {
+	data := RecapPageData{
+		Date:     FormatTime(friday.Start),
+		Toppings: ToppingStandings(rsvps),
+		Photos:   friday.Photos,
+		Images:   friday.Images,
+		ShareURL: BaseURL + RecapURL(friday),
+	}
+	for _, rsvp := range rsvps {
+		data.Headcount += rsvp.Guests()
+		if name, ok := names[rsvp.Email]; ok && len(name) > 0 {
+			data.Guests = append(data.Guests, name)
+		}
+	}
+	return data
+}
+
+func HandleRecap(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/recap.html")
+	if err != nil {
+		Log.Error("template recap failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil || !friday.IsOver() {
+		Handle4xx(w, r)
+		return
+	}
+	if !friday.RecapPublic && !VerifyLink(r.URL.Query().Get("sig"), "recap", id) {
+		Handle4xx(w, r)
+		return
+	}
+
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Log.Error("failed to list rsvps", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	}
+	names := make(map[string]string)
+	for _, rsvp := range rsvps {
+		if name, err := GetCachedFriendName(rsvp.Email); err == nil {
+			names[rsvp.Email] = name
+		}
+	}
+
+	if err = executeTemplate(w, plate, BuildRecap(*friday, ConfirmedRSVPs(rsvps), names)); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
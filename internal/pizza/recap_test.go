@@ -0,0 +1,52 @@
+package pizza_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRecap(t *testing.T) {
+	// GIVEN
+	pizza.BaseURL = "https://rsvp.pizza"
+	friday := pizza.Friday{
+		Start:  time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC),
+		Photos: []string{"https://rsvp.pizza/photos/1.jpg"},
+	}
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 1, Toppings: []string{"pepperoni"}},
+		{Email: "coach@tedlasso.com", Toppings: []string{"pepperoni", "mushroom"}},
+	}
+	names := map[string]string{"believe@tedlasso.com": "Ted Lasso"}
+
+	// WHEN
+	recap := pizza.BuildRecap(friday, rsvps, names)
+
+	// THEN
+	assert.Equal(t, 3, recap.Headcount)
+	assert.Equal(t, []string{"Ted Lasso"}, recap.Guests)
+	assert.Equal(t, "pepperoni", recap.Toppings[0].Topping)
+	assert.Equal(t, friday.Photos, recap.Photos)
+	assert.True(t, strings.HasPrefix(recap.ShareURL, "https://rsvp.pizza/recap/1680903000?sig="))
+}
+
+func TestRecapEscapesNames(t *testing.T) {
+	// GIVEN a friend who joined with markup for a name
+	pizza.StaticDir = "../../static"
+	plate, err := pizza.ParseTemplate("html/recap.html")
+	assert.NoError(t, err)
+	data := pizza.RecapPageData{Date: "Fri Apr 7, 5:30 PM", Headcount: 1, Guests: []string{`<script>alert("pizza")</script>`}}
+
+	// WHEN
+	var page bytes.Buffer
+	err = pizza.ExecuteTemplate(&page, plate, data)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.NotContains(t, page.String(), "<script>alert")
+	assert.Contains(t, page.String(), "&lt;script&gt;alert(&#34;pizza&#34;)&lt;/script&gt;")
+}
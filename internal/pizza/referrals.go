@@ -0,0 +1,260 @@
+package pizza
+
+import (
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReferralsEnabled lets friends share a link that adds newcomers to the
+// friends.
+var ReferralsEnabled = false
+
+// ReferralPlusOnes are extra plus ones for friends who referred a newcomer.
+var ReferralPlusOnes = 0
+
+// MaxNameLength is the longest name a newcomer may give.
+const MaxNameLength = 100
+
+var referralCache *Cache[int]
+
+func init() {
+	c := NewCache(10*time.Minute, CountReferrals)
+	referralCache = &c
+}
+
+// ReferralURL is the friend's link for bringing newcomers. It doesn't expire,
+// so it can be shared in a group chat.
+func ReferralURL(email string) string {
+	q := url.Values{}
+	q.Set("by", email)
+	q.Set("sig", SignLink("refer", email))
+	return BaseURL + "/join?" + q.Encode()
+}
+
+// MaxPlusOnesFor is how many friends the friend may bring, counting the extra
+// plus ones for having referred a newcomer.
+func MaxPlusOnesFor(email string) int {
+	maxPlusOnes := CurrentSettings().MaxPlusOnes
+	if ReferralPlusOnes <= 0 {
+		return maxPlusOnes
+	}
+	referrals, err := referralCache.Get(email)
+	if err != nil {
+		Log.Warn("failed to count referrals", zap.Error(err), zap.String("email", email))
+		return maxPlusOnes
+	}
+	if referrals > 0 {
+		return maxPlusOnes + ReferralPlusOnes
+	}
+	return maxPlusOnes
+}
+
+// ReferrerStats are the newcomers one friend referred.
+type ReferrerStats struct {
+	Email     string
+	Newcomers []ReferredFriend
+}
+
+// SummarizeReferrals groups the newcomers by who referred them, the friends
+// with the most referrals first and their newest newcomers first.
+func SummarizeReferrals(friends []ReferredFriend) []ReferrerStats {
+	byReferrer := map[string]*ReferrerStats{}
+	for _, friend := range friends {
+		stats, ok := byReferrer[friend.ReferredBy]
+		if !ok {
+			stats = &ReferrerStats{Email: friend.ReferredBy}
+			byReferrer[friend.ReferredBy] = stats
+		}
+		stats.Newcomers = append(stats.Newcomers, friend)
+	}
+
+	referrers := make([]ReferrerStats, 0, len(byReferrer))
+	for _, stats := range byReferrer {
+		sort.Slice(stats.Newcomers, func(i, j int) bool {
+			return stats.Newcomers[i].JoinedAt.After(stats.Newcomers[j].JoinedAt)
+		})
+		referrers = append(referrers, *stats)
+	}
+	sort.Slice(referrers, func(i, j int) bool {
+		if len(referrers[i].Newcomers) != len(referrers[j].Newcomers) {
+			return len(referrers[i].Newcomers) > len(referrers[j].Newcomers)
+		}
+		return referrers[i].Email < referrers[j].Email
+	})
+	return referrers
+}
+
+type ReferPageData struct {
+	Email         string
+	Enabled       bool
+	URL           string
+	Referrals     int
+	BonusPlusOnes int
+}
+
+// HandleRefer shows the friend remembered by the browser their referral link
+// and how many newcomers joined through it.
+func HandleRefer(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/refer.html")
+	if err != nil {
+		Log.Error("template refer failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	data := ReferPageData{Email: friendFromCookie(r), Enabled: ReferralsEnabled, BonusPlusOnes: ReferralPlusOnes}
+	if len(data.Email) > 0 && ReferralsEnabled {
+		data.URL = ReferralURL(data.Email)
+		if data.Referrals, err = CountReferrals(data.Email); err != nil {
+			Handle500(w, r)
+			return
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type JoinPageData struct {
+	By        string
+	Sig       string
+	Referrer  string
+	Name      string
+	Email     string
+	Error     string
+	Sent      bool
+	FormToken string
+	Captcha   *CaptchaWidget
+}
+
+// HandleJoin adds a newcomer to the friends through a friend's referral link
+// and emails them an invite link. Like requesting an invite, the page looks
+// the same whether or not the email already belongs to a friend.
+func HandleJoin(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/join.html")
+	if err != nil {
+		Log.Error("template join failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := JoinPageData{
+		By:        strings.ToLower(r.Form.Get("by")),
+		Sig:       r.Form.Get("sig"),
+		FormToken: FormToken(),
+		Captcha:   CaptchaWidgetFor(),
+	}
+	if !ReferralsEnabled || !VerifyLink(data.Sig, "refer", data.By) {
+		Handle4xx(w, r)
+		return
+	}
+	// the referrer may have been removed since sharing their link
+	if allowed, err := IsFriendAllowed(data.By); err != nil {
+		Handle500(w, r)
+		return
+	} else if !allowed {
+		Handle4xx(w, r)
+		return
+	}
+	data.Referrer, _ = GetCachedFriendName(data.By)
+
+	if r.Method == http.MethodPost {
+		if !CheckCaptcha(r, r.PostForm) {
+			Handle4xx(w, r)
+			return
+		}
+		data.Name = strings.TrimSpace(r.PostForm.Get("name"))
+		data.Email = strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+		if addr, err := mail.ParseAddress(data.Email); err != nil || addr.Address != data.Email {
+			data.Error = "That doesn't look like an email address."
+		} else if len(data.Name) == 0 || len(data.Name) > MaxNameLength {
+			data.Error = "Please tell us your name."
+		} else if reason := CheckSpam(r.PostForm); len(reason) > 0 {
+			Log.Info("join request looks like spam", zap.String("reason", reason), zap.String("email", data.Email))
+			data.Sent = true
+		} else if allowed, err := IsFriendAllowed(data.Email); err != nil {
+			Log.Error("error checking email for join request", zap.Error(err))
+			Handle500(w, r)
+			return
+		} else if allowed {
+			data.Sent = true
+			sendInvite(data.Email)
+		} else if err = AddReferredFriend(data.Name, data.Email, data.By); err != nil {
+			Handle500(w, r)
+			return
+		} else {
+			Log.Info("newcomer joined", zap.String("email", data.Email), zap.String("referrer", data.By))
+			referralCache.Delete(data.By)
+			data.Sent = true
+			sendInvite(data.Email)
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminReferrerData struct {
+	Name      string
+	Email     string
+	Newcomers []AdminNewcomerData
+}
+
+type AdminNewcomerData struct {
+	Name   string
+	Email  string
+	Joined string
+}
+
+type AdminReferralsPageData struct {
+	Referrers     []AdminReferrerData
+	BonusPlusOnes int
+}
+
+// HandleAdminReferrals shows which friends referred which newcomers.
+func HandleAdminReferrals(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/referrals.html")
+	if err != nil {
+		Log.Error("template admin referrals failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	friends, err := ListReferredFriends()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminReferralsPageData{BonusPlusOnes: ReferralPlusOnes}
+	for _, stats := range SummarizeReferrals(friends) {
+		referrer := AdminReferrerData{Email: stats.Email}
+		referrer.Name, _ = GetCachedFriendName(stats.Email)
+		for _, newcomer := range stats.Newcomers {
+			referrer.Newcomers = append(referrer.Newcomers, AdminNewcomerData{
+				Name:   newcomer.Name,
+				Email:  newcomer.Email,
+				Joined: FormatTime(newcomer.JoinedAt),
+			})
+		}
+		data.Referrers = append(data.Referrers, referrer)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,61 @@
+package pizza_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferralURL(t *testing.T) {
+	// GIVEN
+	defer func(base string) { pizza.BaseURL = base }(pizza.BaseURL)
+	pizza.BaseURL = "https://rsvp.pizza"
+
+	// WHEN
+	link, err := url.Parse(pizza.ReferralURL("believe@tedlasso.com"))
+
+	// THEN the link is signed for the referrer
+	require.NoError(t, err)
+	assert.Equal(t, "/join", link.Path)
+	assert.Equal(t, "believe@tedlasso.com", link.Query().Get("by"))
+	assert.True(t, pizza.VerifyLink(link.Query().Get("sig"), "refer", "believe@tedlasso.com"))
+	assert.False(t, pizza.VerifyLink(link.Query().Get("sig"), "refer", "roy@kent.com"))
+}
+
+func TestMaxPlusOnesForWithoutBonus(t *testing.T) {
+	// GIVEN no extra plus ones, so referrals aren't looked up
+	defer func(bonus int) { pizza.ReferralPlusOnes = bonus }(pizza.ReferralPlusOnes)
+	pizza.ReferralPlusOnes = 0
+
+	// WHEN
+	max := pizza.MaxPlusOnesFor("believe@tedlasso.com")
+
+	// THEN
+	assert.Equal(t, pizza.CurrentSettings().MaxPlusOnes, max)
+}
+
+func TestSummarizeReferrals(t *testing.T) {
+	// GIVEN
+	day := time.Date(2023, 4, 7, 18, 0, 0, 0, time.UTC)
+	friends := []pizza.ReferredFriend{
+		{Name: "Roy", Email: "roy@kent.com", ReferredBy: "keeley@jones.com", JoinedAt: day},
+		{Name: "Jamie", Email: "jamie@tartt.com", ReferredBy: "believe@tedlasso.com", JoinedAt: day},
+		{Name: "Sam", Email: "sam@obisanya.com", ReferredBy: "believe@tedlasso.com", JoinedAt: day.AddDate(0, 0, 1)},
+	}
+
+	// WHEN
+	referrers := pizza.SummarizeReferrals(friends)
+
+	// THEN the friend with the most referrals comes first, newest newcomer first
+	require.Len(t, referrers, 2)
+	assert.Equal(t, "believe@tedlasso.com", referrers[0].Email)
+	require.Len(t, referrers[0].Newcomers, 2)
+	assert.Equal(t, "sam@obisanya.com", referrers[0].Newcomers[0].Email)
+	assert.Equal(t, "jamie@tartt.com", referrers[0].Newcomers[1].Email)
+	assert.Equal(t, "keeley@jones.com", referrers[1].Email)
+	assert.Empty(t, pizza.SummarizeReferrals(nil))
+}
@@ -0,0 +1,347 @@
+package pizza
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The channels a reminder can go out on. Matrix reminders are posted once to
+// the room rather than to each guest.
+const (
+	ReminderEmail  = "email"
+	ReminderSMS    = "sms"
+	ReminderMatrix = "matrix"
+)
+
+var ReminderChannels = []string{ReminderEmail, ReminderSMS, ReminderMatrix}
+
+// DefaultReminders are sent before every party until the host changes the
+// schedule.
+var DefaultReminders = []Reminder{
+	{Before: 7 * 24 * time.Hour, Channels: []string{ReminderEmail}},
+	{Before: 24 * time.Hour, Channels: []string{ReminderEmail}},
+	{Before: 2 * time.Hour, Channels: []string{ReminderEmail, ReminderSMS}},
+}
+
+var ErrInvalidReminder = errors.New("invalid reminder")
+
+// Reminder reminds the guests of a party Before it starts on each of its
+// Channels.
+type Reminder struct {
+	Before   time.Duration `fauna:"before"`
+	Channels []string      `fauna:"channels"`
+}
+
+// ID names the reminder, and is what remembers it was sent for a party.
+func (r Reminder) ID() string {
+	return "before-" + r.Before.String()
+}
+
+// Lead is how long before the party the reminder is sent, like "7 days".
+func (r Reminder) Lead() string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return strconv.Itoa(n) + " " + unit + "s"
+	}
+	switch {
+	case r.Before%(24*time.Hour) == 0:
+		return plural(int(r.Before/(24*time.Hour)), "day")
+	case r.Before%time.Hour == 0:
+		return plural(int(r.Before/time.Hour), "hour")
+	}
+	return plural(int(r.Before/time.Minute), "minute")
+}
+
+func (r Reminder) String() string {
+	return r.Lead() + " before by " + strings.Join(r.Channels, " and ")
+}
+
+// ParseReminder reads a reminder from the admin form, where before is a
+// duration like 2h or a number of days like 7d.
+func ParseReminder(before string, channels []string) (Reminder, error) {
+	before = strings.TrimSpace(before)
+	var d time.Duration
+	if strings.HasSuffix(before, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(before, "d"))
+		if err != nil {
+			return Reminder{}, ErrInvalidReminder
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(before); err != nil {
+			return Reminder{}, ErrInvalidReminder
+		}
+	}
+	if d < time.Minute || len(channels) == 0 {
+		return Reminder{}, ErrInvalidReminder
+	}
+	for _, channel := range channels {
+		if !containsString(ReminderChannels, channel) {
+			return Reminder{}, ErrInvalidReminder
+		}
+	}
+	return Reminder{Before: d.Truncate(time.Minute), Channels: channels}, nil
+}
+
+// FridayReminders are the party's own reminders, or the schedule when it has
+// none.
+func FridayReminders(schedule []Reminder, friday Friday) []Reminder {
+	if len(friday.Reminders) > 0 {
+		return friday.Reminders
+	}
+	return schedule
+}
+
+// DueReminders are the reminders whose time has come for a party at now,
+// leaving out those already sent, longest before first. Only the last is
+// worth sending: the others were missed, like the 7 day reminder for a party
+// added 3 days before, and are only marked sent.
+func DueReminders(reminders []Reminder, friday Friday, now time.Time, sent map[string]bool) []Reminder {
+	due := []Reminder{}
+	if !now.Before(friday.Start) {
+		return due
+	}
+	for _, reminder := range reminders {
+		if !sent[reminder.ID()+":"+friday.ID()] && !now.Before(friday.Start.Add(-reminder.Before)) {
+			due = append(due, reminder)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Before > due[j].Before })
+	return due
+}
+
+type ReminderEmailData struct {
+	Name         string
+	Date         string
+	Lead         string
+	Announcement string
+	EditURL      string
+	// ArrivalURL is set for reminders on the day of the party
+	ArrivalURL string
+}
+
+// CheckReminders sends the party's reminder that is due, if any. It runs on
+// the notifications schedule.
+func CheckReminders(friday Friday) {
+	schedule, sent, err := GetReminderSchedule()
+	if err != nil {
+		return
+	}
+	due := DueReminders(FridayReminders(schedule, friday), friday, time.Now(), sent)
+	if len(due) == 0 {
+		return
+	}
+	keys := []string{}
+	for _, reminder := range due {
+		keys = append(keys, reminder.ID()+":"+friday.ID())
+	}
+	// mark them first so a failed reminder isn't sent again every time
+	if err = MarkRemindersSent(keys); err != nil {
+		return
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return
+	}
+	sendReminder(friday, due[len(due)-1], ConfirmedRSVPs(rsvps))
+}
+
+func sendReminder(friday Friday, reminder Reminder, rsvps []RSVP) {
+	lead := reminder.Lead()
+	announcement := MarkdownText(FillAnnouncement(friday.Announcement, AnnouncementVars(friday, Headcount(rsvps))))
+	for _, channel := range reminder.Channels {
+		switch channel {
+		case ReminderEmail:
+			for _, rsvp := range rsvps {
+				name, _ := GetCachedFriendName(rsvp.Email)
+				editURL := BaseURL + EditRSVPURL(rsvp)
+				arrivalURL := ""
+				if friday.ArrivalOpen(time.Now()) {
+					arrivalURL = BaseURL + ArrivalURL(rsvp)
+				}
+				trackEmail(rsvp.Email, "reminder", &editURL, &arrivalURL)
+				msg, err := RenderEmail("reminder", ReminderEmailData{
+					Name:         name,
+					Date:         FormatTime(friday.Start),
+					Lead:         lead,
+					Announcement: announcement,
+					EditURL:      editURL,
+					ArrivalURL:   arrivalURL,
+				})
+				if err != nil {
+					Log.Error("reminder template failure", zap.Error(err))
+					return
+				}
+				msg.To = rsvp.Email
+				if err = SendEmail(msg); err != nil {
+					Log.Warn("failed to send reminder", zap.Error(err), zap.String("email", rsvp.Email))
+				}
+			}
+		case ReminderSMS:
+			if !SMSEnabled() {
+				continue
+			}
+			for _, rsvp := range rsvps {
+				phone, err := GetFriendPhone(rsvp.Email)
+				if err != nil || len(phone) == 0 {
+					continue
+				}
+				body := fmt.Sprintf("Pizza Friday is in %s, %s. Change your RSVP: %s", lead, FormatTime(friday.Start), BaseURL+EditRSVPURL(rsvp))
+				if friday.ArrivalOpen(time.Now()) {
+					body += " Running late? " + BaseURL + ArrivalURL(rsvp)
+				}
+				if err = SendSMS(phone, body); err != nil {
+					Log.Warn("failed to text reminder", zap.Error(err), zap.String("email", rsvp.Email))
+				}
+			}
+		case ReminderMatrix:
+			if roomBot == nil {
+				continue
+			}
+			text := fmt.Sprintf("Pizza Friday is in %s, %s, with %d coming. RSVP at %s/", lead, FormatTime(friday.Start), Headcount(rsvps), BaseURL)
+			if err := roomBot.Notify(text); err != nil {
+				Log.Warn("failed to post reminder", zap.Error(err))
+			}
+		}
+	}
+}
+
+type AdminRemindersPageData struct {
+	AdminView
+	Schedule []AdminReminderData
+	Fridays  []AdminReminderFridayData
+	Channels []string
+	Message  string
+	Error    string
+}
+
+type AdminReminderData struct {
+	ID          string
+	Description string
+}
+
+type AdminReminderFridayData struct {
+	ID        string
+	Date      string
+	Reminders []AdminReminderData
+}
+
+func adminReminders(reminders []Reminder) []AdminReminderData {
+	data := []AdminReminderData{}
+	for _, reminder := range reminders {
+		data = append(data, AdminReminderData{ID: reminder.ID(), Description: reminder.String()})
+	}
+	return data
+}
+
+// HandleAdminReminders shows the reminder schedule and the upcoming parties'
+// own reminders, and adds and removes them. Reminders added for a party
+// replace the schedule for it.
+func HandleAdminReminders(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/reminders.html")
+	if err != nil {
+		Log.Error("template admin reminders failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	schedule, _, err := GetReminderSchedule()
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	fridays, err := GetUpcomingFridays(UpcomingDays)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminRemindersPageData{AdminView: newAdminView(w, r), Channels: ReminderChannels}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		id := r.PostForm.Get("friday")
+		reminders := schedule
+		var friday *Friday
+		if len(id) > 0 {
+			for i := range fridays {
+				if fridays[i].ID() == id {
+					friday = &fridays[i]
+				}
+			}
+			if friday == nil {
+				Handle4xx(w, r)
+				return
+			}
+			reminders = friday.Reminders
+		}
+		saved := []Reminder{}
+		switch r.PostForm.Get("action") {
+		case "add":
+			reminder, err := ParseReminder(r.PostForm.Get("before"), r.PostForm["channel"])
+			if err != nil {
+				data.Error = "Reminders need a time before the party, like 2h or 7d, and a channel."
+				break
+			}
+			for _, existing := range reminders {
+				if existing.ID() != reminder.ID() {
+					saved = append(saved, existing)
+				}
+			}
+			saved = append(saved, reminder)
+			sort.Slice(saved, func(i, j int) bool { return saved[i].Before > saved[j].Before })
+			data.Message = "Added the reminder " + reminder.String() + "."
+		case "remove":
+			for _, existing := range reminders {
+				if existing.ID() != r.PostForm.Get("id") {
+					saved = append(saved, existing)
+				}
+			}
+			data.Message = "Removed the reminder."
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		if len(data.Error) == 0 {
+			if friday != nil {
+				if err = SetFridayReminders(id, saved); err != nil {
+					Handle500(w, r)
+					return
+				}
+				fridayCache.Clear()
+				friday.Reminders = saved
+			} else {
+				if err = SaveReminderSchedule(saved); err != nil {
+					Handle500(w, r)
+					return
+				}
+				schedule = saved
+			}
+		}
+	}
+
+	data.Schedule = adminReminders(schedule)
+	for _, friday := range fridays {
+		data.Fridays = append(data.Fridays, AdminReminderFridayData{
+			ID:        friday.ID(),
+			Date:      FormatTime(friday.Start),
+			Reminders: adminReminders(friday.Reminders),
+		})
+	}
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,60 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReminder(t *testing.T) {
+	// WHEN
+	week, err := pizza.ParseReminder("7d", []string{"email"})
+	require.Nil(t, err)
+	soon, err := pizza.ParseReminder("90m", []string{"email", "sms"})
+	require.Nil(t, err)
+
+	// THEN
+	assert.Equal(t, "7 days before by email", week.String())
+	assert.Equal(t, "90 minutes before by email and sms", soon.String())
+	assert.Equal(t, "before-1h30m0s", soon.ID())
+	_, err = pizza.ParseReminder("2h", nil)
+	assert.Equal(t, pizza.ErrInvalidReminder, err)
+	_, err = pizza.ParseReminder("2h", []string{"pigeon"})
+	assert.Equal(t, pizza.ErrInvalidReminder, err)
+	_, err = pizza.ParseReminder("soon", []string{"email"})
+	assert.Equal(t, pizza.ErrInvalidReminder, err)
+}
+
+func TestDueReminders(t *testing.T) {
+	// GIVEN a party on Friday, Apr 7 at 5:30pm in New York
+	friday := pizza.Friday{Start: time.Unix(1680903000, 0)}
+	reminders := pizza.DefaultReminders
+	none := map[string]bool{}
+
+	// THEN
+	assert.Empty(t, pizza.DueReminders(reminders, friday, friday.Start.Add(-8*24*time.Hour), none))
+	assert.Equal(t, reminders[:1], pizza.DueReminders(reminders, friday, friday.Start.Add(-7*24*time.Hour), none))
+
+	// THEN reminders that were missed come before the one to send
+	due := pizza.DueReminders(reminders, friday, friday.Start.Add(-time.Hour), none)
+	assert.Equal(t, []pizza.Reminder{reminders[0], reminders[1], reminders[2]}, due)
+
+	// THEN reminders are only sent once per party
+	sent := map[string]bool{"before-168h0m0s:1680903000": true, "before-24h0m0s:1680903000": true}
+	assert.Equal(t, reminders[2:], pizza.DueReminders(reminders, friday, friday.Start.Add(-time.Hour), sent))
+
+	// THEN nothing is sent once the party started
+	assert.Empty(t, pizza.DueReminders(reminders, friday, friday.Start, none))
+}
+
+func TestFridayReminders(t *testing.T) {
+	// GIVEN
+	own := []pizza.Reminder{{Before: time.Hour, Channels: []string{"matrix"}}}
+
+	// THEN a party's own reminders replace the schedule
+	assert.Equal(t, pizza.DefaultReminders, pizza.FridayReminders(pizza.DefaultReminders, pizza.Friday{}))
+	assert.Equal(t, own, pizza.FridayReminders(pizza.DefaultReminders, pizza.Friday{Reminders: own}))
+}
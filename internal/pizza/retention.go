@@ -0,0 +1,114 @@
+package pizza
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetentionPolicy is how long one kind of record is kept before it is purged
+// or anonymized.
+type RetentionPolicy struct {
+	Name string
+	// Months is how long the records are kept, forever when 0
+	Months int
+	log    string
+	// docType limits the policy to one type of record in the log
+	docType string
+	// personal are the fields cleared when anonymizing
+	personal []string
+}
+
+// RetentionPolicies cover the audit log, click tracking, and the details of
+// cancelled RSVPs kept in the changes feed.
+var RetentionPolicies = []RetentionPolicy{
+	{Name: "audit", log: "audit", personal: []string{"target", "ip"}},
+	{Name: "clicks", log: "email_events", personal: []string{"email"}},
+	{Name: "cancelled", log: "changes", docType: ChangeRSVPDeleted, personal: []string{"rsvp"}},
+}
+
+// RetentionAnonymize clears who the old records were about instead of
+// deleting them, so counts like the analytics stay the same.
+var RetentionAnonymize = false
+
+// RetentionDryRun only reports what the retention job would remove.
+var RetentionDryRun = false
+
+type RetentionResult struct {
+	Name       string    `json:"name"`
+	Before     time.Time `json:"before"`
+	Count      int       `json:"count"`
+	Anonymized bool      `json:"anonymized"`
+	DryRun     bool      `json:"dryRun"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RetentionCutoff is when records older than the months stop being kept.
+func RetentionCutoff(now time.Time, months int) time.Time {
+	return now.AddDate(0, -months, 0)
+}
+
+// SetRetentionMonths sets how long the policy's records are kept.
+func SetRetentionMonths(name string, months int) {
+	for i := range RetentionPolicies {
+		if RetentionPolicies[i].Name == name {
+			RetentionPolicies[i].Months = months
+		}
+	}
+}
+
+// ApplyRetention sets the retention policies from the config.
+func ApplyRetention(config RetentionConfig) {
+	SetRetentionMonths("audit", config.AuditMonths)
+	SetRetentionMonths("clicks", config.ClickMonths)
+	SetRetentionMonths("cancelled", config.CancelledMonths)
+	RetentionAnonymize = config.Anonymize
+	RetentionDryRun = config.DryRun
+}
+
+// RunRetention purges or anonymizes the records past each policy's months,
+// or only counts them in a dry run.
+func RunRetention(now time.Time, dryRun bool) []RetentionResult {
+	results := []RetentionResult{}
+	for _, policy := range RetentionPolicies {
+		if policy.Months <= 0 {
+			continue
+		}
+		result := RetentionResult{
+			Name:       policy.Name,
+			Before:     RetentionCutoff(now, policy.Months),
+			Anonymized: RetentionAnonymize,
+			DryRun:     dryRun,
+		}
+		fields := []string{}
+		if RetentionAnonymize {
+			fields = policy.personal
+		}
+		n, err := store.ExpireLog(policy.log, result.Before, policy.docType, fields, dryRun)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Count = n
+		results = append(results, result)
+	}
+	return results
+}
+
+// WatchRetention runs the retention policies until ctx is done, logging what
+// was, or in a dry run would be, removed.
+func WatchRetention(ctx context.Context, every time.Duration) {
+	for {
+		for _, result := range RunRetention(time.Now(), RetentionDryRun) {
+			if len(result.Error) > 0 {
+				Log.Error("retention failed", zap.String("policy", result.Name), zap.String("error", result.Error))
+			} else if result.Count > 0 {
+				Log.Info("retention", zap.String("policy", result.Name), zap.Int("count", result.Count),
+					zap.Time("before", result.Before), zap.Bool("anonymized", result.Anonymized), zap.Bool("dryRun", result.DryRun))
+			}
+		}
+		if !sleepCtx(ctx, every) {
+			return
+		}
+	}
+}
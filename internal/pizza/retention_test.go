@@ -0,0 +1,46 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestRetentionCutoff(t *testing.T) {
+	// GIVEN
+	now := time.Date(2023, 4, 7, 21, 30, 0, 0, time.UTC)
+
+	// THEN
+	assert.Equal(t, time.Date(2022, 4, 7, 21, 30, 0, 0, time.UTC), pizza.RetentionCutoff(now, 12))
+	assert.Equal(t, time.Date(2022, 10, 7, 21, 30, 0, 0, time.UTC), pizza.RetentionCutoff(now, 6))
+}
+
+func TestApplyRetention(t *testing.T) {
+	// GIVEN
+	defer pizza.ApplyRetention(pizza.RetentionConfig{})
+
+	// WHEN
+	pizza.ApplyRetention(pizza.RetentionConfig{AuditMonths: 24, CancelledMonths: 6, Anonymize: true})
+
+	// THEN
+	months := map[string]int{}
+	for _, policy := range pizza.RetentionPolicies {
+		months[policy.Name] = policy.Months
+	}
+	assert.Equal(t, map[string]int{"audit": 24, "clicks": 0, "cancelled": 6}, months)
+	assert.True(t, pizza.RetentionAnonymize)
+}
+
+func TestRunRetentionKeepsForever(t *testing.T) {
+	// GIVEN no policy has a limit
+	pizza.ApplyRetention(pizza.RetentionConfig{})
+
+	// WHEN
+	results := pizza.RunRetention(time.Now(), true)
+
+	// THEN nothing is looked at
+	assert.Empty(t, results)
+}
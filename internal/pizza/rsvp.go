@@ -0,0 +1,515 @@
+package pizza
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRSVPNotFound    = errors.New("rsvp not found")
+	ErrRSVPNotOwner    = errors.New("rsvp belongs to another friend")
+	ErrRSVPClosed      = errors.New("rsvps are closed for this friday")
+	ErrRSVPInvalid     = errors.New("invalid rsvp")
+	ErrRSVPReviewed    = errors.New("rsvp is not waiting for approval")
+	ErrRSVPCodeExpired = errors.New("rsvp code has expired")
+	ErrRSVPConflict    = errors.New("rsvp was changed by someone else")
+	ErrRSVPFull        = errors.New("not enough spots left for the rsvp")
+)
+
+// RSVPCodeTTL is how long a friend has to confirm their pending dates with the
+// code they were sent.
+var RSVPCodeTTL = 24 * time.Hour
+
+// SweepRSVPCodes clears codes, and the dates waiting on them, that expired more
+// than LinkClockSkew ago.
+func SweepRSVPCodes() {
+	n, err := ClearExpiredRSVPCodes(time.Now().Add(-LinkClockSkew))
+	if err != nil {
+		Log.Error("failed to clear expired rsvp codes", zap.Error(err))
+	} else if n > 0 {
+		Log.Info("cleared expired rsvp codes", zap.Int("count", n))
+	}
+}
+
+// PendingRSVPExpires is when an RSVP waiting for approval expires, which is
+// when its Friday starts since it can't be approved after that.
+func PendingRSVPExpires(rsvp RSVP, friday Friday) (time.Time, bool) {
+	if rsvp.Status != RSVPStatusPending {
+		return time.Time{}, false
+	}
+	return friday.Start, true
+}
+
+// SweepPendingRSVPs deletes RSVPs still waiting for approval when their Friday
+// started. Fauna removes them itself once their TTL passes, but only some time
+// after, and without recording the change.
+func SweepPendingRSVPs(now time.Time) {
+	rsvps, err := ListPendingRSVPs()
+	if err != nil {
+		Log.Error("failed to list pending rsvps", zap.Error(err))
+		return
+	}
+	fridays := map[string]*Friday{}
+	for _, rsvp := range rsvps {
+		friday, ok := fridays[rsvp.FridayID]
+		if !ok {
+			if friday, err = GetFriday(rsvp.FridayID); err != nil {
+				continue
+			}
+			fridays[rsvp.FridayID] = friday
+		}
+		if friday == nil {
+			continue
+		}
+		if expires, ok := PendingRSVPExpires(rsvp, *friday); !ok || now.Before(expires) {
+			continue
+		}
+		if err = DeleteFridayRSVP(rsvp.ID); err != nil {
+			Log.Error("failed to delete expired rsvp", zap.Error(err), zap.String("id", rsvp.ID))
+			continue
+		}
+		Log.Info("expired pending rsvp", zap.String("id", rsvp.ID), zap.String("friday", rsvp.FridayID))
+		rsvpsChanged(ChangeRSVPDeleted, rsvp.FridayID)
+	}
+}
+
+// WatchExpired sweeps expired RSVP codes and pending RSVPs until ctx is done.
+func WatchExpired(ctx context.Context, every time.Duration) {
+	for {
+		SweepRSVPCodes()
+		SweepPendingRSVPs(time.Now())
+		if !sleepCtx(ctx, every) {
+			return
+		}
+	}
+}
+
+const (
+	RSVPStatusConfirmed = "confirmed"
+	RSVPStatusPending   = "pending"
+	RSVPStatusDeclined  = "declined"
+)
+
+// ToppingOptions are the toppings friends may vote for. An empty list allows
+// any topping.
+var ToppingOptions []string
+
+// RSVPOptions are what the friend said when they RSVPed.
+type RSVPOptions struct {
+	PlusOnes int
+	Kids     int
+	// Days are the days of a multi-day event they're coming, checked with
+	// EventDays. The friend counts towards the limit whichever days they come.
+	Days []string
+	// Preferences are their topping votes and dietary restrictions, checked
+	// with ParsePreferences
+	Preferences RSVPPreferences
+}
+
+// RSVPToFriday records the friend's RSVP and invites them to the Friday's
+// calendar event. RSVPs that would take the Friday over its limit wait for the
+// host's approval instead. While the calendar is backed up, or if the invite
+// fails, the RSVP is still saved and marked InvitePending for
+// DeliverPendingInvites to invite them later. The friend must already be
+// allowed and the Friday still open. If they already RSVPed, their RSVP is
+// returned as it is, without inviting or telling anyone again.
+func RSVPToFriday(email string, friday Friday, opts RSVPOptions) (RSVP, error) {
+	rsvp := RSVP{Email: email, FridayID: friday.ID(), PlusOnes: opts.PlusOnes, Kids: opts.Kids, Status: RSVPStatusConfirmed,
+		Days: opts.Days, Toppings: opts.Preferences.Toppings, Diets: opts.Preferences.Diets}
+	rsvp, created, err := CreateFridayRSVP(rsvp, friday.Limit())
+	if err != nil || !created {
+		return rsvp, err
+	}
+	if rsvp.Confirmed() {
+		if CalendarBackedUp() {
+			err = errors.New("calendar is backed up")
+		} else {
+			err = inviteToFriday(rsvp, friday)
+		}
+		if err != nil {
+			Log.Warn("queueing calendar invite", zap.Error(err), zap.String("eventID", friday.ID()), zap.String("email", email))
+			rsvp.InvitePending = true
+			if err = UpdateFridayRSVP(rsvp); err != nil {
+				return rsvp, err
+			}
+		}
+	}
+	rsvpsChanged(ChangeRSVPCreated, friday.ID())
+	go notifyChat(ChangeRSVPCreated, rsvp)
+	return rsvp, nil
+}
+
+// DeliverPendingInvites sends the calendar invites that were put off while the
+// calendar was backed up.
+func DeliverPendingInvites() {
+	rsvps, err := ListInvitePendingRSVPs()
+	if err != nil {
+		Log.Error("failed to list pending invites", zap.Error(err))
+		return
+	}
+	for _, rsvp := range rsvps {
+		if CalendarBackedUp() {
+			return
+		}
+		friday, err := GetFriday(rsvp.FridayID)
+		if err != nil {
+			continue
+		}
+		if friday != nil && rsvp.Confirmed() && !friday.IsOver() {
+			if err = inviteToFriday(rsvp, *friday); err != nil {
+				Log.Warn("failed to send pending invite", zap.Error(err), zap.String("id", rsvp.ID))
+				continue
+			}
+		}
+		rsvp.InvitePending = false
+		if err = UpdateFridayRSVP(rsvp); err != nil {
+			Log.Warn("failed to clear pending invite", zap.Error(err), zap.String("id", rsvp.ID))
+			continue
+		}
+		RefreshEventDescription(rsvp.FridayID)
+	}
+}
+
+// WatchPendingInvites sends put off invites until ctx is done.
+func WatchPendingInvites(ctx context.Context, every time.Duration) {
+	for sleepCtx(ctx, every) {
+		DeliverPendingInvites()
+	}
+}
+
+func inviteToFriday(rsvp RSVP, friday Friday) error {
+	friendName, err := GetCachedFriendName(rsvp.Email)
+	if err != nil {
+		return err
+	}
+	event, err := InviteToCalendarEvent(friday.ID(), friday.Start, friday.EndTime(), friendName, rsvp.Email)
+	if err != nil {
+		return err
+	}
+	Log.Debug("event updated", zap.Any("event", event))
+	return nil
+}
+
+type ReviewEmailData struct {
+	Name     string
+	Date     string
+	Approved bool
+	// Waitlist is set when the friend got in off the waitlist
+	Waitlist bool
+	EditURL  string
+}
+
+// ReviewRSVP approves or declines an RSVP waiting for the host's approval and
+// lets the friend know either way.
+func ReviewRSVP(id string, approve bool) (RSVP, error) {
+	rsvp, err := GetFridayRSVP(id)
+	if err != nil {
+		return RSVP{}, err
+	} else if rsvp == nil {
+		return RSVP{}, ErrRSVPNotFound
+	} else if rsvp.Status != RSVPStatusPending {
+		return *rsvp, ErrRSVPReviewed
+	}
+	friday, err := GetFriday(rsvp.FridayID)
+	if err != nil {
+		return *rsvp, err
+	} else if friday == nil {
+		return *rsvp, ErrRSVPNotFound
+	}
+
+	rsvp.Status = RSVPStatusDeclined
+	if approve {
+		if err = inviteToFriday(*rsvp, *friday); err != nil {
+			return *rsvp, err
+		}
+		rsvp.Status = RSVPStatusConfirmed
+	}
+	if err = UpdateFridayRSVP(*rsvp); err != nil {
+		return *rsvp, err
+	}
+	rsvpsChanged(ChangeRSVPUpdated, rsvp.FridayID)
+
+	name, _ := GetCachedFriendName(rsvp.Email)
+	msg, err := RenderEmail("review", ReviewEmailData{
+		Name:     name,
+		Date:     FormatTime(friday.Start),
+		Approved: approve,
+		EditURL:  BaseURL + EditRSVPURL(*rsvp),
+	})
+	if err != nil {
+		Log.Error("review template failure", zap.Error(err))
+		return *rsvp, nil
+	}
+	msg.To = rsvp.Email
+	if err = SendEmail(msg); err != nil {
+		Log.Warn("failed to send review email", zap.Error(err), zap.String("email", rsvp.Email))
+	}
+	return *rsvp, nil
+}
+
+// RSVPEdit is a partial change to an RSVP. Nil fields are left as they are.
+type RSVPEdit struct {
+	Email    string            `json:"email"`
+	Sig      string            `json:"sig"`
+	PlusOnes *int              `json:"plusOnes"`
+	Kids     *int              `json:"kids"`
+	Drinks   map[string]int    `json:"drinks"`
+	Toppings []string          `json:"toppings"`
+	Answers  map[string]string `json:"answers"`
+	Diets    map[string]int    `json:"diets"`
+}
+
+// EditRSVP applies the edit to the friend's RSVP and refreshes the calendar
+// event description. The edit must carry the signature from the friend's edit
+// link, and is only accepted up to the Friday's deadline. A confirmed RSVP
+// can't bring more guests than there are spots left; the edit is refused with
+// ErrRSVPFull instead.
+func EditRSVP(id string, edit RSVPEdit) (RSVP, error) {
+	rsvp, err := GetFridayRSVP(id)
+	if err != nil {
+		return RSVP{}, err
+	} else if rsvp == nil {
+		return RSVP{}, ErrRSVPNotFound
+	}
+	if rsvp.Email != strings.ToLower(edit.Email) || !VerifyLink(edit.Sig, "rsvp", rsvp.ID, rsvp.Email) {
+		return *rsvp, ErrRSVPNotOwner
+	}
+
+	friday, ok, err := GetCachedFriday(UpcomingDays, rsvp.FridayID)
+	if err != nil {
+		return *rsvp, err
+	} else if !ok || friday.IsClosed() {
+		return *rsvp, ErrRSVPClosed
+	}
+
+	if err = applyRSVPEdit(rsvp, friday, edit); err != nil {
+		return *rsvp, err
+	}
+	if err = EditFridayRSVP(*rsvp, friday.Limit()); err != nil {
+		return *rsvp, err
+	}
+	rsvpsChanged(ChangeRSVPUpdated, rsvp.FridayID)
+	return *rsvp, nil
+}
+
+// CancelRSVP removes the friend's RSVP and takes them off the calendar
+// invite, freeing their spots for anyone waiting on one. Like an edit, it must
+// carry the signature from the friend's edit link, but it is accepted after
+// the deadline until the Friday starts.
+func CancelRSVP(id, email, sig string) (RSVP, error) {
+	rsvp, err := GetFridayRSVP(id)
+	if err != nil {
+		return RSVP{}, err
+	} else if rsvp == nil {
+		return RSVP{}, ErrRSVPNotFound
+	}
+	if rsvp.Email != strings.ToLower(email) || !VerifyLink(sig, "rsvp", rsvp.ID, rsvp.Email) {
+		return *rsvp, ErrRSVPNotOwner
+	}
+	return *rsvp, cancelRSVP(*rsvp)
+}
+
+// cancelRSVP removes the RSVP, until its Friday starts, once the friend has
+// been checked.
+func cancelRSVP(rsvp RSVP) error {
+	if _, ok, err := GetCachedFriday(UpcomingDays, rsvp.FridayID); err != nil {
+		return err
+	} else if !ok {
+		return ErrRSVPClosed
+	}
+
+	if err := DeleteFridayRSVP(rsvp.ID); err != nil {
+		return err
+	}
+	if err := RemoveCalendarAttendee(rsvp.FridayID, rsvp.Email); err != nil {
+		Log.Warn("failed to remove calendar attendee", zap.Error(err), zap.String("eventID", rsvp.FridayID))
+	}
+	rsvpsChanged(ChangeRSVPDeleted, rsvp.FridayID)
+	go notifyChat(ChangeRSVPDeleted, rsvp)
+	return nil
+}
+
+func applyRSVPEdit(rsvp *RSVP, friday Friday, edit RSVPEdit) error {
+	if edit.PlusOnes != nil {
+		if *edit.PlusOnes < 0 || *edit.PlusOnes > MaxPlusOnesFor(rsvp.Email) {
+			return ErrRSVPInvalid
+		}
+		rsvp.PlusOnes = *edit.PlusOnes
+	}
+	if edit.Kids != nil {
+		if *edit.Kids < 0 || *edit.Kids > CurrentSettings().MaxKids {
+			return ErrRSVPInvalid
+		}
+		rsvp.Kids = *edit.Kids
+	}
+	if edit.Toppings != nil {
+		for _, topping := range edit.Toppings {
+			if len(ToppingOptions) > 0 && !containsString(ToppingOptions, topping) {
+				return ErrRSVPInvalid
+			}
+		}
+		rsvp.Toppings = edit.Toppings
+	}
+	for diet, n := range edit.Diets {
+		if !containsString(DietaryOptions, diet) || n < 0 || n > rsvp.Guests() {
+			return ErrRSVPInvalid
+		}
+		if rsvp.Diets == nil {
+			rsvp.Diets = make(map[string]int)
+		}
+		if n == 0 {
+			delete(rsvp.Diets, diet)
+		} else {
+			rsvp.Diets[diet] = n
+		}
+	}
+	for category, n := range edit.Drinks {
+		if !containsString(friday.Drinks, category) || n < 0 || n > MaxDrinks {
+			return ErrRSVPInvalid
+		}
+		if rsvp.Drinks == nil {
+			rsvp.Drinks = make(map[string]int)
+		}
+		rsvp.Drinks[category] = n
+	}
+	for question, answer := range edit.Answers {
+		if !containsString(friday.Questions, question) {
+			return ErrRSVPInvalid
+		}
+		if rsvp.Answers == nil {
+			rsvp.Answers = make(map[string]string)
+		}
+		rsvp.Answers[question] = answer
+	}
+	return nil
+}
+
+// FridayStatus summarizes a Friday for dashboards and home automation.
+type FridayStatus struct {
+	ID        string    `json:"id"`
+	Start     time.Time `json:"start"`
+	Deadline  time.Time `json:"deadline"`
+	Closed    bool      `json:"closed"`
+	Open      bool      `json:"open"`
+	Headcount int       `json:"headcount"`
+	Kids      int       `json:"kids"`
+	Pending   int       `json:"pending"`
+}
+
+func GetFridayStatus(friday Friday) (FridayStatus, error) {
+	status := FridayStatus{
+		ID:       friday.ID(),
+		Start:    friday.Start,
+		Deadline: friday.Deadline(),
+		Closed:   friday.IsClosed(),
+		Open:     friday.IsOpen(),
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return status, err
+	}
+	status.Headcount = Headcount(rsvps)
+	status.Kids = CountGuests(rsvps).Kids
+	for _, rsvp := range rsvps {
+		if rsvp.Status == RSVPStatusPending {
+			status.Pending += rsvp.Guests()
+		}
+	}
+	return status, nil
+}
+
+// ConfirmedRSVPs leaves out RSVPs that are pending or declined.
+func ConfirmedRSVPs(rsvps []RSVP) []RSVP {
+	confirmed := []RSVP{}
+	for _, rsvp := range rsvps {
+		if rsvp.Confirmed() {
+			confirmed = append(confirmed, rsvp)
+		}
+	}
+	return confirmed
+}
+
+// Headcount counts the confirmed guests and their plus ones.
+func Headcount(rsvps []RSVP) int {
+	n := 0
+	for _, rsvp := range ConfirmedRSVPs(rsvps) {
+		n += rsvp.Guests()
+	}
+	return n
+}
+
+// DayHeadcount is how many confirmed guests are coming on one day of an event.
+type DayHeadcount struct {
+	EventDay
+	Headcount int
+}
+
+// DayHeadcounts counts the confirmed guests, with their plus ones, coming on
+// each day of the event.
+func DayHeadcounts(friday Friday, rsvps []RSVP) []DayHeadcount {
+	days := friday.Days()
+	counts := make([]DayHeadcount, len(days))
+	for i, day := range days {
+		counts[i].EventDay = day
+		for _, rsvp := range ConfirmedRSVPs(rsvps) {
+			if rsvp.Attends(day.Date) {
+				counts[i].Headcount += rsvp.Guests()
+			}
+		}
+	}
+	return counts
+}
+
+// rsvpsChanged brings everything derived from a Friday's RSVPs up to date.
+func rsvpsChanged(changeType, fridayID string) {
+	apiFridaysCache.Clear()
+	// don't keep the friend waiting on the calendar queue
+	if CalendarBackedUp() {
+		go RefreshEventDescription(fridayID)
+	} else {
+		RefreshEventDescription(fridayID)
+	}
+	go PublishRSVPChange(changeType, fridayID)
+	go mirrorFridayID(fridayID)
+	go func() {
+		if friday, ok, err := GetCachedFriday(UpcomingDays, fridayID); err == nil && ok {
+			// the waitlist gets freed spots before anyone asking to be told
+			PromoteWaitlist(friday)
+			CheckOpenSpots(friday)
+			CheckHeadcountAlerts(friday)
+		}
+	}()
+}
+
+// RefreshEventDescription rewrites the calendar event description from the
+// Friday's current RSVPs. Failures are logged since the RSVPs themselves are
+// already saved.
+func RefreshEventDescription(fridayID string) {
+	rsvps, err := ListFridayRSVPs(fridayID)
+	if err != nil {
+		Log.Warn("failed to list rsvps for description", zap.Error(err), zap.String("eventID", fridayID))
+		return
+	}
+	names := make(map[string]string)
+	for _, rsvp := range rsvps {
+		if name, err := GetCachedFriendName(rsvp.Email); err == nil {
+			names[rsvp.Email] = name
+		}
+	}
+	if _, err = UpdateCalendarEventDescription(fridayID, BuildEventDescription(ConfirmedRSVPs(rsvps), names)); err != nil {
+		Log.Warn("failed to update event description", zap.Error(err), zap.String("eventID", fridayID))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package pizza_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadcount(t *testing.T) {
+	// GIVEN
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 1},
+		{Email: "coach@tedlasso.com", Status: pizza.RSVPStatusConfirmed},
+		{Email: "roy@tedlasso.com", PlusOnes: 2, Status: pizza.RSVPStatusPending},
+		{Email: "jamie@tedlasso.com", Status: pizza.RSVPStatusDeclined},
+	}
+
+	// WHEN
+	headcount := pizza.Headcount(rsvps)
+	confirmed := pizza.ConfirmedRSVPs(rsvps)
+
+	// THEN
+	assert.Equal(t, 3, headcount)
+	assert.Len(t, confirmed, 2)
+}
+
+func TestCountGuests(t *testing.T) {
+	// GIVEN
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 1, Kids: 2},
+		{Email: "coach@tedlasso.com"},
+		{Email: "roy@tedlasso.com", Kids: 1, Status: pizza.RSVPStatusPending},
+	}
+
+	// WHEN
+	count := pizza.CountGuests(rsvps)
+
+	// THEN kids take a spot but eat less
+	assert.Equal(t, pizza.GuestCount{Adults: 3, Kids: 2}, count)
+	assert.Equal(t, 5, pizza.Headcount(rsvps))
+	assert.Equal(t, 2, count.Pizzas())
+	assert.Equal(t, 0, pizza.GuestCount{}.Pizzas())
+	assert.Equal(t, 1, pizza.GuestCount{Kids: 4}.Pizzas())
+}
+
+func TestFridayLimit(t *testing.T) {
+	// GIVEN
+	defer pizza.SetSettings(func(s *pizza.RuntimeSettings) { s.DefaultCapacity = 12 })()
+
+	// THEN
+	assert.Equal(t, 12, pizza.Friday{}.Limit())
+	assert.Equal(t, 20, pizza.Friday{Capacity: 20}.Limit())
+}
+
+func TestRenderReviewEmail(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	data := pizza.ReviewEmailData{Name: "Ted Lasso", Date: "07 Apr 23 17:30 EDT", EditURL: "https://rsvp.pizza/rsvp/1/edit?sig=abc"}
+
+	// WHEN
+	declined, err := pizza.RenderEmail("review", data)
+	data.Approved = true
+	approved, err2 := pizza.RenderEmail("review", data)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Nil(t, err2)
+	assert.Equal(t, "Pizza Friday is full", declined.Subject)
+	assert.NotContains(t, declined.Body, data.EditURL)
+	assert.Equal(t, "You're in for pizza!", approved.Subject)
+	assert.Contains(t, approved.Body, data.EditURL)
+}
+
+func TestPendingRSVPExpires(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{UID: "01gxd2m9r0a1b2c3d4e5f6g7h8", Start: time.Unix(1680903000, 0)}
+	pending := pizza.RSVP{FridayID: friday.ID(), Status: pizza.RSVPStatusPending}
+	confirmed := pizza.RSVP{FridayID: friday.ID(), Status: pizza.RSVPStatusConfirmed}
+
+	// WHEN
+	expires, ok := pizza.PendingRSVPExpires(pending, friday)
+	_, confirmedExpires := pizza.PendingRSVPExpires(confirmed, friday)
+
+	// THEN pending RSVPs expire when their Friday starts
+	assert.True(t, ok)
+	assert.Equal(t, friday.Start, expires)
+	assert.False(t, confirmedExpires)
+}
+
+func TestWatchPendingInvitesStops(t *testing.T) {
+	// GIVEN
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pizza.WatchPendingInvites(ctx, time.Hour)
+		close(done)
+	}()
+
+	// WHEN the server shuts down
+	cancel()
+
+	// THEN the watcher stops without waiting out its period
+	assert.Eventually(t, func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,620 @@
+package pizza
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrSandboxReadOnly = errors.New("the sandbox is read-only")
+
+// sandboxFriends are the made up friends of the sandbox. Their emails are at
+// example.com so nobody is ever emailed.
+var sandboxFriends = []Friend{
+	{Name: "Ada Example", Email: "ada@example.com"},
+	{Name: "Basil Example", Email: "basil@example.com"},
+	{Name: "Cora Example", Email: "cora@example.com"},
+	{Name: "Dev Example", Email: "dev@example.com"},
+	{Name: "Edie Example", Email: "edie@example.com"},
+	{Name: "Finn Example", Email: "finn@example.com"},
+}
+
+// SandboxStore is a read-only Store of made up friends, events, and RSVPs,
+// for the API sandbox.
+type SandboxStore struct {
+	fridays []Friday
+	rsvps   []RSVP
+	changes []Change
+}
+
+// NewSandboxStore makes up the next four Fridays after now, each with a few
+// RSVPs, the last of them waiting for approval.
+func NewSandboxStore(now time.Time) *SandboxStore {
+	s := &SandboxStore{}
+	estZone, _ := time.LoadLocation("America/New_York")
+	for i, start := range NextFridays(now, estZone, 18, 0, 4) {
+		friday := Friday{Start: start, Capacity: 8, TS: now.Add(-time.Duration(4-i) * time.Hour).UnixMicro()}
+		s.fridays = append(s.fridays, friday)
+		for j, friend := range sandboxFriends[:3+i%3] {
+			rsvp := RSVP{
+				ID:        fmt.Sprintf("%s%02d", friday.ID(), j),
+				Email:     friend.Email,
+				FridayID:  friday.ID(),
+				PlusOnes:  j % 2,
+				Kids:      (i + j) % 3 / 2,
+				Status:    RSVPStatusConfirmed,
+				Toppings:  []string{},
+				Answers:   map[string]string{},
+				UpdatedAt: now.Add(-time.Duration(4-i)*time.Hour + time.Duration(j)*time.Minute),
+			}
+			if j == 4 {
+				rsvp.Status = RSVPStatusPending
+			}
+			s.rsvps = append(s.rsvps, rsvp)
+		}
+	}
+	for i := range s.rsvps {
+		rsvp := s.rsvps[i]
+		change := Change{TS: rsvp.UpdatedAt.UnixMicro(), ID: rsvp.ID, Type: ChangeRSVPCreated, FridayID: rsvp.FridayID, RSVPID: rsvp.ID, RSVP: &rsvp}
+		change.Cursor = fmt.Sprintf("%d-%s", change.TS, change.ID)
+		change.At = time.UnixMicro(change.TS)
+		s.changes = append(s.changes, change)
+	}
+	sort.SliceStable(s.changes, func(i, j int) bool { return s.changes[i].TS < s.changes[j].TS })
+	return s
+}
+
+func (s *SandboxStore) IsFriendAllowed(friendEmail string) (bool, error) {
+	name, _ := s.GetFriendName(friendEmail)
+	return len(name) > 0, nil
+}
+
+func (s *SandboxStore) GetFriendName(friendEmail string) (string, error) {
+	for _, friend := range sandboxFriends {
+		if friend.Email == friendEmail {
+			return friend.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *SandboxStore) GetUpcomingFridays(daysAhead int) ([]Friday, error) {
+	now := time.Now()
+	fridays := []Friday{}
+	for _, friday := range s.fridays {
+		if !friday.Start.Before(now) && !friday.Start.After(now.AddDate(0, 0, 1+daysAhead)) {
+			fridays = append(fridays, friday)
+		}
+	}
+	return fridays, nil
+}
+
+func (s *SandboxStore) GetFriday(id string) (*Friday, error) {
+	for _, friday := range s.fridays {
+		if friday.ID() == id {
+			return &friday, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *SandboxStore) CreateFriday(friday Friday) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) DeleteFriday(id string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) CreateRSVP(friendEmail, code string, pendingDates []time.Time, expires time.Time) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ConfirmRSVP(friendEmail, code string, notAfter time.Time) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) CreateFridayRSVP(rsvp RSVP, limit int) (RSVP, bool, error) {
+	return RSVP{}, false, ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFridayRSVP(id string) (*RSVP, error) {
+	for _, rsvp := range s.rsvps {
+		if rsvp.ID == id {
+			return &rsvp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *SandboxStore) UpdateFridayRSVP(rsvp RSVP) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) EditFridayRSVP(rsvp RSVP, limit int) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) DeleteFridayRSVP(id string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListFridayRSVPs(fridayID string) ([]RSVP, error) {
+	var rsvps []RSVP
+	for _, rsvp := range s.rsvps {
+		if rsvp.FridayID == fridayID {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	return rsvps, nil
+}
+
+func (s *SandboxStore) ListFriendRSVPs(friendEmail string) ([]RSVP, error) {
+	var rsvps []RSVP
+	for _, rsvp := range s.rsvps {
+		if rsvp.Email == friendEmail {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	return rsvps, nil
+}
+
+// SearchFriends is SearchFriends over the made up friends.
+func (s *SandboxStore) SearchFriends(query string) ([]Friend, error) {
+	query = strings.ToLower(query)
+	var friends []Friend
+	for _, friend := range sandboxFriends {
+		if strings.Contains(strings.ToLower(friend.Name), query) || strings.Contains(friend.Email, query) {
+			friends = append(friends, friend)
+		}
+	}
+	return friends, nil
+}
+
+// SearchComments is SearchComments over the made up RSVPs.
+func (s *SandboxStore) SearchComments(query string) ([]RSVP, error) {
+	var rsvps []RSVP
+	for _, rsvp := range s.rsvps {
+		if len(CommentMatches(rsvp, query)) > 0 {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	return rsvps, nil
+}
+
+// GetAllFridays is GetAllFridays over the made up events.
+func (s *SandboxStore) GetAllFridays() ([]Friday, error) {
+	return append([]Friday{}, s.fridays...), nil
+}
+
+// ListChanges is ListChanges over the made up RSVPs.
+func (s *SandboxStore) ListChanges(cursor string, limit int) ([]Change, string, error) {
+	cursorTS, cursorID, hasCursor := parseChangeCursor(cursor)
+	changes := []Change{}
+	for _, change := range s.changes {
+		if hasCursor && (change.TS < cursorTS || change.TS == cursorTS && change.ID <= cursorID) {
+			continue
+		}
+		if len(changes) == limit {
+			break
+		}
+		changes = append(changes, change)
+		cursor = change.Cursor
+	}
+	return changes, cursor, nil
+}
+
+// ListRecentChanges is ListRecentChanges over the made up RSVPs.
+func (s *SandboxStore) ListRecentChanges(limit int) ([]Change, error) {
+	changes := []Change{}
+	for i := len(s.changes) - 1; i >= 0 && len(changes) < limit; i-- {
+		changes = append(changes, s.changes[i])
+	}
+	return changes, nil
+}
+
+// The made up friends saved nothing else, and nothing can be changed.
+
+func (s *SandboxStore) Ping() error {
+	return nil
+}
+
+func (s *SandboxStore) AddFriend(name, email string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) AddReferredFriend(name, email, referrer string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) DeleteFriend(friendEmail string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) PurgeFriend(friendEmail string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetPrimaryEmail(email string) (string, error) {
+	if ok, _ := s.IsFriendAllowed(email); ok {
+		return email, nil
+	}
+	return "", nil
+}
+
+func (s *SandboxStore) GetFriendAliases(friendEmail string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) AddFriendAlias(friendEmail, alias string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) RemoveFriendAlias(friendEmail, alias string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) FlagFriendEmail(issue EmailIssue) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetEmailStatus(friendEmail string) (string, error) {
+	return "", nil
+}
+
+func (s *SandboxStore) ListFlaggedFriends() ([]FlaggedFriend, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) SetFriendDigest(friendEmail string, subscribed bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendDigest(friendEmail string) (bool, error) {
+	return false, nil
+}
+
+func (s *SandboxStore) ListDigestFriends() ([]DigestFriend, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) SetFriendBirthday(friendEmail, birthday string, noCelebrate bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendBirthday(friendEmail string) (FriendBirthday, error) {
+	return FriendBirthday{Email: friendEmail}, nil
+}
+
+func (s *SandboxStore) ListFriendBirthdays() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (s *SandboxStore) SetFriendNoTracking(friendEmail string, noTracking bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendNoTracking(friendEmail string) (bool, error) {
+	return false, nil
+}
+
+func (s *SandboxStore) SetFriendNote(friendEmail, note string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendNote(friendEmail string) (string, error) {
+	return "", nil
+}
+
+func (s *SandboxStore) SetFriendGroups(friendEmail string, groups []string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendGroups(friendEmail string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) SetFriendLabs(friendEmail string, labs []string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFriendLabs(friendEmail string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) CountReferrals(friendEmail string) (int, error) {
+	return 0, nil
+}
+
+func (s *SandboxStore) ListReferredFriends() ([]ReferredFriend, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) GetFriendByPhone(phone string) (string, error) {
+	return "", nil
+}
+
+func (s *SandboxStore) GetFriendPhone(friendEmail string) (string, error) {
+	return "", nil
+}
+
+func (s *SandboxStore) SetFriendPhone(friendEmail, phone string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ClearExpiredRSVPCodes(before time.Time) (int, error) {
+	return 0, ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetFridayAt(start time.Time) (*Friday, error) {
+	for _, friday := range s.fridays {
+		if friday.Start.Equal(start) {
+			return &friday, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *SandboxStore) MigrateFridayUIDs(dryRun bool) (int, error) {
+	return 0, ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) AddFridayImage(id string, img Image, cover bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetFridayReminders(id string, reminders []Reminder) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetFridayTables(id string, tables [][]string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetFridayAnnouncement(id, announcement string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetFridayCohosts(id string, cohosts []string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetReaction(r Reaction) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListReactions(fridayID string) ([]Reaction, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) ImportFridayRSVP(rsvp RSVP) (bool, error) {
+	return false, ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetRSVPCheckedIn(id string, checkedIn bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) SetRSVPArrival(id string, arrival time.Time, late bool) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListPendingRSVPs() ([]RSVP, error) {
+	var rsvps []RSVP
+	for _, rsvp := range s.rsvps {
+		if rsvp.Status == RSVPStatusPending {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	return rsvps, nil
+}
+
+func (s *SandboxStore) ListInvitePendingRSVPs() ([]RSVP, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) CreateNotification(n Notification) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListNotifications(fridayID, kind string) ([]Notification, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) UpdateNotification(n Notification) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) DeleteNotification(id string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetSettings() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (s *SandboxStore) SaveSettings(settings map[string]string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetOwnership() (Ownership, error) {
+	return Ownership{}, nil
+}
+
+func (s *SandboxStore) SaveOwnership(own Ownership) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetAdminAuth() (AdminAuth, error) {
+	return AdminAuth{}, nil
+}
+
+func (s *SandboxStore) SaveAdminAuth(auth AdminAuth) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetContent() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (s *SandboxStore) SaveContent(content map[string]string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetAnnouncementTemplates() ([]AnnouncementTemplate, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) SaveAnnouncementTemplates(templates []AnnouncementTemplate) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetHeadcountAlerts() ([]HeadcountAlert, map[string]bool, error) {
+	return nil, map[string]bool{}, nil
+}
+
+func (s *SandboxStore) SaveHeadcountAlerts(alerts []HeadcountAlert) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) MarkHeadcountAlertSent(key string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetReminderSchedule() ([]Reminder, map[string]bool, error) {
+	return DefaultReminders, map[string]bool{}, nil
+}
+
+func (s *SandboxStore) SaveReminderSchedule(reminders []Reminder) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) MarkRemindersSent(keys []string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) CreateQuarantined(q Quarantined) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListQuarantined() ([]Quarantined, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) GetQuarantined(id string) (*Quarantined, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) DeleteQuarantined(id string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) RecordEmailEvent(email, kind, event string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListEmailEvents(since time.Time) ([]EmailEvent, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) RecordAudit(action, target, ip string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListAuditEntries(since time.Time) ([]AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) ExpireLog(log string, before time.Time, docType string, fields []string, dryRun bool) (int, error) {
+	return 0, ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) CreatePasskey(key Passkey) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetPasskey(id string) (*Passkey, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) ListPasskeys(friendEmail string) ([]Passkey, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) UpdatePasskeySignCount(id string, signCount uint32) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) DeletePasskey(friendEmail, id string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) CreateDevice(d Device) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) GetDeviceByToken(hash string) (*Device, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) RotateDevice(id, prev, token string) error {
+	return ErrSandboxReadOnly
+}
+
+func (s *SandboxStore) ListDevices(friendEmail string) ([]Device, error) {
+	return nil, nil
+}
+
+func (s *SandboxStore) DeleteDevice(id string) error {
+	return ErrSandboxReadOnly
+}
+
+// sandboxAPI lets anyone read the sandbox API without a key, rate limited by
+// IP address like an API client with every read scope.
+func sandboxAPI(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if ok, wait := allowAPI("sandbox-"+ip, ScopeReadEvents); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeAPIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		w.Header().Set("X-Pizza-Sandbox", "true")
+		next(w, r)
+	}
+}
+
+// HandleSandboxReadOnly answers requests that would change something.
+func HandleSandboxReadOnly(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusForbidden, ErrSandboxReadOnly.Error())
+}
+
+// newSandboxRouter serves only the API, over made up data, so developers can
+// build integrations without seeing any real friend's details.
+func newSandboxRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", HandleSandboxHealth).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", HandleSandboxHealth).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/changes", sandboxAPI(HandleAPIListChanges)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/search", sandboxAPI(HandleAPISearch)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/homeassistant", sandboxAPI(HandleAPIHomeAssistant)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/fridays", sandboxAPI(HandleAPIListFridays)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/api/v1/fridays/{id:[0-9a-v]+}/rsvps", sandboxAPI(HandleAPIListRSVPs)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/fridays/{id:[0-9a-v]+}/preferences", sandboxAPI(HandleAPIPreferences)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/rsvp/{id}/{action:approve|decline}", sandboxAPI(HandleSandboxReadOnly)).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/rsvp/{id}", sandboxAPI(HandleSandboxReadOnly)).Methods(http.MethodPatch)
+	r.HandleFunc("/api/v1/triggers/{trigger:new_event|new_rsvp|event_full}", sandboxAPI(HandleAPITrigger)).Methods(http.MethodGet)
+	r.HandleFunc("/ifttt/v1/triggers/{trigger:new_event|new_rsvp|event_full}", sandboxAPI(HandleIFTTTTrigger)).Methods(http.MethodPost)
+	r.HandleFunc("/ifttt/v1/status", sandboxAPI(HandleIFTTTStatus)).Methods(http.MethodGet)
+	return r
+}
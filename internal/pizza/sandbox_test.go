@@ -0,0 +1,54 @@
+package pizza_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxStore(t *testing.T) {
+	// GIVEN
+	sandbox := pizza.NewSandboxStore(time.Now())
+
+	// WHEN
+	fridays, err := sandbox.GetUpcomingFridays(30)
+
+	// THEN there are made up events with made up guests
+	require.Nil(t, err)
+	require.Len(t, fridays, 4)
+	for _, friday := range fridays {
+		assert.Equal(t, time.Friday, friday.Start.Weekday())
+		rsvps, err := sandbox.ListFridayRSVPs(friday.ID())
+		assert.Nil(t, err)
+		assert.NotEmpty(t, rsvps)
+		for _, rsvp := range rsvps {
+			assert.True(t, strings.HasSuffix(rsvp.Email, "@example.com"), rsvp.Email)
+		}
+	}
+
+	// THEN nothing can be changed
+	assert.Equal(t, pizza.ErrSandboxReadOnly, sandbox.DeleteFriday(fridays[0].ID()))
+	_, _, err = sandbox.CreateFridayRSVP(pizza.RSVP{Email: "ada@example.com", FridayID: fridays[0].ID()}, 0)
+	assert.Equal(t, pizza.ErrSandboxReadOnly, err)
+}
+
+func TestSandboxListChanges(t *testing.T) {
+	// GIVEN
+	sandbox := pizza.NewSandboxStore(time.Now())
+	all, _, _ := sandbox.ListChanges("", 1000)
+
+	// WHEN
+	first, cursor, _ := sandbox.ListChanges("", 5)
+	rest, _, _ := sandbox.ListChanges(cursor, 1000)
+
+	// THEN the cursor pages through every change once
+	assert.Len(t, first, 5)
+	assert.Equal(t, all, append(first, rest...))
+	recent, err := sandbox.ListRecentChanges(1)
+	assert.NoError(t, err)
+	assert.Equal(t, all[len(all)-1], recent[0])
+}
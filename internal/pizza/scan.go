@@ -0,0 +1,100 @@
+package pizza
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// UploadTypes are the content types accepted for uploads and the file
+// extensions allowed for each.
+var UploadTypes = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+}
+
+// ClamdSocket is the unix socket of a ClamAV daemon that uploads are scanned
+// with. Uploads aren't scanned when it is empty.
+var ClamdSocket = ""
+
+var (
+	ErrUploadType     = errors.New("upload type not allowed")
+	ErrUploadInfected = errors.New("upload failed virus scan")
+)
+
+// CheckUpload rejects an upload unless both its extension and its sniffed
+// content are an allowed image type, and the virus scan passes.
+func CheckUpload(filename string, data []byte) error {
+	contentType := http.DetectContentType(data)
+	ext := strings.ToLower(filepath.Ext(filename))
+	allowed := false
+	for _, e := range UploadTypes[contentType] {
+		allowed = allowed || e == ext
+	}
+	if !allowed {
+		Log.Warn("rejected upload", zap.String("filename", filename), zap.String("contentType", contentType))
+		return ErrUploadType
+	}
+	if len(ClamdSocket) == 0 {
+		return nil
+	}
+	return scanClamd(ClamdSocket, data)
+}
+
+// scanClamd streams the data to clamd with the INSTREAM command.
+func scanClamd(socket string, data []byte) error {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		Log.Error("clamd error", zap.Error(err))
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	if _, err = conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	const chunk = 64 << 10
+	size := make([]byte, 4)
+	for len(data) > 0 {
+		n := len(data)
+		if n > chunk {
+			n = chunk
+		}
+		binary.BigEndian.PutUint32(size, uint32(n))
+		if _, err = conn.Write(append(size, data[:n]...)); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	if _, err = conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 0, 256)
+	buf := make([]byte, 256)
+	for !bytes.Contains(reply, []byte{0}) {
+		n, err := conn.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	result := strings.TrimRight(string(reply), "\x00\n")
+	if strings.HasSuffix(result, "FOUND") {
+		Log.Warn("upload failed virus scan", zap.String("result", result))
+		return ErrUploadInfected
+	} else if !strings.HasSuffix(result, "OK") {
+		Log.Error("clamd error", zap.String("result", result))
+		return errors.New("clamd: " + result)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package pizza_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUpload(t *testing.T) {
+	// GIVEN
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 4))))
+	img := buf.Bytes()
+
+	// WHEN
+	ok := pizza.CheckUpload("pizza.PNG", img)
+	wrongExt := pizza.CheckUpload("pizza.jpg", img)
+	html := pizza.CheckUpload("pizza.png", []byte("<html><script>alert(1)</script></html>"))
+
+	// THEN
+	assert.NoError(t, ok)
+	assert.Equal(t, pizza.ErrUploadType, wrongExt)
+	assert.Equal(t, pizza.ErrUploadType, html)
+}
+
+func TestCheckUploadClamd(t *testing.T) {
+	// GIVEN
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command := make([]byte, len("zINSTREAM\x00"))
+			io.ReadFull(conn, command)
+			var data []byte
+			for {
+				var size uint32
+				if binary.Read(conn, binary.BigEndian, &size) != nil || size == 0 {
+					break
+				}
+				chunk := make([]byte, size)
+				io.ReadFull(conn, chunk)
+				data = append(data, chunk...)
+			}
+			if bytes.Contains(data, []byte("virus")) {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+	pizza.ClamdSocket = socket
+	defer func() { pizza.ClamdSocket = "" }()
+	gif := []byte("GIF89a")
+
+	// WHEN
+	clean := pizza.CheckUpload("pizza.gif", gif)
+	infected := pizza.CheckUpload("pizza.gif", append(gif, []byte("virus")...))
+
+	// THEN
+	assert.NoError(t, clean)
+	assert.Equal(t, pizza.ErrUploadInfected, infected)
+}
@@ -0,0 +1,182 @@
+package pizza
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API scopes. A scope ending in ":*" grants every scope with that prefix, and
+// "admin:*" grants everything.
+const (
+	ScopeReadEvents   = "read:events"
+	ScopeReadRSVPs    = "read:rsvps"
+	ScopeWriteRSVP    = "write:rsvp"
+	ScopeAdminFriends = "admin:friends"
+	ScopeReadMetrics  = "read:metrics"
+	ScopeAdminAll     = "admin:*"
+)
+
+// APIClients are the API keys and the scopes each is allowed.
+var APIClients []APIClientConfig
+
+// APIJWTSecret verifies HS256 JWTs whose "scope" claim lists their scopes.
+// JWTs are not accepted when it is empty.
+var APIJWTSecret []byte
+
+// APIRateLimits are the requests per minute each API client may make with a
+// scope. Scopes without a limit are unlimited.
+var APIRateLimits = map[string]int{
+	ScopeReadEvents:   600,
+	ScopeReadRSVPs:    600,
+	ScopeWriteRSVP:    60,
+	ScopeAdminFriends: 60,
+}
+
+var ErrInvalidJWT = errors.New("invalid token")
+
+// HasScope reports whether the granted scopes include the required one.
+func HasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == required || scope == ScopeAdminAll {
+			return true
+		}
+		if prefix := strings.TrimSuffix(scope, "*"); strings.HasSuffix(scope, ":*") && strings.HasPrefix(required, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateAPI returns the name and scopes of the API client making the
+// request.
+func authenticateAPI(r *http.Request) (string, []string, bool) {
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == auth {
+		// automation services that can't send a bearer token send a key header
+		token = r.Header.Get("X-API-Key")
+		if len(token) == 0 {
+			token = r.Header.Get("IFTTT-Service-Key")
+		}
+	}
+	if len(token) == 0 {
+		return "", nil, false
+	}
+	for i, apiKey := range APIKeys {
+		if len(apiKey) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) == 1 {
+			return "key-" + strconv.Itoa(i), []string{ScopeAdminAll}, true
+		}
+	}
+	for _, client := range APIClients {
+		if len(client.Key) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(client.Key)) == 1 {
+			return client.Name, client.Scopes, true
+		}
+	}
+	if strings.Count(token, ".") == 2 && len(APIJWTSecret) > 0 {
+		sub, scopes, err := VerifyJWT(token, APIJWTSecret, time.Now())
+		if err == nil {
+			return "jwt-" + sub, scopes, true
+		}
+	}
+	return "", nil, false
+}
+
+// VerifyJWT checks an HS256 JWT and returns its subject and scopes. Tokens
+// must expire.
+func VerifyJWT(token string, secret []byte, now time.Time) (string, []string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, ErrInvalidJWT
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", nil, ErrInvalidJWT
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	var claims struct {
+		Sub   string `json:"sub"`
+		Scope string `json:"scope"`
+		Exp   int64  `json:"exp"`
+		Nbf   int64  `json:"nbf"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return "", nil, ErrInvalidJWT
+	}
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(rawClaims, &claims) != nil {
+		return "", nil, ErrInvalidJWT
+	}
+	if claims.Exp == 0 || now.Unix() >= claims.Exp || now.Unix() < claims.Nbf {
+		return "", nil, ErrInvalidJWT
+	}
+	return claims.Sub, strings.Fields(claims.Scope), nil
+}
+
+type apiWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	apiLimitMu sync.Mutex
+	apiWindows = map[string]*apiWindow{}
+)
+
+// allowAPI counts a request by the client with the scope, returning how long
+// to wait when it is over the scope's limit for the minute.
+func allowAPI(client, scope string) (bool, time.Duration) {
+	limit, ok := APIRateLimits[scope]
+	if !ok || limit <= 0 {
+		return true, 0
+	}
+	apiLimitMu.Lock()
+	defer apiLimitMu.Unlock()
+	now := time.Now()
+	key := client + " " + scope
+	window, ok := apiWindows[key]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &apiWindow{start: now}
+		apiWindows[key] = window
+	}
+	if window.count >= limit {
+		return false, window.start.Add(time.Minute).Sub(now)
+	}
+	window.count++
+	return true, 0
+}
+
+// requireScope only lets API clients with the scope through, within the
+// scope's rate limit.
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, scopes, ok := authenticateAPI(r)
+		if !ok {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if !HasScope(scopes, scope) {
+			writeAPIError(w, http.StatusForbidden, "missing scope "+scope)
+			return
+		}
+		if ok, wait := allowAPI(client, scope); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeAPIError(w, http.StatusTooManyRequests, "rate limit exceeded for "+scope)
+			return
+		}
+		next(w, r)
+	}
+}
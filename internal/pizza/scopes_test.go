@@ -0,0 +1,55 @@
+package pizza_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasScope(t *testing.T) {
+	assert.True(t, pizza.HasScope([]string{pizza.ScopeReadEvents}, pizza.ScopeReadEvents))
+	assert.False(t, pizza.HasScope([]string{pizza.ScopeReadEvents}, pizza.ScopeWriteRSVP))
+	assert.True(t, pizza.HasScope([]string{"write:*"}, pizza.ScopeWriteRSVP))
+	assert.False(t, pizza.HasScope([]string{"write:*"}, pizza.ScopeReadEvents))
+	assert.False(t, pizza.HasScope([]string{pizza.ScopeReadEvents}, pizza.ScopeReadRSVPs))
+	assert.True(t, pizza.HasScope([]string{"read:*"}, pizza.ScopeReadRSVPs))
+	assert.True(t, pizza.HasScope([]string{pizza.ScopeAdminAll}, pizza.ScopeReadEvents))
+	assert.False(t, pizza.HasScope(nil, pizza.ScopeReadEvents))
+}
+
+func signJWT(secret, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	// GIVEN
+	now := time.Unix(1700000000, 0)
+	token := signJWT("secret", `{"sub":"pollbot","scope":"read:events write:rsvp","exp":1700000600}`)
+
+	// WHEN
+	sub, scopes, err := pizza.VerifyJWT(token, []byte("secret"), now)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "pollbot", sub)
+	assert.Equal(t, []string{"read:events", "write:rsvp"}, scopes)
+
+	// WHEN
+	_, _, wrongSecret := pizza.VerifyJWT(token, []byte("other"), now)
+	_, _, expired := pizza.VerifyJWT(token, []byte("secret"), now.Add(time.Hour))
+	_, _, noExp := pizza.VerifyJWT(signJWT("secret", `{"sub":"pollbot","scope":"admin:*"}`), []byte("secret"), now)
+
+	// THEN
+	assert.Equal(t, pizza.ErrInvalidJWT, wrongSecret)
+	assert.Equal(t, pizza.ErrInvalidJWT, expired)
+	assert.Equal(t, pizza.ErrInvalidJWT, noExp)
+}
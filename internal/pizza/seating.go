@@ -0,0 +1,343 @@
+package pizza
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// DefaultTableSize is how many guests sit at a table unless the host picks
+// another size when seating them.
+var DefaultTableSize = 8
+
+// SeatingParty is a friend and the guests they bring, who sit together.
+type SeatingParty struct {
+	Email  string
+	Seats  int
+	Groups []string
+}
+
+// AssignTables seats the parties at tables of size, keeping parties that share
+// a group at the same table when they fit and at tables of their own when they
+// don't. The biggest groups are seated first. It returns the emails of the
+// parties at each table.
+func AssignTables(parties []SeatingParty, size int) [][]string {
+	if size < 1 {
+		size = 1
+	}
+	// union parties that share a group
+	parent := make([]int, len(parties))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	seen := map[string]int{}
+	for i, party := range parties {
+		for _, group := range party.Groups {
+			key := strings.ToLower(strings.TrimSpace(group))
+			if len(key) == 0 {
+				continue
+			}
+			if j, ok := seen[key]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				seen[key] = i
+			}
+		}
+	}
+
+	clusters := map[int][]SeatingParty{}
+	seats := map[int]int{}
+	order := []int{}
+	for i, party := range parties {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			order = append(order, root)
+		}
+		clusters[root] = append(clusters[root], party)
+		seats[root] += party.Seats
+	}
+	sort.SliceStable(order, func(i, j int) bool { return seats[order[i]] > seats[order[j]] })
+
+	var tables [][]string
+	var free []int
+	seat := func(first int, party SeatingParty) {
+		for t := first; t < len(tables); t++ {
+			if free[t] >= party.Seats {
+				tables[t] = append(tables[t], party.Email)
+				free[t] -= party.Seats
+				return
+			}
+		}
+		tables = append(tables, []string{party.Email})
+		free = append(free, size-party.Seats)
+	}
+	for _, root := range order {
+		cluster := clusters[root]
+		if seats[root] <= size {
+			t := len(tables)
+			for i := range tables {
+				if free[i] >= seats[root] {
+					t = i
+					break
+				}
+			}
+			if t == len(tables) {
+				tables = append(tables, nil)
+				free = append(free, size)
+			}
+			for _, party := range cluster {
+				seat(t, party)
+			}
+			continue
+		}
+		// too many for one table, so the group gets tables of its own
+		sort.SliceStable(cluster, func(i, j int) bool { return cluster[i].Seats > cluster[j].Seats })
+		first := len(tables)
+		for _, party := range cluster {
+			seat(first, party)
+		}
+	}
+	return tables
+}
+
+// SeatRSVPs matches the saved tables to the confirmed RSVPs. Friends who are no
+// longer coming are left out, along with tables left empty, and the RSVPs not
+// at any table are unseated.
+func SeatRSVPs(tables [][]string, rsvps []RSVP) (seated [][]RSVP, unseated []RSVP) {
+	byEmail := map[string]RSVP{}
+	for _, rsvp := range ConfirmedRSVPs(rsvps) {
+		byEmail[rsvp.Email] = rsvp
+	}
+	for _, table := range tables {
+		var guests []RSVP
+		for _, email := range table {
+			if rsvp, ok := byEmail[email]; ok {
+				guests = append(guests, rsvp)
+				delete(byEmail, email)
+			}
+		}
+		if len(guests) > 0 {
+			seated = append(seated, guests)
+		}
+	}
+	for _, rsvp := range ConfirmedRSVPs(rsvps) {
+		if _, ok := byEmail[rsvp.Email]; ok {
+			unseated = append(unseated, rsvp)
+		}
+	}
+	return seated, unseated
+}
+
+// PlaceCard is the card for one seat. Plus ones and kids are named as the
+// friend's guests.
+type PlaceCard struct {
+	Name    string
+	GuestOf string
+	Table   int
+}
+
+// PlaceCards are the cards for every seat at the tables, table by table.
+// Names are keyed by email.
+func PlaceCards(seated [][]RSVP, names map[string]string) []PlaceCard {
+	var cards []PlaceCard
+	for i, table := range seated {
+		for _, rsvp := range table {
+			name := names[rsvp.Email]
+			if len(name) == 0 {
+				name = rsvp.Email
+			}
+			cards = append(cards, PlaceCard{Name: name, Table: i + 1})
+			for j := 1; j < rsvp.Guests(); j++ {
+				cards = append(cards, PlaceCard{GuestOf: name, Table: i + 1})
+			}
+		}
+	}
+	return cards
+}
+
+type AdminSeatData struct {
+	Name   string
+	Email  string
+	Seats  int
+	Groups []string
+	Table  int
+}
+
+type AdminTableData struct {
+	Number int
+	Seats  int
+	Guests []AdminSeatData
+}
+
+type AdminSeatingPageData struct {
+	AdminView
+	FridayID  string
+	Date      string
+	TableSize int
+	Tables    []AdminTableData
+	Unseated  []AdminSeatData
+	// Options are the table numbers a guest can be moved to, where 0 is
+	// unseated and the last is a new table
+	Options []int
+}
+
+// HandleAdminSeating seats the confirmed guests at tables, either
+// automatically by the groups they are tagged with or by the host moving them
+// one at a time.
+func HandleAdminSeating(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/seating.html")
+	if err != nil {
+		Log.Error("template admin seating failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminSeatingPageData{AdminView: newAdminView(w, r), FridayID: id, Date: FormatTime(friday.Start), TableSize: DefaultTableSize}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		var tables [][]string
+		switch r.PostForm.Get("action") {
+		case "auto":
+			if size, err := strconv.Atoi(r.PostForm.Get("size")); err == nil && size > 0 {
+				data.TableSize = size
+			}
+			var parties []SeatingParty
+			for _, rsvp := range ConfirmedRSVPs(rsvps) {
+				party := SeatingParty{Email: rsvp.Email, Seats: rsvp.Guests()}
+				if party.Groups, err = GetFriendGroups(rsvp.Email); err != nil {
+					Handle500(w, r)
+					return
+				}
+				parties = append(parties, party)
+			}
+			tables = AssignTables(parties, data.TableSize)
+		case "save":
+			byNumber := map[int][]string{}
+			numbers := []int{}
+			for _, rsvp := range ConfirmedRSVPs(rsvps) {
+				n, err := strconv.Atoi(r.PostForm.Get("table:" + rsvp.Email))
+				if err != nil || n <= 0 {
+					continue
+				}
+				if _, ok := byNumber[n]; !ok {
+					numbers = append(numbers, n)
+				}
+				byNumber[n] = append(byNumber[n], rsvp.Email)
+			}
+			sort.Ints(numbers)
+			for _, n := range numbers {
+				tables = append(tables, byNumber[n])
+			}
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		if err = SetFridayTables(id, tables); err != nil {
+			Handle500(w, r)
+			return
+		}
+		friday.Tables = tables
+	}
+
+	seat := func(rsvp RSVP, table int) AdminSeatData {
+		guest := AdminSeatData{Email: rsvp.Email, Seats: rsvp.Guests(), Table: table}
+		guest.Name, _ = GetCachedFriendName(rsvp.Email)
+		guest.Groups, _ = GetFriendGroups(rsvp.Email)
+		return guest
+	}
+	seated, unseated := SeatRSVPs(friday.Tables, rsvps)
+	for i, table := range seated {
+		tableData := AdminTableData{Number: i + 1}
+		for _, rsvp := range table {
+			tableData.Guests = append(tableData.Guests, seat(rsvp, i+1))
+			tableData.Seats += rsvp.Guests()
+		}
+		data.Tables = append(data.Tables, tableData)
+	}
+	for _, rsvp := range unseated {
+		data.Unseated = append(data.Unseated, seat(rsvp, 0))
+	}
+	for n := 0; n <= len(seated)+1; n++ {
+		data.Options = append(data.Options, n)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminPlaceCardsPageData struct {
+	Date  string
+	Cards []PlaceCard
+}
+
+// HandleAdminPlaceCards prints a place card for every seat in the seating
+// plan, rendered from html/admin/placecards.html.
+func HandleAdminPlaceCards(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/placecards.html")
+	if err != nil {
+		Log.Error("template admin place cards failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	friday, err := GetFriday(id)
+	if err != nil {
+		Log.Error("failed to get friday", zap.Error(err), zap.String("id", id))
+		Handle500(w, r)
+		return
+	} else if friday == nil {
+		Handle4xx(w, r)
+		return
+	}
+	rsvps, err := ListFridayRSVPs(id)
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	seated, _ := SeatRSVPs(friday.Tables, rsvps)
+	names := map[string]string{}
+	for _, table := range seated {
+		for _, rsvp := range table {
+			names[rsvp.Email], _ = GetCachedFriendName(rsvp.Email)
+		}
+	}
+	data := AdminPlaceCardsPageData{Date: FormatTime(friday.Start), Cards: PlaceCards(seated, names)}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,53 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignTables(t *testing.T) {
+	// GIVEN two friends from work who fit at one table, a climbing group too
+	// big for one, and a friend who could sit anywhere
+	parties := []pizza.SeatingParty{
+		{Email: "ted@work.com", Seats: 2, Groups: []string{"Work"}},
+		{Email: "anyone@example.com", Seats: 1},
+		{Email: "roy@climb.com", Seats: 3, Groups: []string{"climbing"}},
+		{Email: "keeley@work.com", Seats: 1, Groups: []string{" work"}},
+		{Email: "jamie@climb.com", Seats: 2, Groups: []string{"climbing"}},
+		{Email: "sam@climb.com", Seats: 1, Groups: []string{"climbing"}},
+	}
+
+	// WHEN
+	tables := pizza.AssignTables(parties, 4)
+
+	// THEN the climbers take tables of their own, work sits together, and the
+	// friend without a group fills a gap
+	require.Len(t, tables, 3)
+	assert.Equal(t, []string{"roy@climb.com", "sam@climb.com"}, tables[0])
+	assert.Equal(t, []string{"jamie@climb.com", "anyone@example.com"}, tables[1])
+	assert.Equal(t, []string{"ted@work.com", "keeley@work.com"}, tables[2])
+	assert.Empty(t, pizza.AssignTables(nil, 8))
+}
+
+func TestSeatRSVPs(t *testing.T) {
+	// GIVEN tables saved before one friend cancelled and another RSVPed
+	tables := [][]string{{"ted@lasso.com", "gone@example.com"}, {"gone@example.com"}}
+	rsvps := []pizza.RSVP{
+		{Email: "ted@lasso.com", PlusOnes: 1},
+		{Email: "roy@kent.com"},
+		{Email: "jamie@tartt.com", Status: pizza.RSVPStatusPending},
+	}
+
+	// WHEN
+	seated, unseated := pizza.SeatRSVPs(tables, rsvps)
+	cards := pizza.PlaceCards(seated, map[string]string{"ted@lasso.com": "Ted Lasso"})
+
+	// THEN the empty table is dropped and only confirmed RSVPs are seated
+	require.Len(t, seated, 1)
+	require.Len(t, unseated, 1)
+	assert.Equal(t, "roy@kent.com", unseated[0].Email)
+	assert.Equal(t, []pizza.PlaceCard{{Name: "Ted Lasso", Table: 1}, {GuestOf: "Ted Lasso", Table: 1}}, cards)
+}
@@ -3,106 +3,559 @@ package pizza
 import (
 	"context"
 	"fmt"
+	"html/template"
 	"net/http"
-	"path"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"text/template"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-var StaticDir = "static"
+// StaticDir is a static directory to serve the templates and assets from
+// instead of the ones built into the binary, like while working on them.
+var StaticDir = ""
+
 var EventDuration = time.Hour * 4
+var UpcomingDays = 30
+var BaseURL = ""
 
 type Server struct {
 	s      http.Server
 	config Config
+	matrix *MatrixBot
+	// ctx is done once the server is stopping, which stops the watchers
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce *sync.Once
+	// stopped is closed once the requests in flight are done
+	stopped chan struct{}
 }
 
-func NewServer(config Config) (Server, error) {
-	r := mux.NewRouter()
-	r.HandleFunc("/", HandleIndex)
-	r.HandleFunc("/submit", HandleSubmit)
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(StaticDir))))
-
+func newServer(config Config, handler http.Handler, matrix *MatrixBot) Server {
+	ctx, cancel := context.WithCancel(context.Background())
 	return Server{
 		s: http.Server{
 			Addr:         fmt.Sprintf("0.0.0.0:%d", config.Port),
 			ReadTimeout:  config.ReadTimeout,
 			WriteTimeout: config.WriteTimeout,
-			Handler:      r,
+			Handler:      handler,
 		},
-		config: config,
-	}, nil
+		config:   config,
+		matrix:   matrix,
+		ctx:      ctx,
+		cancel:   cancel,
+		stopOnce: &sync.Once{},
+		stopped:  make(chan struct{}),
+	}
+}
+
+func NewServer(config Config) (Server, error) {
+	UpdateSettings(func(s *RuntimeSettings) {
+		if config.RSVPDeadline > 0 {
+			s.RSVPDeadline = config.RSVPDeadline
+		}
+		if config.MaxPlusOnes > 0 {
+			s.MaxPlusOnes = config.MaxPlusOnes
+		}
+		if config.MaxKids > 0 {
+			s.MaxKids = config.MaxKids
+		}
+		s.DefaultCapacity = config.Capacity
+		s.RSVPOpenWindow = config.RSVPOpenWindow
+		s.Maintenance = config.Maintenance
+	})
+	if config.SlicesPerAdult > 0 {
+		SlicesPerAdult = config.SlicesPerAdult
+	}
+	if config.SlicesPerKid > 0 {
+		SlicesPerKid = config.SlicesPerKid
+	}
+	if config.TableSize > 0 {
+		DefaultTableSize = config.TableSize
+	}
+	SpotNotifyAll = config.SpotNotifications == "all"
+	if config.ClaimWindow > 0 {
+		ClaimWindow = config.ClaimWindow
+	}
+	ToppingOptions = config.Toppings
+	if len(config.DietaryOptions) > 0 {
+		DietaryOptions = config.DietaryOptions
+	}
+	if len(config.DrinkCategories) > 0 {
+		DrinkCategories = config.DrinkCategories
+	}
+	EmailWebhookToken = config.Email.WebhookToken
+	EventsHookSecret = config.EventsHookSecret
+	BaseURL = strings.TrimRight(config.BaseURL, "/")
+	APIKeys = config.APIKeys
+	APIClients = config.APIClients
+	APIJWTSecret = []byte(config.APIJWTSecret)
+	for scope, limit := range config.APIRateLimits {
+		APIRateLimits[scope] = limit
+	}
+	AdminPassword = config.AdminPassword
+	CacheStale = config.CacheStale
+	if config.Calendar.RateLimit > 0 {
+		CalendarRateLimit = config.Calendar.RateLimit
+	}
+	if config.Calendar.Workers > 0 {
+		CalendarWorkers = config.Calendar.Workers
+	}
+	if config.Calendar.QueueLimit > 0 {
+		CalendarQueueLimit = config.Calendar.QueueLimit
+	}
+	if config.Calendar.MaxErrorRate > 0 {
+		CalendarMaxErrorRate = config.Calendar.MaxErrorRate
+	}
+	fridayCache.StaleWhileRevalidate(CacheStale)
+	apiFridaysCache.StaleWhileRevalidate(CacheStale)
+	if config.Probe.Every <= 0 {
+		config.Probe.Every = 5 * time.Minute
+	}
+	if config.Sheets.Every <= 0 {
+		config.Sheets.Every = 10 * time.Minute
+	}
+	ReferralsEnabled = config.Referrals
+	ReferralPlusOnes = config.ReferralPlusOnes
+	DigestExperimentHours = config.Email.DigestHours
+	MirrorCalendarID = config.Calendar.MirrorID
+	ApplyRetention(config.Retention)
+	if err := ApplySessionConfig(config.Session); err != nil {
+		return Server{}, err
+	}
+	Venue = config.Venue
+	Waitlist = config.Waitlist
+	UpdateSettings(func(s *RuntimeSettings) { s.HostEmail = config.HostEmail })
+	if config.MaxBodySize > 0 {
+		MaxBodySize = config.MaxBodySize
+	}
+	if config.SubmitLimits.IP.Burst != 0 {
+		SubmitIPLimit.Burst = config.SubmitLimits.IP.Burst
+	}
+	if config.SubmitLimits.IP.Every > 0 {
+		SubmitIPLimit.Every = config.SubmitLimits.IP.Every
+	}
+	if config.SubmitLimits.Email.Burst != 0 {
+		SubmitEmailLimit.Burst = config.SubmitLimits.Email.Burst
+	}
+	if config.SubmitLimits.Email.Every > 0 {
+		SubmitEmailLimit.Every = config.SubmitLimits.Email.Every
+	}
+	initSettings()
+	if err := LoadAssets(StaticDir); err != nil {
+		Log.Warn("failed to load assets", zap.Error(err))
+	}
+	if len(config.UploadDir) > 0 {
+		blobStore = NewFileBlobStore(config.UploadDir)
+	}
+	if config.Sandbox {
+		store = NewSandboxStore(time.Now())
+		return newServer(config, LimitBody(newSandboxRouter()), nil), nil
+	}
+	if err := LoadTemplates(StaticDir); err != nil {
+		return Server{}, err
+	}
+	imagePool = NewImagePool(config.ImageWorkers)
+	ClamdSocket = config.ClamdSocket
+	initCaptcha(config.Captcha)
+	InitSMS(config.SMS)
+	InitChat(config.Chat)
+	initWebAuthn(BaseURL)
+	initOAuth(config.OAuth)
+	for name, target := range config.Features {
+		for i := range target.Friends {
+			target.Friends[i] = strings.ToLower(target.Friends[i])
+		}
+		FeatureTargets[name] = target
+	}
+	if config.SpamMinFillTime > 0 {
+		SpamMinFillTime = config.SpamMinFillTime
+	}
+	if config.StaticMaxAge > 0 {
+		StaticMaxAge = config.StaticMaxAge
+	}
+	configSnapshot = SnapshotConfig(config)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/", previewBots(HandleIndex))
+	r.HandleFunc("/submit", validate(SubmitSchema, HandleSubmit))
+	r.HandleFunc("/events/{id:[0-9a-v]+}.ics", HandleEventICS)
+	r.HandleFunc("/ical/{token}", HandleICalFeed).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id:[0-9a-v]+}.vcf", HandleEventVCard)
+	r.HandleFunc("/rsvp/{id}/edit", previewBots(HandleEditRSVP)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/cancel", previewBots(HandleCancel)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/transfer", previewBots(HandleTransfer)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/arrival", previewBots(HandleArrival)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/confirm", previewBots(HandleConfirm)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/recap/{id:[0-9a-v]+}", previewBots(HandleRecap)).Methods(http.MethodGet)
+	r.HandleFunc("/claim/{id:[0-9a-v]+}", previewBots(HandleClaim)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/react", HandleReact).Methods(http.MethodPost)
+	r.HandleFunc("/login", HandleLogin).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/login/{provider:[a-z]+}", HandleOAuthLogin).Methods(http.MethodGet)
+	r.HandleFunc("/login/{provider:[a-z]+}/callback", HandleOAuthCallback).Methods(http.MethodGet)
+	r.HandleFunc("/phone", HandlePhone).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/devices", HandleDevices).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/labs", HandleLabs).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/passkeys", HandlePasskeys).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/passkeys/register", HandlePasskeyRegister).Methods(http.MethodPost)
+	r.HandleFunc("/passkeys/login", HandlePasskeyLogin).Methods(http.MethodPost)
+	r.HandleFunc("/aliases", HandleAliases).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/aliases/verify", previewBots(HandleVerifyAlias)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/invite", HandleRequestInvite).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/notify", HandleNotify).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/digest", previewBots(HandleDigest)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/click", HandleClick).Methods(http.MethodGet)
+	r.HandleFunc("/refer", HandleRefer).Methods(http.MethodGet)
+	r.HandleFunc("/join", previewBots(HandleJoin)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/cohost/{id:[0-9a-v]+}", previewBots(HandleCohost)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/login", requireAdminPassword(requireAdminCSRF(HandleAdminLogin))).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/security", requireAdmin(HandleAdminSecurity)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/audit", requireAdmin(HandleAdminAudit)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/transfer", requireAdmin(requireConfirmation("transfer ownership", transferTarget, HandleAdminTransfer))).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/analytics", requireAdmin(HandleAdminAnalytics)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/settings", requireAdmin(HandleAdminSettings)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/content", requireAdmin(HandleAdminContent)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/templates", requireAdmin(HandleAdminTemplates)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/alerts", requireAdmin(HandleAdminAlerts)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/reminders", requireAdmin(HandleAdminReminders)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/search", requireAdmin(HandleAdminSearch)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/friends", requireAdmin(HandleAdminFriends)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/friends/referrals", requireAdmin(HandleAdminReferrals)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/friends/purge", requireAdmin(requireConfirmation("purge friend", purgeFriendTarget, HandleAdminPurgeFriend))).Methods(http.MethodPost)
+	r.HandleFunc("/admin/friends/duplicates", requireAdmin(requireConfirmation("merge friends", mergeTarget, HandleAdminDuplicates))).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/quarantine", requireAdmin(HandleAdminQuarantine)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/fridays", requireAdmin(HandleAdminFridays)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/import", requireAdmin(HandleAdminImport)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/guests", requireAdmin(HandleAdminGuests)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/delete", requireAdmin(requireConfirmation("delete event", deleteFridayTarget, HandleAdminDeleteFriday))).Methods(http.MethodPost)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/cohosts", requireAdmin(HandleAdminCohosts)).Methods(http.MethodPost)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/seating", requireAdmin(HandleAdminSeating)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/cards", requireAdmin(HandleAdminPlaceCards)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/events/{id:[0-9a-v]+}/print", requireAdmin(HandleAdminPrint)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/fridays/{id:[0-9a-v]+}/images", requireAdmin(HandleAdminImages)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/healthz", HandleHealthz).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", HandleReadyz).Methods(http.MethodGet)
+	r.HandleFunc("/metrics", requireScope(ScopeReadMetrics, HandleMetrics)).Methods(http.MethodGet)
+	r.HandleFunc("/debug/config", requireScope(ScopeAdminAll, HandleDebugConfig)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/changes", requireScope(ScopeReadEvents, validate(ChangesSchema, HandleAPIListChanges))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/search", requireScope(ScopeAdminFriends, validate(SearchSchema, HandleAPISearch))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/homeassistant", HandleAPIHomeAssistant).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/fridays", HandleAPIListFridays).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/api/v1/fridays/{id:[0-9a-v]+}/rsvps", requireScope(ScopeReadRSVPs, HandleAPIListRSVPs)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/fridays/{id:[0-9a-v]+}/preferences", requireScope(ScopeReadEvents, HandleAPIPreferences)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/rsvp/{id}/{action:approve|decline}", requireScope(ScopeWriteRSVP, requireAPICode(HandleAPIReviewRSVP))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/rsvp/{id}", validate(PatchRSVPSchema, HandleAPIPatchRSVP)).Methods(http.MethodPatch)
+	r.HandleFunc("/api/v1/triggers/{trigger:new_event|new_rsvp|event_full}", requireScope(ScopeReadEvents, validate(TriggerSchema, HandleAPITrigger))).Methods(http.MethodGet)
+	r.HandleFunc("/ifttt/v1/triggers/{trigger:new_event|new_rsvp|event_full}", requireScope(ScopeReadEvents, HandleIFTTTTrigger)).Methods(http.MethodPost)
+	r.HandleFunc("/ifttt/v1/status", requireScope(ScopeReadEvents, HandleIFTTTStatus)).Methods(http.MethodGet)
+	r.HandleFunc("/hooks/email/{provider}", HandleEmailWebhook).Methods(http.MethodPost)
+	r.HandleFunc("/hooks/inbound/{provider}", HandleInboundEmail).Methods(http.MethodPost)
+	r.HandleFunc("/hooks/events", HandleEventsHook).Methods(http.MethodPost)
+	if store, ok := blobStore.(*FileBlobStore); ok {
+		r.PathPrefix("/uploads/").Handler(store.Handler())
+	}
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", FileServer(StaticDir, fmt.Sprintf("public, max-age=%d", int(StaticMaxAge.Seconds())))))
+	r.Use(LimitSubmit)
+
+	var matrix *MatrixBot
+	if len(config.Matrix.Homeserver) > 0 {
+		matrix = NewMatrixBot(config.Matrix)
+		roomBot = matrix
+	}
+
+	return newServer(config, CheckMaintenance(LimitBody(Sessions(RememberDevice(r)))), matrix), nil
 }
 
+// Start serves until the server is stopped, either by Stop or by the process
+// getting SIGINT or SIGTERM, and returns once the requests in flight are done.
 func (s *Server) Start() error {
-	// watch the calendar to keep credentials renewed and learn when they have expired
-	go s.WatchCalendar(1 * time.Hour)
-	// start the HTTP server
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			Log.Info("shutting down", zap.String("signal", sig.String()))
+			s.Stop()
+		case <-s.ctx.Done():
+		}
+	}()
+
+	if s.config.Sandbox {
+		// nothing real to keep up to date
+		return s.serve()
+	}
+	StartCalendarWorkers(CalendarWorkers, CalendarRateLimit)
+	// check Fauna and the calendar for the probes, which also keeps the
+	// calendar credentials renewed
+	go WatchHealth(s.ctx, 1*time.Minute)
+	if s.matrix != nil {
+		go s.matrix.Run(s.ctx)
+	}
+	if len(s.config.Email.DigestDay) > 0 {
+		day, err := ParseWeekday(s.config.Email.DigestDay)
+		if err != nil {
+			return err
+		}
+		hours := []int{s.config.Email.DigestHour}
+		if len(s.config.Email.DigestHours) > 0 {
+			hours = s.config.Email.DigestHours
+		}
+		go WatchDigest(s.ctx, day, hours)
+	}
+	go WatchSettings(s.ctx, 1*time.Minute)
+	go WatchOwnership(s.ctx, 1*time.Minute)
+	go WatchNotifications(s.ctx, 15*time.Minute)
+	go WatchExpired(s.ctx, time.Hour)
+	go WatchRetention(s.ctx, 24*time.Hour)
+	go WatchPendingInvites(s.ctx, time.Minute)
+	if MirrorEnabled() {
+		go WatchMirror(s.ctx, time.Hour)
+	}
+	if SheetsEnabled() {
+		go WatchSheets(s.ctx, s.config.Sheets.Every)
+	}
+	if len(s.config.Probe.FridayID) > 0 {
+		go WatchProbe(s.ctx, s.config.Probe)
+	}
+	go func() {
+		PublishDiscovery(s.config.MQTT.DiscoveryPrefix)
+		PublishHeadcounts()
+	}()
+	return s.serve()
+}
+
+// serve runs the HTTP server until it is stopped, and waits for the requests
+// in flight.
+func (s *Server) serve() error {
 	if err := s.s.ListenAndServe(); err != http.ErrServerClosed {
 		Log.Error("http listen error", zap.Error(err))
+		s.cancel()
 		return err
 	}
+	<-s.stopped
 	return nil
 }
 
+// Stop stops the watchers and new connections, then waits up to
+// ShutdownTimeout for the requests in flight to finish.
 func (s *Server) Stop() {
-	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
-	defer cancel()
-	s.s.Shutdown(ctx)
+	s.stopOnce.Do(func() {
+		s.cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+		defer cancel()
+		if err := s.s.Shutdown(ctx); err != nil {
+			Log.Warn("requests still running at shutdown", zap.Error(err))
+		}
+		close(s.stopped)
+	})
 }
 
-func (s *Server) WatchCalendar(period time.Duration) {
-	timer := time.NewTimer(period)
-	for {
-		if _, err := ListEvents(1); err != nil {
-			Log.Warn("failed to list calendar events", zap.Error(err))
-		} else {
-			Log.Debug("calendar credentials are valid")
-		}
-		<-timer.C
-		timer.Reset(period)
+// waitTimer waits for the timer, and reports false if ctx is done first.
+func waitTimer(ctx context.Context, timer *time.Timer) bool {
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return false
+	case <-timer.C:
+		return true
 	}
 }
 
+// sleepCtx is time.Sleep that reports false if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	return waitTimer(ctx, time.NewTimer(d))
+}
+
+// FormatTime displays a time in the party's local time zone.
+func FormatTime(t time.Time) string {
+	estZone, _ := time.LoadLocation("America/New_York")
+	return t.In(estZone).Format(time.RFC822)
+}
+
 type IndexFridayData struct {
-	Date   string
-	ID     int64
-	Guests []int
+	Date     string
+	ID       string
+	StartISO string
+	EndISO   string
+	Deadline string
+	Closed   bool
+	Opens    string
+	Full     bool
+	Cover    Image
+	// Announcement is the host's note rendered to HTML
+	Announcement template.HTML
+	Reactions    []ReactionCount
+	Guests       []int
+	// Days are set for multi-day events, so friends can pick some of them
+	Days []EventDay
+	// Countdown is how long until the party, for friends with the countdown
+	// feature
+	Countdown string
 }
 
-type PageData struct {
+type IndexPageData struct {
+	View
 	FridayTimes []IndexFridayData
+	Email       string
+	// InviteHint is who the invite link was sent to, masked
+	InviteHint string
+	// InviteExpired is set when the invite link is too old to use
+	InviteExpired bool
+	FormToken     string
+	Captcha       *CaptchaWidget
+	// Content is the host's copy rendered to HTML by section name
+	Content map[string]template.HTML
+	// LoginLink is shown when friends can log in without an invite link
+	LoginLink bool
+	// Toppings and Diets are offered for the friend's party to pick from
+	Toppings []string
+	Diets    []string
+}
+
+type SubmitRSVPData struct {
+	Date    string
+	EditURL string
+	Pending bool
+	// Queued is set when the calendar invite will be sent later
+	Queued bool
+}
+
+type SubmitPageData struct {
+	RSVPs     []SubmitRSVPData
+	DigestURL string
+	// ICalURL is the friend's calendar feed of their RSVPs
+	ICalURL string
+	// Held is set when the submission looked like spam and waits for review
+	Held bool
+	// ConfirmSentTo is where the link to confirm the RSVP was emailed
+	ConfirmSentTo string
+	// Waitlist is set when RSVPs to full parties are let in automatically
+	Waitlist bool
+}
+
+type EditOptionData struct {
+	Name    string
+	Checked bool
+}
+
+type EditDietData struct {
+	Name  string
+	Count int
+}
+
+type EditAnswerData struct {
+	Question string
+	Answer   string
+}
+
+type EditPageData struct {
+	View
+	ID      string
+	Email   string
+	Sig     string
+	Confirm bool
+	Hint    string
+	// Typed is set when the friend typed their email rather than the
+	// browser being known to be theirs
+	Typed    bool
+	Date     string
+	PlusOnes int
+	Kids     int
+	Toppings []EditOptionData
+	Diets    []EditDietData
+	Answers  []EditAnswerData
+	Closed   bool
+	// Conflict is set when the RSVP changed while the friend was editing it
+	Conflict bool
+	// Full is set when the friend asked to bring more guests than there are
+	// spots left
+	Full     bool
+	RecapURL string
+	// Drinks is set when guests coordinate drinks for the Friday
+	Drinks    []DrinkTally
+	CancelURL string
+	// ArrivalURL is set on the day of the party
+	ArrivalURL string
+	ICalURL    string
 }
 
 func HandleIndex(w http.ResponseWriter, r *http.Request) {
-	plate, err := template.ParseFiles(path.Join(StaticDir, "html/index.html"))
-	if err != nil {
-		Log.Error("template index failure", zap.Error(err))
-		Handle500(w, r)
-		return
+	lite := LiteMode(r)
+	page := "html/index.html"
+	if lite {
+		page = "html/lite.html"
 	}
-	data := PageData{}
+	data := IndexPageData{FormToken: FormToken(), Captcha: CaptchaWidgetFor(), Content: RenderedContent(), Toppings: ToppingOptions, Diets: DietaryOptions}
+	data.Friend = friendFromCookie(r)
+	data.LoginLink = (SMSEnabled() || PasskeysEnabled() || OAuthEnabled()) && len(data.Friend) == 0
 
-	fridays, err := GetCachedFridays(30)
+	// a personal invite link only prefills the email on a browser that has
+	// already proven it belongs to the friend, otherwise they must type it
+	if q := r.URL.Query(); len(q.Get("invite")) > 0 {
+		invite, ok := InviteEmail(q)
+		if !ok {
+			Handle4xx(w, r)
+			return
+		}
+		if LinkExpired(q.Get("expires"), time.Now()) {
+			data.InviteExpired = true
+		} else if data.Friend == invite {
+			data.Email = invite
+		} else {
+			data.InviteHint = MaskEmail(invite)
+		}
+	}
+
+	fridays, err := GetCachedFridays(UpcomingDays)
 	if err != nil {
 		Log.Error("failed to get fridays", zap.Error(err))
 		Handle500(w, r)
 		return
 	}
 
-	estZone, _ := time.LoadLocation("America/New_York")
+	countdown := FeatureOn(FeatureCountdown, data.Friend)
 	data.FridayTimes = make([]IndexFridayData, len(fridays))
-	for i, t := range fridays {
-		t = t.In(estZone)
-		data.FridayTimes[i].Date = t.Format(time.RFC822)
-		data.FridayTimes[i].ID = t.Unix()
+	for i, friday := range fridays {
+		data.FridayTimes[i].Date = FormatTime(friday.Start)
+		data.FridayTimes[i].ID = friday.ID()
+		if friday.MultiDay() {
+			data.FridayTimes[i].Date += " to " + FormatTime(friday.EndTime())
+			data.FridayTimes[i].Days = friday.Days()
+		}
+		data.FridayTimes[i].StartISO = friday.Start.Format(time.RFC3339)
+		data.FridayTimes[i].EndISO = friday.EndTime().Format(time.RFC3339)
+		data.FridayTimes[i].Deadline = FormatTime(friday.Deadline())
+		if countdown {
+			data.FridayTimes[i].Countdown = Countdown(time.Now(), friday.Start)
+		}
+		data.FridayTimes[i].Closed = friday.IsClosed()
+		data.FridayTimes[i].Announcement = template.HTML(RenderBasicMarkdown(FridayAnnouncement(friday)))
+		// the lite page shows no covers or reactions
+		if !lite {
+			data.FridayTimes[i].Cover = friday.Cover
+			if reactions, err := reactionsCache.Get(friday.ID()); err != nil {
+				Log.Warn("failed to get reactions", zap.Error(err), zap.String("eventID", friday.ID()))
+			} else {
+				data.FridayTimes[i].Reactions = CountReactions(reactions, data.Friend)
+			}
+		}
+		if time.Now().Before(friday.Opens()) {
+			data.FridayTimes[i].Opens = FormatTime(friday.Opens())
+		}
+		if full, err := isFull(friday); err != nil {
+			Log.Warn("failed to get friday status", zap.Error(err), zap.String("eventID", friday.ID()))
+		} else {
+			data.FridayTimes[i].Full = full
+		}
 
-		eventID := strconv.FormatInt(data.FridayTimes[i].ID, 10)
+		eventID := friday.ID()
 		if event, err := GetCalendarEvent(eventID); event != nil {
 			data.FridayTimes[i].Guests = make([]int, len(event.Attendees))
 		} else if err != nil {
@@ -113,36 +566,62 @@ func HandleIndex(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err = plate.Execute(w, data); err != nil {
-		Log.Error("template execution failure", zap.Error(err))
-		Handle500(w, r)
-		return
-	}
+	rememberLiteMode(w, r)
+	w.Header().Add("Vary", "Save-Data")
+	render(w, r, page, &data)
 }
 
+// SubmitSchema is what the RSVP form sends. The email may be left out by
+// friends who logged in.
+var SubmitSchema = Schema{Query: []Param{
+	{Name: "date", Required: true},
+	{Name: "email", Email: true},
+	{Name: "plusOnes", Int: true},
+	{Name: "kids", Int: true, Max: func() int { return CurrentSettings().MaxKids }},
+}}
+
 func HandleSubmit(w http.ResponseWriter, r *http.Request) {
-	plate, err := template.ParseFiles(path.Join(StaticDir, "html/submit.html"))
+	plate, err := parseTemplate("html/submit.html")
 	if err != nil {
 		Log.Error("template submit failure", zap.Error(err))
 		Handle500(w, r)
 		return
 	}
-	data := PageData{}
+	data := SubmitPageData{Waitlist: Waitlist}
 
 	Log.Debug("incoming submit request", zap.Stringer("url", r.URL))
 
 	form := r.URL.Query()
-	dates, ok := form["date"]
-	if !ok {
+	if !CheckCaptcha(r, form) {
 		Handle4xx(w, r)
 		return
 	}
-	email := form.Get("email")
+	dates := RequestParams(r, "date")
+	email := RequestParam(r, "email")
+	// friends who logged in RSVP as themselves without typing their email
+	if len(email) == 0 {
+		email = friendFromCookie(r)
+	}
 	if len(email) == 0 {
 		Handle4xx(w, r)
 		return
 	}
 	email = strings.ToLower(email)
+	// RSVPs from a browser known to be the friend's go through, anyone else,
+	// even with an invite link, has to confirm from their inbox first
+	verified := friendFromCookie(r) == email
+	// referrals raise the friend's own limit, so it isn't in the schema
+	plusOnes := RequestInt(r, "plusOnes", 0)
+	if plusOnes > MaxPlusOnesFor(email) {
+		writeInvalid(w, r, []FieldError{{"plusOnes", "must be at most " + strconv.Itoa(MaxPlusOnesFor(email))}})
+		return
+	}
+	kids := RequestInt(r, "kids", 0)
+	prefs, err := ParsePreferences(form, 1+plusOnes+kids)
+	if err != nil {
+		Handle4xx(w, r)
+		return
+	}
 	Log.Debug("rsvp request", zap.String("email", email), zap.Strings("dates", dates))
 
 	if ok, err := IsFriendAllowed(email); !ok {
@@ -154,64 +633,297 @@ func HandleSubmit(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	to := email
+	if email, err = GetCachedPrimaryEmail(email); err != nil {
+		Handle500(w, r)
+		return
+	}
+	verified = verified || friendFromCookie(r) == email
 
-	pendingDates := make([]time.Time, len(dates))
-	for i, d := range dates {
-		num, err := strconv.ParseInt(d, 10, 64)
-		if err != nil {
-			Log.Error("error parsing date int from rsvp form", zap.String("date", d))
+	if reason := CheckSpam(form); len(reason) > 0 {
+		if err = holdSubmission("rsvp", form, reason); err != nil {
 			Handle500(w, r)
 			return
 		}
-		pendingDates[i] = time.Unix(num, 0)
+		data.Held = true
+		if err = executeTemplate(w, plate, data); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+		}
+		return
+	}
 
-		friendName, err := GetCachedFriendName(email)
+	pendingDates := make([]Friday, len(dates))
+	pendingDays := make([][]string, len(dates))
+	for i, d := range dates {
+		friday, ok, err := GetCachedFriday(UpcomingDays, d)
 		if err != nil {
-			Log.Error("could not get friend name", zap.Error(err), zap.String("email", email))
+			Log.Error("failed to get fridays", zap.Error(err))
 			Handle500(w, r)
 			return
+		} else if !ok || !friday.IsOpen() {
+			Log.Debug("rsvp for unknown or closed friday", zap.String("date", d))
+			Handle4xx(w, r)
+			return
 		}
+		pendingDates[i] = friday
+		if pendingDays[i], ok = EventDays(friday, form["day:"+d]); !ok {
+			Log.Debug("rsvp for days the friday isn't on", zap.String("date", d))
+			Handle4xx(w, r)
+			return
+		}
+	}
 
-		event, err := InviteToCalendarEvent(d, pendingDates[i], pendingDates[i].Add(time.Hour+5), friendName, email)
-		if err != nil {
-			Log.Error("invite failed", zap.String("eventID", d), zap.String("email", email))
+	if !verified {
+		if err = sendRSVPConfirmation(to, email, pendingDates, pendingDays, plusOnes, kids, prefs); err != nil {
+			Log.Error("failed to send rsvp confirmation", zap.Error(err), zap.String("email", email))
 			Handle500(w, r)
 			return
 		}
-		Log.Debug("event updated", zap.Any("event", event))
+		data.ConfirmSentTo = MaskEmail(to)
+		if err = executeTemplate(w, plate, data); err != nil {
+			Log.Error("template execution failure", zap.Error(err))
+			Handle500(w, r)
+		}
+		return
+	}
+
+	unlock, ok := LockEmail(email, SubmitLockWait)
+	if !ok {
+		Log.Warn("rsvp still in flight", zap.String("email", email))
+		Handle4xx(w, r)
+		return
 	}
+	defer unlock()
+	if data.RSVPs, err = submitRSVPs(email, pendingDates, pendingDays, plusOnes, kids, prefs); err != nil {
+		Handle500(w, r)
+		return
+	}
+	data.DigestURL = DigestURL(email)
+	data.ICalURL = ICalFeedURL(email)
 
-	if err = plate.Execute(w, data); err != nil {
+	if err = executeTemplate(w, plate, data); err != nil {
 		Log.Error("template execution failure", zap.Error(err))
 		Handle500(w, r)
 		return
 	}
 }
 
-func Handle4xx(w http.ResponseWriter, r *http.Request) {
-	plate, err := template.ParseFiles(path.Join(StaticDir, "html/4xx.html"))
+func HandleEditRSVP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	sig := r.Form.Get("sig")
+	data := EditPageData{ID: id, Sig: sig}
+
+	rsvp, err := GetFridayRSVP(id)
 	if err != nil {
-		Log.Error("template 4xx failure", zap.Error(err))
+		Log.Error("failed to get rsvp", zap.Error(err), zap.String("id", id))
 		Handle500(w, r)
 		return
+	} else if rsvp == nil {
+		// the RSVP expired or was removed, so offer a fresh invite instead
+		http.Redirect(w, r, "/invite?expired=1", http.StatusSeeOther)
+		return
+	} else if !VerifyLink(sig, "rsvp", id, rsvp.Email) {
+		Handle4xx(w, r)
+		return
 	}
-	data := PageData{}
-	if err = plate.Execute(w, data); err != nil {
-		Log.Error("template execution failure", zap.Error(err))
+
+	// edit links may be forwarded, so whoever opens one has to confirm the
+	// email it was sent to unless this browser is already known to be theirs.
+	// Typing it doesn't log the browser in, so the form carries it along.
+	if friendFromCookie(r) != rsvp.Email {
+		email := strings.ToLower(r.Form.Get("email"))
+		if len(email) == 0 {
+			data.Confirm = true
+			data.Hint = MaskEmail(rsvp.Email)
+			render(w, r, "html/edit.html", &data)
+			return
+		} else if email != rsvp.Email {
+			Log.Debug("edit link used by someone else", zap.String("id", id), zap.String("email", email))
+			Handle4xx(w, r)
+			return
+		}
+		data.Typed = true
+	}
+	data.Email = rsvp.Email
+	friday, ok, err := GetCachedFriday(UpcomingDays, rsvp.FridayID)
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
 		Handle500(w, r)
 		return
 	}
+	data.Closed = !ok || friday.IsClosed()
+
+	if r.Method == http.MethodPost && !data.Closed {
+		edit := RSVPEdit{Email: rsvp.Email, Sig: sig, Toppings: r.PostForm["topping"], Answers: make(map[string]string)}
+		if edit.Toppings == nil {
+			edit.Toppings = []string{}
+		}
+		plusOnes, err := strconv.Atoi(r.PostForm.Get("plusOnes"))
+		if err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		edit.PlusOnes = &plusOnes
+		if val := r.PostForm.Get("kids"); len(val) > 0 {
+			kids, err := strconv.Atoi(val)
+			if err != nil {
+				Handle4xx(w, r)
+				return
+			}
+			edit.Kids = &kids
+		}
+		for _, question := range friday.Questions {
+			edit.Answers[question] = r.PostForm.Get("answer:" + question)
+		}
+		for _, diet := range DietaryOptions {
+			if val := r.PostForm.Get("diet:" + diet); len(val) > 0 {
+				n, err := strconv.Atoi(val)
+				if err != nil {
+					Handle4xx(w, r)
+					return
+				}
+				if edit.Diets == nil {
+					edit.Diets = make(map[string]int)
+				}
+				edit.Diets[diet] = n
+			}
+		}
+		for _, category := range friday.Drinks {
+			if val := r.PostForm.Get("drink:" + category); len(val) > 0 {
+				n, err := strconv.Atoi(val)
+				if err != nil {
+					Handle4xx(w, r)
+					return
+				}
+				if edit.Drinks == nil {
+					edit.Drinks = make(map[string]int)
+				}
+				edit.Drinks[category] = n
+			}
+		}
+		updated, err := EditRSVP(id, edit)
+		if err == ErrRSVPClosed {
+			data.Closed = true
+		} else if err == ErrRSVPConflict {
+			data.Conflict = true
+			if latest, err := GetFridayRSVP(id); err == nil && latest != nil {
+				rsvp = latest
+			}
+		} else if err == ErrRSVPFull {
+			data.Full = true
+		} else if err == ErrRSVPInvalid || err == ErrRSVPNotOwner {
+			Handle4xx(w, r)
+			return
+		} else if err != nil {
+			Log.Error("failed to edit rsvp", zap.Error(err), zap.String("id", id))
+			Handle500(w, r)
+			return
+		} else {
+			// back to the edit page, so reloading it doesn't post again
+			addFlash(w, r, "Your RSVP for "+FormatTime(friday.Start)+" has been updated.")
+			http.Redirect(w, r, EditRSVPURL(updated), http.StatusSeeOther)
+			return
+		}
+	}
+
+	if ok {
+		data.Date = FormatTime(friday.Start)
+	} else if past, err := GetFriday(rsvp.FridayID); err == nil && past != nil {
+		data.Date = FormatTime(past.Start)
+		if past.IsOver() {
+			data.RecapURL = RecapURL(*past)
+		}
+	}
+	data.PlusOnes = rsvp.PlusOnes
+	data.Kids = rsvp.Kids
+	data.CancelURL = CancelRSVPURL(*rsvp)
+	data.ICalURL = ICalFeedURL(rsvp.Email)
+	if ok && rsvp.Confirmed() && friday.ArrivalOpen(time.Now()) {
+		data.ArrivalURL = ArrivalURL(*rsvp)
+	}
+	for _, topping := range ToppingOptions {
+		data.Toppings = append(data.Toppings, EditOptionData{topping, containsString(rsvp.Toppings, topping)})
+	}
+	for _, diet := range DietaryOptions {
+		data.Diets = append(data.Diets, EditDietData{diet, rsvp.Diets[diet]})
+	}
+	for _, question := range friday.Questions {
+		data.Answers = append(data.Answers, EditAnswerData{question, rsvp.Answers[question]})
+	}
+	if len(friday.Drinks) > 0 {
+		rsvps, err := ListFridayRSVPs(rsvp.FridayID)
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		names := make(map[string]string)
+		for _, guest := range rsvps {
+			names[guest.Email], _ = GetCachedFriendName(guest.Email)
+		}
+		data.Drinks = TallyDrinks(friday, rsvps, names, rsvp.Email)
+	}
+
+	render(w, r, "html/edit.html", &data)
+}
+
+func HandleEventICS(w http.ResponseWriter, r *http.Request) {
+	friday, ok := lookupFriday(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pizza-%s.ics"`, friday.ID()))
+	if err := WriteICalendar(w, "Pizza Friday", []ICalEvent{FridayICalEvent(friday)}); err != nil {
+		Log.Error("ics write failure", zap.Error(err))
+	}
+}
+
+func HandleEventVCard(w http.ResponseWriter, r *http.Request) {
+	friday, ok := lookupFriday(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pizza-%s.vcf"`, friday.ID()))
+	if err := WriteVCard(w, friday); err != nil {
+		Log.Error("vcard write failure", zap.Error(err))
+	}
+}
+
+// lookupFriday finds the upcoming Friday named in the route, rendering an
+// error page if there isn't one.
+func lookupFriday(w http.ResponseWriter, r *http.Request) (Friday, bool) {
+	friday, ok, err := GetCachedFriday(UpcomingDays, mux.Vars(r)["id"])
+	if err != nil {
+		Log.Error("failed to get fridays", zap.Error(err))
+		Handle500(w, r)
+		return friday, false
+	} else if !ok {
+		Handle4xx(w, r)
+		return friday, false
+	}
+	return friday, true
+}
+
+func Handle4xx(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "html/4xx.html", &ErrorPageData{})
 }
 
+// Handle500 doesn't use render, which renders this page when it fails.
 func Handle500(w http.ResponseWriter, r *http.Request) {
-	plate, err := template.ParseFiles(path.Join(StaticDir, "html/500.html"))
+	plate, err := parseTemplate("html/500.html")
 	if err != nil {
 		Log.Error("template 400 failure", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	data := PageData{}
-	if err = plate.Execute(w, data); err != nil {
+	data := ErrorPageData{}
+	if err = executeTemplate(w, plate, data); err != nil {
 		Log.Error("template execution failure", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -29,7 +29,7 @@ func TestHandleIndex(t *testing.T) {
 func TestHandleSubmit(t *testing.T) {
 	// GIVEN
 	pizza.StaticDir = "../../static"
-	ts := httptest.NewServer(http.HandlerFunc(pizza.HandleSubmit))
+	ts := httptest.NewServer(pizza.Validate(pizza.SubmitSchema, pizza.HandleSubmit))
 	defer ts.Close()
 	url := fmt.Sprintf("%s?date=1672060005&date=1672040005&email=popfizz@foo.com", ts.URL)
 
@@ -0,0 +1,231 @@
+package pizza
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const friendCookieName = "pizza_friend"
+
+// FriendCookieTTL is how long a browser stays logged in as a friend, after
+// which its device token logs it back in.
+var FriendCookieTTL = 24 * time.Hour
+
+var (
+	// CookieSecure sends cookies over https only. When nil they are when
+	// BaseURL is https.
+	CookieSecure *bool
+	// CookieSameSite is set on cookies that don't need their own, which the
+	// admin session and the OAuth state do.
+	CookieSameSite = http.SameSiteLaxMode
+	// CookieDomain shares cookies with subdomains, only the host gets them
+	// when empty.
+	CookieDomain = ""
+	// SessionIdleTimeout logs out a browser that wasn't used for this long,
+	// and forgets its device. It is off when 0.
+	SessionIdleTimeout time.Duration
+)
+
+// sessionTouchEvery is how often a used session's cookie is set again to
+// push back its idle timeout.
+const sessionTouchEvery = time.Minute
+
+// ApplySessionConfig sets the cookie attributes and session timeouts.
+func ApplySessionConfig(config SessionConfig) error {
+	switch strings.ToLower(config.SameSite) {
+	case "", "lax":
+		CookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		if config.Secure != nil && !*config.Secure {
+			return fmt.Errorf("session.sameSite none needs secure cookies")
+		}
+		CookieSameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("unknown session.sameSite %q", config.SameSite)
+	}
+	CookieSecure = config.Secure
+	CookieDomain = config.Domain
+	if config.Lifetime > 0 {
+		FriendCookieTTL = config.Lifetime
+	}
+	SessionIdleTimeout = config.IdleTimeout
+	return nil
+}
+
+// newCookie is an HttpOnly cookie with the configured attributes.
+func newCookie(name, value, path string, expires time.Time) *http.Cookie {
+	secure := strings.HasPrefix(BaseURL, "https://") || CookieSameSite == http.SameSiteNoneMode
+	if CookieSecure != nil {
+		secure = *CookieSecure
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   CookieDomain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: CookieSameSite,
+	}
+}
+
+// clearCookie removes the cookie, which must be for the same domain and path
+// it was set with.
+func clearCookie(w http.ResponseWriter, name, path string) {
+	cookie := newCookie(name, "", path, time.Time{})
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// replaceRequestCookie changes the cookie for the rest of the request, or
+// removes it when c is nil.
+func replaceRequestCookie(r *http.Request, name string, c *http.Cookie) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			r.AddCookie(cookie)
+		}
+	}
+	if c != nil {
+		r.AddCookie(&http.Cookie{Name: name, Value: c.Value})
+	}
+}
+
+// friendSession is a browser logged in as a friend. Its ID changes whenever
+// the browser logs in, so tokens tied to it, like the CSRF token, don't
+// outlive the login they were given to.
+type friendSession struct {
+	Email  string
+	ID     string
+	Issued time.Time
+	// Seen is when the browser last used the session
+	Seen time.Time
+}
+
+func newFriendSession(email string) friendSession {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate session id: %v", err))
+	}
+	now := time.Now()
+	return friendSession{Email: email, ID: base64.RawURLEncoding.EncodeToString(buf), Issued: now, Seen: now}
+}
+
+func (s friendSession) fields() []string {
+	return []string{s.ID, strconv.FormatInt(s.Issued.Unix(), 10), strconv.FormatInt(s.Seen.Unix(), 10)}
+}
+
+func (s friendSession) cookie() *http.Cookie {
+	fields := s.fields()
+	value := url.QueryEscape(s.Email) + "." + strings.Join(fields, ".") + "." + SignLink("friend", append([]string{s.Email}, fields...)...)
+	return newCookie(friendCookieName, value, "/", s.Issued.Add(FriendCookieTTL))
+}
+
+// idle reports whether the browser wasn't used for longer than the idle
+// timeout.
+func (s friendSession) idle(now time.Time) bool {
+	return SessionIdleTimeout > 0 && now.Sub(s.Seen) > SessionIdleTimeout
+}
+
+// parseFriendSession reads the session from its cookie, whether or not it ran
+// out.
+func parseFriendSession(value string) (friendSession, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 5 {
+		return friendSession{}, false
+	}
+	n := len(parts)
+	email, err := url.QueryUnescape(strings.Join(parts[:n-4], "."))
+	if err != nil || !VerifyLink(parts[n-1], "friend", email, parts[n-4], parts[n-3], parts[n-2]) {
+		return friendSession{}, false
+	}
+	issued, err := strconv.ParseInt(parts[n-3], 10, 64)
+	if err != nil {
+		return friendSession{}, false
+	}
+	seen, err := strconv.ParseInt(parts[n-2], 10, 64)
+	if err != nil {
+		return friendSession{}, false
+	}
+	return friendSession{Email: email, ID: parts[n-4], Issued: time.Unix(issued, 0), Seen: time.Unix(seen, 0)}, true
+}
+
+// sessionFromCookie is the browser's session, if it has one that hasn't run
+// out.
+func sessionFromCookie(r *http.Request) (friendSession, bool) {
+	cookie, err := r.Cookie(friendCookieName)
+	if err != nil {
+		return friendSession{}, false
+	}
+	session, ok := parseFriendSession(cookie.Value)
+	now := time.Now()
+	if !ok || now.Sub(session.Issued) > FriendCookieTTL || session.idle(now) {
+		return friendSession{}, false
+	}
+	return session, true
+}
+
+func friendFromCookie(r *http.Request) string {
+	session, _ := sessionFromCookie(r)
+	return session.Email
+}
+
+// setFriendCookie remembers that this browser has proven it belongs to the
+// friend, so their personal links skip the email confirmation next time. It
+// starts a new session, and returns it for the rest of the request.
+func setFriendCookie(w http.ResponseWriter, email string) *http.Cookie {
+	cookie := newFriendSession(email).cookie()
+	http.SetCookie(w, cookie)
+	return cookie
+}
+
+func clearFriendCookie(w http.ResponseWriter) {
+	clearCookie(w, friendCookieName, "/")
+}
+
+// rotateSession gives the browser's session a new ID when it gains a
+// privilege, like the admin pages, so nothing tied to the old one carries
+// over.
+func rotateSession(w http.ResponseWriter, r *http.Request) {
+	if email := friendFromCookie(r); len(email) > 0 {
+		replaceRequestCookie(r, friendCookieName, setFriendCookie(w, email))
+	}
+}
+
+// Sessions logs out browsers that weren't used for the idle timeout, and
+// pushes it back for those that are. A browser that timed out is not logged
+// back in by its device token, which is forgotten.
+func Sessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(friendCookieName)
+		if err != nil || SessionIdleTimeout <= 0 || strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		session, ok := parseFriendSession(cookie.Value)
+		now := time.Now()
+		if ok && session.idle(now) {
+			Log.Info("session timed out", zap.String("email", session.Email))
+			forgetDevice(w, r)
+			clearFriendCookie(w)
+			replaceRequestCookie(r, friendCookieName, nil)
+			replaceRequestCookie(r, deviceCookieName, nil)
+		} else if ok && now.Sub(session.Seen) > sessionTouchEvery && now.Sub(session.Issued) <= FriendCookieTTL {
+			session.Seen = now
+			http.SetCookie(w, session.cookie())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestApplySessionConfig(t *testing.T) {
+	defer pizza.ApplySessionConfig(pizza.SessionConfig{})
+	secure, insecure := true, false
+
+	// THEN
+	assert.NotNil(t, pizza.ApplySessionConfig(pizza.SessionConfig{SameSite: "sometimes"}))
+	assert.NotNil(t, pizza.ApplySessionConfig(pizza.SessionConfig{SameSite: "none", Secure: &insecure}))
+
+	// WHEN
+	require.Nil(t, pizza.ApplySessionConfig(pizza.SessionConfig{SameSite: "strict", Domain: "rsvp.pizza", Secure: &secure}))
+	w := httptest.NewRecorder()
+	pizza.AddFlash(w, httptest.NewRequest(http.MethodPost, "/labs", nil), "Saved.")
+
+	// THEN every cookie gets the configured attributes
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "rsvp.pizza", cookies[0].Domain)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+}
+
+func TestSessionsIdleTimeout(t *testing.T) {
+	// GIVEN
+	pizza.SessionIdleTimeout = time.Hour
+	defer func() { pizza.SessionIdleTimeout = 0 }()
+	var loggedIn bool
+	handler := pizza.Sessions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := r.Cookie("pizza_friend")
+		loggedIn = err == nil
+	}))
+	serve := func(seen time.Time) *http.Response {
+		session := pizza.NewFriendSession("believe@tedlasso.com")
+		session.Seen = seen
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(pizza.FriendSessionCookie(session))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	// WHEN the browser was last used a few minutes ago
+	resp := serve(time.Now().Add(-5 * time.Minute))
+
+	// THEN its idle timeout is pushed back
+	assert.True(t, loggedIn)
+	require.Len(t, resp.Cookies(), 1)
+	assert.Equal(t, "pizza_friend", resp.Cookies()[0].Name)
+	assert.NotEmpty(t, resp.Cookies()[0].Value)
+
+	// WHEN the browser wasn't used for longer than the timeout
+	resp = serve(time.Now().Add(-2 * time.Hour))
+
+	// THEN it is logged out
+	assert.False(t, loggedIn)
+	require.Len(t, resp.Cookies(), 1)
+	assert.Equal(t, "pizza_friend", resp.Cookies()[0].Name)
+	assert.Less(t, resp.Cookies()[0].MaxAge, 0)
+}
@@ -0,0 +1,273 @@
+package pizza
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RuntimeSettings are what can change without editing the config file: what
+// the host overrides from the admin page, and who the host is once the series
+// is transferred. They change while requests read them, so they are only read
+// with CurrentSettings and changed with UpdateSettings.
+type RuntimeSettings struct {
+	// DefaultCapacity is the guest limit for events without their own
+	// capacity. RSVPs over the limit wait for the host's approval. 0 means no
+	// limit.
+	DefaultCapacity int
+	MaxPlusOnes     int
+	// MaxKids is how many children each guest may bring.
+	MaxKids      int
+	RSVPDeadline time.Duration
+	// RSVPOpenWindow is how long before an event RSVPs open. 0 means RSVPs
+	// are open as soon as the event is scheduled.
+	RSVPOpenWindow time.Duration
+	// Maintenance puts the site in maintenance mode, where every page but the
+	// admin pages shows a maintenance page.
+	Maintenance bool
+	// The banner is a note from the host shown at the top of every page, like
+	// a new address this week. It is hidden while the message is empty or
+	// after it expires.
+	BannerMessage string
+	BannerLevel   string
+	BannerExpires time.Time
+	// HostEmail is where the host is emailed headcount alerts. Alerts are off
+	// when it is empty.
+	HostEmail string
+}
+
+var (
+	runtimeSettings atomic.Pointer[RuntimeSettings]
+	// settingsMu keeps updates from undoing each other
+	settingsMu sync.Mutex
+)
+
+func init() {
+	runtimeSettings.Store(&RuntimeSettings{MaxPlusOnes: 3, MaxKids: 4, BannerLevel: BannerInfo})
+}
+
+// CurrentSettings are the settings as they are now.
+func CurrentSettings() RuntimeSettings {
+	return *runtimeSettings.Load()
+}
+
+// UpdateSettings changes a copy of the settings and swaps it in, so readers
+// see either all of the change or none of it.
+func UpdateSettings(update func(s *RuntimeSettings)) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	s := CurrentSettings()
+	update(&s)
+	runtimeSettings.Store(&s)
+}
+
+// Setting is one of the RuntimeSettings, as the admin page shows it.
+type Setting struct {
+	Name  string
+	Help  string
+	get   func(s RuntimeSettings) string
+	parse func(string) (func(s *RuntimeSettings), error)
+}
+
+var ErrSettingInvalid = errors.New("invalid setting")
+
+// Settings are the settings that can be overridden.
+var Settings = []Setting{
+	countSetting("capacity", "Guests before RSVPs need approval, 0 for no limit",
+		func(s *RuntimeSettings) *int { return &s.DefaultCapacity }),
+	countSetting("maxPlusOnes", "Friends each guest may bring",
+		func(s *RuntimeSettings) *int { return &s.MaxPlusOnes }),
+	countSetting("maxKids", "Kids each guest may bring",
+		func(s *RuntimeSettings) *int { return &s.MaxKids }),
+	durationSetting("rsvpDeadline", "How long before the party RSVPs close, e.g. 2h",
+		func(s *RuntimeSettings) *time.Duration { return &s.RSVPDeadline }),
+	durationSetting("rsvpOpens", "How long before the party RSVPs open, 0s to always be open",
+		func(s *RuntimeSettings) *time.Duration { return &s.RSVPOpenWindow }),
+	boolSetting("maintenance", "Show a maintenance page on everything but the admin pages, true or false",
+		func(s *RuntimeSettings) *bool { return &s.Maintenance }),
+	stringSetting("bannerMessage", "Note shown at the top of every page in basic markdown, blank for none",
+		func(s *RuntimeSettings) *string { return &s.BannerMessage }, nil),
+	stringSetting("bannerLevel", "How the banner looks, info or warning",
+		func(s *RuntimeSettings) *string { return &s.BannerLevel }, func(s string) bool {
+			return s == BannerInfo || s == BannerWarning
+		}),
+	timeSetting("bannerExpires", "When the banner stops showing in New York time, e.g. 2023-04-08T00:00, blank to keep it",
+		func(s *RuntimeSettings) *time.Time { return &s.BannerExpires }),
+}
+
+// Each kind of setting takes the field of RuntimeSettings it reads and sets.
+
+func countSetting(name, help string, field func(s *RuntimeSettings) *int) Setting {
+	return Setting{
+		Name: name,
+		Help: help,
+		get:  func(s RuntimeSettings) string { return strconv.Itoa(*field(&s)) },
+		parse: func(v string) (func(s *RuntimeSettings), error) {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, ErrSettingInvalid
+			}
+			return func(s *RuntimeSettings) { *field(s) = n }, nil
+		},
+	}
+}
+
+func durationSetting(name, help string, field func(s *RuntimeSettings) *time.Duration) Setting {
+	return Setting{
+		Name: name,
+		Help: help,
+		get:  func(s RuntimeSettings) string { return field(&s).String() },
+		parse: func(v string) (func(s *RuntimeSettings), error) {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, ErrSettingInvalid
+			}
+			return func(s *RuntimeSettings) { *field(s) = d }, nil
+		},
+	}
+}
+
+func boolSetting(name, help string, field func(s *RuntimeSettings) *bool) Setting {
+	return Setting{
+		Name: name,
+		Help: help,
+		get:  func(s RuntimeSettings) string { return strconv.FormatBool(*field(&s)) },
+		parse: func(v string) (func(s *RuntimeSettings), error) {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, ErrSettingInvalid
+			}
+			return func(s *RuntimeSettings) { *field(s) = b }, nil
+		},
+	}
+}
+
+func stringSetting(name, help string, field func(s *RuntimeSettings) *string, valid func(string) bool) Setting {
+	return Setting{
+		Name: name,
+		Help: help,
+		get:  func(s RuntimeSettings) string { return *field(&s) },
+		parse: func(v string) (func(s *RuntimeSettings), error) {
+			if valid != nil && !valid(v) {
+				return nil, ErrSettingInvalid
+			}
+			return func(s *RuntimeSettings) { *field(s) = v }, nil
+		},
+	}
+}
+
+// timeSetting is a time in New York, or the zero time when blank.
+func timeSetting(name, help string, field func(s *RuntimeSettings) *time.Time) Setting {
+	return Setting{
+		Name: name,
+		Help: help,
+		get: func(s RuntimeSettings) string {
+			if t := *field(&s); !t.IsZero() {
+				estZone, _ := time.LoadLocation("America/New_York")
+				return t.In(estZone).Format("2006-01-02T15:04")
+			}
+			return ""
+		},
+		parse: func(v string) (func(s *RuntimeSettings), error) {
+			if len(v) == 0 {
+				return func(s *RuntimeSettings) { *field(s) = time.Time{} }, nil
+			}
+			estZone, _ := time.LoadLocation("America/New_York")
+			t, err := time.ParseInLocation("2006-01-02T15:04", v, estZone)
+			if err != nil {
+				return nil, ErrSettingInvalid
+			}
+			return func(s *RuntimeSettings) { *field(s) = t }, nil
+		},
+	}
+}
+
+// Value is the setting's current value.
+func (s Setting) Value() string {
+	return s.get(CurrentSettings())
+}
+
+// fileSettings are the values from the config file, restored when an override
+// is removed.
+var fileSettings = map[string]string{}
+
+var settingsCache *Cache[map[string]string]
+
+// initSettings remembers the config file values and loads any overrides.
+func initSettings() {
+	current := CurrentSettings()
+	for _, setting := range Settings {
+		fileSettings[setting.Name] = setting.get(current)
+	}
+	c := NewCache(time.Minute, func(string) (map[string]string, error) { return GetSettings() })
+	settingsCache = &c
+}
+
+func findSetting(name string) (Setting, bool) {
+	for _, setting := range Settings {
+		if setting.Name == name {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// ApplySettings sets each setting to its override, or back to the config file
+// value when it has none, all at once. Invalid values leave the setting as it
+// is.
+func ApplySettings(overrides map[string]string) {
+	UpdateSettings(func(s *RuntimeSettings) {
+		for _, setting := range Settings {
+			value, ok := overrides[setting.Name]
+			if !ok {
+				value = fileSettings[setting.Name]
+			}
+			apply, err := setting.parse(value)
+			if err != nil {
+				Log.Warn("ignoring invalid setting", zap.String("name", setting.Name), zap.String("value", value))
+				continue
+			}
+			apply(s)
+		}
+	})
+}
+
+// SaveSettingOverrides stores the overrides and applies them right away.
+func SaveSettingOverrides(overrides map[string]string) error {
+	for name, value := range overrides {
+		setting, ok := findSetting(name)
+		if !ok {
+			return ErrSettingInvalid
+		}
+		if _, err := setting.parse(value); err != nil {
+			return err
+		}
+	}
+	if err := SaveSettings(overrides); err != nil {
+		return err
+	}
+	settingsCache.Store("runtime", overrides)
+	ApplySettings(overrides)
+	return nil
+}
+
+// WatchSettings picks up overrides saved by other instances.
+func WatchSettings(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		if overrides, err := settingsCache.Get("runtime"); err != nil {
+			Log.Warn("failed to load settings", zap.Error(err))
+		} else {
+			ApplySettings(overrides)
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
@@ -0,0 +1,65 @@
+package pizza_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySettings(t *testing.T) {
+	// GIVEN
+	defer pizza.SetSettings(func(*pizza.RuntimeSettings) {})()
+	_, err := pizza.NewServer(pizza.Config{Capacity: 10, MaxPlusOnes: 2, RSVPDeadline: time.Hour})
+	require.Nil(t, err)
+
+	// WHEN
+	pizza.ApplySettings(map[string]string{"capacity": "20", "rsvpDeadline": "3h", "maxPlusOnes": "-1"})
+
+	// THEN
+	settings := pizza.CurrentSettings()
+	assert.Equal(t, 20, settings.DefaultCapacity)
+	assert.Equal(t, 3*time.Hour, settings.RSVPDeadline)
+	assert.Equal(t, 2, settings.MaxPlusOnes)
+
+	// WHEN
+	pizza.ApplySettings(map[string]string{})
+
+	// THEN
+	settings = pizza.CurrentSettings()
+	assert.Equal(t, 10, settings.DefaultCapacity)
+	assert.Equal(t, time.Hour, settings.RSVPDeadline)
+}
+
+func TestApplySettingsWhileReading(t *testing.T) {
+	// GIVEN requests reading the settings
+	defer pizza.SetSettings(func(*pizza.RuntimeSettings) {})()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pizza.RenderBanner(time.Now())
+				pizza.Friday{Start: time.Now()}.IsOpen()
+			}
+		}
+	}()
+
+	// WHEN the settings change under them, like from WatchSettings and the admin page
+	for i := 1; i <= 100; i++ {
+		pizza.ApplySettings(map[string]string{"capacity": strconv.Itoa(i), "bannerMessage": "party #" + strconv.Itoa(i)})
+	}
+	close(stop)
+	<-done
+
+	// THEN the last change wins, and go test -race finds no data race
+	assert.Equal(t, 100, pizza.Friday{}.Limit())
+	assert.Contains(t, pizza.RenderBanner(time.Now()), "party #100")
+}
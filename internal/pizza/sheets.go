@@ -0,0 +1,253 @@
+package pizza
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// sheetsSrv writes attendee lists to SheetsSpreadsheetID, a tab per party.
+// Syncing is off when either is empty.
+var (
+	sheetsSrv           *sheets.Service
+	SheetsSpreadsheetID = ""
+	// SheetsColumns are the columns the host fills in on each tab, like
+	// "Paid?", which are read back into the RSVPs
+	SheetsColumns = []string{}
+)
+
+// sheetsColumns are the columns written for each guest, before the host's.
+var sheetsColumns = []string{"Email", "Name", "Guests", "Kids", "Status", "Checked in"}
+
+func SheetsEnabled() bool {
+	return sheetsSrv != nil && len(SheetsSpreadsheetID) > 0
+}
+
+// InitSheetsClient connects to Google Sheets with the calendar's credentials,
+// whose token must also have been granted the spreadsheets scope.
+func InitSheetsClient(credentialFile, tokenFile string, config SheetsConfig, ctx context.Context) error {
+	if len(config.SpreadsheetID) == 0 {
+		return nil
+	}
+	b, err := os.ReadFile(credentialFile)
+	if err != nil {
+		return err
+	}
+	oauthConfig, err := google.ConfigFromJSON(b, sheets.SpreadsheetsScope)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(tokenFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tok := &oauth2.Token{}
+	if err = json.NewDecoder(f).Decode(tok); err != nil {
+		return err
+	}
+	srv, err := sheets.NewService(ctx, option.WithHTTPClient(oauthConfig.Client(context.Background(), tok)))
+	if err != nil {
+		return err
+	}
+	sheetsSrv = srv
+	SheetsSpreadsheetID = config.SpreadsheetID
+	SheetsColumns = config.Columns
+	return nil
+}
+
+// SheetTitle is the name of a party's tab.
+func SheetTitle(friday Friday) string {
+	estZone, _ := time.LoadLocation("America/New_York")
+	return friday.Start.In(estZone).Format("2006-01-02") + " " + friday.ID()
+}
+
+// SheetRows are a party's tab: a header, then a row for each guest with what
+// the host entered for them in the host's columns.
+func SheetRows(rsvps []RSVP, names map[string]string) [][]interface{} {
+	header := []interface{}{}
+	for _, column := range append(sheetsColumns, SheetsColumns...) {
+		header = append(header, column)
+	}
+	rows := [][]interface{}{header}
+	for _, rsvp := range rsvps {
+		row := []interface{}{rsvp.Email, names[rsvp.Email], rsvp.Guests(), rsvp.Kids, rsvp.Status, rsvp.CheckedIn}
+		if len(rsvp.Status) == 0 {
+			row[4] = RSVPStatusConfirmed
+		}
+		for _, column := range SheetsColumns {
+			row = append(row, rsvp.Sheet[column])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ReadSheetColumns finds what the host entered in their columns of a tab, by
+// guest email. Columns are found by their header, so the host may reorder
+// them, and guests without anything entered are left out.
+func ReadSheetColumns(rows [][]interface{}) map[string]map[string]string {
+	entered := make(map[string]map[string]string)
+	if len(rows) == 0 {
+		return entered
+	}
+	emailAt := -1
+	columnsAt := make(map[int]string)
+	for i, cell := range rows[0] {
+		header := strings.TrimSpace(sheetCell(cell))
+		if header == "Email" {
+			emailAt = i
+		} else if containsString(SheetsColumns, header) {
+			columnsAt[i] = header
+		}
+	}
+	if emailAt < 0 {
+		return entered
+	}
+	for _, row := range rows[1:] {
+		if emailAt >= len(row) {
+			continue
+		}
+		email := strings.TrimSpace(sheetCell(row[emailAt]))
+		for i, column := range columnsAt {
+			if i >= len(row) || len(sheetCell(row[i])) == 0 {
+				continue
+			}
+			if entered[email] == nil {
+				entered[email] = make(map[string]string)
+			}
+			entered[email][column] = sheetCell(row[i])
+		}
+	}
+	return entered
+}
+
+func sheetCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// sameSheetColumns reports whether the RSVP already has what was entered.
+func sameSheetColumns(rsvp RSVP, entered map[string]string) bool {
+	for _, column := range SheetsColumns {
+		if rsvp.Sheet[column] != entered[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// SyncFridaySheet reads back what the host entered on the party's tab, then
+// writes its attendee list there, adding the tab if it's new.
+func SyncFridaySheet(friday Friday) error {
+	if !SheetsEnabled() {
+		return nil
+	}
+	title := SheetTitle(friday)
+	if err := addSheetTab(title); err != nil {
+		return err
+	}
+	var current *sheets.ValueRange
+	err := callCalendar(func() (err error) {
+		current, err = sheetsSrv.Spreadsheets.Values.Get(SheetsSpreadsheetID, title).Do()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return err
+	}
+	entered := ReadSheetColumns(current.Values)
+	names := make(map[string]string)
+	for i, rsvp := range rsvps {
+		if names[rsvp.Email], err = GetCachedFriendName(rsvp.Email); err != nil {
+			return err
+		}
+		if sameSheetColumns(rsvp, entered[rsvp.Email]) {
+			continue
+		}
+		rsvp.Sheet = entered[rsvp.Email]
+		if err = UpdateFridayRSVP(rsvp); err == ErrRSVPConflict {
+			// the friend changed it meanwhile, it's read again next time
+			continue
+		} else if err != nil {
+			return err
+		}
+		rsvps[i] = rsvp
+	}
+	rows := SheetRows(rsvps, names)
+	// blank out guests who are no longer coming
+	for len(rows) < len(current.Values) {
+		rows = append(rows, make([]interface{}, len(rows[0])))
+		for i := range rows[len(rows)-1] {
+			rows[len(rows)-1][i] = ""
+		}
+	}
+	return callCalendar(func() error {
+		_, err := sheetsSrv.Spreadsheets.Values.Update(SheetsSpreadsheetID, title, &sheets.ValueRange{Values: rows}).
+			ValueInputOption("RAW").Do()
+		return err
+	})
+}
+
+// addSheetTab adds a tab with the title to the spreadsheet unless there is one.
+func addSheetTab(title string) error {
+	var spreadsheet *sheets.Spreadsheet
+	err := callCalendar(func() (err error) {
+		spreadsheet, err = sheetsSrv.Spreadsheets.Get(SheetsSpreadsheetID).Fields("sheets.properties.title").Do()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties != nil && sheet.Properties.Title == title {
+			return nil
+		}
+	}
+	return callCalendar(func() error {
+		_, err := sheetsSrv.Spreadsheets.BatchUpdate(SheetsSpreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+			Requests: []*sheets.Request{{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: title}}}},
+		}).Do()
+		return err
+	})
+}
+
+// WatchSheets syncs every upcoming party's tab, through the same queue as the
+// calendar so the Google quota is shared.
+func WatchSheets(ctx context.Context, period time.Duration) {
+	timer := time.NewTimer(period)
+	for {
+		if fridays, err := GetCachedFridays(UpcomingDays); err != nil {
+			Log.Warn("failed to get fridays to sync", zap.Error(err))
+		} else {
+			for _, friday := range fridays {
+				if err = SyncFridaySheet(friday); err != nil {
+					Log.Warn("failed to sync sheet", zap.Error(err), zap.String("eventID", friday.ID()))
+				}
+			}
+		}
+		if !waitTimer(ctx, timer) {
+			return
+		}
+		timer.Reset(period)
+	}
+}
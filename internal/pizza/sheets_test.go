@@ -0,0 +1,47 @@
+package pizza_test
+
+import (
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetRows(t *testing.T) {
+	// GIVEN
+	pizza.SheetsColumns = []string{"Paid?"}
+	defer func() { pizza.SheetsColumns = []string{} }()
+	rsvps := []pizza.RSVP{
+		{Email: "believe@tedlasso.com", PlusOnes: 1, Sheet: map[string]string{"Paid?": "yes"}},
+		{Email: "roy@afcrichmond.com", Status: pizza.RSVPStatusPending},
+	}
+	names := map[string]string{"believe@tedlasso.com": "Ted Lasso", "roy@afcrichmond.com": "Roy Kent"}
+
+	// WHEN
+	rows := pizza.SheetRows(rsvps, names)
+
+	// THEN the host's columns come after the guests'
+	assert.Equal(t, [][]interface{}{
+		{"Email", "Name", "Guests", "Kids", "Status", "Checked in", "Paid?"},
+		{"believe@tedlasso.com", "Ted Lasso", 2, 0, pizza.RSVPStatusConfirmed, false, "yes"},
+		{"roy@afcrichmond.com", "Roy Kent", 1, 0, pizza.RSVPStatusPending, false, ""},
+	}, rows)
+}
+
+func TestReadSheetColumns(t *testing.T) {
+	// GIVEN the host moved their column and added one of their own
+	pizza.SheetsColumns = []string{"Paid?"}
+	defer func() { pizza.SheetsColumns = []string{} }()
+	rows := [][]interface{}{
+		{"Paid?", "Email", "Name", "Scratch"},
+		{"yes", "believe@tedlasso.com", "Ted Lasso", "x"},
+		{"", "roy@afcrichmond.com", "Roy Kent"},
+		{float64(12)},
+	}
+
+	// WHEN
+	entered := pizza.ReadSheetColumns(rows)
+
+	// THEN only the configured columns of guests are read back
+	assert.Equal(t, map[string]map[string]string{"believe@tedlasso.com": {"Paid?": "yes"}}, entered)
+}
@@ -0,0 +1,119 @@
+package pizza
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Twilio sends text messages through the Twilio REST API.
+type Twilio struct {
+	// URL is the API root, it is only changed in tests
+	URL        string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+var sms *Twilio
+
+var ErrSMSDisabled = errors.New("sms is not configured")
+
+func NewTwilio(config SMSConfig) *Twilio {
+	return &Twilio{
+		URL:        "https://api.twilio.com",
+		accountSID: config.AccountSID,
+		authToken:  config.AuthToken,
+		from:       config.From,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// InitSMS sets up sending text messages. SMS stays disabled when no Twilio
+// account is configured.
+func InitSMS(config SMSConfig) {
+	if len(config.AccountSID) == 0 {
+		sms = nil
+		return
+	}
+	sms = NewTwilio(config)
+}
+
+// SetSMS replaces the SMS provider, e.g. with one pointed at a test server.
+func SetSMS(t *Twilio) {
+	sms = t
+}
+
+func SMSEnabled() bool {
+	return sms != nil
+}
+
+func SendSMS(to, body string) error {
+	if sms == nil {
+		return ErrSMSDisabled
+	}
+	return sms.Send(to, body)
+}
+
+func (t *Twilio) Send(to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.from)
+	form.Set("Body", body)
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(t.URL, "/"), url.PathEscape(t.accountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("twilio returned %d: %d %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return nil
+}
+
+// NormalizePhone returns the number in E.164 format, e.g. +15555550123.
+// Numbers without a country code are taken to be North American.
+func NormalizePhone(phone string) (string, bool) {
+	var digits strings.Builder
+	for i, c := range strings.TrimSpace(phone) {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == '+' && i == 0:
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", false
+		}
+	}
+	n := digits.String()
+	if !strings.HasPrefix(strings.TrimSpace(phone), "+") {
+		if len(n) == 11 && n[0] == '1' {
+			n = n[1:]
+		}
+		if len(n) != 10 {
+			return "", false
+		}
+		n = "1" + n
+	}
+	if len(n) < 8 || len(n) > 15 || n[0] == '0' {
+		return "", false
+	}
+	return "+" + n, true
+}
@@ -0,0 +1,52 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	for in, want := range map[string]string{
+		"(555) 555-0123":   "+15555550123",
+		"1 555.555.0123":   "+15555550123",
+		"+44 20 7946 0958": "+442079460958",
+		"555-0123":         "",
+		"+1 555 CALL NOW":  "",
+	} {
+		got, ok := pizza.NormalizePhone(in)
+		assert.Equal(t, want, got, in)
+		assert.Equal(t, len(want) > 0, ok, in)
+	}
+}
+
+func TestTwilioSend(t *testing.T) {
+	// GIVEN
+	var form url.Values
+	var path, user string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, _, _ = r.BasicAuth()
+		r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	twilio := pizza.NewTwilio(pizza.SMSConfig{AccountSID: "AC123", AuthToken: "token", From: "+15555550100"})
+	twilio.URL = server.URL
+
+	// WHEN
+	err := twilio.Send("+15555550123", "123456 is your rsvp.pizza code")
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", path)
+	assert.Equal(t, "AC123", user)
+	assert.Equal(t, "+15555550123", form.Get("To"))
+	assert.Equal(t, "+15555550100", form.Get("From"))
+	assert.Equal(t, "123456 is your rsvp.pizza code", form.Get("Body"))
+}
@@ -0,0 +1,145 @@
+package pizza
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSNSSignature = errors.New("invalid sns signature")
+
+// snsHostPattern matches Amazon SNS in any region, the only host subscription
+// and signing certificate URLs may point to.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SNSEnvelope is a message from Amazon SNS as posted to the webhooks.
+type SNSEnvelope struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// snsCerts are the signing certificates already fetched, by URL.
+var snsCerts sync.Map
+
+var snsClient = &http.Client{Timeout: 10 * time.Second}
+
+// IsSNSURL reports whether the URL is an https URL on Amazon SNS.
+func IsSNSURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && u.Host == u.Hostname() && snsHostPattern.MatchString(u.Host)
+}
+
+// stringToSign is what SNS signs: the envelope's fields for its type, each
+// name and value on its own line.
+func (e SNSEnvelope) stringToSign() string {
+	fields := [][2]string{{"Message", e.Message}, {"MessageId", e.MessageID}}
+	if e.Type == "Notification" {
+		if len(e.Subject) > 0 {
+			fields = append(fields, [2]string{"Subject", e.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", e.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", e.Timestamp})
+	if e.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", e.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", e.TopicArn}, [2]string{"Type", e.Type})
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+// VerifySNS checks the envelope was signed by SNS, with a certificate fetched
+// from SNS itself. Nothing in the envelope can be trusted until it passes.
+func VerifySNS(e SNSEnvelope) error {
+	if !IsSNSURL(e.SigningCertURL) {
+		return ErrSNSSignature
+	}
+	var h hash.Hash
+	var alg crypto.Hash
+	switch e.SignatureVersion {
+	case "1":
+		h, alg = sha1.New(), crypto.SHA1
+	case "2":
+		h, alg = sha256.New(), crypto.SHA256
+	default:
+		return ErrSNSSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(e.Signature)
+	if err != nil {
+		return ErrSNSSignature
+	}
+	cert, err := snsCert(e.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrSNSSignature
+	}
+	h.Write([]byte(e.stringToSign()))
+	if err = rsa.VerifyPKCS1v15(key, alg, h.Sum(nil), sig); err != nil {
+		return ErrSNSSignature
+	}
+	return nil
+}
+
+// snsCert fetches the signing certificate, or returns it from snsCerts. It
+// must not have expired.
+func snsCert(certURL string) (*x509.Certificate, error) {
+	if cert, ok := snsCerts.Load(certURL); ok {
+		if c := cert.(*x509.Certificate); time.Now().Before(c.NotAfter) {
+			return c, nil
+		}
+	}
+	res, err := snsClient.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrSNSSignature
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, ErrSNSSignature
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ErrSNSSignature
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, ErrSNSSignature
+	}
+	snsCerts.Store(certURL, cert)
+	return cert, nil
+}
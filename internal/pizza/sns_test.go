@@ -0,0 +1,80 @@
+package pizza_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestIsSNSURL(t *testing.T) {
+	assert.True(t, pizza.IsSNSURL("https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=x"))
+	assert.True(t, pizza.IsSNSURL("https://sns.cn-north-1.amazonaws.com.cn/cert.pem"))
+	assert.False(t, pizza.IsSNSURL("http://sns.us-east-1.amazonaws.com/"))
+	assert.False(t, pizza.IsSNSURL("https://sns.evil.com/"))
+	assert.False(t, pizza.IsSNSURL("https://sns.us-east-1.amazonaws.com.evil.com/"))
+	assert.False(t, pizza.IsSNSURL("https://sns.us-east-1.amazonaws.com@169.254.169.254/"))
+	assert.False(t, pizza.IsSNSURL("https://sns.us-east-1.amazonaws.com:8443/"))
+}
+
+func TestVerifySNS(t *testing.T) {
+	// GIVEN a certificate at an SNS URL
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	certURL := "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+	pizza.SetSNSCert(certURL, cert)
+
+	envelope := pizza.SNSEnvelope{
+		Type:             "SubscriptionConfirmation",
+		MessageID:        "1",
+		Token:            "token",
+		TopicArn:         "arn:aws:sns:us-east-1:123456789012:pizza",
+		Message:          "You have chosen to subscribe",
+		SubscribeURL:     "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token",
+		Timestamp:        "2023-04-07T21:30:00.000Z",
+		SignatureVersion: "2",
+		SigningCertURL:   certURL,
+	}
+	sum := sha256.Sum256([]byte(pizza.SNSStringToSign(envelope)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	require.NoError(t, err)
+	envelope.Signature = base64.StdEncoding.EncodeToString(sig)
+
+	// THEN
+	assert.NoError(t, pizza.VerifySNS(envelope))
+
+	// WHEN the subscribe URL is swapped
+	tampered := envelope
+	tampered.SubscribeURL = "http://169.254.169.254/latest/meta-data/"
+
+	// THEN
+	assert.Equal(t, pizza.ErrSNSSignature, pizza.VerifySNS(tampered))
+
+	// WHEN the certificate is somewhere else
+	tampered = envelope
+	tampered.SigningCertURL = "https://example.com/cert.pem"
+
+	// THEN
+	assert.Equal(t, pizza.ErrSNSSignature, pizza.VerifySNS(tampered))
+}
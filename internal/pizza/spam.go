@@ -0,0 +1,95 @@
+package pizza
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SpamMinFillTime is how long a person takes at least to fill in a public
+// form. Faster submissions are held for review.
+var SpamMinFillTime = 3 * time.Second
+
+// honeypotField is a form field hidden from people that bots fill in.
+const honeypotField = "website"
+
+const (
+	SpamHoneypot = "honeypot"
+	SpamTooFast  = "too fast"
+	SpamNoToken  = "no form token"
+)
+
+// FormToken is a signed timestamp of when the form was shown.
+func FormToken() string {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return ts + "." + SignLink("form", ts)
+}
+
+// CheckSpam returns why the submission of a public form looks like spam, or
+// the empty string if it doesn't.
+func CheckSpam(form url.Values) string {
+	if len(form.Get(honeypotField)) > 0 {
+		return SpamHoneypot
+	}
+	ts, sig, _ := strings.Cut(form.Get("ft"), ".")
+	shown, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || !VerifyLink(sig, "form", ts) {
+		return SpamNoToken
+	}
+	if time.Since(time.Unix(shown, 0)) < SpamMinFillTime {
+		return SpamTooFast
+	}
+	return ""
+}
+
+// holdSubmission quarantines a submission that looks like spam so the host can
+// release it from the admin page.
+func holdSubmission(form string, values url.Values, reason string) error {
+	values = cloneValues(values)
+	values.Del(honeypotField)
+	values.Del("ft")
+	Log.Info("holding suspicious submission", zap.String("form", form), zap.String("reason", reason))
+	return CreateQuarantined(Quarantined{Form: form, Values: values, Reason: reason})
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for k, v := range values {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// ReleaseQuarantined submits a held submission as if it had passed the spam
+// checks and removes it from quarantine.
+func ReleaseQuarantined(q Quarantined) error {
+	values := url.Values(q.Values)
+	email := strings.ToLower(values.Get("email"))
+	switch q.Form {
+	case "rsvp":
+		plusOnes, _ := strconv.Atoi(values.Get("plusOnes"))
+		for _, d := range values["date"] {
+			friday, ok, err := GetCachedFriday(UpcomingDays, d)
+			if err != nil {
+				return err
+			} else if !ok || !friday.IsOpen() {
+				continue
+			}
+			if _, err = RSVPToFriday(email, friday, RSVPOptions{PlusOnes: plusOnes}); err != nil {
+				return err
+			}
+		}
+	case "notify":
+		kind := values.Get("kind")
+		if len(kind) == 0 {
+			kind = NotifyRSVPsOpen
+		}
+		if err := CreateNotification(Notification{Email: email, FridayID: values.Get("friday"), Kind: kind}); err != nil {
+			return err
+		}
+	}
+	return DeleteQuarantined(q.ID)
+}
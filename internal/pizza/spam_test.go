@@ -0,0 +1,37 @@
+package pizza_test
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSpam(t *testing.T) {
+	// GIVEN
+	pizza.SpamMinFillTime = time.Hour
+	defer func() { pizza.SpamMinFillTime = 3 * time.Second }()
+	fresh := pizza.FormToken()
+	pizza.SpamMinFillTime = 0
+	old := pizza.FormToken()
+	forged := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10) + ".forged"
+
+	// WHEN
+	pizza.SpamMinFillTime = time.Hour
+	tooFast := pizza.CheckSpam(url.Values{"ft": {fresh}})
+	pizza.SpamMinFillTime = 0
+	ok := pizza.CheckSpam(url.Values{"ft": {old}})
+	honeypot := pizza.CheckSpam(url.Values{"ft": {old}, "website": {"http://spam.example"}})
+	noToken := pizza.CheckSpam(url.Values{})
+	forgedToken := pizza.CheckSpam(url.Values{"ft": {forged}})
+
+	// THEN
+	assert.Equal(t, pizza.SpamTooFast, tooFast)
+	assert.Equal(t, "", ok)
+	assert.Equal(t, pizza.SpamHoneypot, honeypot)
+	assert.Equal(t, pizza.SpamNoToken, noToken)
+	assert.Equal(t, pizza.SpamNoToken, forgedToken)
+}
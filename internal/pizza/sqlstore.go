@@ -0,0 +1,1555 @@
+package pizza
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// the databases database.driver can name
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// sqlSchema creates the SQLStore tables. Times are unix microseconds, like
+// versions and Fauna's ts, or 0 when unset, so the same schema works in SQLite
+// and Postgres. Whatever isn't queried on is kept as JSON in a TEXT column.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS friends (
+		email TEXT PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		joined_at BIGINT NOT NULL DEFAULT 0,
+		referred_by TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		friend_groups TEXT NOT NULL DEFAULT '[]',
+		labs TEXT NOT NULL DEFAULT '[]',
+		phone TEXT NOT NULL DEFAULT '',
+		digest BOOLEAN NOT NULL DEFAULT FALSE,
+		birthday TEXT NOT NULL DEFAULT '',
+		no_celebrate BOOLEAN NOT NULL DEFAULT FALSE,
+		no_tracking BOOLEAN NOT NULL DEFAULT FALSE,
+		email_status TEXT NOT NULL DEFAULT '',
+		email_reason TEXT NOT NULL DEFAULT '',
+		email_flagged_at BIGINT NOT NULL DEFAULT 0,
+		pending_rsvps TEXT,
+		confirmed_rsvps TEXT,
+		rsvp_code TEXT,
+		rsvp_code_expires BIGINT
+	)`,
+	`CREATE INDEX IF NOT EXISTS friends_by_phone ON friends (phone)`,
+	`CREATE TABLE IF NOT EXISTS friend_aliases (
+		alias TEXT PRIMARY KEY,
+		email TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS fridays (
+		uid TEXT PRIMARY KEY,
+		start_time BIGINT NOT NULL,
+		data TEXT NOT NULL,
+		version BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS fridays_by_start ON fridays (start_time)`,
+	// pending RSVPs expire when their event starts, like Fauna's TTL
+	`CREATE TABLE IF NOT EXISTS rsvps (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		friday TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT '',
+		invite_pending BOOLEAN NOT NULL DEFAULT FALSE,
+		data TEXT NOT NULL,
+		version BIGINT NOT NULL,
+		expires BIGINT,
+		UNIQUE (email, friday)
+	)`,
+	`CREATE INDEX IF NOT EXISTS rsvps_by_friday ON rsvps (friday)`,
+	`CREATE INDEX IF NOT EXISTS rsvps_by_status ON rsvps (status)`,
+	`CREATE TABLE IF NOT EXISTS changes (
+		id TEXT PRIMARY KEY,
+		ts BIGINT NOT NULL,
+		type TEXT NOT NULL,
+		friday TEXT NOT NULL,
+		rsvp_id TEXT NOT NULL,
+		rsvp TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS changes_by_ts ON changes (ts, id)`,
+	`CREATE TABLE IF NOT EXISTS notifications (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		friday TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		notified_at BIGINT NOT NULL DEFAULT 0,
+		UNIQUE (email, friday, kind)
+	)`,
+	`CREATE TABLE IF NOT EXISTS reactions (
+		email TEXT NOT NULL,
+		friday TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		PRIMARY KEY (email, friday)
+	)`,
+	`CREATE TABLE IF NOT EXISTS documents (
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS quarantine (
+		id TEXT PRIMARY KEY,
+		form TEXT NOT NULL,
+		form_values TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	// the columns cleared by the retention policies can be null
+	`CREATE TABLE IF NOT EXISTS audit (
+		id TEXT PRIMARY KEY,
+		ts BIGINT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT,
+		ip TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS audit_by_ts ON audit (ts)`,
+	`CREATE TABLE IF NOT EXISTS email_events (
+		id TEXT PRIMARY KEY,
+		ts BIGINT NOT NULL,
+		email TEXT,
+		kind TEXT NOT NULL,
+		event TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS email_events_by_ts ON email_events (ts)`,
+	`CREATE TABLE IF NOT EXISTS passkeys (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		sign_count BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS devices (
+		id TEXT PRIMARY KEY,
+		token TEXT NOT NULL,
+		prev TEXT NOT NULL,
+		email TEXT NOT NULL,
+		user_agent TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		rotated_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS devices_by_token ON devices (token)`,
+	`CREATE INDEX IF NOT EXISTS devices_by_prev ON devices (prev)`,
+}
+
+// sqlLogs are the tables ExpireLog may be asked to expire.
+var sqlLogs = []string{"audit", "email_events", "changes"}
+
+// SQLStore keeps everything in SQLite or Postgres through database/sql. The
+// sqlite3 driver needs the binary built with cgo.
+type SQLStore struct {
+	db *sql.DB
+	// numbered is set for databases with $1 placeholders rather than ?
+	numbered bool
+}
+
+var _ Store = &SQLStore{}
+
+var ErrNoSQLDriver = errors.New("database.driver has to be sqlite3 or postgres")
+
+// OpenSQLStore connects to the database and creates the tables it needs.
+func OpenSQLStore(driver, dsn string) (*SQLStore, error) {
+	s, err := openSQLStore(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range sqlSchema {
+		if _, err = s.db.Exec(stmt); err != nil {
+			s.db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func openSQLStore(driver, dsn string) (*SQLStore, error) {
+	if driver != "sqlite3" && driver != "postgres" {
+		return nil, fmt.Errorf("%w, not %q", ErrNoSQLDriver, driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, numbered: driver == "postgres"}, nil
+}
+
+// BootstrapSQL creates the tables that don't exist yet, or with dryRun only
+// checks the database can be reached. Existing tables are left alone, so it
+// is safe to run again.
+func BootstrapSQL(config DatabaseConfig, dryRun bool) ([]BootstrapStep, error) {
+	step := BootstrapStep{Kind: "database", Name: config.Driver, Created: !dryRun}
+	var s *SQLStore
+	var err error
+	if dryRun {
+		if s, err = openSQLStore(config.Driver, config.DSN); err == nil {
+			err = s.Ping()
+		}
+	} else {
+		s, err = OpenSQLStore(config.Driver, config.DSN)
+	}
+	if err != nil {
+		step.Error = err.Error()
+		return []BootstrapStep{step}, err
+	}
+	s.db.Close()
+	return []BootstrapStep{step}, nil
+}
+
+// rebind switches the ? placeholders in the query to $1, $2, ... for
+// databases that need them.
+func rebind(query string, numbered bool) string {
+	if !numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// sqlQueryer is a database or a transaction.
+type sqlQueryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *SQLStore) exec(q sqlQueryer, query string, args ...any) (sql.Result, error) {
+	res, err := q.Exec(rebind(query, s.numbered), args...)
+	if err != nil {
+		Log.Error("sql error", zap.Error(err))
+	}
+	return res, err
+}
+
+func (s *SQLStore) query(q sqlQueryer, query string, args ...any) (*sql.Rows, error) {
+	return q.Query(rebind(query, s.numbered), args...)
+}
+
+// queryRow scans the first row of the query into dest, returning false if
+// there was none.
+func (s *SQLStore) queryRow(q sqlQueryer, query string, args []any, dest ...any) (bool, error) {
+	err := q.QueryRow(rebind(query, s.numbered), args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		Log.Error("sql error", zap.Error(err))
+		return false, err
+	}
+	return true, nil
+}
+
+// inTx runs fn in a transaction, committed if fn returns no error.
+func (s *SQLStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		Log.Error("sql error", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		Log.Error("sql error", zap.Error(err))
+	}
+	return err
+}
+
+// scanAll calls scan for each row until one fails.
+func scanAll(rows *sql.Rows, err error, scan func(rows *sql.Rows) error) error {
+	if err != nil {
+		Log.Error("sql error", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			Log.Error("sql decode error", zap.Error(err))
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		Log.Error("sql error", zap.Error(err))
+	}
+	return err
+}
+
+// newSQLID is a row ID that sorts by when it was made.
+func newSQLID() string {
+	return strconv.FormatInt(time.Now().UnixMicro()<<10|rand.Int63n(1<<10), 10)
+}
+
+// sqlTime is t in unix microseconds, or 0 when it is zero.
+func sqlTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro()
+}
+
+func fromSQLTime(us int64) time.Time {
+	if us == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us).UTC()
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		Log.Error("sql encode error", zap.Error(err))
+	}
+	return string(data), err
+}
+
+// sqlPlaceholders is n comma separated ?s for an IN list.
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Ping checks the database answers with database.dsn.
+func (s *SQLStore) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *SQLStore) IsFriendAllowed(friendEmail string) (bool, error) {
+	var n int
+	_, err := s.queryRow(s.db, `SELECT
+		(SELECT COUNT(*) FROM friends WHERE email = ?) + (SELECT COUNT(*) FROM friend_aliases WHERE alias = ?)`,
+		[]any{friendEmail, friendEmail}, &n)
+	return n > 0, err
+}
+
+func (s *SQLStore) GetFriendName(friendEmail string) (string, error) {
+	var name string
+	found, err := s.queryRow(s.db, `SELECT name FROM friends WHERE email = ?`, []any{friendEmail}, &name)
+	if err == nil && !found {
+		err = sql.ErrNoRows
+	}
+	return name, err
+}
+
+func (s *SQLStore) AddFriend(name, email string) error {
+	return s.AddReferredFriend(name, email, "")
+}
+
+func (s *SQLStore) AddReferredFriend(name, email, referrer string) error {
+	_, err := s.exec(s.db, `INSERT INTO friends (email, name, referred_by, joined_at) VALUES (?, ?, ?, ?)`,
+		email, name, referrer, time.Now().UnixMicro())
+	return err
+}
+
+func (s *SQLStore) DeleteFriend(friendEmail string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `DELETE FROM friend_aliases WHERE email = ?`, friendEmail); err != nil {
+			return err
+		}
+		_, err := s.exec(tx, `DELETE FROM friends WHERE email = ?`, friendEmail)
+		return err
+	})
+}
+
+func (s *SQLStore) PurgeFriend(friendEmail string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, table := range []string{"notifications", "reactions", "passkeys", "devices", "email_events", "friend_aliases", "friends"} {
+			if _, err := s.exec(tx, `DELETE FROM `+table+` WHERE email = ?`, friendEmail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, which use \ as their
+// escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *SQLStore) SearchFriends(query string) ([]Friend, error) {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(query)) + "%"
+	var friends []Friend
+	rows, err := s.query(s.db, `SELECT name, email FROM friends
+		WHERE LOWER(name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\' OR LOWER(note) LIKE ? ESCAPE '\'
+		ORDER BY name`, pattern, pattern, pattern)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var friend Friend
+		err := rows.Scan(&friend.Name, &friend.Email)
+		friends = append(friends, friend)
+		return err
+	})
+	return friends, err
+}
+
+func (s *SQLStore) GetPrimaryEmail(email string) (string, error) {
+	var primary string
+	_, err := s.queryRow(s.db, `SELECT COALESCE(
+		(SELECT email FROM friends WHERE email = ?), (SELECT email FROM friend_aliases WHERE alias = ?), '')`, []any{email, email}, &primary)
+	return primary, err
+}
+
+func (s *SQLStore) GetFriendAliases(friendEmail string) ([]string, error) {
+	aliases := []string{}
+	rows, err := s.query(s.db, `SELECT alias FROM friend_aliases WHERE email = ? ORDER BY alias`, friendEmail)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var alias string
+		err := rows.Scan(&alias)
+		aliases = append(aliases, alias)
+		return err
+	})
+	return aliases, err
+}
+
+func (s *SQLStore) AddFriendAlias(friendEmail, alias string) error {
+	_, err := s.exec(s.db, `INSERT INTO friend_aliases (alias, email) VALUES (?, ?) ON CONFLICT (alias) DO NOTHING`, alias, friendEmail)
+	return err
+}
+
+func (s *SQLStore) RemoveFriendAlias(friendEmail, alias string) error {
+	_, err := s.exec(s.db, `DELETE FROM friend_aliases WHERE alias = ? AND email = ?`, alias, friendEmail)
+	return err
+}
+
+// getFriend scans the columns of the friend into dest.
+func (s *SQLStore) getFriend(friendEmail, columns string, dest ...any) error {
+	found, err := s.queryRow(s.db, `SELECT `+columns+` FROM friends WHERE email = ?`, []any{friendEmail}, dest...)
+	if err == nil && !found {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// setFriend sets columns of the friend, like "note = ?".
+func (s *SQLStore) setFriend(friendEmail, set string, args ...any) error {
+	_, err := s.exec(s.db, `UPDATE friends SET `+set+` WHERE email = ?`, append(args, friendEmail)...)
+	return err
+}
+
+func (s *SQLStore) FlagFriendEmail(issue EmailIssue) error {
+	return s.setFriend(issue.Email, `email_status = ?, email_reason = ?, email_flagged_at = ?`,
+		issue.Status, issue.Reason, time.Now().UnixMicro())
+}
+
+func (s *SQLStore) GetEmailStatus(friendEmail string) (string, error) {
+	var status string
+	_, err := s.queryRow(s.db, `SELECT email_status FROM friends WHERE email = ?`, []any{friendEmail}, &status)
+	return status, err
+}
+
+func (s *SQLStore) ListFlaggedFriends() ([]FlaggedFriend, error) {
+	var friends []FlaggedFriend
+	rows, err := s.query(s.db, `SELECT name, email, email_status, email_reason, email_flagged_at FROM friends
+		WHERE email_status IN (?, ?) ORDER BY email_flagged_at`, EmailStatusBounced, EmailStatusComplained)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var friend FlaggedFriend
+		var flaggedAt int64
+		err := rows.Scan(&friend.Name, &friend.Email, &friend.Status, &friend.Reason, &flaggedAt)
+		friend.FlaggedAt = fromSQLTime(flaggedAt)
+		friends = append(friends, friend)
+		return err
+	})
+	return friends, err
+}
+
+func (s *SQLStore) SetFriendDigest(friendEmail string, subscribed bool) error {
+	return s.setFriend(friendEmail, `digest = ?`, subscribed)
+}
+
+func (s *SQLStore) GetFriendDigest(friendEmail string) (bool, error) {
+	var subscribed bool
+	err := s.getFriend(friendEmail, `digest`, &subscribed)
+	return subscribed, err
+}
+
+func (s *SQLStore) ListDigestFriends() ([]DigestFriend, error) {
+	var friends []DigestFriend
+	rows, err := s.query(s.db, `SELECT name, email FROM friends WHERE digest = ? ORDER BY email`, true)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var friend DigestFriend
+		err := rows.Scan(&friend.Name, &friend.Email)
+		friends = append(friends, friend)
+		return err
+	})
+	return friends, err
+}
+
+func (s *SQLStore) SetFriendBirthday(friendEmail, birthday string, noCelebrate bool) error {
+	return s.setFriend(friendEmail, `birthday = ?, no_celebrate = ?`, birthday, noCelebrate)
+}
+
+func (s *SQLStore) GetFriendBirthday(friendEmail string) (FriendBirthday, error) {
+	var birthday FriendBirthday
+	err := s.getFriend(friendEmail, `email, birthday, no_celebrate`, &birthday.Email, &birthday.Birthday, &birthday.NoCelebrate)
+	return birthday, err
+}
+
+func (s *SQLStore) ListFriendBirthdays() (map[string]string, error) {
+	birthdays := map[string]string{}
+	rows, err := s.query(s.db, `SELECT email, birthday FROM friends WHERE birthday != '' AND no_celebrate = ?`, false)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var email, birthday string
+		err := rows.Scan(&email, &birthday)
+		birthdays[email] = birthday
+		return err
+	})
+	return birthdays, err
+}
+
+func (s *SQLStore) SetFriendNoTracking(friendEmail string, noTracking bool) error {
+	return s.setFriend(friendEmail, `no_tracking = ?`, noTracking)
+}
+
+func (s *SQLStore) GetFriendNoTracking(friendEmail string) (bool, error) {
+	var noTracking bool
+	err := s.getFriend(friendEmail, `no_tracking`, &noTracking)
+	return noTracking, err
+}
+
+func (s *SQLStore) SetFriendNote(friendEmail, note string) error {
+	return s.setFriend(friendEmail, `note = ?`, note)
+}
+
+func (s *SQLStore) GetFriendNote(friendEmail string) (string, error) {
+	var note string
+	err := s.getFriend(friendEmail, `note`, &note)
+	return note, err
+}
+
+func (s *SQLStore) SetFriendGroups(friendEmail string, groups []string) error {
+	data, err := toJSON(groups)
+	if err != nil {
+		return err
+	}
+	return s.setFriend(friendEmail, `friend_groups = ?`, data)
+}
+
+func (s *SQLStore) GetFriendGroups(friendEmail string) ([]string, error) {
+	return s.getFriendList(friendEmail, `friend_groups`)
+}
+
+func (s *SQLStore) SetFriendLabs(friendEmail string, labs []string) error {
+	data, err := toJSON(labs)
+	if err != nil {
+		return err
+	}
+	return s.setFriend(friendEmail, `labs = ?`, data)
+}
+
+func (s *SQLStore) GetFriendLabs(friendEmail string) ([]string, error) {
+	return s.getFriendList(friendEmail, `labs`)
+}
+
+// getFriendList reads a column of the friend holding a JSON list.
+func (s *SQLStore) getFriendList(friendEmail, column string) ([]string, error) {
+	var data string
+	if err := s.getFriend(friendEmail, column, &data); err != nil {
+		return nil, err
+	}
+	list := []string{}
+	err := json.Unmarshal([]byte(data), &list)
+	return list, err
+}
+
+func (s *SQLStore) CountReferrals(friendEmail string) (int, error) {
+	var count int
+	_, err := s.queryRow(s.db, `SELECT COUNT(*) FROM friends WHERE referred_by = ?`, []any{friendEmail}, &count)
+	return count, err
+}
+
+func (s *SQLStore) ListReferredFriends() ([]ReferredFriend, error) {
+	var friends []ReferredFriend
+	rows, err := s.query(s.db, `SELECT name, email, referred_by, joined_at FROM friends WHERE referred_by != '' ORDER BY joined_at`)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var friend ReferredFriend
+		var joinedAt int64
+		err := rows.Scan(&friend.Name, &friend.Email, &friend.ReferredBy, &joinedAt)
+		friend.JoinedAt = fromSQLTime(joinedAt)
+		friends = append(friends, friend)
+		return err
+	})
+	return friends, err
+}
+
+func (s *SQLStore) GetFriendByPhone(phone string) (string, error) {
+	var email string
+	_, err := s.queryRow(s.db, `SELECT email FROM friends WHERE phone = ?`, []any{phone}, &email)
+	return email, err
+}
+
+func (s *SQLStore) GetFriendPhone(friendEmail string) (string, error) {
+	var phone string
+	err := s.getFriend(friendEmail, `phone`, &phone)
+	return phone, err
+}
+
+func (s *SQLStore) SetFriendPhone(friendEmail, phone string) error {
+	return s.setFriend(friendEmail, `phone = ?`, phone)
+}
+
+func (s *SQLStore) CreateRSVP(friendEmail, code string, pendingDates []time.Time, expires time.Time) error {
+	dates, err := toJSON(pendingDates)
+	if err != nil {
+		return err
+	}
+	return s.setFriend(friendEmail, `pending_rsvps = ?, rsvp_code = ?, rsvp_code_expires = ?`, dates, code, expires.UnixMicro())
+}
+
+func (s *SQLStore) ConfirmRSVP(friendEmail, code string, notAfter time.Time) error {
+	res, err := s.exec(s.db, `UPDATE friends
+		SET confirmed_rsvps = pending_rsvps, pending_rsvps = NULL, rsvp_code = NULL, rsvp_code_expires = NULL
+		WHERE email = ? AND rsvp_code = ? AND rsvp_code_expires >= ?`,
+		friendEmail, code, notAfter.UnixMicro())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRSVPCodeExpired
+	}
+	return nil
+}
+
+func (s *SQLStore) ClearExpiredRSVPCodes(before time.Time) (int, error) {
+	res, err := s.exec(s.db, `UPDATE friends SET pending_rsvps = NULL, rsvp_code = NULL, rsvp_code_expires = NULL
+		WHERE rsvp_code_expires <= ?`, before.UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// fridayColumns are what scanFridays reads.
+const fridayColumns = `uid, data, version`
+
+func (s *SQLStore) scanFridays(rows *sql.Rows, err error) ([]Friday, error) {
+	fridays := []Friday{}
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var uid, data string
+		var friday Friday
+		if err := rows.Scan(&uid, &data, &friday.TS); err != nil {
+			return err
+		}
+		version := friday.TS
+		if err := json.Unmarshal([]byte(data), &friday); err != nil {
+			return err
+		}
+		friday.TS = version
+		fridays = append(fridays, friday)
+		return nil
+	})
+	return fridays, err
+}
+
+func (s *SQLStore) GetAllFridays() ([]Friday, error) {
+	return s.scanFridays(s.query(s.db, `SELECT `+fridayColumns+` FROM fridays ORDER BY start_time`))
+}
+
+func (s *SQLStore) GetUpcomingFridays(daysAhead int) ([]Friday, error) {
+	now := time.Now()
+	return s.scanFridays(s.query(s.db, `SELECT `+fridayColumns+` FROM fridays WHERE start_time >= ? AND start_time <= ? ORDER BY start_time`,
+		now.UnixMicro(), now.AddDate(0, 0, 1+daysAhead).UnixMicro()))
+}
+
+func (s *SQLStore) GetFriday(id string) (*Friday, error) {
+	fridays, err := s.scanFridays(s.query(s.db, `SELECT `+fridayColumns+` FROM fridays WHERE uid = ?`, id))
+	if err != nil || len(fridays) == 0 {
+		return nil, err
+	}
+	return &fridays[0], nil
+}
+
+func (s *SQLStore) GetFridayAt(start time.Time) (*Friday, error) {
+	fridays, err := s.scanFridays(s.query(s.db, `SELECT `+fridayColumns+` FROM fridays WHERE start_time = ?`, start.UnixMicro()))
+	if err != nil || len(fridays) == 0 {
+		return nil, err
+	}
+	return &fridays[0], nil
+}
+
+// CreateFriday stores the event under its ID, so an event without a UID is
+// found by its start time like it is in Fauna.
+func (s *SQLStore) CreateFriday(friday Friday) error {
+	friday.TS = 0
+	data, err := toJSON(friday)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(s.db, `INSERT INTO fridays (uid, start_time, data, version) VALUES (?, ?, ?, ?)`,
+		friday.ID(), friday.Start.UnixMicro(), data, time.Now().UnixMicro())
+	return err
+}
+
+// DeleteFriday removes the event along with its RSVPs and reactions. Each RSVP
+// is recorded in the change feed.
+func (s *SQLStore) DeleteFriday(id string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		rsvps, err := s.scanRSVPs(s.query(tx, `SELECT `+rsvpColumns+` FROM rsvps WHERE friday = ?`, id))
+		if err != nil {
+			return err
+		}
+		for _, rsvp := range rsvps {
+			if err = s.recordRSVPChange(tx, ChangeRSVPDeleted, rsvp); err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"rsvps", "reactions"} {
+			if _, err = s.exec(tx, `DELETE FROM `+table+` WHERE friday = ?`, id); err != nil {
+				return err
+			}
+		}
+		res, err := s.exec(tx, `DELETE FROM fridays WHERE uid = ?`, id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrRSVPNotFound
+		}
+		return nil
+	})
+}
+
+// MigrateFridayUIDs saves the ID of each event without a UID as its UID. The
+// events are already stored under their IDs.
+func (s *SQLStore) MigrateFridayUIDs(dryRun bool) (int, error) {
+	migrated := 0
+	err := s.inTx(func(tx *sql.Tx) error {
+		fridays, err := s.scanFridays(s.query(tx, `SELECT `+fridayColumns+` FROM fridays`))
+		if err != nil {
+			return err
+		}
+		for _, friday := range fridays {
+			if len(friday.UID) > 0 {
+				continue
+			}
+			migrated++
+			if dryRun {
+				continue
+			}
+			id := friday.ID()
+			if err = s.updateFriday(tx, id, func(friday *Friday) { friday.UID = id }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return migrated, err
+}
+
+// updateFriday lets update change the event with the ID and saves it,
+// returning ErrRSVPNotFound if there is no such event.
+func (s *SQLStore) updateFriday(q sqlQueryer, id string, update func(friday *Friday)) error {
+	fridays, err := s.scanFridays(s.query(q, `SELECT `+fridayColumns+` FROM fridays WHERE uid = ?`, id))
+	if err != nil {
+		return err
+	}
+	if len(fridays) == 0 {
+		return ErrRSVPNotFound
+	}
+	friday := fridays[0]
+	update(&friday)
+	version := friday.TS
+	friday.TS = 0
+	data, err := toJSON(friday)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(q, `UPDATE fridays SET data = ?, start_time = ?, version = ? WHERE uid = ?`,
+		data, friday.Start.UnixMicro(), nextSQLVersion(version), id)
+	return err
+}
+
+// nextSQLVersion is the version a row changed now gets, which is always
+// after the one it had.
+func nextSQLVersion(version int64) int64 {
+	if now := time.Now().UnixMicro(); now > version {
+		return now
+	}
+	return version + 1
+}
+
+// updateFridayData changes the event with the ID in a transaction.
+func (s *SQLStore) updateFridayData(id string, update func(friday *Friday)) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `UPDATE fridays SET version = version WHERE uid = ?`, id); err != nil {
+			return err
+		}
+		return s.updateFriday(tx, id, update)
+	})
+}
+
+func (s *SQLStore) AddFridayImage(id string, img Image, cover bool) error {
+	return s.updateFridayData(id, func(friday *Friday) {
+		if cover {
+			friday.Cover = img
+		} else {
+			friday.Images = append(friday.Images, img)
+		}
+	})
+}
+
+func (s *SQLStore) SetFridayReminders(id string, reminders []Reminder) error {
+	return s.updateFridayData(id, func(friday *Friday) { friday.Reminders = reminders })
+}
+
+func (s *SQLStore) SetFridayTables(id string, tables [][]string) error {
+	return s.updateFridayData(id, func(friday *Friday) { friday.Tables = tables })
+}
+
+func (s *SQLStore) SetFridayAnnouncement(id, announcement string) error {
+	return s.updateFridayData(id, func(friday *Friday) { friday.Announcement = announcement })
+}
+
+func (s *SQLStore) SetFridayCohosts(id string, cohosts []string) error {
+	return s.updateFridayData(id, func(friday *Friday) { friday.Cohosts = cohosts })
+}
+
+func (s *SQLStore) SetReaction(r Reaction) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `DELETE FROM reactions WHERE email = ? AND friday = ?`, r.Email, r.FridayID); err != nil {
+			return err
+		}
+		if len(r.Emoji) == 0 {
+			return nil
+		}
+		_, err := s.exec(tx, `INSERT INTO reactions (email, friday, emoji) VALUES (?, ?, ?)`, r.Email, r.FridayID, r.Emoji)
+		return err
+	})
+}
+
+func (s *SQLStore) ListReactions(fridayID string) ([]Reaction, error) {
+	var reactions []Reaction
+	rows, err := s.query(s.db, `SELECT email, friday, emoji FROM reactions WHERE friday = ?`, fridayID)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var r Reaction
+		err := rows.Scan(&r.Email, &r.FridayID, &r.Emoji)
+		reactions = append(reactions, r)
+		return err
+	})
+	return reactions, err
+}
+
+// rsvpColumns are what scanRSVPs reads.
+const rsvpColumns = `id, data, version, expires`
+
+// scanRSVPs skips pending RSVPs whose event has started, which Fauna would
+// have removed.
+func (s *SQLStore) scanRSVPs(rows *sql.Rows, err error) ([]RSVP, error) {
+	now := time.Now().UnixMicro()
+	rsvps := []RSVP{}
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var id, data string
+		var version int64
+		var expires sql.NullInt64
+		if err := rows.Scan(&id, &data, &version, &expires); err != nil {
+			return err
+		}
+		if expires.Valid && expires.Int64 <= now {
+			return nil
+		}
+		var rsvp RSVP
+		if err := json.Unmarshal([]byte(data), &rsvp); err != nil {
+			return err
+		}
+		rsvp.ID, rsvp.Version = id, version
+		rsvps = append(rsvps, rsvp)
+		return nil
+	})
+	return rsvps, err
+}
+
+// rsvpExpires is when the RSVP expires, the start of its event while it
+// waits for approval, and never otherwise.
+func (s *SQLStore) rsvpExpires(q sqlQueryer, rsvp RSVP) (sql.NullInt64, error) {
+	var expires sql.NullInt64
+	if rsvp.Status != RSVPStatusPending {
+		return expires, nil
+	}
+	_, err := s.queryRow(q, `SELECT start_time FROM fridays WHERE uid = ?`, []any{rsvp.FridayID}, &expires)
+	return expires, err
+}
+
+// saveRSVP inserts the RSVP, or replaces it when update is set.
+func (s *SQLStore) saveRSVP(q sqlQueryer, rsvp RSVP, update bool) error {
+	expires, err := s.rsvpExpires(q, rsvp)
+	if err != nil {
+		return err
+	}
+	data, err := toJSON(rsvp)
+	if err != nil {
+		return err
+	}
+	if update {
+		_, err = s.exec(q, `UPDATE rsvps SET status = ?, invite_pending = ?, data = ?, version = ?, expires = ? WHERE id = ?`,
+			rsvp.Status, rsvp.InvitePending, data, rsvp.Version, expires, rsvp.ID)
+	} else {
+		_, err = s.exec(q, `INSERT INTO rsvps (id, email, friday, status, invite_pending, data, version, expires) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			rsvp.ID, rsvp.Email, rsvp.FridayID, rsvp.Status, rsvp.InvitePending, data, rsvp.Version, expires)
+	}
+	return err
+}
+
+func (s *SQLStore) recordRSVPChange(q sqlQueryer, changeType string, rsvp RSVP) error {
+	data, err := toJSON(rsvp)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(q, `INSERT INTO changes (id, ts, type, friday, rsvp_id, rsvp) VALUES (?, ?, ?, ?, ?, ?)`,
+		newSQLID(), time.Now().UnixMicro(), changeType, rsvp.FridayID, rsvp.ID, data)
+	return err
+}
+
+// lockFriday holds the event until the transaction ends, so RSVPs to it are
+// counted one at a time.
+func (s *SQLStore) lockFriday(tx *sql.Tx, fridayID string) error {
+	_, err := s.exec(tx, `UPDATE fridays SET version = version WHERE uid = ?`, fridayID)
+	return err
+}
+
+func (s *SQLStore) CreateFridayRSVP(rsvp RSVP, limit int) (RSVP, bool, error) {
+	created := false
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := s.lockFriday(tx, rsvp.FridayID); err != nil {
+			return err
+		}
+		// an expired RSVP no longer stops the friend making a new one
+		if _, err := s.exec(tx, `DELETE FROM rsvps WHERE email = ? AND friday = ? AND expires <= ?`,
+			rsvp.Email, rsvp.FridayID, time.Now().UnixMicro()); err != nil {
+			return err
+		}
+		rsvps, err := s.scanRSVPs(s.query(tx, `SELECT `+rsvpColumns+` FROM rsvps WHERE friday = ?`, rsvp.FridayID))
+		if err != nil {
+			return err
+		}
+		for _, existing := range rsvps {
+			if existing.Email == rsvp.Email {
+				rsvp = existing
+				return nil
+			}
+		}
+		if limit > 0 && Headcount(rsvps)+rsvp.Guests() > limit {
+			rsvp.Status = RSVPStatusPending
+		}
+		rsvp.ID = newSQLID()
+		rsvp.UpdatedAt = time.Now()
+		rsvp.Version = rsvp.UpdatedAt.UnixMicro()
+		if err = s.saveRSVP(tx, rsvp, false); err != nil {
+			return err
+		}
+		created = true
+		return s.recordRSVPChange(tx, ChangeRSVPCreated, rsvp)
+	})
+	return rsvp, created, err
+}
+
+func (s *SQLStore) ImportFridayRSVP(rsvp RSVP) (bool, error) {
+	data, err := toJSON(rsvp)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.exec(s.db, `INSERT INTO rsvps (id, email, friday, status, invite_pending, data, version) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email, friday) DO NOTHING`,
+		newSQLID(), rsvp.Email, rsvp.FridayID, rsvp.Status, rsvp.InvitePending, data, time.Now().UnixMicro())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLStore) GetFridayRSVP(id string) (*RSVP, error) {
+	return s.getFridayRSVP(s.db, id)
+}
+
+func (s *SQLStore) getFridayRSVP(q sqlQueryer, id string) (*RSVP, error) {
+	rsvps, err := s.scanRSVPs(s.query(q, `SELECT `+rsvpColumns+` FROM rsvps WHERE id = ?`, id))
+	if err != nil || len(rsvps) == 0 {
+		return nil, err
+	}
+	return &rsvps[0], nil
+}
+
+func (s *SQLStore) UpdateFridayRSVP(rsvp RSVP) error {
+	return s.updateFridayRSVP(rsvp, 0)
+}
+
+func (s *SQLStore) EditFridayRSVP(rsvp RSVP, limit int) error {
+	return s.updateFridayRSVP(rsvp, limit)
+}
+
+func (s *SQLStore) updateFridayRSVP(rsvp RSVP, limit int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if err := s.lockFriday(tx, rsvp.FridayID); err != nil {
+			return err
+		}
+		stored, err := s.getFridayRSVP(tx, rsvp.ID)
+		if err != nil {
+			return err
+		} else if stored == nil {
+			return ErrRSVPNotFound
+		} else if rsvp.Version != 0 && rsvp.Version != stored.Version {
+			return ErrRSVPConflict
+		}
+		before := 0
+		if stored.Confirmed() {
+			before = stored.Guests()
+		}
+		// shrinking always fits, even if the limit was lowered below the headcount
+		if limit > 0 && rsvp.Confirmed() && rsvp.Guests() > before {
+			rsvps, err := s.scanRSVPs(s.query(tx, `SELECT `+rsvpColumns+` FROM rsvps WHERE friday = ?`, rsvp.FridayID))
+			if err != nil {
+				return err
+			}
+			if Headcount(rsvps)-before+rsvp.Guests() > limit {
+				return ErrRSVPFull
+			}
+		}
+		rsvp.UpdatedAt = time.Now()
+		rsvp.Version = nextSQLVersion(stored.Version)
+		if err = s.saveRSVP(tx, rsvp, true); err != nil {
+			return err
+		}
+		return s.recordRSVPChange(tx, ChangeRSVPUpdated, rsvp)
+	})
+}
+
+// updateRSVPData lets update change the RSVP with the ID and saves it,
+// without recording a change.
+func (s *SQLStore) updateRSVPData(id string, update func(rsvp *RSVP)) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		rsvp, err := s.getFridayRSVP(tx, id)
+		if err != nil {
+			return err
+		} else if rsvp == nil {
+			return ErrRSVPNotFound
+		}
+		update(rsvp)
+		rsvp.Version = nextSQLVersion(rsvp.Version)
+		return s.saveRSVP(tx, *rsvp, true)
+	})
+}
+
+func (s *SQLStore) SetRSVPCheckedIn(id string, checkedIn bool) error {
+	return s.updateRSVPData(id, func(rsvp *RSVP) { rsvp.CheckedIn = checkedIn })
+}
+
+func (s *SQLStore) SetRSVPArrival(id string, arrival time.Time, late bool) error {
+	return s.updateRSVPData(id, func(rsvp *RSVP) { rsvp.Arrival, rsvp.Late = arrival, late })
+}
+
+func (s *SQLStore) DeleteFridayRSVP(id string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		rsvp, err := s.getFridayRSVP(tx, id)
+		if err != nil {
+			return err
+		} else if rsvp == nil {
+			return ErrRSVPNotFound
+		}
+		if _, err = s.exec(tx, `DELETE FROM rsvps WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return s.recordRSVPChange(tx, ChangeRSVPDeleted, *rsvp)
+	})
+}
+
+func (s *SQLStore) ListFridayRSVPs(fridayID string) ([]RSVP, error) {
+	return s.scanRSVPs(s.query(s.db, `SELECT `+rsvpColumns+` FROM rsvps WHERE friday = ?`, fridayID))
+}
+
+func (s *SQLStore) ListFriendRSVPs(friendEmail string) ([]RSVP, error) {
+	return s.scanRSVPs(s.query(s.db, `SELECT `+rsvpColumns+` FROM rsvps WHERE email = ?`, friendEmail))
+}
+
+func (s *SQLStore) ListPendingRSVPs() ([]RSVP, error) {
+	return s.scanRSVPs(s.query(s.db, `SELECT `+rsvpColumns+` FROM rsvps WHERE status = ?`, RSVPStatusPending))
+}
+
+func (s *SQLStore) ListInvitePendingRSVPs() ([]RSVP, error) {
+	return s.scanRSVPs(s.query(s.db, `SELECT `+rsvpColumns+` FROM rsvps WHERE invite_pending = ?`, true))
+}
+
+// SearchComments reads every RSVP, since the answers are only kept in their
+// JSON.
+func (s *SQLStore) SearchComments(query string) ([]RSVP, error) {
+	rsvps, err := s.scanRSVPs(s.query(s.db, `SELECT `+rsvpColumns+` FROM rsvps`))
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	var matches []RSVP
+	for _, rsvp := range rsvps {
+		for _, answer := range rsvp.Answers {
+			if strings.Contains(strings.ToLower(answer), query) {
+				matches = append(matches, rsvp)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// changeColumns are what scanChanges reads.
+const changeColumns = `id, ts, type, friday, rsvp_id, rsvp`
+
+func (s *SQLStore) scanChanges(rows *sql.Rows, err error) ([]Change, error) {
+	changes := []Change{}
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var change Change
+		var rsvp sql.NullString
+		if err := rows.Scan(&change.ID, &change.TS, &change.Type, &change.FridayID, &change.RSVPID, &rsvp); err != nil {
+			return err
+		}
+		if rsvp.Valid {
+			change.RSVP = &RSVP{}
+			if err := json.Unmarshal([]byte(rsvp.String), change.RSVP); err != nil {
+				return err
+			}
+			change.RSVP.ID = change.RSVPID
+		}
+		change.Cursor = fmt.Sprintf("%d-%s", change.TS, change.ID)
+		change.At = time.UnixMicro(change.TS)
+		changes = append(changes, change)
+		return nil
+	})
+	return changes, err
+}
+
+func (s *SQLStore) ListChanges(cursor string, limit int) ([]Change, string, error) {
+	cursorTS, cursorID, hasCursor := parseChangeCursor(cursor)
+	if !hasCursor {
+		cursorTS = -1 << 63
+	}
+	changes, err := s.scanChanges(s.query(s.db, `SELECT `+changeColumns+` FROM changes
+		WHERE ts > ? OR (ts = ? AND id > ?) ORDER BY ts, id LIMIT ?`, cursorTS, cursorTS, cursorID, limit))
+	if err != nil {
+		return nil, cursor, err
+	}
+	if len(changes) > 0 {
+		cursor = changes[len(changes)-1].Cursor
+	}
+	return changes, cursor, nil
+}
+
+func (s *SQLStore) ListRecentChanges(limit int) ([]Change, error) {
+	return s.scanChanges(s.query(s.db, `SELECT `+changeColumns+` FROM changes ORDER BY ts DESC, id DESC LIMIT ?`, limit))
+}
+
+func (s *SQLStore) CreateNotification(n Notification) error {
+	_, err := s.exec(s.db, `INSERT INTO notifications (id, email, friday, kind, created_at, notified_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email, friday, kind) DO NOTHING`,
+		newSQLID(), n.Email, n.FridayID, n.Kind, time.Now().UnixMicro(), sqlTime(n.NotifiedAt))
+	return err
+}
+
+func (s *SQLStore) ListNotifications(fridayID, kind string) ([]Notification, error) {
+	var notifications []Notification
+	rows, err := s.query(s.db, `SELECT id, email, friday, kind, created_at, notified_at FROM notifications
+		WHERE friday = ? AND kind = ? ORDER BY created_at`, fridayID, kind)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var n Notification
+		var createdAt, notifiedAt int64
+		err := rows.Scan(&n.ID, &n.Email, &n.FridayID, &n.Kind, &createdAt, &notifiedAt)
+		n.CreatedAt, n.NotifiedAt = fromSQLTime(createdAt), fromSQLTime(notifiedAt)
+		notifications = append(notifications, n)
+		return err
+	})
+	return notifications, err
+}
+
+func (s *SQLStore) UpdateNotification(n Notification) error {
+	_, err := s.exec(s.db, `UPDATE notifications SET email = ?, friday = ?, kind = ?, notified_at = ? WHERE id = ?`,
+		n.Email, n.FridayID, n.Kind, sqlTime(n.NotifiedAt), n.ID)
+	return err
+}
+
+func (s *SQLStore) DeleteNotification(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM notifications WHERE id = ?`, id)
+	return err
+}
+
+// getDocument reads the document with the name into v, returning false if it
+// was never saved.
+func (s *SQLStore) getDocument(q sqlQueryer, name string, v any) (bool, error) {
+	var data string
+	found, err := s.queryRow(q, `SELECT data FROM documents WHERE name = ?`, []any{name}, &data)
+	if err != nil || !found {
+		return false, err
+	}
+	if err = json.Unmarshal([]byte(data), v); err != nil {
+		Log.Error("sql decode error", zap.Error(err))
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLStore) saveDocument(q sqlQueryer, name string, v any) error {
+	data, err := toJSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(q, `INSERT INTO documents (name, data) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET data = excluded.data`, name, data)
+	return err
+}
+
+// updateDocument reads the document with the name into v, lets update change
+// it, and saves it, with the document locked in between.
+func (s *SQLStore) updateDocument(name string, v any, update func(found bool)) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `UPDATE documents SET name = name WHERE name = ?`, name); err != nil {
+			return err
+		}
+		found, err := s.getDocument(tx, name, v)
+		if err != nil {
+			return err
+		}
+		update(found)
+		return s.saveDocument(tx, name, v)
+	})
+}
+
+func (s *SQLStore) GetSettings() (map[string]string, error) {
+	settings := map[string]string{}
+	_, err := s.getDocument(s.db, "settings", &settings)
+	return settings, err
+}
+
+func (s *SQLStore) SaveSettings(settings map[string]string) error {
+	return s.saveDocument(s.db, "settings", settings)
+}
+
+func (s *SQLStore) GetOwnership() (Ownership, error) {
+	var own Ownership
+	_, err := s.getDocument(s.db, "ownership", &own)
+	return own, err
+}
+
+func (s *SQLStore) SaveOwnership(own Ownership) error {
+	return s.saveDocument(s.db, "ownership", own)
+}
+
+func (s *SQLStore) GetAdminAuth() (AdminAuth, error) {
+	var auth AdminAuth
+	_, err := s.getDocument(s.db, "admin_auth", &auth)
+	return auth, err
+}
+
+func (s *SQLStore) SaveAdminAuth(auth AdminAuth) error {
+	return s.saveDocument(s.db, "admin_auth", auth)
+}
+
+func (s *SQLStore) GetContent() (map[string]string, error) {
+	content := map[string]string{}
+	_, err := s.getDocument(s.db, "content", &content)
+	return content, err
+}
+
+func (s *SQLStore) SaveContent(content map[string]string) error {
+	return s.saveDocument(s.db, "content", content)
+}
+
+func (s *SQLStore) GetAnnouncementTemplates() ([]AnnouncementTemplate, error) {
+	templates := []AnnouncementTemplate{}
+	_, err := s.getDocument(s.db, "announcement_templates", &templates)
+	return templates, err
+}
+
+func (s *SQLStore) SaveAnnouncementTemplates(templates []AnnouncementTemplate) error {
+	return s.saveDocument(s.db, "announcement_templates", templates)
+}
+
+// alertsDocument and remindersDocument keep which were sent for which party
+// alongside them, like their Fauna documents.
+type alertsDocument struct {
+	Alerts []HeadcountAlert
+	Sent   []string
+}
+
+type remindersDocument struct {
+	Reminders []Reminder
+	Sent      []string
+}
+
+// sentSet is the set of the keys sent.
+func sentSet(keys []string) map[string]bool {
+	sent := map[string]bool{}
+	for _, key := range keys {
+		sent[key] = true
+	}
+	return sent
+}
+
+func (s *SQLStore) GetHeadcountAlerts() ([]HeadcountAlert, map[string]bool, error) {
+	doc := alertsDocument{Alerts: []HeadcountAlert{}}
+	if _, err := s.getDocument(s.db, "headcount_alerts", &doc); err != nil {
+		return nil, nil, err
+	}
+	return doc.Alerts, sentSet(doc.Sent), nil
+}
+
+func (s *SQLStore) SaveHeadcountAlerts(alerts []HeadcountAlert) error {
+	var doc alertsDocument
+	return s.updateDocument("headcount_alerts", &doc, func(bool) { doc.Alerts = alerts })
+}
+
+func (s *SQLStore) MarkHeadcountAlertSent(key string) error {
+	var doc alertsDocument
+	return s.updateDocument("headcount_alerts", &doc, func(bool) { doc.Sent = append(doc.Sent, key) })
+}
+
+func (s *SQLStore) GetReminderSchedule() ([]Reminder, map[string]bool, error) {
+	var doc remindersDocument
+	found, err := s.getDocument(s.db, "reminders", &doc)
+	if err != nil {
+		return nil, nil, err
+	} else if !found {
+		return DefaultReminders, map[string]bool{}, nil
+	}
+	return doc.Reminders, sentSet(doc.Sent), nil
+}
+
+func (s *SQLStore) SaveReminderSchedule(reminders []Reminder) error {
+	var doc remindersDocument
+	return s.updateDocument("reminders", &doc, func(bool) { doc.Reminders = reminders })
+}
+
+func (s *SQLStore) MarkRemindersSent(keys []string) error {
+	var doc remindersDocument
+	return s.updateDocument("reminders", &doc, func(found bool) {
+		if !found {
+			doc.Reminders = DefaultReminders
+		}
+		doc.Sent = append(doc.Sent, keys...)
+	})
+}
+
+func (s *SQLStore) CreateQuarantined(q Quarantined) error {
+	values, err := toJSON(q.Values)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(s.db, `INSERT INTO quarantine (id, form, form_values, reason, created_at) VALUES (?, ?, ?, ?, ?)`,
+		newSQLID(), q.Form, values, q.Reason, time.Now().UnixMicro())
+	return err
+}
+
+func (s *SQLStore) scanQuarantined(rows *sql.Rows, err error) ([]Quarantined, error) {
+	held := []Quarantined{}
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var q Quarantined
+		var values string
+		var createdAt int64
+		if err := rows.Scan(&q.ID, &q.Form, &values, &q.Reason, &createdAt); err != nil {
+			return err
+		}
+		q.CreatedAt = fromSQLTime(createdAt)
+		held = append(held, q)
+		return json.Unmarshal([]byte(values), &held[len(held)-1].Values)
+	})
+	return held, err
+}
+
+func (s *SQLStore) ListQuarantined() ([]Quarantined, error) {
+	return s.scanQuarantined(s.query(s.db, `SELECT id, form, form_values, reason, created_at FROM quarantine ORDER BY created_at DESC`))
+}
+
+func (s *SQLStore) GetQuarantined(id string) (*Quarantined, error) {
+	held, err := s.scanQuarantined(s.query(s.db, `SELECT id, form, form_values, reason, created_at FROM quarantine WHERE id = ?`, id))
+	if err != nil || len(held) == 0 {
+		return nil, err
+	}
+	return &held[0], nil
+}
+
+func (s *SQLStore) DeleteQuarantined(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM quarantine WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLStore) RecordEmailEvent(email, kind, event string) error {
+	_, err := s.exec(s.db, `INSERT INTO email_events (id, ts, email, kind, event) VALUES (?, ?, ?, ?, ?)`,
+		newSQLID(), time.Now().UnixMicro(), email, kind, event)
+	return err
+}
+
+func (s *SQLStore) ListEmailEvents(since time.Time) ([]EmailEvent, error) {
+	var events []EmailEvent
+	rows, err := s.query(s.db, `SELECT COALESCE(email, ''), kind, event, ts FROM email_events WHERE ts >= ? ORDER BY ts`,
+		since.UnixMicro())
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var e EmailEvent
+		err := rows.Scan(&e.Email, &e.Kind, &e.Event, &e.TS)
+		e.At = time.UnixMicro(e.TS)
+		events = append(events, e)
+		return err
+	})
+	return events, err
+}
+
+func (s *SQLStore) RecordAudit(action, target, ip string) error {
+	_, err := s.exec(s.db, `INSERT INTO audit (id, ts, action, target, ip) VALUES (?, ?, ?, ?, ?)`,
+		newSQLID(), time.Now().UnixMicro(), action, target, ip)
+	return err
+}
+
+func (s *SQLStore) ListAuditEntries(since time.Time) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	rows, err := s.query(s.db, `SELECT action, COALESCE(target, ''), COALESCE(ip, ''), ts FROM audit WHERE ts >= ? ORDER BY ts`,
+		since.UnixMicro())
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var entry AuditEntry
+		err := rows.Scan(&entry.Action, &entry.Target, &entry.IP, &entry.TS)
+		entry.At = time.UnixMicro(entry.TS)
+		entries = append(entries, entry)
+		return err
+	})
+	return entries, err
+}
+
+// ExpireLog deletes or clears the oldest 1000 entries of the log from before
+// the time, see Store. The fields are the names of the columns to clear.
+func (s *SQLStore) ExpireLog(log string, before time.Time, docType string, fields []string, dryRun bool) (int, error) {
+	if !containsString(sqlLogs, log) {
+		return 0, fmt.Errorf("no log %q", log)
+	}
+	query := `SELECT id FROM ` + log + ` WHERE ts < ?`
+	args := []any{before.UnixMicro()}
+	if len(docType) > 0 {
+		query += ` AND type = ?`
+		args = append(args, docType)
+	}
+	if len(fields) > 0 {
+		has := make([]string, len(fields))
+		for i, field := range fields {
+			has[i] = field + ` IS NOT NULL`
+		}
+		query += ` AND (` + strings.Join(has, ` OR `) + `)`
+	}
+	var ids []any
+	rows, err := s.query(s.db, query+` ORDER BY ts LIMIT 1000`, args...)
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var id string
+		err := rows.Scan(&id)
+		ids = append(ids, id)
+		return err
+	})
+	if err != nil || dryRun || len(ids) == 0 {
+		return len(ids), err
+	}
+	change := `DELETE FROM ` + log
+	if len(fields) > 0 {
+		cleared := make([]string, len(fields))
+		for i, field := range fields {
+			cleared[i] = field + ` = NULL`
+		}
+		change = `UPDATE ` + log + ` SET ` + strings.Join(cleared, `, `)
+	}
+	if _, err = s.exec(s.db, change+` WHERE id IN (`+sqlPlaceholders(len(ids))+`)`, ids...); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (s *SQLStore) CreatePasskey(key Passkey) error {
+	_, err := s.exec(s.db, `INSERT INTO passkeys (id, email, public_key, sign_count, name, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Email, key.PublicKey, int64(key.SignCount), key.Name, sqlTime(key.CreatedAt))
+	return err
+}
+
+func (s *SQLStore) scanPasskeys(rows *sql.Rows, err error) ([]Passkey, error) {
+	var keys []Passkey
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var key Passkey
+		var signCount, createdAt int64
+		err := rows.Scan(&key.ID, &key.Email, &key.PublicKey, &signCount, &key.Name, &createdAt)
+		key.SignCount, key.CreatedAt = uint32(signCount), fromSQLTime(createdAt)
+		keys = append(keys, key)
+		return err
+	})
+	return keys, err
+}
+
+func (s *SQLStore) GetPasskey(id string) (*Passkey, error) {
+	keys, err := s.scanPasskeys(s.query(s.db, `SELECT id, email, public_key, sign_count, name, created_at FROM passkeys WHERE id = ?`, id))
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+func (s *SQLStore) ListPasskeys(friendEmail string) ([]Passkey, error) {
+	return s.scanPasskeys(s.query(s.db, `SELECT id, email, public_key, sign_count, name, created_at FROM passkeys WHERE email = ? ORDER BY created_at`, friendEmail))
+}
+
+func (s *SQLStore) UpdatePasskeySignCount(id string, signCount uint32) error {
+	_, err := s.exec(s.db, `UPDATE passkeys SET sign_count = ? WHERE id = ?`, int64(signCount), id)
+	return err
+}
+
+func (s *SQLStore) DeletePasskey(friendEmail, id string) error {
+	_, err := s.exec(s.db, `DELETE FROM passkeys WHERE id = ? AND email = ?`, id, friendEmail)
+	return err
+}
+
+func (s *SQLStore) CreateDevice(d Device) error {
+	_, err := s.exec(s.db, `INSERT INTO devices (id, token, prev, email, user_agent, created_at, rotated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		newSQLID(), d.Token, d.Prev, d.Email, d.UserAgent, sqlTime(d.CreatedAt), sqlTime(d.RotatedAt))
+	return err
+}
+
+func (s *SQLStore) scanDevices(rows *sql.Rows, err error) ([]Device, error) {
+	var devices []Device
+	err = scanAll(rows, err, func(rows *sql.Rows) error {
+		var d Device
+		var createdAt, rotatedAt int64
+		err := rows.Scan(&d.ID, &d.Token, &d.Prev, &d.Email, &d.UserAgent, &createdAt, &rotatedAt)
+		d.CreatedAt, d.RotatedAt = fromSQLTime(createdAt), fromSQLTime(rotatedAt)
+		devices = append(devices, d)
+		return err
+	})
+	return devices, err
+}
+
+// GetDeviceByToken prefers the device whose current token has the hash.
+func (s *SQLStore) GetDeviceByToken(hash string) (*Device, error) {
+	devices, err := s.scanDevices(s.query(s.db, `SELECT id, token, prev, email, user_agent, created_at, rotated_at FROM devices
+		WHERE token = ? OR prev = ?`, hash, hash))
+	if err != nil || len(devices) == 0 {
+		return nil, err
+	}
+	sort.SliceStable(devices, func(i, j int) bool { return devices[i].Token == hash && devices[j].Token != hash })
+	return &devices[0], nil
+}
+
+func (s *SQLStore) RotateDevice(id, prev, token string) error {
+	_, err := s.exec(s.db, `UPDATE devices SET token = ?, prev = ?, rotated_at = ? WHERE id = ?`,
+		token, prev, time.Now().UnixMicro(), id)
+	return err
+}
+
+func (s *SQLStore) ListDevices(friendEmail string) ([]Device, error) {
+	return s.scanDevices(s.query(s.db, `SELECT id, token, prev, email, user_agent, created_at, rotated_at FROM devices
+		WHERE email = ? ORDER BY rotated_at DESC`, friendEmail))
+}
+
+func (s *SQLStore) DeleteDevice(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM devices WHERE id = ?`, id)
+	return err
+}
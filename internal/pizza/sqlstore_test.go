@@ -0,0 +1,61 @@
+package pizza_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestOpenSQLStoreWithoutDriver(t *testing.T) {
+	// WHEN
+	store, err := pizza.OpenSQLStore("sqlite", "file:pizza.db")
+
+	// THEN
+	assert.Nil(t, store)
+	assert.ErrorIs(t, err, pizza.ErrNoSQLDriver)
+}
+
+func TestRebind(t *testing.T) {
+	// GIVEN
+	query := `UPDATE rsvps SET data = ?, version = ? WHERE id = ?`
+
+	// THEN postgres numbers its placeholders
+	assert.Equal(t, query, pizza.Rebind(query, false))
+	assert.Equal(t, `UPDATE rsvps SET data = $1, version = $2 WHERE id = $3`, pizza.Rebind(query, true))
+}
+
+func TestSQLStoreRSVPs(t *testing.T) {
+	// GIVEN a party of four in SQLite
+	store, err := pizza.OpenSQLStore("sqlite3", "file:"+filepath.Join(t.TempDir(), "pizza.db"))
+	require.NoError(t, err)
+	require.NoError(t, store.CreateFriday(pizza.Friday{UID: "party", Start: time.Now().Add(48 * time.Hour), Capacity: 4}))
+	ada, created, err := store.CreateFridayRSVP(pizza.RSVP{Email: "ada@example.com", FridayID: "party", PlusOnes: 2}, 4)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	// WHEN another friend brings more than fits, and an old copy of the first RSVP is saved
+	basil, created, err := store.CreateFridayRSVP(pizza.RSVP{Email: "basil@example.com", FridayID: "party", PlusOnes: 1}, 4)
+	require.NoError(t, err)
+	require.True(t, created)
+	ada.PlusOnes = 4
+	grown := store.EditFridayRSVP(ada, 4)
+	ada.PlusOnes = 1
+	require.NoError(t, store.EditFridayRSVP(ada, 4))
+	stale := store.UpdateFridayRSVP(ada)
+
+	// THEN they wait for approval, the party can't be overbooked, and the old copy conflicts
+	assert.Equal(t, pizza.RSVPStatusPending, basil.Status)
+	assert.Equal(t, pizza.ErrRSVPFull, grown)
+	assert.Equal(t, pizza.ErrRSVPConflict, stale)
+	rsvps, err := store.ListFridayRSVPs("party")
+	require.NoError(t, err)
+	assert.Len(t, rsvps, 2)
+	changes, _, err := store.ListChanges("", 10)
+	require.NoError(t, err)
+	assert.Len(t, changes, 3)
+}
@@ -0,0 +1,95 @@
+package pizza
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/static"
+)
+
+// staticFS is the static directory at dir, or the one built into the binary
+// when dir is empty.
+func staticFS(dir string) fs.FS {
+	if len(dir) == 0 {
+		return static.FS
+	}
+	return os.DirFS(dir)
+}
+
+// StaticMaxAge is how long browsers may cache files under /static/.
+var StaticMaxAge = time.Hour
+
+// precompressed are the encodings that may be served from a file next to the
+// original with the extension, in order of preference.
+var precompressed = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// FileServer serves the files in dir, or the built in ones when dir is empty,
+// with the Cache-Control header. Unlike
+// http.FileServer it doesn't list directories or serve dotfiles, and it serves
+// a .br or .gz file next to the requested one to clients that accept it.
+func FileServer(dir, cacheControl string) http.Handler {
+	fsys := staticFS(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || strings.Contains(name, "/.") {
+			http.NotFound(w, r)
+			return
+		}
+		file := strings.TrimPrefix(name, "/")
+		info, err := fs.Stat(fsys, file)
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Add("Vary", "Accept-Encoding")
+		for _, p := range precompressed {
+			if !acceptsEncoding(r.Header.Get("Accept-Encoding"), p.encoding) {
+				continue
+			}
+			if cinfo, err := fs.Stat(fsys, file+p.ext); err == nil && !cinfo.IsDir() {
+				if contentType := mime.TypeByExtension(path.Ext(file)); len(contentType) > 0 {
+					w.Header().Set("Content-Type", contentType)
+				}
+				w.Header().Set("Content-Encoding", p.encoding)
+				file, info = file+p.ext, cinfo
+				break
+			}
+		}
+
+		f, err := fsys.Open(file)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		// both files on disk and built in files can seek
+		content, ok := f.(io.ReadSeeker)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+	})
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
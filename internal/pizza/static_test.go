@@ -0,0 +1,70 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileServer(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "css"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css/index.css"), []byte("body {}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css/index.css.br"), []byte("brotli"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("secret"), 0o644))
+	handler := pizza.FileServer(dir, "public, max-age=60")
+	get := func(path, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// WHEN
+	plain := get("/css/index.css", "")
+	br := get("/css/index.css", "gzip, br")
+	noBr := get("/css/index.css", "br;q=0")
+	dotfile := get("/.env", "")
+	escape := get("/../css/.././.env", "")
+	listing := get("/css/", "")
+	dir2 := get("/css", "")
+
+	// THEN
+	assert.Equal(t, http.StatusOK, plain.Code)
+	assert.Equal(t, "body {}", plain.Body.String())
+	assert.Equal(t, "public, max-age=60", plain.Header().Get("Cache-Control"))
+	assert.Equal(t, "brotli", br.Body.String())
+	assert.Equal(t, "br", br.Header().Get("Content-Encoding"))
+	assert.Contains(t, br.Header().Get("Content-Type"), "text/css")
+	assert.Equal(t, "body {}", noBr.Body.String())
+	assert.Equal(t, http.StatusNotFound, dotfile.Code)
+	assert.Equal(t, http.StatusNotFound, escape.Code)
+	assert.Equal(t, http.StatusNotFound, listing.Code)
+	assert.Equal(t, http.StatusNotFound, dir2.Code)
+}
+
+func TestBuiltInStatic(t *testing.T) {
+	// GIVEN
+	handler := pizza.FileServer("", "public, max-age=60")
+	w := httptest.NewRecorder()
+
+	// WHEN
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/css/index.css", nil))
+
+	// THEN the binary has everything the static directory has
+	assert.Equal(t, http.StatusOK, w.Code)
+	css, err := os.ReadFile("../../static/css/index.css")
+	require.NoError(t, err)
+	assert.Equal(t, string(css), w.Body.String())
+	assert.Empty(t, pizza.CheckStaticDir(""))
+	assert.Empty(t, pizza.CheckTemplates(""))
+	assert.Empty(t, pizza.CheckTemplateRenders(""))
+}
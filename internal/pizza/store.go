@@ -0,0 +1,781 @@
+package pizza
+
+import (
+	"errors"
+	"time"
+)
+
+// Store keeps everything the server remembers. FaunaStore is used unless
+// database.driver is set, in which case a SQLStore is, or the server runs as
+// a sandbox, in which case a SandboxStore is.
+type Store interface {
+	FriendStore
+	EventStore
+	RSVPStore
+	NotificationStore
+	DocumentStore
+	QuarantineStore
+	LogStore
+	LoginStore
+	// Ping checks the database can be reached with the server's credentials.
+	Ping() error
+}
+
+// FriendStore keeps the friends and what the host and they saved about
+// themselves.
+type FriendStore interface {
+	// IsFriendAllowed reports whether the email, or an alias of it, belongs
+	// to a friend.
+	IsFriendAllowed(friendEmail string) (bool, error)
+	GetFriendName(friendEmail string) (string, error)
+	AddFriend(name, email string) error
+	AddReferredFriend(name, email, referrer string) error
+	DeleteFriend(friendEmail string) error
+	// PurgeFriend removes the friend along with their notifications,
+	// reactions, passkeys, devices, and email events, but not their RSVPs.
+	PurgeFriend(friendEmail string) error
+	SearchFriends(query string) ([]Friend, error)
+	// GetPrimaryEmail returns an empty string for strangers.
+	GetPrimaryEmail(email string) (string, error)
+	GetFriendAliases(friendEmail string) ([]string, error)
+	AddFriendAlias(friendEmail, alias string) error
+	RemoveFriendAlias(friendEmail, alias string) error
+	// FlagFriendEmail does nothing for strangers.
+	FlagFriendEmail(issue EmailIssue) error
+	GetEmailStatus(friendEmail string) (string, error)
+	ListFlaggedFriends() ([]FlaggedFriend, error)
+	SetFriendDigest(friendEmail string, subscribed bool) error
+	GetFriendDigest(friendEmail string) (bool, error)
+	ListDigestFriends() ([]DigestFriend, error)
+	SetFriendBirthday(friendEmail, birthday string, noCelebrate bool) error
+	GetFriendBirthday(friendEmail string) (FriendBirthday, error)
+	ListFriendBirthdays() (map[string]string, error)
+	SetFriendNoTracking(friendEmail string, noTracking bool) error
+	GetFriendNoTracking(friendEmail string) (bool, error)
+	SetFriendNote(friendEmail, note string) error
+	GetFriendNote(friendEmail string) (string, error)
+	SetFriendGroups(friendEmail string, groups []string) error
+	GetFriendGroups(friendEmail string) ([]string, error)
+	SetFriendLabs(friendEmail string, labs []string) error
+	GetFriendLabs(friendEmail string) ([]string, error)
+	CountReferrals(friendEmail string) (int, error)
+	ListReferredFriends() ([]ReferredFriend, error)
+	// GetFriendByPhone returns an empty string if no friend has the phone.
+	GetFriendByPhone(phone string) (string, error)
+	GetFriendPhone(friendEmail string) (string, error)
+	SetFriendPhone(friendEmail, phone string) error
+	CreateRSVP(friendEmail, code string, pendingDates []time.Time, expires time.Time) error
+	// ConfirmRSVP returns ErrRSVPCodeExpired for codes expired before
+	// notAfter.
+	ConfirmRSVP(friendEmail, code string, notAfter time.Time) error
+	ClearExpiredRSVPCodes(before time.Time) (int, error)
+}
+
+// EventStore keeps the events and the reactions to them.
+type EventStore interface {
+	GetAllFridays() ([]Friday, error)
+	// GetUpcomingFridays returns the events starting from now until daysAhead
+	// days after tomorrow, in order.
+	GetUpcomingFridays(daysAhead int) ([]Friday, error)
+	// GetFriday returns nil if there is no event with the ID.
+	GetFriday(id string) (*Friday, error)
+	// GetFridayAt returns nil if no event starts at exactly start.
+	GetFridayAt(start time.Time) (*Friday, error)
+	CreateFriday(friday Friday) error
+	// DeleteFriday returns ErrRSVPNotFound if there is no event with the ID.
+	DeleteFriday(id string) error
+	MigrateFridayUIDs(dryRun bool) (int, error)
+	// AddFridayImage and the other setters of an event's fields return
+	// ErrRSVPNotFound if there is no event with the ID.
+	AddFridayImage(id string, img Image, cover bool) error
+	SetFridayReminders(id string, reminders []Reminder) error
+	SetFridayTables(id string, tables [][]string) error
+	SetFridayAnnouncement(id, announcement string) error
+	SetFridayCohosts(id string, cohosts []string) error
+	SetReaction(r Reaction) error
+	ListReactions(fridayID string) ([]Reaction, error)
+}
+
+// RSVPStore keeps the RSVPs to each event and the feed of their changes.
+type RSVPStore interface {
+	// CreateFridayRSVP returns the friend's existing RSVP for the event if
+	// they have one, and stores RSVPs that would take the headcount over the
+	// limit as pending. It reports whether the RSVP was created.
+	CreateFridayRSVP(rsvp RSVP, limit int) (RSVP, bool, error)
+	ImportFridayRSVP(rsvp RSVP) (bool, error)
+	// GetFridayRSVP returns nil if there is no RSVP with the ID.
+	GetFridayRSVP(id string) (*RSVP, error)
+	// UpdateFridayRSVP returns ErrRSVPConflict if the RSVP changed since its
+	// Version was read.
+	UpdateFridayRSVP(rsvp RSVP) error
+	// EditFridayRSVP is UpdateFridayRSVP, but returns ErrRSVPFull if the
+	// RSVP is confirmed and its new guests would take the headcount over the
+	// limit, counted in the same transaction as the update.
+	EditFridayRSVP(rsvp RSVP, limit int) error
+	// SetRSVPCheckedIn and SetRSVPArrival return ErrRSVPNotFound if there is
+	// no RSVP with the ID.
+	SetRSVPCheckedIn(id string, checkedIn bool) error
+	SetRSVPArrival(id string, arrival time.Time, late bool) error
+	DeleteFridayRSVP(id string) error
+	ListFridayRSVPs(fridayID string) ([]RSVP, error)
+	ListFriendRSVPs(friendEmail string) ([]RSVP, error)
+	ListPendingRSVPs() ([]RSVP, error)
+	ListInvitePendingRSVPs() ([]RSVP, error)
+	SearchComments(query string) ([]RSVP, error)
+	// ListChanges returns up to limit changes after the cursor, oldest first,
+	// along with the cursor to resume from.
+	ListChanges(cursor string, limit int) ([]Change, string, error)
+	ListRecentChanges(limit int) ([]Change, error)
+}
+
+// NotificationStore keeps the friends' requests to hear about an event.
+type NotificationStore interface {
+	// CreateNotification does nothing if the friend already has a
+	// notification of the kind for the event.
+	CreateNotification(n Notification) error
+	// ListNotifications returns the notifications oldest first.
+	ListNotifications(fridayID, kind string) ([]Notification, error)
+	UpdateNotification(n Notification) error
+	DeleteNotification(id string) error
+}
+
+// DocumentStore keeps the documents there is only one of, like the settings.
+// Each getter returns the empty document until it is first saved.
+type DocumentStore interface {
+	GetSettings() (map[string]string, error)
+	SaveSettings(settings map[string]string) error
+	GetOwnership() (Ownership, error)
+	SaveOwnership(own Ownership) error
+	GetAdminAuth() (AdminAuth, error)
+	SaveAdminAuth(auth AdminAuth) error
+	GetContent() (map[string]string, error)
+	SaveContent(content map[string]string) error
+	GetAnnouncementTemplates() ([]AnnouncementTemplate, error)
+	SaveAnnouncementTemplates(templates []AnnouncementTemplate) error
+	GetHeadcountAlerts() ([]HeadcountAlert, map[string]bool, error)
+	SaveHeadcountAlerts(alerts []HeadcountAlert) error
+	MarkHeadcountAlertSent(key string) error
+	// GetReminderSchedule returns DefaultReminders until the schedule is
+	// first saved.
+	GetReminderSchedule() ([]Reminder, map[string]bool, error)
+	SaveReminderSchedule(reminders []Reminder) error
+	MarkRemindersSent(keys []string) error
+}
+
+// QuarantineStore keeps the public form submissions held as spam.
+type QuarantineStore interface {
+	CreateQuarantined(q Quarantined) error
+	// ListQuarantined returns the held submissions newest first.
+	ListQuarantined() ([]Quarantined, error)
+	// GetQuarantined returns nil if there is no submission with the ID.
+	GetQuarantined(id string) (*Quarantined, error)
+	DeleteQuarantined(id string) error
+}
+
+// LogStore keeps the logs the retention policies cover: the audit log, the
+// email events, and the changes feed.
+type LogStore interface {
+	RecordEmailEvent(email, kind, event string) error
+	ListEmailEvents(since time.Time) ([]EmailEvent, error)
+	RecordAudit(action, target, ip string) error
+	ListAuditEntries(since time.Time) ([]AuditEntry, error)
+	// ExpireLog deletes the entries of the log, "audit", "email_events", or
+	// "changes", from before the time, or when fields are given, clears
+	// those fields from the ones that still have any of them. Only entries of
+	// the type are covered, unless it is empty, and in a dry run nothing is
+	// changed. It returns how many entries were, or would be, changed, at
+	// most 1000 a run.
+	ExpireLog(log string, before time.Time, docType string, fields []string, dryRun bool) (int, error)
+}
+
+// LoginStore keeps the passkeys and devices friends log in with.
+type LoginStore interface {
+	CreatePasskey(key Passkey) error
+	// GetPasskey returns nil if there is no passkey with the ID.
+	GetPasskey(id string) (*Passkey, error)
+	ListPasskeys(friendEmail string) ([]Passkey, error)
+	UpdatePasskeySignCount(id string, signCount uint32) error
+	// DeletePasskey leaves passkeys of other friends alone.
+	DeletePasskey(friendEmail, id string) error
+	CreateDevice(d Device) error
+	// GetDeviceByToken returns nil if no device has the hash as its current
+	// or previous token.
+	GetDeviceByToken(hash string) (*Device, error)
+	RotateDevice(id, prev, token string) error
+	// ListDevices returns the devices most recently rotated first.
+	ListDevices(friendEmail string) ([]Device, error)
+	DeleteDevice(id string) error
+}
+
+var store Store = FaunaStore{}
+
+var ErrNoDatabase = errors.New("no FAUNADB_SECRET found and database.driver isn't set")
+
+// OpenStore keeps everything in the SQL database when database.driver is
+// set, and in Fauna otherwise.
+func OpenStore(config DatabaseConfig) error {
+	if len(config.Driver) == 0 {
+		if faunaClient == nil {
+			return ErrNoDatabase
+		}
+		return nil
+	}
+	sqlStore, err := OpenSQLStore(config.Driver, config.DSN)
+	if err != nil {
+		return err
+	}
+	store = sqlStore
+	return nil
+}
+
+func IsFriendAllowed(friendEmail string) (bool, error) {
+	if negativeFriendCache.Has(friendEmail) {
+		return false, nil
+	}
+	if positiveFriendCache.Has(friendEmail) {
+		return true, nil
+	}
+	exists, err := store.IsFriendAllowed(friendEmail)
+	if err == nil && !exists {
+		negativeFriendCache.Store(friendEmail, false)
+	}
+	return exists, err
+}
+
+func GetFriendName(friendEmail string) (string, error) {
+	return store.GetFriendName(friendEmail)
+}
+
+// AddFriend adds a friend so they can RSVP.
+func AddFriend(name, email string) error {
+	err := store.AddFriend(name, email)
+	if err == nil {
+		negativeFriendCache.Delete(email)
+	}
+	return err
+}
+
+// AddReferredFriend adds a newcomer to the friends, remembering who referred
+// them.
+func AddReferredFriend(name, email, referrer string) error {
+	err := store.AddReferredFriend(name, email, referrer)
+	if err == nil {
+		negativeFriendCache.Delete(email)
+	}
+	return err
+}
+
+// DeleteFriend removes the friend so they can no longer RSVP.
+func DeleteFriend(friendEmail string) error {
+	err := store.DeleteFriend(friendEmail)
+	if err == nil {
+		positiveFriendCache.Delete(friendEmail)
+	}
+	return err
+}
+
+// PurgeFriend removes the friend and everything stored about them but their
+// RSVPs, which are removed one at a time so the calendar follows.
+func PurgeFriend(friendEmail string) error {
+	err := store.PurgeFriend(friendEmail)
+	if err == nil {
+		positiveFriendCache.Delete(friendEmail)
+	}
+	return err
+}
+
+// SearchFriends finds friends whose name, email, or the host's note about them
+// contains the query, ignoring case.
+func SearchFriends(query string) ([]Friend, error) {
+	return store.SearchFriends(query)
+}
+
+// GetPrimaryEmail returns the email of the friend the address belongs to,
+// which is the address itself unless it is one of their aliases. It is empty
+// for strangers.
+func GetPrimaryEmail(email string) (string, error) {
+	return store.GetPrimaryEmail(email)
+}
+
+func GetFriendAliases(friendEmail string) ([]string, error) {
+	return store.GetFriendAliases(friendEmail)
+}
+
+// AddFriendAlias lets the friend use another email address.
+func AddFriendAlias(friendEmail, alias string) error {
+	return store.AddFriendAlias(friendEmail, alias)
+}
+
+func RemoveFriendAlias(friendEmail, alias string) error {
+	return store.RemoveFriendAlias(friendEmail, alias)
+}
+
+// FlagFriendEmail marks a friend's email as undeliverable so the host can
+// correct it.
+func FlagFriendEmail(issue EmailIssue) error {
+	err := store.FlagFriendEmail(issue)
+	if err == nil {
+		suppressedEmailCache.Store(issue.Email, issue.Status)
+	}
+	return err
+}
+
+// GetEmailStatus returns why mail to the friend is suppressed, or an empty
+// string if it can be delivered.
+func GetEmailStatus(friendEmail string) (string, error) {
+	return store.GetEmailStatus(friendEmail)
+}
+
+func ListFlaggedFriends() ([]FlaggedFriend, error) {
+	return store.ListFlaggedFriends()
+}
+
+// SetFriendDigest subscribes or unsubscribes the friend from the weekly digest.
+func SetFriendDigest(friendEmail string, subscribed bool) error {
+	return store.SetFriendDigest(friendEmail, subscribed)
+}
+
+func GetFriendDigest(friendEmail string) (bool, error) {
+	return store.GetFriendDigest(friendEmail)
+}
+
+func ListDigestFriends() ([]DigestFriend, error) {
+	return store.ListDigestFriends()
+}
+
+// SetFriendBirthday saves the friend's birthday, or clears it when empty.
+func SetFriendBirthday(friendEmail, birthday string, noCelebrate bool) error {
+	return store.SetFriendBirthday(friendEmail, birthday, noCelebrate)
+}
+
+func GetFriendBirthday(friendEmail string) (FriendBirthday, error) {
+	return store.GetFriendBirthday(friendEmail)
+}
+
+// ListFriendBirthdays returns the birthdays of the friends who want them
+// celebrated, by email.
+func ListFriendBirthdays() (map[string]string, error) {
+	return store.ListFriendBirthdays()
+}
+
+// SetFriendNoTracking turns click tracking in the friend's emails off or back
+// on.
+func SetFriendNoTracking(friendEmail string, noTracking bool) error {
+	return store.SetFriendNoTracking(friendEmail, noTracking)
+}
+
+func GetFriendNoTracking(friendEmail string) (bool, error) {
+	return store.GetFriendNoTracking(friendEmail)
+}
+
+// SetFriendNote sets the host's private note about a friend.
+func SetFriendNote(friendEmail, note string) error {
+	return store.SetFriendNote(friendEmail, note)
+}
+
+func GetFriendNote(friendEmail string) (string, error) {
+	return store.GetFriendNote(friendEmail)
+}
+
+// SetFriendGroups sets the groups the host tagged the friend with, such as
+// "work", which the seating plan keeps together.
+func SetFriendGroups(friendEmail string, groups []string) error {
+	return store.SetFriendGroups(friendEmail, groups)
+}
+
+func GetFriendGroups(friendEmail string) ([]string, error) {
+	return store.GetFriendGroups(friendEmail)
+}
+
+// SetFriendLabs sets the labs features the friend turned on for themselves.
+func SetFriendLabs(friendEmail string, labs []string) error {
+	return store.SetFriendLabs(friendEmail, labs)
+}
+
+func GetFriendLabs(friendEmail string) ([]string, error) {
+	return store.GetFriendLabs(friendEmail)
+}
+
+// CountReferrals is how many newcomers joined through the friend's referral
+// link.
+func CountReferrals(friendEmail string) (int, error) {
+	return store.CountReferrals(friendEmail)
+}
+
+func ListReferredFriends() ([]ReferredFriend, error) {
+	return store.ListReferredFriends()
+}
+
+// GetFriendByPhone is the email of the friend with the phone number, or empty
+// if no friend has it.
+func GetFriendByPhone(phone string) (string, error) {
+	return store.GetFriendByPhone(phone)
+}
+
+// GetFriendPhone is the friend's phone number, or empty if they haven't
+// added one.
+func GetFriendPhone(friendEmail string) (string, error) {
+	return store.GetFriendPhone(friendEmail)
+}
+
+func SetFriendPhone(friendEmail, phone string) error {
+	return store.SetFriendPhone(friendEmail, phone)
+}
+
+// CreateRSVP holds the friend's pending dates until they confirm them with the
+// code, which works until expires.
+func CreateRSVP(friendEmail, code string, pendingDates []time.Time, expires time.Time) error {
+	return store.CreateRSVP(friendEmail, code, pendingDates, expires)
+}
+
+// ConfirmRSVP confirms the friend's pending dates and clears the code so it
+// can't be used again.
+func ConfirmRSVP(friendEmail, code string, notAfter time.Time) error {
+	return store.ConfirmRSVP(friendEmail, code, notAfter)
+}
+
+// ClearExpiredRSVPCodes removes the codes and pending dates of RSVPs that were
+// not confirmed before their code expired, returning how many were cleared.
+func ClearExpiredRSVPCodes(before time.Time) (int, error) {
+	return store.ClearExpiredRSVPCodes(before)
+}
+
+// GetAllFridays returns every event, past and upcoming, in order.
+func GetAllFridays() ([]Friday, error) {
+	return store.GetAllFridays()
+}
+
+func GetUpcomingFridays(daysAhead int) ([]Friday, error) {
+	return store.GetUpcomingFridays(daysAhead)
+}
+
+// GetFriday finds any event, past or upcoming, by its ID. It returns nil if
+// there is no such event.
+func GetFriday(id string) (*Friday, error) {
+	return store.GetFriday(id)
+}
+
+// GetFridayAt finds the event starting at exactly start, whatever its ID. It
+// returns nil if there is no such event.
+func GetFridayAt(start time.Time) (*Friday, error) {
+	return store.GetFridayAt(start)
+}
+
+func CreateFriday(friday Friday) error {
+	return store.CreateFriday(friday)
+}
+
+// DeleteFriday removes the event along with its RSVPs.
+func DeleteFriday(id string) error {
+	return store.DeleteFriday(id)
+}
+
+// MigrateFridayUIDs gives every event without a UID its old timestamp ID as a
+// UID, so links, RSVPs and calendar events made before UIDs keep working after
+// the event is rescheduled. It returns how many events were migrated, or with
+// dryRun how many would be without changing them.
+func MigrateFridayUIDs(dryRun bool) (int, error) {
+	return store.MigrateFridayUIDs(dryRun)
+}
+
+// AddFridayImage adds an uploaded photo to the event, or makes it the cover.
+func AddFridayImage(id string, img Image, cover bool) error {
+	return store.AddFridayImage(id, img, cover)
+}
+
+// SetFridayReminders replaces the reminders for the event, which then
+// follows the schedule again when they are empty.
+func SetFridayReminders(id string, reminders []Reminder) error {
+	return store.SetFridayReminders(id, reminders)
+}
+
+// SetFridayTables saves the seating plan for the event.
+func SetFridayTables(id string, tables [][]string) error {
+	return store.SetFridayTables(id, tables)
+}
+
+// SetFridayAnnouncement replaces the host's note for the event.
+func SetFridayAnnouncement(id, announcement string) error {
+	return store.SetFridayAnnouncement(id, announcement)
+}
+
+// SetFridayCohosts replaces the emails of the event's co-hosts.
+func SetFridayCohosts(id string, cohosts []string) error {
+	return store.SetFridayCohosts(id, cohosts)
+}
+
+// SetReaction replaces the friend's reaction to the Friday, or removes it when
+// the emoji is empty.
+func SetReaction(r Reaction) error {
+	return store.SetReaction(r)
+}
+
+func ListReactions(fridayID string) ([]Reaction, error) {
+	return store.ListReactions(fridayID)
+}
+
+// CreateFridayRSVP stores the RSVP unless the friend already has one for the
+// Friday, see Store.
+func CreateFridayRSVP(rsvp RSVP, limit int) (RSVP, bool, error) {
+	return store.CreateFridayRSVP(rsvp, limit)
+}
+
+// ImportFridayRSVP adds an RSVP from before the friend's RSVPs were kept,
+// unless they already have one for the Friday. It is left out of the changes
+// feed, since nothing changed. It reports whether the RSVP was added.
+func ImportFridayRSVP(rsvp RSVP) (bool, error) {
+	return store.ImportFridayRSVP(rsvp)
+}
+
+// GetFridayRSVP returns the RSVP with the given ID, or nil if there is none.
+func GetFridayRSVP(id string) (*RSVP, error) {
+	return store.GetFridayRSVP(id)
+}
+
+// UpdateFridayRSVP saves the RSVP. An RSVP that was read from the database is
+// only saved if nobody changed it since, otherwise ErrRSVPConflict is returned.
+func UpdateFridayRSVP(rsvp RSVP) error {
+	return store.UpdateFridayRSVP(rsvp)
+}
+
+// EditFridayRSVP saves the friend's change to their RSVP, like
+// UpdateFridayRSVP, as long as any guests they added still fit, see Store.
+func EditFridayRSVP(rsvp RSVP, limit int) error {
+	return store.EditFridayRSVP(rsvp, limit)
+}
+
+// SetRSVPCheckedIn marks whether the friend has arrived at the party.
+func SetRSVPCheckedIn(id string, checkedIn bool) error {
+	return store.SetRSVPCheckedIn(id, checkedIn)
+}
+
+// SetRSVPArrival saves when the friend expects to get to the party and
+// whether they're running late.
+func SetRSVPArrival(id string, arrival time.Time, late bool) error {
+	return store.SetRSVPArrival(id, arrival, late)
+}
+
+// DeleteFridayRSVP removes an RSVP.
+func DeleteFridayRSVP(id string) error {
+	return store.DeleteFridayRSVP(id)
+}
+
+func ListFridayRSVPs(fridayID string) ([]RSVP, error) {
+	return store.ListFridayRSVPs(fridayID)
+}
+
+// ListFriendRSVPs returns every RSVP the friend has made.
+func ListFriendRSVPs(friendEmail string) ([]RSVP, error) {
+	return store.ListFriendRSVPs(friendEmail)
+}
+
+// ListPendingRSVPs returns every RSVP waiting for the host's approval.
+func ListPendingRSVPs() ([]RSVP, error) {
+	return store.ListPendingRSVPs()
+}
+
+// ListInvitePendingRSVPs returns the RSVPs whose calendar invite is still to
+// be sent.
+func ListInvitePendingRSVPs() ([]RSVP, error) {
+	return store.ListInvitePendingRSVPs()
+}
+
+// SearchComments finds RSVPs with an answer to one of the party's questions
+// that contains the query, ignoring case.
+func SearchComments(query string) ([]RSVP, error) {
+	return store.SearchComments(query)
+}
+
+// ListChanges returns up to limit changes after the cursor, oldest first,
+// along with the cursor to resume from.
+func ListChanges(cursor string, limit int) ([]Change, string, error) {
+	return store.ListChanges(cursor, limit)
+}
+
+// ListRecentChanges returns the latest changes, newest first.
+func ListRecentChanges(limit int) ([]Change, error) {
+	return store.ListRecentChanges(limit)
+}
+
+// CreateNotification subscribes the friend unless they already are.
+func CreateNotification(n Notification) error {
+	return store.CreateNotification(n)
+}
+
+// ListNotifications returns the subscriptions of a kind for the Friday, oldest
+// first.
+func ListNotifications(fridayID, kind string) ([]Notification, error) {
+	return store.ListNotifications(fridayID, kind)
+}
+
+func UpdateNotification(n Notification) error {
+	return store.UpdateNotification(n)
+}
+
+func DeleteNotification(id string) error {
+	return store.DeleteNotification(id)
+}
+
+// GetSettings returns the settings overridden from the admin page by name.
+func GetSettings() (map[string]string, error) {
+	return store.GetSettings()
+}
+
+// SaveSettings replaces the settings overrides.
+func SaveSettings(settings map[string]string) error {
+	return store.SaveSettings(settings)
+}
+
+func GetOwnership() (Ownership, error) {
+	return store.GetOwnership()
+}
+
+// SaveOwnership replaces who owns the series.
+func SaveOwnership(own Ownership) error {
+	return store.SaveOwnership(own)
+}
+
+func GetAdminAuth() (AdminAuth, error) {
+	return store.GetAdminAuth()
+}
+
+// SaveAdminAuth replaces the admin's second factor.
+func SaveAdminAuth(auth AdminAuth) error {
+	return store.SaveAdminAuth(auth)
+}
+
+// GetContent returns the markdown of the index page sections by name.
+func GetContent() (map[string]string, error) {
+	return store.GetContent()
+}
+
+// SaveContent replaces the index page copy.
+func SaveContent(content map[string]string) error {
+	return store.SaveContent(content)
+}
+
+func GetAnnouncementTemplates() ([]AnnouncementTemplate, error) {
+	return store.GetAnnouncementTemplates()
+}
+
+// SaveAnnouncementTemplates replaces the announcement templates.
+func SaveAnnouncementTemplates(templates []AnnouncementTemplate) error {
+	return store.SaveAnnouncementTemplates(templates)
+}
+
+// GetHeadcountAlerts returns the alerts and the set of "<alert>:<friday>"
+// already sent.
+func GetHeadcountAlerts() ([]HeadcountAlert, map[string]bool, error) {
+	return store.GetHeadcountAlerts()
+}
+
+// SaveHeadcountAlerts replaces the alerts, keeping which were sent.
+func SaveHeadcountAlerts(alerts []HeadcountAlert) error {
+	return store.SaveHeadcountAlerts(alerts)
+}
+
+// MarkHeadcountAlertSent remembers an "<alert>:<friday>" was sent.
+func MarkHeadcountAlertSent(key string) error {
+	return store.MarkHeadcountAlertSent(key)
+}
+
+// GetReminderSchedule returns the reminders sent before every party, which
+// are DefaultReminders until the host changes them, and the set of
+// "<reminder>:<friday>" already sent.
+func GetReminderSchedule() ([]Reminder, map[string]bool, error) {
+	return store.GetReminderSchedule()
+}
+
+// SaveReminderSchedule replaces the reminder schedule, keeping which were
+// sent.
+func SaveReminderSchedule(reminders []Reminder) error {
+	return store.SaveReminderSchedule(reminders)
+}
+
+// MarkRemindersSent remembers each "<reminder>:<friday>" was sent.
+func MarkRemindersSent(keys []string) error {
+	return store.MarkRemindersSent(keys)
+}
+
+func CreateQuarantined(q Quarantined) error {
+	return store.CreateQuarantined(q)
+}
+
+// ListQuarantined returns the held submissions, newest first.
+func ListQuarantined() ([]Quarantined, error) {
+	return store.ListQuarantined()
+}
+
+func GetQuarantined(id string) (*Quarantined, error) {
+	return store.GetQuarantined(id)
+}
+
+func DeleteQuarantined(id string) error {
+	return store.DeleteQuarantined(id)
+}
+
+func RecordEmailEvent(email, kind, event string) error {
+	return store.RecordEmailEvent(email, kind, event)
+}
+
+// ListEmailEvents returns the email events since the time, oldest first.
+func ListEmailEvents(since time.Time) ([]EmailEvent, error) {
+	return store.ListEmailEvents(since)
+}
+
+// RecordAudit adds the action to the audit log.
+func RecordAudit(action, target, ip string) error {
+	return store.RecordAudit(action, target, ip)
+}
+
+// ListAuditEntries returns the audit log since the time, oldest first.
+func ListAuditEntries(since time.Time) ([]AuditEntry, error) {
+	return store.ListAuditEntries(since)
+}
+
+func CreatePasskey(key Passkey) error {
+	return store.CreatePasskey(key)
+}
+
+// GetPasskey is the passkey with the credential id, or nil if there is none.
+func GetPasskey(id string) (*Passkey, error) {
+	return store.GetPasskey(id)
+}
+
+func ListPasskeys(friendEmail string) ([]Passkey, error) {
+	return store.ListPasskeys(friendEmail)
+}
+
+func UpdatePasskeySignCount(id string, signCount uint32) error {
+	return store.UpdatePasskeySignCount(id, signCount)
+}
+
+// DeletePasskey removes the friend's passkey. Passkeys of other friends are
+// left alone.
+func DeletePasskey(friendEmail, id string) error {
+	return store.DeletePasskey(friendEmail, id)
+}
+
+func CreateDevice(d Device) error {
+	return store.CreateDevice(d)
+}
+
+// GetDeviceByToken is the device whose current or previous token has the hash,
+// or nil if there is none.
+func GetDeviceByToken(hash string) (*Device, error) {
+	return store.GetDeviceByToken(hash)
+}
+
+// RotateDevice replaces the device's token, keeping the old one as its
+// previous token.
+func RotateDevice(id, prev, token string) error {
+	return store.RotateDevice(id, prev, token)
+}
+
+func ListDevices(friendEmail string) ([]Device, error) {
+	return store.ListDevices(friendEmail)
+}
+
+func DeleteDevice(id string) error {
+	return store.DeleteDevice(id)
+}
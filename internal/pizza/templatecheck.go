@@ -0,0 +1,341 @@
+package pizza
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+var fixtureFriday = IndexFridayData{
+	Date:         "Fri Apr 7, 5:30 PM",
+	ID:           "1680903000",
+	StartISO:     "2023-04-07T17:30:00-04:00",
+	EndISO:       "2023-04-07T21:30:00-04:00",
+	Deadline:     "Fri Apr 7, 3:30 PM",
+	Cover:        Image{Key: "1680903000/cover", Widths: []int{320, 800}},
+	Announcement: template.HTML("<p>Bring a <strong>friend</strong></p>"),
+	Reactions:    []ReactionCount{{Emoji: "🍕", Count: 2, Mine: true}, {Emoji: "🎉"}},
+	Guests:       []int{0, 1, 2},
+}
+
+// TemplateFixtures are sample data for every template, the zero value and a
+// filled in one for each, to render them all before a deploy. Emails are
+// keyed by name, pages by path under static/.
+var TemplateFixtures = map[string][]any{
+	"html/index.html": {IndexPageData{}, IndexPageData{
+		FridayTimes: []IndexFridayData{fixtureFriday, {Date: "Fri Apr 14, 5:30 PM", ID: "1681507800", Closed: true, Full: true, Countdown: "in 7 days"}, {
+			Date: "Fri Apr 21, 5:30 PM to Sun Apr 23, 11:00 AM", ID: "01gxd2m9r0a1b2c3d4e5f6g7h8", Opens: "Fri Apr 14",
+			Days: []EventDay{{Date: "2023-04-21", Label: "Fri Apr 21"}, {Date: "2023-04-22", Label: "Sat Apr 22"}, {Date: "2023-04-23", Label: "Sun Apr 23"}},
+		}},
+		Email:         "believe@tedlasso.com",
+		InviteExpired: true,
+		InviteHint:    "b******@tedlasso.com",
+		FormToken:     "token",
+		Captcha:       &CaptchaWidget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", SiteKey: "key"},
+		View:          View{Friend: "believe@tedlasso.com", Flashes: []string{"You're logged out."}},
+		Content:       map[string]template.HTML{"welcome": "<p>Hi</p>", "rules": "<p>Be kind</p>", "faq": "<p>Pizza?</p>"},
+		LoginLink:     true,
+	}, IndexPageData{View: View{Friend: "believe@tedlasso.com"}}},
+	"html/lite.html": {IndexPageData{}, IndexPageData{
+		FridayTimes: []IndexFridayData{fixtureFriday, {Date: "Fri Apr 14, 5:30 PM", ID: "1681507800", Closed: true, Full: true}, {
+			Date: "Fri Apr 21, 5:30 PM to Sun Apr 23, 11:00 AM", ID: "01gxd2m9r0a1b2c3d4e5f6g7h8", Opens: "Fri Apr 14",
+			Days: []EventDay{{Date: "2023-04-21", Label: "Fri Apr 21"}, {Date: "2023-04-22", Label: "Sat Apr 22"}},
+		}},
+		Email:         "believe@tedlasso.com",
+		InviteExpired: true,
+		InviteHint:    "b******@tedlasso.com",
+		FormToken:     "token",
+		Captcha:       &CaptchaWidget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", SiteKey: "key"},
+	}, IndexPageData{View: View{Friend: "believe@tedlasso.com"}}},
+	"html/submit.html": {SubmitPageData{}, SubmitPageData{
+		RSVPs:     []SubmitRSVPData{{Date: "Fri Apr 7, 5:30 PM", EditURL: "/rsvp/1/edit?sig=x"}, {Date: "Fri Apr 14, 5:30 PM", Pending: true}, {Date: "Fri Apr 21, 5:30 PM", Queued: true}},
+		DigestURL: "/digest?sig=x",
+		ICalURL:   "https://rsvp.pizza/ical/x.y",
+	}, SubmitPageData{Held: true}, SubmitPageData{ConfirmSentTo: "b******@tedlasso.com"},
+		SubmitPageData{RSVPs: []SubmitRSVPData{{Date: "Fri Apr 14, 5:30 PM", Pending: true}}, Waitlist: true}},
+	"html/cancel.html": {CancelPageData{}, CancelPageData{ID: "1", Sig: "sig", Date: "Fri Apr 7, 5:30 PM", Confirm: true, Hint: "b******@tedlasso.com"},
+		CancelPageData{Date: "Fri Apr 7, 5:30 PM", Cancelled: true}, CancelPageData{Closed: true}},
+	"html/arrival.html": {ArrivalPageData{}, ArrivalPageData{ID: "1", Sig: "sig", Date: "Fri Apr 7, 5:30 PM", Confirm: true, Hint: "b******@tedlasso.com", Arrival: "19:15"},
+		ArrivalPageData{Arrival: "19:15", Late: true, Saved: true}, ArrivalPageData{Error: "Pick a time before the party ends."}, ArrivalPageData{Closed: true}},
+	"html/transfer.html": {TransferPageData{}, TransferPageData{To: "roy@kent.com", Expires: "1680903000", Sig: "sig", Hint: "r**@kent.com", Owner: "believe@tedlasso.com", Error: "The passwords didn't match."},
+		TransferPageData{Accepted: true}, TransferPageData{Closed: true}},
+	"html/confirm.html": {ConfirmPageData{}, ConfirmPageData{Query: "email=believe%40tedlasso.com&sig=x", Dates: []string{"Fri Apr 7, 5:30 PM"}},
+		ConfirmPageData{Expired: true}},
+	"html/edit.html": {EditPageData{}, EditPageData{
+		ID: "1", Email: "believe@tedlasso.com", Sig: "sig", Hint: "b******@tedlasso.com", Date: "Fri Apr 7, 5:30 PM", PlusOnes: 2, Kids: 1,
+		Toppings: []EditOptionData{{Name: "pepperoni", Checked: true}, {Name: "mushroom"}},
+		Answers:  []EditAnswerData{{Question: "Bringing drinks?", Answer: "yes"}},
+		View:     View{Flashes: []string{"Your RSVP for Fri Apr 7, 5:30 PM has been updated."}}, RecapURL: "/recap/1680903000?sig=x",
+	}, EditPageData{Confirm: true, Hint: "b******@tedlasso.com"}, EditPageData{Closed: true, CancelURL: "/cancel?rsvp=1&sig=x", ArrivalURL: "/arrival?rsvp=1&sig=x", ICalURL: "https://rsvp.pizza/ical/x.y"}, EditPageData{Conflict: true, Typed: true, Email: "believe@tedlasso.com"}, EditPageData{Full: true}, EditPageData{
+		Drinks: []DrinkTally{{Category: "beer", Count: 12, Bringers: []string{"Ted Lasso", "Roy Kent"}, Mine: 6}, {Category: "wine"}},
+	}},
+	"html/4xx.html":         {ErrorPageData{}, ErrorPageData{View: View{Flashes: []string{"That link expired."}}}},
+	"html/invalid.html":     {InvalidPageData{}, InvalidPageData{Fields: []FieldError{{"kids", "must be at most 4"}}}},
+	"html/500.html":         {ErrorPageData{}},
+	"html/maintenance.html": {MaintenancePageData{}},
+	"html/preview.html":     {PreviewPageData{}, PreviewPageData{Title: "Pizza Friday", Description: "RSVP for pizza"}},
+	"html/digest.html":      {DigestPageData{}, DigestPageData{Email: "believe@tedlasso.com", Sig: "sig", CSRFToken: "csrf", Subscribed: true, NoTracking: true, Birthday: FriendBirthday{Birthday: "04-08", NoCelebrate: true}, Error: "birthdays look like 04-08", Saved: true}},
+	"html/recap.html": {RecapPageData{}, RecapPageData{
+		Date: "Fri Apr 7, 5:30 PM", Headcount: 5, Guests: []string{"Ted Lasso", "Roy Kent"},
+		Toppings: []ToppingCount{{Topping: "pepperoni", Votes: 3}}, Photos: []string{"https://example.com/1.jpg"},
+		Images: []Image{{Key: "1680903000/a", Widths: []int{320, 800, 1600}}}, ShareURL: "/recap/1680903000",
+	}},
+	"html/notify.html": {NotifyPageData{}, NotifyPageData{
+		FridayID: "1680903000", Kind: "open", Date: "Fri Apr 7, 5:30 PM", Opens: "Fri Mar 31", Email: "believe@tedlasso.com",
+		FormToken: "token", Captcha: &CaptchaWidget{Script: "https://challenges.cloudflare.com/turnstile/v0/api.js", Class: "cf-turnstile", SiteKey: "key"},
+	}, NotifyPageData{Subscribed: true, Kind: "spot"}, NotifyPageData{Held: true}},
+	"html/invite.html": {InvitePageData{}, InvitePageData{
+		Expired: true, FormToken: "token", Captcha: &CaptchaWidget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", SiteKey: "key"},
+	}, InvitePageData{Sent: true}},
+	"html/join.html": {JoinPageData{}, JoinPageData{
+		By: "believe@tedlasso.com", Sig: "sig", Referrer: "Ted Lasso", Name: "Roy", Email: "roy@kent", Error: "That doesn't look like an email address.",
+		FormToken: "token", Captcha: &CaptchaWidget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", SiteKey: "key"},
+	}, JoinPageData{Sent: true, Email: "roy@kent.com"}},
+	"html/refer.html": {ReferPageData{}, ReferPageData{Enabled: true}, ReferPageData{
+		Enabled: true, Email: "believe@tedlasso.com", URL: "https://rsvp.pizza/join?by=believe%40tedlasso.com&sig=sig", Referrals: 1, BonusPlusOnes: 1,
+	}, ReferPageData{Enabled: true, Email: "believe@tedlasso.com", BonusPlusOnes: 2}},
+	"html/claim.html": {ClaimPageData{}, ClaimPageData{
+		FridayID: "1680903000", Email: "believe@tedlasso.com", Expires: "1680903000", Sig: "sig", Date: "Fri Apr 7, 5:30 PM",
+		Room: 2, MaxPlusOnes: 1,
+	}, ClaimPageData{Expired: true}, ClaimPageData{Claimed: true, EditURL: "/rsvp/1/edit?sig=x"}, ClaimPageData{Taken: true}},
+	"html/aliases.html":      {AliasesPageData{}, AliasesPageData{Email: "believe@tedlasso.com", Aliases: []string{"coach@richmond.com"}, Sent: "ted@example.com", Error: "That email can't be added."}},
+	"html/verify_alias.html": {VerifyAliasPageData{}, VerifyAliasPageData{Email: "believe@tedlasso.com", Alias: "coach@richmond.com", Expires: "1680903000", Sig: "sig"}, VerifyAliasPageData{Expired: true}, VerifyAliasPageData{Verified: true, Alias: "coach@richmond.com"}},
+	"html/login.html": {LoginPageData{}, LoginPageData{Enabled: true, PasskeyChallenge: "challenge", RPID: "rsvp.pizza"}, LoginPageData{Enabled: true, Phone: "+15555550123", Sent: true, Error: "That code didn't work."},
+		LoginPageData{Providers: []LoginProviderData{{Title: "Google", URL: "/login/google"}}, Error: "roy@kent.com isn't on the friends list."}},
+	"html/labs.html": {LabsPageData{}, LabsPageData{View: View{CSRFToken: "csrf", Flashes: []string{"Saved."}}, Email: "believe@tedlasso.com", Features: []LabsFeatureData{
+		{Name: "countdown", Description: "Show how many days are left until each party on the RSVP page.", On: true}, {Name: "other", Description: "Other", Targeted: true},
+	}}, LabsPageData{Email: "believe@tedlasso.com"}},
+	"html/phone.html": {PhonePageData{}, PhonePageData{Enabled: true, Email: "believe@tedlasso.com"}, PhonePageData{Enabled: true, Email: "believe@tedlasso.com", Phone: "+15555550123", Sent: true, Error: "That code didn't work."}, PhonePageData{Enabled: true, Email: "believe@tedlasso.com", Verified: true}},
+	"html/passkeys.html": {PasskeysPageData{}, PasskeysPageData{
+		View: View{CSRFToken: "csrf", Flashes: []string{"Removed the passkey."}}, Enabled: true, Email: "believe@tedlasso.com", RPID: "rsvp.pizza", UserID: "user", Challenge: "challenge",
+		Passkeys: []Passkey{{ID: "id", Name: "My phone", CreatedAt: time.Date(2023, 4, 7, 0, 0, 0, 0, time.UTC)}, {ID: "id2"}},
+	}},
+	"html/devices.html": {DevicesPageData{}, DevicesPageData{
+		View: View{CSRFToken: "csrf"}, Email: "believe@tedlasso.com", Current: "1",
+		Devices: []Device{{ID: "1", UserAgent: "Firefox", RotatedAt: time.Date(2023, 4, 7, 0, 0, 0, 0, time.UTC)}, {ID: "2"}},
+	}, DevicesPageData{Email: "believe@tedlasso.com"}},
+	"html/admin/login.html": {AdminLoginPageData{}, AdminLoginPageData{Next: "/admin/settings", Error: "That code didn't work."}},
+	"html/admin/security.html": {AdminSecurityPageData{}, AdminSecurityPageData{
+		Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/rsvp.pizza:admin?issuer=rsvp.pizza&secret=JBSWY3DPEHPK3PXP", Error: "That code didn't work.",
+	}, AdminSecurityPageData{Enrolled: true, EnrolledAt: "Fri Apr 7, 5:30 PM", RecoveryLeft: 9, RecoveryCodes: []string{"abcd-efgh"}}},
+	"html/admin/confirm.html": {AdminConfirmPageData{}, AdminConfirmPageData{
+		Action: "delete event", Target: "Fri Apr 7, 5:30 PM with 3 RSVPs", URL: "/admin/fridays/1680903000/delete",
+		Fields: []AdminConfirmField{{"email", "believe@tedlasso.com"}}, Expires: "1680903000", Code: "123456", Error: "That code didn't match.",
+	}},
+	"html/admin/dryrun.html": {AdminDryRunPageData{}, AdminDryRunPageData{
+		Plan: Plan{
+			Action: "delete event", Store: []string{"delete the event on 07 Apr 23 17:30 EDT", "delete the confirmed RSVP of believe@tedlasso.com for 3"},
+			Calendar: []string{"delete calendar event 01g7d2m9r0"}, Notifications: []string{"the calendar emails the cancellation to believe@tedlasso.com"},
+		},
+		URL: "/admin/fridays/01g7d2m9r0/delete", Fields: []AdminConfirmField{{"email", "believe@tedlasso.com"}},
+	}},
+	"html/admin/audit.html": {AdminAuditPageData{}, AdminAuditPageData{
+		Days: 90, Entries: []AdminAuditEntryData{{Action: "purge friend", Target: "roy@kent.com", IP: "127.0.0.1", At: "Fri Apr 7, 5:30 PM"}},
+	}},
+	"html/admin/import.html": {AdminImportPageData{}, AdminImportPageData{
+		Since: "2020-04-07", Started: "Fri Apr 7, 5:30 PM", Finished: "Fri Apr 7, 5:31 PM", Error: "googleapi: Error 403", Busy: true,
+		Result: ImportResult{Events: 40, RSVPs: 312, Skipped: 1, Strangers: 3},
+	}, AdminImportPageData{Since: "2020-04-07", Started: "Fri Apr 7, 5:30 PM", Running: true}},
+	"html/admin/analytics.html": {AdminAnalyticsPageData{}, AdminAnalyticsPageData{
+		Days:       90,
+		Kinds:      []EmailKindStats{{Kind: "digest", Sent: 10, Clicks: 4, Clickers: 3}},
+		Friends:    []AdminAnalyticsFriendData{{Name: "Ted Lasso", Email: "believe@tedlasso.com", Sent: 2, Clicks: 1, LastClick: "Fri Apr 7, 5:30 PM"}, {Email: "roy@kent.com", Sent: 1}},
+		Experiment: []DigestCohortStats{{Hour: 9, Friends: 10, Attended: 4, RSVPs: 6, Rate: 40}, {Hour: 17, Friends: 9, Attended: 6, RSVPs: 7, Rate: 66.7, Delta: 26.7}},
+	}},
+	"html/admin/referrals.html": {AdminReferralsPageData{}, AdminReferralsPageData{
+		BonusPlusOnes: 1,
+		Referrers:     []AdminReferrerData{{Name: "Ted Lasso", Email: "believe@tedlasso.com", Newcomers: []AdminNewcomerData{{Name: "Roy Kent", Email: "roy@kent.com", Joined: "Fri Apr 7, 5:30 PM"}}}},
+	}},
+	"html/admin/settings.html": {AdminSettingsPageData{}, AdminSettingsPageData{
+		AdminView: AdminView{CSRFToken: "csrf"},
+		Settings:  []AdminSettingData{{Name: "capacity", Help: "Guests per party", Value: "10", Override: "10", Default: "0"}},
+		Saved:     true, Error: "One of the settings isn't valid.",
+	}},
+	"html/admin/images.html": {AdminImagesPageData{}, AdminImagesPageData{
+		FridayID: "1680903000", Date: "Fri Apr 7, 5:30 PM", Cover: fixtureFriday.Cover,
+		Images: []Image{{Key: "1680903000/a", Widths: []int{320}}}, Saved: true, Error: "Only JPEG, PNG, and GIF images can be uploaded.",
+	}},
+	"html/admin/quarantine.html": {AdminQuarantinePageData{}, AdminQuarantinePageData{
+		Held: []AdminQuarantinedData{{ID: "1", Form: "rsvp", Reason: "honeypot", Created: "Fri Apr 7, 5:30 PM", Values: "email=spam@example.com"}},
+	}},
+	"html/admin/content.html": {AdminContentPageData{}, AdminContentPageData{
+		Sections: []AdminContentData{{Name: "welcome", Title: "Welcome", Markdown: "Hi **friends**", Preview: "<p>Hi <strong>friends</strong></p>"}},
+		Saved:    true,
+	}},
+	"html/admin/templates.html": {AdminTemplatesPageData{}, AdminTemplatesPageData{
+		Templates: []AdminTemplateData{{Name: "last call", Body: "{{spots_left}} spots left", Preview: "2 spots left"}},
+		Variables: AnnouncementVariables,
+		Fridays:   []AdminTemplateFriday{{ID: "1680903000", Date: "Fri Apr 7, 5:30 PM"}},
+		Matrix:    true, Message: "Saved last call.", Error: "Pick a template and a Friday.",
+	}},
+	"html/admin/guests.html": {AdminGuestsPageData{}, AdminGuestsPageData{
+		FridayID: "1680903000", Date: "Fri Apr 7, 5:30 PM", Headcount: 3, Count: GuestCount{Adults: 2, Kids: 1},
+		Days:    []DayHeadcount{{EventDay{Date: "2023-04-07", Label: "Fri Apr 7"}, 3}, {EventDay{Date: "2023-04-08", Label: "Sat Apr 8"}, 2}},
+		Guests:  []AdminGuestData{{Name: "Ted Lasso", Email: "believe@tedlasso.com", PlusOnes: 1, Kids: 1, Status: "confirmed", Note: "allergic to shellfish", Groups: "work", Days: []string{"Fri Apr 7"}, CheckedIn: true, Birthday: true}, {ID: "2", Name: "Roy Kent", Status: "pending", Arrival: "7:15PM", Late: true}},
+		Cohosts: []string{"keeley@jones.com"}, Message: "Approved Roy Kent, who was emailed they're in.", AdminView: AdminView{CSRFToken: "csrf"},
+	}},
+	"html/cohost.html": {CohostPageData{}, CohostPageData{
+		FridayID: "1680903000", Email: "keeley@jones.com", Expires: "1680903000", Sig: "sig", Date: "Fri Apr 7, 5:30 PM", Headcount: 4, Arrived: 3,
+		Guests:       []CohostGuestData{{ID: "1", Name: "Ted Lasso", Email: "believe@tedlasso.com", PlusOnes: 1, Kids: 1, CheckedIn: true}, {ID: "2", Email: "roy@kent.com", Arrival: "7:15PM", Late: true, Note: "allergic to shellfish"}},
+		Announcement: "Bring a **friend**",
+	}},
+	"html/admin/seating.html": {AdminSeatingPageData{}, AdminSeatingPageData{
+		FridayID: "1680903000", Date: "Fri Apr 7, 5:30 PM", TableSize: 8, Options: []int{0, 1, 2},
+		Tables:   []AdminTableData{{Number: 1, Seats: 3, Guests: []AdminSeatData{{Name: "Ted Lasso", Email: "believe@tedlasso.com", Seats: 3, Groups: []string{"work", "climbing"}, Table: 1}}}},
+		Unseated: []AdminSeatData{{Name: "Roy Kent", Email: "roy@kent.com", Seats: 1}},
+	}},
+	"html/admin/print.html": {AdminPrintPageData{}, AdminPrintPageData{
+		Date: "Fri Apr 7, 5:30 PM", Headcount: 3, Count: GuestCount{Adults: 2, Kids: 1},
+		Days:     []DayHeadcount{{EventDay{Date: "2023-04-07", Label: "Fri Apr 7"}, 3}},
+		Toppings: []ToppingCount{{"pepperoni", 2}},
+		Guests: []PrintGuestData{{
+			Name: "Ted Lasso", Email: "believe@tedlasso.com", PlusOnes: 1, Kids: 1, Table: 1, Days: []string{"Fri Apr 7"},
+			Note: "allergic to shellfish", Answers: []EditAnswerData{{"Dietary restrictions?", "vegetarian"}},
+		}, {Email: "roy@kent.com"}},
+		Drinks: []DrinkTally{{Category: "beer", Count: 6, Bringers: []string{"Ted Lasso"}}},
+	}},
+	"html/admin/placecards.html": {AdminPlaceCardsPageData{}, AdminPlaceCardsPageData{
+		Date: "Fri Apr 7, 5:30 PM", Cards: []PlaceCard{{Name: "Ted Lasso", Table: 1}, {GuestOf: "Ted Lasso", Table: 1}},
+	}},
+	"html/admin/duplicates.html": {AdminDuplicatesPageData{}, AdminDuplicatesPageData{
+		Groups: [][]Friend{{{Name: "Ted Lasso", Email: "ted.lasso@gmail.com"}, {Name: "Ted Lasso", Email: "tedlasso@gmail.com"}}},
+		Merged: 1,
+	}},
+	"html/admin/alerts.html": {AdminAlertsPageData{}, AdminAlertsPageData{
+		Alerts: []AdminAlertData{{ID: "above-15", Description: "more than 15 people"}}, Enabled: true, Message: "Removed the alert.", Error: "Alerts need a number of people.",
+	}},
+	"html/admin/transfer.html": {AdminTransferPageData{}, AdminTransferPageData{
+		Owner: "believe@tedlasso.com", Since: "Fri Apr 7, 5:30 PM", To: "roy@kent.com", Expires: "Mon Apr 10, 5:30 PM", Message: "Sent roy@kent.com a link to accept the series.", Error: "That doesn't look like an email address.",
+	}},
+	"html/admin/reminders.html": {AdminRemindersPageData{}, AdminRemindersPageData{
+		Schedule: []AdminReminderData{{ID: "before-2h0m0s", Description: "2 hours before by email and sms"}},
+		Fridays: []AdminReminderFridayData{
+			{ID: "1680903000", Date: "07 Apr 23 17:30 EDT", Reminders: []AdminReminderData{{ID: "before-24h0m0s", Description: "1 day before by matrix"}}},
+			{ID: "1681507800", Date: "14 Apr 23 17:30 EDT"},
+		},
+		Channels: ReminderChannels, Message: "Removed the reminder.", Error: "Reminders need a channel.",
+	}},
+	"html/admin/friends.html": {AdminFriendsPageData{}, AdminFriendsPageData{
+		Friends: []Friend{{Name: "Ted Lasso", Email: "believe@tedlasso.com"}}, Query: "ted", Added: "Roy Kent", Removed: "jamie@tartt.com", Error: "That doesn't look like an email address.",
+	}},
+	"html/admin/search.html": {AdminSearchPageData{}, AdminSearchPageData{Query: "ted", Searched: true}, AdminSearchPageData{
+		Query: "ted", Searched: true,
+		Friends:  []Friend{{Name: "Ted Lasso", Email: "believe@tedlasso.com"}},
+		Fridays:  []AdminFridayData{{ID: "1680903000", Date: "Fri, 07 Apr 2023 17:30:00 EDT"}},
+		Comments: []AdminSearchComment{{APISearchComment{"abc123", "1680903000", "believe@tedlasso.com", "Anything we should know?", "Ted is bringing biscuits"}, "Fri Apr 7 at 5:30 PM"}},
+	}},
+	"html/admin/fridays.html": {AdminFridaysPageData{}, AdminFridaysPageData{
+		Fridays: []AdminFridayData{{ID: "1680903000", Date: "Fri, 07 Apr 2023 17:30:00 EDT"}},
+		Created: "Fri, 07 Apr 2023 17:30:00 EDT", Error: "an event already starts at that time",
+		Next: "2023-04-14T18:00", DrinkCategories: "beer, wine, soda",
+	}},
+	"email/digest": {DigestData{}, DigestData{
+		Name: "Ted", RSVPURL: "https://rsvp.pizza/?invite=x", UnsubscribeURL: "https://rsvp.pizza/digest?sig=x",
+		Fridays: []DigestFridayData{
+			{Date: "Fri Apr 7, 5:30 PM", Deadline: "Fri Apr 7, 3:30 PM", Headcount: 3, Kids: 1, Pizzas: 1, Guests: []string{"Ted Lasso", "Roy Kent"}, Birthdays: []string{"Ted Lasso"}, Announcement: "Bring a friend",
+				Notes: []DigestNote{{Name: "Ted Lasso", Note: "allergic to shellfish"}}},
+			{Date: "Fri Apr 14, 5:30 PM", Closed: true},
+		},
+		Toppings: []ToppingCount{{Topping: "pepperoni", Votes: 3}},
+	}},
+	"email/opened": {OpenedEmailData{}, OpenedEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Deadline: "Fri Apr 7, 3:30 PM", RSVPURL: "https://rsvp.pizza/?invite=x", Reply: true}},
+	"email/spot":   {SpotEmailData{}, SpotEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Expires: "Fri Apr 7, 1:30 PM", ClaimURL: "https://rsvp.pizza/claim?sig=x"}},
+	"email/review": {ReviewEmailData{}, ReviewEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Approved: true, EditURL: "https://rsvp.pizza/rsvp/1/edit?sig=x"},
+		ReviewEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Approved: true, Waitlist: true, EditURL: "https://rsvp.pizza/rsvp/1/edit?sig=x"}},
+	"email/alias":  {AliasEmailData{}, AliasEmailData{Name: "Ted", Alias: "coach@richmond.com", VerifyURL: "https://rsvp.pizza/aliases/verify?sig=x"}},
+	"email/invite": {InviteEmailData{}, InviteEmailData{Name: "Ted", RSVPURL: "https://rsvp.pizza/?invite=x", ReplyDate: "Fri Apr 7, 5:30 PM"}},
+	"email/reminder": {ReminderEmailData{}, ReminderEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Lead: "2 hours", Announcement: "Bring a friend", EditURL: "https://rsvp.pizza/rsvp/1/edit?sig=x"},
+		ReminderEmailData{Name: "Ted", Date: "Fri Apr 7, 5:30 PM", Lead: "2 hours", EditURL: "https://rsvp.pizza/rsvp/1/edit?sig=x", ArrivalURL: "https://rsvp.pizza/arrival?rsvp=1&sig=x"}},
+	"email/alert":       {AlertEmailData{}, AlertEmailData{Date: "Fri Apr 7, 5:30 PM", Headcount: 16, Alert: "more than 15 people", GuestsURL: "https://rsvp.pizza/admin/fridays/1680903000/guests"}},
+	"email/confirm":     {ConfirmEmailData{}, ConfirmEmailData{Name: "Ted", Dates: []string{"Fri Apr 7, 5:30 PM"}, ConfirmURL: "https://rsvp.pizza/confirm?sig=x", Expires: "Sat Apr 8, 5:30 PM"}},
+	"email/transfer":    {TransferEmailData{}, TransferEmailData{Owner: "believe@tedlasso.com", AcceptURL: "https://rsvp.pizza/transfer?sig=x", Expires: "Mon Apr 10, 5:30 PM"}},
+	"email/transferred": {TransferredEmailData{}, TransferredEmailData{Owner: "roy@kent.com", AuditURL: "https://rsvp.pizza/admin/audit"}},
+	"email/cohost":      {CohostEmailData{}, CohostEmailData{Date: "Fri Apr 7, 5:30 PM", CohostURL: "https://rsvp.pizza/cohost/1680903000?sig=x", Expires: "Sat Apr 8, 9:30 AM"}},
+}
+
+// CheckTemplateRenders renders every template in the static directory, or the
+// built in one when dir is empty, with its fixtures, reporting templates that
+// fail, have no fixtures, or use assets that don't exist.
+func CheckTemplateRenders(dir string) []string {
+	var problems []string
+	fsys := staticFS(dir)
+	funcs := template.FuncMap{}
+	for name, fn := range templateFuncs {
+		funcs[name] = fn
+	}
+	var current string
+	checkAsset := func(name string) {
+		if _, err := fs.Stat(fsys, name); err != nil {
+			problems = append(problems, fmt.Sprintf("%s uses missing asset %s", current, name))
+		}
+	}
+	funcs["asset"] = func(name string) string { checkAsset(name); return AssetURL(name) }
+	for _, name := range []string{"stylesheet", "script"} {
+		fn := templateFuncs[name].(func(string) template.HTML)
+		funcs[name] = func(asset string) template.HTML { checkAsset(asset); return fn(asset) }
+	}
+
+	found := map[string]bool{}
+	fs.WalkDir(fsys, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(rel, "html/") && strings.HasSuffix(rel, ".html"):
+			found[rel] = true
+		case strings.HasPrefix(rel, "email/") && strings.HasSuffix(rel, ".txt"):
+			found[strings.TrimSuffix(rel, ".txt")] = true
+		}
+		return nil
+	})
+
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	for name := range TemplateFixtures {
+		if !found[name] {
+			problems = append(problems, fmt.Sprintf("%s has fixtures but no template", name))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		current = name
+		fixtures, ok := TemplateFixtures[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no fixtures in TemplateFixtures", name))
+			continue
+		}
+		var plate interface {
+			Execute(io.Writer, any) error
+			ExecuteTemplate(io.Writer, string, any) error
+		}
+		var err error
+		if strings.HasPrefix(name, "email/") {
+			plate, err = texttemplate.ParseFS(fsys, name+".txt")
+		} else {
+			plate, err = template.New(path.Base(name)).Funcs(funcs).ParseFS(fsys, name)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s doesn't parse: %v", name, err))
+			continue
+		}
+		for i, data := range fixtures {
+			if err = plate.Execute(io.Discard, data); err != nil {
+				problems = append(problems, fmt.Sprintf("%s fails with fixture %d: %v", name, i, err))
+			}
+			if strings.HasPrefix(name, "email/") {
+				if err = plate.ExecuteTemplate(io.Discard, "subject", data); err != nil {
+					problems = append(problems, fmt.Sprintf("%s subject fails with fixture %d: %v", name, i, err))
+				}
+			}
+		}
+	}
+	return problems
+}
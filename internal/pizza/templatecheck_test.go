@@ -0,0 +1,40 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTemplateRenders(t *testing.T) {
+	assert.Empty(t, pizza.CheckTemplateRenders("../../static"))
+}
+
+func TestCheckTemplateRendersBroken(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "html", "admin"), 0o755))
+	write := func(name, body string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644))
+	}
+	write("html/index.html", `{{stylesheet "css/gone.css"}}{{range .FridayTimes}}{{.Nope}}{{end}}`)
+	write("html/new.html", `hi`)
+
+	// WHEN
+	problems := pizza.CheckTemplateRenders(dir)
+
+	// THEN
+	assert.Contains(t, problems, "html/index.html uses missing asset css/gone.css")
+	assert.Contains(t, problems, "html/new.html has no fixtures in TemplateFixtures")
+	assert.Contains(t, problems, "html/edit.html has fixtures but no template")
+	found := false
+	for _, p := range problems {
+		found = found || strings.HasPrefix(p, "html/index.html fails with fixture 1:")
+	}
+	assert.True(t, found, "%v", problems)
+}
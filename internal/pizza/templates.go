@@ -0,0 +1,96 @@
+package pizza
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"go.uber.org/zap"
+)
+
+// ReloadTemplates reads the templates from StaticDir every time they are
+// used, so edits show without a restart. The built in templates can't change,
+// so it only makes a difference with StaticDir set. It is for working on the pages; in
+// production they are parsed once by LoadTemplates.
+var ReloadTemplates = false
+
+var loadedTemplates struct {
+	sync.RWMutex
+	// pages are keyed by path under the static directory and emails by name
+	pages  map[string]*template.Template
+	emails map[string]*texttemplate.Template
+}
+
+// LoadTemplates parses every page and email template in the static directory,
+// or the built in one when dir is empty, to serve from memory, so a template
+// that doesn't parse stops the server from starting instead of failing its
+// page. Pages are html/template, escaping whatever they print for where it is
+// printed, and emails are plain text.
+func LoadTemplates(dir string) error {
+	fsys := staticFS(dir)
+	pages := map[string]*template.Template{}
+	err := fs.WalkDir(fsys, "html", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return err
+		}
+		plate, err := template.New(path.Base(p)).Funcs(templateFuncs).ParseFS(fsys, p)
+		if err != nil {
+			return fmt.Errorf("template %s doesn't parse: %w", p, err)
+		}
+		pages[p] = plate
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	emails := map[string]*texttemplate.Template{}
+	files, err := fs.Glob(fsys, "email/*.txt")
+	if err != nil {
+		return err
+	}
+	for _, p := range files {
+		plate, err := texttemplate.ParseFS(fsys, p)
+		if err != nil {
+			return fmt.Errorf("email %s doesn't parse: %w", path.Base(p), err)
+		}
+		emails[strings.TrimSuffix(path.Base(p), ".txt")] = plate
+	}
+
+	loadedTemplates.Lock()
+	loadedTemplates.pages, loadedTemplates.emails = pages, emails
+	loadedTemplates.Unlock()
+	Log.Info("loaded templates", zap.Int("pages", len(pages)), zap.Int("emails", len(emails)))
+	return nil
+}
+
+// parseTemplate is the page template under StaticDir with the asset helpers,
+// read from disk when the templates aren't loaded or are reloaded.
+func parseTemplate(name string) (*template.Template, error) {
+	if !ReloadTemplates {
+		loadedTemplates.RLock()
+		plate, ok := loadedTemplates.pages[name]
+		loadedTemplates.RUnlock()
+		if ok {
+			return plate, nil
+		}
+	}
+	return template.New(path.Base(name)).Funcs(templateFuncs).ParseFS(staticFS(StaticDir), name)
+}
+
+// emailTemplate is the email template static/email/<name>.txt, read like
+// parseTemplate reads pages.
+func emailTemplate(name string) (*texttemplate.Template, error) {
+	if !ReloadTemplates {
+		loadedTemplates.RLock()
+		plate, ok := loadedTemplates.emails[name]
+		loadedTemplates.RUnlock()
+		if ok {
+			return plate, nil
+		}
+	}
+	return texttemplate.ParseFS(staticFS(StaticDir), "email/"+name+".txt")
+}
@@ -0,0 +1,46 @@
+package pizza_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+
+	// WHEN
+	err := pizza.LoadTemplates(pizza.StaticDir)
+
+	// THEN pages are parsed once
+	assert.Nil(t, err)
+	first, err := pizza.ParseTemplate("html/4xx.html")
+	assert.Nil(t, err)
+	second, _ := pizza.ParseTemplate("html/4xx.html")
+	assert.Same(t, first, second)
+
+	// and read from disk again when reloading
+	pizza.ReloadTemplates = true
+	defer func() { pizza.ReloadTemplates = false }()
+	reloaded, err := pizza.ParseTemplate("html/4xx.html")
+	assert.Nil(t, err)
+	assert.NotSame(t, first, reloaded)
+}
+
+func TestLoadTemplatesBroken(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "html"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "html", "bad.html"), []byte("{{if}}"), 0o644))
+
+	// WHEN
+	err := pizza.LoadTemplates(dir)
+
+	// THEN
+	assert.ErrorContains(t, err, "html/bad.html")
+}
@@ -0,0 +1,441 @@
+package pizza
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// TOTPStep is how long each code from the authenticator app lasts.
+	TOTPStep = 30 * time.Second
+	// RecoveryCodeCount is how many recovery codes are made when the admin
+	// enrolls.
+	RecoveryCodeCount = 10
+
+	adminCookieName = "pizza_admin"
+)
+
+// AdminSessionTTL is how long the admin stays logged in after entering a code.
+var AdminSessionTTL = 12 * time.Hour
+
+var (
+	// AdminCodeMaxAttempts is how many wrong admin codes, on the admin pages
+	// or in X-TOTP headers, lock out every code, recovery codes too.
+	AdminCodeMaxAttempts = 5
+	// AdminCodeLockout is how long the codes are locked out for, counted from
+	// the first wrong one.
+	AdminCodeLockout = time.Hour
+)
+
+var ErrAdminCodeLocked = errors.New("too many wrong codes")
+
+// wrong codes live in memory like the texted ones, see otpFailures
+var (
+	adminCodeMu       sync.Mutex
+	adminCodeFailures *otpFailure
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var adminAuthCache *Cache[AdminAuth]
+
+func init() {
+	c := NewCache(time.Minute, func(string) (AdminAuth, error) { return GetAdminAuth() })
+	adminAuthCache = &c
+}
+
+// NewTOTPSecret makes a secret for an authenticator app, in the base32 the
+// apps expect.
+func NewTOTPSecret() string {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("could not generate totp secret: %v", err))
+	}
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI is the otpauth link an authenticator app adds the secret from.
+func TOTPURI(secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", "rsvp.pizza")
+	return "otpauth://totp/rsvp.pizza:admin?" + q.Encode()
+}
+
+// TOTPCode is the six digit code for the secret at the time, as in RFC 6238.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return totpCodeAt(key, t.Unix()/int64(TOTPStep/time.Second)), nil
+}
+
+func totpCodeAt(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	n := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", n%1000000)
+}
+
+// the last step a code was accepted for, so a code can't be used twice
+var (
+	totpMu       sync.Mutex
+	totpLastStep int64
+)
+
+// CheckTOTP reports whether the code is the secret's code at the time, or
+// one step either side of it for clocks that are a little off. Each code
+// works once.
+func CheckTOTP(secret, code string, now time.Time) bool {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(key) == 0 || len(code) != 6 {
+		return false
+	}
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	step := now.Unix() / int64(TOTPStep/time.Second)
+	for _, s := range []int64{step, step - 1, step + 1} {
+		if s <= totpLastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCodeAt(key, s)), []byte(code)) == 1 {
+			totpLastStep = s
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecoveryCodes makes codes the admin can log in with once each if they
+// lose their authenticator app, and the hashes of them to store.
+func NewRecoveryCodes() (codes []string, hashes []string) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("could not generate recovery code: %v", err))
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes
+}
+
+// HashRecoveryCode is how a recovery code is stored, ignoring case, spaces,
+// and dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkAdminCode reports whether the code is a TOTP code or an unused
+// recovery code. After AdminCodeMaxAttempts wrong codes it returns
+// ErrAdminCodeLocked, even for the right code, until AdminCodeLockout has
+// passed. Missing codes aren't counted as wrong.
+func checkAdminCode(auth AdminAuth, code string) (bool, error) {
+	adminCodeMu.Lock()
+	defer adminCodeMu.Unlock()
+	now := time.Now()
+	if adminCodeFailures != nil && now.Sub(adminCodeFailures.since) > AdminCodeLockout {
+		adminCodeFailures = nil
+	}
+	if adminCodeFailures != nil && adminCodeFailures.count >= AdminCodeMaxAttempts {
+		return false, ErrAdminCodeLocked
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == 0 {
+		return false, nil
+	}
+	if ok, err := matchAdminCode(auth, code, now); err != nil {
+		return false, err
+	} else if ok {
+		adminCodeFailures = nil
+		return true, nil
+	}
+	if adminCodeFailures == nil {
+		adminCodeFailures = &otpFailure{since: now}
+	}
+	adminCodeFailures.count++
+	return false, nil
+}
+
+// matchAdminCode reports whether the code is a TOTP code or an unused
+// recovery code, which is used up.
+func matchAdminCode(auth AdminAuth, code string, now time.Time) (bool, error) {
+	if CheckTOTP(auth.TOTPSecret, code, now) {
+		return true, nil
+	}
+	hash := HashRecoveryCode(code)
+	for i, h := range auth.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) != 1 {
+			continue
+		}
+		auth.RecoveryCodes = append(auth.RecoveryCodes[:i:i], auth.RecoveryCodes[i+1:]...)
+		if err := SaveAdminAuth(auth); err != nil {
+			return false, err
+		}
+		adminAuthCache.Delete("")
+		Log.Info("admin used a recovery code", zap.Int("left", len(auth.RecoveryCodes)))
+		return true, nil
+	}
+	return false, nil
+}
+
+// the session is bound to the secret, so enrolling again logs out everyone
+func adminSessionValue(auth AdminAuth, expires time.Time) string {
+	exp := LinkExpiry(expires)
+	return exp + "." + SignLink("admin", exp, auth.TOTPSecret)
+}
+
+func setAdminCookie(w http.ResponseWriter, auth AdminAuth) {
+	expires := time.Now().Add(AdminSessionTTL)
+	cookie := newCookie(adminCookieName, adminSessionValue(auth, expires), "/", expires)
+	cookie.SameSite = http.SameSiteStrictMode
+	http.SetCookie(w, cookie)
+}
+
+func hasAdminSession(r *http.Request, auth AdminAuth) bool {
+	cookie, err := r.Cookie(adminCookieName)
+	if err != nil {
+		return false
+	}
+	exp, sig, ok := strings.Cut(cookie.Value, ".")
+	return ok && !LinkExpired(exp, time.Now()) && VerifyLink(sig, "admin", exp, auth.TOTPSecret)
+}
+
+// requireAdminCode makes an admin who enrolled a second factor enter a code
+// before reaching the page. Pages are redirected to log in, other requests
+// are refused.
+func requireAdminCode(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth, err := adminAuthCache.Get("")
+		if err != nil {
+			Handle500(w, r)
+			return
+		}
+		if len(auth.TOTPSecret) == 0 || hasAdminSession(r, auth) {
+			next(w, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "two-factor code required", http.StatusUnauthorized)
+	}
+}
+
+// requireAPICode makes full admin API keys send the current code in the
+// X-TOTP header for destructive requests, once the admin enrolled a second
+// factor. Clients given their own scoped keys are left alone.
+func requireAPICode(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if client, _, ok := authenticateAPI(r); ok && strings.HasPrefix(client, "key-") {
+			auth, err := adminAuthCache.Get("")
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			if len(auth.TOTPSecret) > 0 {
+				if ok, err := checkAdminCode(auth, r.Header.Get("X-TOTP")); err == ErrAdminCodeLocked {
+					writeAPIError(w, http.StatusTooManyRequests, err.Error())
+					return
+				} else if err != nil {
+					writeAPIError(w, http.StatusInternalServerError, "internal error")
+					return
+				} else if !ok {
+					writeAPIError(w, http.StatusUnauthorized, "two-factor code required")
+					return
+				}
+			}
+		}
+		next(w, r)
+	}
+}
+
+type AdminLoginPageData struct {
+	AdminView
+	Next  string
+	Error string
+}
+
+// HandleAdminLogin asks the admin for a code from their authenticator app or
+// a recovery code.
+func HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/login.html")
+	if err != nil {
+		Log.Error("template admin login failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		Handle4xx(w, r)
+		return
+	}
+	data := AdminLoginPageData{AdminView: newAdminView(w, r), Next: r.Form.Get("next")}
+	// only go back to admin pages on this site
+	if !strings.HasPrefix(data.Next, "/admin/") || strings.HasPrefix(data.Next, "/admin/login") {
+		data.Next = "/admin/security"
+	}
+	auth, err := adminAuthCache.Get("")
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	if len(auth.TOTPSecret) == 0 {
+		http.Redirect(w, r, data.Next, http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if ok, err := checkAdminCode(auth, r.PostForm.Get("code")); err == ErrAdminCodeLocked {
+			Log.Warn("admin codes locked out")
+			data.Error = "Too many wrong codes, try again later."
+		} else if err != nil {
+			Handle500(w, r)
+			return
+		} else if ok {
+			rotateSession(w, r)
+			setAdminCookie(w, auth)
+			http.Redirect(w, r, data.Next, http.StatusSeeOther)
+			return
+		} else {
+			Log.Warn("wrong admin code")
+			data.Error = "That code didn't work."
+		}
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
+
+type AdminSecurityPageData struct {
+	AdminView
+	Enrolled   bool
+	EnrolledAt string
+	// RecoveryLeft is how many recovery codes are unused
+	RecoveryLeft int
+	// Secret and URI are a new secret to enroll
+	Secret string
+	URI    string
+	// RecoveryCodes are shown once, right after they are made
+	RecoveryCodes []string
+	Error         string
+}
+
+// HandleAdminSecurity enrolls the admin in two-factor login, makes new
+// recovery codes, and turns it off again. Each needs a current code.
+func HandleAdminSecurity(w http.ResponseWriter, r *http.Request) {
+	plate, err := parseTemplate("html/admin/security.html")
+	if err != nil {
+		Log.Error("template admin security failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	auth, err := adminAuthCache.Get("")
+	if err != nil {
+		Handle500(w, r)
+		return
+	}
+	data := AdminSecurityPageData{AdminView: newAdminView(w, r)}
+
+	if r.Method == http.MethodPost {
+		if err = r.ParseForm(); err != nil {
+			Handle4xx(w, r)
+			return
+		}
+		code := r.PostForm.Get("code")
+		switch action := r.PostForm.Get("action"); action {
+		case "enroll":
+			secret := r.PostForm.Get("secret")
+			if len(auth.TOTPSecret) > 0 {
+				Handle4xx(w, r)
+				return
+			} else if !CheckTOTP(secret, strings.TrimSpace(code), time.Now()) {
+				data.Error = "That code didn't work, check the clock on your phone and try again."
+				data.Secret = secret
+				break
+			}
+			var hashes []string
+			data.RecoveryCodes, hashes = NewRecoveryCodes()
+			auth = AdminAuth{TOTPSecret: secret, RecoveryCodes: hashes, EnrolledAt: time.Now()}
+		case "recovery", "disable":
+			if len(auth.TOTPSecret) == 0 {
+				Handle4xx(w, r)
+				return
+			}
+			if ok, err := checkAdminCode(auth, code); err == ErrAdminCodeLocked {
+				data.Error = "Too many wrong codes, try again later."
+				break
+			} else if err != nil {
+				Handle500(w, r)
+				return
+			} else if !ok {
+				data.Error = "That code didn't work."
+				break
+			}
+			if action == "recovery" {
+				data.RecoveryCodes, auth.RecoveryCodes = NewRecoveryCodes()
+			} else {
+				auth = AdminAuth{}
+			}
+		default:
+			Handle4xx(w, r)
+			return
+		}
+		if len(data.Error) == 0 {
+			if err = SaveAdminAuth(auth); err != nil {
+				Handle500(w, r)
+				return
+			}
+			adminAuthCache.Delete("")
+			if len(auth.TOTPSecret) > 0 {
+				rotateSession(w, r)
+				setAdminCookie(w, auth)
+			}
+			Log.Info("admin two-factor changed", zap.Bool("enrolled", len(auth.TOTPSecret) > 0))
+		}
+	}
+
+	data.Enrolled = len(auth.TOTPSecret) > 0
+	if data.Enrolled {
+		data.EnrolledAt = FormatTime(auth.EnrolledAt)
+		data.RecoveryLeft = len(auth.RecoveryCodes)
+	} else {
+		if len(data.Secret) == 0 {
+			data.Secret = NewTOTPSecret()
+		}
+		data.URI = TOTPURI(data.Secret)
+	}
+
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,92 @@
+package pizza_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// GIVEN the secret from the RFC 6238 test vectors, "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	// WHEN
+	first, err := pizza.TOTPCode(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	second, err := pizza.TOTPCode(strings.ToLower(secret), time.Unix(1111111109, 0))
+	require.NoError(t, err)
+
+	// THEN the codes are the last six digits of the vectors
+	assert.Equal(t, "287082", first)
+	assert.Equal(t, "081804", second)
+}
+
+func TestCheckTOTP(t *testing.T) {
+	// GIVEN
+	secret := pizza.NewTOTPSecret()
+	now := time.Now()
+	code, err := pizza.TOTPCode(secret, now)
+	require.NoError(t, err)
+	late, err := pizza.TOTPCode(secret, now.Add(-pizza.TOTPStep))
+	require.NoError(t, err)
+
+	// WHEN / THEN a code works once, and codes from before it no longer do
+	assert.False(t, pizza.CheckTOTP(secret, "12345", now))
+	assert.False(t, pizza.CheckTOTP("", code, now))
+	assert.True(t, pizza.CheckTOTP(secret, code, now))
+	assert.False(t, pizza.CheckTOTP(secret, code, now))
+	assert.False(t, pizza.CheckTOTP(secret, late, now))
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	// WHEN
+	codes, hashes := pizza.NewRecoveryCodes()
+
+	// THEN codes are typed with or without the dash in any case
+	require.Len(t, codes, pizza.RecoveryCodeCount)
+	require.Len(t, hashes, pizza.RecoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}$`, codes[0])
+	assert.Equal(t, hashes[0], pizza.HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))))
+	assert.NotEqual(t, codes[0], codes[1])
+}
+
+func TestCheckAdminCodeLockout(t *testing.T) {
+	// GIVEN
+	pizza.ResetAdminCodes()
+	defer pizza.ResetAdminCodes()
+	auth := pizza.AdminAuth{TOTPSecret: pizza.NewTOTPSecret()}
+	code, err := pizza.TOTPCode(auth.TOTPSecret, time.Now())
+	require.NoError(t, err)
+
+	// WHEN requests without a code come in, they don't count
+	for i := 0; i < pizza.AdminCodeMaxAttempts; i++ {
+		ok, err := pizza.CheckAdminCode(auth, " ")
+		assert.False(t, ok)
+		assert.NoError(t, err)
+	}
+	// WHEN the codes are guessed
+	for i := 0; i < pizza.AdminCodeMaxAttempts; i++ {
+		ok, err := pizza.CheckAdminCode(auth, "12345")
+		assert.False(t, ok)
+		assert.NoError(t, err)
+	}
+
+	// THEN even the right code is refused
+	ok, err := pizza.CheckAdminCode(auth, code)
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, pizza.ErrAdminCodeLocked)
+
+	// WHEN the lockout passes
+	lockout := pizza.AdminCodeLockout
+	pizza.AdminCodeLockout = 0
+	defer func() { pizza.AdminCodeLockout = lockout }()
+
+	// THEN codes are checked again
+	ok, err = pizza.CheckAdminCode(auth, "12345")
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
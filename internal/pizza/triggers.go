@@ -0,0 +1,201 @@
+package pizza
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	TriggerNewEvent  = "new_event"
+	TriggerNewRSVP   = "new_rsvp"
+	TriggerEventFull = "event_full"
+)
+
+// TriggerLimit is how many items a polling trigger returns by default.
+var TriggerLimit = 50
+
+// TriggerItem is one item of a polling trigger, shaped for Zapier and IFTTT.
+// ID never changes for the same item so the services can tell what's new.
+type TriggerItem struct {
+	ID        string    `json:"id"`
+	EventID   string    `json:"event_id"`
+	Date      string    `json:"date"`
+	Start     time.Time `json:"start"`
+	URL       string    `json:"url"`
+	Headcount int       `json:"headcount,omitempty"`
+	Capacity  int       `json:"capacity,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	PlusOnes  int       `json:"plus_ones,omitempty"`
+	Kids      int       `json:"kids,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func eventTriggerItem(id string, friday Friday, at time.Time) TriggerItem {
+	return TriggerItem{
+		ID:        id,
+		EventID:   friday.ID(),
+		Date:      FormatTime(friday.Start),
+		Start:     friday.Start,
+		URL:       BaseURL + "/",
+		Capacity:  friday.Limit(),
+		CreatedAt: at,
+	}
+}
+
+// EventTriggerItems are the new_event items for the events, newest first.
+func EventTriggerItems(fridays []Friday) []TriggerItem {
+	items := make([]TriggerItem, 0, len(fridays))
+	for _, friday := range fridays {
+		items = append(items, eventTriggerItem(friday.ID(), friday, friday.Modified()))
+	}
+	sortTriggerItems(items)
+	return items
+}
+
+// RSVPTriggerItems are the new_rsvp items for the RSVPs created in the
+// changes, newest first. Names are keyed by email.
+func RSVPTriggerItems(changes []Change, fridays []Friday, names map[string]string) []TriggerItem {
+	byID := map[string]Friday{}
+	for _, friday := range fridays {
+		byID[friday.ID()] = friday
+	}
+	items := []TriggerItem{}
+	for _, change := range changes {
+		if change.Type != ChangeRSVPCreated || change.RSVP == nil {
+			continue
+		}
+		friday, ok := byID[change.FridayID]
+		if !ok {
+			continue
+		}
+		item := eventTriggerItem(change.ID, friday, change.At)
+		item.Email = change.RSVP.Email
+		item.Name = names[change.RSVP.Email]
+		item.PlusOnes = change.RSVP.PlusOnes
+		item.Kids = change.RSVP.Kids
+		items = append(items, item)
+	}
+	sortTriggerItems(items)
+	return items
+}
+
+func sortTriggerItems(items []TriggerItem) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+}
+
+// triggerItems gathers up to limit items of the trigger.
+func triggerItems(trigger string, limit int) ([]TriggerItem, error) {
+	fridays, err := GetCachedFridays(UpcomingDays)
+	if err != nil {
+		return nil, err
+	}
+	var items []TriggerItem
+	switch trigger {
+	case TriggerNewEvent:
+		items = EventTriggerItems(fridays)
+	case TriggerNewRSVP:
+		// changes of other kinds share the feed, so read past them
+		changes, err := ListRecentChanges(4 * limit)
+		if err != nil {
+			return nil, err
+		}
+		names := map[string]string{}
+		for _, change := range changes {
+			if change.RSVP != nil {
+				names[change.RSVP.Email], _ = GetCachedFriendName(change.RSVP.Email)
+			}
+		}
+		items = RSVPTriggerItems(changes, fridays, names)
+	case TriggerEventFull:
+		items = []TriggerItem{}
+		for _, friday := range fridays {
+			status, err := GetFridayStatus(friday)
+			if err != nil {
+				return nil, err
+			}
+			if friday.Limit() > 0 && friday.IsOpen() && status.Headcount >= friday.Limit() {
+				item := eventTriggerItem(friday.ID()+"-full", friday, friday.Modified())
+				item.Headcount = status.Headcount
+				items = append(items, item)
+			}
+		}
+		sortTriggerItems(items)
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+var TriggerSchema = Schema{Query: []Param{{Name: "limit", Int: true, Min: 1, Max: atMost(100)}}}
+
+// HandleAPITrigger serves a polling trigger as Zapier expects it, a list of
+// items newest first.
+func HandleAPITrigger(w http.ResponseWriter, r *http.Request) {
+	items, err := triggerItems(mux.Vars(r)["trigger"], RequestInt(r, "limit", TriggerLimit))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+type IFTTTMeta struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type IFTTTItem struct {
+	TriggerItem
+	Meta IFTTTMeta `json:"meta"`
+}
+
+type IFTTTError struct {
+	Message string `json:"message"`
+}
+
+func writeIFTTTError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Errors []IFTTTError `json:"errors"`
+	}{[]IFTTTError{{msg}}})
+}
+
+// HandleIFTTTTrigger serves a polling trigger as IFTTT expects it, with the
+// limit in the request body and the items under data.
+func HandleIFTTTTrigger(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Limit *int `json:"limit"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeIFTTTError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	limit := TriggerLimit
+	if body.Limit != nil {
+		limit = *body.Limit
+	}
+	data := []IFTTTItem{}
+	if limit > 0 {
+		items, err := triggerItems(mux.Vars(r)["trigger"], limit)
+		if err != nil {
+			writeIFTTTError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		for _, item := range items {
+			data = append(data, IFTTTItem{item, IFTTTMeta{item.ID, item.CreatedAt.Unix()}})
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Data []IFTTTItem `json:"data"`
+	}{data})
+}
+
+// HandleIFTTTStatus tells IFTTT the service is up, once it has a valid key.
+func HandleIFTTTStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,47 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTriggerItems(t *testing.T) {
+	// GIVEN an event added after another that starts later
+	later := pizza.Friday{UID: "01g7later0", Start: time.Date(2023, 4, 14, 22, 0, 0, 0, time.UTC), TS: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC).UnixMicro()}
+	sooner := pizza.Friday{UID: "01g7sooner", Start: time.Date(2023, 4, 7, 22, 0, 0, 0, time.UTC), TS: time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC).UnixMicro(), Capacity: 12}
+
+	// WHEN
+	items := pizza.EventTriggerItems([]pizza.Friday{later, sooner})
+
+	// THEN the newest event comes first, keyed by its ID
+	require.Len(t, items, 2)
+	assert.Equal(t, "01g7sooner", items[0].ID)
+	assert.Equal(t, 12, items[0].Capacity)
+	assert.Equal(t, "01g7later0", items[1].ID)
+}
+
+func TestRSVPTriggerItems(t *testing.T) {
+	// GIVEN
+	friday := pizza.Friday{UID: "01g7d2m9r0", Start: time.Date(2023, 4, 7, 22, 0, 0, 0, time.UTC)}
+	at := time.Date(2023, 4, 3, 12, 0, 0, 0, time.UTC)
+	changes := []pizza.Change{
+		{ID: "3", Type: pizza.ChangeRSVPDeleted, FridayID: friday.ID(), At: at.Add(2 * time.Hour), RSVP: &pizza.RSVP{Email: "roy@kent.com"}},
+		{ID: "2", Type: pizza.ChangeRSVPCreated, FridayID: friday.ID(), At: at.Add(time.Hour), RSVP: &pizza.RSVP{Email: "believe@tedlasso.com", PlusOnes: 1}},
+		{ID: "1", Type: pizza.ChangeRSVPCreated, FridayID: "gone", At: at, RSVP: &pizza.RSVP{Email: "jamie@tartt.com"}},
+	}
+
+	// WHEN
+	items := pizza.RSVPTriggerItems(changes, []pizza.Friday{friday}, map[string]string{"believe@tedlasso.com": "Ted Lasso"})
+
+	// THEN only new RSVPs to upcoming events are triggered, keyed by change
+	require.Len(t, items, 1)
+	assert.Equal(t, "2", items[0].ID)
+	assert.Equal(t, "01g7d2m9r0", items[0].EventID)
+	assert.Equal(t, "Ted Lasso", items[0].Name)
+	assert.Equal(t, 1, items[0].PlusOnes)
+	assert.Equal(t, at.Add(time.Hour), items[0].CreatedAt)
+}
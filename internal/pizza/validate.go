@@ -0,0 +1,259 @@
+package pizza
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Param is a query or body field a route accepts. Fields a route doesn't list
+// are left to the handler.
+type Param struct {
+	Name     string
+	Required bool
+	// Int params must be whole numbers of at least Min, and at most Max when
+	// it is set. Max is a func so limits changed on the settings page apply.
+	Int bool
+	Min int
+	Max func() int
+	// MinLen and MaxLen bound the length of other params, ignoring spaces
+	// around them, when set
+	MinLen int
+	MaxLen int
+	Email  bool
+	OneOf  []string
+}
+
+// atMost is a fixed Max for a Param.
+func atMost(n int) func() int {
+	return func() int { return n }
+}
+
+// Schema is what a route accepts. Body params are read from a form post, or
+// from a JSON object when the request is JSON.
+type Schema struct {
+	Query []Param
+	Body  []Param
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is the JSON answer to a request that failed its schema, an
+// APIError with the field errors.
+type ValidationError struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+type InvalidPageData struct {
+	View
+	Fields []FieldError
+}
+
+type paramsKey struct{}
+
+// check is the error with the value, or empty when the param allows it.
+func (p Param) check(val string, ok bool) string {
+	if !ok || len(val) == 0 {
+		if p.Required {
+			return "is required"
+		}
+		return ""
+	}
+	if p.Int {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return "must be a whole number"
+		} else if n < p.Min {
+			return "must be at least " + strconv.Itoa(p.Min)
+		} else if p.Max != nil && n > p.Max() {
+			return "must be at most " + strconv.Itoa(p.Max())
+		}
+		return ""
+	}
+	val = strings.TrimSpace(val)
+	if p.MinLen > 0 && len(val) < p.MinLen {
+		return "must be at least " + strconv.Itoa(p.MinLen) + " characters"
+	} else if p.MaxLen > 0 && len(val) > p.MaxLen {
+		return "must be at most " + strconv.Itoa(p.MaxLen) + " characters"
+	}
+	if p.Email {
+		if addr, err := mail.ParseAddress(val); err != nil || addr.Address != val {
+			return "must be an email address"
+		}
+	}
+	if len(p.OneOf) > 0 && !containsString(p.OneOf, val) {
+		return "must be one of " + strings.Join(p.OneOf, ", ")
+	}
+	return ""
+}
+
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// bodyParams reads the schema's params from the body, leaving it to be read
+// again by the handler. JSON fields that aren't a string, number, or bool are
+// errors, since no param can be an object or list.
+func (s Schema) bodyParams(r *http.Request) (url.Values, []FieldError, error) {
+	if len(s.Body) == 0 || r.Body == nil {
+		return url.Values{}, nil, nil
+	}
+	if !isJSON(r) {
+		if err := r.ParseForm(); err != nil {
+			return nil, nil, err
+		}
+		return r.PostForm, nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	raw := map[string]any{}
+	if err = json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, err
+	}
+	values := url.Values{}
+	fields := []FieldError{}
+	for _, p := range s.Body {
+		switch v := raw[p.Name].(type) {
+		case string:
+			values.Set(p.Name, v)
+		case float64:
+			values.Set(p.Name, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			values.Set(p.Name, strconv.FormatBool(v))
+		case nil:
+		default:
+			fields = append(fields, FieldError{p.Name, "must be a string or number"})
+		}
+	}
+	return values, fields, nil
+}
+
+// Check reads the request's params and returns those that failed the schema.
+// The error is set when the body couldn't be read.
+func (s Schema) Check(r *http.Request) (url.Values, []FieldError, error) {
+	body, fields, err := s.bodyParams(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := url.Values{}
+	for _, set := range []struct {
+		params []Param
+		from   url.Values
+	}{{s.Query, r.URL.Query()}, {s.Body, body}} {
+		for _, p := range set.params {
+			vals, ok := set.from[p.Name]
+			val := ""
+			if ok && len(vals) > 0 {
+				val = vals[0]
+			}
+			if hasFieldError(fields, p.Name) {
+				continue
+			} else if msg := p.check(val, ok); len(msg) > 0 {
+				fields = append(fields, FieldError{p.Name, msg})
+			} else if ok {
+				values[p.Name] = vals
+			}
+		}
+	}
+	return values, fields, nil
+}
+
+func hasFieldError(fields []FieldError, name string) bool {
+	for _, f := range fields {
+		if f.Field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wantsJSON reports whether the client should be answered in JSON instead of
+// with a page.
+func wantsJSON(r *http.Request) bool {
+	for _, prefix := range []string{"/api/", "/hooks/", "/ifttt/"} {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return isJSON(r) || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeInvalid answers 400 with the field errors, in JSON to API clients and
+// as a page to browsers.
+func writeInvalid(w http.ResponseWriter, r *http.Request, fields []FieldError) {
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusBadRequest, ValidationError{"invalid request", fields})
+		return
+	}
+	plate, err := parseTemplate("html/invalid.html")
+	if err != nil {
+		Log.Error("template invalid failure", zap.Error(err))
+		Handle500(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	if err = executeTemplate(w, plate, InvalidPageData{Fields: fields}); err != nil {
+		Log.Error("template execution failure", zap.Error(err))
+	}
+}
+
+// validate checks the request against the route's schema before the handler
+// runs, which then reads the params with RequestParam and RequestInt.
+func validate(schema Schema, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, fields, err := schema.Check(r)
+		if bodyTooLarge(err) {
+			writeTooLarge(w, r)
+			return
+		} else if err != nil {
+			fields = []FieldError{{"body", "is malformed"}}
+		}
+		if len(fields) > 0 {
+			Log.Debug("invalid request", zap.String("path", r.URL.Path), zap.Any("fields", fields))
+			writeInvalid(w, r, fields)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, values)))
+	}
+}
+
+func requestParams(r *http.Request) url.Values {
+	if values, ok := r.Context().Value(paramsKey{}).(url.Values); ok {
+		return values
+	}
+	return url.Values{}
+}
+
+// RequestParam is the validated param, with spaces around it removed.
+func RequestParam(r *http.Request, name string) string {
+	return strings.TrimSpace(requestParams(r).Get(name))
+}
+
+// RequestParams are every value of the validated param.
+func RequestParams(r *http.Request, name string) []string {
+	return requestParams(r)[name]
+}
+
+// RequestInt is the validated Int param, or the default when it wasn't given.
+func RequestInt(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(requestParams(r).Get(name))
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package pizza_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestSchemaCheck(t *testing.T) {
+	// GIVEN
+	schema := pizza.Schema{
+		Query: []pizza.Param{{Name: "q", Required: true, MinLen: 2}, {Name: "limit", Int: true, Min: 1}},
+		Body:  []pizza.Param{{Name: "email", Email: true}, {Name: "size", OneOf: []string{"small", "large"}}},
+	}
+	form := url.Values{"email": {"roy@kent"}, "size": {"large"}}
+	req := httptest.NewRequest(http.MethodPost, "/search?q=+a+&limit=0", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// WHEN
+	values, fields, err := schema.Check(req)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, []pizza.FieldError{
+		{Field: "q", Message: "must be at least 2 characters"},
+		{Field: "limit", Message: "must be at least 1"},
+		{Field: "email", Message: "must be an email address"},
+	}, fields)
+	assert.Equal(t, "large", values.Get("size"))
+}
+
+func TestValidate(t *testing.T) {
+	// GIVEN
+	schema := pizza.Schema{Body: []pizza.Param{
+		{Name: "email", Required: true, Email: true},
+		{Name: "kids", Int: true, Max: func() int { return 2 }},
+	}}
+	handler := pizza.Validate(schema, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, 2, pizza.RequestInt(r, "kids", 0))
+		w.Write([]byte(pizza.RequestParam(r, "email") + " " + string(body)))
+	})
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/rsvp/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// WHEN
+	ok := send(`{"email": "roy@kent.com", "kids": 2, "drinks": {"beer": 6}}`)
+	invalid := send(`{"kids": 3}`)
+	wrongType := send(`{"email": ["roy@kent.com"]}`)
+	malformed := send(`{"email"`)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, ok.Code)
+	assert.Equal(t, `roy@kent.com {"email": "roy@kent.com", "kids": 2, "drinks": {"beer": 6}}`, ok.Body.String())
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.JSONEq(t, `{"error":"invalid request","fields":[{"field":"email","message":"is required"},{"field":"kids","message":"must be at most 2"}]}`, invalid.Body.String())
+	assert.JSONEq(t, `{"error":"invalid request","fields":[{"field":"email","message":"must be a string or number"}]}`, wrongType.Body.String())
+	assert.JSONEq(t, `{"error":"invalid request","fields":[{"field":"body","message":"is malformed"}]}`, malformed.Body.String())
+}
@@ -0,0 +1,168 @@
+package pizza
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	flashCookieName = "pizza_flash"
+	csrfCookieName  = "pizza_csrf"
+)
+
+// View is what every page is rendered with. Page data embeds it and render
+// fills it in, so handlers only set what is particular to their page.
+type View struct {
+	// Friend is the friend the browser is logged in as
+	Friend string
+	// Flashes are messages left for this page by the request before it,
+	// shown once
+	Flashes []string
+	// CSRFToken goes in a csrf field of forms that act as the logged in
+	// friend, checked by checkCSRF
+	CSRFToken string
+}
+
+func (v *View) view() *View {
+	return v
+}
+
+// viewModel is page data that embeds View.
+type viewModel interface {
+	view() *View
+}
+
+// ErrorPageData is the data of the 4xx and 500 pages.
+type ErrorPageData struct {
+	View
+}
+
+type MaintenancePageData struct {
+	View
+}
+
+// csrfToken is the token forms acting as the friend must send back. It is
+// tied to the friend's session, so a page on another site can't know it and
+// it stops working when they log in again.
+func csrfToken(session friendSession) string {
+	if len(session.Email) == 0 {
+		return ""
+	}
+	return SignLink("csrf", session.Email, session.ID)
+}
+
+// checkCSRF reports whether the posted form came from one of our pages shown
+// to the logged in friend.
+func checkCSRF(r *http.Request) bool {
+	return checkCSRFToken(r, r.PostForm.Get("csrf"))
+}
+
+// checkCSRFToken is checkCSRF for a token sent some other way, like in a JSON
+// body.
+func checkCSRFToken(r *http.Request, token string) bool {
+	session, ok := sessionFromCookie(r)
+	return ok && VerifyLink(token, "csrf", session.Email, session.ID)
+}
+
+// browserCSRFToken is the CSRF token of forms that don't act as a logged in
+// friend: the admin pages, which the browser sends the admin password to from
+// any site, and pages opened from an emailed link. It is tied to a random
+// cookie the browser is given with the form, which another site can't read.
+func browserCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) > 0 {
+		return SignLink("form", cookie.Value)
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate csrf cookie: %v", err))
+	}
+	value := base64.RawURLEncoding.EncodeToString(buf)
+	cookie := newCookie(csrfCookieName, value, "/", time.Time{})
+	cookie.SameSite = http.SameSiteStrictMode
+	http.SetCookie(w, cookie)
+	return SignLink("form", value)
+}
+
+// checkBrowserCSRF reports whether the posted form came from one of our pages
+// shown to this browser.
+func checkBrowserCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	return err == nil && VerifyLink(r.PostForm.Get("csrf"), "form", cookie.Value)
+}
+
+// addFlash leaves a message for the next page the browser is shown, usually
+// the one it is redirected to after a form is posted. The messages are kept in
+// a signed cookie, so another site can't put words in our mouth.
+func addFlash(w http.ResponseWriter, r *http.Request, msg string) {
+	value := url.Values{"m": append(readFlashes(r), msg)}.Encode()
+	http.SetCookie(w, newCookie(flashCookieName, value+"."+SignLink("flash", value), "/", time.Time{}))
+}
+
+func readFlashes(r *http.Request) []string {
+	cookie, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return nil
+	}
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 || !VerifyLink(cookie.Value[i+1:], "flash", cookie.Value[:i]) {
+		return nil
+	}
+	q, err := url.ParseQuery(cookie.Value[:i])
+	if err != nil {
+		return nil
+	}
+	return q["m"]
+}
+
+// takeFlashes are the messages left for this page, cleared so they are only
+// shown once.
+func takeFlashes(w http.ResponseWriter, r *http.Request) []string {
+	if _, err := r.Cookie(flashCookieName); err != nil {
+		return nil
+	}
+	clearCookie(w, flashCookieName, "/")
+	return readFlashes(r)
+}
+
+// renderFlashes is the flashes template helper, escaping each message.
+func renderFlashes(flashes []string) string {
+	if len(flashes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<div class="flashes">`)
+	for _, msg := range flashes {
+		b.WriteString(`<p class="flash">` + html.EscapeString(msg) + `</p>`)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// render fills in the page's View and renders it, rendering the 500 page if
+// it can't.
+func render(w http.ResponseWriter, r *http.Request, name string, data viewModel) {
+	plate, err := parseTemplate(name)
+	if err != nil {
+		Log.Error("template failure", zap.Error(err), zap.String("template", name))
+		Handle500(w, r)
+		return
+	}
+	session, _ := sessionFromCookie(r)
+	v := data.view()
+	v.Friend = session.Email
+	v.Flashes = takeFlashes(w, r)
+	v.CSRFToken = csrfToken(session)
+	if err = executeTemplate(w, plate, data); err != nil {
+		Log.Error("template execution failure", zap.Error(err), zap.String("template", name))
+		Handle500(w, r)
+		return
+	}
+}
@@ -0,0 +1,142 @@
+package pizza_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+)
+
+func TestFlashes(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	w := httptest.NewRecorder()
+	pizza.AddFlash(w, httptest.NewRequest(http.MethodPost, "/devices", nil), "Logged out <everywhere>")
+	req := httptest.NewRequest(http.MethodGet, "/nope", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	// WHEN
+	page := httptest.NewRecorder()
+	pizza.Handle4xx(page, req)
+
+	// THEN the flash is shown once, escaped
+	assert.Contains(t, page.Body.String(), `<p class="flash">Logged out &lt;everywhere&gt;</p>`)
+	cleared := page.Result().Cookies()
+	assert.Len(t, cleared, 1)
+	assert.Equal(t, "pizza_flash", cleared[0].Name)
+	assert.Less(t, cleared[0].MaxAge, 0)
+}
+
+func TestCheckCSRF(t *testing.T) {
+	// GIVEN
+	email := "believe@tedlasso.com"
+	session := pizza.NewFriendSession(email)
+	post := func(token string) *http.Request {
+		form := url.Values{"csrf": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/labs", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(pizza.FriendSessionCookie(session))
+		req.ParseForm()
+		return req
+	}
+
+	// THEN
+	assert.True(t, pizza.CheckCSRF(post(pizza.CSRFToken(session))))
+	assert.False(t, pizza.CheckCSRF(post(pizza.CSRFToken(pizza.NewFriendSession("roy@kent.com")))))
+	assert.False(t, pizza.CheckCSRF(post("")))
+	assert.Equal(t, "", pizza.CSRFToken(pizza.NewFriendSession("")))
+
+	// THEN a token from before the friend logged in again doesn't work
+	assert.False(t, pizza.CheckCSRF(post(pizza.CSRFToken(pizza.NewFriendSession(email)))))
+}
+
+func TestCheckBrowserCSRF(t *testing.T) {
+	// GIVEN a browser shown a form
+	w := httptest.NewRecorder()
+	token := pizza.BrowserCSRFToken(w, httptest.NewRequest(http.MethodGet, "/digest", nil))
+	cookies := w.Result().Cookies()
+	post := func(token string, cookies []*http.Cookie) *http.Request {
+		form := url.Values{"csrf": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/digest", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		req.ParseForm()
+		return req
+	}
+
+	// THEN only that browser can post it back
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.True(t, pizza.CheckBrowserCSRF(post(token, cookies)))
+	assert.False(t, pizza.CheckBrowserCSRF(post(token, nil)))
+	assert.False(t, pizza.CheckBrowserCSRF(post("", cookies)))
+	other := httptest.NewRecorder()
+	pizza.BrowserCSRFToken(other, httptest.NewRequest(http.MethodGet, "/digest", nil))
+	assert.False(t, pizza.CheckBrowserCSRF(post(token, other.Result().Cookies())))
+
+	// WHEN the browser is shown another form
+	again := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/digest", nil)
+	req.AddCookie(cookies[0])
+
+	// THEN it keeps its cookie and token
+	assert.Equal(t, token, pizza.BrowserCSRFToken(again, req))
+	assert.Empty(t, again.Result().Cookies())
+}
+
+func TestRequireAdminCSRF(t *testing.T) {
+	// GIVEN
+	pizza.StaticDir = "../../static"
+	w := httptest.NewRecorder()
+	token := pizza.BrowserCSRFToken(w, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	handler := pizza.RequireAdminCSRF(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("saved"))
+	})
+	post := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{"csrf": {token}, "capacity": {"12"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		res := httptest.NewRecorder()
+		handler(res, req)
+		return res
+	}
+
+	// WHEN a page on another site posts the form
+	forged := post("")
+
+	// THEN
+	assert.NotEqual(t, http.StatusOK, forged.Code)
+	assert.NotContains(t, forged.Body.String(), "saved")
+
+	// WHEN the admin posts it from the page
+	res := post(token)
+
+	// THEN
+	assert.Equal(t, "saved", res.Body.String())
+}
+
+func TestFlashesForged(t *testing.T) {
+	// GIVEN a flash this site didn't leave
+	pizza.StaticDir = "../../static"
+	req := httptest.NewRequest(http.MethodGet, "/nope", nil)
+	req.AddCookie(&http.Cookie{Name: "pizza_flash", Value: "m=Your+RSVP+is+cancelled.sig"})
+
+	// WHEN
+	page := httptest.NewRecorder()
+	pizza.Handle4xx(page, req)
+
+	// THEN
+	assert.NotContains(t, page.Body.String(), "cancelled")
+}
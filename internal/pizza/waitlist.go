@@ -0,0 +1,90 @@
+package pizza
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Waitlist lets RSVPs past a party's limit in automatically, first come first
+// served, as room opens up, instead of waiting for the host to approve them.
+var Waitlist = false
+
+// WaitlistPromotions are the RSVPs waiting for a spot that now fit under the
+// limit, in the order they were made. The first in line is never skipped for
+// a smaller RSVP behind them.
+func WaitlistPromotions(rsvps []RSVP, limit int) []RSVP {
+	promoted := []RSVP{}
+	if limit <= 0 {
+		return promoted
+	}
+	waiting := []RSVP{}
+	for _, rsvp := range rsvps {
+		if rsvp.Status == RSVPStatusPending {
+			waiting = append(waiting, rsvp)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool { return waiting[i].UpdatedAt.Before(waiting[j].UpdatedAt) })
+	headcount := Headcount(rsvps)
+	for _, rsvp := range waiting {
+		if headcount+rsvp.Guests() > limit {
+			break
+		}
+		headcount += rsvp.Guests()
+		promoted = append(promoted, rsvp)
+	}
+	return promoted
+}
+
+// PromoteWaitlist lets in the friends on the party's waitlist that fit now,
+// invites them, and emails them they're in.
+func PromoteWaitlist(friday Friday) {
+	if !Waitlist || !time.Now().Before(friday.Start) {
+		return
+	}
+	rsvps, err := ListFridayRSVPs(friday.ID())
+	if err != nil {
+		return
+	}
+	for _, rsvp := range WaitlistPromotions(rsvps, friday.Limit()) {
+		rsvp.Status = RSVPStatusConfirmed
+		// saving first means only one promotion wins if RSVPs change at once
+		if err = UpdateFridayRSVP(rsvp); err != nil {
+			Log.Warn("failed to promote waitlisted rsvp", zap.Error(err), zap.String("id", rsvp.ID))
+			return
+		}
+		if CalendarBackedUp() {
+			err = errors.New("calendar is backed up")
+		} else {
+			err = inviteToFriday(rsvp, friday)
+		}
+		if err != nil {
+			Log.Warn("queueing calendar invite", zap.Error(err), zap.String("eventID", friday.ID()), zap.String("email", rsvp.Email))
+			rsvp.InvitePending = true
+			if err = UpdateFridayRSVP(rsvp); err != nil {
+				Log.Warn("failed to queue invite", zap.Error(err), zap.String("id", rsvp.ID))
+			}
+		}
+		Log.Info("promoted waitlisted rsvp", zap.String("id", rsvp.ID), zap.String("friday", friday.ID()))
+		rsvpsChanged(ChangeRSVPUpdated, friday.ID())
+
+		name, _ := GetCachedFriendName(rsvp.Email)
+		msg, err := RenderEmail("review", ReviewEmailData{
+			Name:     name,
+			Date:     FormatTime(friday.Start),
+			Approved: true,
+			Waitlist: true,
+			EditURL:  BaseURL + EditRSVPURL(rsvp),
+		})
+		if err != nil {
+			Log.Error("review template failure", zap.Error(err))
+			continue
+		}
+		msg.To = rsvp.Email
+		if err = SendEmail(msg); err != nil {
+			Log.Warn("failed to send waitlist email", zap.Error(err), zap.String("email", rsvp.Email))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pizza_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitlistPromotions(t *testing.T) {
+	// GIVEN a party for 6 with 4 coming and three friends waiting
+	at := time.Unix(1680903000, 0)
+	rsvps := []pizza.RSVP{
+		{ID: "1", Status: pizza.RSVPStatusConfirmed, PlusOnes: 3},
+		{ID: "3", Status: pizza.RSVPStatusPending, UpdatedAt: at.Add(2 * time.Minute)},
+		{ID: "2", Status: pizza.RSVPStatusPending, UpdatedAt: at.Add(time.Minute)},
+		{ID: "4", Status: pizza.RSVPStatusPending, UpdatedAt: at.Add(3 * time.Minute)},
+	}
+
+	// WHEN
+	promoted := pizza.WaitlistPromotions(rsvps, 6)
+
+	// THEN the first two in line get in
+	assert.Len(t, promoted, 2)
+	assert.Equal(t, "2", promoted[0].ID)
+	assert.Equal(t, "3", promoted[1].ID)
+}
+
+func TestWaitlistPromotionsInOrder(t *testing.T) {
+	// GIVEN the first in line brings more than there is room for
+	at := time.Unix(1680903000, 0)
+	rsvps := []pizza.RSVP{
+		{ID: "1", Status: pizza.RSVPStatusConfirmed, PlusOnes: 4},
+		{ID: "2", Status: pizza.RSVPStatusPending, PlusOnes: 1, UpdatedAt: at},
+		{ID: "3", Status: pizza.RSVPStatusPending, UpdatedAt: at.Add(time.Minute)},
+	}
+
+	// THEN nobody skips ahead of them
+	assert.Empty(t, pizza.WaitlistPromotions(rsvps, 6))
+	assert.Empty(t, pizza.WaitlistPromotions(rsvps, 0))
+}
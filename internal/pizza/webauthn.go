@@ -0,0 +1,250 @@
+package pizza
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	// WebAuthnRPID is the domain passkeys are bound to, taken from the base URL.
+	// Passkeys are off when it is empty.
+	WebAuthnRPID string
+	// WebAuthnOrigin is the origin browsers report for the site.
+	WebAuthnOrigin string
+	// WebAuthnChallengeTTL is how long the browser has to use a passkey.
+	WebAuthnChallengeTTL = 5 * time.Minute
+)
+
+var ErrWebAuthn = errors.New("passkey could not be verified")
+
+const (
+	coseAlgES256 = -7
+	coseAlgRS256 = -257
+
+	authFlagUserPresent = 0x01
+	authFlagAttested    = 0x40
+)
+
+func initWebAuthn(baseURL string) {
+	WebAuthnRPID, WebAuthnOrigin = "", ""
+	u, err := url.Parse(baseURL)
+	if err != nil || len(u.Hostname()) == 0 {
+		return
+	}
+	WebAuthnRPID = u.Hostname()
+	WebAuthnOrigin = u.Scheme + "://" + u.Host
+}
+
+func PasskeysEnabled() bool {
+	return len(WebAuthnRPID) > 0
+}
+
+// challenges live in memory like login codes, each can be used once
+var (
+	challengeMu sync.Mutex
+	challenges  = map[string]challenge{}
+)
+
+type challenge struct {
+	purpose string
+	made    time.Time
+}
+
+// NewWebAuthnChallenge makes a challenge for a passkey ceremony. The purpose
+// ties it to what it is for, e.g. registering a passkey for one friend.
+func NewWebAuthnChallenge(purpose string) string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("could not generate challenge: %v", err))
+	}
+	c := base64.RawURLEncoding.EncodeToString(buf)
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	for k, v := range challenges {
+		if time.Since(v.made) > WebAuthnChallengeTTL {
+			delete(challenges, k)
+		}
+	}
+	challenges[c] = challenge{purpose: purpose, made: time.Now()}
+	return c
+}
+
+func takeWebAuthnChallenge(c, purpose string) bool {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	v, ok := challenges[c]
+	if !ok {
+		return false
+	}
+	delete(challenges, c)
+	return v.purpose == purpose && time.Since(v.made) <= WebAuthnChallengeTTL
+}
+
+// VerifyClientData checks the browser's client data for a ceremony of the type,
+// "webauthn.create" or "webauthn.get", and uses up its challenge.
+func VerifyClientData(clientDataJSON []byte, typ, purpose string) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ErrWebAuthn
+	}
+	if clientData.Type != typ || clientData.Origin != WebAuthnOrigin {
+		return ErrWebAuthn
+	}
+	if !takeWebAuthnChallenge(clientData.Challenge, purpose) {
+		return ErrWebAuthn
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	flags     byte
+	signCount uint32
+	credID    []byte
+	publicKey []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrWebAuthn
+	}
+	rpIDHash := sha256.Sum256([]byte(WebAuthnRPID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrWebAuthn
+	}
+	auth := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if auth.flags&authFlagUserPresent == 0 {
+		return nil, ErrWebAuthn
+	}
+	if auth.flags&authFlagAttested == 0 {
+		return auth, nil
+	}
+	// aaguid, then the length of the credential id
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, ErrWebAuthn
+	}
+	n := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < n {
+		return nil, ErrWebAuthn
+	}
+	auth.credID = rest[:n]
+	_, after, err := decodeCBOR(rest[n:])
+	if err != nil {
+		return nil, ErrWebAuthn
+	}
+	auth.publicKey = rest[n : len(rest)-len(after)]
+	return auth, nil
+}
+
+// VerifyRegistration reads the new credential out of an attestation. The
+// attestation statement isn't checked, any authenticator is welcome.
+func VerifyRegistration(attestationObject []byte) (*Passkey, error) {
+	obj, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, ErrWebAuthn
+	}
+	m, _ := obj.(map[interface{}]interface{})
+	authData, _ := m["authData"].([]byte)
+	auth, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if len(auth.credID) == 0 {
+		return nil, ErrWebAuthn
+	}
+	if _, _, err = parseCOSEKey(auth.publicKey); err != nil {
+		return nil, err
+	}
+	return &Passkey{
+		ID:        base64.RawURLEncoding.EncodeToString(auth.credID),
+		PublicKey: base64.RawURLEncoding.EncodeToString(auth.publicKey),
+		SignCount: auth.signCount,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// VerifyAssertion checks a login with the passkey and returns the
+// authenticator's new signature count.
+func VerifyAssertion(key Passkey, clientDataJSON, authData, signature []byte) (uint32, error) {
+	auth, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+	coseKey, err := base64.RawURLEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return 0, ErrWebAuthn
+	}
+	pub, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	switch alg {
+	case coseAlgES256:
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature) {
+			return 0, ErrWebAuthn
+		}
+	case coseAlgRS256:
+		if rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+			return 0, ErrWebAuthn
+		}
+	}
+	// a count that doesn't go up means the passkey may have been cloned
+	if auth.signCount != 0 && auth.signCount <= key.SignCount {
+		return 0, ErrWebAuthn
+	}
+	return auth.signCount, nil
+}
+
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	obj, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, ErrWebAuthn
+	}
+	m, _ := obj.(map[interface{}]interface{})
+	alg, _ := m[int64(3)].(int64)
+	switch alg {
+	case coseAlgES256:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if m[int64(1)] != int64(2) || m[int64(-1)] != int64(1) || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrWebAuthn
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, ErrWebAuthn
+		}
+		return pub, alg, nil
+	case coseAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if m[int64(1)] != int64(3) || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrWebAuthn
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	}
+	return nil, 0, ErrWebAuthn
+}
@@ -0,0 +1,82 @@
+package pizza_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/mpoegel/rsvp.pizza/internal/pizza"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAuthData(flags byte, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte("rsvp.pizza"))
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], signCount)
+	return append(data, attested...)
+}
+
+func testCOSEKey(pub *ecdsa.PublicKey) []byte {
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	key = append(key, pub.X.FillBytes(make([]byte, 32))...)
+	key = append(key, 0x22, 0x58, 0x20)
+	return append(key, pub.Y.FillBytes(make([]byte, 32))...)
+}
+
+func TestPasskey(t *testing.T) {
+	// GIVEN
+	pizza.WebAuthnRPID = "rsvp.pizza"
+	pizza.WebAuthnOrigin = "https://rsvp.pizza"
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credID := []byte("credential-1")
+	attested := append(make([]byte, 16), 0, byte(len(credID)))
+	attested = append(append(attested, credID...), testCOSEKey(&priv.PublicKey)...)
+	authData := testAuthData(0x41, 0, attested)
+	attestation := []byte{0xa3, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0,
+		0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59, byte(len(authData) >> 8), byte(len(authData))}
+	attestation = append(attestation, authData...)
+
+	// WHEN
+	key, err := pizza.VerifyRegistration(attestation)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, "Y3JlZGVudGlhbC0x", key.ID)
+
+	// GIVEN
+	challenge := pizza.NewWebAuthnChallenge("login")
+	clientData := []byte(fmt.Sprintf(`{"type":"webauthn.get","challenge":%q,"origin":"https://rsvp.pizza"}`, challenge))
+	loginData := testAuthData(0x05, 7, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, loginData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	require.NoError(t, err)
+
+	// WHEN
+	clientErr := pizza.VerifyClientData(clientData, "webauthn.get", "login")
+	replayErr := pizza.VerifyClientData(clientData, "webauthn.get", "login")
+	signCount, err := pizza.VerifyAssertion(*key, clientData, loginData, sig)
+
+	// THEN
+	assert.NoError(t, clientErr)
+	assert.Equal(t, pizza.ErrWebAuthn, replayErr)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), signCount)
+
+	// WHEN
+	key.SignCount = 7
+	_, clonedErr := pizza.VerifyAssertion(*key, clientData, loginData, sig)
+	sig[len(sig)-1] ^= 0xff
+	key.SignCount = 0
+	_, badSigErr := pizza.VerifyAssertion(*key, clientData, loginData, sig)
+
+	// THEN
+	assert.Equal(t, pizza.ErrWebAuthn, clonedErr)
+	assert.Equal(t, pizza.ErrWebAuthn, badSigErr)
+}
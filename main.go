@@ -4,7 +4,6 @@ import (
 	"context"
 	"flag"
 	"os"
-	"os/signal"
 
 	"github.com/mpoegel/rsvp.pizza/internal/pizza"
 	"go.uber.org/zap"
@@ -12,26 +11,55 @@ import (
 
 func main() {
 	configFile := flag.String("config", "configs/pizza.yaml", "config file")
+	buildAssets := flag.Bool("build-assets", false, "write the asset manifest and exit")
+	skipChecks := flag.Bool("skip-checks", false, "start without checking the database, calendar, and templates")
+	reloadTemplates := flag.Bool("reload-templates", false, "read the templates from disk on every request, to work on them")
 	flag.Parse()
+	pizza.ReloadTemplates = *reloadTemplates
+	if *reloadTemplates && len(pizza.StaticDir) == 0 {
+		// the built in templates never change
+		pizza.StaticDir = "static"
+	}
+	if *buildAssets {
+		dir := pizza.StaticDir
+		if len(dir) == 0 {
+			dir = "static"
+		}
+		if err := pizza.WriteAssetManifest(dir); err != nil {
+			pizza.Log.Fatal("could not build assets", zap.Error(err))
+		}
+		return
+	}
 	config, err := pizza.LoadConfig(*configFile)
 	if err != nil {
 		pizza.Log.Fatal("could not load config", zap.Error(err))
 	}
-	if err := pizza.InitCalendarClient(config.Calendar.CredentialFile, config.Calendar.TokenFile, config.Calendar.ID, context.Background()); err != nil {
+	if config.Sandbox {
+		// the sandbox makes up its data, so there's nothing else to set up
+		*skipChecks = true
+	} else if err := pizza.OpenStore(config.Database); err != nil {
+		pizza.Log.Fatal("could not open the database", zap.Error(err))
+	} else if err := pizza.InitCalendar(config.Calendar, context.Background()); err != nil {
 		pizza.Log.Fatal("failed to init calendar client", zap.Error(err))
+	} else if err := pizza.InitSheetsClient(config.Calendar.CredentialFile, config.Calendar.TokenFile, config.Sheets, context.Background()); err != nil {
+		pizza.Log.Fatal("failed to init sheets client", zap.Error(err))
+	}
+	if !*skipChecks {
+		if err := pizza.RunStartupChecks(); err != nil {
+			pizza.Log.Fatal(err.Error())
+		}
+	}
+	pizza.InitMailer(config.Email)
+	if err := pizza.InitMQTT(config.MQTT); err != nil {
+		pizza.Log.Fatal("failed to init mqtt publisher", zap.Error(err))
 	}
 	server, err := pizza.NewServer(config)
 	if err != nil {
 		pizza.Log.Fatal("could not create server", zap.Error(err))
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	go func() {
-		<-c
-		pizza.Log.Info("shutting down")
-		server.Stop()
-	}()
-
-	server.Start()
+	// runs until SIGINT or SIGTERM, after the requests in flight are done
+	if err := server.Start(); err != nil {
+		os.Exit(1)
+	}
 }
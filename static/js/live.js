@@ -0,0 +1,8 @@
+// Reload the page every minute so guests checking in or running late show up,
+// unless someone is typing in one of its forms.
+setInterval(() => {
+    const active = document.activeElement;
+    if (!active || !["INPUT", "TEXTAREA", "SELECT"].includes(active.tagName)) {
+        location.reload();
+    }
+}, 60000);
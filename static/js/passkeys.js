@@ -0,0 +1,72 @@
+function toBase64URL(buf) {
+    return btoa(String.fromCharCode(...new Uint8Array(buf)))
+        .replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(s) {
+    const bin = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+    return Uint8Array.from(bin, c => c.charCodeAt(0));
+}
+
+async function post(url, body) {
+    const resp = await fetch(url, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(body),
+    });
+    if (!resp.ok) {
+        throw new Error((await resp.json()).error || resp.statusText);
+    }
+}
+
+async function registerPasskey(button) {
+    const d = button.dataset;
+    const cred = await navigator.credentials.create({
+        publicKey: {
+            challenge: fromBase64URL(d.challenge),
+            rp: { id: d.rpid, name: "rsvp.pizza" },
+            user: { id: fromBase64URL(d.user), name: d.email, displayName: d.email },
+            pubKeyCredParams: [{ type: "public-key", alg: -7 }, { type: "public-key", alg: -257 }],
+            authenticatorSelection: { residentKey: "required", userVerification: "preferred" },
+            attestation: "none",
+        },
+    });
+    await post("/passkeys/register", {
+        name: document.getElementById("passkey-name").value,
+        clientDataJSON: toBase64URL(cred.response.clientDataJSON),
+        attestationObject: toBase64URL(cred.response.attestationObject),
+        csrf: d.csrf,
+    });
+    location.reload();
+}
+
+async function loginWithPasskey(button) {
+    const d = button.dataset;
+    const cred = await navigator.credentials.get({
+        publicKey: {
+            challenge: fromBase64URL(d.challenge),
+            rpId: d.rpid,
+            userVerification: "preferred",
+        },
+    });
+    await post("/passkeys/login", {
+        id: toBase64URL(cred.rawId),
+        clientDataJSON: toBase64URL(cred.response.clientDataJSON),
+        authenticatorData: toBase64URL(cred.response.authenticatorData),
+        signature: toBase64URL(cred.response.signature),
+    });
+    location.href = "/";
+}
+
+for (const [id, action] of [["passkey-register", registerPasskey], ["passkey-login", loginWithPasskey]]) {
+    const button = document.getElementById(id);
+    if (!button || !window.PublicKeyCredential) {
+        continue;
+    }
+    button.hidden = false;
+    button.addEventListener("click", () => {
+        action(button).catch(err => {
+            document.getElementById("passkey-error").textContent = "That didn't work: " + err.message;
+        });
+    });
+}
@@ -0,0 +1,20 @@
+// Drag guests between tables on the seating page. Dropping a guest on a table
+// picks that table in their select, so saving the form works without this.
+let dragged = null;
+
+for (const seat of document.querySelectorAll("#seating .seat")) {
+    seat.addEventListener("dragstart", () => { dragged = seat; });
+    seat.addEventListener("dragend", () => { dragged = null; });
+}
+
+for (const table of document.querySelectorAll("#seating .table")) {
+    table.addEventListener("dragover", (e) => e.preventDefault());
+    table.addEventListener("drop", (e) => {
+        e.preventDefault();
+        if (!dragged) {
+            return;
+        }
+        table.appendChild(dragged);
+        dragged.querySelector("select").value = table.dataset.table;
+    });
+}
@@ -0,0 +1,10 @@
+// Package static holds the templates and assets, built into the binary so
+// the server runs without the static directory next to it.
+package static
+
+import "embed"
+
+// FS is the static directory as it was when the binary was built.
+//
+//go:embed css email html js
+var FS embed.FS